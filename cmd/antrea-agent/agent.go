@@ -16,18 +16,31 @@ package main
 
 import (
 	"fmt"
+	"net"
+	"os"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 
 	"github.com/vmware-tanzu/antrea/pkg/agent"
 	"github.com/vmware-tanzu/antrea/pkg/agent/cniserver"
 	_ "github.com/vmware-tanzu/antrea/pkg/agent/cniserver/ipam"
+	"github.com/vmware-tanzu/antrea/pkg/agent/config"
+	"github.com/vmware-tanzu/antrea/pkg/agent/controller/ipseccertificate"
 	"github.com/vmware-tanzu/antrea/pkg/agent/controller/networkpolicy"
 	"github.com/vmware-tanzu/antrea/pkg/agent/controller/noderoute"
 	"github.com/vmware-tanzu/antrea/pkg/agent/interfacestore"
+	"github.com/vmware-tanzu/antrea/pkg/agent/iptables"
 	"github.com/vmware-tanzu/antrea/pkg/agent/openflow"
+	"github.com/vmware-tanzu/antrea/pkg/agent/proxy"
 	"github.com/vmware-tanzu/antrea/pkg/k8s"
 	"github.com/vmware-tanzu/antrea/pkg/monitor"
 	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
@@ -39,6 +52,9 @@ import (
 // Same as in https://github.com/kubernetes/sample-controller/blob/master/main.go
 const informerDefaultResync time.Duration = 30 * time.Second
 
+// How long to wait before retrying to complete the flow restoration or to delete the stale flows.
+const flowReplayRetryInterval = 5 * time.Second
+
 // run starts Antrea agent with the given options and waits for termination signal.
 func run(o *Options) error {
 	klog.Infof("Starting Antrea agent (version %s)", version.GetFullVersion())
@@ -55,33 +71,55 @@ func run(o *Options) error {
 		return fmt.Errorf("error creating Antrea client: %v", err)
 	}
 
-	// Create ovsdb and openflow clients.
+	// Create ovsdb and openflow clients. The ovsdb connection is re-established if ovsdb-server restarts.
 	ovsdbConnection, err := ovsconfig.NewOVSDBConnectionUDS("")
 	if err != nil {
-		// TODO: ovsconfig.NewOVSDBConnectionUDS might return timeout in the future, need to add retry
 		return fmt.Errorf("error connecting OVSDB: %v", err)
 	}
 	defer ovsdbConnection.Close()
 
 	ovsBridgeClient := ovsconfig.NewOVSBridge(o.config.OVSBridge, o.config.OVSDatapathType, ovsdbConnection)
 
-	ofClient := openflow.NewClient(o.config.OVSBridge)
+	ofClient := openflow.NewClient(o.config.OVSBridge, o.config.OpenFlowBackend, o.config.EnableProxy)
+
+	// Create an event recorder to report the events of the Node, e.g. the flow replays after ovs-vswitchd restarted.
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: k8sClient.CoreV1().Events("")})
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "antrea-agent"})
 
 	// Create an ifaceStore that caches network interfaces managed by this node.
 	ifaceStore := interfacestore.NewInterfaceStore()
 
+	// The TrafficEncapMode, the EncryptionMode, the IPSecAuthenticationMode and the TransportInterfaceCIDR are checked
+	// in option.validate.
+	encapMode, _ := config.GetTrafficEncapModeFromStr(o.config.TrafficEncapMode)
+	encryptionMode, _ := config.GetTrafficEncryptionModeFromStr(o.config.EncryptionMode)
+	ipsecAuthenticationMode, _ := config.GetIPSecAuthenticationModeFromStr(o.config.IPSecAuthenticationMode)
+	networkConfig := &config.NetworkConfig{
+		TrafficEncapMode:        encapMode,
+		TunnelType:              ovsconfig.TunnelType(o.config.TunnelType),
+		TrafficEncryptionMode:   encryptionMode,
+		IPSecAuthenticationMode: ipsecAuthenticationMode,
+		WireGuardPort:           o.config.WireGuardPort,
+		TransportInterface:      o.config.TransportInterface,
+	}
+	if o.config.TransportInterfaceCIDR != "" {
+		_, networkConfig.TransportInterfaceCIDR, _ = net.ParseCIDR(o.config.TransportInterfaceCIDR)
+	}
+
 	// Initialize agent and node network.
 	agentInitializer := agent.NewInitializer(
 		ovsBridgeClient,
 		ofClient,
 		k8sClient,
+		recorder,
 		ifaceStore,
 		o.config.OVSBridge,
 		o.config.ServiceCIDR,
 		o.config.HostGateway,
 		o.config.DefaultMTU,
-		ovsconfig.TunnelType(o.config.TunnelType),
-		o.config.EnableIPSecTunnel)
+		networkConfig,
+		o.config.ProxyAll)
 	err = agentInitializer.Initialize()
 	if err != nil {
 		return fmt.Errorf("error initializing agent: %v", err)
@@ -91,20 +129,63 @@ func run(o *Options) error {
 	nodeRouteController := noderoute.NewNodeRouteController(k8sClient,
 		informerFactory,
 		ofClient,
-		nodeConfig)
+		ovsBridgeClient,
+		ifaceStore,
+		agentInitializer.GetIPTablesClients(),
+		agentInitializer.GetWireGuardClient(),
+		networkConfig,
+		nodeConfig,
+		recorder)
+
+	// Keep the ofports of the interfaces in ifaceStore in sync with OVS, e.g. when an interface is deleted from OVS.
+	// The NodeRouteController creates the IPSec tunnel interfaces again, and updates the flows which use them.
+	ovsInterfaceEventHandler := interfacestore.NewOVSInterfaceEventHandler(ifaceStore, nodeRouteController.HandleInterfaceOFPortChange)
+	if err := ovsBridgeClient.MonitorInterfaces(ovsInterfaceEventHandler); err != nil {
+		return fmt.Errorf("error monitoring OVS interfaces: %v", err)
+	}
+
+	// The desired state is replayed once the initial sync of these controllers is done.
+	initialSyncedChs := []<-chan struct{}{nodeRouteController.InitialSynced()}
+
+	var proxier *proxy.Proxier
+	if o.config.EnableProxy {
+		// With proxyAll, the traffic of the NodePorts and of the LoadBalancer ingress IPs is steered to OVS by
+		// iptables rules. The Antrea proxy only supports IPv4, which is checked by the agentInitializer.
+		var externalPortSyncer proxy.ExternalPortSyncer
+		if o.config.ProxyAll {
+			iptablesClient, err := iptables.NewClient(o.config.HostGateway, false, networkConfig, true)
+			if err != nil {
+				return fmt.Errorf("error creating iptables client: %v", err)
+			}
+			externalPortSyncer = iptablesClient
+		}
+		proxier = proxy.NewProxier(informerFactory, ofClient, nodeConfig.Name, externalPortSyncer)
+		initialSyncedChs = append(initialSyncedChs, proxier.InitialSynced())
+	}
 
-	networkPolicyController := networkpolicy.NewNetworkPolicyController(antreaClient, ofClient, ifaceStore, nodeConfig.Name, nodeConfig.IP.String())
+	var gatewayIPs []string
+	for _, gatewayIP := range nodeConfig.GatewayConfig.IPs() {
+		gatewayIPs = append(gatewayIPs, gatewayIP.String())
+	}
+	networkPolicyController := networkpolicy.NewNetworkPolicyController(antreaClient, ofClient, ifaceStore, nodeConfig.Name, gatewayIPs)
+	initialSyncedChs = append(initialSyncedChs, networkPolicyController.InitialSynced())
 
+	// The CNI server only watches the Pods of the Node.
+	localPodInformerFactory := informers.NewSharedInformerFactoryWithOptions(k8sClient, informerDefaultResync,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeConfig.Name).String()
+		}))
 	cniServer := cniserver.New(
 		o.config.CNISocket,
 		o.config.HostProcPathPrefix,
-		o.config.DefaultMTU,
+		nodeConfig.NodeMTU,
 		o.config.OVSDatapathType,
 		nodeConfig,
 		ovsBridgeClient,
 		ofClient,
 		ifaceStore,
-		k8sClient)
+		k8sClient,
+		localPodInformerFactory.Core().V1().Pods())
 	err = cniServer.Initialize()
 	if err != nil {
 		return fmt.Errorf("error initializing CNI server: %v", err)
@@ -118,12 +199,35 @@ func run(o *Options) error {
 	go cniServer.Run(stopCh)
 
 	informerFactory.Start(stopCh)
+	localPodInformerFactory.Start(stopCh)
 
 	go nodeRouteController.Run(stopCh)
 
+	if networkConfig.TrafficEncryptionMode == config.TrafficEncryptionModeIPSec &&
+		networkConfig.IPSecAuthenticationMode == config.IPSecAuthenticationModeCert {
+		// The CertificateSigningRequests are created with the ServiceAccount token bound to the Agent Pod, and the
+		// IPSec CA is published in the Namespace of Antrea.
+		csrClient, err := k8s.CreateBoundTokenClient(o.config.ClientConnection, ipseccertificate.BoundTokenPath)
+		if err != nil {
+			return fmt.Errorf("error creating K8s client for the IPSec certificate: %v", err)
+		}
+		ipsecCertController := ipseccertificate.NewIPSecCertificateController(csrClient, ovsBridgeClient, nodeConfig.Name, os.Getenv("POD_NAMESPACE"))
+		go ipsecCertController.Run(stopCh)
+	}
+
+	if proxier != nil {
+		go proxier.Run(stopCh)
+	}
+
 	go networkPolicyController.Run(stopCh)
 
-	agentMonitor := monitor.NewAgentMonitor(crdClient, o.config.OVSBridge, nodeConfig.Name, nodeConfig.PodCIDR.String(), ifaceStore, ofClient, ovsBridgeClient)
+	go completeFlowReplay(agentInitializer, ofClient, stopCh, initialSyncedChs...)
+
+	var podCIDRs []string
+	for _, podCIDR := range nodeConfig.PodCIDRs() {
+		podCIDRs = append(podCIDRs, podCIDR.String())
+	}
+	agentMonitor := monitor.NewAgentMonitor(crdClient, o.config.OVSBridge, nodeConfig.Name, podCIDRs, networkConfig.TrafficEncapMode.String(), ifaceStore, ofClient, ovsBridgeClient, nodeRouteController)
 
 	go agentMonitor.Run(stopCh)
 
@@ -131,3 +235,34 @@ func run(o *Options) error {
 	klog.Info("Stopping Antrea agent")
 	return nil
 }
+
+// completeFlowReplay waits for the desired state to be replayed, then lets ovs-vswitchd use the replayed flows and
+// deletes the flows left by the previous Agent. The flows of the local Pods are replayed by cniServer.Initialize, and
+// the flows of the other Nodes, of the Services and of the NetworkPolicies by the initial sync of nodeRouteController,
+// of the Proxier and of networkPolicyController, whose initialSyncedChs are closed once it is done. Both steps are
+// retried until they succeed, and the stale flows are only deleted once ovs-vswitchd uses the replayed flows.
+func completeFlowReplay(agentInitializer *agent.Initializer, ofClient openflow.Client, stopCh <-chan struct{}, initialSyncedChs ...<-chan struct{}) {
+	for _, initialSynced := range initialSyncedChs {
+		select {
+		case <-initialSynced:
+		case <-stopCh:
+			return
+		}
+	}
+	if err := wait.PollImmediateUntil(flowReplayRetryInterval, func() (bool, error) {
+		if err := agentInitializer.FlowRestoreComplete(); err != nil {
+			klog.Errorf("Failed to complete flow restoration, retrying: %v", err)
+			return false, nil
+		}
+		return true, nil
+	}, stopCh); err != nil {
+		return
+	}
+	wait.PollImmediateUntil(flowReplayRetryInterval, func() (bool, error) {
+		if err := ofClient.DeleteStaleFlows(); err != nil {
+			klog.Errorf("Failed to delete stale flows from the previous round, retrying: %v", err)
+			return false, nil
+		}
+		return true, nil
+	}, stopCh)
+}
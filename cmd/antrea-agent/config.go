@@ -36,17 +36,43 @@ type AgentConfig struct {
 	// 'system' is the default value and corresponds to the kernel datapath. Use 'netdev' to run
 	// OVS in userspace mode. Userspace mode requires the tun device driver to be available.
 	OVSDatapathType string `yaml:"ovsDatapathType,omitempty"`
+	// Backend used by antrea-agent to program OpenFlow flows on the OVS bridge. Supported values are:
+	// - native
+	// - ovs-ofctl
+	// 'native' is the default value: the agent talks OpenFlow 1.3 directly to the management socket
+	// of the bridge. 'ovs-ofctl' runs one ovs-ofctl command for each flow operation.
+	OpenFlowBackend string `yaml:"openflowBackend,omitempty"`
 	// Name of the interface antrea-agent will create and use for host <--> pod communication.
 	// Make sure it doesn't conflict with your existing interfaces.
 	// Defaults to gw0.
 	HostGateway string `yaml:"hostGateway,omitempty"`
-	// Encapsulation mode for communication between Pods across Nodes, supported values:
+	// Determines how traffic is forwarded between Pods across Nodes, supported values:
+	// - encap (default): the traffic is encapsulated in a tunnel of type tunnelType.
+	// - noEncap: the traffic is not encapsulated, it is routed by the hosts to the Node IP of
+	//   the destination Pod, so the Pod IPs are preserved. The Nodes must be in the same L2
+	//   network, or the underlay network must be able to route the Pod CIDRs.
+	// - hybrid: the traffic is not encapsulated when the Node IP of the destination Pod is in
+	//   the subnet of the local Node IP, and encapsulated in a tunnel of type tunnelType otherwise.
+	TrafficEncapMode string `yaml:"trafficEncapMode,omitempty"`
+	// Tunnel protocol used for encapsulating traffic across Nodes, supported values:
 	// - vxlan (default)
 	// - geneve
+	// It is ignored in noEncap mode.
 	TunnelType string `yaml:"tunnelType,omitempty"`
+	// Name of the interface whose address is used for the tunnels and the routes to the other Nodes,
+	// e.g. the interface of a dedicated data network. If neither transportInterface nor
+	// transportInterfaceCIDR is set, the Node IP (NodeInternalIP, or else NodeExternalIP) is used.
+	// The selected address is published in the node.antrea.io/transport-address annotation of the
+	// Node, so that the other Nodes use it too.
+	TransportInterface string `yaml:"transportInterface,omitempty"`
+	// CIDR of the network used for the tunnels and the routes to the other Nodes: the local address
+	// in this CIDR is used. It cannot be set together with transportInterface.
+	TransportInterfaceCIDR string `yaml:"transportInterfaceCIDR,omitempty"`
 	// Default MTU to use for the host gateway interface and the network interface of each
-	// Pod. If omitted, antrea-agent will default this value to 1450 to accommodate for tunnel
-	// encapsulate overhead.
+	// Pod. If omitted, antrea-agent calculates it from the MTU of the transport interface, minus
+	// the overhead of the tunnel encapsulation (50 bytes for VXLAN and Geneve), of IPSec ESP (38
+	// bytes) or of WireGuard (60 bytes), plus 20 bytes with an IPv6 transport address. No
+	// overhead is deducted in noEncap mode.
 	DefaultMTU int `yaml:"defaultMTU,omitempty"`
 	// Mount location of the /proc directory. The default is "/host", which is appropriate when
 	// antrea-agent is run as part of the Antrea DaemonSet (and the host's /proc directory is mounted
@@ -57,9 +83,41 @@ type AgentConfig struct {
 	// be set to the same value as the one specified by --service-cluster-ip-range for kube-apiserver.
 	// Default is 10.96.0.0/12
 	ServiceCIDR string `yaml:"serviceCIDR,omitempty"`
+	// Whether or not to enable the Antrea proxy, which load balances the traffic sent by Pods to the ClusterIPs
+	// of the Services across their Endpoints with OVS flows, instead of kube-proxy. The Services traffic is then
+	// DNATed before the egress NetworkPolicy rules are enforced. kube-proxy is still needed for the Services
+	// traffic of the host network, unless ProxyAll is enabled too.
+	// Defaults to false.
+	EnableProxy bool `yaml:"enableProxy,omitempty"`
+	// Whether or not the Antrea proxy also load balances the traffic of the NodePorts and of the LoadBalancer
+	// ingress IPs, and the Services traffic of the host network. This traffic is steered to the host gateway by
+	// iptables rules and routes. With externalTrafficPolicy Cluster, the traffic of the NodePorts is masqueraded
+	// so that the replies come back through this Node. kube-proxy must be removed when it is enabled. It requires
+	// EnableProxy.
+	// Defaults to false.
+	ProxyAll bool `yaml:"proxyAll,omitempty"`
 	// Whether or not to enable IPSec (ESP) tunnel for Pod traffic across Nodes. Antrea uses Preshared
 	// Key (PSK) for IKE authentication. When IPSec tunnel is enabled, the PSK value must be passed to
-	// Antrea Agent through an environment variable: ANTREA_IPSEC_PSK.
+	// Antrea Agent through an environment variable: ANTREA_IPSEC_PSK. An IPSec tunnel port is created on
+	// the OVS bridge for each peer Node reached through a tunnel.
 	// Defaults to false.
 	EnableIPSecTunnel bool `yaml:"enableIPSecTunnel,omitempty"`
+	// How the IKE peers of the IPSec tunnels authenticate each other, supported values:
+	// - psk (default): with the pre-shared key passed in ANTREA_IPSEC_PSK.
+	// - cert: with an X.509 certificate for each Node. The Agent requests the certificate of its
+	//   Node through a Kubernetes CertificateSigningRequest, which is approved by the Antrea
+	//   Controller and signed by the cluster signer of kube-controller-manager, and renews it
+	//   before it expires. The peers are verified with the CA certificate of the cluster.
+	IPSecAuthenticationMode string `yaml:"ipsecAuthenticationMode,omitempty"`
+	// Determines how the Pod traffic across Nodes is encrypted, supported values:
+	// - none (default): the traffic is not encrypted.
+	// - ipsec: the traffic is encrypted with IPSec, like with enableIPSecTunnel.
+	// - wireguard: the traffic is routed through a WireGuard device, instead of the tunnel of type
+	//   tunnelType. The Agent generates a key pair for the Node and publishes the public key in the
+	//   node.antrea.io/wireguard-public-key annotation of the Node. It requires the encap mode and
+	//   the WireGuard kernel module.
+	EncryptionMode string `yaml:"encryptionMode,omitempty"`
+	// UDP port of the WireGuard device of every Node, it must be the same on all the Nodes.
+	// Defaults to 51820.
+	WireGuardPort int `yaml:"wireGuardPort,omitempty"`
 }
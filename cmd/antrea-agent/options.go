@@ -20,7 +20,9 @@ import (
 	"io/ioutil"
 	"net"
 
+	"github.com/vmware-tanzu/antrea/pkg/agent/config"
 	"github.com/vmware-tanzu/antrea/pkg/cni"
+	binding "github.com/vmware-tanzu/antrea/pkg/ovs/openflow"
 
 	"github.com/spf13/pflag"
 	"gopkg.in/yaml.v2"
@@ -31,8 +33,7 @@ const (
 	defaultHostGateway        = "gw0"
 	defaultHostProcPathPrefix = "/host"
 	defaultServiceCIDR        = "10.96.0.0/12"
-	defaultMTUVXLAN           = 1450
-	defaultMTUGeneve          = 1450
+	defaultWireGuardPort      = 51820
 )
 
 type Options struct {
@@ -76,12 +77,60 @@ func (o *Options) validate(args []string) error {
 	if err != nil {
 		return fmt.Errorf("service CIDR %s is invalid", o.config.ServiceCIDR)
 	}
-	if o.config.TunnelType != ovsconfig.VXLANTunnel && o.config.TunnelType != ovsconfig.GeneveTunnel {
+	encapMode, err := config.GetTrafficEncapModeFromStr(o.config.TrafficEncapMode)
+	if err != nil {
+		return err
+	}
+	if encapMode.SupportsEncap() && o.config.TunnelType != ovsconfig.VXLANTunnel && o.config.TunnelType != ovsconfig.GeneveTunnel {
 		return fmt.Errorf("tunnel type %s is invalid", o.config.TunnelType)
 	}
+	if o.config.TransportInterface != "" && o.config.TransportInterfaceCIDR != "" {
+		return fmt.Errorf("transportInterface and transportInterfaceCIDR cannot be set together")
+	}
+	if o.config.TransportInterfaceCIDR != "" {
+		if _, _, err := net.ParseCIDR(o.config.TransportInterfaceCIDR); err != nil {
+			return fmt.Errorf("transport interface CIDR %s is invalid", o.config.TransportInterfaceCIDR)
+		}
+	}
+	encryptionMode, err := config.GetTrafficEncryptionModeFromStr(o.config.EncryptionMode)
+	if err != nil {
+		return err
+	}
+	if o.config.EnableIPSecTunnel && encryptionMode != config.TrafficEncryptionModeIPSec {
+		return fmt.Errorf("enableIPSecTunnel cannot be set with encryption mode %s", encryptionMode)
+	}
+	if encryptionMode == config.TrafficEncryptionModeIPSec && !encapMode.SupportsEncap() {
+		return fmt.Errorf("IPSec tunnel is not supported in %s mode", encapMode)
+	}
+	if _, err := config.GetIPSecAuthenticationModeFromStr(o.config.IPSecAuthenticationMode); err != nil {
+		return err
+	}
+	// The traffic to the Nodes which are reached without encapsulation would not be encrypted.
+	if encryptionMode == config.TrafficEncryptionModeWireGuard && encapMode != config.TrafficEncapModeEncap {
+		return fmt.Errorf("WireGuard encryption is not supported in %s mode", encapMode)
+	}
+	if o.config.WireGuardPort <= 0 || o.config.WireGuardPort > 65535 {
+		return fmt.Errorf("WireGuard port %d is invalid", o.config.WireGuardPort)
+	}
+	if o.config.DefaultMTU < 0 {
+		return fmt.Errorf("default MTU %d is invalid", o.config.DefaultMTU)
+	}
 	if o.config.OVSDatapathType != ovsconfig.OVSDatapathSystem && o.config.OVSDatapathType != ovsconfig.OVSDatapathNetdev {
 		return fmt.Errorf("OVS datapath type %s is not supported", o.config.OVSDatapathType)
 	}
+	if o.config.OpenFlowBackend != binding.BackendNative && o.config.OpenFlowBackend != binding.BackendOFCtl {
+		return fmt.Errorf("OpenFlow backend %s is not supported", o.config.OpenFlowBackend)
+	}
+	if o.config.ProxyAll && !o.config.EnableProxy {
+		return fmt.Errorf("proxyAll requires enableProxy")
+	}
+	// The NodePort traffic sent to remote Endpoints would have to be routed back out of the host gateway.
+	if o.config.ProxyAll && encapMode.SupportsNoEncap() {
+		return fmt.Errorf("proxyAll is not supported in %s mode", encapMode)
+	}
+	if o.config.ProxyAll && encryptionMode == config.TrafficEncryptionModeWireGuard {
+		return fmt.Errorf("proxyAll is not supported with WireGuard encryption")
+	}
 	return nil
 }
 
@@ -109,23 +158,36 @@ func (o *Options) setDefaults() {
 	if o.config.OVSDatapathType == "" {
 		o.config.OVSDatapathType = ovsconfig.OVSDatapathSystem
 	}
+	if o.config.OpenFlowBackend == "" {
+		o.config.OpenFlowBackend = binding.BackendNative
+	}
 	if o.config.HostGateway == "" {
 		o.config.HostGateway = defaultHostGateway
 	}
+	if o.config.TrafficEncapMode == "" {
+		o.config.TrafficEncapMode = config.TrafficEncapModeEncap.String()
+	}
 	if o.config.TunnelType == "" {
 		o.config.TunnelType = ovsconfig.VXLANTunnel
 	}
+	if o.config.EncryptionMode == "" {
+		// enableIPSecTunnel is the former way to enable IPSec encryption.
+		if o.config.EnableIPSecTunnel {
+			o.config.EncryptionMode = config.TrafficEncryptionModeIPSec.String()
+		} else {
+			o.config.EncryptionMode = config.TrafficEncryptionModeNone.String()
+		}
+	}
+	if o.config.IPSecAuthenticationMode == "" {
+		o.config.IPSecAuthenticationMode = config.IPSecAuthenticationModePSK.String()
+	}
+	if o.config.WireGuardPort == 0 {
+		o.config.WireGuardPort = defaultWireGuardPort
+	}
 	if o.config.HostProcPathPrefix == "" {
 		o.config.HostProcPathPrefix = defaultHostProcPathPrefix
 	}
 	if o.config.ServiceCIDR == "" {
 		o.config.ServiceCIDR = defaultServiceCIDR
 	}
-	if o.config.DefaultMTU == 0 {
-		if o.config.TunnelType == ovsconfig.VXLANTunnel {
-			o.config.DefaultMTU = defaultMTUVXLAN
-		} else if o.config.TunnelType == ovsconfig.GeneveTunnel {
-			o.config.DefaultMTU = defaultMTUGeneve
-		}
-	}
 }
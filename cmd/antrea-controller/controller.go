@@ -17,6 +17,7 @@ package main
 import (
 	"fmt"
 	"net"
+	"os"
 	"time"
 
 	genericapiserver "k8s.io/apiserver/pkg/server"
@@ -26,6 +27,7 @@ import (
 
 	"github.com/vmware-tanzu/antrea/pkg/apiserver"
 	"github.com/vmware-tanzu/antrea/pkg/apiserver/storage"
+	"github.com/vmware-tanzu/antrea/pkg/controller/ipseccertificate"
 	"github.com/vmware-tanzu/antrea/pkg/controller/networkpolicy"
 	"github.com/vmware-tanzu/antrea/pkg/controller/networkpolicy/store"
 	"github.com/vmware-tanzu/antrea/pkg/k8s"
@@ -50,6 +52,7 @@ func run(o *Options) error {
 	podInformer := informerFactory.Core().V1().Pods()
 	namespaceInformer := informerFactory.Core().V1().Namespaces()
 	networkPolicyInformer := informerFactory.Networking().V1().NetworkPolicies()
+	csrInformer := informerFactory.Certificates().V1beta1().CertificateSigningRequests()
 
 	// Create Antrea object storage.
 	addressGroupStore := store.NewAddressGroupStore()
@@ -64,6 +67,9 @@ func run(o *Options) error {
 		appliedToGroupStore,
 		networkPolicyStore)
 
+	// The Agents run in the Namespace of the Controller.
+	csrApprovingController := ipseccertificate.NewCSRApprovingController(client, csrInformer, os.Getenv("POD_NAMESPACE"))
+
 	apiServerConfig, err := createAPIServerConfig(o.config.ClientConnection.Kubeconfig,
 		addressGroupStore,
 		appliedToGroupStore,
@@ -88,6 +94,8 @@ func run(o *Options) error {
 
 	go networkPolicyController.Run(stopCh)
 
+	go csrApprovingController.Run(stopCh)
+
 	go apiServer.GenericAPIServer.PrepareRun().Run(stopCh)
 
 	<-stopCh
@@ -20,10 +20,11 @@ function echoerr {
     >&2 echo "$@"
 }
 
-_usage="Usage: $0 [--mode (dev|release)] [--kind] [--keep] [--help|-h]
+_usage="Usage: $0 [--mode (dev|release)] [--kind] [--ipsec-cert] [--keep] [--help|-h]
 Generate a YAML manifest for Antrea using Kustomize and print it to stdout.
         --mode (dev|release)  Choose the configuration variant that you need (default is 'dev')
         --kind                Generate a manifest appropriate for running Antrea in a Kind cluster
+        --ipsec-cert          Generate a manifest appropriate for authenticating the IPSec tunnels with certificates
         --keep                Debug flag which will preserve the generated kustomization.yml
         --help, -h            Print this message and exit
 
@@ -44,6 +45,7 @@ function print_help {
 
 MODE="dev"
 KIND=false
+IPSEC_CERT=false
 KEEP=false
 
 while [[ $# -gt 0 ]]
@@ -59,6 +61,10 @@ case $key in
     KIND=true
     shift
     ;;
+    --ipsec-cert)
+    IPSEC_CERT=true
+    shift
+    ;;
     --keep)
     KEEP=true
     shift
@@ -138,6 +144,14 @@ if $KIND; then
     $KUSTOMIZE edit add patch installCni.yml
 fi
 
+if $IPSEC_CERT; then
+    cp ../../patches/ipsec-cert/*.yml .
+
+    # mount a ServiceAccount token bound to the antrea-agent Pod, with which the IPSec certificate
+    # of the Node is requested
+    $KUSTOMIZE edit add patch ipsecCertToken.yml
+fi
+
 $KUSTOMIZE build
 
 popd > /dev/null
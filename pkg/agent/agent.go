@@ -15,23 +15,34 @@
 package agent
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"os"
 	"os/exec"
+	"strconv"
 	"time"
 
 	"github.com/containernetworking/plugins/pkg/ip"
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 
 	"github.com/vmware-tanzu/antrea/pkg/agent/cniserver"
+	"github.com/vmware-tanzu/antrea/pkg/agent/config"
+	"github.com/vmware-tanzu/antrea/pkg/agent/controller/noderoute"
 	"github.com/vmware-tanzu/antrea/pkg/agent/interfacestore"
 	"github.com/vmware-tanzu/antrea/pkg/agent/iptables"
 	"github.com/vmware-tanzu/antrea/pkg/agent/openflow"
+	"github.com/vmware-tanzu/antrea/pkg/agent/openflow/cookie"
 	"github.com/vmware-tanzu/antrea/pkg/agent/types"
+	"github.com/vmware-tanzu/antrea/pkg/agent/util"
+	"github.com/vmware-tanzu/antrea/pkg/agent/wireguard"
 	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
 )
 
@@ -42,22 +53,39 @@ const (
 	maxRetryForHostLink = 5
 	NodeNameEnvKey      = "NODE_NAME"
 	IPSecPSKEnvKey      = "ANTREA_IPSEC_PSK"
+	// roundNumKey is the external ID of the OVS bridge which persists the round number of the last Agent, so that
+	// the flows it installed can be told apart from the ones installed by the current Agent.
+	roundNumKey = "roundNum"
+	// initialRoundNum is the round number of the first Agent on the Node. Round 0 is never used, it identifies the
+	// flows installed without a cookie.
+	initialRoundNum = 1
+	// flowRestoreWaitKey is the key of the other_config of OVS which makes ovs-vswitchd keep the existing datapath
+	// flows, instead of flushing or expiring them, until it is set to false or deleted.
+	flowRestoreWaitKey = "flow-restore-wait"
+	// defaultTransportMTU is the MTU assumed for the transport interface if it cannot be found.
+	defaultTransportMTU = 1500
 )
 
 // Initializer knows how to setup host networking, OpenVSwitch, and Openflow.
 type Initializer struct {
-	ovsBridge         string
-	hostGateway       string
-	tunnelType        ovsconfig.TunnelType
-	mtu               int
-	enableIPSecTunnel bool
-	client            clientset.Interface
-	ifaceStore        interfacestore.InterfaceStore
-	nodeConfig        *types.NodeConfig
-	ovsBridgeClient   ovsconfig.OVSBridgeClient
-	serviceCIDR       *net.IPNet
-	ofClient          openflow.Client
-	ipsecPSK          string
+	ovsBridge       string
+	hostGateway     string
+	mtu             int
+	networkConfig   *config.NetworkConfig
+	proxyAll        bool
+	client          clientset.Interface
+	recorder        record.EventRecorder
+	ifaceStore      interfacestore.InterfaceStore
+	nodeConfig      *types.NodeConfig
+	nodeUID         k8stypes.UID
+	ovsBridgeClient ovsconfig.OVSBridgeClient
+	serviceCIDR     *net.IPNet
+	ofClient        openflow.Client
+	roundNum        uint64
+	// iptablesClients has one client per IP family of the Pod network.
+	iptablesClients []*iptables.Client
+	// wireGuardClient is the WireGuard device of the Node, it is set in TrafficEncryptionModeWireGuard.
+	wireGuardClient wireguard.Interface
 }
 
 func disableICMPSendRedirects(intfName string) error {
@@ -74,25 +102,27 @@ func NewInitializer(
 	ovsBridgeClient ovsconfig.OVSBridgeClient,
 	ofClient openflow.Client,
 	k8sClient clientset.Interface,
+	recorder record.EventRecorder,
 	ifaceStore interfacestore.InterfaceStore,
 	ovsBridge, serviceCIDR, hostGateway string,
 	mtu int,
-	tunnelType ovsconfig.TunnelType,
-	enableIPSecTunnel bool) *Initializer {
+	networkConfig *config.NetworkConfig,
+	proxyAll bool) *Initializer {
 	// Parse service CIDR configuration. serviceCIDR is checked in option.validate, so
 	// it should be a valid configuration here.
 	_, serviceCIDRNet, _ := net.ParseCIDR(serviceCIDR)
 	return &Initializer{
-		ovsBridgeClient:   ovsBridgeClient,
-		ovsBridge:         ovsBridge,
-		hostGateway:       hostGateway,
-		tunnelType:        tunnelType,
-		mtu:               mtu,
-		enableIPSecTunnel: enableIPSecTunnel,
-		client:            k8sClient,
-		ifaceStore:        ifaceStore,
-		serviceCIDR:       serviceCIDRNet,
-		ofClient:          ofClient,
+		ovsBridgeClient: ovsBridgeClient,
+		ovsBridge:       ovsBridge,
+		hostGateway:     hostGateway,
+		mtu:             mtu,
+		networkConfig:   networkConfig,
+		proxyAll:        proxyAll,
+		client:          k8sClient,
+		recorder:        recorder,
+		ifaceStore:      ifaceStore,
+		serviceCIDR:     serviceCIDRNet,
+		ofClient:        ofClient,
 	}
 }
 
@@ -101,9 +131,20 @@ func (i *Initializer) GetNodeConfig() *types.NodeConfig {
 	return i.nodeConfig
 }
 
+// GetIPTablesClients returns the iptables clients set up by Initialize, one per IP family of the Pod network.
+func (i *Initializer) GetIPTablesClients() []*iptables.Client {
+	return i.iptablesClients
+}
+
+// GetWireGuardClient returns the WireGuard device set up by Initialize, or nil if the Pod traffic is not encrypted
+// with WireGuard.
+func (i *Initializer) GetWireGuardClient() wireguard.Interface {
+	return i.wireGuardClient
+}
+
 // GetIPSecPSK returns PSK used for IPSec tunnel.
 func (i *Initializer) GetIPSecPSK() string {
-	return i.ipsecPSK
+	return i.networkConfig.IPSecPSK
 }
 
 // setupOVSBridge sets up the OVS bridge and create host gateway interface and tunnel port
@@ -118,8 +159,12 @@ func (i *Initializer) setupOVSBridge() error {
 		return err
 	}
 
-	// Setup Tunnel port on OVS
-	if err := i.setupTunnelInterface(TunPortName); err != nil {
+	// Setup Tunnel port on OVS, or delete the one created in encap mode by a previous Agent.
+	if i.networkConfig.TrafficEncapMode.SupportsEncap() {
+		if err := i.setupTunnelInterface(TunPortName); err != nil {
+			return err
+		}
+	} else if err := i.deleteTunnelInterface(TunPortName); err != nil {
 		return err
 	}
 	// Setup host gateway interface
@@ -170,8 +215,10 @@ func (i *Initializer) initInterfaceStore() error {
 				OVSPortConfig: ovsPort,
 				ID:            TunPortName}
 		default:
-			// The port should be for a container interface.
-			intf = cniserver.ParseOVSPortInterfaceConfig(port, ovsPort)
+			// The port should be for a container interface, or be the IPSec tunnel port of a peer Node.
+			if intf = noderoute.ParseTunnelInterfaceConfig(port, ovsPort); intf == nil {
+				intf = cniserver.ParseOVSPortInterfaceConfig(port, ovsPort)
+			}
 		}
 		if intf != nil {
 			ifaceList = append(ifaceList, intf)
@@ -187,68 +234,195 @@ func (i *Initializer) Initialize() error {
 	if err := i.initNodeLocalConfig(); err != nil {
 		return err
 	}
+	if err := i.initNodeTransportAddr(); err != nil {
+		return err
+	}
+	if err := i.initNodeMTU(); err != nil {
+		return err
+	}
+	if i.nodeConfig.PodIPv6CIDR != nil && i.proxyAll {
+		return fmt.Errorf("the Antrea proxy does not support IPv6 PodCIDR %s", i.nodeConfig.PodIPv6CIDR)
+	}
 
 	if err := i.readIPSecPSK(); err != nil {
 		return err
 	}
 
-	// Setup iptables chains and rules.
-	iptablesClient, err := iptables.NewClient(i.hostGateway)
-	if err != nil {
-		return fmt.Errorf("error creating iptables client: %v", err)
+	if err := i.initWireGuard(); err != nil {
+		return err
 	}
-	if err := iptablesClient.SetupRules(); err != nil {
-		return fmt.Errorf("error setting up iptables rules: %v", err)
+
+	// Setup iptables chains and rules, with ip6tables for the IPv6 Pod CIDR.
+	for _, podCIDR := range i.nodeConfig.PodCIDRs() {
+		iptablesClient, err := iptables.NewClient(i.hostGateway, podCIDR.IP.To4() == nil, i.networkConfig, i.proxyAll)
+		if err != nil {
+			return fmt.Errorf("error creating iptables client: %v", err)
+		}
+		if err := iptablesClient.SetupRules(); err != nil {
+			return fmt.Errorf("error setting up iptables rules: %v", err)
+		}
+		i.iptablesClients = append(i.iptablesClients, iptablesClient)
+	}
+
+	// Keep forwarding the existing connections with the datapath flows until the desired state has been replayed,
+	// so that restarting the Agent does not disrupt traffic. FlowRestoreComplete must be called once it is done.
+	if err := i.ovsBridgeClient.UpdateOVSOtherConfig(map[string]interface{}{flowRestoreWaitKey: "true"}); err != nil {
+		return fmt.Errorf("failed to set %s: %v", flowRestoreWaitKey, err)
 	}
 
 	if err := i.setupOVSBridge(); err != nil {
 		return err
 	}
 
+	if err := i.initRoundNum(); err != nil {
+		return err
+	}
+
 	// Install Openflow entries on OVS bridge
 	if err := i.initOpenFlowPipeline(); err != nil {
 		return err
 	}
 
+	// Route the Service traffic of the host to OVS once the flows which load balance it are installed.
+	if i.proxyAll {
+		if err := i.setupServiceRoutes(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FlowRestoreComplete clears the flow-restore-wait flag set by Initialize, once the flows of all the Pods, Nodes,
+// Services and NetworkPolicies have been replayed. ovs-vswitchd then revalidates the datapath flows against the
+// replayed OpenFlow pipeline.
+func (i *Initializer) FlowRestoreComplete() error {
+	if err := i.ovsBridgeClient.DeleteOVSOtherConfig([]string{flowRestoreWaitKey}); err != nil {
+		return fmt.Errorf("failed to clear %s: %v", flowRestoreWaitKey, err)
+	}
+	klog.Info("Flow restoration completed")
 	return nil
 }
 
+// initRoundNum computes the round number of this Agent from the one persisted on the OVS bridge by the previous
+// Agent, and persists it in turn. It must be called before any flow is installed, otherwise the flows installed
+// by this Agent could be deleted as stale after a restart.
+func (i *Initializer) initRoundNum() error {
+	externalIDs, err := i.ovsBridgeClient.GetExternalIDs()
+	if err != nil {
+		return fmt.Errorf("failed to get external IDs of OVS bridge: %v", err)
+	}
+	roundNum := uint64(initialRoundNum)
+	if value, ok := externalIDs[roundNumKey]; ok {
+		prevRoundNum, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			klog.Warningf("Ignoring invalid round number %q of the previous Agent", value)
+		} else {
+			roundNum = getNextRoundNum(prevRoundNum)
+		}
+	}
+	// SetExternalIDs replaces all the external IDs of the bridge, so the existing ones must be kept.
+	updatedIDs := make(map[string]interface{}, len(externalIDs)+1)
+	for k, v := range externalIDs {
+		updatedIDs[k] = v
+	}
+	updatedIDs[roundNumKey] = strconv.FormatUint(roundNum, 10)
+	if err := i.ovsBridgeClient.SetExternalIDs(updatedIDs); err != nil {
+		return fmt.Errorf("failed to persist round number %d on OVS bridge: %v", roundNum, err)
+	}
+	klog.Infof("Using round number %d", roundNum)
+	i.roundNum = roundNum
+	return nil
+}
+
+// getNextRoundNum returns the round number following prevRoundNum. Round numbers wrap around after
+// cookie.BitwidthRound bits, skipping 0.
+func getNextRoundNum(prevRoundNum uint64) uint64 {
+	roundNum := (prevRoundNum + 1) % (1 << cookie.BitwidthRound)
+	if roundNum == 0 {
+		roundNum = initialRoundNum
+	}
+	return roundNum
+}
+
 // initOpenFlowPipeline sets up necessary Openflow entries, including pipeline, classifiers, conn_track, and gateway flows
 func (i *Initializer) initOpenFlowPipeline() error {
 	// Setup all basic flows.
-	if err := i.ofClient.Initialize(); err != nil {
+	connectionCh, err := i.ofClient.Initialize(i.roundNum, i.nodeConfig)
+	if err != nil {
 		klog.Errorf("Failed to setup basic openflow entries: %v", err)
 		return err
 	}
+	go i.replayFlowsOnReconnection(connectionCh)
 
 	// Setup flow entries for gateway interface, including classifier, skip spoof guard check,
 	// L3 forwarding and L2 forwarding
 	gateway, _ := i.ifaceStore.GetInterface(i.hostGateway)
 	gatewayOFPort := uint32(gateway.OFPort)
-	if err := i.ofClient.InstallGatewayFlows(gateway.IP, gateway.MAC, gatewayOFPort); err != nil {
+	if err := i.ofClient.InstallGatewayFlows(gateway.IPs, gateway.MAC, gatewayOFPort); err != nil {
 		klog.Errorf("Failed to setup openflow entries for gateway: %v", err)
 		return err
 	}
 
 	// Setup flow entries for tunnel port Interface, including classifier and L2 Forwarding
 	// (match vMAC as dst)
-	if err := i.ofClient.InstallTunnelFlows(tunOFPort); err != nil {
-		klog.Errorf("Failed to setup openflow entries for tunnel interface: %v", err)
-		return err
+	if i.networkConfig.TrafficEncapMode.SupportsEncap() {
+		if err := i.ofClient.InstallTunnelFlows(tunOFPort); err != nil {
+			klog.Errorf("Failed to setup openflow entries for tunnel interface: %v", err)
+			return err
+		}
 	}
 
-	// Setup flow entries to enable service connectivity. Upstream kube-proxy is leveraged to
-	// provide load-balancing, and the flows installed by this method ensure that traffic sent
-	// from local Pods to any Service address can be forwarded to the host gateway interface
-	// correctly. Otherwise packets might be dropped by egress rules before they are DNATed to
-	// backend Pods.
+	// Setup flow entries to enable service connectivity. Unless the Antrea proxy is enabled,
+	// upstream kube-proxy is leveraged to provide load-balancing, and the flows installed by this
+	// method ensure that traffic sent from local Pods to any Service address can be forwarded to
+	// the host gateway interface correctly. Otherwise packets might be dropped by egress rules
+	// before they are DNATed to backend Pods. With the Antrea proxy, the flows send the new
+	// connections to any Service address to the load-balancing tables of the pipeline.
 	if err := i.ofClient.InstallClusterServiceCIDRFlows(i.serviceCIDR, gatewayOFPort); err != nil {
 		klog.Errorf("Failed to setup openflow entries for Cluster Service CIDR %s: %v", i.serviceCIDR, err)
 		return err
 	}
+
+	// The traffic of the NodePorts and of the LoadBalancer ingress IPs is DNATed to the NodePort virtual IP by the
+	// host and sent to the host gateway interface.
+	if i.proxyAll {
+		if err := i.ofClient.InstallNodePortFlows(types.NodePortVirtualIP); err != nil {
+			klog.Errorf("Failed to setup openflow entries for NodePorts: %v", err)
+			return err
+		}
+	}
 	return nil
 }
 
+// replayFlowsOnReconnection replays all the flows every time the OpenFlow connection to OVS is re-established, as
+// the flows are lost when ovs-vswitchd restarts. An event is recorded on the Node for every replay.
+func (i *Initializer) replayFlowsOnReconnection(connectionCh <-chan struct{}) {
+	for range connectionCh {
+		klog.Warning("OpenFlow connection to OVS was re-established, replaying all flows")
+		if err := i.ofClient.ReplayFlows(); err != nil {
+			klog.Errorf("Failed to replay flows: %v", err)
+			i.recordNodeEvent(corev1.EventTypeWarning, "OVSFlowReplayFailed", "Failed to replay OpenFlow flows after OVS restarted: %v", err)
+			continue
+		}
+		klog.Info("Flow replay completed")
+		i.recordNodeEvent(corev1.EventTypeNormal, "OVSFlowsReplayed", "Replayed OpenFlow flows after OVS restarted")
+	}
+}
+
+// recordNodeEvent records an event on the Node of the Agent.
+func (i *Initializer) recordNodeEvent(eventType, reason, messageFmt string, args ...interface{}) {
+	if i.recorder == nil {
+		return
+	}
+	nodeRef := &corev1.ObjectReference{
+		Kind: "Node",
+		Name: i.nodeConfig.Name,
+		UID:  i.nodeUID,
+	}
+	i.recorder.Eventf(nodeRef, eventType, reason, messageFmt, args...)
+}
+
 // setupGatewayInterface creates the host gateway interface which is an internal port on OVS. The ofport for host
 // gateway interface is predefined, so invoke CreateInternalPort with a specific ofport_request
 func (i *Initializer) setupGatewayInterface() error {
@@ -270,8 +444,8 @@ func (i *Initializer) setupGatewayInterface() error {
 	// Idempotent operation to set the gateway's MTU: we perform this operation regardless of
 	// whether or not the gateway interface already exists, as the desired MTU may change across
 	// restarts.
-	klog.V(4).Infof("Setting gateway interface %s MTU to %d", i.hostGateway, i.mtu)
-	i.ovsBridgeClient.SetInterfaceMTU(i.hostGateway, i.mtu)
+	klog.V(4).Infof("Setting gateway interface %s MTU to %d", i.hostGateway, i.nodeConfig.NodeMTU)
+	i.ovsBridgeClient.SetInterfaceMTU(i.hostGateway, i.nodeConfig.NodeMTU)
 	// host link might not be queried at once after create OVS internal port, retry max 5 times with 1s
 	// delay each time to ensure the link is ready. If still failed after max retry return error.
 	link, err := func() (netlink.Link, error) {
@@ -300,34 +474,55 @@ func (i *Initializer) setupGatewayInterface() error {
 		return err
 	}
 
-	// Configure host gateway IP using the first address of node localSubnet
-	localSubnet := i.nodeConfig.PodCIDR
-	subnetID := localSubnet.IP.Mask(localSubnet.Mask)
-	gwIP := &net.IPNet{IP: ip.NextIP(subnetID), Mask: localSubnet.Mask}
-	gwAddr := &netlink.Addr{IPNet: gwIP, Label: ""}
 	gwMAC := link.Attrs().HardwareAddr
-	i.nodeConfig.GatewayConfig = &types.GatewayConfig{Name: i.hostGateway, IP: gwIP.IP, MAC: gwMAC}
-	gatewayIface.IP = gwIP.IP
+	i.nodeConfig.GatewayConfig = &types.GatewayConfig{Name: i.hostGateway, MAC: gwMAC}
 	gatewayIface.MAC = gwMAC
+	gatewayIface.IPs = nil
+	// Configure host gateway IP using the first address of each Pod CIDR of the Node.
+	for _, localSubnet := range i.nodeConfig.PodCIDRs() {
+		subnetID := localSubnet.IP.Mask(localSubnet.Mask)
+		gwIP := &net.IPNet{IP: ip.NextIP(subnetID), Mask: localSubnet.Mask}
+		if gwIP.IP.To4() == nil {
+			i.nodeConfig.GatewayConfig.IPv6 = gwIP.IP
+		} else {
+			i.nodeConfig.GatewayConfig.IPv4 = gwIP.IP
+		}
+		gatewayIface.IPs = append(gatewayIface.IPs, gwIP.IP)
+		if err := i.configureGatewayAddress(link, gwIP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configureGatewayAddress assigns gwIP to the host gateway interface, unless it is assigned already.
+func (i *Initializer) configureGatewayAddress(link netlink.Link, gwIP *net.IPNet) error {
+	gwAddr := &netlink.Addr{IPNet: gwIP, Label: ""}
+	family, familyName := netlink.FAMILY_V4, "IPv4"
+	if gwIP.IP.To4() == nil {
+		family, familyName = netlink.FAMILY_V6, "IPv6"
+		// The gateway address is unique in the cluster, Duplicate Address Detection would only delay its use.
+		gwAddr.Flags = unix.IFA_F_NODAD
+	}
 
 	// Check IP address configuration on existing interface, return if already has target
 	// address
 	// We perform this check unconditionally, even if the OVS port did not exist when this
 	// function was called (i.e. portExists is false). Indeed, it may be possible for the Linux
 	// interface to exist even if the OVS bridge does not exist.
-	if addrs, err := netlink.AddrList(link, netlink.FAMILY_V4); err != nil {
-		klog.Errorf("Failed to query IPv4 address list for interface %s: %v", i.hostGateway, err)
+	if addrs, err := netlink.AddrList(link, family); err != nil {
+		klog.Errorf("Failed to query %s address list for interface %s: %v", familyName, i.hostGateway, err)
 		return err
 	} else if addrs != nil {
 		for _, addr := range addrs {
-			klog.V(4).Infof("Found IPv4 address %s for interface %s", addr.IP.String(), i.hostGateway)
+			klog.V(4).Infof("Found %s address %s for interface %s", familyName, addr.IP.String(), i.hostGateway)
 			if addr.IP.Equal(gwAddr.IPNet.IP) {
-				klog.V(2).Infof("IPv4 address %s already assigned to interface %s", addr.IP.String(), i.hostGateway)
+				klog.V(2).Infof("%s address %s already assigned to interface %s", familyName, addr.IP.String(), i.hostGateway)
 				return nil
 			}
 		}
 	} else {
-		klog.V(2).Infof("Link %s has no configured IPv4 address", i.hostGateway)
+		klog.V(2).Infof("Link %s has no configured %s address", i.hostGateway, familyName)
 	}
 
 	klog.V(2).Infof("Adding address %v to gateway interface %s", gwAddr, i.hostGateway)
@@ -338,15 +533,59 @@ func (i *Initializer) setupGatewayInterface() error {
 	return nil
 }
 
+// setupServiceRoutes routes the NodePort virtual IP and the Service CIDR to the host gateway interface, so that the
+// traffic of the NodePorts and the Service traffic of the host network are load balanced by OVS instead of
+// kube-proxy. The next hop of the Service CIDR is the NodePort virtual IP, whose permanent neighbor entry is the
+// global virtual MAC: OVS forwards the packets sent to this MAC like the ones received from the tunnel.
+func (i *Initializer) setupServiceRoutes() error {
+	link, err := netlink.LinkByName(i.hostGateway)
+	if err != nil {
+		return fmt.Errorf("failed to find host link for gateway %s: %v", i.hostGateway, err)
+	}
+	virtualIP := types.NodePortVirtualIP
+	neigh := &netlink.Neigh{
+		LinkIndex:    link.Attrs().Index,
+		Family:       netlink.FAMILY_V4,
+		State:        netlink.NUD_PERMANENT,
+		IP:           virtualIP,
+		HardwareAddr: openflow.GlobalVirtualMAC,
+	}
+	if err := netlink.NeighSet(neigh); err != nil {
+		return fmt.Errorf("failed to set neighbor %s on gateway %s: %v", virtualIP, i.hostGateway, err)
+	}
+	routes := []*netlink.Route{
+		{
+			LinkIndex: link.Attrs().Index,
+			Dst:       &net.IPNet{IP: virtualIP, Mask: net.CIDRMask(32, 32)},
+			Scope:     netlink.SCOPE_LINK,
+		},
+		{
+			LinkIndex: link.Attrs().Index,
+			Dst:       i.serviceCIDR,
+			Gw:        virtualIP,
+			Src:       i.nodeConfig.GatewayConfig.IPv4,
+			Flags:     int(netlink.FLAG_ONLINK),
+		},
+	}
+	for _, route := range routes {
+		if err := netlink.RouteReplace(route); err != nil {
+			return fmt.Errorf("failed to install route to %s on gateway %s: %v", route.Dst, i.hostGateway, err)
+		}
+	}
+	klog.Infof("Routed NodePort virtual IP %s and Service CIDR %s to gateway %s", virtualIP, i.serviceCIDR, i.hostGateway)
+	return nil
+}
+
 func (i *Initializer) setupTunnelInterface(tunnelPortName string) error {
 	tunnelIface, portExists := i.ifaceStore.GetInterface(tunnelPortName)
 	if portExists {
 		klog.V(2).Infof("Tunnel port %s already exists on OVS", tunnelPortName)
 		return nil
 	}
-	tunnelPortUUID, err := i.ovsBridgeClient.CreateTunnelPort(tunnelPortName, i.tunnelType, tunOFPort)
+	tunnelType := i.networkConfig.TunnelType
+	tunnelPortUUID, err := i.ovsBridgeClient.CreateTunnelPort(tunnelPortName, tunnelType, tunOFPort)
 	if err != nil {
-		klog.Errorf("Failed to add tunnel port %s type %s on OVS: %v", tunnelPortName, i.tunnelType, err)
+		klog.Errorf("Failed to add tunnel port %s type %s on OVS: %v", tunnelPortName, tunnelType, err)
 		return err
 	}
 	tunnelIface = interfacestore.NewTunnelInterface(tunnelPortName)
@@ -355,8 +594,23 @@ func (i *Initializer) setupTunnelInterface(tunnelPortName string) error {
 	return nil
 }
 
-// initNodeLocalConfig retrieves node's subnet CIDR from node.spec.PodCIDR, which is used for IPAM and setup
-// host gateway interface.
+// deleteTunnelInterface deletes the tunnel port if it exists, e.g. because the Agent was restarted in noEncap mode
+// after it ran in encap mode.
+func (i *Initializer) deleteTunnelInterface(tunnelPortName string) error {
+	tunnelIface, portExists := i.ifaceStore.GetInterface(tunnelPortName)
+	if !portExists {
+		return nil
+	}
+	klog.Infof("Deleting tunnel port %s which is not needed in %s mode", tunnelPortName, i.networkConfig.TrafficEncapMode)
+	if err := i.ovsBridgeClient.DeletePort(tunnelIface.PortUUID); err != nil {
+		return fmt.Errorf("failed to delete tunnel port %s: %v", tunnelPortName, err)
+	}
+	i.ifaceStore.DeleteInterface(tunnelPortName)
+	return nil
+}
+
+// initNodeLocalConfig retrieves node's subnet CIDRs from node.spec.PodCIDRs, which are used for IPAM and setup
+// host gateway interface. With dual-stack, the Node has one CIDR per IP family.
 func (i *Initializer) initNodeLocalConfig() error {
 	nodeName, err := getNodeName()
 	if err != nil {
@@ -367,22 +621,190 @@ func (i *Initializer) initNodeLocalConfig() error {
 		klog.Errorf("Failed to get node from K8s with name %s: %v", nodeName, err)
 		return err
 	}
+	podCIDRs := node.Spec.PodCIDRs
+	if len(podCIDRs) == 0 && node.Spec.PodCIDR != "" {
+		podCIDRs = []string{node.Spec.PodCIDR}
+	}
 	// Spec.PodCIDR can be empty due to misconfiguration
-	if node.Spec.PodCIDR == "" {
+	if len(podCIDRs) == 0 {
 		klog.Errorf("Spec.PodCIDR is empty for Node %s. Please make sure --allocate-node-cidrs is enabled "+
 			"for kube-controller-manager and --cluster-cidr specifies a sufficient CIDR range", nodeName)
 		return fmt.Errorf("CIDR string is empty for node %s", nodeName)
 	}
-	_, localSubnet, err := net.ParseCIDR(node.Spec.PodCIDR)
+	nodeConfig := &types.NodeConfig{Name: nodeName}
+	for _, podCIDR := range podCIDRs {
+		_, localSubnet, err := net.ParseCIDR(podCIDR)
+		if err != nil {
+			klog.Errorf("Failed to parse subnet from CIDR string %s: %v", podCIDR, err)
+			return err
+		}
+		if localSubnet.IP.To4() != nil {
+			if nodeConfig.PodIPv4CIDR != nil {
+				return fmt.Errorf("node %s has more than one IPv4 PodCIDR: %v", nodeName, podCIDRs)
+			}
+			nodeConfig.PodIPv4CIDR = localSubnet
+		} else {
+			if nodeConfig.PodIPv6CIDR != nil {
+				return fmt.Errorf("node %s has more than one IPv6 PodCIDR: %v", nodeName, podCIDRs)
+			}
+			nodeConfig.PodIPv6CIDR = localSubnet
+		}
+	}
+
+	nodeIP, err := util.GetNodeAddr(node)
 	if err != nil {
-		klog.Errorf("Failed to parse subnet from CIDR string %s: %v", node.Spec.PodCIDR, err)
+		return fmt.Errorf("failed to get the IP of Node %s: %v", nodeName, err)
+	}
+	if nodeConfig.NodeIPAddr, err = getLocalIPNet(nodeIP); err != nil {
 		return err
 	}
 
-	i.nodeConfig = &types.NodeConfig{Name: nodeName, PodCIDR: localSubnet}
+	i.nodeConfig = nodeConfig
+	i.nodeUID = node.UID
+	return nil
+}
+
+// initNodeTransportAddr selects the address of the Node used for the tunnels and the routes to the other Nodes, and
+// publishes it in the transport address annotation of the Node, so that the other Nodes use it too.
+func (i *Initializer) initNodeTransportAddr() error {
+	transportAddr := i.nodeConfig.NodeIPAddr
+	if i.networkConfig.TransportInterface != "" || i.networkConfig.TransportInterfaceCIDR != nil {
+		var err error
+		if transportAddr, err = getTransportAddr(i.networkConfig.TransportInterface, i.networkConfig.TransportInterfaceCIDR); err != nil {
+			return err
+		}
+	}
+	i.nodeConfig.NodeTransportIPAddr = transportAddr
+	klog.Infof("Using transport address %s", transportAddr)
+
+	if err := i.patchNodeAnnotations(map[string]string{types.NodeTransportAddressAnnotationKey: transportAddr.IP.String()}); err != nil {
+		return fmt.Errorf("failed to publish the transport address of Node %s: %v", i.nodeConfig.Name, err)
+	}
 	return nil
 }
 
+// initNodeMTU sets the MTU of the gateway and of the Pods: the MTU of the transport interface minus the overhead of
+// the encapsulation and of the encryption of the Pod traffic across Nodes, unless the MTU is configured. A configured
+// MTU which exceeds it is used anyway, with a warning, as the packets which are too large would then be dropped.
+func (i *Initializer) initNodeMTU() error {
+	transportIP := i.nodeConfig.NodeTransportIPAddr.IP
+	transportMTU, err := getTransportInterfaceMTU(transportIP)
+	if err != nil {
+		return err
+	}
+	maxMTU := transportMTU - i.networkConfig.CalculateMTUDeduction(transportIP.To4() == nil)
+	if i.mtu == 0 {
+		i.nodeConfig.NodeMTU = maxMTU
+		klog.Infof("Using MTU %d, calculated from the MTU %d of the transport interface", maxMTU, transportMTU)
+		return nil
+	}
+	if i.mtu > maxMTU {
+		klog.Warningf("Configured MTU %d exceeds the MTU %d allowed by the MTU %d of the transport interface, the Pod traffic across Nodes may be dropped", i.mtu, maxMTU, transportMTU)
+	} else if i.mtu < maxMTU {
+		klog.Infof("Configured MTU %d is lower than the MTU %d allowed by the MTU %d of the transport interface", i.mtu, maxMTU, transportMTU)
+	}
+	i.nodeConfig.NodeMTU = i.mtu
+	return nil
+}
+
+// initWireGuard sets up the WireGuard device of the Node in TrafficEncryptionModeWireGuard, and publishes its public
+// key in the WireGuard public key annotation of the Node, so that the other Nodes add it as a peer. Otherwise, it
+// deletes the WireGuard device created by a previous Agent, if any.
+func (i *Initializer) initWireGuard() error {
+	if i.networkConfig.TrafficEncryptionMode != config.TrafficEncryptionModeWireGuard {
+		return wireguard.DeleteDevice()
+	}
+	wireGuardClient := wireguard.NewClient(i.nodeConfig.NodeMTU, i.networkConfig.WireGuardPort)
+	if err := wireGuardClient.Init(); err != nil {
+		return err
+	}
+	if err := i.patchNodeAnnotations(map[string]string{types.NodeWireGuardPublicKeyAnnotationKey: wireGuardClient.PublicKey()}); err != nil {
+		return fmt.Errorf("failed to publish the WireGuard public key of Node %s: %v", i.nodeConfig.Name, err)
+	}
+	i.wireGuardClient = wireGuardClient
+	return nil
+}
+
+// patchNodeAnnotations adds the annotations to the Node of the Agent, or updates them.
+func (i *Initializer) patchNodeAnnotations(annotations map[string]string) error {
+	patch, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	_, err := i.client.CoreV1().Nodes().Patch(i.nodeConfig.Name, k8stypes.StrategicMergePatchType, patch)
+	return err
+}
+
+// getTransportAddr returns the first global unicast address of the transport interface, or of any local interface if
+// ifaceName is empty, which is in transportCIDR if it is not nil. The address has the mask of its subnet.
+func getTransportAddr(ifaceName string, transportCIDR *net.IPNet) (*net.IPNet, error) {
+	var link netlink.Link
+	if ifaceName != "" {
+		var err error
+		if link, err = netlink.LinkByName(ifaceName); err != nil {
+			return nil, fmt.Errorf("failed to find transport interface %s: %v", ifaceName, err)
+		}
+	}
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the local addresses: %v", err)
+	}
+	for _, addr := range addrs {
+		if !addr.IP.IsGlobalUnicast() || (transportCIDR != nil && !transportCIDR.Contains(addr.IP)) {
+			continue
+		}
+		return addr.IPNet, nil
+	}
+	if ifaceName != "" {
+		return nil, fmt.Errorf("transport interface %s has no global unicast address", ifaceName)
+	}
+	return nil, fmt.Errorf("no local address in transport CIDR %s", transportCIDR)
+}
+
+// getTransportInterfaceMTU returns the MTU of the local interface to which the transport address is assigned. If the
+// address is not assigned to a local interface, the MTU of the Ethernet networks is returned.
+func getTransportInterfaceMTU(transportIP net.IP) (int, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list the local interfaces: %v", err)
+	}
+	for _, link := range links {
+		addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list the addresses of interface %s: %v", link.Attrs().Name, err)
+		}
+		for _, addr := range addrs {
+			if addr.IP.Equal(transportIP) {
+				return link.Attrs().MTU, nil
+			}
+		}
+	}
+	klog.Warningf("Transport address %s is not assigned to a local interface, assuming MTU %d", transportIP, defaultTransportMTU)
+	return defaultTransportMTU, nil
+}
+
+// getLocalIPNet returns the address of a local interface which is ip, with the mask of its subnet. If the address
+// is not assigned to a local interface, e.g. because it is translated by the underlay network, the subnet of the
+// Node is unknown and the host mask is used.
+func getLocalIPNet(ip net.IP) (*net.IPNet, error) {
+	addrs, err := netlink.AddrList(nil, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the local addresses: %v", err)
+	}
+	for _, addr := range addrs {
+		if addr.IP.Equal(ip) {
+			return addr.IPNet, nil
+		}
+	}
+	klog.Warningf("Node IP %s is not assigned to a local interface, its subnet is unknown", ip)
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		bits = 8 * net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
 // getNodeName returns the node's name used in Kubernetes, based on the priority:
 // - Environment variable NODE_NAME, which should be set by Downward API
 // - OS's hostname
@@ -402,18 +824,20 @@ func getNodeName() (string, error) {
 }
 
 // readIPSecPSK reads the IPSec PSK value from environment variable
-// ANTREA_IPSEC_PSK, when enableIPSecTunnel is set to true.
+// ANTREA_IPSEC_PSK, when the Pod traffic is encrypted with IPSec and the IKE peers
+// authenticate each other with the PSK.
 func (i *Initializer) readIPSecPSK() error {
-	if !i.enableIPSecTunnel {
+	if i.networkConfig.TrafficEncryptionMode != config.TrafficEncryptionModeIPSec ||
+		i.networkConfig.IPSecAuthenticationMode != config.IPSecAuthenticationModePSK {
 		return nil
 	}
 
-	i.ipsecPSK = os.Getenv(IPSecPSKEnvKey)
-	if i.ipsecPSK == "" {
+	i.networkConfig.IPSecPSK = os.Getenv(IPSecPSKEnvKey)
+	if i.networkConfig.IPSecPSK == "" {
 		return fmt.Errorf("IPSec PSK environment variable is not set or is empty")
 	}
 
 	// Normally we want not to log the secret data.
-	klog.V(4).Infof("IPSec PSK value: %s", i.ipsecPSK)
+	klog.V(4).Infof("IPSec PSK value: %s", i.networkConfig.IPSecPSK)
 	return nil
 }
@@ -22,9 +22,14 @@ import (
 
 	mock "github.com/golang/mock/gomock"
 	"github.com/google/uuid"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 
 	"github.com/vmware-tanzu/antrea/pkg/agent/cniserver"
+	"github.com/vmware-tanzu/antrea/pkg/agent/config"
 	"github.com/vmware-tanzu/antrea/pkg/agent/interfacestore"
+	"github.com/vmware-tanzu/antrea/pkg/agent/types"
 	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
 	ovsconfigtest "github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig/testing"
 )
@@ -101,10 +106,10 @@ func TestInitstore(t *testing.T) {
 
 	ovsPort1 := ovsconfig.OVSPortData{UUID: uuid1, Name: "p1", IFName: "p1", OFPort: 1,
 		ExternalIDs: convertExternalIDMap(cniserver.BuildOVSPortExternalIDs(
-			interfacestore.NewContainerInterface(uuid1, "pod1", "ns1", "netns1", p1NetMAC, p1NetIP)))}
+			interfacestore.NewContainerInterface(uuid1, "pod1", "ns1", "netns1", p1NetMAC, []net.IP{p1NetIP})))}
 	ovsPort2 := ovsconfig.OVSPortData{UUID: uuid2, Name: "p2", IFName: "p2", OFPort: 2,
 		ExternalIDs: convertExternalIDMap(cniserver.BuildOVSPortExternalIDs(
-			interfacestore.NewContainerInterface(uuid2, "pod2", "ns2", "netns2", p2NetMAC, p2NetIP)))}
+			interfacestore.NewContainerInterface(uuid2, "pod2", "ns2", "netns2", p2NetMAC, []net.IP{p2NetIP})))}
 	initOVSPorts := []ovsconfig.OVSPortData{ovsPort1, ovsPort2}
 
 	mockOVSBridgeClient.EXPECT().GetPortList().Return(initOVSPorts, ovsconfig.NewTransactionError(fmt.Errorf("Failed to list OVS ports"), true))
@@ -121,7 +126,7 @@ func TestInitstore(t *testing.T) {
 	container1, found1 := store.GetInterface("p1")
 	if !found1 {
 		t.Errorf("Failed to load OVS port into local store")
-	} else if container1.OFPort != 1 || container1.IP.String() != p1IP || container1.MAC.String() != p1MAC || container1.IfaceName != "p1" {
+	} else if container1.OFPort != 1 || len(container1.IPs) != 1 || container1.IPs[0].String() != p1IP || container1.MAC.String() != p1MAC || container1.IfaceName != "p1" {
 		t.Errorf("Failed to load OVS port configuration into local store")
 	}
 	_, found2 := store.GetInterface("p2")
@@ -129,3 +134,62 @@ func TestInitstore(t *testing.T) {
 		t.Errorf("Failed to load OVS port into local store")
 	}
 }
+
+func TestInitRoundNum(t *testing.T) {
+	controller := mock.NewController(t)
+	defer controller.Finish()
+	mockOVSBridgeClient := ovsconfigtest.NewMockOVSBridgeClient(controller)
+	initializer := newAgentInitializer(mockOVSBridgeClient, nil)
+
+	testCases := []struct {
+		externalIDs map[string]string
+		roundNum    uint64
+	}{
+		{map[string]string{}, 1},
+		{map[string]string{roundNumKey: "3", "foo": "bar"}, 4},
+		{map[string]string{roundNumKey: "65535"}, 1},
+		{map[string]string{roundNumKey: "invalid"}, 1},
+	}
+	for _, tc := range testCases {
+		expectedIDs := map[string]interface{}{roundNumKey: fmt.Sprint(tc.roundNum)}
+		for k, v := range tc.externalIDs {
+			if k != roundNumKey {
+				expectedIDs[k] = v
+			}
+		}
+		mockOVSBridgeClient.EXPECT().GetExternalIDs().Return(tc.externalIDs, nil)
+		mockOVSBridgeClient.EXPECT().SetExternalIDs(expectedIDs).Return(nil)
+		if err := initializer.initRoundNum(); err != nil {
+			t.Fatalf("Failed to initialize round number: %v", err)
+		}
+		if initializer.roundNum != tc.roundNum {
+			t.Errorf("Wrong round number, want: %d, get: %d", tc.roundNum, initializer.roundNum)
+		}
+	}
+}
+
+func TestInitNodeTransportAddr(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	client := fake.NewSimpleClientset(node)
+	_, nodeIPNet, _ := net.ParseCIDR("192.168.10.10/24")
+	nodeIPNet.IP = net.ParseIP("192.168.10.10")
+	initializer := &Initializer{
+		client:        client,
+		nodeConfig:    &types.NodeConfig{Name: "node1", NodeIPAddr: nodeIPNet},
+		networkConfig: &config.NetworkConfig{},
+	}
+
+	if err := initializer.initNodeTransportAddr(); err != nil {
+		t.Fatalf("Failed to initialize transport address: %v", err)
+	}
+	if initializer.nodeConfig.NodeTransportIPAddr.String() != nodeIPNet.String() {
+		t.Errorf("Wrong transport address, want: %s, get: %s", nodeIPNet, initializer.nodeConfig.NodeTransportIPAddr)
+	}
+	updatedNode, err := client.CoreV1().Nodes().Get("node1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get Node: %v", err)
+	}
+	if addr := updatedNode.Annotations[types.NodeTransportAddressAnnotationKey]; addr != "192.168.10.10" {
+		t.Errorf("Wrong transport address annotation, want: 192.168.10.10, get: %s", addr)
+	}
+}
@@ -28,8 +28,19 @@ type IPAMConfig struct {
 	Type    string `json:"type,omitempty"`
 	Subnet  string `json:"subnet,omitempty"`
 	Gateway string `json:"gateway,omitempty"`
+	// Ranges are the address ranges allocated in addition to Subnet, one address is allocated from each RangeSet.
+	Ranges []RangeSet `json:"ranges,omitempty"`
 }
 
+// Range is an address range of the host-local IPAM plugin.
+type Range struct {
+	Subnet  string `json:"subnet"`
+	Gateway string `json:"gateway,omitempty"`
+}
+
+// RangeSet is a set of address ranges from which the host-local IPAM plugin allocates one address.
+type RangeSet []Range
+
 //go:generate mockgen -copyright_file ../../../../hack/boilerplate/license_header.raw.txt -destination testing/mock_ipam.go -package=testing github.com/vmware-tanzu/antrea/pkg/agent/cniserver/ipam IPAMDriver
 
 type IPAMDriver interface {
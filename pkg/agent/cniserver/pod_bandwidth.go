@@ -0,0 +1,167 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cniserver
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
+)
+
+const (
+	// ingressBandwidthAnnotation limits the rate of the traffic received by a Pod, in bits per second.
+	ingressBandwidthAnnotation = "kubernetes.io/ingress-bandwidth"
+	// egressBandwidthAnnotation limits the rate of the traffic sent by a Pod, in bits per second.
+	egressBandwidthAnnotation = "kubernetes.io/egress-bandwidth"
+	// podResyncPeriod is the period at which the bandwidth limits of the Pods are synced again, e.g. when they could
+	// not be updated.
+	podResyncPeriod = 5 * time.Minute
+)
+
+var (
+	// The bandwidth limits must be in the same range as for the kubelet.
+	minBandwidth = resource.MustParse("1k")
+	maxBandwidth = resource.MustParse("1P")
+)
+
+// podBandwidth is the bandwidth limits of a Pod in bits per second, 0 means that there is no limit.
+type podBandwidth struct {
+	ingress uint64
+	egress  uint64
+}
+
+// getPodBandwidth returns the bandwidth limits set by the annotations of a Pod.
+func getPodBandwidth(annotations map[string]string) (podBandwidth, error) {
+	var bandwidth podBandwidth
+	var err error
+	if bandwidth.ingress, err = parseBandwidth(annotations, ingressBandwidthAnnotation); err != nil {
+		return podBandwidth{}, err
+	}
+	if bandwidth.egress, err = parseBandwidth(annotations, egressBandwidthAnnotation); err != nil {
+		return podBandwidth{}, err
+	}
+	return bandwidth, nil
+}
+
+func parseBandwidth(annotations map[string]string, annotation string) (uint64, error) {
+	value, ok := annotations[annotation]
+	if !ok {
+		return 0, nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid annotation %s %q: %v", annotation, value, err)
+	}
+	if quantity.Cmp(minBandwidth) < 0 || quantity.Cmp(maxBandwidth) > 0 {
+		return 0, fmt.Errorf("invalid annotation %s %q: bandwidth must be between %s and %s", annotation, value, minBandwidth.String(), maxBandwidth.String())
+	}
+	return uint64(quantity.Value()), nil
+}
+
+// setBandwidth adds the bandwidth limits of a Pod to tx, which configures its OVS port. The traffic received by the
+// Pod is output to the port, so it is shaped by the QoS of the port. The traffic sent by the Pod is received on the
+// interface of the port, so it is policed, ingress policing is in kbps.
+func setBandwidth(tx ovsconfig.PortTransaction, ovsPortName string, bandwidth podBandwidth) {
+	tx.SetPortQoS(ovsPortName, bandwidth.ingress, 0)
+	tx.SetInterfaceIngressPolicing(ovsPortName, bandwidth.egress/1000, 0)
+}
+
+// updateBandwidth updates the bandwidth limits of the OVS port of a Pod, if they changed since they were set.
+func (pc *podConfigurator) updateBandwidth(ovsPortName string, bandwidth podBandwidth) error {
+	pc.bandwidthMutex.Lock()
+	defer pc.bandwidthMutex.Unlock()
+	if current, ok := pc.bandwidths[ovsPortName]; ok && current == bandwidth {
+		return nil
+	}
+	tx := pc.ovsBridgeClient.NewPortTransaction()
+	setBandwidth(tx, ovsPortName, bandwidth)
+	if _, err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to set the bandwidth limits of OVS port %s: %v", ovsPortName, err)
+	}
+	pc.bandwidths[ovsPortName] = bandwidth
+	klog.Infof("Set the bandwidth limits of OVS port %s to %d bps ingress and %d bps egress", ovsPortName, bandwidth.ingress, bandwidth.egress)
+	return nil
+}
+
+// setCurrentBandwidth records the bandwidth limits set on the OVS port of a Pod when it is created.
+func (pc *podConfigurator) setCurrentBandwidth(ovsPortName string, bandwidth podBandwidth) {
+	pc.bandwidthMutex.Lock()
+	defer pc.bandwidthMutex.Unlock()
+	pc.bandwidths[ovsPortName] = bandwidth
+}
+
+// deleteCurrentBandwidth forgets the bandwidth limits of the OVS port of a Pod when it is deleted.
+func (pc *podConfigurator) deleteCurrentBandwidth(ovsPortName string) {
+	pc.bandwidthMutex.Lock()
+	defer pc.bandwidthMutex.Unlock()
+	delete(pc.bandwidths, ovsPortName)
+}
+
+// syncPodBandwidth updates the bandwidth limits of the OVS port of a Pod when its annotations change. The Pods whose
+// interface is not created yet are ignored, CmdAdd reads their annotations.
+func (s *CNIServer) syncPodBandwidth(pod *corev1.Pod) {
+	if pod.Spec.HostNetwork {
+		return
+	}
+	containerConfig, found := s.podConfigurator.ifaceStore.GetContainerInterface(pod.Name, pod.Namespace)
+	if !found {
+		return
+	}
+	bandwidth, err := getPodBandwidth(pod.Annotations)
+	if err != nil {
+		klog.Errorf("Failed to get the bandwidth limits of Pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		return
+	}
+	s.containerAccess.lockContainer(containerConfig.ID)
+	defer s.containerAccess.unlockContainer(containerConfig.ID)
+	// The interface may have been deleted while waiting for the lock.
+	if current, found := s.podConfigurator.ifaceStore.GetContainerInterface(pod.Name, pod.Namespace); !found || current.ID != containerConfig.ID {
+		return
+	}
+	if err := s.podConfigurator.updateBandwidth(containerConfig.IfaceName, bandwidth); err != nil {
+		klog.Errorf("Failed to update the bandwidth limits of Pod %s/%s: %v", pod.Namespace, pod.Name, err)
+	}
+}
+
+// readPodBandwidth reads the bandwidth limits of a Pod whose interface is being created. An error is returned if the
+// Pod is not in the informer cache yet, so that the interface is created again by the kubelet once it is.
+func (s *CNIServer) readPodBandwidth(podName, podNamespace string) (podBandwidth, error) {
+	pod, err := s.podLister.Pods(podNamespace).Get(podName)
+	if err != nil {
+		return podBandwidth{}, fmt.Errorf("failed to get Pod %s/%s: %v", podNamespace, podName, err)
+	}
+	return getPodBandwidth(pod.Annotations)
+}
+
+// addPodEventHandler updates the bandwidth limits of the OVS port of the Pods when their annotations change. The
+// bandwidth limits are only updated if they differ from the ones set on the OVS port, so all the updates can be
+// synced, including the periodic resyncs.
+func (s *CNIServer) addPodEventHandler(podInformer coreinformers.PodInformer) {
+	podInformer.Informer().AddEventHandlerWithResyncPeriod(
+		cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(_, obj interface{}) {
+				s.syncPodBandwidth(obj.(*corev1.Pod))
+			},
+		},
+		podResyncPeriod,
+	)
+}
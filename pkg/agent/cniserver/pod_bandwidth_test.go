@@ -0,0 +1,125 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cniserver
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vmware-tanzu/antrea/pkg/agent/interfacestore"
+	ovsconfigtest "github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig/testing"
+)
+
+func TestGetPodBandwidth(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    podBandwidth
+		expectedErr bool
+	}{
+		{
+			name:     "no annotation",
+			expected: podBandwidth{},
+		},
+		{
+			name:        "ingress and egress",
+			annotations: map[string]string{ingressBandwidthAnnotation: "10M", egressBandwidthAnnotation: "1500k"},
+			expected:    podBandwidth{ingress: 10000000, egress: 1500000},
+		},
+		{
+			name:        "egress only",
+			annotations: map[string]string{egressBandwidthAnnotation: "1G"},
+			expected:    podBandwidth{egress: 1000000000},
+		},
+		{
+			name:        "invalid quantity",
+			annotations: map[string]string{ingressBandwidthAnnotation: "fast"},
+			expectedErr: true,
+		},
+		{
+			name:        "too low",
+			annotations: map[string]string{ingressBandwidthAnnotation: "10"},
+			expectedErr: true,
+		},
+		{
+			name:        "too high",
+			annotations: map[string]string{egressBandwidthAnnotation: "10P"},
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bandwidth, err := getPodBandwidth(tt.annotations)
+			if tt.expectedErr {
+				assert.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tt.expected, bandwidth)
+		})
+	}
+}
+
+func TestUpdateBandwidth(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+	mockOVSBridgeClient := ovsconfigtest.NewMockOVSBridgeClient(controller)
+	mockPortTransaction := ovsconfigtest.NewMockPortTransaction(controller)
+	podConfigurator := newPodConfigurator(mockOVSBridgeClient, nil, interfacestore.NewInterfaceStore(), nil, "system")
+	portName := "pod1-abcdef"
+
+	bandwidth := podBandwidth{ingress: 10000000, egress: 2000000}
+	mockOVSBridgeClient.EXPECT().NewPortTransaction().Return(mockPortTransaction)
+	mockPortTransaction.EXPECT().SetPortQoS(portName, uint64(10000000), uint64(0))
+	mockPortTransaction.EXPECT().SetInterfaceIngressPolicing(portName, uint64(2000), uint64(0))
+	mockPortTransaction.EXPECT().Commit().Return(nil, nil)
+	require.Nil(t, podConfigurator.updateBandwidth(portName, bandwidth))
+
+	// The limits are not set again if they did not change.
+	require.Nil(t, podConfigurator.updateBandwidth(portName, bandwidth))
+
+	// The limits are removed.
+	mockOVSBridgeClient.EXPECT().NewPortTransaction().Return(mockPortTransaction)
+	mockPortTransaction.EXPECT().SetPortQoS(portName, uint64(0), uint64(0))
+	mockPortTransaction.EXPECT().SetInterfaceIngressPolicing(portName, uint64(0), uint64(0))
+	mockPortTransaction.EXPECT().Commit().Return(nil, nil)
+	require.Nil(t, podConfigurator.updateBandwidth(portName, podBandwidth{}))
+}
+
+func TestReadPodBandwidth(t *testing.T) {
+	podInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Pods()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "pod1",
+		Namespace:   "ns1",
+		Annotations: map[string]string{ingressBandwidthAnnotation: "10M"},
+	}}
+	require.Nil(t, podInformer.Informer().GetStore().Add(pod))
+	s := &CNIServer{podLister: podInformer.Lister()}
+
+	bandwidth, err := s.readPodBandwidth("pod1", "ns1")
+	require.Nil(t, err)
+	assert.Equal(t, podBandwidth{ingress: 10000000}, bandwidth)
+
+	// CmdAdd fails, and is retried by the kubelet, if the Pod is not in the informer cache yet.
+	_, err = s.readPodBandwidth("pod2", "ns1")
+	assert.NotNil(t, err)
+}
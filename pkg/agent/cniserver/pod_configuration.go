@@ -18,6 +18,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"strings"
+	"sync"
 
 	cnitypes "github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/types/current"
@@ -64,6 +66,10 @@ type podConfigurator struct {
 	ifaceStore      interfacestore.InterfaceStore
 	gatewayMAC      net.HardwareAddr
 	ovsDatapathType string
+	// bandwidthMutex protects bandwidths, which maps the name of the OVS port of each Pod to the bandwidth limits set
+	// on it.
+	bandwidthMutex sync.Mutex
+	bandwidths     map[string]podBandwidth
 }
 
 func newPodConfigurator(
@@ -73,7 +79,14 @@ func newPodConfigurator(
 	gatewayMAC net.HardwareAddr,
 	ovsDatapathType string,
 ) *podConfigurator {
-	return &podConfigurator{ovsBridgeClient, ofClient, ifaceStore, gatewayMAC, ovsDatapathType}
+	return &podConfigurator{
+		ovsBridgeClient: ovsBridgeClient,
+		ofClient:        ofClient,
+		ifaceStore:      ifaceStore,
+		gatewayMAC:      gatewayMAC,
+		ovsDatapathType: ovsDatapathType,
+		bandwidths:      map[string]podBandwidth{},
+	}
 }
 
 // setupInterfaces creates a veth pair: containerIface is in the container
@@ -125,7 +138,7 @@ func configureContainerAddr(netns ns.NetNS, containerInterface *current.Interfac
 			return err
 		}
 		// Send gratuitous ARP to network in case of stale mappings for this IP address
-		// (e.g. if a previous - deleted - Pod was using the same IP).
+		// (e.g. if a previous - deleted - Pod was using the same IP). IPv6 addresses are not announced.
 		for _, ipc := range result.IPs {
 			if ipc.Version == "4" {
 				// Ignore error
@@ -217,20 +230,26 @@ func validateContainerPeerInterface(hostIntf *current.Interface, containerVeth *
 	return hostVeth, nil
 }
 
-func parseContainerIP(ips []*current.IPConfig) (net.IP, error) {
+// parseContainerIPs returns the IPv4 and IPv6 addresses of the result, there is one address per IP family with
+// dual-stack.
+func parseContainerIPs(ips []*current.IPConfig) ([]net.IP, error) {
+	var containerIPs []net.IP
 	for _, ipc := range ips {
-		if ipc.Version == "4" {
-			return ipc.Address.IP, nil
+		if ipc.Version == "4" || ipc.Version == "6" {
+			containerIPs = append(containerIPs, ipc.Address.IP)
 		}
 	}
-	return nil, fmt.Errorf("failed to find a valid IP address")
+	if len(containerIPs) == 0 {
+		return nil, fmt.Errorf("failed to find a valid IP address")
+	}
+	return containerIPs, nil
 }
 
 func buildContainerConfig(
 	containerID, podName, podNamespace string,
 	containerIface *current.Interface,
 	ips []*current.IPConfig) *interfacestore.InterfaceConfig {
-	containerIP, err := parseContainerIP(ips)
+	containerIPs, err := parseContainerIPs(ips)
 	if err != nil {
 		klog.Errorf("Failed to find container %s IP", containerID)
 	}
@@ -242,7 +261,7 @@ func buildContainerConfig(
 		podNamespace,
 		containerIface.Sandbox,
 		containerMAC,
-		containerIP)
+		containerIPs)
 }
 
 // BuildOVSPortExternalIDs parses OVS port external_ids from InterfaceConfig.
@@ -251,12 +270,32 @@ func BuildOVSPortExternalIDs(containerConfig *interfacestore.InterfaceConfig) ma
 	externalIDs := make(map[string]interface{})
 	externalIDs[ovsExternalIDMAC] = containerConfig.MAC.String()
 	externalIDs[ovsExternalIDContainerID] = containerConfig.ID
-	externalIDs[ovsExternalIDIP] = containerConfig.IP.String()
+	externalIDs[ovsExternalIDIP] = formatIPs(containerConfig.IPs)
 	externalIDs[ovsExternalIDPodName] = containerConfig.PodName
 	externalIDs[ovsExternalIDPodNamespace] = containerConfig.PodNamespace
 	return externalIDs
 }
 
+// formatIPs returns the comma-separated list of the IPs, which is saved in the OVS port external_ids.
+func formatIPs(ips []net.IP) string {
+	ipStrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		ipStrs = append(ipStrs, ip.String())
+	}
+	return strings.Join(ipStrs, ",")
+}
+
+// parseIPs parses the comma-separated list of IPs saved in the OVS port external_ids.
+func parseIPs(ipsStr string) []net.IP {
+	var ips []net.IP
+	for _, ipStr := range strings.Split(ipsStr, ",") {
+		if ip := net.ParseIP(ipStr); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
 // ParseOVSPortInterfaceConfig reads the Pod properties saved in the OVS port
 // external_ids, initializes and returns an InterfaceConfig struct.
 // nill will be returned, if the OVS port does not have external IDs or it is
@@ -272,7 +311,7 @@ func ParseOVSPortInterfaceConfig(portData *ovsconfig.OVSPortData, portConfig *in
 		klog.V(2).Infof("OVS port %s has no %s in external_ids", portData.Name, ovsExternalIDContainerID)
 		return nil
 	}
-	containerIP := net.ParseIP(portData.ExternalIDs[ovsExternalIDIP])
+	containerIPs := parseIPs(portData.ExternalIDs[ovsExternalIDIP])
 	containerMAC, err := net.ParseMAC(portData.ExternalIDs[ovsExternalIDMAC])
 	if err != nil {
 		klog.Errorf("Failed to parse MAC address from OVS external config %s: %v",
@@ -284,7 +323,7 @@ func ParseOVSPortInterfaceConfig(portData *ovsconfig.OVSPortData, portConfig *in
 		Type:          interfacestore.ContainerInterface,
 		OVSPortConfig: portConfig,
 		ID:            containerID,
-		IP:            containerIP,
+		IPs:           containerIPs,
 		MAC:           containerMAC,
 		PodName:       podName,
 		PodNamespace:  podNamespace}
@@ -297,6 +336,7 @@ func (pc *podConfigurator) configureInterface(
 	containerNetNS string,
 	ifname string,
 	mtu int,
+	bandwidth podBandwidth,
 	result *current.Result,
 ) error {
 	netns, err := ns.GetNS(containerNetNS)
@@ -325,7 +365,7 @@ func (pc *podConfigurator) configureInterface(
 	// create OVS Port and add attach container configuration into external_ids
 	ovsPortName := hostIface.Name
 	klog.V(2).Infof("Adding OVS port %s for container %s", ovsPortName, containerID)
-	portUUID, err := pc.setupContainerOVSPort(containerConfig, ovsPortName)
+	portUUID, err := pc.setupContainerOVSPort(containerConfig, ovsPortName, mtu, bandwidth)
 	if err != nil {
 		return err
 	}
@@ -347,7 +387,7 @@ func (pc *podConfigurator) configureInterface(
 	klog.V(2).Infof("Setting up Openflow entries for container %s", containerID)
 	err = pc.ofClient.InstallPodFlows(
 		ovsPortName,
-		containerConfig.IP,
+		containerConfig.IPs,
 		containerConfig.MAC,
 		pc.gatewayMAC,
 		uint32(ofPort))
@@ -374,22 +414,34 @@ func (pc *podConfigurator) configureInterface(
 	containerConfig.OVSPortConfig = &interfacestore.OVSPortConfig{PortUUID: portUUID, IfaceName: ovsPortName, OFPort: ofPort}
 	// Add containerConfig into local cache
 	pc.ifaceStore.AddInterface(ovsPortName, containerConfig)
+	pc.setCurrentBandwidth(ovsPortName, bandwidth)
 	// Mark the manipulation as success to cancel defer deletion
 	success = true
 	klog.Infof("Interface added successfully for container %s", containerID)
 	return nil
 }
 
+// setupContainerOVSPort creates the OVS port of a container, with its external_ids, MTU and bandwidth limits, in one
+// OVSDB transaction, so that no port is left behind if a step fails.
 func (pc *podConfigurator) setupContainerOVSPort(
 	containerConfig *interfacestore.InterfaceConfig,
-	ovsPortName string) (string, error) {
+	ovsPortName string,
+	mtu int,
+	bandwidth podBandwidth) (string, error) {
 	ovsAttchInfo := BuildOVSPortExternalIDs(containerConfig)
-	if portUUID, err := pc.ovsBridgeClient.CreatePort(ovsPortName, ovsPortName, ovsAttchInfo); err != nil {
+	tx := pc.ovsBridgeClient.NewPortTransaction()
+	tx.CreatePort(ovsPortName, ovsPortName, "", 0)
+	tx.SetPortExternalIDs(ovsPortName, ovsAttchInfo)
+	tx.SetInterfaceMTU(ovsPortName, mtu)
+	if bandwidth != (podBandwidth{}) {
+		setBandwidth(tx, ovsPortName, bandwidth)
+	}
+	portUUIDs, err := tx.Commit()
+	if err != nil {
 		klog.Errorf("Failed to add OVS port %s, remove from local cache: %v", ovsPortName, err)
 		return "", err
-	} else {
-		return portUUID, nil
 	}
+	return portUUIDs[ovsPortName], nil
 }
 
 func removeContainerLink(containerID string, containerNetns string, ifname string) error {
@@ -444,6 +496,7 @@ func (pc *podConfigurator) removeInterfaces(podName, podNamespace, containerID,
 	}
 	// Remove container configuration from cache.
 	pc.ifaceStore.DeleteInterface(ovsPortName)
+	pc.deleteCurrentBandwidth(ovsPortName)
 	klog.Infof("Interfaces removed successfully for container %s", containerID)
 	return nil
 }
@@ -543,14 +596,24 @@ func (pc *podConfigurator) validateOVSPort(
 				containerID, ovsPortName)
 		}
 
-		for _, ipc := range ips {
-			if ipc.Version == "4" {
-				if containerConfig.IP.Equal(ipc.Address.IP) {
-					return nil
+		// Every address of the result must be attached to the OVS port.
+		containerIPs, err := parseContainerIPs(ips)
+		if err != nil {
+			return err
+		}
+		for _, containerIP := range containerIPs {
+			found := false
+			for _, ip := range containerConfig.IPs {
+				if ip.Equal(containerIP) {
+					found = true
+					break
 				}
 			}
+			if !found {
+				return fmt.Errorf("failed to find attached address %s in the valid IPs", containerIP)
+			}
 		}
-		return fmt.Errorf("failed to find a valid IP equal to attached address")
+		return nil
 	} else {
 		klog.V(2).Infof("Not found container %s config from local cache", containerID)
 		return fmt.Errorf("not found OVS port %s", ovsPortName)
@@ -607,7 +670,7 @@ func (pc *podConfigurator) reconcile(pods []corev1.Pod) error {
 		klog.V(4).Infof("Syncing interface %s for Pod %s/%s", containerConfig.IfaceName, pod.Namespace, pod.Name)
 		if err := pc.ofClient.InstallPodFlows(
 			containerConfig.IfaceName,
-			containerConfig.IP,
+			containerConfig.IPs,
 			containerConfig.MAC,
 			pc.gatewayMAC,
 			uint32(containerConfig.OFPort),
@@ -615,6 +678,12 @@ func (pc *podConfigurator) reconcile(pods []corev1.Pod) error {
 			klog.Errorf("Error when re-installing flows for Pod %s/%s", pod.Namespace, pod.Name)
 			continue
 		}
+		// The annotations of the Pod may have changed while the Agent was down.
+		if bandwidth, err := getPodBandwidth(pod.Annotations); err != nil {
+			klog.Errorf("Failed to get the bandwidth limits of Pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		} else if err := pc.updateBandwidth(containerConfig.IfaceName, bandwidth); err != nil {
+			klog.Errorf("Failed to update the bandwidth limits of Pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
 		desiredInterfaces[containerConfig.IfaceName] = true
 	}
 
@@ -31,7 +31,10 @@ import (
 	"github.com/containernetworking/plugins/pkg/ip"
 	"google.golang.org/grpc"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 
 	"github.com/vmware-tanzu/antrea/pkg/agent/cniserver/ipam"
@@ -95,6 +98,8 @@ type CNIServer struct {
 	hostProcPathPrefix   string
 	defaultMTU           int
 	kubeClient           clientset.Interface
+	podLister            corelisters.PodLister
+	podListerSynced      cache.InformerSynced
 	containerAccess      *containerAccessArbitrator
 	podConfigurator      *podConfigurator
 }
@@ -127,8 +132,9 @@ type CNIConfig struct {
 //   * updates the IP configuration for each assigned IP address: this includes computing the
 //     gateway (if missing) based on the subnet and setting the interface pointer to the container
 //     interface
-//   * if there is no default route, add one using the provided default gateway
-func updateResultIfaceConfig(result *current.Result, defaultV4Gateway net.IP) {
+//   * if there is no default route, add one using the provided default gateway of each IP family, IPv4 and / or
+//     IPv6
+func updateResultIfaceConfig(result *current.Result, defaultIPv4Gateway net.IP, defaultIPv6Gateway net.IP) {
 	for _, ipc := range result.IPs {
 		// result.Interfaces[0] is host interface, and result.Interfaces[1] is container interface
 		ipc.Interface = current.Int(1)
@@ -139,22 +145,26 @@ func updateResultIfaceConfig(result *current.Result, defaultV4Gateway net.IP) {
 		}
 	}
 
-	foundDefaultRoute := false
-	defaultRouteDst := "0.0.0.0/0"
-	if result.Routes != nil {
-		for _, route := range result.Routes {
-			if route.Dst.String() == defaultRouteDst {
-				foundDefaultRoute = true
-				break
-			}
-		}
-	} else {
+	if result.Routes == nil {
 		result.Routes = []*cnitypes.Route{}
 	}
-	if !foundDefaultRoute {
-		_, defaultRouteDstNet, _ := net.ParseCIDR(defaultRouteDst)
-		result.Routes = append(result.Routes, &cnitypes.Route{Dst: *defaultRouteDstNet, GW: defaultV4Gateway})
+	if defaultIPv4Gateway != nil {
+		addDefaultRoute(result, "0.0.0.0/0", defaultIPv4Gateway)
+	}
+	if defaultIPv6Gateway != nil {
+		addDefaultRoute(result, "::/0", defaultIPv6Gateway)
+	}
+}
+
+// addDefaultRoute adds a route to defaultRouteDst via defaultGateway to the result, unless it has one already.
+func addDefaultRoute(result *current.Result, defaultRouteDst string, defaultGateway net.IP) {
+	for _, route := range result.Routes {
+		if route.Dst.String() == defaultRouteDst {
+			return
+		}
 	}
+	_, defaultRouteDstNet, _ := net.ParseCIDR(defaultRouteDst)
+	result.Routes = append(result.Routes, &cnitypes.Route{Dst: *defaultRouteDstNet, GW: defaultGateway})
 }
 
 func (s *CNIServer) loadNetworkConfig(request *cnipb.CniCmdRequest) (*CNIConfig, error) {
@@ -202,9 +212,18 @@ func (s *CNIServer) checkRequestMessage(request *cnipb.CniCmdRequest) (*CNIConfi
 	return cniConfig, nil
 }
 
+// updateLocalIPAMSubnet sets the Pod CIDRs of the Node in the IPAM configuration. With dual-stack, the IPv6 CIDR is
+// set as an additional range, so that the IPAM plugin allocates one address of each IP family.
 func (s *CNIServer) updateLocalIPAMSubnet(cniConfig *CNIConfig) {
-	cniConfig.NetworkConfig.IPAM.Gateway = s.nodeConfig.GatewayConfig.IP.String()
-	cniConfig.NetworkConfig.IPAM.Subnet = s.nodeConfig.PodCIDR.String()
+	gatewayIPs := s.nodeConfig.GatewayConfig.IPs()
+	podCIDRs := s.nodeConfig.PodCIDRs()
+	cniConfig.NetworkConfig.IPAM.Gateway = gatewayIPs[0].String()
+	cniConfig.NetworkConfig.IPAM.Subnet = podCIDRs[0].String()
+	cniConfig.NetworkConfig.IPAM.Ranges = nil
+	for i := 1; i < len(podCIDRs); i++ {
+		cniConfig.NetworkConfig.IPAM.Ranges = append(cniConfig.NetworkConfig.IPAM.Ranges,
+			ipam.RangeSet{{Subnet: podCIDRs[i].String(), Gateway: gatewayIPs[i].String()}})
+	}
 	cniConfig.NetworkConfiguration, _ = json.Marshal(cniConfig.NetworkConfig)
 }
 
@@ -387,10 +406,15 @@ func (s *CNIServer) CmdAdd(ctx context.Context, request *cnipb.CniCmdRequest) (
 	result.IPs = ipamResult.IPs
 	result.Routes = ipamResult.Routes
 	// Ensure interface gateway setting and mapping relations between result.Interfaces and result.IPs
-	updateResultIfaceConfig(result, s.nodeConfig.GatewayConfig.IP)
+	updateResultIfaceConfig(result, s.nodeConfig.GatewayConfig.IPv4, s.nodeConfig.GatewayConfig.IPv6)
 	// Setup pod interfaces and connect to ovs bridge
 	podName := string(cniConfig.K8S_POD_NAME)
 	podNamespace := string(cniConfig.K8S_POD_NAMESPACE)
+	bandwidth, err := s.readPodBandwidth(podName, podNamespace)
+	if err != nil {
+		klog.Errorf("Failed to get the bandwidth limits of container %s: %v", cniConfig.ContainerId, err)
+		return s.configInterfaceFailureResponse(err), nil
+	}
 	if err = s.podConfigurator.configureInterface(
 		podName,
 		podNamespace,
@@ -398,6 +422,7 @@ func (s *CNIServer) CmdAdd(ctx context.Context, request *cnipb.CniCmdRequest) (
 		netNS,
 		cniConfig.Ifname,
 		cniConfig.MTU,
+		bandwidth,
 		result,
 	); err != nil {
 		klog.Errorf("Failed to configure container %s interface: %v", cniConfig.ContainerId, err)
@@ -488,8 +513,9 @@ func New(
 	ofClient openflow.Client,
 	ifaceStore interfacestore.InterfaceStore,
 	kubeClient clientset.Interface,
+	podInformer coreinformers.PodInformer,
 ) *CNIServer {
-	return &CNIServer{
+	s := &CNIServer{
 		cniSocket:            cniSocket,
 		supportedCNIVersions: supportedCNIVersionSet,
 		serverVersion:        cni.AntreaCNIVersion,
@@ -497,9 +523,13 @@ func New(
 		hostProcPathPrefix:   hostProcPathPrefix,
 		defaultMTU:           defaultMTU,
 		kubeClient:           kubeClient,
+		podLister:            podInformer.Lister(),
+		podListerSynced:      podInformer.Informer().HasSynced,
 		containerAccess:      newContainerAccessArbitrator(),
 		podConfigurator:      newPodConfigurator(ovsBridgeClient, ofClient, ifaceStore, nodeConfig.GatewayConfig.MAC, ovsDatapathType),
 	}
+	s.addPodEventHandler(podInformer)
+	return s
 }
 
 func (s *CNIServer) Initialize() error {
@@ -513,6 +543,12 @@ func (s *CNIServer) Run(stopCh <-chan struct{}) {
 	klog.Info("Starting CNI server")
 	defer klog.Info("Shutting down CNI server")
 
+	// The Pods are read from the informer cache when their interface is created.
+	if !cache.WaitForCacheSync(stopCh, s.podListerSynced) {
+		klog.Error("Unable to sync caches for CNI server")
+		return
+	}
+
 	// remove before bind to avoid "address already in use" errors
 	os.Remove(s.cniSocket)
 
@@ -81,11 +81,11 @@ func TestLoadNetConfig(t *testing.T) {
 	assert.Equal(networkCfg.Name, netCfg.Name)
 	assert.Equal(networkCfg.IPAM.Type, netCfg.IPAM.Type)
 	assert.Equal(
-		netCfg.IPAM.Subnet, testNodeConfig.PodCIDR.String(),
+		netCfg.IPAM.Subnet, testNodeConfig.PodIPv4CIDR.String(),
 		"Network configuration (PodCIDR) was not updated",
 	)
 	assert.Equal(
-		netCfg.IPAM.Gateway, testNodeConfig.GatewayConfig.IP.String(),
+		netCfg.IPAM.Gateway, testNodeConfig.GatewayConfig.IPv4.String(),
 		"Network configuration (Gateway IP) was not updated",
 	)
 }
@@ -276,13 +276,13 @@ func TestUpdateResultIfaceConfig(t *testing.T) {
 	// return a Result with 2 v4 addresses.
 	testIps := []string{"10.1.2.100/24, ,4", "192.168.1.100/24, 192.168.2.253, 4"}
 
-	require.Equal(gwIP, testNodeConfig.GatewayConfig.IP)
+	require.Equal(gwIP, testNodeConfig.GatewayConfig.IPv4)
 
 	t.Run("Gateways updated", func(t *testing.T) {
 		assert := assert.New(t)
 
 		result := ipamtest.GenerateIPAMResult(supportedCNIVersion, testIps, routes, dns)
-		updateResultIfaceConfig(result, gwIP)
+		updateResultIfaceConfig(result, gwIP, nil)
 
 		assert.Len(result.IPs, 2, "Failed to construct result")
 		for _, ipc := range result.IPs {
@@ -300,7 +300,7 @@ func TestUpdateResultIfaceConfig(t *testing.T) {
 	t.Run("Default route added", func(t *testing.T) {
 		emptyRoutes := []string{}
 		result := ipamtest.GenerateIPAMResult(supportedCNIVersion, testIps, emptyRoutes, dns)
-		updateResultIfaceConfig(result, gwIP)
+		updateResultIfaceConfig(result, gwIP, nil)
 		require.NotEmpty(t, result.Routes)
 		defaultRoute := func() *cnitypes.Route {
 			for _, route := range result.Routes {
@@ -312,6 +312,28 @@ func TestUpdateResultIfaceConfig(t *testing.T) {
 		}()
 		assert.NotNil(t, defaultRoute.GW)
 	})
+
+	t.Run("IPv6 default route added", func(t *testing.T) {
+		v6GatewayIP := net.ParseIP("fd00:10:1:2::1")
+		result := ipamtest.GenerateIPAMResult(supportedCNIVersion, []string{"fd00:10:1:2::100/64, ,6"}, []string{}, dns)
+		updateResultIfaceConfig(result, nil, v6GatewayIP)
+		require.Len(result.Routes, 1)
+		assert.Equal(t, "::/0", result.Routes[0].Dst.String())
+		assert.Equal(t, v6GatewayIP, result.Routes[0].GW)
+		assert.Equal(t, "fd00:10:1:2::1", result.IPs[0].Gateway.String())
+	})
+
+	t.Run("Dual-stack default routes added", func(t *testing.T) {
+		v6GatewayIP := net.ParseIP("fd00:10:1:2::1")
+		dualStackIps := []string{"10.1.2.100/24, ,4", "fd00:10:1:2::100/64, ,6"}
+		result := ipamtest.GenerateIPAMResult(supportedCNIVersion, dualStackIps, []string{}, dns)
+		updateResultIfaceConfig(result, gwIP, v6GatewayIP)
+		require.Len(result.Routes, 2)
+		assert.Equal(t, "0.0.0.0/0", result.Routes[0].Dst.String())
+		assert.Equal(t, gwIP, result.Routes[0].GW)
+		assert.Equal(t, "::/0", result.Routes[1].Dst.String())
+		assert.Equal(t, v6GatewayIP, result.Routes[1].GW)
+	})
 }
 
 func TestValidateOVSPort(t *testing.T) {
@@ -348,7 +370,7 @@ func TestRemoveInterface(t *testing.T) {
 		ifaceStore:      ifaceStore,
 	}
 	containerMAC, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
-	containerIP := net.ParseIP("1.1.1.1")
+	containerIPs := []net.IP{net.ParseIP("1.1.1.1")}
 
 	var cniConfig *CNIConfig
 	var containerID string
@@ -369,7 +391,7 @@ func TestRemoveInterface(t *testing.T) {
 		cniConfig.ContainerId = containerID
 		cniConfig.Netns = ""
 
-		containerConfig = interfacestore.NewContainerInterface(containerID, podName, testPodNamespace, "", containerMAC, containerIP)
+		containerConfig = interfacestore.NewContainerInterface(containerID, podName, testPodNamespace, "", containerMAC, containerIPs)
 		containerConfig.OVSPortConfig = &interfacestore.OVSPortConfig{hostIfaceName, fakePortUUID, 0}
 	}
 
@@ -415,11 +437,11 @@ func TestRemoveInterface(t *testing.T) {
 func TestBuildOVSPortExternalIDs(t *testing.T) {
 	containerID := uuid.New().String()
 	containerMAC, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
-	containerIP := net.ParseIP("10.1.2.100")
-	containerConfig := interfacestore.NewContainerInterface(containerID, "test-1", "t1", "", containerMAC, containerIP)
+	containerIPs := []net.IP{net.ParseIP("10.1.2.100"), net.ParseIP("fd00:10:1:2::100")}
+	containerConfig := interfacestore.NewContainerInterface(containerID, "test-1", "t1", "", containerMAC, containerIPs)
 	externalIds := BuildOVSPortExternalIDs(containerConfig)
 	parsedIP, existed := externalIds[ovsExternalIDIP]
-	if !existed || parsedIP != "10.1.2.100" {
+	if !existed || parsedIP != "10.1.2.100,fd00:10:1:2::100" {
 		t.Errorf("Failed to parse container configuration")
 	}
 	parsedMac, existed := externalIds[ovsExternalIDMAC]
@@ -504,6 +526,6 @@ func init() {
 	gwIP = net.ParseIP("192.168.1.1")
 	_, nodePodCIDR, _ := net.ParseCIDR("192.168.1.0/24")
 	gwMAC, _ := net.ParseMAC("00:00:00:00:00:01")
-	gateway := &types.GatewayConfig{Name: "gw", IP: gwIP, MAC: gwMAC}
-	testNodeConfig = &types.NodeConfig{testBr, nodeName, nodePodCIDR, gateway}
+	gateway := &types.GatewayConfig{Name: "gw", IPv4: gwIP, MAC: gwMAC}
+	testNodeConfig = &types.NodeConfig{Bridge: testBr, Name: nodeName, PodIPv4CIDR: nodePodCIDR, GatewayConfig: gateway}
 }
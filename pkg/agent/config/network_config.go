@@ -0,0 +1,219 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
+)
+
+const (
+	// vxlanOverhead is the overhead of the VXLAN encapsulation with an IPv4 outer header: the inner Ethernet header
+	// (14 bytes), the VXLAN header (8 bytes), the UDP header (8 bytes) and the IPv4 header (20 bytes).
+	vxlanOverhead = 50
+	// geneveOverhead is the overhead of the Geneve encapsulation with an IPv4 outer header and no option, it has the
+	// same headers as VXLAN. The pipeline doesn't set any Geneve option.
+	geneveOverhead = 50
+	// ipv6ExtraOverhead is the difference between the sizes of the IPv6 and IPv4 headers.
+	ipv6ExtraOverhead = 20
+	// ipsecESPOverhead is the maximum overhead of ESP in transport mode with AES-GCM: the ESP header (8 bytes), the IV
+	// (8 bytes), the padding (up to 3 bytes), the ESP trailer (2 bytes) and the ICV (16 bytes).
+	ipsecESPOverhead = 38
+	// wireGuardOverhead is the overhead of WireGuard with an IPv4 outer header: the IPv4 header (20 bytes), the UDP
+	// header (8 bytes) and the WireGuard data header and authentication tag (32 bytes). The WireGuard device is a L3
+	// device, there is no inner Ethernet header.
+	wireGuardOverhead = 60
+)
+
+// TrafficEncapModeType is the mode in which the Pod traffic across Nodes is forwarded.
+type TrafficEncapModeType int
+
+const (
+	// TrafficEncapModeEncap encapsulates the Pod traffic across Nodes in a tunnel.
+	TrafficEncapModeEncap TrafficEncapModeType = iota
+	// TrafficEncapModeNoEncap routes the Pod traffic across Nodes through the host network, with the IP of each Node
+	// as the next hop of its Pod CIDRs. The Pod IPs are preserved, so the Nodes must be in the same L2 network, or the
+	// underlay network must route the Pod CIDRs itself.
+	TrafficEncapModeNoEncap
+	// TrafficEncapModeHybrid routes the Pod traffic to the Nodes whose IP is in the subnet of the local Node IP like
+	// TrafficEncapModeNoEncap, and encapsulates the Pod traffic to the other Nodes in a tunnel.
+	TrafficEncapModeHybrid
+)
+
+var trafficEncapModeStrs = [...]string{
+	TrafficEncapModeEncap:   "encap",
+	TrafficEncapModeNoEncap: "noEncap",
+	TrafficEncapModeHybrid:  "hybrid",
+}
+
+func (m TrafficEncapModeType) String() string {
+	if m < 0 || int(m) >= len(trafficEncapModeStrs) {
+		return fmt.Sprintf("TrafficEncapModeType(%d)", int(m))
+	}
+	return trafficEncapModeStrs[m]
+}
+
+// GetTrafficEncapModeFromStr returns the TrafficEncapModeType of its name in the configuration, e.g. "noEncap".
+func GetTrafficEncapModeFromStr(str string) (TrafficEncapModeType, error) {
+	for mode, modeStr := range trafficEncapModeStrs {
+		if str == modeStr {
+			return TrafficEncapModeType(mode), nil
+		}
+	}
+	return TrafficEncapModeEncap, fmt.Errorf("traffic encapsulation mode %s is invalid", str)
+}
+
+// SupportsEncap returns true if the Pod traffic across Nodes may be encapsulated, i.e. the tunnel port is needed.
+func (m TrafficEncapModeType) SupportsEncap() bool {
+	return m == TrafficEncapModeEncap || m == TrafficEncapModeHybrid
+}
+
+// SupportsNoEncap returns true if the Pod traffic across Nodes may be routed by the hosts without encapsulation.
+func (m TrafficEncapModeType) SupportsNoEncap() bool {
+	return m == TrafficEncapModeNoEncap || m == TrafficEncapModeHybrid
+}
+
+// NeedsEncapToPeer returns true if the Pod traffic to the peer Node with IP peerNodeIP must be encapsulated.
+// localNodeIP is the IP of the local Node, with the mask of its subnet.
+func (m TrafficEncapModeType) NeedsEncapToPeer(peerNodeIP net.IP, localNodeIP *net.IPNet) bool {
+	switch m {
+	case TrafficEncapModeEncap:
+		return true
+	case TrafficEncapModeHybrid:
+		return localNodeIP == nil || !localNodeIP.Contains(peerNodeIP)
+	default:
+		return false
+	}
+}
+
+// TrafficEncryptionModeType is the mode in which the Pod traffic across Nodes is encrypted.
+type TrafficEncryptionModeType int
+
+const (
+	// TrafficEncryptionModeNone does not encrypt the Pod traffic across Nodes.
+	TrafficEncryptionModeNone TrafficEncryptionModeType = iota
+	// TrafficEncryptionModeIPSec encapsulates the Pod traffic across Nodes in an IPSec tunnel for each peer Node.
+	TrafficEncryptionModeIPSec
+	// TrafficEncryptionModeWireGuard routes the Pod traffic across Nodes through a WireGuard device, which has a peer
+	// for each peer Node, instead of encapsulating it in the OVS tunnel.
+	TrafficEncryptionModeWireGuard
+)
+
+var trafficEncryptionModeStrs = [...]string{
+	TrafficEncryptionModeNone:      "none",
+	TrafficEncryptionModeIPSec:     "ipsec",
+	TrafficEncryptionModeWireGuard: "wireguard",
+}
+
+func (m TrafficEncryptionModeType) String() string {
+	if m < 0 || int(m) >= len(trafficEncryptionModeStrs) {
+		return fmt.Sprintf("TrafficEncryptionModeType(%d)", int(m))
+	}
+	return trafficEncryptionModeStrs[m]
+}
+
+// GetTrafficEncryptionModeFromStr returns the TrafficEncryptionModeType of its name in the configuration, e.g.
+// "wireguard".
+func GetTrafficEncryptionModeFromStr(str string) (TrafficEncryptionModeType, error) {
+	for mode, modeStr := range trafficEncryptionModeStrs {
+		if str == modeStr {
+			return TrafficEncryptionModeType(mode), nil
+		}
+	}
+	return TrafficEncryptionModeNone, fmt.Errorf("traffic encryption mode %s is invalid", str)
+}
+
+// IPSecAuthenticationModeType is the mode in which the IKE peers of the IPSec tunnels authenticate each other.
+type IPSecAuthenticationModeType int
+
+const (
+	// IPSecAuthenticationModePSK authenticates the peers with the pre-shared key of the Pod network.
+	IPSecAuthenticationModePSK IPSecAuthenticationModeType = iota
+	// IPSecAuthenticationModeCert authenticates the peers with the X.509 certificates of the Nodes, which are issued
+	// through Kubernetes CertificateSigningRequests.
+	IPSecAuthenticationModeCert
+)
+
+var ipsecAuthenticationModeStrs = [...]string{
+	IPSecAuthenticationModePSK:  "psk",
+	IPSecAuthenticationModeCert: "cert",
+}
+
+func (m IPSecAuthenticationModeType) String() string {
+	if m < 0 || int(m) >= len(ipsecAuthenticationModeStrs) {
+		return fmt.Sprintf("IPSecAuthenticationModeType(%d)", int(m))
+	}
+	return ipsecAuthenticationModeStrs[m]
+}
+
+// GetIPSecAuthenticationModeFromStr returns the IPSecAuthenticationModeType of its name in the configuration, e.g.
+// "cert".
+func GetIPSecAuthenticationModeFromStr(str string) (IPSecAuthenticationModeType, error) {
+	for mode, modeStr := range ipsecAuthenticationModeStrs {
+		if str == modeStr {
+			return IPSecAuthenticationModeType(mode), nil
+		}
+	}
+	return IPSecAuthenticationModePSK, fmt.Errorf("IPSec authentication mode %s is invalid", str)
+}
+
+// NetworkConfig is the configuration of the Pod network across the Nodes, it is the same on all the Nodes of the
+// cluster.
+type NetworkConfig struct {
+	TrafficEncapMode        TrafficEncapModeType
+	TunnelType              ovsconfig.TunnelType
+	TrafficEncryptionMode   TrafficEncryptionModeType
+	IPSecAuthenticationMode IPSecAuthenticationModeType
+	// IPSecPSK is the pre-shared key of the IPSec tunnels, it is set in TrafficEncryptionModeIPSec with
+	// IPSecAuthenticationModePSK.
+	IPSecPSK string
+	// WireGuardPort is the UDP port of the WireGuard device of every Node, it is set in
+	// TrafficEncryptionModeWireGuard.
+	WireGuardPort int
+	// TransportInterface and TransportInterfaceCIDR select the address of the Node used for the tunnels and the
+	// routes to the other Nodes. At most one of them is set, the Node IP is used if none is.
+	TransportInterface     string
+	TransportInterfaceCIDR *net.IPNet
+}
+
+// CalculateMTUDeduction returns the number of bytes which the encapsulation and the encryption of the Pod traffic
+// across Nodes add to the packets, i.e. the difference between the MTU of the transport interface and the MTU of the
+// Pods. isIPv6 is true if the transport address is an IPv6 address, the outer IP header is then IPv6. In hybrid mode,
+// the full overhead of the encapsulation is deducted even though the traffic to the Nodes in the same subnet is not
+// encapsulated: the Pods have a single MTU, which must fit the traffic to the Nodes reached through the tunnel.
+func (nc *NetworkConfig) CalculateMTUDeduction(isIPv6 bool) int {
+	var deduction int
+	if nc.TrafficEncryptionMode == TrafficEncryptionModeWireGuard {
+		// The Pod traffic is routed through the WireGuard device instead of the tunnel.
+		deduction = wireGuardOverhead
+	} else if nc.TrafficEncapMode.SupportsEncap() {
+		if nc.TunnelType == ovsconfig.GeneveTunnel {
+			deduction = geneveOverhead
+		} else {
+			deduction = vxlanOverhead
+		}
+		if nc.TrafficEncryptionMode == TrafficEncryptionModeIPSec {
+			deduction += ipsecESPOverhead
+		}
+	} else {
+		return 0
+	}
+	if isIPv6 {
+		deduction += ipv6ExtraOverhead
+	}
+	return deduction
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
+)
+
+func TestGetTrafficEncapModeFromStr(t *testing.T) {
+	for _, mode := range []TrafficEncapModeType{TrafficEncapModeEncap, TrafficEncapModeNoEncap, TrafficEncapModeHybrid} {
+		parsed, err := GetTrafficEncapModeFromStr(mode.String())
+		assert.Nil(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+	_, err := GetTrafficEncapModeFromStr("noencap")
+	assert.NotNil(t, err)
+}
+
+func TestGetTrafficEncryptionModeFromStr(t *testing.T) {
+	for _, mode := range []TrafficEncryptionModeType{TrafficEncryptionModeNone, TrafficEncryptionModeIPSec, TrafficEncryptionModeWireGuard} {
+		parsed, err := GetTrafficEncryptionModeFromStr(mode.String())
+		assert.Nil(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+	_, err := GetTrafficEncryptionModeFromStr("WireGuard")
+	assert.NotNil(t, err)
+}
+
+func TestGetIPSecAuthenticationModeFromStr(t *testing.T) {
+	for _, mode := range []IPSecAuthenticationModeType{IPSecAuthenticationModePSK, IPSecAuthenticationModeCert} {
+		parsed, err := GetIPSecAuthenticationModeFromStr(mode.String())
+		assert.Nil(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+	_, err := GetIPSecAuthenticationModeFromStr("certificate")
+	assert.NotNil(t, err)
+}
+
+func TestNeedsEncapToPeer(t *testing.T) {
+	_, localSubnet, _ := net.ParseCIDR("192.168.1.0/24")
+	localNodeIP := &net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: localSubnet.Mask}
+	sameSubnetPeer := net.ParseIP("192.168.1.11")
+	otherSubnetPeer := net.ParseIP("192.168.2.11")
+
+	tests := []struct {
+		mode      TrafficEncapModeType
+		peerIP    net.IP
+		wantEncap bool
+	}{
+		{TrafficEncapModeEncap, sameSubnetPeer, true},
+		{TrafficEncapModeEncap, otherSubnetPeer, true},
+		{TrafficEncapModeNoEncap, sameSubnetPeer, false},
+		{TrafficEncapModeNoEncap, otherSubnetPeer, false},
+		{TrafficEncapModeHybrid, sameSubnetPeer, false},
+		{TrafficEncapModeHybrid, otherSubnetPeer, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.wantEncap, tt.mode.NeedsEncapToPeer(tt.peerIP, localNodeIP), "mode %s, peer %s", tt.mode, tt.peerIP)
+	}
+	// The subnet of the local Node is unknown.
+	assert.True(t, TrafficEncapModeHybrid.NeedsEncapToPeer(sameSubnetPeer, nil))
+}
+
+func TestCalculateMTUDeduction(t *testing.T) {
+	tests := []struct {
+		name              string
+		networkConfig     NetworkConfig
+		expectedDeduction int
+	}{
+		{"vxlan", NetworkConfig{TunnelType: ovsconfig.VXLANTunnel}, 50},
+		{"geneve", NetworkConfig{TunnelType: ovsconfig.GeneveTunnel}, 50},
+		{"noEncap", NetworkConfig{TrafficEncapMode: TrafficEncapModeNoEncap, TunnelType: ovsconfig.GeneveTunnel}, 0},
+		// In hybrid mode, the full overhead of the encapsulation is deducted.
+		{"hybrid vxlan", NetworkConfig{TrafficEncapMode: TrafficEncapModeHybrid, TunnelType: ovsconfig.VXLANTunnel}, 50},
+		{"hybrid geneve", NetworkConfig{TrafficEncapMode: TrafficEncapModeHybrid, TunnelType: ovsconfig.GeneveTunnel}, 50},
+		{"ipsec vxlan", NetworkConfig{TunnelType: ovsconfig.VXLANTunnel, TrafficEncryptionMode: TrafficEncryptionModeIPSec}, 88},
+		{"ipsec geneve", NetworkConfig{TunnelType: ovsconfig.GeneveTunnel, TrafficEncryptionMode: TrafficEncryptionModeIPSec}, 88},
+		{"hybrid ipsec", NetworkConfig{TrafficEncapMode: TrafficEncapModeHybrid, TunnelType: ovsconfig.GeneveTunnel, TrafficEncryptionMode: TrafficEncryptionModeIPSec}, 88},
+		// The Pod traffic is routed through the WireGuard device instead of the tunnel.
+		{"wireguard", NetworkConfig{TunnelType: ovsconfig.VXLANTunnel, TrafficEncryptionMode: TrafficEncryptionModeWireGuard}, 60},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expectedDeduction, tt.networkConfig.CalculateMTUDeduction(false), "%s with IPv4 transport", tt.name)
+		// The outer IPv6 header is 20 bytes longer than the IPv4 one, there is no outer header in noEncap mode.
+		expectedIPv6Deduction := tt.expectedDeduction
+		if expectedIPv6Deduction > 0 {
+			expectedIPv6Deduction += 20
+		}
+		assert.Equal(t, expectedIPv6Deduction, tt.networkConfig.CalculateMTUDeduction(true), "%s with IPv6 transport", tt.name)
+	}
+}
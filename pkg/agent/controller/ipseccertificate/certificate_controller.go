@@ -0,0 +1,314 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipseccertificate
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	certv1beta1 "k8s.io/api/certificates/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/vmware-tanzu/antrea/pkg/k8s"
+	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
+)
+
+const (
+	// defaultCertificateDir is the directory in which the certificate of the Node is installed. It is in the run
+	// directory of Open vSwitch, which is shared with the containers running ovs-vswitchd and ovs-monitor-ipsec.
+	defaultCertificateDir = "/var/run/openvswitch/ipsec"
+	// BoundTokenPath is the ServiceAccount token bound to the Agent Pod, with which the CertificateSigningRequests
+	// are created, so that the Antrea Controller can check that they are created by the Agent of the Node.
+	BoundTokenPath = "/var/run/secrets/antrea.io/ipsec-token/token"
+	// certificateFilePrefix is the prefix of the files installed in the certificate directory.
+	certificateFilePrefix = "antrea-ipsec-"
+	// The keys of the other_config of the Open_vSwitch table from which ovs-monitor-ipsec reads the certificate of
+	// the Node, its private key and the CA certificate which the certificates of the peer Nodes are verified with.
+	ovsOtherConfigCertificate = "certificate"
+	ovsOtherConfigPrivateKey  = "private_key"
+	ovsOtherConfigCACert      = "ca_cert"
+
+	keySize = 2048
+	// How long to wait before retrying after a failure to get or install a certificate.
+	retryInterval = 30 * time.Second
+	// How long to wait for the CertificateSigningRequest to be approved and signed.
+	csrTimeout      = 10 * time.Minute
+	csrPollInterval = 2 * time.Second
+	// The certificate is renewed once this fraction of its validity period has elapsed.
+	renewFraction = 0.8
+)
+
+// Controller requests the certificate with which the IPSec tunnels of the Node are authenticated through a
+// CertificateSigningRequest, installs it for ovs-monitor-ipsec, and renews it before it expires. The Common Name of
+// the certificate is the name of the Node, which is the remote_name of the tunnels to the Node on the peer Nodes.
+// The certificates are signed by the IPSec CA of the Antrea Controller, whose certificate is published in a
+// ConfigMap of the Antrea Namespace, and which is the only CA accepted by ovs-monitor-ipsec.
+type Controller struct {
+	kubeClient      clientset.Interface
+	ovsBridgeClient ovsconfig.OVSBridgeClient
+	nodeName        string
+	namespace       string
+	certificateDir  string
+}
+
+// NewIPSecCertificateController returns a Controller for the certificate of the Node nodeName, whose Agent runs in
+// namespace. kubeClient must authenticate with the ServiceAccount token bound to the Agent Pod.
+func NewIPSecCertificateController(kubeClient clientset.Interface, ovsBridgeClient ovsconfig.OVSBridgeClient, nodeName, namespace string) *Controller {
+	return &Controller{
+		kubeClient:      kubeClient,
+		ovsBridgeClient: ovsBridgeClient,
+		nodeName:        nodeName,
+		namespace:       namespace,
+		certificateDir:  defaultCertificateDir,
+	}
+}
+
+// Run installs the certificate of the Node if it is missing or due for renewal, and renews it when needed until
+// stopCh is closed.
+func (c *Controller) Run(stopCh <-chan struct{}) {
+	klog.Info("Starting IPSec certificate controller")
+	defer klog.Info("Shutting down IPSec certificate controller")
+
+	for {
+		var delay time.Duration
+		renewTime, err := c.syncCertificate()
+		if err != nil {
+			klog.Errorf("Failed to sync the IPSec certificate of Node %s, retrying in %v: %v", c.nodeName, retryInterval, err)
+			delay = retryInterval
+		} else {
+			klog.Infof("IPSec certificate of Node %s will be renewed at %v", c.nodeName, renewTime)
+			delay = time.Until(renewTime)
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// syncCertificate requests and installs a certificate if the installed one is missing, invalid, signed by another CA
+// or due for renewal, and returns the time at which the certificate must be renewed.
+func (c *Controller) syncCertificate() (time.Time, error) {
+	caCertPEM, caCert, err := c.getCACertificate()
+	if err != nil {
+		return time.Time{}, err
+	}
+	cert, err := c.loadInstalledCertificate(caCertPEM)
+	if err != nil {
+		klog.Warningf("Replacing the installed IPSec certificate: %v", err)
+	} else if cert != nil {
+		renewTime := getRenewTime(cert)
+		if time.Now().Before(renewTime) {
+			return renewTime, nil
+		}
+		klog.Infof("Renewing the IPSec certificate of Node %s which expires at %v", c.nodeName, cert.NotAfter)
+	}
+
+	certPEM, keyPEM, err := c.requestCertificate()
+	if err != nil {
+		return time.Time{}, err
+	}
+	cert, err = parseCertificate(certPEM)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse the signed certificate: %v", err)
+	}
+	if err := cert.CheckSignatureFrom(caCert); err != nil {
+		return time.Time{}, fmt.Errorf("the signed certificate is not signed by the IPSec CA: %v", err)
+	}
+	if err := c.installCertificate(certPEM, keyPEM, caCertPEM); err != nil {
+		return time.Time{}, err
+	}
+	klog.Infof("Installed the IPSec certificate of Node %s, valid until %v", c.nodeName, cert.NotAfter)
+	return getRenewTime(cert), nil
+}
+
+// getCACertificate returns the certificate of the IPSec CA published by the Antrea Controller, in PEM format and
+// parsed.
+func (c *Controller) getCACertificate() ([]byte, *x509.Certificate, error) {
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(c.namespace).Get(k8s.IPSecCAName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get ConfigMap %s/%s: %v", c.namespace, k8s.IPSecCAName, err)
+	}
+	caCertPEM := []byte(configMap.Data[k8s.IPSecCACertKey])
+	caCert, err := parseCertificate(caCertPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the CA certificate of ConfigMap %s/%s: %v", c.namespace, k8s.IPSecCAName, err)
+	}
+	return caCertPEM, caCert, nil
+}
+
+// loadInstalledCertificate returns the certificate configured in OVS, or nil if there is none. It returns an error
+// if the certificate cannot be used for the Node, or if the installed CA certificate is not caCertPEM, e.g. because
+// the IPSec CA was replaced.
+func (c *Controller) loadInstalledCertificate(caCertPEM []byte) (*x509.Certificate, error) {
+	otherConfig, ovsErr := c.ovsBridgeClient.GetOVSOtherConfig()
+	if ovsErr != nil {
+		return nil, fmt.Errorf("failed to get the OVS other_config: %v", ovsErr)
+	}
+	certPath, keyPath, caCertPath := otherConfig[ovsOtherConfigCertificate], otherConfig[ovsOtherConfigPrivateKey], otherConfig[ovsOtherConfigCACert]
+	if certPath == "" || keyPath == "" || caCertPath == "" {
+		return nil, nil
+	}
+	certPEM, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate %s: %v", certPath, err)
+	}
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key %s: %v", keyPath, err)
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return nil, fmt.Errorf("invalid certificate %s or private key %s: %v", certPath, keyPath, err)
+	}
+	installedCACertPEM, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate %s: %v", caCertPath, err)
+	}
+	if !bytes.Equal(installedCACertPEM, caCertPEM) {
+		return nil, fmt.Errorf("CA certificate %s is not the certificate of the IPSec CA", caCertPath)
+	}
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %v", certPath, err)
+	}
+	if cert.Subject.CommonName != c.nodeName {
+		return nil, fmt.Errorf("certificate %s is for %s instead of Node %s", certPath, cert.Subject.CommonName, c.nodeName)
+	}
+	return cert, nil
+}
+
+// requestCertificate generates a private key, requests a certificate for it through a CertificateSigningRequest
+// and waits for it to be signed. It returns the certificate and the private key in PEM format.
+func (c *Controller) requestCertificate() ([]byte, []byte, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate private key: %v", err)
+	}
+	template := &x509.CertificateRequest{Subject: pkix.Name{CommonName: c.nodeName}}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, template, privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate request: %v", err)
+	}
+	csr := &certv1beta1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: k8s.IPSecCSRNamePrefix + c.nodeName + "-"},
+		Spec: certv1beta1.CertificateSigningRequestSpec{
+			Request: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}),
+			Usages:  k8s.IPSecCertificateUsages,
+		},
+	}
+	// The CertificateSigningRequests are not deleted, kube-controller-manager garbage collects them.
+	csr, err = c.kubeClient.CertificatesV1beta1().CertificateSigningRequests().Create(csr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CertificateSigningRequest: %v", err)
+	}
+	klog.Infof("Created CertificateSigningRequest %s for the IPSec certificate of Node %s", csr.Name, c.nodeName)
+
+	var certPEM []byte
+	csrName := csr.Name
+	if err := wait.PollImmediate(csrPollInterval, csrTimeout, func() (bool, error) {
+		csr, err := c.kubeClient.CertificatesV1beta1().CertificateSigningRequests().Get(csrName, metav1.GetOptions{})
+		if err != nil {
+			klog.Warningf("Failed to get CertificateSigningRequest %s: %v", csrName, err)
+			return false, nil
+		}
+		for _, condition := range csr.Status.Conditions {
+			if condition.Type == certv1beta1.CertificateDenied {
+				return false, fmt.Errorf("CertificateSigningRequest %s was denied: %s", csrName, condition.Message)
+			}
+		}
+		if len(csr.Status.Certificate) == 0 {
+			return false, nil
+		}
+		certPEM = csr.Status.Certificate
+		return true, nil
+	}); err != nil {
+		return nil, nil, fmt.Errorf("failed to get the certificate of CertificateSigningRequest %s: %v", csrName, err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	return certPEM, keyPEM, nil
+}
+
+// installCertificate writes the certificate, its private key and the CA certificate to the certificate directory,
+// configures OVS to use them and removes the files of the former certificate. The files have new names each time,
+// so that ovs-monitor-ipsec notices the change and reloads the IKE daemon.
+func (c *Controller) installCertificate(certPEM, keyPEM, caCertPEM []byte) error {
+	if err := os.MkdirAll(c.certificateDir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", c.certificateDir, err)
+	}
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	certPath := filepath.Join(c.certificateDir, certificateFilePrefix+"cert-"+suffix+".crt")
+	keyPath := filepath.Join(c.certificateDir, certificateFilePrefix+"key-"+suffix+".key")
+	caCertPath := filepath.Join(c.certificateDir, certificateFilePrefix+"ca-"+suffix+".crt")
+	files := map[string][]byte{certPath: certPEM, keyPath: keyPEM, caCertPath: caCertPEM}
+	for path, data := range files {
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+	}
+	if err := c.ovsBridgeClient.UpdateOVSOtherConfig(map[string]interface{}{
+		ovsOtherConfigCertificate: certPath,
+		ovsOtherConfigPrivateKey:  keyPath,
+		ovsOtherConfigCACert:      caCertPath,
+	}); err != nil {
+		return fmt.Errorf("failed to configure the IPSec certificate in OVS: %v", err)
+	}
+
+	entries, err := ioutil.ReadDir(c.certificateDir)
+	if err != nil {
+		klog.Warningf("Failed to list directory %s: %v", c.certificateDir, err)
+		return nil
+	}
+	for _, entry := range entries {
+		path := filepath.Join(c.certificateDir, entry.Name())
+		if _, ok := files[path]; ok || !strings.HasPrefix(entry.Name(), certificateFilePrefix) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			klog.Warningf("Failed to remove stale IPSec certificate file %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// parseCertificate parses the first certificate of PEM data.
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// getRenewTime returns the time at which a certificate must be renewed.
+func getRenewTime(cert *x509.Certificate) time.Time {
+	validity := cert.NotAfter.Sub(cert.NotBefore)
+	return cert.NotBefore.Add(time.Duration(float64(validity) * renewFraction))
+}
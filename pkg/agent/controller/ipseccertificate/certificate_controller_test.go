@@ -0,0 +1,224 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipseccertificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	certv1beta1 "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/vmware-tanzu/antrea/pkg/k8s"
+	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
+	ovsconfigtest "github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig/testing"
+)
+
+const (
+	testNodeName  = "node1"
+	testNamespace = "kube-system"
+)
+
+type testCA struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	return &testCA{cert: cert, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), key: key}
+}
+
+// sign signs a certificate request in PEM format, for a validity period of one hour.
+func (ca *testCA) sign(t *testing.T, requestPEM []byte) []byte {
+	block, _ := pem.Decode(requestPEM)
+	require.NotNil(t, block)
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      request.Subject,
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, request.PublicKey, ca.key)
+	require.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// newTestController returns a Controller whose CertificateSigningRequests are approved and signed by ca when they
+// are created, or denied if deny is true, and the directory of its files. The certificate of ca is the one of the
+// IPSec CA.
+func newTestController(t *testing.T, ovsBridgeClient ovsconfig.OVSBridgeClient, ca *testCA, deny bool) (*Controller, *fake.Clientset, string) {
+	tempDir, err := ioutil.TempDir("", "ipseccertificate")
+	require.Nil(t, err)
+
+	kubeClient := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: k8s.IPSecCAName, Namespace: testNamespace},
+		Data:       map[string]string{k8s.IPSecCACertKey: string(ca.certPEM)},
+	})
+	csrCount := 0
+	kubeClient.PrependReactor("create", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		csr := action.(k8stesting.CreateAction).GetObject().(*certv1beta1.CertificateSigningRequest)
+		// The fake clientset does not generate names.
+		csrCount++
+		csr.Name = csr.GenerateName + strconv.Itoa(csrCount)
+		if deny {
+			csr.Status.Conditions = append(csr.Status.Conditions, certv1beta1.CertificateSigningRequestCondition{Type: certv1beta1.CertificateDenied})
+		} else {
+			csr.Status.Certificate = ca.sign(t, csr.Spec.Request)
+		}
+		return false, nil, nil
+	})
+
+	c := NewIPSecCertificateController(kubeClient, ovsBridgeClient, testNodeName, testNamespace)
+	c.certificateDir = filepath.Join(tempDir, "ipsec")
+	return c, kubeClient, tempDir
+}
+
+func TestSyncCertificate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ovsBridgeClient := ovsconfigtest.NewMockOVSBridgeClient(ctrl)
+	ca := newTestCA(t)
+	c, kubeClient, tempDir := newTestController(t, ovsBridgeClient, ca, false)
+	defer os.RemoveAll(tempDir)
+
+	otherConfig := map[string]string{}
+	ovsBridgeClient.EXPECT().GetOVSOtherConfig().DoAndReturn(func() (map[string]string, ovsconfig.Error) {
+		return otherConfig, nil
+	}).AnyTimes()
+	ovsBridgeClient.EXPECT().UpdateOVSOtherConfig(gomock.Any()).DoAndReturn(func(configs map[string]interface{}) ovsconfig.Error {
+		otherConfig = map[string]string{}
+		for k, v := range configs {
+			otherConfig[k] = v.(string)
+		}
+		return nil
+	}).Times(3)
+
+	// A certificate is requested and installed when there is none.
+	renewTime, err := c.syncCertificate()
+	require.Nil(t, err)
+	csrs, err := kubeClient.CertificatesV1beta1().CertificateSigningRequests().List(metav1.ListOptions{})
+	require.Nil(t, err)
+	require.Len(t, csrs.Items, 1)
+	assert.Equal(t, "antrea-ipsec-node1-1", csrs.Items[0].Name)
+	assert.ElementsMatch(t, []certv1beta1.KeyUsage{certv1beta1.UsageDigitalSignature, certv1beta1.UsageKeyEncipherment, certv1beta1.UsageServerAuth, certv1beta1.UsageClientAuth}, csrs.Items[0].Spec.Usages)
+	cert, err := c.loadInstalledCertificate(ca.certPEM)
+	require.Nil(t, err)
+	require.NotNil(t, cert)
+	assert.Equal(t, testNodeName, cert.Subject.CommonName)
+	assert.Equal(t, getRenewTime(cert), renewTime)
+	caCertPEM, err := ioutil.ReadFile(otherConfig[ovsOtherConfigCACert])
+	require.Nil(t, err)
+	assert.Equal(t, ca.certPEM, caCertPEM)
+	info, err := os.Stat(otherConfig[ovsOtherConfigPrivateKey])
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The installed certificate is reused until its renew time.
+	_, err = c.syncCertificate()
+	require.Nil(t, err)
+	csrs, _ = kubeClient.CertificatesV1beta1().CertificateSigningRequests().List(metav1.ListOptions{})
+	assert.Len(t, csrs.Items, 1)
+
+	// A certificate of another Node is replaced, and the files of the former certificate are removed.
+	c.nodeName = "node2"
+	formerCertPath := otherConfig[ovsOtherConfigCertificate]
+	_, err = c.syncCertificate()
+	require.Nil(t, err)
+	csrs, _ = kubeClient.CertificatesV1beta1().CertificateSigningRequests().List(metav1.ListOptions{})
+	assert.Len(t, csrs.Items, 2)
+	cert, err = c.loadInstalledCertificate(ca.certPEM)
+	require.Nil(t, err)
+	assert.Equal(t, "node2", cert.Subject.CommonName)
+	_, err = os.Stat(formerCertPath)
+	assert.True(t, os.IsNotExist(err))
+	entries, err := ioutil.ReadDir(c.certificateDir)
+	require.Nil(t, err)
+	assert.Len(t, entries, 3)
+
+	// The certificate is replaced when the IPSec CA is replaced.
+	newCA := newTestCA(t)
+	_, err = kubeClient.CoreV1().ConfigMaps(testNamespace).Update(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: k8s.IPSecCAName, Namespace: testNamespace},
+		Data:       map[string]string{k8s.IPSecCACertKey: string(newCA.certPEM)},
+	})
+	require.Nil(t, err)
+	_, err = c.syncCertificate()
+	// The certificate signed by another CA than the IPSec CA is not installed.
+	assert.NotNil(t, err)
+	csrs, _ = kubeClient.CertificatesV1beta1().CertificateSigningRequests().List(metav1.ListOptions{})
+	assert.Len(t, csrs.Items, 3)
+	*ca = *newCA
+	_, err = c.syncCertificate()
+	require.Nil(t, err)
+	cert, err = c.loadInstalledCertificate(newCA.certPEM)
+	require.Nil(t, err)
+	assert.Nil(t, cert.CheckSignatureFrom(newCA.cert))
+}
+
+func TestSyncCertificateDenied(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ovsBridgeClient := ovsconfigtest.NewMockOVSBridgeClient(ctrl)
+	c, _, tempDir := newTestController(t, ovsBridgeClient, newTestCA(t), true)
+	defer os.RemoveAll(tempDir)
+
+	ovsBridgeClient.EXPECT().GetOVSOtherConfig().Return(map[string]string{}, nil)
+	_, err := c.syncCertificate()
+	assert.NotNil(t, err)
+}
+
+func TestGetRenewTime(t *testing.T) {
+	notBefore := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{NotBefore: notBefore, NotAfter: notBefore.Add(10 * time.Hour)}
+	assert.Equal(t, notBefore.Add(8*time.Hour), getRenewTime(cert))
+}
@@ -15,6 +15,7 @@
 package networkpolicy
 
 import (
+	"sync"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -63,23 +64,39 @@ type Controller struct {
 	// reconciler provides interfaces to reconcile the desired state of
 	// NetworkPolicy rules with the actual state of Openflow entries.
 	reconciler Reconciler
+	// watchersSynced is done once each watcher has received the Bookmark
+	// event which follows the initial objects.
+	watchersSynced sync.WaitGroup
+	// appliedToGroupsSynced, addressGroupsSynced and networkPoliciesSynced
+	// ensure that each watcher marks watchersSynced done only once, even
+	// when it reconnects.
+	appliedToGroupsSynced sync.Once
+	addressGroupsSynced   sync.Once
+	networkPoliciesSynced sync.Once
+	// initialSynced is closed once the rules of the initial objects have
+	// been reconciled.
+	initialSynced chan struct{}
 }
 
 // NewNetworkPolicyController returns a new *Controller.
-func NewNetworkPolicyController(antreaClient versioned.Interface, ofClient openflow.Client, ifaceStore interfacestore.InterfaceStore, nodeName string, gatewayIP string) *Controller {
+func NewNetworkPolicyController(antreaClient versioned.Interface, ofClient openflow.Client, ifaceStore interfacestore.InterfaceStore, nodeName string, gatewayIPs []string) *Controller {
 	c := &Controller{
-		antreaClient: antreaClient,
-		nodeName:     nodeName,
-		queue:        workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "networkpolicyrule"),
-		reconciler:   newReconciler(ofClient, ifaceStore),
+		antreaClient:  antreaClient,
+		nodeName:      nodeName,
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "networkpolicyrule"),
+		reconciler:    newReconciler(ofClient, ifaceStore),
+		initialSynced: make(chan struct{}),
 	}
-	// Set Node gateway IP as the defaultFromAddresses so that Node to Pod traffic will always be allowed.
-	c.ruleCache = newRuleCache(c.enqueueRule, []string{gatewayIP})
+	// One for each of AppliedToGroups, AddressGroups and NetworkPolicies.
+	c.watchersSynced.Add(3)
+	// Set Node gateway IPs as the defaultFromAddresses so that Node to Pod traffic will always be allowed.
+	c.ruleCache = newRuleCache(c.enqueueRule, gatewayIPs)
 	return c
 }
 
 // Run begins watching and processing Antrea AddressGroups, AppliedToGroups
-// and NetworkPolicies, and spawns workers that reconciles NetworkPolicy rules.
+// and NetworkPolicies, reconciles the rules of the initial objects, and then
+// spawns workers that reconciles NetworkPolicy rules.
 // Run will not return until stopCh is closed.
 func (c *Controller) Run(stopCh <-chan struct{}) error {
 	// Use NonSlidingUntil so that normal reconnection (disconnected after
@@ -89,6 +106,26 @@ func (c *Controller) Run(stopCh <-chan struct{}) error {
 	go wait.NonSlidingUntil(c.watchAddressGroups, 5*time.Second, stopCh)
 	go wait.NonSlidingUntil(c.watchNetworkPolicies, 5*time.Second, stopCh)
 
+	klog.Info("Waiting for the initial AppliedToGroups, AddressGroups and NetworkPolicies")
+	watchersSynced := make(chan struct{})
+	go func() {
+		c.watchersSynced.Wait()
+		close(watchersSynced)
+	}()
+	select {
+	case <-watchersSynced:
+	case <-stopCh:
+		return nil
+	}
+	// Reconcile the rules of the initial objects before starting the workers,
+	// so that their flows are installed when initialSynced is closed. The
+	// rules which fail are retried by the workers.
+	for i, n := 0, c.queue.Len(); i < n; i++ {
+		c.processNextWorkItem()
+	}
+	klog.Info("Reconciled the initial NetworkPolicy rules")
+	close(c.initialSynced)
+
 	for i := 0; i < defaultWorkers; i++ {
 		go wait.Until(c.worker, time.Second, stopCh)
 	}
@@ -97,6 +134,13 @@ func (c *Controller) Run(stopCh <-chan struct{}) error {
 	return nil
 }
 
+// InitialSynced returns a channel which is closed once the rules of the
+// AppliedToGroups, AddressGroups and NetworkPolicies received when the
+// Controller started have been reconciled.
+func (c *Controller) InitialSynced() <-chan struct{} {
+	return c.initialSynced
+}
+
 func (c *Controller) enqueueRule(ruleID string) {
 	c.queue.Add(ruleID)
 }
@@ -212,6 +256,9 @@ func (c *Controller) watchAppliedToGroups() {
 				}
 				klog.V(2).Infof("Removed AppliedToGroup (%#v)", event.Object)
 				c.ruleCache.DeleteAppliedToGroup(group)
+			case watch.Bookmark:
+				klog.Infof("Received %d initial AppliedToGroups", eventCount)
+				c.appliedToGroupsSynced.Do(c.watchersSynced.Done)
 			}
 			eventCount++
 		}
@@ -265,6 +312,9 @@ func (c *Controller) watchAddressGroups() {
 				}
 				klog.V(2).Infof("Removed AddressGroup (%#v)", event.Object)
 				c.ruleCache.DeleteAddressGroup(group)
+			case watch.Bookmark:
+				klog.Infof("Received %d initial AddressGroups", eventCount)
+				c.addressGroupsSynced.Do(c.watchersSynced.Done)
 			}
 			eventCount++
 		}
@@ -318,6 +368,9 @@ func (c *Controller) watchNetworkPolicies() {
 				}
 				klog.V(2).Infof("Removed NetworkPolicy (%#v)", event.Object)
 				c.ruleCache.DeleteNetworkPolicy(policy)
+			case watch.Bookmark:
+				klog.Infof("Received %d initial NetworkPolicies", eventCount)
+				c.networkPoliciesSynced.Do(c.watchersSynced.Done)
 			}
 			eventCount++
 		}
@@ -35,7 +35,7 @@ const gatewayIP = "10.10.10.1"
 func newTestController() (*Controller, *fake.Clientset, *mockReconciler) {
 	clientset := &fake.Clientset{}
 
-	controller := NewNetworkPolicyController(clientset, nil, nil, "node1", gatewayIP)
+	controller := NewNetworkPolicyController(clientset, nil, nil, "node1", []string{gatewayIP})
 	reconciler := newMockReconciler()
 	controller.reconciler = reconciler
 	return controller, clientset, reconciler
@@ -84,6 +84,13 @@ func (r *mockReconciler) getLastRealized(ruleID string) (*CompletedRule, bool) {
 
 var _ Reconciler = &mockReconciler{}
 
+// sendBookmarks sends to the watchers the Bookmark event which follows the initial objects.
+func sendBookmarks(watchers ...*watch.FakeWatcher) {
+	for _, w := range watchers {
+		w.Action(watch.Bookmark, nil)
+	}
+}
+
 func getAddressGroup(name string, addresses []v1beta1.IPAddress) *v1beta1.AddressGroup {
 	return &v1beta1.AddressGroup{
 		ObjectMeta:  v1.ObjectMeta{Name: name},
@@ -133,6 +140,8 @@ func TestAddSingleGroupRule(t *testing.T) {
 	stopCh := make(chan struct{})
 	defer close(stopCh)
 	go controller.Run(stopCh)
+	// Start with no initial objects.
+	sendBookmarks(addressGroupWatcher, appliedToGroupWatcher, networkPolicyWatcher)
 
 	// policy1 comes first, no rule will be synced due to missing addressGroup1 and appliedToGroup1.
 	networkPolicyWatcher.Add(getNetworkPolicy("policy1", []string{"addressGroup1"}, []string{}, []string{"appliedToGroup1"}, services))
@@ -196,6 +205,8 @@ func TestAddMultipleGroupsRule(t *testing.T) {
 	stopCh := make(chan struct{})
 	defer close(stopCh)
 	go controller.Run(stopCh)
+	// Start with no initial objects.
+	sendBookmarks(addressGroupWatcher, appliedToGroupWatcher, networkPolicyWatcher)
 
 	// addressGroup1 comes, no rule will be synced.
 	addressGroupWatcher.Add(getAddressGroup("addressGroup1", []v1beta1.IPAddress{ipStrToIPAddress("1.1.1.1"), ipStrToIPAddress("2.2.2.2")}))
@@ -257,6 +268,8 @@ func TestDeleteRule(t *testing.T) {
 	stopCh := make(chan struct{})
 	defer close(stopCh)
 	go controller.Run(stopCh)
+	// Start with no initial objects.
+	sendBookmarks(addressGroupWatcher, appliedToGroupWatcher, networkPolicyWatcher)
 
 	addressGroupWatcher.Add(getAddressGroup("addressGroup1", []v1beta1.IPAddress{ipStrToIPAddress("1.1.1.1"), ipStrToIPAddress("2.2.2.2")}))
 	appliedToGroupWatcher.Add(getAppliedToGroup("appliedToGroup1", []v1beta1.PodReference{{"pod1", "ns1"}}))
@@ -282,3 +295,49 @@ func TestDeleteRule(t *testing.T) {
 		t.Fatal("Expected one update, got none")
 	}
 }
+
+func TestInitialSync(t *testing.T) {
+	controller, clientset, reconciler := newTestController()
+	addressGroupWatcher := watch.NewFake()
+	appliedToGroupWatcher := watch.NewFake()
+	networkPolicyWatcher := watch.NewFake()
+	clientset.AddWatchReactor("addressgroups", k8stesting.DefaultWatchReactor(addressGroupWatcher, nil))
+	clientset.AddWatchReactor("appliedtogroups", k8stesting.DefaultWatchReactor(appliedToGroupWatcher, nil))
+	clientset.AddWatchReactor("networkpolicies", k8stesting.DefaultWatchReactor(networkPolicyWatcher, nil))
+
+	protocolTCP := v1beta1.ProtocolTCP
+	port := int32(80)
+	services := []v1beta1.Service{{Protocol: &protocolTCP, Port: &port}}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go controller.Run(stopCh)
+
+	networkPolicyWatcher.Add(getNetworkPolicy("policy1", []string{"addressGroup1"}, []string{}, []string{"appliedToGroup1"}, services))
+	addressGroupWatcher.Add(getAddressGroup("addressGroup1", []v1beta1.IPAddress{ipStrToIPAddress("1.1.1.1")}))
+	appliedToGroupWatcher.Add(getAppliedToGroup("appliedToGroup1", []v1beta1.PodReference{{"pod1", "ns1"}}))
+	sendBookmarks(addressGroupWatcher, appliedToGroupWatcher)
+	// The initial NetworkPolicies have not all been received yet.
+	select {
+	case <-controller.InitialSynced():
+		t.Fatal("Expected initial sync to wait for the NetworkPolicies")
+	case ruleID := <-reconciler.updated:
+		t.Fatalf("Expected no update before the initial sync, got %v", ruleID)
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	sendBookmarks(networkPolicyWatcher)
+	select {
+	case <-controller.InitialSynced():
+	case <-time.After(time.Second):
+		t.Fatal("Expected initial sync to complete")
+	}
+	// The rule of the initial objects is reconciled before the initial sync completes.
+	select {
+	case ruleID := <-reconciler.updated:
+		if _, exists := reconciler.getLastRealized(ruleID); !exists {
+			t.Errorf("Expected rule %s, got none", ruleID)
+		}
+	default:
+		t.Fatal("Expected one update before the initial sync completed, got none")
+	}
+}
@@ -301,15 +301,22 @@ func (r *reconciler) podsToIPAddresses(pods podSet) []types.Address {
 			klog.Warningf("Can't find interface for Pod %s/%s, skipping", pod.Namespace, pod.Name)
 			continue
 		}
-		klog.V(2).Infof("Got IP %v for Pod %s/%s", iface.IP, pod.Namespace, pod.Name)
-		addresses = append(addresses, openflow.NewIPAddress(iface.IP))
+		klog.V(2).Infof("Got IPs %v for Pod %s/%s", iface.IPs, pod.Namespace, pod.Name)
+		for _, ip := range iface.IPs {
+			addresses = append(addresses, openflow.NewIPAddress(ip))
+		}
 	}
 	return addresses
 }
 
 func antreaIPNetToIPNet(in v1beta1.IPNet) net.IPNet {
+	ip := net.IP(in.IP)
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		bits = 8 * net.IPv4len
+	}
 	return net.IPNet{
-		IP:   net.IP(in.IP),
-		Mask: net.CIDRMask(int(in.PrefixLength), 32),
+		IP:   ip,
+		Mask: net.CIDRMask(int(in.PrefixLength), bits),
 	}
 }
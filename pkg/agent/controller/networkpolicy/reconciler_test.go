@@ -81,7 +81,7 @@ func TestReconcilerReconcile(t *testing.T) {
 	appliedToGroup2 := newPodSet(v1beta1.PodReference{"pod2", "ns1"})
 	ifaceStore := interfacestore.NewInterfaceStore()
 	ifaceStore.AddInterface(util.GenerateContainerInterfaceName("pod1", "ns1"),
-		&interfacestore.InterfaceConfig{IP: net.ParseIP("2.2.2.2"), OVSPortConfig: &interfacestore.OVSPortConfig{OFPort: 1}})
+		&interfacestore.InterfaceConfig{IPs: []net.IP{net.ParseIP("2.2.2.2")}, OVSPortConfig: &interfacestore.OVSPortConfig{OFPort: 1}})
 	protocolTCP := v1beta1.ProtocolTCP
 	port80 := int32(80)
 	service1 := v1beta1.Service{Protocol: &protocolTCP, Port: &port80}
@@ -163,3 +163,22 @@ func TestReconcilerReconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestAntreaIPNetToIPNet(t *testing.T) {
+	tests := []struct {
+		name string
+		in   v1beta1.IPNet
+		want string
+	}{
+		{"ipv4", v1beta1.IPNet{IP: v1beta1.IPAddress(net.ParseIP("10.10.0.0").To4()), PrefixLength: 16}, "10.10.0.0/16"},
+		{"ipv6", v1beta1.IPNet{IP: v1beta1.IPAddress(net.ParseIP("fd00:10::")), PrefixLength: 64}, "fd00:10::/64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipNet := antreaIPNetToIPNet(tt.in)
+			if got := ipNet.String(); got != tt.want {
+				t.Errorf("antreaIPNetToIPNet() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
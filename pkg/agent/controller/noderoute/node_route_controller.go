@@ -17,6 +17,7 @@ package noderoute
 import (
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -24,17 +25,26 @@ import (
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	coreinformers "k8s.io/client-go/informers/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 
+	"github.com/vmware-tanzu/antrea/pkg/agent/config"
+	"github.com/vmware-tanzu/antrea/pkg/agent/interfacestore"
+	"github.com/vmware-tanzu/antrea/pkg/agent/iptables"
 	"github.com/vmware-tanzu/antrea/pkg/agent/openflow"
 	"github.com/vmware-tanzu/antrea/pkg/agent/types"
+	"github.com/vmware-tanzu/antrea/pkg/agent/util"
+	"github.com/vmware-tanzu/antrea/pkg/agent/wireguard"
+	"github.com/vmware-tanzu/antrea/pkg/apis/clusterinformation/crd/antrea/v1beta1"
+	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
 )
 
 const (
@@ -45,8 +55,33 @@ const (
 	maxRetryDelay = 300 * time.Second
 	// Default number of workers processing a node change
 	defaultWorkers = 4
+	// ovsExternalIDNodeName is the key of the external ID of the IPSec tunnel port of a peer Node which holds the name
+	// of the Node.
+	ovsExternalIDNodeName = "node-name"
+	// antreaRouteProtocol is the protocol of the routes to the peer Nodes, which identifies them when they are
+	// listed after an Agent restart. It is not assigned in /etc/iproute2/rt_protos.
+	antreaRouteProtocol = 99
 )
 
+// The netlink functions used to manage the routes and the neighbor entries to the Nodes are variables, so that they
+// can be faked in tests.
+var (
+	routeListFiltered = netlink.RouteListFiltered
+	routeAdd          = netlink.RouteAdd
+	routeReplace      = netlink.RouteReplace
+	routeDel          = netlink.RouteDel
+	neighSet          = netlink.NeighSet
+	neighDel          = netlink.NeighDel
+)
+
+// iptablesRuleClient is the part of iptables.Client used to exempt the traffic to the Pod CIDRs of the Nodes from
+// masquerading.
+type iptablesRuleClient interface {
+	IsIPv6() bool
+	AddPeerPodCIDR(nodeName string, peerPodCIDR *net.IPNet) error
+	DeletePeerPodCIDR(peerPodCIDR *net.IPNet) error
+}
+
 // Controller is responsible for setting up necessary IP routes and Openflow entries for inter-node traffic.
 type Controller struct {
 	kubeClient       clientset.Interface
@@ -55,25 +90,94 @@ type Controller struct {
 	nodeListerSynced cache.InformerSynced
 	queue            workqueue.RateLimitingInterface
 	ofClient         openflow.Client
-	nodeConfig       *types.NodeConfig
-	gatewayLink      netlink.Link
+	ovsBridgeClient  ovsconfig.OVSBridgeClient
+	// interfaceStore tracks the IPSec tunnel ports of the peer Nodes.
+	interfaceStore interfacestore.InterfaceStore
+	// iptablesClients has one client per IP family of the Pod network. They exempt the traffic to the Pod CIDRs of
+	// the Nodes which are reached without encapsulation from masquerading.
+	iptablesClients []iptablesRuleClient
+	// wireGuardClient has a peer for each Node the Pod traffic is routed to through the WireGuard device. It is nil
+	// unless the Pod traffic is encrypted with WireGuard.
+	wireGuardClient wireguard.Interface
+	networkConfig   *config.NetworkConfig
+	nodeConfig      *types.NodeConfig
+	gatewayLink     netlink.Link
+	// recorder records an event on a peer Node when its routes and flows are updated.
+	recorder record.EventRecorder
 	// installedNodes records routes and flows installation states of Nodes.
-	// The key is the host name of the Node, the value is the route to the Node.
+	// The key is the host name of the Node, the value is the *nodeRouteInfo of the routes to the Pod CIDRs of the
+	// Node.
 	// If the flows of the Node are installed, the installedNodes must contains a key which is the host name.
-	// If the route of the Node are installed, the flows of the Node must be installed first and the value of host name
+	// If the routes of the Node are installed, the flows of the Node must be installed first and the value of host name
 	// key must not be nil.
-	// TODO: handle agent restart cases.
+	// The value of a Node is replaced when the parameters of the Node change.
+	// When the Agent restarts, the routes installed by the previous run are restored by initialSync, without the
+	// flows of the Nodes.
 	installedNodes *sync.Map
+	// initialSynced is closed once the routes and flows of the Nodes which existed when the controller started have
+	// been installed.
+	initialSynced chan struct{}
+}
+
+// nodeRouteInfo is the state of the routes to the Pod CIDRs of a peer Node.
+type nodeRouteInfo struct {
+	nodeIP net.IP
+	// encap is true if the Pod traffic to the Node is encapsulated in a tunnel, false if it is routed by the host.
+	encap bool
+	// wireGuardPublicKey is the public key of the WireGuard peer of the Node, when the Pod traffic to the Node is
+	// routed by the host through the WireGuard device.
+	wireGuardPublicKey string
+	// tunOFPort is the ofport of the IPSec tunnel port to the Node used by the flows, when the Pod traffic to the Node
+	// is encrypted with IPSec.
+	tunOFPort int32
+	routes    []*netlink.Route
+	// restored is true if the routes were installed by a previous run of the Agent, the flows are not installed then.
+	restored bool
+}
+
+// equal returns true if the routes and flows of info are the same as the ones of other.
+func (info *nodeRouteInfo) equal(other *nodeRouteInfo) bool {
+	if !info.nodeIP.Equal(other.nodeIP) || info.encap != other.encap || info.wireGuardPublicKey != other.wireGuardPublicKey ||
+		info.tunOFPort != other.tunOFPort || len(info.routes) != len(other.routes) {
+		return false
+	}
+	for _, route := range info.routes {
+		otherRoute := other.findRoute(route.Dst)
+		if otherRoute == nil || !otherRoute.Gw.Equal(route.Gw) {
+			return false
+		}
+	}
+	return true
+}
+
+// findRoute returns the route to the Pod CIDR dst, or nil if there is none.
+func (info *nodeRouteInfo) findRoute(dst *net.IPNet) *netlink.Route {
+	for _, route := range info.routes {
+		if route.Dst.String() == dst.String() {
+			return route
+		}
+	}
+	return nil
 }
 
 func NewNodeRouteController(
 	kubeClient clientset.Interface,
 	informerFactory informers.SharedInformerFactory,
 	client openflow.Client,
-	config *types.NodeConfig,
+	ovsBridgeClient ovsconfig.OVSBridgeClient,
+	interfaceStore interfacestore.InterfaceStore,
+	iptablesClients []*iptables.Client,
+	wireGuardClient wireguard.Interface,
+	networkConfig *config.NetworkConfig,
+	nodeConfig *types.NodeConfig,
+	recorder record.EventRecorder,
 ) *Controller {
 	nodeInformer := informerFactory.Core().V1().Nodes()
-	link, _ := netlink.LinkByName(config.GatewayConfig.Name)
+	link, _ := netlink.LinkByName(nodeConfig.GatewayConfig.Name)
+	ruleClients := make([]iptablesRuleClient, 0, len(iptablesClients))
+	for _, client := range iptablesClients {
+		ruleClients = append(ruleClients, client)
+	}
 
 	controller := &Controller{
 		kubeClient:       kubeClient,
@@ -82,9 +186,16 @@ func NewNodeRouteController(
 		nodeListerSynced: nodeInformer.Informer().HasSynced,
 		queue:            workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "noderoute"),
 		ofClient:         client,
-		nodeConfig:       config,
+		ovsBridgeClient:  ovsBridgeClient,
+		interfaceStore:   interfaceStore,
+		iptablesClients:  ruleClients,
+		wireGuardClient:  wireGuardClient,
+		networkConfig:    networkConfig,
+		nodeConfig:       nodeConfig,
 		gatewayLink:      link,
+		recorder:         recorder,
 		installedNodes:   &sync.Map{},
+		initialSynced:    make(chan struct{}),
 	}
 	nodeInformer.Informer().AddEventHandlerWithResyncPeriod(
 		cache.ResourceEventHandlerFuncs{
@@ -139,12 +250,133 @@ func (c *Controller) Run(stopCh <-chan struct{}) {
 	}
 	klog.Info("Caches are synced for Node Route controller")
 
+	c.initialSync()
+
 	for i := 0; i < defaultWorkers; i++ {
 		go wait.Until(c.worker, time.Second, stopCh)
 	}
 	<-stopCh
 }
 
+// initialSync restores the routes installed by a previous run of the Agent, then installs the routes and flows of all
+// the Nodes in the lister cache, and closes initialSynced when it is done. It runs before the workers are started, so
+// that syncNodeRoute is never called concurrently for the same Node. The Nodes which fail to be synced are still
+// processed by the workers, which requeue them on error.
+func (c *Controller) initialSync() {
+	defer close(c.initialSynced)
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list Nodes: %v", err)
+		return
+	}
+	if err := c.restoreRoutes(nodes); err != nil {
+		klog.Errorf("Failed to restore the routes to the Nodes: %v", err)
+	}
+	c.deleteStaleTunnelPorts(nodes)
+	if c.wireGuardClient != nil {
+		c.deleteStaleWireGuardPeers(nodes)
+	}
+	for _, node := range nodes {
+		if node.Name == c.nodeConfig.Name {
+			continue
+		}
+		if err := c.syncNodeRoute(node.Name); err != nil {
+			klog.Errorf("Error syncing Node %s: %v", node.Name, err)
+		}
+	}
+	klog.Infof("Initial sync of %d Nodes completed", len(nodes))
+}
+
+// restoreRoutes rebuilds installedNodes from the routes installed by a previous run of the Agent, which are tagged with
+// antreaRouteProtocol, and deletes the routes to the Pod CIDRs which no longer belong to any Node, e.g. because the
+// Node was deleted while the Agent was down. It must be called once the informer cache has synced, with the Nodes of
+// the cache.
+func (c *Controller) restoreRoutes(nodes []*v1.Node) error {
+	routes, err := routeListFiltered(netlink.FAMILY_ALL, &netlink.Route{Protocol: antreaRouteProtocol}, netlink.RT_FILTER_PROTOCOL)
+	if err != nil {
+		return fmt.Errorf("failed to list the routes to the Nodes: %v", err)
+	}
+
+	podCIDRNodes := make(map[string]*v1.Node)
+	for _, node := range nodes {
+		if node.Name == c.nodeConfig.Name {
+			continue
+		}
+		for _, podCIDR := range getPodCIDRs(node) {
+			if _, peerPodCIDR, err := net.ParseCIDR(podCIDR); err == nil {
+				podCIDRNodes[peerPodCIDR.String()] = node
+			}
+		}
+	}
+
+	restoredNodes := make(map[string]*nodeRouteInfo)
+	for i := range routes {
+		route := &routes[i]
+		if route.Dst == nil {
+			continue
+		}
+		// The routes to the Nodes reached through a tunnel use the gateway interface, the other ones are routed to
+		// the Node IP, or through the WireGuard device without a gateway.
+		encap := route.LinkIndex == c.gatewayLink.Attrs().Index
+		wireGuard := c.wireGuardClient != nil && route.LinkIndex == c.wireGuardClient.LinkIndex()
+		node, ok := podCIDRNodes[route.Dst.String()]
+		if !ok {
+			klog.Infof("Deleting stale route to %s", route.Dst)
+			if err := routeDel(route); err != nil && err != unix.ESRCH {
+				return fmt.Errorf("failed to delete stale route to %s: %v", route.Dst, err)
+			}
+			if encap && route.Gw.To4() == nil {
+				if err := neighDel(c.peerGatewayNeigh(route.Gw)); err != nil && err != unix.ENOENT {
+					return fmt.Errorf("failed to delete stale neighbor %s: %v", route.Gw, err)
+				}
+			} else if iptablesClient := c.iptablesClient(route.Dst); !encap && iptablesClient != nil {
+				// The traffic to the Pod CIDR is not exempted from masquerading anymore. The rule is looked up by
+				// the Pod CIDR, as the name of the Node it was added for is not known.
+				if err := iptablesClient.DeletePeerPodCIDR(route.Dst); err != nil {
+					return fmt.Errorf("failed to delete stale iptables rule of %s: %v", route.Dst, err)
+				}
+			}
+			continue
+		}
+		info, ok := restoredNodes[node.Name]
+		if !ok {
+			info = &nodeRouteInfo{encap: encap, restored: true}
+			restoredNodes[node.Name] = info
+		}
+		if !encap && !wireGuard {
+			info.nodeIP = route.Gw
+		} else if info.nodeIP == nil {
+			// The routes through a tunnel or the WireGuard device don't have the Node IP, which is the transport
+			// address of the Node then.
+			info.nodeIP, _ = getNodeTransportAddr(node)
+		}
+		if wireGuard {
+			info.wireGuardPublicKey = node.Annotations[types.NodeWireGuardPublicKeyAnnotationKey]
+		}
+		info.routes = append(info.routes, route)
+	}
+	for nodeName, info := range restoredNodes {
+		c.installedNodes.Store(nodeName, info)
+	}
+	klog.Infof("Restored the routes to %d Nodes", len(restoredNodes))
+	return nil
+}
+
+// HandleInterfaceOFPortChange enqueues the peer Node of an IPSec tunnel interface whose ofport changed, so that the
+// interface is created again if it was deleted from OVS, and the flows to the Node use its current ofport.
+func (c *Controller) HandleInterfaceOFPortChange(iface *interfacestore.InterfaceConfig) {
+	if iface.Type == interfacestore.TunnelInterface && iface.NodeName != "" {
+		klog.Infof("Ofport of IPSec tunnel interface %s changed to %d, syncing Node %s", iface.IfaceName, iface.OFPort, iface.NodeName)
+		c.queue.Add(iface.NodeName)
+	}
+}
+
+// InitialSynced returns a channel which is closed once the routes and flows of the Nodes which existed when the
+// controller started have been installed.
+func (c *Controller) InitialSynced() <-chan struct{} {
+	return c.initialSynced
+}
+
 func (c *Controller) worker() {
 	for c.processNextWorkItem() {
 	}
@@ -187,12 +419,23 @@ func (c *Controller) processNextWorkItem() bool {
 }
 
 // Manages connectivity to "peer" Node with name nodeName
-// If we have not established connectivity to the Node yet:
-//   * we install the appropriate Linux route:
+// If we have not established connectivity to the Node yet, or if its parameters (Node IP, Pod
+// CIDRs, encapsulation) changed since:
+//   * we install the appropriate Linux route, for each Pod CIDR of the Node in an IP family
+//   enabled on the local Node:
 // Destination     Gateway         Use Iface
 // peerPodCIDR     peerGatewayIP   localGatewayIface (e.g gw0)
+// peerPodCIDR     peerNodeIP      the transport interface (e.g eth0), without encapsulation
+// peerPodCIDR                     the WireGuard device (antrea-wg0), with WireGuard encryption
 //   * we install the appropriate OpenFlow flows to ensure that all the traffic destined to
-//   peerPodCIDR goes through the correct L3 tunnel.
+//   peerPodCIDR goes through the correct L3 tunnel, or through the local gateway without
+//   encapsulation, i.e. in noEncap mode, in hybrid mode when the Node IP is in the subnet
+//   of the local Node IP, or with WireGuard encryption.
+//   * without encapsulation, we exempt the traffic destined to peerPodCIDR from masquerading.
+//   * with WireGuard encryption, we add the peer of the Node to the WireGuard device, with the
+//   public key published in the annotation of the Node.
+// When the parameters changed, the flows are replaced in one bundle and the routes are replaced
+// in place, then the routes of the former Pod CIDRs are deleted.
 // If the Node no longer exists (cannot be retrieved by name from nodeLister) we delete the route
 // and OpenFlow flows associated with it.
 func (c *Controller) syncNodeRoute(nodeName string) error {
@@ -205,88 +448,428 @@ func (c *Controller) syncNodeRoute(nodeName string) error {
 	// same Node, which is required by the InstallNodeFlows / UninstallNodeFlows OF Client
 	// methods.
 
-	if node, err := c.nodeLister.Get(nodeName); err != nil {
-		klog.Infof("Deleting routes and flow entries to Node %s", nodeName)
-		route, flowsAreInstalled := c.installedNodes.Load(nodeName)
-		if route != nil {
-			if err = netlink.RouteDel(route.(*netlink.Route)); err != nil {
-				return fmt.Errorf("failed to delete the route to Node %s: %v", nodeName, err)
-			}
-			c.installedNodes.Store(nodeName, nil)
+	node, err := c.nodeLister.Get(nodeName)
+	if err != nil {
+		return c.deleteNodeRoute(nodeName)
+	}
+	return c.addNodeRoute(node)
+}
+
+// deleteNodeRoute deletes the routes and flows to a Node which no longer exists.
+func (c *Controller) deleteNodeRoute(nodeName string) error {
+	klog.Infof("Deleting routes and flow entries to Node %s", nodeName)
+	info, flowsAreInstalled := c.installedNodes.Load(nodeName)
+	if info != nil {
+		routeInfo := info.(*nodeRouteInfo)
+		if err := c.deleteRoutes(nodeName, routeInfo, nil); err != nil {
+			return err
 		}
-		if flowsAreInstalled {
-			if err = c.ofClient.UninstallNodeFlows(nodeName); err != nil {
-				return fmt.Errorf("failed to uninstall flows to Node %s: %v", nodeName, err)
-			}
+		c.installedNodes.Store(nodeName, nil)
+	}
+	if flowsAreInstalled {
+		if err := c.ofClient.UninstallNodeFlows(nodeName); err != nil {
+			return fmt.Errorf("failed to uninstall flows to Node %s: %v", nodeName, err)
 		}
-		c.installedNodes.Delete(nodeName)
-	} else if route, flowsAreInstalled := c.installedNodes.Load(nodeName); route == nil {
-		klog.Infof("Adding routes and flows to Node %s, podCIDR: %s, addresses: %v",
-			nodeName, node.Spec.PodCIDR, node.Status.Addresses)
-		if node.Spec.PodCIDR == "" {
-			klog.V(1).Infof("PodCIDR is empty for peer node %s", nodeName)
-			return nil
+	}
+	if err := c.deleteIPSecTunnelPort(nodeName); err != nil {
+		return err
+	}
+	if c.wireGuardClient != nil {
+		if err := c.wireGuardClient.DeletePeer(nodeName); err != nil {
+			return fmt.Errorf("failed to delete the WireGuard peer of Node %s: %v", nodeName, err)
 		}
+	}
+	c.installedNodes.Delete(nodeName)
+	return nil
+}
+
+// addNodeRoute installs the routes and flows to a Node, or replaces them if the parameters of the Node changed since
+// they were installed.
+func (c *Controller) addNodeRoute(node *v1.Node) error {
+	nodeName := node.Name
+	podCIDRs := getPodCIDRs(node)
+	if len(podCIDRs) == 0 {
+		klog.V(1).Infof("PodCIDR is empty for peer node %s", nodeName)
+		return nil
+	}
 
-		peerPodCIDRAddr, peerPodCIDR, err := net.ParseCIDR(node.Spec.PodCIDR)
+	peerConfigs := make(map[*net.IPNet]net.IP, len(podCIDRs))
+	for _, podCIDR := range podCIDRs {
+		peerPodCIDRAddr, peerPodCIDR, err := net.ParseCIDR(podCIDR)
 		if err != nil {
-			return fmt.Errorf("failed to parse PodCIDR %s", node.Spec.PodCIDR)
+			return fmt.Errorf("failed to parse PodCIDR %s", podCIDR)
 		}
-		peerNodeIP, err := getNodeAddr(node)
-		if err != nil {
-			return fmt.Errorf("failed to retrieve IP address of Node %s: %v", nodeName, err)
+		// The Pod CIDRs of an IP family which is not enabled on the local Node cannot be reached.
+		isIPv6 := peerPodCIDRAddr.To4() == nil
+		if (isIPv6 && c.nodeConfig.PodIPv6CIDR == nil) || (!isIPv6 && c.nodeConfig.PodIPv4CIDR == nil) {
+			klog.Warningf("Ignoring PodCIDR %s of Node %s, its IP family is not enabled on this Node", podCIDR, nodeName)
+			continue
 		}
-		peerGatewayIP := ip.NextIP(peerPodCIDRAddr)
+		peerConfigs[peerPodCIDR] = ip.NextIP(peerPodCIDRAddr)
+	}
+	peerNodeIP, err := getNodeTransportAddr(node)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve IP address of Node %s: %v", nodeName, err)
+	}
 
-		if !flowsAreInstalled { // then install flows
-			err = c.ofClient.InstallNodeFlows(nodeName, c.nodeConfig.GatewayConfig.MAC, peerGatewayIP, *peerPodCIDR, peerNodeIP)
-			if err != nil {
-				return fmt.Errorf("failed to install flows to Node %s: %v", nodeName, err)
+	encap := c.networkConfig.TrafficEncapMode.NeedsEncapToPeer(peerNodeIP, c.nodeConfig.NodeTransportIPAddr)
+	// With WireGuard, the Pod traffic to the Node is routed by the host through the WireGuard device, instead of being
+	// encapsulated in the OVS tunnel. WireGuard requires the encap mode, so that the traffic to all the Nodes is
+	// encrypted.
+	var wireGuardPublicKey string
+	if encap && c.wireGuardClient != nil {
+		wireGuardPublicKey = node.Annotations[types.NodeWireGuardPublicKeyAnnotationKey]
+		if wireGuardPublicKey == "" {
+			// The Node is synced again when its Agent publishes the public key.
+			klog.Infof("Waiting for the WireGuard public key of Node %s", nodeName)
+			return nil
+		}
+		encap = false
+	}
+	var peerPodCIDRs []*net.IPNet
+	if !encap {
+		// The Node IP is the next hop of the Pod CIDRs, which must be in the same IP family. The WireGuard device
+		// carries both IP families to the Node IP.
+		for peerPodCIDR := range peerConfigs {
+			if wireGuardPublicKey == "" && (peerPodCIDR.IP.To4() == nil) != (peerNodeIP.To4() == nil) {
+				klog.Warningf("Ignoring PodCIDR %s of Node %s, it cannot be routed to Node IP %s without encapsulation", peerPodCIDR, nodeName, peerNodeIP)
+				delete(peerConfigs, peerPodCIDR)
+				continue
 			}
-			c.installedNodes.Store(nodeName, nil)
+			peerPodCIDRs = append(peerPodCIDRs, peerPodCIDR)
+		}
+	}
+
+	var routes []*netlink.Route
+	for peerPodCIDR, peerGatewayIP := range peerConfigs {
+		if encap {
+			routes = append(routes, &netlink.Route{
+				Dst:       peerPodCIDR,
+				Flags:     int(netlink.FLAG_ONLINK),
+				LinkIndex: c.gatewayLink.Attrs().Index,
+				Gw:        peerGatewayIP,
+				Protocol:  antreaRouteProtocol,
+			})
+		} else if wireGuardPublicKey != "" {
+			routes = append(routes, &netlink.Route{
+				Dst:       peerPodCIDR,
+				LinkIndex: c.wireGuardClient.LinkIndex(),
+				Protocol:  antreaRouteProtocol,
+			})
+		} else {
+			routes = append(routes, &netlink.Route{
+				Dst:      peerPodCIDR,
+				Gw:       peerNodeIP,
+				Protocol: antreaRouteProtocol,
+			})
+		}
+	}
+	newRouteInfo := &nodeRouteInfo{nodeIP: peerNodeIP, encap: encap, wireGuardPublicKey: wireGuardPublicKey, routes: routes}
+	// With IPSec, the traffic to the Node is output to its IPSec tunnel port instead of the flow based tunnel port.
+	// The port is created again, and the flows updated, if its ofport changed, e.g. because it was deleted from OVS.
+	ipsecTunnel := encap && c.networkConfig.TrafficEncryptionMode == config.TrafficEncryptionModeIPSec
+	if ipsecTunnel {
+		if iface, ok := c.interfaceStore.GetNodeTunnelInterface(nodeName); ok {
+			newRouteInfo.tunOFPort = iface.OFPort
+		}
+	}
+
+	info, _ := c.installedNodes.Load(nodeName)
+	var oldRouteInfo *nodeRouteInfo
+	if info != nil {
+		oldRouteInfo = info.(*nodeRouteInfo)
+	}
+	if oldRouteInfo != nil && !oldRouteInfo.restored {
+		if oldRouteInfo.equal(newRouteInfo) {
+			return nil
+		}
+		klog.Infof("Updating routes and flows to Node %s, podCIDRs: %v, Node IP: %s, encap: %t", nodeName, podCIDRs, peerNodeIP, encap)
+	} else {
+		klog.Infof("Adding routes and flows to Node %s, podCIDRs: %v, Node IP: %s, encap: %t", nodeName, podCIDRs, peerNodeIP, encap)
+	}
+
+	var tunOFPort int32
+	if ipsecTunnel {
+		if tunOFPort, err = c.createIPSecTunnelPort(nodeName, peerNodeIP); err != nil {
+			return err
+		}
+		newRouteInfo.tunOFPort = tunOFPort
+	}
+
+	// The flows installed already for the Node, if any, are replaced atomically.
+	if encap {
+		err = c.ofClient.InstallNodeFlows(nodeName, c.nodeConfig.GatewayConfig.MAC, peerConfigs, peerNodeIP, uint32(tunOFPort))
+	} else {
+		err = c.ofClient.InstallNoEncapNodeFlows(nodeName, c.nodeConfig.GatewayConfig.MAC, peerPodCIDRs)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to install flows to Node %s: %v", nodeName, err)
+	}
+	if oldRouteInfo == nil {
+		c.installedNodes.Store(nodeName, nil)
+	}
+	if !ipsecTunnel {
+		if err := c.deleteIPSecTunnelPort(nodeName); err != nil {
+			return err
 		}
-		// install route
-		route := &netlink.Route{
-			Dst:       peerPodCIDR,
-			Flags:     int(netlink.FLAG_ONLINK),
-			LinkIndex: c.gatewayLink.Attrs().Index,
-			Gw:        peerGatewayIP,
+	}
+	if wireGuardPublicKey != "" {
+		if err := c.wireGuardClient.UpdatePeer(nodeName, wireGuardPublicKey, peerNodeIP, peerPodCIDRs); err != nil {
+			return fmt.Errorf("failed to update the WireGuard peer of Node %s: %v", nodeName, err)
 		}
+	}
 
-		err = netlink.RouteAdd(route)
-		// This is likely to be caused by an agent restart and so should not happen once we
-		// handle state reconciliation on restart properly. However, it is probably better
-		// to handle this case gracefully for the time being.
-		if err == unix.EEXIST {
-			klog.Warningf("Route to Node %s already exists, replacing it", nodeName)
-			err = netlink.RouteReplace(route)
+	for _, route := range routes {
+		if !encap {
+			if err = c.iptablesClient(route.Dst).AddPeerPodCIDR(nodeName, route.Dst); err != nil {
+				return fmt.Errorf("failed to add the iptables rule of Node %s: %v", nodeName, err)
+			}
+		} else if route.Gw.To4() == nil {
+			// There is no ARP responder for the IPv6 peer gateways: they are resolved to the global virtual MAC
+			// with a permanent neighbor entry instead.
+			if err = neighSet(c.peerGatewayNeigh(route.Gw)); err != nil {
+				return fmt.Errorf("failed to set the neighbor of Node %s gateway: %v", nodeName, err)
+			}
+		}
+
+		if oldRouteInfo != nil {
+			// The route to the Pod CIDR, if any, is replaced atomically.
+			err = routeReplace(route)
+		} else {
+			err = routeAdd(route)
+			// The route may have been installed by another program, or by a previous run of the
+			// Agent which did not tag its routes with antreaRouteProtocol.
+			if err == unix.EEXIST {
+				klog.Warningf("Route to Node %s already exists, replacing it", nodeName)
+				err = routeReplace(route)
+			}
 		}
 		if err != nil {
 			return fmt.Errorf("failed to install route to Node %s with netlink: %v", nodeName, err)
 		}
-		c.installedNodes.Store(nodeName, route)
 	}
+
+	if oldRouteInfo != nil {
+		if err := c.deleteRoutes(nodeName, oldRouteInfo, newRouteInfo); err != nil {
+			return err
+		}
+		if !oldRouteInfo.restored {
+			c.recordNodeEvent(node, v1.EventTypeNormal, "NodeRouteUpdated",
+				"Routes and flows to Node %s updated: Node IP %s, encap %t, Pod CIDRs %v", nodeName, peerNodeIP, encap, podCIDRs)
+		}
+	}
+	c.installedNodes.Store(nodeName, newRouteInfo)
 	return nil
 }
 
-// getNodeAddr gets the available IP address of a Node. getNodeAddr will first try to get the
-// NodeInternalIP, then try to get the NodeExternalIP.
-func getNodeAddr(node *v1.Node) (net.IP, error) {
-	addresses := make(map[v1.NodeAddressType]string)
-	for _, addr := range node.Status.Addresses {
-		addresses[addr.Type] = addr.Address
-	}
-	var ipAddrStr string
-	if internalIp, ok := addresses[v1.NodeInternalIP]; ok {
-		ipAddrStr = internalIp
-	} else if externalIp, ok := addresses[v1.NodeExternalIP]; ok {
-		ipAddrStr = externalIp
+// deleteRoutes deletes the routes of oldRouteInfo, with their iptables rules and neighbor entries, which are not
+// installed anymore with newRouteInfo. All of them are deleted if newRouteInfo is nil.
+func (c *Controller) deleteRoutes(nodeName string, oldRouteInfo, newRouteInfo *nodeRouteInfo) error {
+	for _, route := range oldRouteInfo.routes {
+		var newRoute *netlink.Route
+		if newRouteInfo != nil {
+			newRoute = newRouteInfo.findRoute(route.Dst)
+		}
+		// A route to the same Pod CIDR was replaced already.
+		if newRoute == nil {
+			if err := routeDel(route); err != nil && err != unix.ESRCH {
+				return fmt.Errorf("failed to delete the route to Node %s: %v", nodeName, err)
+			}
+		}
+		if !oldRouteInfo.encap {
+			if newRoute == nil || newRouteInfo.encap {
+				if err := c.iptablesClient(route.Dst).DeletePeerPodCIDR(route.Dst); err != nil {
+					return fmt.Errorf("failed to delete the iptables rule of Node %s: %v", nodeName, err)
+				}
+			}
+		} else if peerGatewayIP := route.Gw; peerGatewayIP.To4() == nil {
+			if newRoute == nil || !newRouteInfo.encap || !newRoute.Gw.Equal(peerGatewayIP) {
+				if err := neighDel(c.peerGatewayNeigh(peerGatewayIP)); err != nil && err != unix.ENOENT {
+					return fmt.Errorf("failed to delete the neighbor of Node %s gateway: %v", nodeName, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// createIPSecTunnelPort creates the IPSec tunnel port to a Node, with the pre-shared key of the Pod network or the name
+// of the Node in its certificate, and returns its ofport. If the port exists already, it is reused if its remote IP is
+// nodeIP and it uses the same authentication, and recreated otherwise.
+func (c *Controller) createIPSecTunnelPort(nodeName string, nodeIP net.IP) (int32, error) {
+	portName := util.GenerateNodeTunnelInterfaceName(nodeName)
+	// The certificate of a Node has the name of the Node as Common Name.
+	var remoteName, psk string
+	if c.networkConfig.IPSecAuthenticationMode == config.IPSecAuthenticationModeCert {
+		remoteName = nodeName
 	} else {
-		return nil, fmt.Errorf("Node %s has neither external ip nor internal ip", node.Name)
+		psk = c.networkConfig.IPSecPSK
+	}
+	if iface, ok := c.interfaceStore.GetInterface(portName); ok {
+		if len(iface.IPs) > 0 && iface.IPs[0].Equal(nodeIP) && iface.IPSecRemoteName == remoteName && iface.OFPort > 0 {
+			return iface.OFPort, nil
+		}
+		klog.Infof("Remote IP or authentication of IPSec tunnel port %s of Node %s changed, recreating it", portName, nodeName)
+		if err := c.deleteIPSecTunnelPort(nodeName); err != nil {
+			return 0, err
+		}
+	}
+
+	ovsExternalIDs := map[string]interface{}{ovsExternalIDNodeName: nodeName}
+	portUUID, err := c.ovsBridgeClient.CreateTunnelPortExt(portName, c.networkConfig.TunnelType, 0, nodeIP.String(), remoteName, psk, ovsExternalIDs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create IPSec tunnel port %s to Node %s: %v", portName, nodeName, err)
+	}
+	ofPort, err := c.ovsBridgeClient.GetOFPort(portName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the ofport of IPSec tunnel port %s: %v", portName, err)
+	}
+	klog.Infof("Created IPSec tunnel port %s to Node %s with ofport %d", portName, nodeName, ofPort)
+	iface := interfacestore.NewIPSecTunnelInterface(portName, nodeName, nodeIP)
+	iface.IPSecRemoteName = remoteName
+	iface.OVSPortConfig = &interfacestore.OVSPortConfig{IfaceName: portName, PortUUID: portUUID, OFPort: ofPort}
+	c.interfaceStore.AddInterface(portName, iface)
+	return ofPort, nil
+}
+
+// deleteIPSecTunnelPort deletes the IPSec tunnel port to a Node, if it exists.
+func (c *Controller) deleteIPSecTunnelPort(nodeName string) error {
+	iface, ok := c.interfaceStore.GetNodeTunnelInterface(nodeName)
+	if !ok {
+		return nil
 	}
-	ipAddr := net.ParseIP(ipAddrStr)
-	if ipAddr == nil {
-		return nil, fmt.Errorf("<%v> is not a valid ip address", ipAddrStr)
+	if err := c.ovsBridgeClient.DeletePort(iface.PortUUID); err != nil {
+		return fmt.Errorf("failed to delete IPSec tunnel port %s of Node %s: %v", iface.IfaceName, nodeName, err)
+	}
+	klog.Infof("Deleted IPSec tunnel port %s of Node %s", iface.IfaceName, nodeName)
+	c.interfaceStore.DeleteInterface(iface.IfaceName)
+	return nil
+}
+
+// deleteStaleTunnelPorts deletes the IPSec tunnel ports created by a previous run of the Agent for the Nodes which no
+// longer exist, or for all the Nodes if IPSec is not enabled anymore. The ports of the other Nodes are reused, or
+// deleted if they are not needed anymore, when the Nodes are synced.
+func (c *Controller) deleteStaleTunnelPorts(nodes []*v1.Node) {
+	nodeNames := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		nodeNames[node.Name] = true
+	}
+	for _, ifaceID := range c.interfaceStore.GetInterfaceIDs() {
+		iface, ok := c.interfaceStore.GetInterface(ifaceID)
+		if !ok || iface.Type != interfacestore.TunnelInterface || iface.NodeName == "" {
+			continue
+		}
+		if c.networkConfig.TrafficEncryptionMode == config.TrafficEncryptionModeIPSec && nodeNames[iface.NodeName] {
+			continue
+		}
+		if err := c.deleteIPSecTunnelPort(iface.NodeName); err != nil {
+			klog.Errorf("Failed to delete stale IPSec tunnel port: %v", err)
+		}
+	}
+}
+
+// deleteStaleWireGuardPeers deletes the WireGuard peers added by a previous run of the Agent for the Nodes which no
+// longer exist, or whose public key changed since. The peers of the other Nodes are updated when the Nodes are synced.
+func (c *Controller) deleteStaleWireGuardPeers(nodes []*v1.Node) {
+	publicKeys := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		if publicKey, ok := node.Annotations[types.NodeWireGuardPublicKeyAnnotationKey]; ok && node.Name != c.nodeConfig.Name {
+			publicKeys[publicKey] = true
+		}
+	}
+	if err := c.wireGuardClient.DeleteStalePeers(publicKeys); err != nil {
+		klog.Errorf("Failed to delete stale WireGuard peers: %v", err)
+	}
+}
+
+// ParseTunnelInterfaceConfig returns the configuration of the IPSec tunnel port of a peer Node from the OVS port data,
+// or nil if the port is not the IPSec tunnel port of a Node.
+func ParseTunnelInterfaceConfig(portData *ovsconfig.OVSPortData, portConfig *interfacestore.OVSPortConfig) *interfacestore.InterfaceConfig {
+	nodeName, found := portData.ExternalIDs[ovsExternalIDNodeName]
+	if !found {
+		return nil
+	}
+	remoteIP := net.ParseIP(portData.Options["remote_ip"])
+	if remoteIP == nil {
+		klog.Warningf("IPSec tunnel port %s of Node %s has no valid remote IP", portData.Name, nodeName)
+	}
+	iface := interfacestore.NewIPSecTunnelInterface(portData.Name, nodeName, remoteIP)
+	iface.IPSecRemoteName = portData.Options["remote_name"]
+	iface.OVSPortConfig = portConfig
+	return iface
+}
+
+// recordNodeEvent records an event on a peer Node.
+func (c *Controller) recordNodeEvent(node *v1.Node, eventType, reason, messageFmt string, args ...interface{}) {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.Eventf(node, eventType, reason, messageFmt, args...)
+}
+
+// GetPeerNodes returns the peer Nodes whose routes are installed, and whether the Pod traffic to each of them is
+// encapsulated, sorted by name.
+func (c *Controller) GetPeerNodes() []v1beta1.PeerNodeInfo {
+	var peerNodes []v1beta1.PeerNodeInfo
+	c.installedNodes.Range(func(key, value interface{}) bool {
+		if value == nil {
+			return true
+		}
+		info := value.(*nodeRouteInfo)
+		peerNodes = append(peerNodes, v1beta1.PeerNodeInfo{Name: key.(string), NodeIP: info.nodeIP.String(), Encap: info.encap})
+		return true
+	})
+	sort.Slice(peerNodes, func(i, j int) bool {
+		return peerNodes[i].Name < peerNodes[j].Name
+	})
+	return peerNodes
+}
+
+// getPodCIDRs returns the Pod CIDRs of a Node, there is one CIDR per IP family with dual-stack. The Nodes which are
+// not dual-stack aware only set Spec.PodCIDR.
+func getPodCIDRs(node *v1.Node) []string {
+	if len(node.Spec.PodCIDRs) > 0 {
+		return node.Spec.PodCIDRs
+	}
+	if node.Spec.PodCIDR != "" {
+		return []string{node.Spec.PodCIDR}
+	}
+	return nil
+}
+
+// getNodeTransportAddr returns the address of a Node used for the tunnels and the routes to the Node: the one
+// published by the Agent of the Node in the transport address annotation, or else the Node IP.
+func getNodeTransportAddr(node *v1.Node) (net.IP, error) {
+	if addr, ok := node.Annotations[types.NodeTransportAddressAnnotationKey]; ok {
+		if ip := net.ParseIP(addr); ip != nil {
+			return ip, nil
+		}
+		klog.Warningf("Ignoring invalid transport address %q of Node %s", addr, node.Name)
+	}
+	return util.GetNodeAddr(node)
+}
+
+// iptablesClient returns the iptables client of the IP family of the Pod CIDR. The Pod CIDRs of the IP families which
+// are not enabled on the local Node are ignored, so the client always exists, except for the stale routes installed
+// by a previous run of the Agent with other IP families.
+func (c *Controller) iptablesClient(podCIDR *net.IPNet) iptablesRuleClient {
+	isIPv6 := podCIDR.IP.To4() == nil
+	for _, client := range c.iptablesClients {
+		if client.IsIPv6() == isIPv6 {
+			return client
+		}
+	}
+	return nil
+}
+
+// peerGatewayNeigh returns the permanent neighbor entry which resolves the IPv6 gateway of a peer Node to the global
+// virtual MAC on the local gateway interface.
+func (c *Controller) peerGatewayNeigh(peerGatewayIP net.IP) *netlink.Neigh {
+	return &netlink.Neigh{
+		LinkIndex:    c.gatewayLink.Attrs().Index,
+		Family:       netlink.FAMILY_V6,
+		State:        netlink.NUD_PERMANENT,
+		IP:           peerGatewayIP,
+		HardwareAddr: openflow.GlobalVirtualMAC,
 	}
-	return ipAddr, nil
 }
@@ -0,0 +1,497 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package noderoute
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/vmware-tanzu/antrea/pkg/agent/config"
+	"github.com/vmware-tanzu/antrea/pkg/agent/interfacestore"
+	openflowtest "github.com/vmware-tanzu/antrea/pkg/agent/openflow/testing"
+	"github.com/vmware-tanzu/antrea/pkg/agent/types"
+	"github.com/vmware-tanzu/antrea/pkg/agent/util"
+	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
+	ovsconfigtest "github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig/testing"
+)
+
+const gatewayIndex = 10
+
+var (
+	gatewayMAC, _  = net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	_, podCIDR, _  = net.ParseCIDR("10.10.0.0/24")
+	_, podCIDR6, _ = net.ParseCIDR("fd00:10:10::/64")
+	nodeConfig     = &types.NodeConfig{
+		Name:                "node0",
+		PodIPv4CIDR:         podCIDR,
+		PodIPv6CIDR:         podCIDR6,
+		NodeTransportIPAddr: &net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)},
+		GatewayConfig:       &types.GatewayConfig{Name: "antrea-gw0", MAC: gatewayMAC},
+	}
+)
+
+// fakeNetlink replaces the netlink functions of the controller, and records the routes and the neighbor entries
+// installed and deleted with them.
+type fakeNetlink struct {
+	routes         []netlink.Route
+	addedRoutes    []string
+	replacedRoutes []string
+	deletedRoutes  []string
+	setNeighs      []string
+	deletedNeighs  []string
+}
+
+func routeString(route *netlink.Route) string {
+	if route.Gw == nil {
+		return fmt.Sprintf("%s dev %d", route.Dst, route.LinkIndex)
+	}
+	return fmt.Sprintf("%s via %s", route.Dst, route.Gw)
+}
+
+// install replaces the netlink functions with the fake ones, and returns a function restoring them.
+func (f *fakeNetlink) install() func() {
+	origRouteListFiltered, origRouteAdd, origRouteReplace, origRouteDel := routeListFiltered, routeAdd, routeReplace, routeDel
+	origNeighSet, origNeighDel := neighSet, neighDel
+	routeListFiltered = func(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error) {
+		return f.routes, nil
+	}
+	routeAdd = func(route *netlink.Route) error {
+		f.addedRoutes = append(f.addedRoutes, routeString(route))
+		return nil
+	}
+	routeReplace = func(route *netlink.Route) error {
+		f.replacedRoutes = append(f.replacedRoutes, routeString(route))
+		return nil
+	}
+	routeDel = func(route *netlink.Route) error {
+		f.deletedRoutes = append(f.deletedRoutes, routeString(route))
+		return nil
+	}
+	neighSet = func(neigh *netlink.Neigh) error {
+		f.setNeighs = append(f.setNeighs, neigh.IP.String())
+		return nil
+	}
+	neighDel = func(neigh *netlink.Neigh) error {
+		f.deletedNeighs = append(f.deletedNeighs, neigh.IP.String())
+		return nil
+	}
+	return func() {
+		routeListFiltered, routeAdd, routeReplace, routeDel = origRouteListFiltered, origRouteAdd, origRouteReplace, origRouteDel
+		neighSet, neighDel = origNeighSet, origNeighDel
+	}
+}
+
+// fakeIPTablesClient records the Pod CIDRs exempted from masquerading, with the name of their Node.
+type fakeIPTablesClient struct {
+	ipv6         bool
+	peerPodCIDRs map[string]string
+}
+
+func (c *fakeIPTablesClient) IsIPv6() bool {
+	return c.ipv6
+}
+
+func (c *fakeIPTablesClient) AddPeerPodCIDR(nodeName string, peerPodCIDR *net.IPNet) error {
+	c.peerPodCIDRs[peerPodCIDR.String()] = nodeName
+	return nil
+}
+
+func (c *fakeIPTablesClient) DeletePeerPodCIDR(peerPodCIDR *net.IPNet) error {
+	delete(c.peerPodCIDRs, peerPodCIDR.String())
+	return nil
+}
+
+type fakeController struct {
+	*Controller
+	ofClient       *openflowtest.MockClient
+	ovsClient      *ovsconfigtest.MockOVSBridgeClient
+	iptablesClient *fakeIPTablesClient
+	recorder       *record.FakeRecorder
+}
+
+func newFakeController(t *testing.T, ctrl *gomock.Controller, encapMode config.TrafficEncapModeType, nodes ...*v1.Node) *fakeController {
+	clientset := fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(clientset, 0)
+	ofClient := openflowtest.NewMockClient(ctrl)
+	ovsClient := ovsconfigtest.NewMockOVSBridgeClient(ctrl)
+	recorder := record.NewFakeRecorder(10)
+	networkConfig := &config.NetworkConfig{TrafficEncapMode: encapMode, TunnelType: ovsconfig.GeneveTunnel}
+	c := NewNodeRouteController(clientset, informerFactory, ofClient, ovsClient, interfacestore.NewInterfaceStore(), nil,
+		nil, networkConfig, nodeConfig, recorder)
+	c.gatewayLink = &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Index: gatewayIndex}}
+	iptablesClient := &fakeIPTablesClient{peerPodCIDRs: map[string]string{}}
+	c.iptablesClients = []iptablesRuleClient{iptablesClient, &fakeIPTablesClient{ipv6: true, peerPodCIDRs: map[string]string{}}}
+	for _, node := range nodes {
+		require.Nil(t, informerFactory.Core().V1().Nodes().Informer().GetStore().Add(node))
+	}
+	return &fakeController{c, ofClient, ovsClient, iptablesClient, recorder}
+}
+
+func newNode(name, nodeIP string, podCIDRs ...string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1.NodeSpec{PodCIDRs: podCIDRs},
+		Status:     v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: nodeIP}}},
+	}
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return ipNet
+}
+
+// encapRoute returns the route to a Pod CIDR through the tunnel, via the gateway of the peer Node.
+func encapRoute(dst, gw string) *netlink.Route {
+	return &netlink.Route{Dst: mustParseCIDR(dst), Gw: net.ParseIP(gw), LinkIndex: gatewayIndex, Flags: int(netlink.FLAG_ONLINK), Protocol: antreaRouteProtocol}
+}
+
+// noEncapRoute returns the route to a Pod CIDR routed by the host, via the peer Node IP.
+func noEncapRoute(dst, nodeIP string) *netlink.Route {
+	return &netlink.Route{Dst: mustParseCIDR(dst), Gw: net.ParseIP(nodeIP), Protocol: antreaRouteProtocol}
+}
+
+func TestRestoreRoutes(t *testing.T) {
+	node1 := newNode("node1", "192.168.1.11", "10.10.1.0/24")
+	tests := []struct {
+		name                 string
+		nodes                []*v1.Node
+		routes               []*netlink.Route
+		peerPodCIDRs         map[string]string
+		expectedNodes        map[string]*nodeRouteInfo
+		expectedDeleted      []string
+		expectedDeletedNeigh []string
+		expectedPeerPodCIDRs map[string]string
+	}{
+		{
+			name:   "encap-route",
+			nodes:  []*v1.Node{node1},
+			routes: []*netlink.Route{encapRoute("10.10.1.0/24", "10.10.1.1")},
+			expectedNodes: map[string]*nodeRouteInfo{
+				"node1": {nodeIP: net.ParseIP("192.168.1.11"), encap: true, routes: []*netlink.Route{encapRoute("10.10.1.0/24", "10.10.1.1")}},
+			},
+			expectedPeerPodCIDRs: map[string]string{},
+		},
+		{
+			name:         "noencap-route",
+			nodes:        []*v1.Node{node1},
+			routes:       []*netlink.Route{noEncapRoute("10.10.1.0/24", "192.168.1.11")},
+			peerPodCIDRs: map[string]string{"10.10.1.0/24": "node1"},
+			expectedNodes: map[string]*nodeRouteInfo{
+				"node1": {nodeIP: net.ParseIP("192.168.1.11"), routes: []*netlink.Route{noEncapRoute("10.10.1.0/24", "192.168.1.11")}},
+			},
+			expectedPeerPodCIDRs: map[string]string{"10.10.1.0/24": "node1"},
+		},
+		{
+			name:                 "stale-encap-route",
+			routes:               []*netlink.Route{encapRoute("10.10.1.0/24", "10.10.1.1")},
+			expectedNodes:        map[string]*nodeRouteInfo{},
+			expectedDeleted:      []string{"10.10.1.0/24 via 10.10.1.1"},
+			expectedPeerPodCIDRs: map[string]string{},
+		},
+		{
+			name:                 "stale-noencap-route",
+			routes:               []*netlink.Route{noEncapRoute("10.10.1.0/24", "192.168.1.11")},
+			peerPodCIDRs:         map[string]string{"10.10.1.0/24": "node1"},
+			expectedNodes:        map[string]*nodeRouteInfo{},
+			expectedDeleted:      []string{"10.10.1.0/24 via 192.168.1.11"},
+			expectedPeerPodCIDRs: map[string]string{},
+		},
+		{
+			name:                 "stale-ipv6-encap-route",
+			nodes:                []*v1.Node{node1},
+			routes:               []*netlink.Route{encapRoute("fd00:10:10:1::/64", "fd00:10:10:1::1")},
+			expectedNodes:        map[string]*nodeRouteInfo{},
+			expectedDeleted:      []string{"fd00:10:10:1::/64 via fd00:10:10:1::1"},
+			expectedDeletedNeigh: []string{"fd00:10:10:1::1"},
+			expectedPeerPodCIDRs: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := newFakeController(t, ctrl, config.TrafficEncapModeEncap, tt.nodes...)
+			for cidr, nodeName := range tt.peerPodCIDRs {
+				c.iptablesClient.peerPodCIDRs[cidr] = nodeName
+			}
+			fakeNetlink := &fakeNetlink{}
+			for _, route := range tt.routes {
+				fakeNetlink.routes = append(fakeNetlink.routes, *route)
+			}
+			defer fakeNetlink.install()()
+
+			require.Nil(t, c.restoreRoutes(tt.nodes))
+			restoredNodes := map[string]*nodeRouteInfo{}
+			c.installedNodes.Range(func(key, value interface{}) bool {
+				restoredNodes[key.(string)] = value.(*nodeRouteInfo)
+				return true
+			})
+			require.Equal(t, len(tt.expectedNodes), len(restoredNodes))
+			for nodeName, expected := range tt.expectedNodes {
+				info := restoredNodes[nodeName]
+				require.NotNil(t, info, "Routes of Node %s not restored", nodeName)
+				assert.True(t, info.restored)
+				expected.restored = true
+				assert.True(t, expected.equal(info), "Expected routes %+v, got %+v", expected, info)
+			}
+			assert.Equal(t, tt.expectedDeleted, fakeNetlink.deletedRoutes)
+			assert.Equal(t, tt.expectedDeletedNeigh, fakeNetlink.deletedNeighs)
+			assert.Equal(t, tt.expectedPeerPodCIDRs, c.iptablesClient.peerPodCIDRs)
+		})
+	}
+}
+
+func TestAddNodeRoute(t *testing.T) {
+	node1 := newNode("node1", "192.168.1.11", "10.10.1.0/24")
+	tests := []struct {
+		name                 string
+		encapMode            config.TrafficEncapModeType
+		installed            *nodeRouteInfo
+		expectFlows          func(ofClient *openflowtest.MockClientMockRecorder)
+		expectedAdded        []string
+		expectedReplaced     []string
+		expectedDeleted      []string
+		expectedPeerPodCIDRs map[string]string
+		expectedEvent        bool
+	}{
+		{
+			name:      "add",
+			encapMode: config.TrafficEncapModeEncap,
+			expectFlows: func(ofClient *openflowtest.MockClientMockRecorder) {
+				ofClient.InstallNodeFlows("node1", gatewayMAC, gomock.Any(), net.ParseIP("192.168.1.11"), uint32(0))
+			},
+			expectedAdded:        []string{"10.10.1.0/24 via 10.10.1.1"},
+			expectedPeerPodCIDRs: map[string]string{},
+		},
+		{
+			name:      "unchanged",
+			encapMode: config.TrafficEncapModeEncap,
+			installed: &nodeRouteInfo{nodeIP: net.ParseIP("192.168.1.11"), encap: true,
+				routes: []*netlink.Route{encapRoute("10.10.1.0/24", "10.10.1.1")}},
+			expectFlows:          func(ofClient *openflowtest.MockClientMockRecorder) {},
+			expectedPeerPodCIDRs: map[string]string{},
+		},
+		{
+			name:      "replace-node-ip",
+			encapMode: config.TrafficEncapModeEncap,
+			installed: &nodeRouteInfo{nodeIP: net.ParseIP("192.168.1.12"), encap: true,
+				routes: []*netlink.Route{encapRoute("10.10.1.0/24", "10.10.1.1")}},
+			expectFlows: func(ofClient *openflowtest.MockClientMockRecorder) {
+				ofClient.InstallNodeFlows("node1", gatewayMAC, gomock.Any(), net.ParseIP("192.168.1.11"), uint32(0))
+			},
+			expectedReplaced:     []string{"10.10.1.0/24 via 10.10.1.1"},
+			expectedPeerPodCIDRs: map[string]string{},
+			expectedEvent:        true,
+		},
+		{
+			name:      "replace-restored",
+			encapMode: config.TrafficEncapModeEncap,
+			installed: &nodeRouteInfo{nodeIP: net.ParseIP("192.168.1.11"), encap: true, restored: true,
+				routes: []*netlink.Route{encapRoute("10.10.1.0/24", "10.10.1.1")}},
+			expectFlows: func(ofClient *openflowtest.MockClientMockRecorder) {
+				ofClient.InstallNodeFlows("node1", gatewayMAC, gomock.Any(), net.ParseIP("192.168.1.11"), uint32(0))
+			},
+			expectedReplaced:     []string{"10.10.1.0/24 via 10.10.1.1"},
+			expectedPeerPodCIDRs: map[string]string{},
+		},
+		{
+			name:      "replace-encap-with-noencap",
+			encapMode: config.TrafficEncapModeHybrid,
+			installed: &nodeRouteInfo{nodeIP: net.ParseIP("192.168.1.11"), encap: true,
+				routes: []*netlink.Route{encapRoute("10.10.1.0/24", "10.10.1.1")}},
+			expectFlows: func(ofClient *openflowtest.MockClientMockRecorder) {
+				ofClient.InstallNoEncapNodeFlows("node1", gatewayMAC, []*net.IPNet{mustParseCIDR("10.10.1.0/24")})
+			},
+			expectedReplaced:     []string{"10.10.1.0/24 via 192.168.1.11"},
+			expectedPeerPodCIDRs: map[string]string{"10.10.1.0/24": "node1"},
+			expectedEvent:        true,
+		},
+		{
+			name:      "replace-noencap-with-encap",
+			encapMode: config.TrafficEncapModeEncap,
+			installed: &nodeRouteInfo{nodeIP: net.ParseIP("192.168.1.11"),
+				routes: []*netlink.Route{noEncapRoute("10.10.1.0/24", "192.168.1.11")}},
+			expectFlows: func(ofClient *openflowtest.MockClientMockRecorder) {
+				ofClient.InstallNodeFlows("node1", gatewayMAC, gomock.Any(), net.ParseIP("192.168.1.11"), uint32(0))
+			},
+			expectedReplaced:     []string{"10.10.1.0/24 via 10.10.1.1"},
+			expectedPeerPodCIDRs: map[string]string{},
+			expectedEvent:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := newFakeController(t, ctrl, tt.encapMode, node1)
+			if tt.installed != nil {
+				c.installedNodes.Store("node1", tt.installed)
+				if !tt.installed.encap {
+					c.iptablesClient.peerPodCIDRs["10.10.1.0/24"] = "node1"
+				}
+			}
+			tt.expectFlows(c.ofClient.EXPECT())
+			fakeNetlink := &fakeNetlink{}
+			defer fakeNetlink.install()()
+
+			require.Nil(t, c.addNodeRoute(node1))
+			assert.Equal(t, tt.expectedAdded, fakeNetlink.addedRoutes)
+			assert.Equal(t, tt.expectedReplaced, fakeNetlink.replacedRoutes)
+			assert.Equal(t, tt.expectedDeleted, fakeNetlink.deletedRoutes)
+			assert.Equal(t, tt.expectedPeerPodCIDRs, c.iptablesClient.peerPodCIDRs)
+			info, ok := c.installedNodes.Load("node1")
+			require.True(t, ok)
+			assert.False(t, info.(*nodeRouteInfo).restored)
+			if tt.expectedEvent {
+				require.Len(t, c.recorder.Events, 1)
+				assert.Contains(t, <-c.recorder.Events, "NodeRouteUpdated")
+			} else {
+				assert.Len(t, c.recorder.Events, 0)
+			}
+		})
+	}
+}
+
+func TestDeleteNodeRoute(t *testing.T) {
+	tests := []struct {
+		name                 string
+		installed            *nodeRouteInfo
+		flowsInstalled       bool
+		tunnelPort           bool
+		peerPodCIDRs         map[string]string
+		expectedDeleted      []string
+		expectedDeletedNeigh []string
+	}{
+		{
+			name:            "encap",
+			installed:       &nodeRouteInfo{nodeIP: net.ParseIP("192.168.1.11"), encap: true, routes: []*netlink.Route{encapRoute("10.10.1.0/24", "10.10.1.1")}},
+			flowsInstalled:  true,
+			expectedDeleted: []string{"10.10.1.0/24 via 10.10.1.1"},
+		},
+		{
+			name:            "noencap",
+			installed:       &nodeRouteInfo{nodeIP: net.ParseIP("192.168.1.11"), routes: []*netlink.Route{noEncapRoute("10.10.1.0/24", "192.168.1.11")}},
+			flowsInstalled:  true,
+			peerPodCIDRs:    map[string]string{"10.10.1.0/24": "node1"},
+			expectedDeleted: []string{"10.10.1.0/24 via 192.168.1.11"},
+		},
+		{
+			name: "ipv6-encap",
+			installed: &nodeRouteInfo{nodeIP: net.ParseIP("192.168.1.11"), encap: true,
+				routes: []*netlink.Route{encapRoute("fd00:10:10:1::/64", "fd00:10:10:1::1")}},
+			flowsInstalled:       true,
+			expectedDeleted:      []string{"fd00:10:10:1::/64 via fd00:10:10:1::1"},
+			expectedDeletedNeigh: []string{"fd00:10:10:1::1"},
+		},
+		{
+			name:            "ipsec",
+			installed:       &nodeRouteInfo{nodeIP: net.ParseIP("192.168.1.11"), encap: true, tunOFPort: 20, routes: []*netlink.Route{encapRoute("10.10.1.0/24", "10.10.1.1")}},
+			flowsInstalled:  true,
+			tunnelPort:      true,
+			expectedDeleted: []string{"10.10.1.0/24 via 10.10.1.1"},
+		},
+		{
+			name:           "flows-only",
+			flowsInstalled: true,
+		},
+		{
+			name: "not-installed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := newFakeController(t, ctrl, config.TrafficEncapModeEncap)
+			for cidr, nodeName := range tt.peerPodCIDRs {
+				c.iptablesClient.peerPodCIDRs[cidr] = nodeName
+			}
+			if tt.installed != nil {
+				c.installedNodes.Store("node1", tt.installed)
+			} else if tt.flowsInstalled {
+				c.installedNodes.Store("node1", nil)
+			}
+			if tt.flowsInstalled {
+				c.ofClient.EXPECT().UninstallNodeFlows("node1")
+			}
+			if tt.tunnelPort {
+				portName := util.GenerateNodeTunnelInterfaceName("node1")
+				iface := interfacestore.NewIPSecTunnelInterface(portName, "node1", net.ParseIP("192.168.1.11"))
+				iface.OVSPortConfig = &interfacestore.OVSPortConfig{IfaceName: portName, PortUUID: "uuid1", OFPort: 20}
+				c.interfaceStore.AddInterface(portName, iface)
+				c.ovsClient.EXPECT().DeletePort("uuid1")
+			}
+			fakeNetlink := &fakeNetlink{}
+			defer fakeNetlink.install()()
+
+			require.Nil(t, c.deleteNodeRoute("node1"))
+			assert.Equal(t, tt.expectedDeleted, fakeNetlink.deletedRoutes)
+			assert.Equal(t, tt.expectedDeletedNeigh, fakeNetlink.deletedNeighs)
+			assert.Empty(t, c.iptablesClient.peerPodCIDRs)
+			_, ok := c.installedNodes.Load("node1")
+			assert.False(t, ok)
+			_, ok = c.interfaceStore.GetNodeTunnelInterface("node1")
+			assert.False(t, ok)
+		})
+	}
+}
+
+// TestSyncNodeRouteUpdate checks that the routes and flows to a Node are replaced when its IP and Pod CIDR change.
+func TestSyncNodeRouteUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	node1 := newNode("node1", "192.168.1.11", "10.10.1.0/24")
+	c := newFakeController(t, ctrl, config.TrafficEncapModeEncap, node1)
+	c.installedNodes.Store("node1", &nodeRouteInfo{nodeIP: net.ParseIP("192.168.1.11"), encap: true,
+		routes: []*netlink.Route{encapRoute("10.10.1.0/24", "10.10.1.1")}})
+	updatedNode1 := newNode("node1", "192.168.1.12", "10.10.2.0/24")
+	require.Nil(t, c.nodeInformer.Informer().GetStore().Update(updatedNode1))
+	// The keys of the peer configs are pointers, which gomock would compare by address.
+	peerConfigs := map[string]string{}
+	c.ofClient.EXPECT().InstallNodeFlows("node1", gatewayMAC, gomock.Any(), net.ParseIP("192.168.1.12"), uint32(0)).Times(1).Do(
+		func(_ string, _ net.HardwareAddr, configs map[*net.IPNet]net.IP, _ net.IP, _ uint32) {
+			for peerPodCIDR, peerGatewayIP := range configs {
+				peerConfigs[peerPodCIDR.String()] = peerGatewayIP.String()
+			}
+		})
+	fakeNetlink := &fakeNetlink{}
+	defer fakeNetlink.install()()
+
+	require.Nil(t, c.syncNodeRoute("node1"))
+	assert.Equal(t, map[string]string{"10.10.2.0/24": "10.10.2.1"}, peerConfigs)
+	assert.Empty(t, fakeNetlink.addedRoutes)
+	assert.Equal(t, []string{"10.10.2.0/24 via 10.10.2.1"}, fakeNetlink.replacedRoutes)
+	assert.Equal(t, []string{"10.10.1.0/24 via 10.10.1.1"}, fakeNetlink.deletedRoutes)
+	require.Len(t, c.recorder.Events, 1)
+	assert.Contains(t, <-c.recorder.Events, "NodeRouteUpdated")
+	info, ok := c.installedNodes.Load("node1")
+	require.True(t, ok)
+	assert.True(t, info.(*nodeRouteInfo).equal(&nodeRouteInfo{nodeIP: net.ParseIP("192.168.1.12"), encap: true,
+		routes: []*netlink.Route{encapRoute("10.10.2.0/24", "10.10.2.1")}}))
+}
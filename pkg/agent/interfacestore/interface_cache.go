@@ -33,6 +33,8 @@ import (
 // check previousResult with local cache.
 // Host gateway and tunnel interfaces are added into cache in node initialization phase or
 // retrieved from existing OVS ports
+// The IPSec tunnel interfaces of the peer Nodes are added into cache by the NodeRouteController,
+// their IfaceName is generated from the Node name.
 // Todo: add periodic task to sync local cache with container veth pair
 
 type interfaceCache struct {
@@ -60,6 +62,26 @@ func (c *interfaceCache) DeleteInterface(ifaceID string) {
 	delete(c.cache, ifaceID)
 }
 
+// SetInterfaceOFPort updates the ofport of the OVS port of an interface in local cache. The InterfaceConfig is
+// replaced rather than modified, as the callers of GetInterface read it without lock.
+func (c *interfaceCache) SetInterfaceOFPort(ifaceID string, ofPort int32) bool {
+	c.Lock()
+	defer c.Unlock()
+	iface, found := c.cache[ifaceID]
+	if !found || iface.OVSPortConfig == nil {
+		return false
+	}
+	if iface.OFPort == ofPort {
+		return true
+	}
+	newIface := *iface
+	newPortConfig := *iface.OVSPortConfig
+	newPortConfig.OFPort = ofPort
+	newIface.OVSPortConfig = &newPortConfig
+	c.cache[ifaceID] = &newIface
+	return true
+}
+
 // GetInterface retrieves interface from local cache
 func (c *interfaceCache) GetInterface(ifaceID string) (*InterfaceConfig, bool) {
 	c.RLock()
@@ -105,6 +127,16 @@ func (c *interfaceCache) GetContainerInterface(podName string, podNamespace stri
 	return iface, ok
 }
 
+// GetNodeTunnelInterface retrieves the tunnel interface created for the peer Node nodeName, e.g. its IPSec tunnel
+// interface.
+func (c *interfaceCache) GetNodeTunnelInterface(nodeName string) (*InterfaceConfig, bool) {
+	ovsPortName := util.GenerateNodeTunnelInterfaceName(nodeName)
+	c.RLock()
+	defer c.RUnlock()
+	iface, ok := c.cache[ovsPortName]
+	return iface, ok
+}
+
 func NewInterfaceStore() InterfaceStore {
 	return &interfaceCache{cache: map[string]*InterfaceConfig{}}
 }
@@ -0,0 +1,66 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interfacestore
+
+import (
+	"k8s.io/klog"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
+)
+
+// OFPortChangeHandler is called with the interfaces whose ofport was changed by the handler of the changes of the OVS
+// interfaces, so that their owner can update the flows which use the ofport, or create the interface again if it was
+// deleted from OVS and its ofport reset.
+type OFPortChangeHandler func(iface *InterfaceConfig)
+
+// NewOVSInterfaceEventHandler returns the handler of the changes of the OVS interfaces, which keeps the ofport of the
+// interfaces of store in sync with OVS and notifies ofPortChangeHandlers when it changes. The interfaces which are not
+// in store are ignored, they are added by the components which create them. The container interfaces are only created
+// by the CNI server when their Pod is created: a Pod whose interface was deleted from OVS must be created again.
+func NewOVSInterfaceEventHandler(store InterfaceStore, ofPortChangeHandlers ...OFPortChangeHandler) ovsconfig.InterfaceEventHandler {
+	setOFPort := func(ifaceName string, ofPort int32) bool {
+		iface, ok := store.GetInterface(ifaceName)
+		if !ok || iface.OVSPortConfig == nil || iface.OFPort == ofPort {
+			return false
+		}
+		if !store.SetInterfaceOFPort(ifaceName, ofPort) {
+			return false
+		}
+		if iface, ok = store.GetInterface(ifaceName); ok {
+			for _, handler := range ofPortChangeHandlers {
+				handler(iface)
+			}
+		}
+		return true
+	}
+	return func(event ovsconfig.InterfaceEvent) {
+		switch event.Type {
+		case ovsconfig.InterfaceAdded, ovsconfig.InterfaceUpdated:
+			// The ofport is unassigned until OVS creates the interface, and -1 if it failed to.
+			if event.OFPort <= 0 {
+				return
+			}
+			if setOFPort(event.Name, event.OFPort) {
+				klog.V(2).Infof("Updated ofport of interface %s to %d", event.Name, event.OFPort)
+			}
+		case ovsconfig.InterfaceDeleted:
+			// The ofport is reset, so that the interface is created again by the components which check it, e.g. the
+			// IPSec tunnel interfaces of the NodeRouteController.
+			if setOFPort(event.Name, 0) {
+				klog.Warningf("Interface %s was deleted from OVS", event.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interfacestore
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
+)
+
+// TestOVSInterfaceEventHandler checks that the ofport of the interfaces is kept in sync with OVS, and that the owners
+// of the interfaces are notified when it changes.
+func TestOVSInterfaceEventHandler(t *testing.T) {
+	store := NewInterfaceStore()
+	tunnelIface := NewIPSecTunnelInterface("node1-abcdef", "node1", net.ParseIP("192.168.1.11"))
+	tunnelIface.OVSPortConfig = &OVSPortConfig{IfaceName: "node1-abcdef", PortUUID: "uuid1", OFPort: 10}
+	store.AddInterface(tunnelIface.ID, tunnelIface)
+
+	var notified []*InterfaceConfig
+	handler := NewOVSInterfaceEventHandler(store, func(iface *InterfaceConfig) {
+		notified = append(notified, iface)
+	})
+
+	// The owner is not notified if the ofport did not change, or if the interface is not in the store.
+	handler(ovsconfig.InterfaceEvent{Type: ovsconfig.InterfaceUpdated, Name: "node1-abcdef", OFPort: 10})
+	handler(ovsconfig.InterfaceEvent{Type: ovsconfig.InterfaceDeleted, Name: "unknown"})
+	assert.Empty(t, notified)
+
+	handler(ovsconfig.InterfaceEvent{Type: ovsconfig.InterfaceDeleted, Name: "node1-abcdef"})
+	require.Len(t, notified, 1)
+	assert.Equal(t, "node1", notified[0].NodeName)
+	assert.Equal(t, int32(0), notified[0].OFPort)
+
+	// The ofport is unassigned until OVS creates the interface.
+	handler(ovsconfig.InterfaceEvent{Type: ovsconfig.InterfaceAdded, Name: "node1-abcdef", OFPort: 0})
+	require.Len(t, notified, 1)
+
+	handler(ovsconfig.InterfaceEvent{Type: ovsconfig.InterfaceAdded, Name: "node1-abcdef", OFPort: 11})
+	require.Len(t, notified, 2)
+	assert.Equal(t, int32(11), notified[1].OFPort)
+	iface, ok := store.GetInterface("node1-abcdef")
+	require.True(t, ok)
+	assert.Equal(t, int32(11), iface.OFPort)
+}
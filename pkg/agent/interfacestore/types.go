@@ -38,11 +38,17 @@ type OVSPortConfig struct {
 type InterfaceConfig struct {
 	ID           string
 	Type         InterfaceType
-	IP           net.IP
+	IPs          []net.IP
 	MAC          net.HardwareAddr
 	PodName      string
 	PodNamespace string
 	NetNS        string
+	// NodeName is the name of the peer Node of a tunnel interface created for this Node, e.g. an IPSec tunnel
+	// interface. IPs then holds the remote IP of the tunnel.
+	NodeName string
+	// IPSecRemoteName is the name of the peer Node in its certificate, for an IPSec tunnel interface whose IKE peers
+	// authenticate each other with certificates.
+	IPSecRemoteName string
 	*OVSPortConfig
 }
 
@@ -52,16 +58,20 @@ type InterfaceStore interface {
 	Initialize(interfaces []*InterfaceConfig)
 	AddInterface(ifaceID string, interfaceConfig *InterfaceConfig)
 	DeleteInterface(ifaceID string)
+	// SetInterfaceOFPort updates the ofport of the OVS port of an interface, and returns false if the interface
+	// does not exist or has no OVS port.
+	SetInterfaceOFPort(ifaceID string, ofPort int32) bool
 	GetInterface(ifaceID string) (*InterfaceConfig, bool)
 	GetContainerInterface(podName string, podNamespace string) (*InterfaceConfig, bool)
 	GetContainerInterfaceNum() int
+	GetNodeTunnelInterface(nodeName string) (*InterfaceConfig, bool)
 	Len() int
 	GetInterfaceIDs() []string
 }
 
 // NewContainerInterface creates container interface configuration
-func NewContainerInterface(containerID string, podName string, podNamespace string, containerNetNS string, mac net.HardwareAddr, ip net.IP) *InterfaceConfig {
-	containerConfig := &InterfaceConfig{ID: containerID, PodName: podName, PodNamespace: podNamespace, NetNS: containerNetNS, MAC: mac, IP: ip, Type: ContainerInterface}
+func NewContainerInterface(containerID string, podName string, podNamespace string, containerNetNS string, mac net.HardwareAddr, ips []net.IP) *InterfaceConfig {
+	containerConfig := &InterfaceConfig{ID: containerID, PodName: podName, PodNamespace: podNamespace, NetNS: containerNetNS, MAC: mac, IPs: ips, Type: ContainerInterface}
 	return containerConfig
 }
 
@@ -76,3 +86,9 @@ func NewTunnelInterface(tunnelName string) *InterfaceConfig {
 	tunnelConfig := &InterfaceConfig{ID: tunnelName, Type: TunnelInterface}
 	return tunnelConfig
 }
+
+// NewIPSecTunnelInterface creates the configuration of the IPSec tunnel port to the peer Node nodeName
+func NewIPSecTunnelInterface(tunnelName string, nodeName string, remoteIP net.IP) *InterfaceConfig {
+	tunnelConfig := &InterfaceConfig{ID: tunnelName, Type: TunnelInterface, NodeName: nodeName, IPs: []net.IP{remoteIP}}
+	return tunnelConfig
+}
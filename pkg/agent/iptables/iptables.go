@@ -15,10 +15,18 @@
 package iptables
 
 import (
+	"bytes"
 	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
 
 	"github.com/coreos/go-iptables/iptables"
 	"k8s.io/klog"
+
+	"github.com/vmware-tanzu/antrea/pkg/agent/config"
+	"github.com/vmware-tanzu/antrea/pkg/agent/types"
 )
 
 const (
@@ -28,11 +36,16 @@ const (
 	AcceptTarget     = "ACCEPT"
 	MasqueradeTarget = "MASQUERADE"
 	MarkTarget       = "MARK"
+	DNATTarget       = "DNAT"
+	ReturnTarget     = "RETURN"
 
 	ForwardChain           = "FORWARD"
+	PreRoutingChain        = "PREROUTING"
+	OutputChain            = "OUTPUT"
 	PostRoutingChain       = "POSTROUTING"
 	AntreaForwardChain     = "ANTREA-FORWARD"
 	AntreaPostRoutingChain = "ANTREA-POSTROUTING"
+	AntreaNodePortChain    = "ANTREA-NODEPORT"
 )
 
 var (
@@ -49,20 +62,40 @@ var (
 type Client struct {
 	ipt         *iptables.IPTables
 	hostGateway string
+	ipv6        bool
+	// networkConfig determines how the Pod traffic across Nodes is forwarded. When it is not encapsulated in the OVS
+	// tunnel, the traffic to the Pods of the other Nodes is routed by the host and must not be masqueraded.
+	networkConfig *config.NetworkConfig
+	// proxyAll indicates that the traffic of the NodePorts and of the LoadBalancer ingress IPs is steered to the
+	// host gateway, to be load balanced by OVS.
+	proxyAll bool
 }
 
-// NewClient constructs a Client instance for iptables operations.
-func NewClient(hostGateway string) (*Client, error) {
-	ipt, err := iptables.New()
+// NewClient constructs a Client instance for iptables operations. The rules are set up with ip6tables if ipv6 is
+// true.
+func NewClient(hostGateway string, ipv6 bool, networkConfig *config.NetworkConfig, proxyAll bool) (*Client, error) {
+	protocol := iptables.ProtocolIPv4
+	if ipv6 {
+		protocol = iptables.ProtocolIPv6
+	}
+	ipt, err := iptables.NewWithProtocol(protocol)
 	if err != nil {
 		return nil, fmt.Errorf("error creating IPTables instance: %v", err)
 	}
 	return &Client{
-		ipt:         ipt,
-		hostGateway: hostGateway,
+		ipt:           ipt,
+		hostGateway:   hostGateway,
+		ipv6:          ipv6,
+		networkConfig: networkConfig,
+		proxyAll:      proxyAll,
 	}, nil
 }
 
+// IsIPv6 returns true if the Client sets up the rules with ip6tables.
+func (c *Client) IsIPv6() bool {
+	return c.ipv6
+}
+
 // rule is a generic struct that describes an iptables rule.
 type rule struct {
 	// The table of this rule.
@@ -98,6 +131,23 @@ func (c *Client) SetupRules() error {
 		// Masquerade traffic requiring SNAT (has masqueradeMark set).
 		{NATTable, AntreaPostRoutingChain, []string{"-m", "mark", "--mark", masqueradeMark}, MasqueradeTarget, nil, "Antrea: masquerade traffic requiring SNAT"},
 	}
+	if c.networkConfig.TrafficEncapMode.SupportsNoEncap() || c.networkConfig.TrafficEncryptionMode == config.TrafficEncryptionModeWireGuard {
+		// Accept the traffic from the Pods of the other Nodes, which is routed to the host gateway interface.
+		rules = append(rules,
+			rule{FilterTable, AntreaForwardChain, []string{"!", "-i", c.hostGateway, "-o", c.hostGateway}, AcceptTarget, nil, "Antrea: accept external to pod traffic"})
+	}
+	if c.proxyAll {
+		virtualIP := types.NodePortVirtualIP.String()
+		rules = append(rules,
+			// Append ANTREA-NODEPORT chain which DNATs the traffic of the NodePorts and LoadBalancer ingress IPs to
+			// the NodePort virtual IP, to the PREROUTING chain for the traffic from other hosts and the Pods, and to
+			// the OUTPUT chain for the traffic of the local host, except the one sent to the loopback addresses.
+			rule{NATTable, PreRoutingChain, nil, AntreaNodePortChain, nil, "Antrea: jump to Antrea NodePort rules"},
+			rule{NATTable, OutputChain, []string{"!", "-d", "127.0.0.0/8"}, AntreaNodePortChain, nil, "Antrea: jump to Antrea NodePort rules"},
+			// Accept the traffic DNATed to the NodePort virtual IP, which is routed to the host gateway interface.
+			rule{FilterTable, AntreaForwardChain, []string{"-o", c.hostGateway, "-d", virtualIP}, AcceptTarget, nil, "Antrea: accept NodePort traffic"},
+		)
+	}
 
 	// Ensure all the chains involved exist.
 	for _, rule := range rules {
@@ -108,18 +158,165 @@ func (c *Client) SetupRules() error {
 
 	// Ensure all the rules exist.
 	for _, rule := range rules {
-		var ruleSpec []string
-		ruleSpec = append(ruleSpec, rule.parameters...)
-		ruleSpec = append(ruleSpec, "-j", rule.target)
-		ruleSpec = append(ruleSpec, rule.targetOptions...)
-		ruleSpec = append(ruleSpec, "-m", "comment", "--comment", rule.comment)
-		if err := c.ensureRule(rule.table, rule.chain, ruleSpec); err != nil {
+		if err := c.ensureRule(rule.table, rule.chain, rule.spec()); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// AddPeerPodCIDR makes the traffic from the local Pods to the Pod CIDR of a peer Node skip masquerading, so that the
+// Pod IPs are preserved when the traffic is routed to the Node by the host, without encapsulation or through the
+// WireGuard device. The rule is inserted at the top of the ANTREA-POSTROUTING chain, before the masquerading rule.
+// It's idempotent.
+func (c *Client) AddPeerPodCIDR(nodeName string, peerPodCIDR *net.IPNet) error {
+	r := peerPodCIDRRule(peerPodCIDR, peerPodCIDRComment(nodeName))
+	exist, err := c.ipt.Exists(r.table, r.chain, r.spec()...)
+	if err != nil {
+		return fmt.Errorf("error checking if rule %v exists in table %s chain %s: %v", r.spec(), r.table, r.chain, err)
+	}
+	if exist {
+		return nil
+	}
+	if err := c.ipt.Insert(r.table, r.chain, 1, r.spec()...); err != nil {
+		return fmt.Errorf("error inserting rule %v to table %s chain %s: %v", r.spec(), r.table, r.chain, err)
+	}
+	klog.V(2).Infof("Inserted rule %v to table %s chain %s", r.spec(), r.table, r.chain)
+	return nil
+}
+
+// DeletePeerPodCIDR deletes the rules added by AddPeerPodCIDR for the Pod CIDR. The rules are looked up by their
+// destination, so that they are found even if the name of the Node they were added for is not known anymore, e.g.
+// because the Node was deleted while the Agent was not running. It's idempotent.
+func (c *Client) DeletePeerPodCIDR(peerPodCIDR *net.IPNet) error {
+	ruleSpecs, err := c.ipt.List(NATTable, AntreaPostRoutingChain)
+	if err != nil {
+		return fmt.Errorf("error listing rules in table %s chain %s: %v", NATTable, AntreaPostRoutingChain, err)
+	}
+	for _, ruleSpec := range ruleSpecs {
+		comment, ok := parsePeerPodCIDRRule(ruleSpec, peerPodCIDR)
+		if !ok {
+			continue
+		}
+		r := peerPodCIDRRule(peerPodCIDR, comment)
+		if err := c.ipt.Delete(r.table, r.chain, r.spec()...); err != nil {
+			return fmt.Errorf("error deleting rule %v from table %s chain %s: %v", r.spec(), r.table, r.chain, err)
+		}
+		klog.V(2).Infof("Deleted rule %v from table %s chain %s", r.spec(), r.table, r.chain)
+	}
+	return nil
+}
+
+func peerPodCIDRRule(peerPodCIDR *net.IPNet, comment string) rule {
+	return rule{NATTable, AntreaPostRoutingChain, []string{"-d", peerPodCIDR.String()}, ReturnTarget, nil, comment}
+}
+
+func peerPodCIDRComment(nodeName string) string {
+	return fmt.Sprintf("Antrea: do not masquerade traffic to Node %s Pods", nodeName)
+}
+
+// parsePeerPodCIDRRule returns the comment of a rule listed by iptables -S, e.g.
+// `-A ANTREA-POSTROUTING -d 10.10.1.0/24 -m comment --comment "Antrea: do not masquerade traffic to Node node1 Pods" -j RETURN`,
+// if it is a rule added by AddPeerPodCIDR for the Pod CIDR.
+func parsePeerPodCIDRRule(ruleSpec string, peerPodCIDR *net.IPNet) (string, bool) {
+	const commentPrefix = "--comment \""
+	if !strings.HasPrefix(ruleSpec, fmt.Sprintf("-A %s -d %s ", AntreaPostRoutingChain, peerPodCIDR.String())) ||
+		!strings.HasSuffix(ruleSpec, " -j "+ReturnTarget) {
+		return "", false
+	}
+	start := strings.Index(ruleSpec, commentPrefix)
+	if start < 0 {
+		return "", false
+	}
+	comment := ruleSpec[start+len(commentPrefix):]
+	end := strings.Index(comment, "\"")
+	if end < 0 {
+		return "", false
+	}
+	return comment[:end], true
+}
+
+// spec returns the rule specification of the rule.
+func (r *rule) spec() []string {
+	var ruleSpec []string
+	ruleSpec = append(ruleSpec, r.parameters...)
+	ruleSpec = append(ruleSpec, "-j", r.target)
+	ruleSpec = append(ruleSpec, r.targetOptions...)
+	ruleSpec = append(ruleSpec, "-m", "comment", "--comment", r.comment)
+	return ruleSpec
+}
+
+// SyncExternalPorts replaces the rules of the ANTREA-NODEPORT chain with the rules steering the traffic of the
+// ExternalPorts to the host gateway: the traffic is DNATed to the NodePort virtual IP and the NodePort, and marked
+// for masquerading if required. The chain is replaced atomically. It can only be called if the Client was created
+// with proxyAll.
+func (c *Client) SyncExternalPorts(ports []*types.ExternalPort) error {
+	if !c.proxyAll {
+		return fmt.Errorf("the external ports can only be synced when proxyAll is enabled")
+	}
+	var rules []rule
+	for _, port := range ports {
+		rules = append(rules, externalPortRules(port)...)
+	}
+	if err := c.restoreChain(NATTable, AntreaNodePortChain, rules); err != nil {
+		return err
+	}
+	klog.V(2).Infof("Synced %d rules of %d external ports to table %s chain %s", len(rules), len(ports), NATTable, AntreaNodePortChain)
+	return nil
+}
+
+// restoreChain replaces the rules of the chain with rules in a single iptables-restore call, so that the traffic
+// never goes through a partially written chain. With --noflush, only the chain declared in the input is flushed,
+// the other chains of the table are kept.
+func (c *Client) restoreChain(table string, chain string, rules []rule) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%s\n", table)
+	fmt.Fprintf(&buf, ":%s - [0:0]\n", chain)
+	for _, r := range rules {
+		args := []string{"-A", chain}
+		for _, arg := range r.spec() {
+			// The comments have spaces, iptables-restore splits the arguments on the spaces which are not quoted.
+			if strings.ContainsAny(arg, " \"") {
+				arg = strconv.Quote(arg)
+			}
+			args = append(args, arg)
+		}
+		fmt.Fprintf(&buf, "%s\n", strings.Join(args, " "))
+	}
+	buf.WriteString("COMMIT\n")
+	restoreCommand := "iptables-restore"
+	if c.ipv6 {
+		restoreCommand = "ip6tables-restore"
+	}
+	cmd := exec.Command(restoreCommand, "--noflush")
+	cmd.Stdin = &buf
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error restoring chain %s in table %s: %v (%q)", chain, table, err, output)
+	}
+	return nil
+}
+
+// externalPortRules returns the rules of the ANTREA-NODEPORT chain for the ExternalPort. A NodePort matches all the
+// local addresses, and keeps its port when it is DNATed. A LoadBalancer ingress IP is DNATed to the NodePort.
+func externalPortRules(port *types.ExternalPort) []rule {
+	protocol := strings.ToLower(string(port.Protocol))
+	var parameters []string
+	destination := types.NodePortVirtualIP.String()
+	if port.IP == nil {
+		parameters = []string{"-p", protocol, "-m", "addrtype", "--dst-type", "LOCAL", "-m", protocol, "--dport", fmt.Sprint(port.Port)}
+	} else {
+		parameters = []string{"-d", port.IP.String() + "/32", "-p", protocol, "-m", protocol, "--dport", fmt.Sprint(port.Port)}
+		destination = fmt.Sprintf("%s:%d", destination, port.NodePort)
+	}
+	comment := fmt.Sprintf("Antrea: steer external port %s", port.String())
+	var rules []rule
+	if port.Masquerade {
+		rules = append(rules, rule{NATTable, AntreaNodePortChain, parameters, MarkTarget, []string{"--set-xmark", masqueradeMark}, comment})
+	}
+	rules = append(rules, rule{NATTable, AntreaNodePortChain, parameters, DNATTarget, []string{"--to-destination", destination}, comment})
+	return rules
+}
+
 // ensureChain checks if target chain already exists, creates it if not.
 func (c *Client) ensureChain(table string, chain string) error {
 	oriChains, err := c.ipt.ListChains(table)
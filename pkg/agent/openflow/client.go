@@ -18,46 +18,98 @@ import (
 	"fmt"
 	"net"
 
+	"k8s.io/klog"
+
+	"github.com/vmware-tanzu/antrea/pkg/agent/openflow/cookie"
 	"github.com/vmware-tanzu/antrea/pkg/agent/types"
 	binding "github.com/vmware-tanzu/antrea/pkg/ovs/openflow"
 )
 
 const maxRetryForOFSwitch = 5
 
+// Keys of the flows of the gateway and tunnel ports in defaultFlowCache.
+const (
+	gatewayFlowCacheKey = "gateway"
+	tunnelFlowCacheKey  = "tunnel"
+)
+
 //go:generate mockgen -copyright_file ../../../hack/boilerplate/license_header.raw.txt -destination testing/mock_client.go -package=testing github.com/vmware-tanzu/antrea/pkg/agent/openflow Client
 
 // Client is the interface to program OVS flows for entity connectivity of Antrea.
-// TODO: flow sync (e.g. at agent restart), retry at failure, garbage collection mechanisms
 type Client interface {
-	// Initialize sets up all basic flows on the specific OVS bridge.
-	Initialize() error
-
-	// InstallGatewayFlows sets up flows related to an OVS gateway port, the gateway must exist.
-	InstallGatewayFlows(gatewayAddr net.IP, gatewayMAC net.HardwareAddr, gatewayOFPort uint32) error
+	// Initialize sets up all basic flows on the specific OVS bridge. roundNum is the round number of the Agent, which
+	// is set in the cookie of all the flows installed by the Client, so that the flows installed by a previous Agent
+	// can be told apart and deleted with DeleteStaleFlows. The returned channel is notified when the connection to
+	// the OFSwitch is re-established after it was lost, e.g. because ovs-vswitchd restarted: ReplayFlows should then
+	// be called, as the OFSwitch may have lost all the flows. The IP families of the Pod networking, IPv4, IPv6 or
+	// both, are the ones of the Pod CIDRs of nodeConfig.
+	Initialize(roundNum uint64, nodeConfig *types.NodeConfig) (<-chan struct{}, error)
+
+	// ReplayFlows installs again all the flows installed so far by the Client: the basic flows, the flows of the
+	// gateway, the tunnel and the Cluster Service CIDR, the flows of all the local Pods and remote Nodes, and the
+	// flows of all the realized NetworkPolicy rules.
+	ReplayFlows() error
+
+	// DeleteStaleFlows deletes all the flows installed in another round than the one passed to Initialize, and all
+	// the groups which were not installed by the Client. It should only be called once the desired state has been
+	// replayed, i.e. when all the flows which are still needed have been installed again with the current round
+	// number and all the groups which are still needed have been installed, otherwise the traffic would be disrupted.
+	DeleteStaleFlows() error
+
+	// InstallGatewayFlows sets up flows related to an OVS gateway port, the gateway must exist. gatewayAddrs has one
+	// address per IP family of the Pod network.
+	InstallGatewayFlows(gatewayAddrs []net.IP, gatewayMAC net.HardwareAddr, gatewayOFPort uint32) error
 
 	// InstallClusterServiceCIDRFlows sets up the appropriate flows so that traffic can reach
 	// the different Services running in the Cluster. This method needs to be invoked once with
 	// the Cluster Service CIDR as a parameter.
 	InstallClusterServiceCIDRFlows(serviceNet *net.IPNet, gatewayOFPort uint32) error
 
+	// InstallServiceFlows sets up the flows and the group which load balance the connections to the Service port
+	// across the endpoints. If affinityTimeout is not 0, the connections of a client are sent to the same endpoint
+	// until affinityTimeout seconds have passed since its first connection. It can only be called if the Client was
+	// created with enableProxy. Calls to InstallServiceFlows are idempotent, and replace the endpoints of the Service
+	// port if it was installed already. The groupID must be unique to the Service port.
+	InstallServiceFlows(groupID binding.GroupIDType, svcPort *types.ServicePort, endpoints []*types.Endpoint, affinityTimeout uint16) error
+
+	// UninstallServiceFlows removes the flows and the group installed for the Service port by InstallServiceFlows.
+	UninstallServiceFlows(groupID binding.GroupIDType, svcPort *types.ServicePort) error
+
+	// InstallNodePortFlows sets up the flow which load balances the connections that the host DNATed to the NodePort
+	// virtual IP and sent to the gateway. The Service ports of the NodePorts are installed with InstallServiceFlows, on
+	// the virtual IP. It can only be called if the Client was created with enableProxy.
+	InstallNodePortFlows(virtualIP net.IP) error
+
 	// InstallTunnelFlows sets up flows related to an OVS tunnel port, the tunnel port must exist.
 	InstallTunnelFlows(tunnelOFPort uint32) error
 
 	// InstallNodeFlows should be invoked when a connection to a remote Node is going to be set
-	// up. The hostname is used to identify the added flows. Calls to InstallNodeFlows are
-	// idempotent. Concurrent calls to InstallNodeFlows and / or UninstallNodeFlows are
-	// supported as long as they are all for different hostnames.
-	InstallNodeFlows(hostname string, localGatewayMAC net.HardwareAddr, peerGatewayIP net.IP, peerPodCIDR net.IPNet, tunnelPeerAddr net.IP) error
+	// up. The hostname is used to identify the added flows. peerConfigs maps each Pod CIDR of
+	// the remote Node to the IP of its gateway in this CIDR. The traffic to the remote Node is
+	// encapsulated in a tunnel to tunnelPeerAddr. If tunOFPort is not 0, the traffic is output
+	// to this tunnel port, e.g. the IPSec tunnel port of the remote Node, instead of the flow
+	// based tunnel port. If flows are installed for the hostname already, they are replaced
+	// atomically with the new ones. Calls to InstallNodeFlows are idempotent. Concurrent calls
+	// to InstallNodeFlows and / or UninstallNodeFlows are supported as long as they are all for
+	// different hostnames.
+	InstallNodeFlows(hostname string, localGatewayMAC net.HardwareAddr, peerConfigs map[*net.IPNet]net.IP, tunnelPeerAddr net.IP, tunOFPort uint32) error
+
+	// InstallNoEncapNodeFlows is the counterpart of InstallNodeFlows for a remote Node to which
+	// the traffic is not encapsulated: the traffic to the Pod CIDRs of the Node is sent to the
+	// local gateway, to be routed by the host. The flows are removed with UninstallNodeFlows.
+	// The same concurrency constraints as InstallNodeFlows apply.
+	InstallNoEncapNodeFlows(hostname string, localGatewayMAC net.HardwareAddr, peerPodCIDRs []*net.IPNet) error
 
 	// UninstallNodeFlows removes the connection to the remote Node specified with the
 	// hostname. UninstallNodeFlows will do nothing if no connection to the host was established.
 	UninstallNodeFlows(hostname string) error
 
 	// InstallPodFlows should be invoked when a connection to a Pod on current Node. The
-	// containerID is used to identify the added flows. Calls to InstallPodFlows are
-	// idempotent. Concurrent calls to InstallPodFlows and / or UninstallPodFlows are
-	// supported as long as they are all for different containerIDs.
-	InstallPodFlows(containerID string, podInterfaceIP net.IP, podInterfaceMAC, gatewayMAC net.HardwareAddr, ofPort uint32) error
+	// containerID is used to identify the added flows. podInterfaceIPs has one IP per IP
+	// family of the Pod network. Calls to InstallPodFlows are idempotent. Concurrent calls
+	// to InstallPodFlows and / or UninstallPodFlows are supported as long as they are all
+	// for different containerIDs.
+	InstallPodFlows(containerID string, podInterfaceIPs []net.IP, podInterfaceMAC, gatewayMAC net.HardwareAddr, ofPort uint32) error
 
 	// UninstallPodFlows removes the connection to the local Pod specified with the
 	// containerID. UninstallPodFlows will do nothing if no connection to the Pod was established.
@@ -88,34 +140,80 @@ type Client interface {
 	Disconnect() error
 }
 
-// GetFlowTableStatus returns an array of flow table status.
+// GetFlowTableStatus returns an array of flow table status. The flow counts are read from the OFSwitch, so they also
+// include the flows which were not installed by this client.
 func (c *client) GetFlowTableStatus() []binding.TableStatus {
 	return c.bridge.DumpTableStatus()
 }
 
-// addMissingFlows adds any flow from flows which is not currently in the flow cache. The function
-// returns immediately in case of error when adding a flow. If a flow is added successfully, it is
-// added to the flow cache. If the flow cache has not been initialized yet (i.e. there is no
-// flowCacheKey key in the cache map), we create it first.
+// addMissingFlows adds any flow from flows which is not currently in the flow cache. The missing
+// flows are added in a single bundle: if the bundle fails, none of them is added and the flow
+// cache is left unchanged, otherwise they are all added to the flow cache. If the flow cache has
+// not been initialized yet (i.e. there is no flowCacheKey key in the cache map), we create it first.
 func (c *client) addMissingFlows(cache *flowCategoryCache, flowCacheKey string, flows []binding.Flow) error {
 	// initialize flow cache if needed
 	fCacheI, _ := cache.LoadOrStore(flowCacheKey, flowCache{})
 	fCache := fCacheI.(flowCache)
 
+	var missingFlows []binding.Flow
 	for _, flow := range flows {
-		flowKey := flow.MatchString()
-		if _, ok := fCache[flowKey]; ok {
+		if _, ok := fCache[flow.MatchString()]; ok {
 			continue
 		}
-		if err := c.flowOperations.Add(flow); err != nil {
-			return err
-		}
+		missingFlows = append(missingFlows, flow)
+	}
+	if len(missingFlows) == 0 {
+		return nil
+	}
+	if err := c.flowOperations.AddAll(missingFlows); err != nil {
+		return err
+	}
+	for _, flow := range missingFlows {
 		fCache[flow.MatchString()] = flow
 	}
 	return nil
 }
 
-// deleteFlows deletes all the flows in the flow cache indexed by the provided flowCacheKey.
+// replaceFlows replaces the flows in the flow cache indexed by the provided flowCacheKey with flows: the
+// new flows are added, the flows whose actions changed are modified and the flows which are not in
+// flows anymore are deleted, in a single bundle. The flow cache is updated only if the bundle
+// succeeds. If no flow is cached for flowCacheKey, e.g. because the flows failed to be installed,
+// it is equivalent to addMissingFlows.
+func (c *client) replaceFlows(cache *flowCategoryCache, flowCacheKey string, flows []binding.Flow) error {
+	fCacheI, ok := cache.Load(flowCacheKey)
+	if !ok || len(fCacheI.(flowCache)) == 0 {
+		return c.addMissingFlows(cache, flowCacheKey, flows)
+	}
+	fCache := fCacheI.(flowCache)
+
+	var addFlows, modFlows, delFlows []binding.Flow
+	desired := make(map[string]bool, len(flows))
+	for _, flow := range flows {
+		key := flow.MatchString()
+		desired[key] = true
+		if cachedFlow, ok := fCache[key]; !ok {
+			addFlows = append(addFlows, flow)
+		} else if cachedFlow.String() != flow.String() {
+			modFlows = append(modFlows, flow)
+		}
+	}
+	for key, flow := range fCache {
+		if !desired[key] {
+			delFlows = append(delFlows, flow)
+		}
+	}
+	if len(addFlows) == 0 && len(modFlows) == 0 && len(delFlows) == 0 {
+		return nil
+	}
+	if err := c.flowOperations.BundleOps(addFlows, modFlows, delFlows); err != nil {
+		return err
+	}
+	cacheFlows(cache, flowCacheKey, flows)
+	return nil
+}
+
+// deleteFlows deletes all the flows in the flow cache indexed by the provided flowCacheKey. The
+// flows are deleted in a single bundle, the flow cache is removed only if the bundle succeeds.
 func (c *client) deleteFlows(cache *flowCategoryCache, flowCacheKey string) error {
 	fCacheI, ok := cache.Load(flowCacheKey)
 	if !ok {
@@ -124,95 +222,302 @@ func (c *client) deleteFlows(cache *flowCategoryCache, flowCacheKey string) erro
 	}
 	fCache := fCacheI.(flowCache)
 
-	// delete flowCache from the top-level cache if all flows were successfully deleted
-	defer func() {
-		if len(fCache) == 0 {
-			cache.Delete(flowCacheKey)
-		}
-	}()
+	var flows []binding.Flow
+	for _, flow := range fCache {
+		flows = append(flows, flow)
+	}
+	if err := c.flowOperations.DeleteAll(flows); err != nil {
+		return err
+	}
+	cache.Delete(flowCacheKey)
+	return nil
+}
 
-	for flowKey, flow := range fCache {
-		if err := c.flowOperations.Delete(flow); err != nil {
-			return err
+func (c *client) InstallNodeFlows(hostname string, localGatewayMAC net.HardwareAddr, peerConfigs map[*net.IPNet]net.IP, tunnelPeerAddr net.IP, tunOFPort uint32) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
+	var flows []binding.Flow
+	if tunOFPort != 0 {
+		// The traffic received from the tunnel port of the Node is classified like the one of the flow based tunnel.
+		flows = append(flows, c.tunnelClassifierFlow(tunOFPort))
+	}
+	for peerPodCIDR, peerGatewayIP := range peerConfigs {
+		flows = append(flows, c.l3FwdFlowToRemote(localGatewayMAC, *peerPodCIDR, tunnelPeerAddr, tunOFPort))
+		// The IPv6 peer gateways are resolved with permanent neighbor entries set by the NodeRouteController.
+		if peerGatewayIP.To4() != nil {
+			flows = append(flows, c.arpResponderFlow(peerGatewayIP))
 		}
-		delete(fCache, flowKey)
 	}
-	return nil
+
+	return c.replaceFlows(c.nodeFlowCache, hostname, flows)
 }
 
-func (c *client) InstallNodeFlows(hostname string, localGatewayMAC net.HardwareAddr, peerGatewayIP net.IP, peerPodCIDR net.IPNet, tunnelPeerAddr net.IP) error {
-	flows := []binding.Flow{
-		c.arpResponderFlow(peerGatewayIP),
-		c.l3FwdFlowToRemote(localGatewayMAC, peerPodCIDR, tunnelPeerAddr),
+func (c *client) InstallNoEncapNodeFlows(hostname string, localGatewayMAC net.HardwareAddr, peerPodCIDRs []*net.IPNet) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
+	var flows []binding.Flow
+	for _, peerPodCIDR := range peerPodCIDRs {
+		flows = append(flows, c.l3FwdFlowToRemoteViaGW(localGatewayMAC, *peerPodCIDR))
 	}
 
-	return c.addMissingFlows(c.nodeFlowCache, hostname, flows)
+	return c.replaceFlows(c.nodeFlowCache, hostname, flows)
 }
 
 func (c *client) UninstallNodeFlows(hostname string) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
 	return c.deleteFlows(c.nodeFlowCache, hostname)
 }
 
-func (c *client) InstallPodFlows(containerID string, podInterfaceIP net.IP, podInterfaceMAC, gatewayMAC net.HardwareAddr, ofPort uint32) error {
+func (c *client) InstallPodFlows(containerID string, podInterfaceIPs []net.IP, podInterfaceMAC, gatewayMAC net.HardwareAddr, ofPort uint32) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
 	flows := []binding.Flow{
 		c.podClassifierFlow(ofPort),
-		c.podIPSpoofGuardFlow(podInterfaceIP, podInterfaceMAC, ofPort),
-		c.arpSpoofGuardFlow(podInterfaceIP, podInterfaceMAC, ofPort),
-		c.l2ForwardCalcFlow(podInterfaceMAC, ofPort),
-		c.l3FlowsToPod(gatewayMAC, podInterfaceIP, podInterfaceMAC),
+		c.l2ForwardCalcFlow(podInterfaceMAC, ofPort, cookie.Pod),
+	}
+	for _, podInterfaceIP := range podInterfaceIPs {
+		flows = append(flows,
+			c.podIPSpoofGuardFlow(podInterfaceIP, podInterfaceMAC, ofPort),
+			c.l3FlowsToPod(gatewayMAC, podInterfaceIP, podInterfaceMAC),
+		)
+		if podInterfaceIP.To4() != nil {
+			flows = append(flows, c.arpSpoofGuardFlow(podInterfaceIP, podInterfaceMAC, ofPort))
+		} else {
+			flows = append(flows, c.ndSpoofGuardFlows(podInterfaceIP, podInterfaceMAC, ofPort)...)
+		}
 	}
 
 	return c.addMissingFlows(c.podFlowCache, containerID, flows)
 }
 
 func (c *client) UninstallPodFlows(containerID string) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
 	return c.deleteFlows(c.podFlowCache, containerID)
 }
 
 func (c *client) InstallClusterServiceCIDRFlows(serviceNet *net.IPNet, gatewayOFPort uint32) error {
-	return c.flowOperations.Add(c.serviceCIDRDNATFlow(serviceNet, gatewayOFPort))
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
+	var flow binding.Flow
+	if c.enableProxy {
+		flow = c.serviceCIDRLBFlow(serviceNet)
+	} else {
+		flow = c.serviceCIDRDNATFlow(serviceNet, gatewayOFPort)
+	}
+	if err := c.flowOperations.Add(flow); err != nil {
+		return err
+	}
+	cacheFlows(c.serviceCache, serviceNet.String(), []binding.Flow{flow})
+	return nil
 }
 
-func (c *client) InstallGatewayFlows(gatewayAddr net.IP, gatewayMAC net.HardwareAddr, gatewayOFPort uint32) error {
-	if err := c.flowOperations.Add(c.gatewayClassifierFlow(gatewayOFPort)); err != nil {
+func (c *client) InstallNodePortFlows(virtualIP net.IP) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
+	if !c.enableProxy {
+		return fmt.Errorf("the NodePort flows can only be installed when the proxy is enabled")
+	}
+	flow := c.nodePortLBFlow(virtualIP)
+	if err := c.flowOperations.Add(flow); err != nil {
 		return err
-	} else if err := c.flowOperations.Add(c.gatewayIPSpoofGuardFlow(gatewayOFPort)); err != nil {
+	}
+	cacheFlows(c.serviceCache, virtualIP.String(), []binding.Flow{flow})
+	return nil
+}
+
+func (c *client) InstallServiceFlows(groupID binding.GroupIDType, svcPort *types.ServicePort, endpoints []*types.Endpoint, affinityTimeout uint16) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
+	if !c.enableProxy {
+		return fmt.Errorf("the Service flows can only be installed when the proxy is enabled")
+	}
+	flowCacheKey := svcPort.String()
+	fCacheI, _ := c.serviceCache.LoadOrStore(flowCacheKey, flowCache{})
+	fCache := fCacheI.(flowCache)
+
+	var epFlows, newEPFlows []binding.Flow
+	for _, endpoint := range endpoints {
+		flow := c.endpointDNATFlow(svcPort, endpoint)
+		epFlows = append(epFlows, flow)
+		if _, ok := fCache[flow.MatchString()]; !ok {
+			newEPFlows = append(newEPFlows, flow)
+		}
+	}
+	// The flows of the new endpoints are installed before the group can select them.
+	if len(newEPFlows) > 0 {
+		if err := c.flowOperations.AddAll(newEPFlows); err != nil {
+			return err
+		}
+		for _, flow := range newEPFlows {
+			fCache[flow.MatchString()] = flow
+		}
+	}
+
+	group := c.serviceGroup(groupID, endpoints, affinityTimeout != 0)
+	if err := c.installGroup(group); err != nil {
+		return err
+	}
+
+	lbFlows := []binding.Flow{c.serviceLBFlow(groupID, svcPort)}
+	if affinityTimeout != 0 {
+		lbFlows = append(lbFlows, c.serviceLearnFlow(svcPort, affinityTimeout))
+	}
+	var addFlows, modFlows, delFlows []binding.Flow
+	for _, flow := range lbFlows {
+		if _, ok := fCache[flow.MatchString()]; ok {
+			modFlows = append(modFlows, flow)
+		} else {
+			addFlows = append(addFlows, flow)
+		}
+	}
+	// The flows of the removed endpoints, and the learn flow if the session affinity was disabled, are deleted
+	// once the group doesn't select the endpoints anymore.
+	flows := append(epFlows, lbFlows...)
+	desired := map[string]bool{}
+	for _, flow := range flows {
+		desired[flow.MatchString()] = true
+	}
+	for key, flow := range fCache {
+		if !desired[key] {
+			delFlows = append(delFlows, flow)
+		}
+	}
+	if err := c.flowOperations.BundleOps(addFlows, modFlows, delFlows); err != nil {
+		return err
+	}
+	cacheFlows(c.serviceCache, flowCacheKey, flows)
+	return nil
+}
+
+// installGroup installs the group, or replaces the buckets of the group if it is installed already.
+func (c *client) installGroup(group binding.Group) error {
+	if _, ok := c.groupCache.Load(group.GetID()); ok {
+		if err := group.Modify(); err != nil {
+			return err
+		}
+	} else if err := addGroup(group); err != nil {
 		return err
-	} else if err := c.flowOperations.Add(c.gatewayARPSpoofGuardFlow(gatewayOFPort)); err != nil {
+	}
+	c.groupCache.Store(group.GetID(), group)
+	return nil
+}
+
+// addGroup adds the group, or modifies it if the OFSwitch has a group with the same ID already, e.g. because it was
+// installed by a previous Agent.
+func addGroup(group binding.Group) error {
+	if err := group.Add(); err != nil {
+		if modErr := group.Modify(); modErr != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *client) UninstallServiceFlows(groupID binding.GroupIDType, svcPort *types.ServicePort) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
+	if err := c.deleteFlows(c.serviceCache, svcPort.String()); err != nil {
 		return err
-	} else if err := c.flowOperations.Add(c.l3ToGatewayFlow(gatewayAddr, gatewayMAC)); err != nil {
+	}
+	groupI, ok := c.groupCache.Load(groupID)
+	if !ok {
+		return nil
+	}
+	if err := groupI.(binding.Group).Delete(); err != nil {
 		return err
-	} else if err := c.flowOperations.Add(c.l2ForwardCalcFlow(gatewayMAC, gatewayOFPort)); err != nil {
+	}
+	c.groupCache.Delete(groupID)
+	return nil
+}
+
+func (c *client) InstallGatewayFlows(gatewayAddrs []net.IP, gatewayMAC net.HardwareAddr, gatewayOFPort uint32) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
+	flows := []binding.Flow{
+		c.gatewayClassifierFlow(gatewayOFPort),
+		c.l2ForwardCalcFlow(gatewayMAC, gatewayOFPort, cookie.Gateway),
+	}
+	flows = append(flows, c.gatewayIPSpoofGuardFlows(gatewayOFPort)...)
+	for _, gatewayAddr := range gatewayAddrs {
+		flows = append(flows, c.l3ToGatewayFlow(gatewayAddr, gatewayMAC))
+	}
+	if c.ipv4 {
+		flows = append(flows, c.gatewayARPSpoofGuardFlow(gatewayOFPort))
+	}
+	if c.ipv6 {
+		flows = append(flows, c.gatewayNDSpoofGuardFlows(gatewayOFPort)...)
+	}
+	if err := c.flowOperations.AddAll(flows); err != nil {
 		return err
 	}
+	cacheFlows(c.defaultFlowCache, gatewayFlowCacheKey, flows)
 	return nil
 }
 
 func (c *client) InstallTunnelFlows(tunnelOFPort uint32) error {
-	if err := c.flowOperations.Add(c.tunnelClassifierFlow(tunnelOFPort)); err != nil {
-		return err
-	} else if err := c.flowOperations.Add(c.l2ForwardCalcFlow(globalVirtualMAC, tunnelOFPort)); err != nil {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
+	flows := []binding.Flow{
+		c.tunnelClassifierFlow(tunnelOFPort),
+		c.l2ForwardCalcFlow(GlobalVirtualMAC, tunnelOFPort, cookie.Default),
+	}
+	if err := c.flowOperations.AddAll(flows); err != nil {
 		return err
 	}
+	cacheFlows(c.defaultFlowCache, tunnelFlowCacheKey, flows)
 	return nil
 }
 
-func (c *client) Initialize() error {
+func (c *client) Initialize(roundNum uint64, nodeConfig *types.NodeConfig) (<-chan struct{}, error) {
+	c.ipv4 = nodeConfig.PodIPv4CIDR != nil
+	c.ipv6 = nodeConfig.PodIPv6CIDR != nil
+	if c.ipv6 && c.enableProxy {
+		return nil, fmt.Errorf("the Antrea proxy does not support IPv6")
+	}
+	c.cookieAllocator = cookie.NewAllocator(roundNum)
 	// Initiate connections to target OFswitch, and create tables on the switch.
-	if err := c.bridge.Connect(maxRetryForOFSwitch); err != nil {
-		return err
+	connectionCh := make(chan struct{}, 1)
+	if err := c.bridge.Connect(maxRetryForOFSwitch, connectionCh); err != nil {
+		return nil, err
+	}
+	if err := c.installDefaultFlows(); err != nil {
+		return nil, err
 	}
+	return connectionCh, nil
+}
 
+// installDefaultFlows installs the basic flows of the pipeline, which don't depend on any entity.
+func (c *client) installDefaultFlows() error {
 	for _, flow := range c.defaultFlows() {
 		if err := c.flowOperations.Add(flow); err != nil {
 			return fmt.Errorf("failed to install default flows: %v", err)
 		}
 	}
-	if err := c.flowOperations.Add(c.arpNormalFlow()); err != nil {
-		return fmt.Errorf("failed to install arp normal flow: %v", err)
+	if c.ipv4 {
+		if err := c.flowOperations.Add(c.arpNormalFlow()); err != nil {
+			return fmt.Errorf("failed to install arp normal flow: %v", err)
+		}
+	}
+	if c.ipv6 {
+		for _, flow := range append(c.ndDropFlows(), c.ndNormalFlow()) {
+			if err := c.flowOperations.Add(flow); err != nil {
+				return fmt.Errorf("failed to install Neighbor Discovery flows: %v", err)
+			}
+		}
+	}
+	for _, flow := range c.l2ForwardOutputFlows() {
+		if err := c.flowOperations.Add(flow); err != nil {
+			return fmt.Errorf("failed to install l2 forward output flows: %v", err)
+		}
 	}
-	if err := c.flowOperations.Add(c.l2ForwardOutputFlow()); err != nil {
-		return fmt.Errorf("failed to install l2 forward output flows: %v", err)
+	if c.enableProxy {
+		for _, flow := range c.serviceLBDefaultFlows() {
+			if err := c.flowOperations.Add(flow); err != nil {
+				return fmt.Errorf("failed to install Service load balancing flows: %v", err)
+			}
+		}
 	}
 	for _, flow := range c.connectionTrackFlows() {
 		if err := c.flowOperations.Add(flow); err != nil {
@@ -220,9 +525,125 @@ func (c *client) Initialize() error {
 		}
 	}
 	for _, flow := range c.establishedConnectionFlows() {
-		if err := flow.Add(); err != nil {
+		if err := c.flowOperations.Add(flow); err != nil {
 			return fmt.Errorf("failed to install flows to skip established connections: %v", err)
 		}
 	}
 	return nil
 }
+
+func (c *client) ReplayFlows() error {
+	c.replayMutex.Lock()
+	defer c.replayMutex.Unlock()
+
+	if err := c.installDefaultFlows(); err != nil {
+		return err
+	}
+	// The groups of the Services must be installed before the flows which use them.
+	if err := c.replayGroups(); err != nil {
+		return err
+	}
+	// The Pod flows cached in podFlowCache are the ones of the interfaces in the InterfaceStore, and the Node flows
+	// cached in nodeFlowCache the ones of the Nodes processed by the NodeRouteController.
+	for _, cache := range []*flowCategoryCache{c.defaultFlowCache, c.serviceCache, c.podFlowCache, c.nodeFlowCache} {
+		if err := c.replayFlowCache(cache); err != nil {
+			return err
+		}
+	}
+	if err := c.replayPolicyFlows(); err != nil {
+		return fmt.Errorf("failed to replay NetworkPolicy flows: %v", err)
+	}
+	return nil
+}
+
+// replayFlowCache installs again the flows of all the entries of the cache, in one bundle per entry. The entries
+// which fail are logged and skipped, and the first error is returned once all the entries have been processed.
+func (c *client) replayFlowCache(cache *flowCategoryCache) error {
+	var firstErr error
+	cache.Range(func(key, value interface{}) bool {
+		fCache := value.(flowCache)
+		flows := make([]binding.Flow, 0, len(fCache))
+		for _, flow := range fCache {
+			flows = append(flows, flow)
+		}
+		if err := c.flowOperations.AddAll(flows); err != nil {
+			klog.Errorf("Failed to replay flows of %s: %v", key, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to replay flows of %s: %v", key, err)
+			}
+		}
+		return true
+	})
+	return firstErr
+}
+
+// replayGroups installs again all the groups in groupCache. The groups which fail are logged and skipped, and the
+// first error is returned once all the groups have been processed.
+func (c *client) replayGroups() error {
+	var firstErr error
+	c.groupCache.Range(func(id, value interface{}) bool {
+		group := value.(binding.Group)
+		if err := addGroup(group); err != nil {
+			klog.Errorf("Failed to replay group %d: %v", id, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to replay group %d: %v", id, err)
+			}
+		}
+		return true
+	})
+	return firstErr
+}
+
+// cacheFlows replaces the flows cached for flowCacheKey with flows, after they have been installed.
+func cacheFlows(cache *flowCategoryCache, flowCacheKey string, flows []binding.Flow) {
+	fCache := flowCache{}
+	for _, flow := range flows {
+		fCache[flow.MatchString()] = flow
+	}
+	cache.Store(flowCacheKey, fCache)
+}
+
+func (c *client) DeleteStaleFlows() error {
+	flows, err := c.bridge.DumpFlows(binding.AllTables, 0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to dump flows: %v", err)
+	}
+	// The flows of a round are deleted with a single cookie match. There is normally only one stale round, the
+	// one of the previous Agent, unless it was restarted before it could delete the flows of its own predecessor.
+	staleRounds := map[uint64]int{}
+	for _, flow := range flows {
+		if round := cookie.ID(flow.Cookie).Round(); round != c.cookieAllocator.Round() {
+			staleRounds[round]++
+		}
+	}
+	for round, count := range staleRounds {
+		klog.Infof("Deleting %d stale flows installed in round %d", count, round)
+		if err := c.bridge.DeleteFlowsByCookie(cookie.NewID(round, cookie.Default).Raw(), cookie.RoundMask); err != nil {
+			return fmt.Errorf("failed to delete stale flows of round %d: %v", round, err)
+		}
+	}
+	return c.deleteStaleGroups()
+}
+
+// deleteStaleGroups deletes the groups installed in the OFSwitch which are not in groupCache, e.g. the groups of the
+// Services deleted while the Agent was not running. The group installations are blocked meanwhile, so that a group
+// cannot be installed again between the check of groupCache and its deletion.
+func (c *client) deleteStaleGroups() error {
+	c.replayMutex.Lock()
+	defer c.replayMutex.Unlock()
+	groupIDs, err := c.bridge.DumpGroupIDs()
+	if err != nil {
+		return fmt.Errorf("failed to dump groups: %v", err)
+	}
+	for _, groupID := range groupIDs {
+		if _, ok := c.groupCache.Load(groupID); ok {
+			continue
+		}
+		klog.Infof("Deleting stale group %d", groupID)
+		// The type of the group is not used to delete it.
+		if err := c.bridge.CreateGroup(groupID, binding.GroupTypeSelect).Delete(); err != nil {
+			return fmt.Errorf("failed to delete stale group %d: %v", groupID, err)
+		}
+	}
+	return nil
+}
@@ -17,6 +17,7 @@ package openflow
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"sync"
 	"testing"
@@ -25,7 +26,13 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/vmware-tanzu/antrea/pkg/agent/openflow/cookie"
 	oftest "github.com/vmware-tanzu/antrea/pkg/agent/openflow/testing"
+	"github.com/vmware-tanzu/antrea/pkg/agent/types"
+	binding "github.com/vmware-tanzu/antrea/pkg/ovs/openflow"
+	mocks "github.com/vmware-tanzu/antrea/pkg/ovs/openflow/testing"
 )
 
 const bridgeName = "dummy-br"
@@ -35,7 +42,7 @@ func installNodeFlows(ofClient Client, cacheKey string) (int, error) {
 	gwMAC, _ := net.ParseMAC("AA:BB:CC:DD:EE:FF")
 	IP, IPNet, _ := net.ParseCIDR("10.0.1.1/24")
 	peerNodeIP := net.ParseIP("192.168.1.1")
-	err := ofClient.InstallNodeFlows(hostName, gwMAC, IP, *IPNet, peerNodeIP)
+	err := ofClient.InstallNodeFlows(hostName, gwMAC, map[*net.IPNet]net.IP{IPNet: IP}, peerNodeIP, 0)
 	client := ofClient.(*client)
 	fCacheI, _ := client.nodeFlowCache.Load(hostName)
 	return len(fCacheI.(flowCache)), err
@@ -47,7 +54,7 @@ func installPodFlows(ofClient Client, cacheKey string) (int, error) {
 	podMAC, _ := net.ParseMAC("AA:BB:CC:DD:EE:EE")
 	podIP := net.ParseIP("10.0.0.2")
 	ofPort := uint32(10)
-	err := ofClient.InstallPodFlows(containerID, podIP, podMAC, gwMAC, ofPort)
+	err := ofClient.InstallPodFlows(containerID, []net.IP{podIP}, podMAC, gwMAC, ofPort)
 	client := ofClient.(*client)
 	fCacheI, _ := client.podFlowCache.Load(containerID)
 	return len(fCacheI.(flowCache)), err
@@ -56,10 +63,10 @@ func installPodFlows(ofClient Client, cacheKey string) (int, error) {
 // TestIdempotentFlowInstallation checks that InstallNodeFlows and InstallPodFlows are idempotent.
 func TestIdempotentFlowInstallation(t *testing.T) {
 	testCases := []struct {
-		name        string
-		cacheKey    string
-		numAddCalls int
-		installFn   func(ofClient Client, cacheKey string) (int, error)
+		name      string
+		cacheKey  string
+		numFlows  int
+		installFn func(ofClient Client, cacheKey string) (int, error)
 	}{
 		{"NodeFlows", "host", 2, installNodeFlows},
 		{"PodFlows", "aaaa-bbbb-cccc-dddd", 5, installPodFlows},
@@ -71,13 +78,15 @@ func TestIdempotentFlowInstallation(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			m := oftest.NewMockFlowOperations(ctrl)
-			ofClient := NewClient(bridgeName)
+			ofClient := NewClient(bridgeName, binding.BackendOFCtl, false)
 			client := ofClient.(*client)
 			client.flowOperations = m
 
-			m.EXPECT().Add(gomock.Any()).Return(nil).Times(tc.numAddCalls)
+			// The flows are added in a single bundle, and only the first time.
+			m.EXPECT().AddAll(gomock.Any()).Return(nil).Times(1)
 			numCached1, err := tc.installFn(ofClient, tc.cacheKey)
 			require.Nil(t, err, "Error when installing Node flows")
+			assert.Equal(t, tc.numFlows, numCached1)
 
 			// Installing the same flows again must not return an error and should not
 			// add additional flows to the cache.
@@ -89,12 +98,14 @@ func TestIdempotentFlowInstallation(t *testing.T) {
 	}
 }
 
-func TestFlowInstallationPartialSuccess(t *testing.T) {
+// TestFlowInstallationFailure checks that no flow is cached when the bundle fails, so that all the flows are
+// installed again on the next call.
+func TestFlowInstallationFailure(t *testing.T) {
 	testCases := []struct {
-		name        string
-		cacheKey    string
-		numAddCalls int
-		installFn   func(ofClient Client, cacheKey string) (int, error)
+		name      string
+		cacheKey  string
+		numFlows  int
+		installFn func(ofClient Client, cacheKey string) (int, error)
 	}{
 		{"NodeFlows", "host", 2, installNodeFlows},
 		{"PodFlows", "aaaa-bbbb-cccc-dddd", 5, installPodFlows},
@@ -106,25 +117,23 @@ func TestFlowInstallationPartialSuccess(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			m := oftest.NewMockFlowOperations(ctrl)
-			ofClient := NewClient(bridgeName)
+			ofClient := NewClient(bridgeName, binding.BackendOFCtl, false)
 			client := ofClient.(*client)
 			client.flowOperations = m
 
-			// We generate an error for the last Add call.
-			successfulCalls := m.EXPECT().Add(gomock.Any()).Return(nil).Times(tc.numAddCalls - 1)
-			errorCall := m.EXPECT().Add(gomock.Any()).Return(errors.New("OF error")).After(successfulCalls)
+			errorCall := m.EXPECT().AddAll(gomock.Any()).Return(errors.New("OF error")).Times(1)
 
 			var err error
 			var numCached int
 
 			numCached, err = tc.installFn(ofClient, tc.cacheKey)
 			require.NotNil(t, err, "Installing flows is expected to fail")
-			assert.Equal(t, tc.numAddCalls-1, numCached)
+			assert.Equal(t, 0, numCached)
 
-			m.EXPECT().Add(gomock.Any()).Return(nil).After(errorCall).Times(1)
+			m.EXPECT().AddAll(gomock.Any()).Return(nil).After(errorCall).Times(1)
 			numCached, err = tc.installFn(ofClient, tc.cacheKey)
 			require.Nil(t, err, "Error when installing Node flows")
-			assert.Equal(t, tc.numAddCalls, numCached)
+			assert.Equal(t, tc.numFlows, numCached)
 		})
 	}
 }
@@ -133,10 +142,10 @@ func TestFlowInstallationPartialSuccess(t *testing.T) {
 // flows) and for different cache keys (e.g. different Node hostnames) can happen concurrently.
 func TestConcurrentFlowInstallation(t *testing.T) {
 	testCases := []struct {
-		name        string
-		cacheKey    string
-		numAddCalls int
-		installFn   func(ofClient Client, cacheKey string) (int, error)
+		name      string
+		cacheKey  string
+		numFlows  int
+		installFn func(ofClient Client, cacheKey string) (int, error)
 	}{
 		{"NodeFlows", "host", 2, installNodeFlows},
 		{"PodFlows", "aaaa-bbbb-cccc-dddd", 5, installPodFlows},
@@ -148,19 +157,19 @@ func TestConcurrentFlowInstallation(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			m := oftest.NewMockFlowOperations(ctrl)
-			ofClient := NewClient(bridgeName)
+			ofClient := NewClient(bridgeName, binding.BackendOFCtl, false)
 			client := ofClient.(*client)
 			client.flowOperations = m
 
 			var wg sync.WaitGroup
-			var counter int32        // tracks the number of concurrent Add calls at any given time
-			var callIdx int32        // used to distinguish between the first Add call and subsequent calls
+			var counter int32        // tracks the number of concurrent AddAll calls at any given time
+			var callIdx int32        // used to distinguish between the first AddAll call and subsequent calls
 			var concurrentCalls bool // set to true if we observe concurrent calls
 			var stop bool
 			var mutex sync.Mutex
 			cond := sync.NewCond(&mutex)
 
-			m.EXPECT().Add(gomock.Any()).DoAndReturn(func(args ...interface{}) error {
+			m.EXPECT().AddAll(gomock.Any()).DoAndReturn(func(args ...interface{}) error {
 				cond.L.Lock()
 				defer cond.L.Unlock()
 				counter += 1
@@ -168,7 +177,7 @@ func TestConcurrentFlowInstallation(t *testing.T) {
 				if callIdx == 1 { // first call
 					// we wait until we need to stop
 					// we stop when one of these conditions is met:
-					// 1) we detected concurrent Add calls (test is a success), or
+					// 1) we detected concurrent AddAll calls (test is a success), or
 					// 2) the test timed out
 					for !stop {
 						cond.Wait()
@@ -207,3 +216,386 @@ func TestConcurrentFlowInstallation(t *testing.T) {
 		})
 	}
 }
+
+// TestFlowCookies checks that the cookie of the flows encodes the round number and the category of the flows.
+func TestFlowCookies(t *testing.T) {
+	testCases := []struct {
+		name      string
+		category  cookie.Category
+		installFn func(ofClient Client, cacheKey string) (int, error)
+	}{
+		{"NodeFlows", cookie.Node, installNodeFlows},
+		{"PodFlows", cookie.Pod, installPodFlows},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := oftest.NewMockFlowOperations(ctrl)
+			ofClient := NewClient(bridgeName, binding.BackendOFCtl, false)
+			client := ofClient.(*client)
+			client.flowOperations = m
+			client.cookieAllocator = cookie.NewAllocator(5)
+
+			expectedCookie := fmt.Sprintf("cookie=0x%x,", cookie.NewID(5, tc.category).Raw())
+			m.EXPECT().AddAll(gomock.Any()).DoAndReturn(func(flows []binding.Flow) error {
+				for _, flow := range flows {
+					assert.Contains(t, flow.String(), expectedCookie)
+				}
+				return nil
+			}).Times(1)
+			_, err := tc.installFn(ofClient, "key")
+			require.Nil(t, err, "Error when installing flows")
+		})
+	}
+}
+
+func TestDeleteStaleFlows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	bridge := mocks.NewMockBridge(ctrl)
+	c := &client{bridge: bridge, cookieAllocator: cookie.NewAllocator(3)}
+
+	bridge.EXPECT().DumpFlows(binding.AllTables, uint64(0), uint64(0)).Return([]*binding.FlowStats{
+		{Cookie: cookie.NewID(3, cookie.Pod).Raw()},
+		{Cookie: cookie.NewID(2, cookie.Pod).Raw()},
+		{Cookie: cookie.NewID(2, cookie.Default).Raw()},
+		// Flows installed without a cookie belong to round 0.
+		{Cookie: 0},
+	}, nil)
+	bridge.EXPECT().DeleteFlowsByCookie(cookie.NewID(2, cookie.Default).Raw(), cookie.RoundMask).Return(nil)
+	bridge.EXPECT().DeleteFlowsByCookie(uint64(0), cookie.RoundMask).Return(nil)
+	// Group 1 is still used by a Service, group 2 is stale.
+	group1, group2 := mocks.NewMockGroup(ctrl), mocks.NewMockGroup(ctrl)
+	c.groupCache.Store(binding.GroupIDType(1), group1)
+	bridge.EXPECT().DumpGroupIDs().Return([]binding.GroupIDType{1, 2}, nil)
+	bridge.EXPECT().CreateGroup(binding.GroupIDType(2), gomock.Any()).Return(group2)
+	group2.EXPECT().Delete().Return(nil)
+	require.Nil(t, c.DeleteStaleFlows())
+
+	bridge.EXPECT().DumpFlows(binding.AllTables, uint64(0), uint64(0)).Return(nil, errors.New("dump error"))
+	assert.NotNil(t, c.DeleteStaleFlows())
+
+	bridge.EXPECT().DumpFlows(binding.AllTables, uint64(0), uint64(0)).Return(nil, nil)
+	bridge.EXPECT().DumpGroupIDs().Return(nil, errors.New("dump error"))
+	assert.NotNil(t, c.DeleteStaleFlows())
+}
+
+// TestReplayFlows checks that ReplayFlows installs again the default flows and the cached flows.
+func TestReplayFlows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := oftest.NewMockFlowOperations(ctrl)
+	ofClient := NewClient(bridgeName, binding.BackendOFCtl, false)
+	client := ofClient.(*client)
+	client.flowOperations = m
+
+	m.EXPECT().AddAll(gomock.Any()).Return(nil).Times(3)
+	_, err := installNodeFlows(ofClient, "host")
+	require.Nil(t, err, "Error when installing Node flows")
+	_, err = installPodFlows(ofClient, "aaaa-bbbb-cccc-dddd")
+	require.Nil(t, err, "Error when installing Pod flows")
+	require.Nil(t, ofClient.InstallTunnelFlows(1), "Error when installing tunnel flows")
+
+	// The default flows are installed one by one, and the cached flows in one bundle per cache entry.
+	m.EXPECT().Add(gomock.Any()).Return(nil).MinTimes(1)
+	replayedFlows := 0
+	m.EXPECT().AddAll(gomock.Any()).Do(func(flows []binding.Flow) {
+		replayedFlows += len(flows)
+	}).Return(nil).Times(3)
+	require.Nil(t, ofClient.ReplayFlows())
+	assert.Equal(t, 2+5+2, replayedFlows)
+}
+
+// TestReplayFlowsSerialized checks that the flows of an entity are not installed while the flows are replayed.
+func TestReplayFlowsSerialized(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := oftest.NewMockFlowOperations(ctrl)
+	ofClient := NewClient(bridgeName, binding.BackendOFCtl, false)
+	client := ofClient.(*client)
+	client.flowOperations = m
+
+	replayStarted := make(chan struct{})
+	resumeReplay := make(chan struct{})
+	var once sync.Once
+	m.EXPECT().Add(gomock.Any()).Do(func(flow binding.Flow) {
+		once.Do(func() {
+			close(replayStarted)
+			<-resumeReplay
+		})
+	}).Return(nil).MinTimes(1)
+	replayDone := make(chan error)
+	go func() {
+		replayDone <- ofClient.ReplayFlows()
+	}()
+	<-replayStarted
+
+	installed := make(chan struct{})
+	m.EXPECT().AddAll(gomock.Any()).Do(func(flows []binding.Flow) {
+		close(installed)
+	}).Return(nil).Times(1)
+	installDone := make(chan error)
+	go func() {
+		_, err := installPodFlows(ofClient, "aaaa-bbbb-cccc-dddd")
+		installDone <- err
+	}()
+	select {
+	case <-installed:
+		t.Fatal("Pod flows were installed during the replay")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(resumeReplay)
+	require.Nil(t, <-replayDone, "Error when replaying flows")
+	require.Nil(t, <-installDone, "Error when installing Pod flows")
+}
+
+// TestIPv6Flows checks that the Pod and Node flows of an IPv6 Pod network match the IPv6 addresses, and that the
+// ARP flows are replaced with Neighbor Discovery flows.
+func TestIPv6Flows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := oftest.NewMockFlowOperations(ctrl)
+	ofClient := NewClient(bridgeName, binding.BackendOFCtl, false)
+	client := ofClient.(*client)
+	client.flowOperations = m
+	client.ipv4 = false
+	client.ipv6 = true
+
+	var flows []string
+	m.EXPECT().AddAll(gomock.Any()).Do(func(added []binding.Flow) {
+		for _, flow := range added {
+			flows = append(flows, flow.String())
+		}
+	}).Return(nil).AnyTimes()
+
+	gwMAC, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	podMAC, _ := net.ParseMAC("aa:bb:cc:dd:ee:ee")
+	podIP := net.ParseIP("fd00:10:0:1::2")
+	require.Nil(t, ofClient.InstallPodFlows("aaaa-bbbb-cccc-dddd", []net.IP{podIP}, podMAC, gwMAC, 10))
+	assert.Len(t, flows, 4+4)
+	assert.Contains(t, flows, "table=10,cookie=0x30000000000,priority=200,ipv6,in_port=10,dl_src=aa:bb:cc:dd:ee:ee,ipv6_src=fd00:10:0:1::2,actions=resubmit(,30)")
+	assert.Contains(t, flows, "table=10,cookie=0x30000000000,priority=210,icmp6,in_port=10,dl_src=aa:bb:cc:dd:ee:ee,icmpv6_type=136,nd_target=fd00:10:0:1::2,nd_tll=aa:bb:cc:dd:ee:ee,actions=resubmit(,20)")
+	assert.Contains(t, flows, "table=10,cookie=0x30000000000,priority=210,icmp6,in_port=10,dl_src=aa:bb:cc:dd:ee:ee,icmpv6_type=135,nd_sll=00:00:00:00:00:00,actions=resubmit(,20)")
+
+	flows = nil
+	peerGatewayIP, peerPodCIDR, _ := net.ParseCIDR("fd00:10:0:2::1/64")
+	require.Nil(t, ofClient.InstallNodeFlows("host", gwMAC, map[*net.IPNet]net.IP{peerPodCIDR: peerGatewayIP}, net.ParseIP("fd00::2"), 0))
+	require.Len(t, flows, 1)
+	assert.Contains(t, flows[0], "ipv6,ipv6_dst=fd00:10:0:2::/64")
+	assert.Contains(t, flows[0], "set_field:fd00::2->tun_ipv6_dst")
+
+	// The Antrea proxy does not support IPv6.
+	_, podCIDR, _ := net.ParseCIDR("fd00:10:0:1::/64")
+	_, err := NewClient(bridgeName, binding.BackendOFCtl, true).Initialize(1, &types.NodeConfig{PodIPv6CIDR: podCIDR})
+	assert.NotNil(t, err)
+}
+
+// TestDualStackFlows checks that the Pod and Node flows of a dual-stack Pod network are installed for both IP
+// families.
+func TestDualStackFlows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := oftest.NewMockFlowOperations(ctrl)
+	ofClient := NewClient(bridgeName, binding.BackendOFCtl, false)
+	client := ofClient.(*client)
+	client.flowOperations = m
+	client.ipv4 = true
+	client.ipv6 = true
+
+	var flows []string
+	m.EXPECT().AddAll(gomock.Any()).Do(func(added []binding.Flow) {
+		for _, flow := range added {
+			flows = append(flows, flow.String())
+		}
+	}).Return(nil).AnyTimes()
+
+	gwMAC, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	podMAC, _ := net.ParseMAC("aa:bb:cc:dd:ee:ee")
+	podIPs := []net.IP{net.ParseIP("10.10.1.2"), net.ParseIP("fd00:10:0:1::2")}
+	require.Nil(t, ofClient.InstallPodFlows("aaaa-bbbb-cccc-dddd", podIPs, podMAC, gwMAC, 10))
+	// The classifier and L2 forwarding flows are shared, the IPv4 address has an ARP spoof guard flow and the IPv6
+	// address four Neighbor Discovery spoof guard flows.
+	assert.Len(t, flows, 2+3+6)
+	assert.Contains(t, flows, "table=10,cookie=0x30000000000,priority=200,ip,in_port=10,dl_src=aa:bb:cc:dd:ee:ee,nw_src=10.10.1.2,actions=resubmit(,30)")
+	assert.Contains(t, flows, "table=10,cookie=0x30000000000,priority=200,ipv6,in_port=10,dl_src=aa:bb:cc:dd:ee:ee,ipv6_src=fd00:10:0:1::2,actions=resubmit(,30)")
+
+	flows = nil
+	peerGatewayIPv4, peerPodIPv4CIDR, _ := net.ParseCIDR("10.10.2.1/24")
+	peerGatewayIPv6, peerPodIPv6CIDR, _ := net.ParseCIDR("fd00:10:0:2::1/64")
+	peerConfigs := map[*net.IPNet]net.IP{peerPodIPv4CIDR: peerGatewayIPv4, peerPodIPv6CIDR: peerGatewayIPv6}
+	require.Nil(t, ofClient.InstallNodeFlows("host", gwMAC, peerConfigs, net.ParseIP("192.168.1.2"), 0))
+	// The IPv6 peer gateway has no ARP responder flow.
+	assert.Len(t, flows, 2+1)
+}
+
+// TestNoEncapNodeFlows checks that the traffic to a remote Node is sent to the local gateway without encapsulation,
+// and that no ARP responder flow is installed for the peer gateway.
+func TestNoEncapNodeFlows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := oftest.NewMockFlowOperations(ctrl)
+	ofClient := NewClient(bridgeName, binding.BackendOFCtl, false)
+	client := ofClient.(*client)
+	client.flowOperations = m
+	client.ipv4 = true
+
+	var flows []string
+	m.EXPECT().AddAll(gomock.Any()).Do(func(added []binding.Flow) {
+		for _, flow := range added {
+			flows = append(flows, flow.String())
+		}
+	}).Return(nil).Times(1)
+
+	gwMAC, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	_, peerPodCIDR, _ := net.ParseCIDR("10.10.2.0/24")
+	require.Nil(t, ofClient.InstallNoEncapNodeFlows("host", gwMAC, []*net.IPNet{peerPodCIDR}))
+	require.Len(t, flows, 1)
+	assert.Contains(t, flows[0], "ip,nw_dst=10.10.2.0/24,actions=set_field:aa:bb:cc:dd:ee:ff->dl_dst,resubmit(,80)")
+	assert.NotContains(t, flows[0], "tun_dst")
+}
+
+// TestIPSecNodeFlows checks that the traffic to a remote Node is output to its IPSec tunnel port, and that the traffic
+// received from this port is classified as tunnel traffic.
+func TestIPSecNodeFlows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := oftest.NewMockFlowOperations(ctrl)
+	ofClient := NewClient(bridgeName, binding.BackendOFCtl, false)
+	client := ofClient.(*client)
+	client.flowOperations = m
+	client.ipv4 = true
+
+	var flows []string
+	m.EXPECT().AddAll(gomock.Any()).Do(func(added []binding.Flow) {
+		for _, flow := range added {
+			flows = append(flows, flow.String())
+		}
+	}).Return(nil).Times(1)
+
+	gwMAC, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	peerGatewayIP, peerPodCIDR, _ := net.ParseCIDR("10.10.2.1/24")
+	require.Nil(t, ofClient.InstallNodeFlows("host", gwMAC, map[*net.IPNet]net.IP{peerPodCIDR: peerGatewayIP}, net.ParseIP("192.168.1.2"), 5))
+	require.Len(t, flows, 3)
+	assert.Contains(t, flows[0], "table=0,priority=200,in_port=5,")
+	assert.Contains(t, flows[1], "ip,nw_dst=10.10.2.0/24,")
+	assert.Contains(t, flows[1], "load:0x5->reg1[0..31],load:0x1->reg0[16..16],resubmit(,90)")
+	assert.NotContains(t, flows[1], "tun_dst")
+}
+
+// TestReplaceNodeFlows checks that the flows of a remote Node are replaced in a single bundle when its parameters
+// change.
+func TestReplaceNodeFlows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := oftest.NewMockFlowOperations(ctrl)
+	ofClient := NewClient(bridgeName, binding.BackendOFCtl, false)
+	client := ofClient.(*client)
+	client.flowOperations = m
+	client.ipv4 = true
+
+	gwMAC, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	peerGatewayIP, peerPodCIDR, _ := net.ParseCIDR("10.10.2.1/24")
+	peerConfigs := map[*net.IPNet]net.IP{peerPodCIDR: peerGatewayIP}
+	m.EXPECT().AddAll(flowCount(2)).Return(nil).Times(1)
+	require.Nil(t, ofClient.InstallNodeFlows("host", gwMAC, peerConfigs, net.ParseIP("192.168.1.2"), 0))
+
+	// The tunnel destination changed: only the L3 forwarding flow is modified.
+	var modified []string
+	m.EXPECT().BundleOps(flowCount(0), flowCount(1), flowCount(0)).Do(func(adds, mods, dels []binding.Flow) {
+		for _, flow := range mods {
+			modified = append(modified, flow.String())
+		}
+	}).Return(nil).Times(1)
+	require.Nil(t, ofClient.InstallNodeFlows("host", gwMAC, peerConfigs, net.ParseIP("192.168.1.3"), 0))
+	require.Len(t, modified, 1)
+	assert.Contains(t, modified[0], "set_field:192.168.1.3->tun_dst")
+
+	// The PodCIDR changed: the flows of the new PodCIDR are added and the ones of the old PodCIDR are deleted.
+	newPeerGatewayIP, newPeerPodCIDR, _ := net.ParseCIDR("10.10.3.1/24")
+	newPeerConfigs := map[*net.IPNet]net.IP{newPeerPodCIDR: newPeerGatewayIP}
+	m.EXPECT().BundleOps(flowCount(2), flowCount(0), flowCount(2)).Return(errors.New("OF error")).Times(1)
+	require.NotNil(t, ofClient.InstallNodeFlows("host", gwMAC, newPeerConfigs, net.ParseIP("192.168.1.3"), 0))
+	// The flow cache is unchanged after a failure, so the same changes are applied when retrying.
+	m.EXPECT().BundleOps(flowCount(2), flowCount(0), flowCount(2)).Return(nil).Times(1)
+	require.Nil(t, ofClient.InstallNodeFlows("host", gwMAC, newPeerConfigs, net.ParseIP("192.168.1.3"), 0))
+	require.Nil(t, ofClient.InstallNodeFlows("host", gwMAC, newPeerConfigs, net.ParseIP("192.168.1.3"), 0))
+}
+
+// TestServiceFlows checks that InstallServiceFlows installs the flows of the new endpoints before the group selects
+// them, and deletes the flows of the removed endpoints once the group doesn't select them anymore.
+func TestServiceFlows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := oftest.NewMockFlowOperations(ctrl)
+	bridge := mocks.NewMockBridge(ctrl)
+	ofClient := NewClient(bridgeName, binding.BackendOFCtl, true)
+	client := ofClient.(*client)
+	client.flowOperations = m
+	client.bridge = bridge
+
+	groupID := binding.GroupIDType(1)
+	svcPort := &types.ServicePort{IP: net.ParseIP("10.96.0.10"), Port: 53, Protocol: corev1.ProtocolUDP}
+	ep1 := &types.Endpoint{IP: net.ParseIP("10.10.0.2"), Port: 5353}
+	ep2 := &types.Endpoint{IP: net.ParseIP("10.10.1.2"), Port: 5353}
+
+	expectGroup := func(numBuckets int) *mocks.MockGroup {
+		group := mocks.NewMockGroup(ctrl)
+		bucket := mocks.NewMockBucketBuilder(ctrl)
+		bridge.EXPECT().CreateGroup(groupID, binding.GroupTypeSelect).Return(group)
+		group.EXPECT().GetID().Return(groupID).AnyTimes()
+		group.EXPECT().Bucket().Return(bucket).Times(numBuckets)
+		bucket.EXPECT().Weight(uint16(100)).Return(bucket).Times(numBuckets)
+		bucket.EXPECT().LoadRegRange(gomock.Any(), gomock.Any(), gomock.Any()).Return(bucket).MinTimes(2 * numBuckets)
+		bucket.EXPECT().Resubmit(emptyPlaceholderStr, gomock.Any()).Return(bucket).Times(numBuckets)
+		bucket.EXPECT().Done().Return(group).Times(numBuckets)
+		return group
+	}
+	countFlows := func(count *int) func(flows []binding.Flow) error {
+		return func(flows []binding.Flow) error {
+			*count = len(flows)
+			return nil
+		}
+	}
+	var numAdds, numMods, numDels int
+	countBundle := func(adds, mods, dels []binding.Flow) error {
+		numAdds, numMods, numDels = len(adds), len(mods), len(dels)
+		return nil
+	}
+
+	var numNewEPFlows int
+	group := expectGroup(2)
+	gomock.InOrder(
+		m.EXPECT().AddAll(gomock.Any()).DoAndReturn(countFlows(&numNewEPFlows)),
+		group.EXPECT().Add().Return(nil),
+		m.EXPECT().BundleOps(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(countBundle),
+	)
+	require.Nil(t, ofClient.InstallServiceFlows(groupID, svcPort, []*types.Endpoint{ep1, ep2}, 0))
+	assert.Equal(t, 2, numNewEPFlows)
+	assert.Equal(t, []int{1, 0, 0}, []int{numAdds, numMods, numDels})
+
+	// The endpoint ep1 is removed and the session affinity is enabled: the learn flow is added, the load balancing
+	// flow is modified and the flow of ep1 is deleted.
+	group = expectGroup(1)
+	gomock.InOrder(
+		group.EXPECT().Modify().Return(nil),
+		m.EXPECT().BundleOps(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(countBundle),
+	)
+	require.Nil(t, ofClient.InstallServiceFlows(groupID, svcPort, []*types.Endpoint{ep2}, 100))
+	assert.Equal(t, []int{1, 1, 1}, []int{numAdds, numMods, numDels})
+	fCacheI, _ := client.serviceCache.Load(svcPort.String())
+	assert.Equal(t, 3, len(fCacheI.(flowCache)))
+
+	var numDeletedFlows int
+	gomock.InOrder(
+		m.EXPECT().DeleteAll(gomock.Any()).DoAndReturn(countFlows(&numDeletedFlows)),
+		group.EXPECT().Delete().Return(nil),
+	)
+	require.Nil(t, ofClient.UninstallServiceFlows(groupID, svcPort))
+	assert.Equal(t, 3, numDeletedFlows)
+	_, ok := client.groupCache.Load(groupID)
+	assert.False(t, ok)
+}
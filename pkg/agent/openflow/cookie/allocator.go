@@ -0,0 +1,116 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cookie encodes the owner of the flows installed by the Antrea Agent in their cookie. The cookie of a flow
+// contains the round number of the Agent which installed it and the category of the flow:
+//
+//	|63 ... 48|47 ... 40|39 ... 0|
+//	|  round  |category |reserved|
+//
+// The round number is incremented every time the Agent restarts, so that the flows left by a previous Agent can be
+// found and deleted once the current Agent has replayed the desired state.
+package cookie
+
+import "fmt"
+
+const (
+	// BitwidthRound is the number of bits of the round number.
+	BitwidthRound = 16
+	// BitwidthCategory is the number of bits of the category.
+	BitwidthCategory = 8
+
+	roundShift    = 64 - BitwidthRound
+	categoryShift = roundShift - BitwidthCategory
+
+	// RoundMask is the mask of the round number in a cookie.
+	RoundMask uint64 = (1<<BitwidthRound - 1) << roundShift
+	// CategoryMask is the mask of the category in a cookie.
+	CategoryMask uint64 = (1<<BitwidthCategory - 1) << categoryShift
+)
+
+// Category is the kind of entity a flow was installed for.
+type Category uint64
+
+const (
+	// Default is the category of the flows which are part of the base pipeline.
+	Default Category = iota
+	Gateway
+	Node
+	Pod
+	Service
+	Policy
+)
+
+var categoryNames = map[Category]string{
+	Default: "Default",
+	Gateway: "Gateway",
+	Node:    "Node",
+	Pod:     "Pod",
+	Service: "Service",
+	Policy:  "Policy",
+}
+
+func (c Category) String() string {
+	if name, ok := categoryNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Category(%d)", uint64(c))
+}
+
+// ID is the cookie of a flow.
+type ID uint64
+
+// NewID returns the cookie of the flows of the category installed in the round.
+func NewID(round uint64, category Category) ID {
+	return ID(round<<roundShift&RoundMask | uint64(category)<<categoryShift&CategoryMask)
+}
+
+// Raw returns the cookie as a number, which can be set on a flow.
+func (i ID) Raw() uint64 {
+	return uint64(i)
+}
+
+// Round returns the round number of the cookie.
+func (i ID) Round() uint64 {
+	return uint64(i) & RoundMask >> roundShift
+}
+
+// Category returns the category of the cookie.
+func (i ID) Category() Category {
+	return Category(uint64(i) & CategoryMask >> categoryShift)
+}
+
+func (i ID) String() string {
+	return fmt.Sprintf("<round:%d,category:%s>", i.Round(), i.Category())
+}
+
+// Allocator allocates the cookies of the flows installed in one round.
+type Allocator struct {
+	round uint64
+}
+
+// NewAllocator returns an Allocator for the round. Only the BitwidthRound lowest bits of round are used.
+func NewAllocator(round uint64) *Allocator {
+	return &Allocator{round: round & (1<<BitwidthRound - 1)}
+}
+
+// Round returns the round number of the cookies allocated by the Allocator.
+func (a *Allocator) Round() uint64 {
+	return a.round
+}
+
+// Request returns the cookie for a flow of the category.
+func (a *Allocator) Request(category Category) ID {
+	return NewID(a.round, category)
+}
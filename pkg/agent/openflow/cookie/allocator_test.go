@@ -0,0 +1,43 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cookie
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocatorRequest(t *testing.T) {
+	a := NewAllocator(3)
+	id := a.Request(Policy)
+	assert.Equal(t, uint64(0x0003050000000000), id.Raw())
+	assert.Equal(t, uint64(3), id.Round())
+	assert.Equal(t, Policy, id.Category())
+	assert.Equal(t, "<round:3,category:Policy>", id.String())
+
+	// Round numbers wrap around.
+	a = NewAllocator(1<<BitwidthRound + 2)
+	assert.Equal(t, uint64(2), a.Round())
+	assert.Equal(t, uint64(2), a.Request(Pod).Round())
+	assert.Equal(t, Pod, a.Request(Pod).Category())
+}
+
+func TestIDMasks(t *testing.T) {
+	id := NewID(0xffff, Category(0xff))
+	assert.Equal(t, RoundMask|CategoryMask, id.Raw())
+	assert.Equal(t, uint64(0), RoundMask&CategoryMask)
+	assert.Equal(t, "Category(255)", id.Category().String())
+}
@@ -36,6 +36,9 @@ const (
 	MatchTCPDstPort
 	MatchUDPDstPort
 	MatchSCTPDstPort
+	MatchTCPv6DstPort
+	MatchUDPv6DstPort
+	MatchSCTPv6DstPort
 	Unsupported
 )
 
@@ -141,8 +144,9 @@ func newConjunctionNotFound(conjunctionID uint32) *ConjunctionNotFound {
 
 // conjunctiveMatch generates match conditions for conjunctive match flow entry, including source or destination
 // IP address, ofport number of OVS interface, or Service port. When conjunctiveMatch is used to match IP
-// address or ofport number, matchProtocol is "ip". When conjunctiveMatch is used to match Service
-// port, matchProtocol is Service protocol. If Service protocol is not set, "tcp" is used by default.
+// address, matchProtocol is "ip" or "ipv6" depending on the address, and no protocol is matched with an ofport
+// number. When conjunctiveMatch is used to match Service port, matchProtocol is Service protocol, and there is one
+// conjunctiveMatch per IP family of the Pod network. If Service protocol is not set, "tcp" is used by default.
 type conjunctiveMatch struct {
 	tableID    binding.TableIDType
 	matchKey   int
@@ -184,100 +188,219 @@ type conjMatchFlowContext struct {
 	dropFlow binding.Flow
 }
 
-// installOrUpdateFlow installs or updates conjunctive match entries of the rule table.
-func (ctx *conjMatchFlowContext) installOrUpdateFlow(actions []*conjunctiveAction) error {
-	// Check if flow is already installed. If not, add new flow on the switch.
+// clone returns a copy of the conjMatchFlowContext, whose actions and denyAllRules can be changed without changing the
+// original ones.
+func (ctx *conjMatchFlowContext) clone() *conjMatchFlowContext {
+	actions := make(map[uint32]*conjunctiveAction, len(ctx.actions))
+	for id, act := range ctx.actions {
+		actions[id] = act
+	}
+	denyAllRules := make(map[uint32]bool, len(ctx.denyAllRules))
+	for id := range ctx.denyAllRules {
+		denyAllRules[id] = true
+	}
+	return &conjMatchFlowContext{
+		conjunctiveMatch: ctx.conjunctiveMatch,
+		actions:          actions,
+		denyAllRules:     denyAllRules,
+		client:           ctx.client,
+		flow:             ctx.flow,
+		dropFlow:         ctx.dropFlow,
+	}
+}
+
+// updateFlow rebuilds the conjunctive match flow with the provided actions. The flow is removed if there is no action.
+// It happens when the match condition is used only for matching AppliedToGroup, but no From or To is defined in the
+// NetworkPolicy rule.
+func (ctx *conjMatchFlowContext) updateFlow(actions []*conjunctiveAction) {
+	if len(actions) == 0 {
+		ctx.flow = nil
+		return
+	}
+	// Build a new Openflow entry if the flow doesn't exist yet, otherwise copy the existing Openflow entry with the
+	// latest actions.
 	if ctx.flow == nil {
-		// Check then number of valid conjunctiveAction, no need to install openflow if it is 0. It happens when the match
-		// condition is used only for matching AppliedToGroup, but no From or To is defined in the NetworkPolicy rule.
-		if len(actions) == 0 {
-			return nil
-		}
+		ctx.flow = ctx.client.conjunctiveMatchFlow(ctx.tableID, ctx.matchKey, ctx.matchValue, actions...)
+		return
+	}
+	flowBuilder := ctx.flow.CopyToBuilder()
+	for _, act := range actions {
+		flowBuilder.Action().Conjunction(act.conjID, act.clauseID, act.nClause)
+	}
+	ctx.flow = flowBuilder.Done()
+}
 
-		// Build the Openflow entry. actions here should not be empty for either add or update case.
-		flow := ctx.client.conjunctiveMatchFlow(ctx.tableID, ctx.matchKey, ctx.matchValue, actions...)
+// deleteAction deletes the specified policyRuleConjunction from conjunctiveMatchFlow's actions, and then rebuilds the
+// conjunctive match flow with the left conjunctive actions. It returns false if the conjunction is not in the actions.
+func (ctx *conjMatchFlowContext) deleteAction(conjID uint32) bool {
+	if _, found := ctx.actions[conjID]; !found {
+		return false
+	}
+	delete(ctx.actions, conjID)
+	actions := make([]*conjunctiveAction, 0, len(ctx.actions))
+	for _, act := range ctx.actions {
+		actions = append(actions, act)
+	}
+	ctx.updateFlow(actions)
+	return true
+}
 
-		if err := flow.Add(); err != nil {
-			return err
-		}
-		ctx.flow = flow
+// addAction adds the specified conjunction into conjunctiveMatchFlow's actions, and then rebuilds the conjunctive
+// match flow. It returns false if the conjunction is already in the actions.
+func (ctx *conjMatchFlowContext) addAction(action *conjunctiveAction) bool {
+	if _, found := ctx.actions[action.conjID]; found {
+		return false
+	}
+	ctx.actions[action.conjID] = action
+	actions := make([]*conjunctiveAction, 0, len(ctx.actions))
+	for _, act := range ctx.actions {
+		actions = append(actions, act)
+	}
+	ctx.updateFlow(actions)
+	return true
+}
 
-		return nil
+func (ctx *conjMatchFlowContext) addDenyAllRule(ruleID uint32) {
+	if ctx.denyAllRules == nil {
+		ctx.denyAllRules = make(map[uint32]bool)
 	}
+	ctx.denyAllRules[ruleID] = true
+}
 
-	// Modify existing Openflow entry with latest actions.
-	flowBuilder := ctx.flow.CopyToBuilder()
-	for _, act := range actions {
-		flowBuilder.Action().Conjunction(act.conjID, act.clauseID, act.nClause)
+// stagedConjMatchFlowContext is the copy of a conjMatchFlowContext changed by conjMatchFlowUpdates.
+type stagedConjMatchFlowContext struct {
+	*conjMatchFlowContext
+	// origin is the conjMatchFlowContext in globalConjMatchFlowCache, it is nil if the context is new.
+	origin *conjMatchFlowContext
+	// flowModified is set if the conjunctive match flow has been rebuilt with different actions.
+	flowModified bool
+}
+
+// clauseMatchChange is the addition or the removal of a conjMatchFlowContext in clause matches.
+type clauseMatchChange struct {
+	clause *clause
+	key    string
+	add    bool
+}
+
+// conjMatchFlowUpdates stages the changes made by a NetworkPolicy rule operation. The conjMatchFlowContexts are
+// changed on copies, and the resulting flow changes are applied on the switch in one bundle. The copies are committed
+// to globalConjMatchFlowCache and to the clauses only if the bundle succeeds, so that a failed operation changes
+// neither the switch nor the caches.
+type conjMatchFlowUpdates struct {
+	client *client
+	// contexts is a map from the key of the conjMatchFlowContext in globalConjMatchFlowCache to its staged copy.
+	contexts     map[string]*stagedConjMatchFlowContext
+	matchChanges []clauseMatchChange
+	// addFlows and delFlows are the conjunction action flows added and deleted by the operation.
+	addFlows []binding.Flow
+	delFlows []binding.Flow
+}
+
+// getContext returns the staged copy of the conjMatchFlowContext, which is copied from globalConjMatchFlowCache when
+// it is accessed for the first time. It returns nil if the conjMatchFlowContext doesn't exist.
+func (u *conjMatchFlowUpdates) getContext(key string) *stagedConjMatchFlowContext {
+	if ctx, found := u.contexts[key]; found {
+		return ctx
 	}
-	newFlow := flowBuilder.Done()
-	if err := newFlow.Modify(); err != nil {
-		return err
+	origin, found := u.client.globalConjMatchFlowCache[key]
+	if !found {
+		return nil
 	}
-	ctx.flow = newFlow
-	return nil
+	ctx := &stagedConjMatchFlowContext{conjMatchFlowContext: origin.clone(), origin: origin}
+	u.contexts[key] = ctx
+	return ctx
 }
 
-// deleteAction deletes the specified policyRuleConjunction from conjunctiveMatchFlow's actions, and then updates the
-// conjunctive match flow entry on the switch.
-func (ctx *conjMatchFlowContext) deleteAction(conjID uint32) error {
-	// If the specified conjunctive action is the last one in actions, delete the conjunctive match flow entry from the
-	// switch. No need to check if the conjunction ID of the only conjunctive action is the specified ID or not, as it
-	// has been checked in the caller.
-	if len(ctx.actions) == 1 && ctx.flow != nil {
-		if err := ctx.flow.Delete(); err != nil {
-			return err
+// newContext stages a new conjMatchFlowContext for the conjunctive match.
+func (u *conjMatchFlowUpdates) newContext(key string, match *conjunctiveMatch) *stagedConjMatchFlowContext {
+	ctx := &stagedConjMatchFlowContext{
+		conjMatchFlowContext: &conjMatchFlowContext{
+			conjunctiveMatch: match,
+			actions:          make(map[uint32]*conjunctiveAction),
+			denyAllRules:     make(map[uint32]bool),
+			client:           u.client,
+		},
+	}
+	u.contexts[key] = ctx
+	return ctx
+}
+
+// flowChanges computes the flows to add, modify and delete on the switch for the staged changes.
+func (u *conjMatchFlowUpdates) flowChanges() (addFlows, modFlows, delFlows []binding.Flow) {
+	addFlows = append(addFlows, u.addFlows...)
+	delFlows = append(delFlows, u.delFlows...)
+	for _, ctx := range u.contexts {
+		var flow, dropFlow binding.Flow
+		if ctx.origin != nil {
+			flow, dropFlow = ctx.origin.flow, ctx.origin.dropFlow
 		}
-		ctx.flow = nil
-	} else {
-		// Update Openflow entry with the left conjunctive actions.
-		var actions []*conjunctiveAction
-		for _, act := range ctx.actions {
-			if act.conjID != conjID {
-				actions = append(actions, act)
-			}
+		switch {
+		case flow == nil && ctx.flow != nil:
+			addFlows = append(addFlows, ctx.flow)
+		case flow != nil && ctx.flow == nil:
+			delFlows = append(delFlows, flow)
+		case flow != nil && ctx.flowModified:
+			modFlows = append(modFlows, ctx.flow)
 		}
-		err := ctx.installOrUpdateFlow(actions)
-		if err != nil {
-			return err
+		if dropFlow == nil && ctx.dropFlow != nil {
+			addFlows = append(addFlows, ctx.dropFlow)
+		} else if dropFlow != nil && ctx.dropFlow == nil {
+			delFlows = append(delFlows, dropFlow)
 		}
 	}
-	delete(ctx.actions, conjID)
-	return nil
+	return addFlows, modFlows, delFlows
 }
 
-// addAction adds the specified conjunction into conjunctiveMatchFlow's actions, and then updates the conjunctive
-// match flow on the switch. It also installs default drop flow if dropTable is not nil, and the dropFlow is not
-// installed before.
-func (ctx *conjMatchFlowContext) addAction(action *conjunctiveAction) error {
-	// Check if the conjunction exists in conjMatchFlowContext actions or not. If yes, return nil directly.
-	// Otherwise, add the new action, and update the Openflow entry.
-	_, found := ctx.actions[action.conjID]
-	if found {
-		return nil
+// apply applies the flow changes on the switch in one bundle, and commits the staged changes to the caches if the
+// bundle succeeds.
+func (u *conjMatchFlowUpdates) apply() error {
+	addFlows, modFlows, delFlows := u.flowChanges()
+	if len(addFlows)+len(modFlows)+len(delFlows) > 0 {
+		if err := u.client.flowOperations.BundleOps(addFlows, modFlows, delFlows); err != nil {
+			return err
+		}
 	}
 
-	// Install or update Openflow entry for the new conjunctiveAction.
-	actions := make([]*conjunctiveAction, 0, len(ctx.actions)+1)
-	for _, act := range ctx.actions {
-		actions = append(actions, act)
+	committed := make(map[string]*conjMatchFlowContext, len(u.contexts))
+	for key, ctx := range u.contexts {
+		context := ctx.conjMatchFlowContext
+		// Update the existing conjMatchFlowContext in place, as it is referenced by the matches of other clauses.
+		if ctx.origin != nil {
+			*ctx.origin = *ctx.conjMatchFlowContext
+			context = ctx.origin
+		}
+		// Remove the context from global cache after both the conjunctive match flow and the default drop flow are
+		// uninstalled from the switch.
+		if len(context.actions) == 0 && len(context.denyAllRules) == 0 {
+			delete(u.client.globalConjMatchFlowCache, key)
+		} else {
+			u.client.globalConjMatchFlowCache[key] = context
+		}
+		committed[key] = context
 	}
-	actions = append(actions, action)
-	err := ctx.installOrUpdateFlow(actions)
-	if err != nil {
-		return err
+	for _, change := range u.matchChanges {
+		if change.add {
+			change.clause.matches[change.key] = committed[change.key]
+		} else {
+			delete(change.clause.matches, change.key)
+		}
 	}
-	ctx.actions[action.conjID] = action
-
 	return nil
 }
 
-func (ctx *conjMatchFlowContext) addDenyAllRule(ruleID uint32) error {
-	if ctx.denyAllRules == nil {
-		ctx.denyAllRules = make(map[uint32]bool)
+// updateConjMatchFlows stages the changes of a NetworkPolicy rule operation with stage, and then applies them. The
+// conjMatchFlowLock is held during the whole operation.
+func (c *client) updateConjMatchFlows(stage func(updates *conjMatchFlowUpdates)) error {
+	c.conjMatchFlowLock.Lock()
+	defer c.conjMatchFlowLock.Unlock()
+
+	updates := &conjMatchFlowUpdates{
+		client:   c,
+		contexts: make(map[string]*stagedConjMatchFlowContext),
 	}
-	ctx.denyAllRules[ruleID] = true
-	return nil
+	stage(updates)
+	return updates.apply()
 }
 
 // policyRuleConjunction is responsible to build Openflow entries for Pods that are in a NetworkPolicy rule's AppliedToGroup.
@@ -323,53 +446,34 @@ type clause struct {
 	dropTable binding.Table
 }
 
-func (c *clause) addConjunctiveMatchFlow(client *client, match *conjunctiveMatch) error {
+// addConjunctiveMatchFlow stages the conjunctive match in the clause. The conjMatchFlowContext of the match is found
+// from globalConjMatchFlowCache, or created if it doesn't exist. The default drop flow is built if dropTable is not
+// nil.
+func (c *clause) addConjunctiveMatchFlow(updates *conjMatchFlowUpdates, match *conjunctiveMatch) {
 	matcherKey := match.generateGlobalMapKey()
 	_, found := c.matches[matcherKey]
 	if found {
 		klog.V(2).Infof("Conjunctive match flow with matcher %s is already added in rule: %d", matcherKey, c.action.conjID)
-		return nil
+		return
 	}
 
-	client.conjMatchFlowLock.Lock()
-	defer client.conjMatchFlowLock.Unlock()
-
-	// Get conjMatchFlowContext from globalConjMatchFlowCache. If it doesn't exist, create a new one and add into the cache.
-	context, found := client.globalConjMatchFlowCache[matcherKey]
-	if !found {
-		context = &conjMatchFlowContext{
-			conjunctiveMatch: match,
-			actions:          make(map[uint32]*conjunctiveAction),
-			client:           client,
-		}
-
-		// Install the default drop flow entry if dropTable is not nil.
-		if c.dropTable != nil && context.dropFlow == nil {
-			dropFlow := context.client.defaultDropFlow(c.dropTable.GetID(), match.matchKey, match.matchValue)
-			if err := dropFlow.Add(); err != nil {
-				return err
-			}
-			context.dropFlow = dropFlow
-		}
-		client.globalConjMatchFlowCache[matcherKey] = context
+	context := updates.getContext(matcherKey)
+	if context == nil {
+		context = updates.newContext(matcherKey, match)
+	}
+	if c.dropTable != nil && context.dropFlow == nil {
+		context.dropFlow = updates.client.defaultDropFlow(c.dropTable.GetID(), match.matchKey, match.matchValue)
 	}
 	if c.action.nClause > 1 {
-		// Add the conjunction into conjunctiveFlowContext's actions, and update the flow entry on the switch.
-		err := context.addAction(c.action)
-		if err != nil {
-			return err
+		// Add the conjunction into conjunctiveFlowContext's actions.
+		if context.addAction(c.action) {
+			context.flowModified = true
 		}
 	} else {
 		// Add the DENY-ALL rule into conjunctiveFlowContext's denyAllRules.
-		err := context.addDenyAllRule(c.action.conjID)
-		if err != nil {
-			return err
-		}
+		context.addDenyAllRule(c.action.conjID)
 	}
-
-	c.matches[matcherKey] = context
-
-	return nil
+	updates.matchChanges = append(updates.matchChanges, clauseMatchChange{clause: c, key: matcherKey, add: true})
 }
 
 func (c *clause) generateAddressConjMatch(addr types.Address, addrType types.AddressType) *conjunctiveMatch {
@@ -383,124 +487,113 @@ func (c *clause) generateAddressConjMatch(addr types.Address, addrType types.Add
 	return match
 }
 
-func getServiceMatchType(protocol *coreV1.Protocol) int {
+// getServiceMatchTypes returns the match types of the Service protocol, one per IP family of the Pod network.
+func (c *client) getServiceMatchTypes(protocol *coreV1.Protocol) []int {
+	var v4MatchType, v6MatchType int
 	switch *protocol {
-	case coreV1.ProtocolTCP:
-		return MatchTCPDstPort
 	case coreV1.ProtocolUDP:
-		return MatchUDPDstPort
+		v4MatchType, v6MatchType = MatchUDPDstPort, MatchUDPv6DstPort
 	case coreV1.ProtocolSCTP:
-		return MatchSCTPDstPort
+		v4MatchType, v6MatchType = MatchSCTPDstPort, MatchSCTPv6DstPort
 	default:
-		return MatchTCPDstPort
+		v4MatchType, v6MatchType = MatchTCPDstPort, MatchTCPv6DstPort
 	}
+	var matchTypes []int
+	if c.ipv4 {
+		matchTypes = append(matchTypes, v4MatchType)
+	}
+	if c.ipv6 {
+		matchTypes = append(matchTypes, v6MatchType)
+	}
+	return matchTypes
 }
 
-func (c *clause) generateServicePortConjMatch(port *v1.NetworkPolicyPort) *conjunctiveMatch {
-	matchKey := getServiceMatchType(port.Protocol)
+func (c *clause) generateServicePortConjMatches(client *client, port *v1.NetworkPolicyPort) []*conjunctiveMatch {
+	var matches []*conjunctiveMatch
 	matchValue := uint16(port.Port.IntVal)
-	match := &conjunctiveMatch{
-		tableID:    c.ruleTable.GetID(),
-		matchKey:   matchKey,
-		matchValue: matchValue,
+	for _, matchKey := range client.getServiceMatchTypes(port.Protocol) {
+		matches = append(matches, &conjunctiveMatch{
+			tableID:    c.ruleTable.GetID(),
+			matchKey:   matchKey,
+			matchValue: matchValue,
+		})
 	}
-	return match
+	return matches
 }
 
-// addAddrFlows translates the specified addresses to conjunctiveMatchFlow, and installs corresponding Openflow entry.
-func (c *clause) addAddrFlows(client *client, addrType types.AddressType, addresses []types.Address) error {
+// addAddrMatches stages the conjunctive matches of the specified addresses.
+func (c *clause) addAddrMatches(updates *conjMatchFlowUpdates, addrType types.AddressType, addresses []types.Address) {
 	for _, addr := range addresses {
 		match := c.generateAddressConjMatch(addr, addrType)
-		err := c.addConjunctiveMatchFlow(client, match)
-		if err != nil {
-			return err
-		}
+		c.addConjunctiveMatchFlow(updates, match)
 	}
-	return nil
 }
 
-// addServiceFlows translates the specified NetworkPolicyPorts to conjunctiveMatchFlow, and installs corresponding Openflow entry.
-func (c *clause) addServiceFlows(client *client, ports []*v1.NetworkPolicyPort) error {
+// addAddrFlows translates the specified addresses to conjunctiveMatchFlow, and installs corresponding Openflow entries
+// in one bundle.
+func (c *clause) addAddrFlows(client *client, addrType types.AddressType, addresses []types.Address) error {
+	return client.updateConjMatchFlows(func(updates *conjMatchFlowUpdates) {
+		c.addAddrMatches(updates, addrType, addresses)
+	})
+}
+
+// addServiceMatches stages the conjunctive matches of the specified NetworkPolicyPorts.
+func (c *clause) addServiceMatches(updates *conjMatchFlowUpdates, ports []*v1.NetworkPolicyPort) {
 	for _, port := range ports {
-		match := c.generateServicePortConjMatch(port)
-		err := c.addConjunctiveMatchFlow(client, match)
-		if err != nil {
-			return err
+		for _, match := range c.generateServicePortConjMatches(updates.client, port) {
+			c.addConjunctiveMatchFlow(updates, match)
 		}
 	}
-	return nil
 }
 
-// deleteConjunctiveMatchFlow deletes the specific conjunctiveAction from existing flow.
-func (c *clause) deleteConjunctiveMatchFlow(flowContextKey string) error {
-	context, found := c.matches[flowContextKey]
+// deleteConjunctiveMatchFlow stages the deletion of the specific conjunctiveAction from the existing flow.
+func (c *clause) deleteConjunctiveMatchFlow(updates *conjMatchFlowUpdates, flowContextKey string) {
 	// Match is not located in clause cache. It happens if the conjMatchFlowContext is already deleted from clause local cache.
-	if !found {
-		return nil
+	if _, found := c.matches[flowContextKey]; !found {
+		return
+	}
+	context := updates.getContext(flowContextKey)
+	if context == nil {
+		return
 	}
 
 	conjID := c.action.conjID
-	context.client.conjMatchFlowLock.Lock()
-	defer context.client.conjMatchFlowLock.Unlock()
 	if c.action.nClause > 1 {
 		// Delete the conjunctive action if it is in context actions.
-		_, found = context.actions[conjID]
-		if found {
-			err := context.deleteAction(conjID)
-			if err != nil {
-				return err
-			}
+		if context.deleteAction(conjID) {
+			context.flowModified = true
 		}
 	} else {
 		// Delete the DENY-ALL rule if it is in context denyAllRules.
-		_, found := context.denyAllRules[conjID]
-		if found {
-			delete(context.denyAllRules, conjID)
-		}
+		delete(context.denyAllRules, conjID)
 	}
 
 	// Uninstall default drop flow if both actions and denyAllRules are empty.
 	if len(context.actions) == 0 && len(context.denyAllRules) == 0 {
-		if context.dropFlow != nil {
-			if err := context.dropFlow.Delete(); err != nil {
-				return err
-			}
-			context.dropFlow = nil
-		}
-		// Remove the context from global cache after both the conjunctive match flow and the default drop
-		// flow are uninstalled from the switch.
-		delete(context.client.globalConjMatchFlowCache, context.generateGlobalMapKey())
+		context.dropFlow = nil
 	}
 
 	// Delete the key of conjMatchFlowContext from clause matches.
-	delete(c.matches, flowContextKey)
-	return nil
+	updates.matchChanges = append(updates.matchChanges, clauseMatchChange{clause: c, key: flowContextKey, add: false})
 }
 
 // deleteAddrFlows deletes conjunctiveMatchFlow relevant to the specified addresses from local cache,
-// and uninstalls Openflow entry.
-func (c *clause) deleteAddrFlows(addrType types.AddressType, addresses []types.Address) error {
-	for _, addr := range addresses {
-		match := c.generateAddressConjMatch(addr, addrType)
-		contextKey := match.generateGlobalMapKey()
-		err := c.deleteConjunctiveMatchFlow(contextKey)
-		if err != nil {
-			return err
+// and uninstalls Openflow entries in one bundle.
+func (c *clause) deleteAddrFlows(client *client, addrType types.AddressType, addresses []types.Address) error {
+	return client.updateConjMatchFlows(func(updates *conjMatchFlowUpdates) {
+		for _, addr := range addresses {
+			match := c.generateAddressConjMatch(addr, addrType)
+			c.deleteConjunctiveMatchFlow(updates, match.generateGlobalMapKey())
 		}
-	}
-	return nil
+	})
 }
 
-// deleteAllMatches deletes all conjunctiveMatchFlow in the clause, and removes Openflow entry. deleteAllMatches
-// is always invoked when NetworkPolicy rule is deleted.
-func (c *clause) deleteAllMatches() error {
+// deleteAllMatches stages the deletion of all conjunctiveMatchFlow in the clause. deleteAllMatches is always invoked
+// when NetworkPolicy rule is deleted.
+func (c *clause) deleteAllMatches(updates *conjMatchFlowUpdates) {
 	for key := range c.matches {
-		err := c.deleteConjunctiveMatchFlow(key)
-		if err != nil {
-			return err
-		}
+		c.deleteConjunctiveMatchFlow(updates, key)
 	}
-	return nil
 }
 
 func (c *policyRuleConjunction) getAddressClause(addrType types.AddressType) *clause {
@@ -531,10 +624,11 @@ func (c *policyRuleConjunction) getAddressClause(addrType types.AddressType) *cl
 // addresses in rule.To for ingress rule. No conjunctive match flow or conjunction action except flows are installed.
 // A DENY-ALL rule is configured with rule.ID, rule.Direction, and either rule.From(egress rule) or rule.To(ingress rule).
 // Other fields in the rule should be nil.
-// If there is an error in any clause's addAddrFlows or addServiceFlows, the conjunction action flow will never be hit.
-// If the default drop flow is already installed before this error, all packets will be dropped by the default drop flow,
-// Otherwise all packets will be allowed.
+// All the Openflow entries of the rule are installed in one bundle. If the bundle fails, none of them is installed and
+// the rule is not added into the cache, so that it can be installed again.
 func (c *client) InstallPolicyRuleFlows(rule *types.PolicyRule) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
 	// Check if the policyRuleConjunction is added into cache or not. If yes, return nil.
 	conj := c.getPolicyRuleConjunction(rule.ID)
 	if conj != nil {
@@ -572,64 +666,56 @@ func (c *client) InstallPolicyRuleFlows(rule *types.PolicyRule) error {
 		serviceID = nClause
 	}
 
-	// Conjunction action flows are installed only if the number of clauses in the conjunction is > 1. It should be a rule
-	// to drop all packets.  If the number is 1, no conjunctive match flows or conjunction action flows are installed,
-	// but the default drop flow is installed.
-	if nClause > 1 {
-		// Install action flows.
-		var actionFlows = []binding.Flow{
-			c.conjunctionActionFlow(rule.ID, ruleTable.GetID(), dropTable.GetNext()),
-		}
-		if rule.ExceptFrom != nil {
-			for _, addr := range rule.ExceptFrom {
-				flow := c.conjunctionExceptionFlow(rule.ID, ruleTable.GetID(), dropTable.GetID(), addr.GetMatchKey(types.SrcAddress), addr.GetValue())
-				actionFlows = append(actionFlows, flow)
-			}
-		}
-		if rule.ExceptTo != nil {
-			for _, addr := range rule.ExceptTo {
-				flow := c.conjunctionExceptionFlow(rule.ID, ruleTable.GetID(), dropTable.GetID(), addr.GetMatchKey(types.DstAddress), addr.GetValue())
-				actionFlows = append(actionFlows, flow)
+	err := c.updateConjMatchFlows(func(updates *conjMatchFlowUpdates) {
+		// Conjunction action flows are installed only if the number of clauses in the conjunction is > 1. It should be
+		// a rule to drop all packets. If the number is 1, no conjunctive match flows or conjunction action flows are
+		// installed, but the default drop flow is installed.
+		if nClause > 1 {
+			// Install action flows.
+			var actionFlows = c.conjunctionActionFlows(rule.ID, ruleTable.GetID(), dropTable.GetNext())
+			if rule.ExceptFrom != nil {
+				for _, addr := range rule.ExceptFrom {
+					flow := c.conjunctionExceptionFlow(rule.ID, ruleTable.GetID(), dropTable.GetID(), addr.GetMatchKey(types.SrcAddress), addr.GetValue())
+					actionFlows = append(actionFlows, flow)
+				}
 			}
-		}
-		for _, flow := range actionFlows {
-			err := flow.Add()
-			if err != nil {
-				return err
+			if rule.ExceptTo != nil {
+				for _, addr := range rule.ExceptTo {
+					flow := c.conjunctionExceptionFlow(rule.ID, ruleTable.GetID(), dropTable.GetID(), addr.GetMatchKey(types.DstAddress), addr.GetValue())
+					actionFlows = append(actionFlows, flow)
+				}
 			}
+			updates.addFlows = append(updates.addFlows, actionFlows...)
+			conj.actionFlows = actionFlows
 		}
-		conj.actionFlows = actionFlows
-	}
 
-	// Install conjunctive match flows if exists in rule.Form/To/Service
-	var defaultTable binding.Table
-	if rule.From != nil {
-		if isEgressRule {
-			defaultTable = dropTable
-		} else {
-			defaultTable = nil
-		}
-		conj.fromClause = conj.newClause(fromID, nClause, ruleTable, defaultTable)
-		if err := conj.fromClause.addAddrFlows(c, types.SrcAddress, rule.From); err != nil {
-			return err
-		}
-	}
-	if rule.To != nil {
-		if !isEgressRule {
-			defaultTable = dropTable
-		} else {
-			defaultTable = nil
+		// Install conjunctive match flows if exists in rule.Form/To/Service
+		var defaultTable binding.Table
+		if rule.From != nil {
+			if isEgressRule {
+				defaultTable = dropTable
+			} else {
+				defaultTable = nil
+			}
+			conj.fromClause = conj.newClause(fromID, nClause, ruleTable, defaultTable)
+			conj.fromClause.addAddrMatches(updates, types.SrcAddress, rule.From)
 		}
-		conj.toClause = conj.newClause(toID, nClause, ruleTable, defaultTable)
-		if err := conj.toClause.addAddrFlows(c, types.DstAddress, rule.To); err != nil {
-			return err
+		if rule.To != nil {
+			if !isEgressRule {
+				defaultTable = dropTable
+			} else {
+				defaultTable = nil
+			}
+			conj.toClause = conj.newClause(toID, nClause, ruleTable, defaultTable)
+			conj.toClause.addAddrMatches(updates, types.DstAddress, rule.To)
 		}
-	}
-	if rule.Service != nil {
-		conj.serviceClause = conj.newClause(serviceID, nClause, ruleTable, nil)
-		if err := conj.serviceClause.addServiceFlows(c, rule.Service); err != nil {
-			return err
+		if rule.Service != nil {
+			conj.serviceClause = conj.newClause(serviceID, nClause, ruleTable, nil)
+			conj.serviceClause.addServiceMatches(updates, rule.Service)
 		}
+	})
+	if err != nil {
+		return err
 	}
 	c.policyCache.Store(rule.ID, conj)
 	return nil
@@ -659,38 +745,25 @@ func (c *client) getPolicyRuleConjunction(ruleID uint32) *policyRuleConjunction
 // UninstallPolicyRuleFlows removes the Openflow entry relevant to the specified NetworkPolicy rule.
 // UninstallPolicyRuleFlows will do nothing if no Openflow entry for the rule is installed.
 func (c *client) UninstallPolicyRuleFlows(ruleID uint32) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
 	conj := c.getPolicyRuleConjunction(ruleID)
 	if conj == nil {
 		klog.V(2).Infof("policyRuleConjunction with ID %d not found", ruleID)
 		return nil
 	}
 
-	// Delete action flows
-	for _, flow := range conj.actionFlows {
-		err := flow.Delete()
-		if err != nil {
-			return err
-		}
-	}
-
-	// Remove conjunctive match flows grouped by this PolicyRuleConjunction's clauses.
-	if conj.fromClause != nil {
-		err := conj.fromClause.deleteAllMatches()
-		if err != nil {
-			return err
-		}
-	}
-	if conj.toClause != nil {
-		err := conj.toClause.deleteAllMatches()
-		if err != nil {
-			return err
-		}
-	}
-	if conj.serviceClause != nil {
-		err := conj.serviceClause.deleteAllMatches()
-		if err != nil {
-			return err
+	// Delete action flows and conjunctive match flows grouped by this PolicyRuleConjunction's clauses in one bundle.
+	err := c.updateConjMatchFlows(func(updates *conjMatchFlowUpdates) {
+		updates.delFlows = append(updates.delFlows, conj.actionFlows...)
+		for _, clause := range []*clause{conj.fromClause, conj.toClause, conj.serviceClause} {
+			if clause != nil {
+				clause.deleteAllMatches(updates)
+			}
 		}
+	})
+	if err != nil {
+		return err
 	}
 
 	// Remove policyRuleConjunction from client's policyCache.
@@ -701,6 +774,8 @@ func (c *client) UninstallPolicyRuleFlows(ruleID uint32) error {
 // AddPolicyRuleAddress adds one or multiple addresses to the specified NetworkPolicy rule. If addrType is srcAddress, the
 // addresses are added to PolicyRule.From, else to PolicyRule.To.
 func (c *client) AddPolicyRuleAddress(ruleID uint32, addrType types.AddressType, addresses []types.Address) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
 	conj := c.getPolicyRuleConjunction(ruleID)
 	// If policyRuleConjunction doesn't exist in client's policyCache return not found error. It should not happen, since
 	// NetworkPolicyController will guarantee the policyRuleConjunction is created before this method is called. The check
@@ -719,6 +794,8 @@ func (c *client) AddPolicyRuleAddress(ruleID uint32, addrType types.AddressType,
 // DeletePolicyRuleAddress removes addresses from the specified NetworkPolicy rule. If addrType is srcAddress, the addresses
 // are removed from PolicyRule.From, else from PolicyRule.To.
 func (c *client) DeletePolicyRuleAddress(ruleID uint32, addrType types.AddressType, addresses []types.Address) error {
+	c.replayMutex.RLock()
+	defer c.replayMutex.RUnlock()
 	conj := c.getPolicyRuleConjunction(ruleID)
 	// If policyRuleConjunction doesn't exist in client's policyCache return not found error. It should not happen, since
 	// NetworkPolicyController will guarantee the policyRuleConjunction is created before this method is called. The check
@@ -733,5 +810,30 @@ func (c *client) DeletePolicyRuleAddress(ruleID uint32, addrType types.AddressTy
 		return fmt.Errorf("no clause is using addrType %d", addrType)
 	}
 	// Remove policyRuleConjunction to actions of conjunctive match using specific address.
-	return clause.deleteAddrFlows(addrType, addresses)
+	return clause.deleteAddrFlows(c, addrType, addresses)
+}
+
+// replayPolicyFlows installs again the flows of all the realized NetworkPolicy rules in one bundle: the conjunction
+// action flows of the rules, and the conjunctive match flows and default drop flows shared by the rules.
+func (c *client) replayPolicyFlows() error {
+	c.conjMatchFlowLock.Lock()
+	defer c.conjMatchFlowLock.Unlock()
+
+	var flows []binding.Flow
+	c.policyCache.Range(func(_, value interface{}) bool {
+		flows = append(flows, value.(*policyRuleConjunction).actionFlows...)
+		return true
+	})
+	for _, ctx := range c.globalConjMatchFlowCache {
+		if ctx.flow != nil {
+			flows = append(flows, ctx.flow)
+		}
+		if ctx.dropFlow != nil {
+			flows = append(flows, ctx.dropFlow)
+		}
+	}
+	if len(flows) == 0 {
+		return nil
+	}
+	return c.flowOperations.AddAll(flows)
 }
@@ -1,6 +1,7 @@
 package openflow
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
@@ -15,6 +16,8 @@ import (
 	v1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
+	"github.com/vmware-tanzu/antrea/pkg/agent/openflow/cookie"
+	oftest "github.com/vmware-tanzu/antrea/pkg/agent/openflow/testing"
 	"github.com/vmware-tanzu/antrea/pkg/agent/types"
 	binding "github.com/vmware-tanzu/antrea/pkg/ovs/openflow"
 	mocks "github.com/vmware-tanzu/antrea/pkg/ovs/openflow/testing"
@@ -32,6 +35,8 @@ var (
 	dropFlow        *mocks.MockFlow
 
 	ruleAction *mocks.MockAction
+
+	flowOperations *oftest.MockFlowOperations
 )
 
 type expectConjunctionTimes struct {
@@ -58,8 +63,7 @@ func TestPolicyRuleConjunction(t *testing.T) {
 	outTable.EXPECT().BuildFlow().Return(newMockRuleFlowBuilder(ctrl)).AnyTimes()
 
 	var addedAddrs = parseAddresses([]string{"192.168.1.3", "192.168.1.30", "192.168.2.0/24", "103", "104"})
-	expectFlowInvokeTimes(dropFlow, 5, 0, 0)
-	expectFlowInvokeTimes(ruleFlow, 5, 0, 0)
+	expectBundleOps(10, 0, 0)
 	expectConjunctionsCount([]*expectConjunctionTimes{{5, ruleID1, clauseID, nClause}})
 	err := clause1.addAddrFlows(c, types.SrcAddress, addedAddrs)
 	require.Nil(t, err, "Failed to invoke addAddrFlows")
@@ -70,9 +74,8 @@ func TestPolicyRuleConjunction(t *testing.T) {
 	var currentFlowCount = len(c.globalConjMatchFlowCache)
 
 	var deletedAddrs = parseAddresses([]string{"192.168.1.3", "103"})
-	expectFlowInvokeTimes(dropFlow, 0, 0, 2)
-	expectFlowInvokeTimes(ruleFlow, 0, 0, 2)
-	err = clause1.deleteAddrFlows(types.SrcAddress, deletedAddrs)
+	expectBundleOps(0, 0, 4)
+	err = clause1.deleteAddrFlows(c, types.SrcAddress, deletedAddrs)
 	require.Nil(t, err, "Failed to invoke deleteAddrFlows")
 	checkFlowCount(t, currentFlowCount-len(deletedAddrs))
 	currentFlowCount = len(c.globalConjMatchFlowCache)
@@ -85,8 +88,7 @@ func TestPolicyRuleConjunction(t *testing.T) {
 	clause2 := conj2.newClause(clauseID2, nClause, outTable, outDropTable)
 	var addedAddrs2 = parseAddresses([]string{"192.168.1.30", "192.168.1.50"})
 
-	expectFlowInvokeTimes(dropFlow, 1, 0, 0)
-	expectFlowInvokeTimes(ruleFlow, 1, 1, 0)
+	expectBundleOps(2, 1, 0)
 	expectConjunctionsCount([]*expectConjunctionTimes{{2, ruleID2, clauseID2, nClause}})
 	expectConjunctionsCount([]*expectConjunctionTimes{{1, ruleID1, clauseID, nClause}})
 	err = clause2.addAddrFlows(c, types.SrcAddress, addedAddrs2)
@@ -108,7 +110,7 @@ func TestPolicyRuleConjunction(t *testing.T) {
 	require.Nil(t, err, "Failed to invoke addAddrFlows")
 	checkConjMatchFlowActions(t, c, clause3, testAddr, types.SrcAddress, 2, 1)
 	checkFlowCount(t, currentFlowCount)
-	err = clause3.deleteAddrFlows(types.SrcAddress, addedAddrs3)
+	err = clause3.deleteAddrFlows(c, types.SrcAddress, addedAddrs3)
 	require.Nil(t, err, "Failed to invoke deleteAddrFlows")
 	checkConjMatchFlowActions(t, c, clause3, testAddr, types.SrcAddress, 2, 0)
 	checkFlowCount(t, currentFlowCount)
@@ -129,7 +131,7 @@ func TestInstallPolicyRuleFlows(t *testing.T) {
 	outDropTable.EXPECT().BuildFlow().Return(newMockDropFlowBuilder(ctrl)).AnyTimes()
 	outTable.EXPECT().BuildFlow().Return(newMockRuleFlowBuilder(ctrl)).AnyTimes()
 
-	expectFlowInvokeTimes(dropFlow, 2, 0, 0)
+	expectBundleOps(2, 0, 0)
 	err := c.InstallPolicyRuleFlows(rule1)
 	if err != nil {
 		t.Fatalf("Failed to invoke InstallPolicyRuleFlows: %v", err)
@@ -143,8 +145,7 @@ func TestInstallPolicyRuleFlows(t *testing.T) {
 		From:      parseAddresses([]string{"192.168.1.40", "192.168.1.50"}),
 		To:        parseAddresses([]string{"0.0.0.0/0"}),
 	}
-	expectFlowInvokeTimes(dropFlow, 1, 0, 0)
-	expectFlowInvokeTimes(ruleFlow, 4, 0, 0)
+	expectBundleOps(5, 0, 0)
 	ruleFlowBuilder.EXPECT().MatchConjID(ruleID2).MaxTimes(1)
 	expectConjunctionsCount([]*expectConjunctionTimes{{1, ruleID2, 2, 2}})
 	expectConjunctionsCount([]*expectConjunctionTimes{{2, ruleID2, 1, 2}})
@@ -168,8 +169,7 @@ func TestInstallPolicyRuleFlows(t *testing.T) {
 		ExceptTo:  parseAddresses([]string{"192.168.2.100", "192.168.2.150"}),
 		Service:   []*v1.NetworkPolicyPort{npPort1, npPort2},
 	}
-	expectFlowInvokeTimes(dropFlow, 1, 0, 0)
-	expectFlowInvokeTimes(ruleFlow, 7, 1, 0)
+	expectBundleOps(8, 1, 0)
 	ruleFlowBuilder.EXPECT().MatchConjID(ruleID3).MaxTimes(3)
 	expectConjunctionsCount([]*expectConjunctionTimes{{1, ruleID2, 1, 2}})
 	expectConjunctionsCount([]*expectConjunctionTimes{{1, ruleID3, 2, 3}})
@@ -180,13 +180,71 @@ func TestInstallPolicyRuleFlows(t *testing.T) {
 	require.Nil(t, err, "Failed to invoke InstallPolicyRuleFlows")
 	checkConjunctionConfig(t, ruleID3, 3, 2, 1, 2)
 
-	expectFlowInvokeTimes(dropFlow, 0, 0, 1)
-	expectFlowInvokeTimes(ruleFlow, 0, 1, 3)
+	expectBundleOps(0, 1, 3)
 	expectConjunctionsCount([]*expectConjunctionTimes{{1, ruleID3, 1, 3}})
 	err = c.UninstallPolicyRuleFlows(ruleID2)
 	require.Nil(t, err, "Failed to invoke UninstallPolicyRuleFlows")
 }
 
+// TestInstallPolicyRuleFlowsFailure checks that the caches are unchanged when the bundle of a rule fails, so that
+// the rule can be installed again.
+func TestInstallPolicyRuleFlowsFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	c = prepareClient(ctrl)
+	outDropTable.EXPECT().BuildFlow().Return(newMockDropFlowBuilder(ctrl)).AnyTimes()
+	outTable.EXPECT().BuildFlow().Return(newMockRuleFlowBuilder(ctrl)).AnyTimes()
+	ruleAction.EXPECT().Conjunction(gomock.Any(), gomock.Any(), gomock.Any()).Return(ruleFlowBuilder).AnyTimes()
+
+	rule1 := &types.PolicyRule{
+		ID:        uint32(101),
+		Direction: v1.PolicyTypeEgress,
+		From:      parseAddresses([]string{"192.168.1.30", "192.168.1.50"}),
+		To:        parseAddresses([]string{"0.0.0.0/0"}),
+	}
+	expectBundleOps(6, 0, 0)
+	require.Nil(t, c.InstallPolicyRuleFlows(rule1), "Failed to invoke InstallPolicyRuleFlows")
+	testAddr := NewIPAddress(net.ParseIP("192.168.1.50"))
+	clause1 := c.getPolicyRuleConjunction(rule1.ID).fromClause
+	checkConjMatchFlowActions(t, c, clause1, testAddr, types.SrcAddress, 1, 0)
+	currentFlowCount := len(c.globalConjMatchFlowCache)
+
+	rule2 := &types.PolicyRule{
+		ID:        uint32(102),
+		Direction: v1.PolicyTypeEgress,
+		From:      parseAddresses([]string{"192.168.1.50", "192.168.1.60"}),
+		To:        parseAddresses([]string{"10.0.0.0/8"}),
+	}
+	flowOperations.EXPECT().BundleOps(flowCount(4), flowCount(1), flowCount(0)).Return(errors.New("OF error")).Times(1)
+	require.NotNil(t, c.InstallPolicyRuleFlows(rule2), "Installing rule flows is expected to fail")
+	assert.Nil(t, c.getPolicyRuleConjunction(rule2.ID), "Failed rule must not be added into policyCache")
+	checkFlowCount(t, currentFlowCount)
+	checkConjMatchFlowActions(t, c, clause1, testAddr, types.SrcAddress, 1, 0)
+
+	flowOperations.EXPECT().BundleOps(flowCount(0), flowCount(0), flowCount(6)).Return(errors.New("OF error")).Times(1)
+	require.NotNil(t, c.UninstallPolicyRuleFlows(rule1.ID), "Uninstalling rule flows is expected to fail")
+	assert.NotNil(t, c.getPolicyRuleConjunction(rule1.ID), "Rule must stay in policyCache")
+	assert.Equal(t, 2, len(clause1.matches))
+	checkFlowCount(t, currentFlowCount)
+
+	expectBundleOps(4, 1, 0)
+	require.Nil(t, c.InstallPolicyRuleFlows(rule2), "Failed to invoke InstallPolicyRuleFlows")
+	checkConjMatchFlowActions(t, c, clause1, testAddr, types.SrcAddress, 2, 0)
+	checkFlowCount(t, currentFlowCount+2)
+}
+
+// TestServiceMatchTypes checks that a Service port is matched for each IP family of the Pod network.
+func TestServiceMatchTypes(t *testing.T) {
+	udpProtocol := coreV1.ProtocolUDP
+	c := &client{ipv4: true}
+	assert.Equal(t, []int{MatchUDPDstPort}, c.getServiceMatchTypes(&udpProtocol))
+	c.ipv6 = true
+	assert.Equal(t, []int{MatchUDPDstPort, MatchUDPv6DstPort}, c.getServiceMatchTypes(&udpProtocol))
+	c.ipv4 = false
+	assert.Equal(t, []int{MatchUDPv6DstPort}, c.getServiceMatchTypes(&udpProtocol))
+}
+
 func checkConjunctionConfig(t *testing.T, ruleID uint32, actionFlowCount, fromMatchCount, toMatchCount, serviceMatchCount int) {
 	conj := c.getPolicyRuleConjunction(ruleID)
 	require.NotNil(t, conj, "Failed to add policyRuleConjunction into client cache")
@@ -215,16 +273,21 @@ func checkConjMatchFlowActions(t *testing.T, client *client, c *clause, address
 	assert.Equal(t, anyDropRuleCount, len(context.denyAllRules), fmt.Sprintf("Incorrect policyRuleConjunction anyDropRule number, expect: %d, actual: %d", anyDropRuleCount, len(context.denyAllRules)))
 }
 
-func expectFlowInvokeTimes(flow *mocks.MockFlow, addCount, modifyCount, deleteCount int) {
-	if addCount > 0 {
-		flow.EXPECT().Add().MaxTimes(addCount)
-	}
-	if modifyCount > 0 {
-		flow.EXPECT().Modify().MaxTimes(modifyCount)
-	}
-	if deleteCount > 0 {
-		flow.EXPECT().Delete().MaxTimes(deleteCount)
-	}
+// flowCount is a gomock.Matcher for a slice of flows with the expected length.
+type flowCount int
+
+func (n flowCount) Matches(x interface{}) bool {
+	flows, ok := x.([]binding.Flow)
+	return ok && len(flows) == int(n)
+}
+
+func (n flowCount) String() string {
+	return fmt.Sprintf("has %d flows", int(n))
+}
+
+// expectBundleOps expects one bundle which adds, modifies and deletes the specified numbers of flows.
+func expectBundleOps(addCount, modifyCount, deleteCount int) *gomock.Call {
+	return flowOperations.EXPECT().BundleOps(flowCount(addCount), flowCount(modifyCount), flowCount(deleteCount)).Return(nil).Times(1)
 }
 
 func expectConjunctionsCount(conjs []*expectConjunctionTimes) {
@@ -236,6 +299,7 @@ func expectConjunctionsCount(conjs []*expectConjunctionTimes) {
 func newMockDropFlowBuilder(ctrl *gomock.Controller) *mocks.MockFlowBuilder {
 	dropFlowBuilder = mocks.NewMockFlowBuilder(ctrl)
 	dropFlowBuilder.EXPECT().Priority(gomock.Any()).Return(dropFlowBuilder).AnyTimes()
+	dropFlowBuilder.EXPECT().Cookie(gomock.Any()).Return(dropFlowBuilder).AnyTimes()
 	dropFlowBuilder.EXPECT().MatchProtocol(gomock.Any()).Return(dropFlowBuilder).AnyTimes()
 	dropFlowBuilder.EXPECT().MatchDstIPNet(gomock.Any()).Return(dropFlowBuilder).AnyTimes()
 	dropFlowBuilder.EXPECT().MatchSrcIPNet(gomock.Any()).Return(dropFlowBuilder).AnyTimes()
@@ -254,6 +318,7 @@ func newMockDropFlowBuilder(ctrl *gomock.Controller) *mocks.MockFlowBuilder {
 func newMockRuleFlowBuilder(ctrl *gomock.Controller) *mocks.MockFlowBuilder {
 	ruleFlowBuilder = mocks.NewMockFlowBuilder(ctrl)
 	ruleFlowBuilder.EXPECT().Priority(gomock.Any()).Return(ruleFlowBuilder).AnyTimes()
+	ruleFlowBuilder.EXPECT().Cookie(gomock.Any()).Return(ruleFlowBuilder).AnyTimes()
 	ruleFlowBuilder.EXPECT().MatchProtocol(gomock.Any()).Return(ruleFlowBuilder).AnyTimes()
 	ruleFlowBuilder.EXPECT().MatchDstIPNet(gomock.Any()).Return(ruleFlowBuilder).AnyTimes()
 	ruleFlowBuilder.EXPECT().MatchSrcIPNet(gomock.Any()).Return(ruleFlowBuilder).AnyTimes()
@@ -303,7 +368,9 @@ func prepareClient(ctrl *gomock.Controller) *client {
 	outTable = createMockTable(ctrl, egressRuleTable, egressDefaultTable, binding.TableMissActionNext)
 	outDropTable = createMockTable(ctrl, egressDefaultTable, l3ForwardingTable, binding.TableMissActionNext)
 	outAllowTable = createMockTable(ctrl, l3ForwardingTable, l2ForwardingCalcTable, binding.TableMissActionNext)
+	flowOperations = oftest.NewMockFlowOperations(ctrl)
 	c = &client{
+		flowOperations: flowOperations,
 		pipeline: map[binding.TableIDType]binding.Table{
 			egressRuleTable:    outTable,
 			egressDefaultTable: outDropTable,
@@ -311,6 +378,8 @@ func prepareClient(ctrl *gomock.Controller) *client {
 		},
 		policyCache:              sync.Map{},
 		globalConjMatchFlowCache: map[string]*conjMatchFlowContext{},
+		cookieAllocator:          cookie.NewAllocator(1),
+		ipv4:                     true,
 	}
 	return c
 }
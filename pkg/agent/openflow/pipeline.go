@@ -15,10 +15,15 @@
 package openflow
 
 import (
+	"encoding/binary"
 	"fmt"
 	"net"
 	"sync"
 
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/vmware-tanzu/antrea/pkg/agent/openflow/cookie"
+	"github.com/vmware-tanzu/antrea/pkg/agent/types"
 	binding "github.com/vmware-tanzu/antrea/pkg/ovs/openflow"
 )
 
@@ -29,7 +34,9 @@ const (
 	arpResponderTable     binding.TableIDType = 20
 	conntrackTable        binding.TableIDType = 30
 	conntrackStateTable   binding.TableIDType = 31
-	dnatTable             binding.TableIDType = 40
+	sessionAffinityTable  binding.TableIDType = 40
+	serviceLBTable        binding.TableIDType = 41
+	endpointDNATTable     binding.TableIDType = 42
 	egressRuleTable       binding.TableIDType = 50
 	egressDefaultTable    binding.TableIDType = 60
 	l3ForwardingTable     binding.TableIDType = 70
@@ -39,11 +46,19 @@ const (
 	l2ForwardingOutTable  binding.TableIDType = 110
 
 	// Flow priority level
-	priorityHigh   = 210
+	priorityHigh = 210
+	// priorityNDDrop is the priority of the flows which drop the Neighbor Discovery messages not allowed by the ND
+	// spoof guard flows. It is above priorityNormal, so that these messages are not allowed by the IP spoof guard
+	// flows instead.
+	priorityNDDrop = 205
 	priorityNormal = 200
 	priorityLow    = 190
 	priorityMiss   = 80
 
+	// Types of the ICMPv6 Neighbor Discovery messages.
+	icmp6TypeNeighborSolicitation  = 135
+	icmp6TypeNeighborAdvertisement = 136
+
 	// Traffic marks
 	markTrafficFromTunnel  = 0
 	markTrafficFromGateway = 1
@@ -56,6 +71,15 @@ var (
 	ofPortMarkRange = binding.Range{16, 16}
 	// ofPortRegRange takes a 32-bit range of register portCacheReg to cache the ofPort number of the interface.
 	ofPortRegRange = binding.Range{0, 31}
+	// endpointIPRegRange takes a 32-bit range of register endpointIPReg to store the IPv4 address of the Endpoint
+	// selected for a Service connection.
+	endpointIPRegRange = binding.Range{0, 31}
+	// endpointPortRegRange takes the 16 lower bits of register endpointPortReg to store the port of the selected
+	// Endpoint.
+	endpointPortRegRange = binding.Range{0, 15}
+	// serviceEPStateRange takes the 16th and 17th bits of register endpointPortReg to store the state of the Endpoint
+	// selection.
+	serviceEPStateRange = binding.Range{16, 17}
 )
 
 type regType uint
@@ -82,23 +106,62 @@ const (
 	// traffic-source resides in [0..15], pod-found resides in [16].
 	marksReg     regType = 0
 	portCacheReg regType = 1
+	// endpointIPReg stores the IP address of the Endpoint selected for a Service connection.
+	endpointIPReg regType = 3
+	// endpointPortReg stores the port of the selected Endpoint in [0..15] and the selection state in [16..17].
+	endpointPortReg regType = 4
 
 	ctZone = 0xfff0
 
 	portFoundMark = 0x1
 	gatewayCTMark = 0x20
+
+	// States of the Endpoint selection of a Service connection, stored in serviceEPStateRange. The Endpoint is
+	// either to be selected by the group of the Service, selected but still to be learned for the session
+	// affinity, or selected.
+	serviceEPToSelect = 0x0
+	serviceEPSelected = 0x1
+	serviceEPToLearn  = 0x2
 )
 
 var (
-	globalVirtualMAC, _ = net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	// GlobalVirtualMAC is the MAC address of the remote gateways, and the one of the next hop of the routes which
+	// send the Service traffic of the host to the gateway.
+	GlobalVirtualMAC, _ = net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	// unspecifiedMAC is the value of the nd_sll and nd_tll fields of the Neighbor Discovery messages which do not
+	// carry the link-layer address option.
+	unspecifiedMAC = net.HardwareAddr{0, 0, 0, 0, 0, 0}
 )
 
+// ipv6Protocols maps the protocols used by the pipeline to their counterpart for IPv6.
+var ipv6Protocols = map[string]string{
+	binding.ProtocolIP:   binding.ProtocolIPv6,
+	binding.ProtocolTCP:  binding.ProtocolTCPv6,
+	binding.ProtocolUDP:  binding.ProtocolUDPv6,
+	binding.ProtocolSCTP: binding.ProtocolSCTPv6,
+	binding.ProtocolICMP: binding.ProtocolICMPv6,
+}
+
+// protocolOf returns the protocol for the IP family of the address, e.g. "tcp6" for "tcp" with an IPv6 address.
+func protocolOf(protocol string, ip net.IP) string {
+	if ip.To4() != nil {
+		return protocol
+	}
+	return ipv6Protocols[protocol]
+}
+
 //go:generate mockgen -copyright_file ../../../hack/boilerplate/license_header.go.txt -package=testing -destination testing/mock_operations.go github.com/vmware-tanzu/antrea/pkg/agent/openflow FlowOperations
 
 type FlowOperations interface {
 	Add(flow binding.Flow) error
 	Modify(flow binding.Flow) error
 	Delete(flow binding.Flow) error
+	// AddAll adds all the flows in one bundle, either all of them are added or none is.
+	AddAll(flows []binding.Flow) error
+	// DeleteAll deletes all the flows in one bundle, either all of them are deleted or none is.
+	DeleteAll(flows []binding.Flow) error
+	// BundleOps adds, modifies and deletes the flows in one bundle, either all the changes are applied or none is.
+	BundleOps(adds []binding.Flow, mods []binding.Flow, dels []binding.Flow) error
 }
 
 type flowCache map[string]binding.Flow
@@ -111,7 +174,15 @@ type client struct {
 	bridge                                    binding.Bridge
 	pipeline                                  map[binding.TableIDType]binding.Table
 	nodeFlowCache, podFlowCache, serviceCache *flowCategoryCache // cache for corresponding deletions
-	flowOperations                            FlowOperations
+	// defaultFlowCache caches the flows of the gateway and tunnel ports, so that they can be replayed.
+	defaultFlowCache *flowCategoryCache
+	flowOperations   FlowOperations
+	// replayMutex is held by ReplayFlows and when deleting the stale groups, and read-held by the methods which
+	// install or uninstall the flows of an entity, so that a replay or a deletion of the stale groups doesn't
+	// interleave with the changes of the caches it relies on.
+	replayMutex sync.RWMutex
+	// cookieAllocator allocates the cookies of the flows, which encode the round number and the flow category.
+	cookieAllocator *cookie.Allocator
 	// policyCache is a map from PolicyRule ID to policyRuleConjunction. It's guaranteed that one policyRuleConjunction
 	// is processed by at most one goroutine at any given time.
 	policyCache       sync.Map
@@ -119,6 +190,28 @@ type client struct {
 	// globalConjMatchFlowCache is a global map for conjMatchFlowContext. The key is a string generated from the
 	// conjMatchFlowContext.
 	globalConjMatchFlowCache map[string]*conjMatchFlowContext
+	// enableProxy indicates that the Service traffic is load balanced by the pipeline instead of kube-proxy.
+	enableProxy bool
+	// groupCache is a map from the ID of the group of a Service to the binding.Group, so that the groups can be
+	// replayed.
+	groupCache sync.Map
+	// ipv4 and ipv6 indicate the IP families of the Pod network, both are true with dual-stack. They are set by
+	// Initialize from the Pod CIDRs of the Node.
+	ipv4 bool
+	ipv6 bool
+}
+
+// ipProtocols returns the protocol for each IP family of the Pod network, e.g. "tcp" and "tcp6" for "tcp" with
+// dual-stack. It is used by the flows which do not match any address.
+func (c *client) ipProtocols(protocol string) []string {
+	var protocols []string
+	if c.ipv4 {
+		protocols = append(protocols, protocol)
+	}
+	if c.ipv6 {
+		protocols = append(protocols, ipv6Protocols[protocol])
+	}
+	return protocols
 }
 
 func (c *client) Add(flow binding.Flow) error {
@@ -133,21 +226,35 @@ func (c *client) Delete(flow binding.Flow) error {
 	return flow.Delete()
 }
 
+func (c *client) AddAll(flows []binding.Flow) error {
+	return c.bridge.AddFlowsInBundle(flows, nil, nil)
+}
+
+func (c *client) DeleteAll(flows []binding.Flow) error {
+	return c.bridge.AddFlowsInBundle(nil, nil, flows)
+}
+
+func (c *client) BundleOps(adds []binding.Flow, mods []binding.Flow, dels []binding.Flow) error {
+	return c.bridge.AddFlowsInBundle(adds, mods, dels)
+}
+
 // defaultFlows generates the default flows of all tables.
 func (c *client) defaultFlows() (flows []binding.Flow) {
 	for _, table := range c.pipeline {
-		flowBuilder := table.BuildFlow().Priority(priorityMiss).MatchProtocol(binding.ProtocolIP)
-		switch table.GetMissAction() {
-		case binding.TableMissActionNext:
-			flowBuilder = flowBuilder.Action().Resubmit(emptyPlaceholderStr, table.GetNext())
-		case binding.TableMissActionNormal:
-			flowBuilder = flowBuilder.Action().Normal()
-		case binding.TableMissActionDrop:
-			fallthrough
-		default:
-			flowBuilder = flowBuilder.Action().Drop()
+		for _, ipProtocol := range c.ipProtocols(binding.ProtocolIP) {
+			flowBuilder := table.BuildFlow().Priority(priorityMiss).MatchProtocol(ipProtocol)
+			switch table.GetMissAction() {
+			case binding.TableMissActionNext:
+				flowBuilder = flowBuilder.Action().Resubmit(emptyPlaceholderStr, table.GetNext())
+			case binding.TableMissActionNormal:
+				flowBuilder = flowBuilder.Action().Normal()
+			case binding.TableMissActionDrop:
+				fallthrough
+			default:
+				flowBuilder = flowBuilder.Action().Drop()
+			}
+			flows = append(flows, flowBuilder.Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).Done())
 		}
-		flows = append(flows, flowBuilder.Done())
 	}
 	return flows
 }
@@ -158,6 +265,7 @@ func (c *client) tunnelClassifierFlow(tunnelOFPort uint32) binding.Flow {
 		MatchInPort(tunnelOFPort).
 		Action().LoadRegRange(int(marksReg), markTrafficFromTunnel, binding.Range{0, 15}).
 		Action().Resubmit(emptyPlaceholderStr, conntrackTable).
+		Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
 		Done()
 }
 
@@ -168,6 +276,7 @@ func (c *client) gatewayClassifierFlow(gatewayOFPort uint32) binding.Flow {
 		MatchInPort(gatewayOFPort).
 		Action().LoadRegRange(int(marksReg), markTrafficFromGateway, binding.Range{0, 15}).
 		Action().Resubmit(emptyPlaceholderStr, classifierTable.GetNext()).
+		Cookie(c.cookieAllocator.Request(cookie.Gateway).Raw()).
 		Done()
 }
 
@@ -178,6 +287,7 @@ func (c *client) podClassifierFlow(podOFPort uint32) binding.Flow {
 		MatchInPort(podOFPort).
 		Action().LoadRegRange(int(marksReg), markTrafficFromLocal, binding.Range{0, 15}).
 		Action().Resubmit(emptyPlaceholderStr, classifierTable.GetNext()).
+		Cookie(c.cookieAllocator.Request(cookie.Pod).Raw()).
 		Done()
 }
 
@@ -189,106 +299,146 @@ func (c *client) podClassifierFlow(podOFPort uint32) binding.Flow {
 // 4) Drop all invalid traffic.
 func (c *client) connectionTrackFlows() (flows []binding.Flow) {
 	connectionTrackTable := c.pipeline[conntrackTable]
-	baseConnectionTrackFlow := connectionTrackTable.BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityNormal).
-		Action().CT(false, connectionTrackTable.GetNext(), ctZone).CTDone().
-		Done()
-	flows = append(flows, baseConnectionTrackFlow)
-
 	connectionTrackStateTable := c.pipeline[conntrackStateTable]
-	gatewayReplyFlow := connectionTrackStateTable.BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityHigh).
-		MatchRegRange(int(marksReg), markTrafficFromGateway, binding.Range{0, 15}).
-		MatchCTMark(i2h(gatewayCTMark)).
-		MatchCTState("-new+trk").
-		Action().Resubmit(emptyPlaceholderStr, connectionTrackStateTable.GetNext()).
-		Done()
-	flows = append(flows, gatewayReplyFlow)
-
-	gatewaySendFlow := connectionTrackStateTable.BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityNormal).
-		MatchRegRange(int(marksReg), markTrafficFromGateway, binding.Range{0, 15}).
-		MatchCTState("+new+trk").
-		Action().CT(true, connectionTrackStateTable.GetNext(), ctZone).LoadToMark(gatewayCTMark).MoveToLabel(binding.NxmFieldSrcMAC, &binding.Range{0, 47}, &binding.Range{0, 47}).CTDone().
-		Done()
-	flows = append(flows, gatewaySendFlow)
-
-	podReplyGatewayFlow := connectionTrackStateTable.BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityNormal).
-		MatchCTMark(i2h(gatewayCTMark)).
-		MatchCTState("-new+trk").
-		Action().MoveRange(binding.NxmFieldCtLabel, binding.NxmFieldDstMAC, binding.Range{0, 47}, binding.Range{0, 47}).
-		Action().Resubmit(emptyPlaceholderStr, connectionTrackStateTable.GetNext()).
-		Done()
-	flows = append(flows, podReplyGatewayFlow)
-
-	nonGatewaySendFlow := connectionTrackStateTable.BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityLow).
-		MatchCTState("+new+trk").
-		Action().CT(true, connectionTrackStateTable.GetNext(), ctZone).CTDone().
-		Done()
-	flows = append(flows, nonGatewaySendFlow)
-
-	invCTFlow := connectionTrackStateTable.BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityNormal).
-		MatchCTState("+new+inv").
-		Action().Drop().
-		Done()
-	flows = append(flows, invCTFlow)
+	for _, ipProtocol := range c.ipProtocols(binding.ProtocolIP) {
+		// The translation of the connections committed with DNAT by the endpointDNATTable is applied to their packets,
+		// so that the following tables see the address of the Endpoint instead of the one of the Service, and its
+		// reverse to the reply packets.
+		baseConnectionTrackFlow := connectionTrackTable.BuildFlow().MatchProtocol(ipProtocol).Priority(priorityNormal).
+			Action().CT(false, connectionTrackTable.GetNext(), ctZone).NAT().CTDone().
+			Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
+			Done()
+		flows = append(flows, baseConnectionTrackFlow)
+
+		gatewayReplyFlow := connectionTrackStateTable.BuildFlow().MatchProtocol(ipProtocol).Priority(priorityHigh).
+			MatchRegRange(int(marksReg), markTrafficFromGateway, binding.Range{0, 15}).
+			MatchCTMark(i2h(gatewayCTMark)).
+			MatchCTState("-new+trk").
+			Action().Resubmit(emptyPlaceholderStr, connectionTrackStateTable.GetNext()).
+			Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
+			Done()
+		flows = append(flows, gatewayReplyFlow)
+
+		gatewaySendFlow := connectionTrackStateTable.BuildFlow().MatchProtocol(ipProtocol).Priority(priorityNormal).
+			MatchRegRange(int(marksReg), markTrafficFromGateway, binding.Range{0, 15}).
+			MatchCTState("+new+trk").
+			Action().CT(true, connectionTrackStateTable.GetNext(), ctZone).LoadToMark(gatewayCTMark).MoveToLabel(binding.NxmFieldSrcMAC, &binding.Range{0, 47}, &binding.Range{0, 47}).CTDone().
+			Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
+			Done()
+		flows = append(flows, gatewaySendFlow)
+
+		podReplyGatewayFlow := connectionTrackStateTable.BuildFlow().MatchProtocol(ipProtocol).Priority(priorityNormal).
+			MatchCTMark(i2h(gatewayCTMark)).
+			MatchCTState("-new+trk").
+			Action().MoveRange(binding.NxmFieldCtLabel, binding.NxmFieldDstMAC, binding.Range{0, 47}, binding.Range{0, 47}).
+			Action().Resubmit(emptyPlaceholderStr, connectionTrackStateTable.GetNext()).
+			Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
+			Done()
+		flows = append(flows, podReplyGatewayFlow)
+
+		nonGatewaySendFlow := connectionTrackStateTable.BuildFlow().MatchProtocol(ipProtocol).Priority(priorityLow).
+			MatchCTState("+new+trk").
+			Action().CT(true, connectionTrackStateTable.GetNext(), ctZone).CTDone().
+			Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
+			Done()
+		flows = append(flows, nonGatewaySendFlow)
+
+		invCTFlow := connectionTrackStateTable.BuildFlow().MatchProtocol(ipProtocol).Priority(priorityNormal).
+			MatchCTState("+new+inv").
+			Action().Drop().
+			Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
+			Done()
+		flows = append(flows, invCTFlow)
+	}
 
 	return flows
 }
 
 // l2ForwardCalcFlow generates the flow that matches dst MAC and loads ofPort to reg.
-func (c *client) l2ForwardCalcFlow(dstMAC net.HardwareAddr, ofPort uint32) binding.Flow {
+func (c *client) l2ForwardCalcFlow(dstMAC net.HardwareAddr, ofPort uint32, category cookie.Category) binding.Flow {
 	l2FwdCalcTable := c.pipeline[l2ForwardingCalcTable]
 	return l2FwdCalcTable.BuildFlow().Priority(priorityNormal).
 		MatchDstMAC(dstMAC).
 		Action().LoadRegRange(int(portCacheReg), ofPort, ofPortRegRange).
 		Action().LoadRegRange(int(marksReg), portFoundMark, ofPortMarkRange).
 		Action().Resubmit(emptyPlaceholderStr, l2FwdCalcTable.GetNext()).
+		Cookie(c.cookieAllocator.Request(category).Raw()).
 		Done()
 }
 
-// l2ForwardOutputFlow generates the flow that outputs packets to OVS port after L2 forwarding calculation.
-func (c *client) l2ForwardOutputFlow() binding.Flow {
-	return c.pipeline[l2ForwardingOutTable].BuildFlow().
-		Priority(priorityNormal).
-		MatchProtocol(binding.ProtocolIP).
-		MatchRegRange(int(marksReg), portFoundMark, ofPortMarkRange).
-		Action().OutputRegRange(int(portCacheReg), ofPortRegRange).
-		Done()
+// l2ForwardOutputFlows generates the flows that output packets to OVS port after L2 forwarding calculation.
+func (c *client) l2ForwardOutputFlows() (flows []binding.Flow) {
+	for _, ipProtocol := range c.ipProtocols(binding.ProtocolIP) {
+		flows = append(flows, c.pipeline[l2ForwardingOutTable].BuildFlow().
+			Priority(priorityNormal).
+			MatchProtocol(ipProtocol).
+			MatchRegRange(int(marksReg), portFoundMark, ofPortMarkRange).
+			Action().OutputRegRange(int(portCacheReg), ofPortRegRange).
+			Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
+			Done())
+	}
+	return flows
 }
 
 // l3FlowsToPod generates the flow to rewrite MAC if the packet is received from tunnel port and destined for local Pods.
 func (c *client) l3FlowsToPod(localGatewayMAC net.HardwareAddr, podInterfaceIP net.IP, podInterfaceMAC net.HardwareAddr) binding.Flow {
 	l3FwdTable := c.pipeline[l3ForwardingTable]
 	// Rewrite src MAC to local gateway MAC, and rewrite dst MAC to pod MAC
-	return l3FwdTable.BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityNormal).
-		MatchDstMAC(globalVirtualMAC).
+	return l3FwdTable.BuildFlow().MatchProtocol(protocolOf(binding.ProtocolIP, podInterfaceIP)).Priority(priorityNormal).
+		MatchDstMAC(GlobalVirtualMAC).
 		MatchDstIP(podInterfaceIP).
 		Action().SetSrcMAC(localGatewayMAC).
 		Action().SetDstMAC(podInterfaceMAC).
 		Action().DecTTL().
 		Action().Resubmit(emptyPlaceholderStr, l3FwdTable.GetNext()).
+		Cookie(c.cookieAllocator.Request(cookie.Pod).Raw()).
 		Done()
 }
 
 // l3ToGatewayFlow generates flow that rewrites MAC of the packet received from tunnel port and destined to local gateway.
 func (c *client) l3ToGatewayFlow(localGatewayIP net.IP, localGatewayMAC net.HardwareAddr) binding.Flow {
 	l3FwdTable := c.pipeline[l3ForwardingTable]
-	return l3FwdTable.BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityNormal).
+	return l3FwdTable.BuildFlow().MatchProtocol(protocolOf(binding.ProtocolIP, localGatewayIP)).Priority(priorityNormal).
 		MatchDstIP(localGatewayIP).
 		Action().SetDstMAC(localGatewayMAC).
 		Action().Resubmit(emptyPlaceholderStr, l3FwdTable.GetNext()).
+		Cookie(c.cookieAllocator.Request(cookie.Gateway).Raw()).
 		Done()
 }
 
 // l3FwdFlowToRemote generates the L3 forward flow on source node to support traffic to remote pods/gateway.
-func (c *client) l3FwdFlowToRemote(localGatewayMAC net.HardwareAddr, peerSubnet net.IPNet, tunnelPeer net.IP) binding.Flow {
+// If tunOFPort is not 0, the traffic is output to this tunnel port, which has the remote IP of the remote Node.
+func (c *client) l3FwdFlowToRemote(localGatewayMAC net.HardwareAddr, peerSubnet net.IPNet, tunnelPeer net.IP, tunOFPort uint32) binding.Flow {
 	l3FwdTable := c.pipeline[l3ForwardingTable]
 	// Rewrite src MAC to local gateway MAC and rewrite dst MAC to virtual MAC
-	return l3FwdTable.BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityNormal).
+	flowBuilder := l3FwdTable.BuildFlow().MatchProtocol(protocolOf(binding.ProtocolIP, peerSubnet.IP)).Priority(priorityNormal).
 		MatchDstIPNet(peerSubnet).
 		Action().DecTTL().
 		Action().SetSrcMAC(localGatewayMAC).
-		Action().SetDstMAC(globalVirtualMAC).
-		Action().SetTunnelDst(tunnelPeer).
+		Action().SetDstMAC(GlobalVirtualMAC)
+	if tunOFPort == 0 {
+		flowBuilder = flowBuilder.Action().SetTunnelDst(tunnelPeer).
+			Action().Resubmit(emptyPlaceholderStr, l3FwdTable.GetNext())
+	} else {
+		// The L2 forwarding calculation would select the flow based tunnel port for the virtual MAC, so the tunnel port
+		// is loaded here and the L2 forwarding calculation table is skipped.
+		flowBuilder = flowBuilder.Action().LoadRegRange(int(portCacheReg), tunOFPort, ofPortRegRange).
+			Action().LoadRegRange(int(marksReg), portFoundMark, ofPortMarkRange).
+			Action().Resubmit(emptyPlaceholderStr, c.pipeline[l2ForwardingCalcTable].GetNext())
+	}
+	return flowBuilder.Cookie(c.cookieAllocator.Request(cookie.Node).Raw()).
+		Done()
+}
+
+// l3FwdFlowToRemoteViaGW generates the L3 forward flow on the source Node which sends the traffic to a remote Pod CIDR
+// to the local gateway, without encapsulation. The host routes the traffic to the remote Node and decrements the TTL.
+func (c *client) l3FwdFlowToRemoteViaGW(localGatewayMAC net.HardwareAddr, peerSubnet net.IPNet) binding.Flow {
+	l3FwdTable := c.pipeline[l3ForwardingTable]
+	return l3FwdTable.BuildFlow().MatchProtocol(protocolOf(binding.ProtocolIP, peerSubnet.IP)).Priority(priorityNormal).
+		MatchDstIPNet(peerSubnet).
+		Action().SetDstMAC(localGatewayMAC).
 		Action().Resubmit(emptyPlaceholderStr, l3FwdTable.GetNext()).
+		Cookie(c.cookieAllocator.Request(cookie.Node).Raw()).
 		Done()
 }
 
@@ -300,13 +450,14 @@ func (c *client) arpResponderFlow(peerGatewayIP net.IP) binding.Flow {
 		MatchARPOp(1).
 		MatchARPTpa(peerGatewayIP).
 		Action().Move(binding.NxmFieldSrcMAC, binding.NxmFieldDstMAC).
-		Action().SetSrcMAC(globalVirtualMAC).
+		Action().SetSrcMAC(GlobalVirtualMAC).
 		Action().LoadARPOperation(2).
 		Action().Move(binding.NxmFieldARPSha, binding.NxmFieldARPTha).
-		Action().SetARPSha(globalVirtualMAC).
+		Action().SetARPSha(GlobalVirtualMAC).
 		Action().Move(binding.NxmFieldARPSpa, binding.NxmFieldARPTpa).
 		Action().SetARPSpa(peerGatewayIP).
 		Action().OutputInPort().
+		Cookie(c.cookieAllocator.Request(cookie.Node).Raw()).
 		Done()
 }
 
@@ -315,11 +466,12 @@ func (c *client) arpResponderFlow(peerGatewayIP net.IP) binding.Flow {
 func (c *client) podIPSpoofGuardFlow(ifIP net.IP, ifMAC net.HardwareAddr, ifOFPort uint32) binding.Flow {
 	ipPipeline := c.pipeline
 	ipSpoofGuardTable := ipPipeline[spoofGuardTable]
-	return ipSpoofGuardTable.BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityNormal).
+	return ipSpoofGuardTable.BuildFlow().MatchProtocol(protocolOf(binding.ProtocolIP, ifIP)).Priority(priorityNormal).
 		MatchInPort(ifOFPort).
 		MatchSrcMAC(ifMAC).
 		MatchSrcIP(ifIP).
 		Action().Resubmit(emptyPlaceholderStr, ipSpoofGuardTable.GetNext()).
+		Cookie(c.cookieAllocator.Request(cookie.Pod).Raw()).
 		Done()
 }
 
@@ -328,6 +480,7 @@ func (c *client) gatewayARPSpoofGuardFlow(gatewayOFPort uint32) binding.Flow {
 	return c.pipeline[spoofGuardTable].BuildFlow().MatchProtocol(binding.ProtocolARP).Priority(priorityNormal).
 		MatchInPort(gatewayOFPort).
 		Action().Resubmit(emptyPlaceholderStr, arpResponderTable).
+		Cookie(c.cookieAllocator.Request(cookie.Gateway).Raw()).
 		Done()
 }
 
@@ -338,42 +491,262 @@ func (c *client) arpSpoofGuardFlow(ifIP net.IP, ifMAC net.HardwareAddr, ifOFPort
 		MatchARPSha(ifMAC).
 		MatchARPSpa(ifIP).
 		Action().Resubmit(emptyPlaceholderStr, arpResponderTable).
+		Cookie(c.cookieAllocator.Request(cookie.Pod).Raw()).
 		Done()
 }
 
-// gatewayIPSpoofGuardFlow generates the flow to skip spoof guard checking for traffic sent from gateway interface.
-func (c *client) gatewayIPSpoofGuardFlow(gatewayOFPort uint32) binding.Flow {
+// gatewayNDSpoofGuardFlows generates the flows to skip the ND spoof guard check on the Neighbor Solicitations and
+// Advertisements sent out from the local gateway interface.
+func (c *client) gatewayNDSpoofGuardFlows(gatewayOFPort uint32) (flows []binding.Flow) {
+	for _, icmp6Type := range []uint8{icmp6TypeNeighborSolicitation, icmp6TypeNeighborAdvertisement} {
+		flows = append(flows, c.pipeline[spoofGuardTable].BuildFlow().MatchProtocol(binding.ProtocolICMPv6).Priority(priorityHigh).
+			MatchInPort(gatewayOFPort).
+			MatchICMPv6Type(icmp6Type).
+			Action().Resubmit(emptyPlaceholderStr, arpResponderTable).
+			Cookie(c.cookieAllocator.Request(cookie.Gateway).Raw()).
+			Done())
+	}
+	return flows
+}
+
+// ndSpoofGuardFlows generates the flows to check the Neighbor Solicitations and Advertisements sent out from local
+// Pods interfaces: the source MAC and the link-layer address option, if any, must be the MAC of the interface, and
+// the target of the Advertisements must be its IP.
+func (c *client) ndSpoofGuardFlows(ifIP net.IP, ifMAC net.HardwareAddr, ifOFPort uint32) (flows []binding.Flow) {
+	ndSpoofGuardTable := c.pipeline[spoofGuardTable]
+	for _, lladdr := range []net.HardwareAddr{ifMAC, unspecifiedMAC} {
+		solicitationFlow := ndSpoofGuardTable.BuildFlow().MatchProtocol(binding.ProtocolICMPv6).Priority(priorityHigh).
+			MatchInPort(ifOFPort).
+			MatchSrcMAC(ifMAC).
+			MatchICMPv6Type(icmp6TypeNeighborSolicitation).
+			MatchNDSll(lladdr).
+			Action().Resubmit(emptyPlaceholderStr, arpResponderTable).
+			Cookie(c.cookieAllocator.Request(cookie.Pod).Raw()).
+			Done()
+		advertisementFlow := ndSpoofGuardTable.BuildFlow().MatchProtocol(binding.ProtocolICMPv6).Priority(priorityHigh).
+			MatchInPort(ifOFPort).
+			MatchSrcMAC(ifMAC).
+			MatchICMPv6Type(icmp6TypeNeighborAdvertisement).
+			MatchNDTarget(ifIP).
+			MatchNDTll(lladdr).
+			Action().Resubmit(emptyPlaceholderStr, arpResponderTable).
+			Cookie(c.cookieAllocator.Request(cookie.Pod).Raw()).
+			Done()
+		flows = append(flows, solicitationFlow, advertisementFlow)
+	}
+	return flows
+}
+
+// ndDropFlows generates the flows to drop the Neighbor Solicitations and Advertisements which are not allowed by the
+// ND spoof guard flows.
+func (c *client) ndDropFlows() (flows []binding.Flow) {
+	for _, icmp6Type := range []uint8{icmp6TypeNeighborSolicitation, icmp6TypeNeighborAdvertisement} {
+		flows = append(flows, c.pipeline[spoofGuardTable].BuildFlow().MatchProtocol(binding.ProtocolICMPv6).Priority(priorityNDDrop).
+			MatchICMPv6Type(icmp6Type).
+			Action().Drop().
+			Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
+			Done())
+	}
+	return flows
+}
+
+// gatewayIPSpoofGuardFlows generates the flows to skip spoof guard checking for traffic sent from gateway interface.
+func (c *client) gatewayIPSpoofGuardFlows(gatewayOFPort uint32) (flows []binding.Flow) {
 	ipPipeline := c.pipeline
 	ipSpoofGuardTable := ipPipeline[spoofGuardTable]
-	return ipSpoofGuardTable.BuildFlow().Priority(priorityNormal).
-		MatchProtocol(binding.ProtocolIP).
-		MatchInPort(gatewayOFPort).
-		Action().Resubmit(emptyPlaceholderStr, ipSpoofGuardTable.GetNext()).
-		Done()
+	for _, ipProtocol := range c.ipProtocols(binding.ProtocolIP) {
+		flows = append(flows, ipSpoofGuardTable.BuildFlow().Priority(priorityNormal).
+			MatchProtocol(ipProtocol).
+			MatchInPort(gatewayOFPort).
+			Action().Resubmit(emptyPlaceholderStr, ipSpoofGuardTable.GetNext()).
+			Cookie(c.cookieAllocator.Request(cookie.Gateway).Raw()).
+			Done())
+	}
+	return flows
 }
 
 // serviceCIDRDNATFlow generates flows to match dst IP in service CIDR and output to host gateway interface directly.
 func (c *client) serviceCIDRDNATFlow(serviceCIDR *net.IPNet, gatewayOFPort uint32) binding.Flow {
-	return c.pipeline[dnatTable].BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityNormal).
+	return c.pipeline[serviceLBTable].BuildFlow().MatchProtocol(protocolOf(binding.ProtocolIP, serviceCIDR.IP)).Priority(priorityNormal).
 		MatchDstIPNet(*serviceCIDR).
 		Action().Output(int(gatewayOFPort)).
+		Cookie(c.cookieAllocator.Request(cookie.Service).Raw()).
+		Done()
+}
+
+// serviceCIDRLBFlow generates the flow to load balance the new connections whose dst IP is in the Service CIDR: the
+// Endpoint is looked up in the sessionAffinityTable first, then selected by the serviceLBTable if not found. The
+// connections are not committed to ct before they are DNATed by the endpointDNATTable.
+func (c *client) serviceCIDRLBFlow(serviceCIDR *net.IPNet) binding.Flow {
+	return c.pipeline[conntrackStateTable].BuildFlow().MatchProtocol(protocolOf(binding.ProtocolIP, serviceCIDR.IP)).Priority(priorityHigh).
+		MatchCTState("+new+trk").
+		MatchDstIPNet(*serviceCIDR).
+		Action().Resubmit(emptyPlaceholderStr, sessionAffinityTable).
+		Action().Resubmit(emptyPlaceholderStr, serviceLBTable).
+		Cookie(c.cookieAllocator.Request(cookie.Service).Raw()).
+		Done()
+}
+
+// nodePortLBFlow generates the flow to load balance the new connections which the host sent to the gateway after
+// DNATing them to the NodePort virtual IP, like the connections to the Service CIDR.
+func (c *client) nodePortLBFlow(virtualIP net.IP) binding.Flow {
+	return c.pipeline[conntrackStateTable].BuildFlow().MatchProtocol(protocolOf(binding.ProtocolIP, virtualIP)).Priority(priorityHigh).
+		MatchRegRange(int(marksReg), markTrafficFromGateway, binding.Range{0, 15}).
+		MatchCTState("+new+trk").
+		MatchDstIP(virtualIP).
+		Action().Resubmit(emptyPlaceholderStr, sessionAffinityTable).
+		Action().Resubmit(emptyPlaceholderStr, serviceLBTable).
+		Cookie(c.cookieAllocator.Request(cookie.Service).Raw()).
+		Done()
+}
+
+// serviceLBDefaultFlows generates the flows which move the connections whose Endpoint has been selected from the
+// serviceLBTable to the endpointDNATTable, and back to the serviceLBTable if the selected Endpoint doesn't exist
+// anymore, e.g. because the session affinity flow of a removed Endpoint hasn't expired yet.
+func (c *client) serviceLBDefaultFlows() []binding.Flow {
+	selectedFlow := c.pipeline[serviceLBTable].BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityHigh).
+		MatchRegRange(int(endpointPortReg), serviceEPSelected, serviceEPStateRange).
+		Action().Resubmit(emptyPlaceholderStr, endpointDNATTable).
+		Cookie(c.cookieAllocator.Request(cookie.Service).Raw()).
+		Done()
+	unknownEndpointFlow := c.pipeline[endpointDNATTable].BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityLow).
+		MatchRegRange(int(endpointPortReg), serviceEPSelected, serviceEPStateRange).
+		Action().LoadRegRange(int(endpointPortReg), serviceEPToSelect, serviceEPStateRange).
+		Action().Resubmit(emptyPlaceholderStr, serviceLBTable).
+		Cookie(c.cookieAllocator.Request(cookie.Service).Raw()).
+		Done()
+	return []binding.Flow{selectedFlow, unknownEndpointFlow}
+}
+
+// matchServicePort adds the matches of the protocol, the dst IP and the dst port of the Service port to the flow.
+func matchServicePort(fb binding.FlowBuilder, svcPort *types.ServicePort) binding.FlowBuilder {
+	switch svcPort.Protocol {
+	case corev1.ProtocolUDP:
+		return fb.MatchProtocol(protocolOf(binding.ProtocolUDP, svcPort.IP)).MatchDstIP(svcPort.IP).MatchUDPDstPort(svcPort.Port)
+	case corev1.ProtocolSCTP:
+		return fb.MatchProtocol(protocolOf(binding.ProtocolSCTP, svcPort.IP)).MatchDstIP(svcPort.IP).MatchSCTPDstPort(svcPort.Port)
+	default:
+		return fb.MatchProtocol(protocolOf(binding.ProtocolTCP, svcPort.IP)).MatchDstIP(svcPort.IP).MatchTCPDstPort(svcPort.Port)
+	}
+}
+
+// learnServicePort makes the learned flow match the protocol, the src IP, the dst IP and the dst port of the
+// packet, i.e. the client of the Service port.
+func learnServicePort(la binding.LearnAction, protocol corev1.Protocol) binding.LearnAction {
+	switch protocol {
+	case corev1.ProtocolUDP:
+		la = la.MatchProtocol(binding.ProtocolUDP).MatchLearnedSrcIP().MatchLearnedDstIP().MatchLearnedDstPort(binding.ProtocolUDP)
+	case corev1.ProtocolSCTP:
+		la = la.MatchProtocol(binding.ProtocolSCTP).MatchLearnedSrcIP().MatchLearnedDstIP().MatchLearnedDstPort(binding.ProtocolSCTP)
+	default:
+		la = la.MatchProtocol(binding.ProtocolTCP).MatchLearnedSrcIP().MatchLearnedDstIP().MatchLearnedDstPort(binding.ProtocolTCP)
+	}
+	return la
+}
+
+// serviceLBFlow generates the flow to select an Endpoint of the Service port with its group.
+func (c *client) serviceLBFlow(groupID binding.GroupIDType, svcPort *types.ServicePort) binding.Flow {
+	fb := c.pipeline[serviceLBTable].BuildFlow().Priority(priorityNormal)
+	return matchServicePort(fb, svcPort).
+		Action().Group(groupID).
+		Cookie(c.cookieAllocator.Request(cookie.Service).Raw()).
 		Done()
 }
 
+// serviceLearnFlow generates the flow to learn the Endpoint selected by the group of a Service port with session
+// affinity: the learned flow loads the same Endpoint for the next connections of the client, until it expires
+// affinityTimeout seconds after it was learned.
+func (c *client) serviceLearnFlow(svcPort *types.ServicePort, affinityTimeout uint16) binding.Flow {
+	fb := c.pipeline[serviceLBTable].BuildFlow().Priority(priorityHigh)
+	cookieID := c.cookieAllocator.Request(cookie.Service).Raw()
+	la := matchServicePort(fb, svcPort).
+		MatchRegRange(int(endpointPortReg), serviceEPToLearn, serviceEPStateRange).
+		Action().Learn(sessionAffinityTable, priorityNormal, 0, affinityTimeout, cookieID)
+	return learnServicePort(la, svcPort.Protocol).
+		LoadLearnedRegRange(int(endpointIPReg), endpointIPRegRange).
+		LoadLearnedRegRange(int(endpointPortReg), endpointPortRegRange).
+		LoadRegRange(int(endpointPortReg), serviceEPSelected, serviceEPStateRange).
+		Done().
+		Action().LoadRegRange(int(endpointPortReg), serviceEPSelected, serviceEPStateRange).
+		Action().Resubmit(emptyPlaceholderStr, endpointDNATTable).
+		Cookie(cookieID).
+		Done()
+}
+
+// endpointDNATFlow generates the flow to DNAT the connections for which the Endpoint has been selected, and to
+// commit them to ct.
+func (c *client) endpointDNATFlow(svcPort *types.ServicePort, endpoint *types.Endpoint) binding.Flow {
+	fb := c.pipeline[endpointDNATTable].BuildFlow().Priority(priorityNormal)
+	return matchServicePort(fb, svcPort).
+		MatchRegRange(int(endpointIPReg), ipToUint32(endpoint.IP), endpointIPRegRange).
+		MatchRegRange(int(endpointPortReg), uint32(endpoint.Port), endpointPortRegRange).
+		Action().CT(true, c.pipeline[endpointDNATTable].GetNext(), ctZone).
+		DNAT(&binding.IPRange{StartIP: endpoint.IP}, &binding.PortRange{StartPort: endpoint.Port}).
+		CTDone().
+		Cookie(c.cookieAllocator.Request(cookie.Service).Raw()).
+		Done()
+}
+
+// serviceGroup generates the select group of a Service port, whose buckets load the Endpoints to the registers with
+// the same weight. Without session affinity the connection is then DNATed by the endpointDNATTable, otherwise the
+// Endpoint is learned first by the serviceLBTable.
+func (c *client) serviceGroup(groupID binding.GroupIDType, endpoints []*types.Endpoint, withSessionAffinity bool) binding.Group {
+	group := c.bridge.CreateGroup(groupID, binding.GroupTypeSelect)
+	for _, endpoint := range endpoints {
+		bucket := group.Bucket().Weight(100).
+			LoadRegRange(int(endpointIPReg), ipToUint32(endpoint.IP), endpointIPRegRange).
+			LoadRegRange(int(endpointPortReg), uint32(endpoint.Port), endpointPortRegRange)
+		if withSessionAffinity {
+			bucket = bucket.LoadRegRange(int(endpointPortReg), serviceEPToLearn, serviceEPStateRange).
+				Resubmit(emptyPlaceholderStr, serviceLBTable)
+		} else {
+			bucket = bucket.Resubmit(emptyPlaceholderStr, endpointDNATTable)
+		}
+		group = bucket.Done()
+	}
+	return group
+}
+
+// ipToUint32 converts an IPv4 address to the value loaded to, or matched in, a 32-bit register.
+func ipToUint32(ip net.IP) uint32 {
+	ipv4 := ip.To4()
+	if ipv4 == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(ipv4)
+}
+
 // arpNormalFlow generates the flow to response arp in normal way if no flow in arpResponderTable is matched.
 func (c *client) arpNormalFlow() binding.Flow {
 	return c.pipeline[arpResponderTable].BuildFlow().
 		MatchProtocol(binding.ProtocolARP).Priority(priorityLow).
-		Action().Normal().Done()
+		Action().Normal().
+		Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
+		Done()
 }
 
-// conjunctionActionFlow generates the flow to resubmit to a specific table if policyRuleConjunction ID is matched. Priority of
-// conjunctionActionFlow is priorityLow.
-func (c *client) conjunctionActionFlow(conjunctionID uint32, tableID binding.TableIDType, nextTable binding.TableIDType) binding.Flow {
-	return c.pipeline[tableID].BuildFlow().
-		MatchProtocol(binding.ProtocolIP).Priority(priorityLow).
-		MatchConjID(conjunctionID).
-		Action().Resubmit(emptyPlaceholderStr, nextTable).Done()
+// ndNormalFlow generates the flow to forward the Neighbor Discovery messages in normal way. With IPv6, there is no
+// responder flow in the arpResponderTable: the peer gateways are resolved with permanent neighbor entries instead.
+func (c *client) ndNormalFlow() binding.Flow {
+	return c.pipeline[arpResponderTable].BuildFlow().
+		MatchProtocol(binding.ProtocolICMPv6).Priority(priorityLow).
+		Action().Normal().
+		Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
+		Done()
+}
+
+// conjunctionActionFlows generates the flows to resubmit to a specific table if policyRuleConjunction ID is matched,
+// one per IP family. Priority of conjunctionActionFlows is priorityLow.
+func (c *client) conjunctionActionFlows(conjunctionID uint32, tableID binding.TableIDType, nextTable binding.TableIDType) (flows []binding.Flow) {
+	for _, ipProtocol := range c.ipProtocols(binding.ProtocolIP) {
+		flows = append(flows, c.pipeline[tableID].BuildFlow().
+			MatchProtocol(ipProtocol).Priority(priorityLow).
+			MatchConjID(conjunctionID).
+			Action().Resubmit(emptyPlaceholderStr, nextTable).
+			Cookie(c.cookieAllocator.Request(cookie.Policy).Raw()).
+			Done())
+	}
+	return flows
 }
 
 func (c *client) Disconnect() error {
@@ -390,40 +763,59 @@ func (c *client) establishedConnectionFlows() (flows []binding.Flow) {
 	// matching the NetworkPolicy rules. Packets in the established connections need not to be checked with the
 	// egressRuleTable or the egressDropTable.
 	egressDropTable := c.pipeline[egressDefaultTable]
-	egressEstFlow := c.pipeline[egressRuleTable].BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityHigh).
-		MatchCTState("-new+est").
-		Action().Resubmit(emptyPlaceholderStr, egressDropTable.GetNext()).Done()
 	// ingressDropTable checks the destination address of packets, and drops packets sent to the AppliedToGroup but not
 	// matching the NetworkPolicy rules. Packets in the established connections need not to be checked with the
 	// ingressRuleTable or ingressDropTable.
 	ingressDropTable := c.pipeline[ingressDefaultTable]
-	ingressEstFlow := c.pipeline[ingressRuleTable].BuildFlow().MatchProtocol(binding.ProtocolIP).Priority(priorityHigh).
-		MatchCTState("-new+est").
-		Action().Resubmit(emptyPlaceholderStr, ingressDropTable.GetNext()).Done()
-	return []binding.Flow{egressEstFlow, ingressEstFlow}
+	for _, ipProtocol := range c.ipProtocols(binding.ProtocolIP) {
+		egressEstFlow := c.pipeline[egressRuleTable].BuildFlow().MatchProtocol(ipProtocol).Priority(priorityHigh).
+			MatchCTState("-new+est").
+			Action().Resubmit(emptyPlaceholderStr, egressDropTable.GetNext()).
+			Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
+			Done()
+		ingressEstFlow := c.pipeline[ingressRuleTable].BuildFlow().MatchProtocol(ipProtocol).Priority(priorityHigh).
+			MatchCTState("-new+est").
+			Action().Resubmit(emptyPlaceholderStr, ingressDropTable.GetNext()).
+			Cookie(c.cookieAllocator.Request(cookie.Default).Raw()).
+			Done()
+		flows = append(flows, egressEstFlow, ingressEstFlow)
+	}
+	return flows
 }
 
 func (c *client) addFlowMatch(fb binding.FlowBuilder, matchType int, matchValue interface{}) binding.FlowBuilder {
 	switch matchType {
 	case MatchDstIP:
-		fb = fb.MatchProtocol(binding.ProtocolIP).MatchDstIP(matchValue.(net.IP))
+		ip := matchValue.(net.IP)
+		fb = fb.MatchProtocol(protocolOf(binding.ProtocolIP, ip)).MatchDstIP(ip)
 	case MatchDstIPNet:
-		fb = fb.MatchProtocol(binding.ProtocolIP).MatchDstIPNet(matchValue.(net.IPNet))
+		ipNet := matchValue.(net.IPNet)
+		fb = fb.MatchProtocol(protocolOf(binding.ProtocolIP, ipNet.IP)).MatchDstIPNet(ipNet)
 	case MatchSrcIP:
-		fb = fb.MatchProtocol(binding.ProtocolIP).MatchSrcIP(matchValue.(net.IP))
+		ip := matchValue.(net.IP)
+		fb = fb.MatchProtocol(protocolOf(binding.ProtocolIP, ip)).MatchSrcIP(ip)
 	case MatchSrcIPNet:
-		fb = fb.MatchProtocol(binding.ProtocolIP).MatchSrcIPNet(matchValue.(net.IPNet))
+		ipNet := matchValue.(net.IPNet)
+		fb = fb.MatchProtocol(protocolOf(binding.ProtocolIP, ipNet.IP)).MatchSrcIPNet(ipNet)
+	// Only IPv4 and IPv6 packets reach the NetworkPolicy tables, the ofport matches don't need to match the protocol
+	// and are shared by both IP families.
 	case MatchDstOFPort:
 		// ofport number in NXM_NX_REG1 is used in ingress rule to match packets sent to local Pod.
-		fb = fb.MatchProtocol(binding.ProtocolIP).MatchRegRange(int(portCacheReg), uint32(matchValue.(int32)), ofPortRegRange)
+		fb = fb.MatchRegRange(int(portCacheReg), uint32(matchValue.(int32)), ofPortRegRange)
 	case MatchSrcOFPort:
-		fb = fb.MatchProtocol(binding.ProtocolIP).MatchInPort(uint32(matchValue.(int32)))
+		fb = fb.MatchInPort(uint32(matchValue.(int32)))
 	case MatchTCPDstPort:
 		fb = fb.MatchProtocol(binding.ProtocolTCP).MatchTCPDstPort(matchValue.(uint16))
+	case MatchTCPv6DstPort:
+		fb = fb.MatchProtocol(binding.ProtocolTCPv6).MatchTCPDstPort(matchValue.(uint16))
 	case MatchUDPDstPort:
 		fb = fb.MatchProtocol(binding.ProtocolUDP).MatchUDPDstPort(matchValue.(uint16))
+	case MatchUDPv6DstPort:
+		fb = fb.MatchProtocol(binding.ProtocolUDPv6).MatchUDPDstPort(matchValue.(uint16))
 	case MatchSCTPDstPort:
 		fb = fb.MatchProtocol(binding.ProtocolSCTP).MatchSCTPDstPort(matchValue.(uint16))
+	case MatchSCTPv6DstPort:
+		fb = fb.MatchProtocol(binding.ProtocolSCTPv6).MatchSCTPDstPort(matchValue.(uint16))
 	}
 	return fb
 }
@@ -432,7 +824,9 @@ func (c *client) addFlowMatch(fb binding.FlowBuilder, matchType int, matchValue
 func (c *client) conjunctionExceptionFlow(conjunctionID uint32, tableID binding.TableIDType, nextTable binding.TableIDType, matchKey int, matchValue interface{}) binding.Flow {
 	fb := c.pipeline[tableID].BuildFlow().Priority(priorityNormal).MatchConjID(conjunctionID)
 	return c.addFlowMatch(fb, matchKey, matchValue).
-		Action().Resubmit(emptyPlaceholderStr, nextTable).Done()
+		Action().Resubmit(emptyPlaceholderStr, nextTable).
+		Cookie(c.cookieAllocator.Request(cookie.Policy).Raw()).
+		Done()
 }
 
 // conjunctiveMatchFlow generates the flow to set conjunctive actions if the match condition is matched.
@@ -442,27 +836,37 @@ func (c *client) conjunctiveMatchFlow(tableID binding.TableIDType, matchKey int,
 	for _, act := range actions {
 		fb.Action().Conjunction(act.conjID, act.clauseID, act.nClause)
 	}
-	return fb.Done()
+	return fb.Cookie(c.cookieAllocator.Request(cookie.Policy).Raw()).Done()
 }
 
 // defaultDropFlow generates the flow to drop packets if the match condition is matched.
 func (c *client) defaultDropFlow(tableID binding.TableIDType, matchKey int, matchValue interface{}) binding.Flow {
 	fb := c.pipeline[tableID].BuildFlow().Priority(priorityNormal)
 	return c.addFlowMatch(fb, matchKey, matchValue).
-		Action().Drop().Done()
+		Action().Drop().
+		Cookie(c.cookieAllocator.Request(cookie.Policy).Raw()).
+		Done()
 }
 
-// NewClient is the constructor of the Client interface.
-func NewClient(bridgeName string) Client {
-	bridge := binding.NewBridge(bridgeName)
+// NewClient is the constructor of the Client interface. ofBackend selects how flows are programmed on the bridge,
+// binding.BackendOFCtl uses ovs-ofctl commands, any other value uses the native OpenFlow connection. If enableProxy
+// is true, the traffic of the Services is load balanced by the pipeline, otherwise it is sent to the gateway for
+// kube-proxy.
+func NewClient(bridgeName string, ofBackend string, enableProxy bool) Client {
+	var bridge binding.Bridge
+	if ofBackend == binding.BackendOFCtl {
+		bridge = binding.NewBridge(bridgeName)
+	} else {
+		bridge = binding.NewOFBridge(bridgeName)
+	}
 	c := &client{
 		bridge: bridge,
 		pipeline: map[binding.TableIDType]binding.Table{
 			classifierTable:       bridge.CreateTable(classifierTable, spoofGuardTable, binding.TableMissActionNext),
 			spoofGuardTable:       bridge.CreateTable(spoofGuardTable, conntrackTable, binding.TableMissActionDrop),
 			conntrackTable:        bridge.CreateTable(conntrackTable, conntrackStateTable, binding.TableMissActionNext),
-			conntrackStateTable:   bridge.CreateTable(conntrackStateTable, dnatTable, binding.TableMissActionNext),
-			dnatTable:             bridge.CreateTable(dnatTable, egressRuleTable, binding.TableMissActionNext),
+			conntrackStateTable:   bridge.CreateTable(conntrackStateTable, serviceLBTable, binding.TableMissActionNext),
+			serviceLBTable:        bridge.CreateTable(serviceLBTable, egressRuleTable, binding.TableMissActionNext),
 			l3ForwardingTable:     bridge.CreateTable(l3ForwardingTable, l2ForwardingCalcTable, binding.TableMissActionNext),
 			l2ForwardingCalcTable: bridge.CreateTable(l2ForwardingCalcTable, ingressRuleTable, binding.TableMissActionNext),
 			l2ForwardingOutTable:  bridge.CreateTable(l2ForwardingOutTable, binding.LastTableID, binding.TableMissActionDrop),
@@ -475,8 +879,19 @@ func NewClient(bridgeName string) Client {
 		nodeFlowCache:            newFlowCategoryCache(),
 		podFlowCache:             newFlowCategoryCache(),
 		serviceCache:             newFlowCategoryCache(),
+		defaultFlowCache:         newFlowCategoryCache(),
 		policyCache:              sync.Map{},
 		globalConjMatchFlowCache: map[string]*conjMatchFlowContext{},
+		// The round number is set by Initialize.
+		cookieAllocator: cookie.NewAllocator(0),
+		enableProxy:     enableProxy,
+		// The IP families are set by Initialize, IPv4 is assumed until then.
+		ipv4: true,
+	}
+	if enableProxy {
+		// The sessionAffinityTable and the endpointDNATTable are only reached by resubmit from the other tables.
+		c.pipeline[sessionAffinityTable] = bridge.CreateTable(sessionAffinityTable, binding.LastTableID, binding.TableMissActionDrop)
+		c.pipeline[endpointDNATTable] = bridge.CreateTable(endpointDNATTable, egressRuleTable, binding.TableMissActionNext)
 	}
 	c.flowOperations = c
 	return c
@@ -0,0 +1,520 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	"github.com/vmware-tanzu/antrea/pkg/agent/openflow"
+	"github.com/vmware-tanzu/antrea/pkg/agent/types"
+	binding "github.com/vmware-tanzu/antrea/pkg/ovs/openflow"
+)
+
+const (
+	// Interval of synchronizing Services and Endpoints from apiserver
+	resyncPeriod = 60 * time.Second
+	// How long to wait before retrying the processing of a Service change
+	minRetryDelay = 5 * time.Second
+	maxRetryDelay = 300 * time.Second
+	// Default number of workers processing a Service change
+	defaultWorkers = 4
+	// maxAffinityTimeout is the longest session affinity timeout supported by the flows, in seconds. The timeouts of
+	// the Services are capped to it.
+	maxAffinityTimeout = 0xffff
+)
+
+// installedServicePort records the group and the endpoints installed for a Service port.
+type installedServicePort struct {
+	svcPort *types.ServicePort
+	groupID binding.GroupIDType
+	// endpoints is the sorted list of the installed endpoints, and affinityTimeout the installed session affinity
+	// timeout. endpoints is nil until the Service port has been installed successfully.
+	endpoints       []string
+	affinityTimeout uint16
+}
+
+// ExternalPortSyncer steers the traffic of the ExternalPorts of the Services to OVS.
+type ExternalPortSyncer interface {
+	// SyncExternalPorts replaces the ExternalPorts whose traffic is steered to OVS.
+	SyncExternalPorts(ports []*types.ExternalPort) error
+}
+
+// Proxier load balances the connections to the ClusterIPs of the Services across their Endpoints with OVS flows, so
+// that kube-proxy is not needed: the connections are DNATed to the selected Endpoint before the egress NetworkPolicy
+// rules are enforced. If it is created with an ExternalPortSyncer, the connections to the NodePorts and to the
+// LoadBalancer ingress IPs are load balanced too, on the NodePort virtual IP to which the host DNATs them.
+type Proxier struct {
+	serviceLister         corelisters.ServiceLister
+	serviceListerSynced   cache.InformerSynced
+	endpointsLister       corelisters.EndpointsLister
+	endpointsListerSynced cache.InformerSynced
+	// queue contains the namespaced names of the Services, which are enqueued when the Service or its Endpoints
+	// change.
+	queue          workqueue.RateLimitingInterface
+	ofClient       openflow.Client
+	groupAllocator *groupAllocator
+	// installedServices is a map from the namespaced name of a Service to its installed ports, indexed by
+	// ServicePort.String(). The work queue guarantees that a Service is only synced by one worker at a time.
+	installedServices     map[string]map[string]*installedServicePort
+	installedServicesLock sync.Mutex
+	// nodeName is the name of the Node, whose Endpoints are the only ones selected for the NodePorts of the Services
+	// with the Local externalTrafficPolicy.
+	nodeName           string
+	externalPortSyncer ExternalPortSyncer
+	// externalPorts is a map from the namespaced name of a Service to its ExternalPorts which have been synced. The
+	// lock is held while the ExternalPorts are synced, so that the syncs of different Services are serialized.
+	externalPorts     map[string][]*types.ExternalPort
+	externalPortsLock sync.Mutex
+	// initialSynced is closed once the flows of the Services which existed when the Proxier started have been
+	// installed.
+	initialSynced chan struct{}
+}
+
+// NewProxier creates a Proxier. If externalPortSyncer is nil, only the connections to the ClusterIPs are load
+// balanced.
+func NewProxier(informerFactory informers.SharedInformerFactory, client openflow.Client, nodeName string, externalPortSyncer ExternalPortSyncer) *Proxier {
+	serviceInformer := informerFactory.Core().V1().Services()
+	endpointsInformer := informerFactory.Core().V1().Endpoints()
+	p := &Proxier{
+		serviceLister:         serviceInformer.Lister(),
+		serviceListerSynced:   serviceInformer.Informer().HasSynced,
+		endpointsLister:       endpointsInformer.Lister(),
+		endpointsListerSynced: endpointsInformer.Informer().HasSynced,
+		queue:                 workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "proxy"),
+		ofClient:              client,
+		groupAllocator:        newGroupAllocator(),
+		installedServices:     map[string]map[string]*installedServicePort{},
+		nodeName:              nodeName,
+		externalPortSyncer:    externalPortSyncer,
+		externalPorts:         map[string][]*types.ExternalPort{},
+		initialSynced:         make(chan struct{}),
+	}
+	// The Endpoints of a Service have the same namespaced name as the Service.
+	eventHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(cur interface{}) {
+			p.enqueueService(cur)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			p.enqueueService(cur)
+		},
+		DeleteFunc: func(old interface{}) {
+			p.enqueueService(old)
+		},
+	}
+	serviceInformer.Informer().AddEventHandlerWithResyncPeriod(eventHandler, resyncPeriod)
+	endpointsInformer.Informer().AddEventHandlerWithResyncPeriod(eventHandler, resyncPeriod)
+	return p
+}
+
+// enqueueService adds the namespaced name of a Service or Endpoints to the work queue. obj could be a *v1.Service, a
+// *v1.Endpoints, or a DeletedFinalStateUnknown item.
+func (p *Proxier) enqueueService(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.Errorf("Received unexpected object %v: %v", obj, err)
+		return
+	}
+	p.queue.Add(key)
+}
+
+func (p *Proxier) Run(stopCh <-chan struct{}) {
+	defer p.queue.ShutDown()
+
+	klog.Info("Starting Proxier")
+	defer klog.Info("Shutting down Proxier")
+
+	klog.Info("Waiting for caches to sync for Proxier")
+	if !cache.WaitForCacheSync(stopCh, p.serviceListerSynced, p.endpointsListerSynced) {
+		klog.Error("Unable to sync caches for Proxier")
+		return
+	}
+	klog.Info("Caches are synced for Proxier")
+
+	p.initialSync()
+
+	for i := 0; i < defaultWorkers; i++ {
+		go wait.Until(p.worker, time.Second, stopCh)
+	}
+	<-stopCh
+}
+
+// initialSync installs the flows of all the Services in the lister cache, and closes initialSynced when it is done.
+// It runs before the workers are started, so that syncService is never called concurrently for the same Service.
+// The Services which fail to be synced are still processed by the workers, which requeue them on error.
+func (p *Proxier) initialSync() {
+	defer close(p.initialSynced)
+	services, err := p.serviceLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list Services: %v", err)
+		return
+	}
+	for _, svc := range services {
+		key, _ := cache.MetaNamespaceKeyFunc(svc)
+		if err := p.syncService(key); err != nil {
+			klog.Errorf("Error syncing Service %s: %v", key, err)
+		}
+	}
+	// The ExternalPorts are only synced when the ones of a Service change, which never happens if no Service has
+	// ExternalPorts: they are synced once, so that the rules of a previous Agent are flushed in any case.
+	if err := p.syncAllExternalPorts(); err != nil {
+		klog.Errorf("Error syncing the external ports of all the Services: %v", err)
+	}
+	klog.Infof("Initial sync of %d Services completed", len(services))
+}
+
+// InitialSynced returns a channel which is closed once the flows of the Services which existed when the Proxier
+// started have been installed.
+func (p *Proxier) InitialSynced() <-chan struct{} {
+	return p.initialSynced
+}
+
+func (p *Proxier) worker() {
+	for p.processNextWorkItem() {
+	}
+}
+
+func (p *Proxier) processNextWorkItem() bool {
+	obj, quit := p.queue.Get()
+	if quit {
+		return false
+	}
+	defer p.queue.Done(obj)
+
+	if key, ok := obj.(string); !ok {
+		p.queue.Forget(obj)
+		klog.Errorf("Expected string in work queue but got %#v", obj)
+		return true
+	} else if err := p.syncService(key); err == nil {
+		p.queue.Forget(key)
+	} else {
+		p.queue.AddRateLimited(key)
+		klog.Errorf("Error syncing Service %s, requeuing. Error: %v", key, err)
+	}
+	return true
+}
+
+// syncService installs the flows of the ports of the Service with the ready addresses of its Endpoints, and
+// uninstalls the flows of the ports which don't exist anymore, e.g. because the Service was deleted. The traffic of
+// the ExternalPorts of the Service is steered to OVS after the flows are installed, and before they are uninstalled.
+func (p *Proxier) syncService(key string) error {
+	startTime := time.Now()
+	defer func() {
+		klog.V(4).Infof("Finished syncing Service %s. (%v)", key, time.Since(startTime))
+	}()
+
+	desiredPorts, externalPorts, err := p.getDesiredServicePorts(key)
+	if err != nil {
+		return err
+	}
+
+	p.installedServicesLock.Lock()
+	installedPorts, ok := p.installedServices[key]
+	if !ok {
+		installedPorts = map[string]*installedServicePort{}
+		p.installedServices[key] = installedPorts
+	}
+	p.installedServicesLock.Unlock()
+
+	for portKey, desired := range desiredPorts {
+		installed, ok := installedPorts[portKey]
+		if !ok {
+			installed = &installedServicePort{svcPort: desired.svcPort, groupID: p.groupAllocator.allocate()}
+			installedPorts[portKey] = installed
+		} else if installed.endpoints != nil && installed.affinityTimeout == desired.affinityTimeout &&
+			reflect.DeepEqual(installed.endpoints, desired.endpointKeys()) {
+			continue
+		}
+		klog.Infof("Installing flows of Service %s port %s with %d endpoints", key, portKey, len(desired.endpoints))
+		if err := p.ofClient.InstallServiceFlows(installed.groupID, desired.svcPort, desired.endpoints, desired.affinityTimeout); err != nil {
+			return fmt.Errorf("failed to install flows of Service port %s: %v", portKey, err)
+		}
+		installed.endpoints = desired.endpointKeys()
+		installed.affinityTimeout = desired.affinityTimeout
+	}
+	if err := p.syncExternalPorts(key, externalPorts); err != nil {
+		return err
+	}
+	for portKey, installed := range installedPorts {
+		if _, ok := desiredPorts[portKey]; ok {
+			continue
+		}
+		klog.Infof("Uninstalling flows of Service %s port %s", key, portKey)
+		if err := p.ofClient.UninstallServiceFlows(installed.groupID, installed.svcPort); err != nil {
+			return fmt.Errorf("failed to uninstall flows of Service port %s: %v", portKey, err)
+		}
+		p.groupAllocator.release(installed.groupID)
+		delete(installedPorts, portKey)
+	}
+
+	if len(installedPorts) == 0 {
+		p.installedServicesLock.Lock()
+		delete(p.installedServices, key)
+		p.installedServicesLock.Unlock()
+	}
+	return nil
+}
+
+// syncExternalPorts syncs the ExternalPorts of all the Services if the ones of the Service have changed.
+func (p *Proxier) syncExternalPorts(key string, ports []*types.ExternalPort) error {
+	if p.externalPortSyncer == nil {
+		return nil
+	}
+	p.externalPortsLock.Lock()
+	defer p.externalPortsLock.Unlock()
+
+	oldPorts := p.externalPorts[key]
+	if reflect.DeepEqual(oldPorts, ports) {
+		return nil
+	}
+	klog.Infof("Syncing %d external ports of Service %s", len(ports), key)
+	p.setExternalPorts(key, ports)
+	if err := p.syncAllExternalPortsLocked(); err != nil {
+		p.setExternalPorts(key, oldPorts)
+		return err
+	}
+	return nil
+}
+
+// syncAllExternalPorts syncs the ExternalPorts of all the Services, even if none of them has changed, so that the
+// rules left by a previous Agent for the Services which don't exist anymore are removed.
+func (p *Proxier) syncAllExternalPorts() error {
+	if p.externalPortSyncer == nil {
+		return nil
+	}
+	p.externalPortsLock.Lock()
+	defer p.externalPortsLock.Unlock()
+	return p.syncAllExternalPortsLocked()
+}
+
+// syncAllExternalPortsLocked syncs the ExternalPorts of all the Services in externalPorts. externalPortsLock must be
+// held.
+func (p *Proxier) syncAllExternalPortsLocked() error {
+	var allPorts []*types.ExternalPort
+	for _, svcPorts := range p.externalPorts {
+		allPorts = append(allPorts, svcPorts...)
+	}
+	// The ports are sorted so that the rules are always synced in the same order.
+	sort.Slice(allPorts, func(i, j int) bool {
+		return allPorts[i].String() < allPorts[j].String()
+	})
+	if err := p.externalPortSyncer.SyncExternalPorts(allPorts); err != nil {
+		return fmt.Errorf("failed to sync external ports: %v", err)
+	}
+	return nil
+}
+
+// setExternalPorts sets the ExternalPorts of the Service in externalPorts. externalPortsLock must be held.
+func (p *Proxier) setExternalPorts(key string, ports []*types.ExternalPort) {
+	if len(ports) == 0 {
+		delete(p.externalPorts, key)
+	} else {
+		p.externalPorts[key] = ports
+	}
+}
+
+// desiredServicePort is a Service port with the endpoints to which its connections must be load balanced.
+type desiredServicePort struct {
+	svcPort         *types.ServicePort
+	endpoints       []*types.Endpoint
+	affinityTimeout uint16
+}
+
+// endpointKeys returns the sorted addresses of the endpoints.
+func (d *desiredServicePort) endpointKeys() []string {
+	keys := make([]string, 0, len(d.endpoints))
+	for _, endpoint := range d.endpoints {
+		keys = append(keys, endpoint.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// getDesiredServicePorts returns the ports of the Service, indexed by ServicePort.String(), and its ExternalPorts if
+// the Proxier has an ExternalPortSyncer. The NodePorts of the Service are load balanced as ports of the NodePort
+// virtual IP. It returns no port if the Service doesn't exist or is not load balanced by the Proxier.
+func (p *Proxier) getDesiredServicePorts(key string) (map[string]*desiredServicePort, []*types.ExternalPort, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	desiredPorts := map[string]*desiredServicePort{}
+	svc, err := p.serviceLister.Services(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return desiredPorts, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	clusterIP := net.ParseIP(svc.Spec.ClusterIP)
+	if svc.Spec.Type == v1.ServiceTypeExternalName || clusterIP == nil {
+		// Headless Services have no ClusterIP, and ExternalName Services are resolved by DNS.
+		return desiredPorts, nil, nil
+	}
+	if clusterIP.To4() == nil {
+		klog.V(2).Infof("Skipping Service %s with non-IPv4 ClusterIP %s", key, svc.Spec.ClusterIP)
+		return desiredPorts, nil, nil
+	}
+	endpoints, err := p.endpointsLister.Endpoints(namespace).Get(name)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, nil, err
+	}
+	affinityTimeout := getAffinityTimeout(svc)
+	// With the Local externalTrafficPolicy, the traffic of the NodePorts is only load balanced to the local Endpoints,
+	// and its source address is preserved.
+	localOnly := svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal
+	var externalPorts []*types.ExternalPort
+	for i := range svc.Spec.Ports {
+		port := &svc.Spec.Ports[i]
+		svcPort := &types.ServicePort{IP: clusterIP, Port: uint16(port.Port), Protocol: port.Protocol}
+		desiredPorts[svcPort.String()] = &desiredServicePort{
+			svcPort:         svcPort,
+			endpoints:       getEndpointsOfPort(endpoints, port, ""),
+			affinityTimeout: affinityTimeout,
+		}
+		if p.externalPortSyncer == nil || port.NodePort == 0 {
+			continue
+		}
+		nodePort := &types.ServicePort{IP: types.NodePortVirtualIP, Port: uint16(port.NodePort), Protocol: port.Protocol}
+		nodePortEndpoints := desiredPorts[svcPort.String()].endpoints
+		if localOnly {
+			nodePortEndpoints = getEndpointsOfPort(endpoints, port, p.nodeName)
+		}
+		desiredPorts[nodePort.String()] = &desiredServicePort{
+			svcPort:         nodePort,
+			endpoints:       nodePortEndpoints,
+			affinityTimeout: affinityTimeout,
+		}
+		externalPorts = append(externalPorts, &types.ExternalPort{
+			Port:       uint16(port.NodePort),
+			Protocol:   port.Protocol,
+			NodePort:   uint16(port.NodePort),
+			Masquerade: !localOnly,
+		})
+		if svc.Spec.Type != v1.ServiceTypeLoadBalancer {
+			continue
+		}
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			ingressIP := net.ParseIP(ingress.IP)
+			if ingressIP == nil || ingressIP.To4() == nil {
+				continue
+			}
+			externalPorts = append(externalPorts, &types.ExternalPort{
+				IP:         ingressIP,
+				Port:       uint16(port.Port),
+				Protocol:   port.Protocol,
+				NodePort:   uint16(port.NodePort),
+				Masquerade: !localOnly,
+			})
+		}
+	}
+	return desiredPorts, externalPorts, nil
+}
+
+// getAffinityTimeout returns the session affinity timeout of the Service in seconds, or 0 if the Service has no
+// session affinity.
+func getAffinityTimeout(svc *v1.Service) uint16 {
+	if svc.Spec.SessionAffinity != v1.ServiceAffinityClientIP {
+		return 0
+	}
+	timeout := v1.DefaultClientIPServiceAffinitySeconds
+	if config := svc.Spec.SessionAffinityConfig; config != nil && config.ClientIP != nil && config.ClientIP.TimeoutSeconds != nil {
+		timeout = *config.ClientIP.TimeoutSeconds
+	}
+	if timeout > maxAffinityTimeout {
+		return maxAffinityTimeout
+	} else if timeout <= 0 {
+		return 0
+	}
+	return uint16(timeout)
+}
+
+// getEndpointsOfPort returns the ready IPv4 addresses of the Endpoints, with the port matching the Service port by
+// name and protocol. If nodeName is not empty, only the addresses on this Node are returned.
+func getEndpointsOfPort(endpoints *v1.Endpoints, port *v1.ServicePort, nodeName string) []*types.Endpoint {
+	if endpoints == nil {
+		return nil
+	}
+	var result []*types.Endpoint
+	seen := map[string]bool{}
+	for _, subset := range endpoints.Subsets {
+		for _, epPort := range subset.Ports {
+			if epPort.Name != port.Name || epPort.Protocol != port.Protocol {
+				continue
+			}
+			for _, addr := range subset.Addresses {
+				if nodeName != "" && (addr.NodeName == nil || *addr.NodeName != nodeName) {
+					continue
+				}
+				ip := net.ParseIP(addr.IP)
+				if ip == nil || ip.To4() == nil {
+					continue
+				}
+				endpoint := &types.Endpoint{IP: ip, Port: uint16(epPort.Port)}
+				if seen[endpoint.String()] {
+					continue
+				}
+				seen[endpoint.String()] = true
+				result = append(result, endpoint)
+			}
+		}
+	}
+	return result
+}
+
+// groupAllocator allocates the IDs of the groups of the Service ports. The released IDs are allocated again before
+// new ones.
+type groupAllocator struct {
+	sync.Mutex
+	lastID   binding.GroupIDType
+	released []binding.GroupIDType
+}
+
+func newGroupAllocator() *groupAllocator {
+	return &groupAllocator{}
+}
+
+func (a *groupAllocator) allocate() binding.GroupIDType {
+	a.Lock()
+	defer a.Unlock()
+
+	if n := len(a.released); n > 0 {
+		id := a.released[n-1]
+		a.released = a.released[:n-1]
+		return id
+	}
+	a.lastID++
+	return a.lastID
+}
+
+func (a *groupAllocator) release(id binding.GroupIDType) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.released = append(a.released, id)
+}
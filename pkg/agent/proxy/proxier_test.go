@@ -0,0 +1,294 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	openflowtest "github.com/vmware-tanzu/antrea/pkg/agent/openflow/testing"
+	"github.com/vmware-tanzu/antrea/pkg/agent/types"
+	binding "github.com/vmware-tanzu/antrea/pkg/ovs/openflow"
+)
+
+type fakeProxier struct {
+	*Proxier
+	informerFactory informers.SharedInformerFactory
+}
+
+func newFakeProxier(ctrl *gomock.Controller, externalPortSyncer ExternalPortSyncer) (*fakeProxier, *openflowtest.MockClient) {
+	ofClient := openflowtest.NewMockClient(ctrl)
+	informerFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	return &fakeProxier{NewProxier(informerFactory, ofClient, localNodeName, externalPortSyncer), informerFactory}, ofClient
+}
+
+// fakeExternalPortSyncer records the ExternalPorts of the last successful sync, and fails the syncs if err is set.
+type fakeExternalPortSyncer struct {
+	ports []*types.ExternalPort
+	syncs int
+	err   error
+}
+
+func (s *fakeExternalPortSyncer) SyncExternalPorts(ports []*types.ExternalPort) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.ports = ports
+	s.syncs++
+	return nil
+}
+
+// setObjects replaces the Service and the Endpoints in the lister caches, a nil object is deleted.
+func (p *fakeProxier) setObjects(t *testing.T, svc *v1.Service, endpoints *v1.Endpoints) {
+	svcStore := p.informerFactory.Core().V1().Services().Informer().GetStore()
+	endpointsStore := p.informerFactory.Core().V1().Endpoints().Informer().GetStore()
+	require.Nil(t, svcStore.Replace(nil, ""))
+	require.Nil(t, endpointsStore.Replace(nil, ""))
+	if svc != nil {
+		require.Nil(t, svcStore.Add(svc))
+	}
+	if endpoints != nil {
+		require.Nil(t, endpointsStore.Add(endpoints))
+	}
+}
+
+func newService(clusterIP string, ports ...v1.ServicePort) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "svc1"},
+		Spec: v1.ServiceSpec{
+			Type:      v1.ServiceTypeClusterIP,
+			ClusterIP: clusterIP,
+			Ports:     ports,
+		},
+	}
+}
+
+func newEndpoints(ports []v1.EndpointPort, ips ...string) *v1.Endpoints {
+	var addresses []v1.EndpointAddress
+	for _, ip := range ips {
+		addresses = append(addresses, v1.EndpointAddress{IP: ip})
+	}
+	return &v1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "svc1"},
+		Subsets:    []v1.EndpointSubset{{Addresses: addresses, Ports: ports}},
+	}
+}
+
+var (
+	httpPort      = v1.ServicePort{Name: "http", Port: 80, Protocol: v1.ProtocolTCP}
+	dnsPort       = v1.ServicePort{Name: "dns", Port: 53, Protocol: v1.ProtocolUDP}
+	httpEPPort    = v1.EndpointPort{Name: "http", Port: 8080, Protocol: v1.ProtocolTCP}
+	dnsEPPort     = v1.EndpointPort{Name: "dns", Port: 5353, Protocol: v1.ProtocolUDP}
+	httpSvcPort   = &types.ServicePort{IP: net.ParseIP("10.96.0.10"), Port: 80, Protocol: v1.ProtocolTCP}
+	dnsSvcPort    = &types.ServicePort{IP: net.ParseIP("10.96.0.10"), Port: 53, Protocol: v1.ProtocolUDP}
+	serviceKey    = "ns1/svc1"
+	serviceIP     = "10.96.0.10"
+	endpointIP1   = "10.10.0.2"
+	endpointIP2   = "10.10.1.2"
+	httpEndpoint1 = &types.Endpoint{IP: net.ParseIP(endpointIP1), Port: 8080}
+	httpEndpoint2 = &types.Endpoint{IP: net.ParseIP(endpointIP2), Port: 8080}
+	localNodeName = "node1"
+)
+
+func TestSyncService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	p, ofClient := newFakeProxier(ctrl, nil)
+
+	// Each port of the Service gets its own group, and only the Endpoints ports with the same name are used.
+	p.setObjects(t, newService(serviceIP, httpPort, dnsPort), newEndpoints([]v1.EndpointPort{httpEPPort}, endpointIP1, endpointIP2))
+	groupIDs := map[string]binding.GroupIDType{}
+	installed := map[string][]*types.Endpoint{}
+	recordInstall := func(groupID binding.GroupIDType, svcPort *types.ServicePort, endpoints []*types.Endpoint, affinityTimeout uint16) error {
+		groupIDs[svcPort.String()] = groupID
+		installed[svcPort.String()] = endpoints
+		return nil
+	}
+	ofClient.EXPECT().InstallServiceFlows(gomock.Any(), gomock.Any(), gomock.Any(), uint16(0)).DoAndReturn(recordInstall).Times(2)
+	require.Nil(t, p.syncService(serviceKey))
+	assert.ElementsMatch(t, []binding.GroupIDType{1, 2}, []binding.GroupIDType{groupIDs[httpSvcPort.String()], groupIDs[dnsSvcPort.String()]})
+	assert.ElementsMatch(t, []*types.Endpoint{httpEndpoint1, httpEndpoint2}, installed[httpSvcPort.String()])
+	assert.Empty(t, installed[dnsSvcPort.String()])
+
+	// Nothing is installed again if the Endpoints didn't change.
+	require.Nil(t, p.syncService(serviceKey))
+
+	// Only the port whose Endpoints changed is installed again, with the same group.
+	p.setObjects(t, newService(serviceIP, httpPort, dnsPort), newEndpoints([]v1.EndpointPort{httpEPPort}, endpointIP2))
+	ofClient.EXPECT().InstallServiceFlows(groupIDs[httpSvcPort.String()], httpSvcPort, []*types.Endpoint{httpEndpoint2}, uint16(0)).Return(nil)
+	require.Nil(t, p.syncService(serviceKey))
+
+	// The flows of all the ports are uninstalled when the Service is deleted, and the group IDs are released.
+	p.setObjects(t, nil, nil)
+	ofClient.EXPECT().UninstallServiceFlows(groupIDs[httpSvcPort.String()], gomock.Any()).Return(nil)
+	ofClient.EXPECT().UninstallServiceFlows(groupIDs[dnsSvcPort.String()], gomock.Any()).Return(nil)
+	require.Nil(t, p.syncService(serviceKey))
+	assert.Empty(t, p.installedServices)
+	assert.ElementsMatch(t, []binding.GroupIDType{1, 2}, p.groupAllocator.released)
+}
+
+func TestSyncServiceExternalPorts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	syncer := &fakeExternalPortSyncer{}
+	p, ofClient := newFakeProxier(ctrl, syncer)
+
+	port := httpPort
+	port.NodePort = 30080
+	svc := newService(serviceIP, port)
+	svc.Spec.Type = v1.ServiceTypeNodePort
+	endpoints := newEndpoints([]v1.EndpointPort{httpEPPort}, endpointIP1, endpointIP2)
+	otherNodeName := "node2"
+	endpoints.Subsets[0].Addresses[0].NodeName = &localNodeName
+	endpoints.Subsets[0].Addresses[1].NodeName = &otherNodeName
+	nodePortSvcPort := &types.ServicePort{IP: types.NodePortVirtualIP, Port: 30080, Protocol: v1.ProtocolTCP}
+
+	// The NodePort is load balanced to all the Endpoints on the NodePort virtual IP, and its traffic is masqueraded.
+	p.setObjects(t, svc, endpoints)
+	ofClient.EXPECT().InstallServiceFlows(gomock.Any(), httpSvcPort, []*types.Endpoint{httpEndpoint1, httpEndpoint2}, uint16(0)).Return(nil)
+	ofClient.EXPECT().InstallServiceFlows(gomock.Any(), nodePortSvcPort, []*types.Endpoint{httpEndpoint1, httpEndpoint2}, uint16(0)).Return(nil)
+	require.Nil(t, p.syncService(serviceKey))
+	assert.Equal(t, []*types.ExternalPort{{Port: 30080, Protocol: v1.ProtocolTCP, NodePort: 30080, Masquerade: true}}, syncer.ports)
+
+	// The ExternalPorts are not synced again if they didn't change.
+	require.Nil(t, p.syncService(serviceKey))
+	assert.Equal(t, 1, syncer.syncs)
+
+	// With the Local externalTrafficPolicy, only the local Endpoints are selected for the NodePort and the traffic is
+	// not masqueraded. The LoadBalancer ingress IPs are DNATed to the NodePort.
+	svc = svc.DeepCopy()
+	svc.Spec.Type = v1.ServiceTypeLoadBalancer
+	svc.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeLocal
+	svc.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "192.168.1.100"}, {Hostname: "lb.example.com"}}
+	p.setObjects(t, svc, endpoints)
+	ofClient.EXPECT().InstallServiceFlows(gomock.Any(), nodePortSvcPort, []*types.Endpoint{httpEndpoint1}, uint16(0)).Return(nil)
+	require.Nil(t, p.syncService(serviceKey))
+	assert.Equal(t, []*types.ExternalPort{
+		{Port: 30080, Protocol: v1.ProtocolTCP, NodePort: 30080},
+		{IP: net.ParseIP("192.168.1.100"), Port: 80, Protocol: v1.ProtocolTCP, NodePort: 30080},
+	}, syncer.ports)
+
+	// A failed sync of the ExternalPorts is retried by the next sync.
+	p.setObjects(t, nil, nil)
+	syncer.err = assert.AnError
+	assert.NotNil(t, p.syncService(serviceKey))
+	syncer.err = nil
+	ofClient.EXPECT().UninstallServiceFlows(gomock.Any(), httpSvcPort).Return(nil)
+	ofClient.EXPECT().UninstallServiceFlows(gomock.Any(), nodePortSvcPort).Return(nil)
+	require.Nil(t, p.syncService(serviceKey))
+	assert.Empty(t, syncer.ports)
+	assert.Empty(t, p.installedServices)
+	assert.Empty(t, p.externalPorts)
+}
+
+// TestInitialSyncExternalPorts checks that the initial sync syncs the ExternalPorts even if no Service has any, so
+// that the rules of a previous Agent are flushed.
+func TestInitialSyncExternalPorts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	syncer := &fakeExternalPortSyncer{ports: []*types.ExternalPort{{Port: 30080, Protocol: v1.ProtocolTCP, NodePort: 30080}}}
+	p, ofClient := newFakeProxier(ctrl, syncer)
+
+	p.setObjects(t, newService(serviceIP, httpPort), newEndpoints([]v1.EndpointPort{httpEPPort}, endpointIP1))
+	ofClient.EXPECT().InstallServiceFlows(gomock.Any(), httpSvcPort, []*types.Endpoint{httpEndpoint1}, uint16(0)).Return(nil)
+	p.initialSync()
+	assert.Equal(t, 1, syncer.syncs)
+	assert.Empty(t, syncer.ports)
+	select {
+	case <-p.InitialSynced():
+	default:
+		t.Errorf("Expected initialSynced to be closed")
+	}
+}
+
+func TestSyncServiceSkipped(t *testing.T) {
+	externalNameService := newService("", httpPort)
+	externalNameService.Spec.Type = v1.ServiceTypeExternalName
+	tests := []struct {
+		name string
+		svc  *v1.Service
+	}{
+		{"headless", newService(v1.ClusterIPNone, httpPort)},
+		{"external-name", externalNameService},
+		{"ipv6", newService("fd00:10:96::10", httpPort)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			p, _ := newFakeProxier(ctrl, nil)
+			p.setObjects(t, tt.svc, newEndpoints([]v1.EndpointPort{httpEPPort}, endpointIP1))
+			require.Nil(t, p.syncService(serviceKey))
+			assert.Empty(t, p.installedServices)
+		})
+	}
+}
+
+// TestSyncServiceFailure checks that a Service port which failed to be installed keeps its group, and is installed
+// again by the next sync.
+func TestSyncServiceFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	p, ofClient := newFakeProxier(ctrl, nil)
+
+	p.setObjects(t, newService(serviceIP, httpPort), newEndpoints([]v1.EndpointPort{httpEPPort}, endpointIP1))
+	ofClient.EXPECT().InstallServiceFlows(binding.GroupIDType(1), httpSvcPort, []*types.Endpoint{httpEndpoint1}, uint16(0)).Return(assert.AnError)
+	assert.NotNil(t, p.syncService(serviceKey))
+	ofClient.EXPECT().InstallServiceFlows(binding.GroupIDType(1), httpSvcPort, []*types.Endpoint{httpEndpoint1}, uint16(0)).Return(nil)
+	require.Nil(t, p.syncService(serviceKey))
+}
+
+func TestGetAffinityTimeout(t *testing.T) {
+	timeout := func(seconds int32) *v1.SessionAffinityConfig {
+		return &v1.SessionAffinityConfig{ClientIP: &v1.ClientIPConfig{TimeoutSeconds: &seconds}}
+	}
+	tests := []struct {
+		name            string
+		affinity        v1.ServiceAffinity
+		config          *v1.SessionAffinityConfig
+		expectedTimeout uint16
+	}{
+		{"none", v1.ServiceAffinityNone, timeout(100), 0},
+		{"default", v1.ServiceAffinityClientIP, nil, uint16(v1.DefaultClientIPServiceAffinitySeconds)},
+		{"timeout", v1.ServiceAffinityClientIP, timeout(100), 100},
+		{"capped", v1.ServiceAffinityClientIP, timeout(int32(24 * time.Hour / time.Second)), maxAffinityTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newService(serviceIP, httpPort)
+			svc.Spec.SessionAffinity = tt.affinity
+			svc.Spec.SessionAffinityConfig = tt.config
+			assert.Equal(t, tt.expectedTimeout, getAffinityTimeout(svc))
+		})
+	}
+}
+
+func TestGroupAllocator(t *testing.T) {
+	a := newGroupAllocator()
+	assert.Equal(t, binding.GroupIDType(1), a.allocate())
+	assert.Equal(t, binding.GroupIDType(2), a.allocate())
+	a.release(1)
+	assert.Equal(t, binding.GroupIDType(1), a.allocate())
+	assert.Equal(t, binding.GroupIDType(3), a.allocate())
+}
@@ -18,15 +18,59 @@ import (
 	"net"
 )
 
+// NodeTransportAddressAnnotationKey is the annotation of the Node in which the Agent publishes the address used for
+// the tunnels and the routes to the Node.
+const NodeTransportAddressAnnotationKey = "node.antrea.io/transport-address"
+
+// NodeWireGuardPublicKeyAnnotationKey is the annotation of the Node in which the Agent publishes the public key of its
+// WireGuard device, when the Pod traffic across Nodes is encrypted with WireGuard.
+const NodeWireGuardPublicKeyAnnotationKey = "node.antrea.io/wireguard-public-key"
+
 type GatewayConfig struct {
-	IP   net.IP
+	// IPv4 and IPv6 are the addresses of the gateway in the Pod CIDRs of the Node. Only the address of the IP
+	// families of the Pod network is set, both are set with dual-stack.
+	IPv4 net.IP
+	IPv6 net.IP
 	MAC  net.HardwareAddr
 	Name string
 }
 
+// IPs returns the addresses of the gateway, the IPv4 one first.
+func (c *GatewayConfig) IPs() []net.IP {
+	var ips []net.IP
+	for _, ip := range []net.IP{c.IPv4, c.IPv6} {
+		if ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
 type NodeConfig struct {
-	Bridge  string
-	Name    string
-	PodCIDR *net.IPNet
+	Bridge string
+	Name   string
+	// PodIPv4CIDR and PodIPv6CIDR are the Pod CIDRs of the Node. Only the CIDR of the IP families of the Pod
+	// network is set, both are set with dual-stack.
+	PodIPv4CIDR *net.IPNet
+	PodIPv6CIDR *net.IPNet
+	// NodeIPAddr is the IP of the Node, with the mask of the subnet of the local interface it is assigned to.
+	NodeIPAddr *net.IPNet
+	// NodeTransportIPAddr is the address used for the tunnels and the routes to the other Nodes, with the mask of its
+	// subnet. It is NodeIPAddr unless a transport interface or CIDR is configured.
+	NodeTransportIPAddr *net.IPNet
+	// NodeMTU is the MTU of the host gateway interface and of the network interfaces of the Pods. Unless it is
+	// configured, it is calculated from the MTU of the transport interface.
+	NodeMTU int
 	*GatewayConfig
 }
+
+// PodCIDRs returns the Pod CIDRs of the Node, the IPv4 one first.
+func (c *NodeConfig) PodCIDRs() []*net.IPNet {
+	var cidrs []*net.IPNet
+	for _, cidr := range []*net.IPNet{c.PodIPv4CIDR, c.PodIPv6CIDR} {
+		if cidr != nil {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs
+}
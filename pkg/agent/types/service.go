@@ -0,0 +1,74 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// NodePortVirtualIP is the link-local address to which the host DNATs the traffic of the ExternalPorts. The host
+// routes it to the gateway, and OVS load balances the traffic sent to NodePortVirtualIP:NodePort across the Endpoints
+// of the Service port.
+var NodePortVirtualIP = net.ParseIP("169.254.169.110")
+
+// ServicePort identifies a port of a Service by the address on which it is exposed.
+type ServicePort struct {
+	IP       net.IP
+	Port     uint16
+	Protocol corev1.Protocol
+}
+
+// String returns the address of the ServicePort, e.g. "10.96.0.10:53/UDP".
+func (p *ServicePort) String() string {
+	return fmt.Sprintf("%s/%s", net.JoinHostPort(p.IP.String(), fmt.Sprint(p.Port)), p.Protocol)
+}
+
+// Endpoint is an address to which the traffic of a ServicePort is load balanced.
+type Endpoint struct {
+	IP   net.IP
+	Port uint16
+}
+
+// String returns the address of the Endpoint, e.g. "10.10.1.2:53".
+func (e *Endpoint) String() string {
+	return net.JoinHostPort(e.IP.String(), fmt.Sprint(e.Port))
+}
+
+// ExternalPort is an address on which a Service port is exposed outside of the cluster: a NodePort on all the local
+// addresses of the Node, or the port of a LoadBalancer ingress IP.
+type ExternalPort struct {
+	// IP is the LoadBalancer ingress IP, or nil for a NodePort.
+	IP       net.IP
+	Port     uint16
+	Protocol corev1.Protocol
+	// NodePort is the NodePort of the Service port: the traffic is DNATed to NodePortVirtualIP:NodePort.
+	NodePort uint16
+	// Masquerade is true if the source address of the traffic must be translated to the address of the gateway, so
+	// that the Endpoints on the other Nodes reply through this Node. It is false if the externalTrafficPolicy of the
+	// Service is Local, which preserves the client address.
+	Masquerade bool
+}
+
+// String returns the address of the ExternalPort, e.g. "*:30080/TCP" or "192.168.1.100:80/TCP".
+func (p *ExternalPort) String() string {
+	ip := "*"
+	if p.IP != nil {
+		ip = p.IP.String()
+	}
+	return fmt.Sprintf("%s/%s", net.JoinHostPort(ip, fmt.Sprint(p.Port)), p.Protocol)
+}
@@ -19,7 +19,10 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"net"
 	"strings"
+
+	v1 "k8s.io/api/core/v1"
 )
 
 const (
@@ -33,14 +36,48 @@ const (
 // return the same value). The output should have length interfaceNameLength (15). The probability of
 // collision should be neglectable.
 func GenerateContainerInterfaceName(podName string, podNamespace string) string {
-	hash := sha1.New()
 	podID := fmt.Sprintf("%s/%s", podNamespace, podName)
-	io.WriteString(hash, podID)
-	podKey := hex.EncodeToString(hash.Sum(nil))
-	name := strings.Replace(podName, "-", "", -1)
+	return generateInterfaceName(podID, podName)
+}
+
+// GenerateNodeTunnelInterfaceName calculates the name of the tunnel port to a peer Node, e.g. its
+// IPSec tunnel port, using the Node name. Like GenerateContainerInterfaceName, the output is
+// deterministic and has length interfaceNameLength (15).
+func GenerateNodeTunnelInterfaceName(nodeName string) string {
+	return generateInterfaceName(nodeName, nodeName)
+}
+
+// generateInterfaceName joins the first characters of prefix and a hash of key.
+func generateInterfaceName(key string, prefix string) string {
+	hash := sha1.New()
+	io.WriteString(hash, key)
+	interfaceKey := hex.EncodeToString(hash.Sum(nil))
+	name := strings.Replace(prefix, "-", "", -1)
 	if len(name) > podNamePrefixLength {
 		name = name[:podNamePrefixLength]
 	}
-	podKeyLength := interfaceNameLength - len(name) - len(containerKeyConnector)
-	return strings.Join([]string{name, podKey[:podKeyLength]}, containerKeyConnector)
+	interfaceKeyLength := interfaceNameLength - len(name) - len(containerKeyConnector)
+	return strings.Join([]string{name, interfaceKey[:interfaceKeyLength]}, containerKeyConnector)
+}
+
+// GetNodeAddr gets the available IP address of a Node. GetNodeAddr will first try to get the
+// NodeInternalIP, then try to get the NodeExternalIP.
+func GetNodeAddr(node *v1.Node) (net.IP, error) {
+	addresses := make(map[v1.NodeAddressType]string)
+	for _, addr := range node.Status.Addresses {
+		addresses[addr.Type] = addr.Address
+	}
+	var ipAddrStr string
+	if internalIP, ok := addresses[v1.NodeInternalIP]; ok {
+		ipAddrStr = internalIP
+	} else if externalIP, ok := addresses[v1.NodeExternalIP]; ok {
+		ipAddrStr = externalIP
+	} else {
+		return nil, fmt.Errorf("Node %s has neither external ip nor internal ip", node.Name)
+	}
+	ipAddr := net.ParseIP(ipAddrStr)
+	if ipAddr == nil {
+		return nil, fmt.Errorf("<%v> is not a valid ip address", ipAddrStr)
+	}
+	return ipAddr, nil
 }
@@ -16,8 +16,11 @@ package util
 
 import (
 	"fmt"
+	"net"
 	"strings"
 	"testing"
+
+	v1 "k8s.io/api/core/v1"
 )
 
 func TestGenerateContainerInterfaceName(t *testing.T) {
@@ -44,3 +47,40 @@ func TestGenerateContainerInterfaceName(t *testing.T) {
 		t.Errorf("failed to differentiate interfaces with pods has the same prefix")
 	}
 }
+
+func TestGenerateNodeTunnelInterfaceName(t *testing.T) {
+	iface1 := GenerateNodeTunnelInterfaceName("k8s-node-1")
+	if len(iface1) != interfaceNameLength {
+		t.Errorf("Failed to ensure length of interface name %s as %d", iface1, interfaceNameLength)
+	}
+	if !strings.HasPrefix(iface1, "k8snode1-") {
+		t.Errorf("failed to use Node name as prefix: %s", iface1)
+	}
+	if iface1 != GenerateNodeTunnelInterfaceName("k8s-node-1") {
+		t.Errorf("failed to generate the same interface name for the same Node")
+	}
+	if iface1 == GenerateNodeTunnelInterfaceName("k8s-node-10") {
+		t.Errorf("failed to differentiate interfaces of Nodes with the same prefix")
+	}
+}
+
+func TestGetNodeAddr(t *testing.T) {
+	node := &v1.Node{}
+	node.Name = "node1"
+	if _, err := GetNodeAddr(node); err == nil {
+		t.Errorf("Expected an error for a Node without address")
+	}
+	node.Status.Addresses = []v1.NodeAddress{
+		{Type: v1.NodeExternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeInternalIP, Address: "192.168.1.1"},
+	}
+	ip, err := GetNodeAddr(node)
+	if err != nil || !ip.Equal(net.ParseIP("192.168.1.1")) {
+		t.Errorf("Expected the internal IP of the Node, got %v, %v", ip, err)
+	}
+	node.Status.Addresses = node.Status.Addresses[:1]
+	ip, err = GetNodeAddr(node)
+	if err != nil || !ip.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Expected the external IP of the Node, got %v, %v", ip, err)
+	}
+}
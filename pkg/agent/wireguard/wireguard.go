@@ -0,0 +1,301 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/crypto/curve25519"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"k8s.io/klog"
+)
+
+const (
+	// DeviceName is the name of the WireGuard device through which the Pod traffic across Nodes is routed.
+	DeviceName = "antrea-wg0"
+	// DefaultKeyDir is the directory in which the private key of the Node is persisted, so that the public key
+	// published to the other Nodes does not change when the Agent restarts.
+	DefaultKeyDir      = "/var/lib/antrea/wireguard"
+	privateKeyFileName = "private.key"
+	keyLen             = 32
+)
+
+// wgctrlClient configures the WireGuard devices through netlink, it is implemented by wgctrl.Client and replaced in
+// the tests.
+type wgctrlClient interface {
+	Device(name string) (*wgtypes.Device, error)
+	ConfigureDevice(name string, cfg wgtypes.Config) error
+}
+
+// Interface is the WireGuard device of the Node, with a peer for each peer Node the Pod traffic is routed to.
+type Interface interface {
+	// Init creates the WireGuard device if it does not exist, configures its MTU, private key and listen port, and
+	// sets it up.
+	Init() error
+	// PublicKey returns the public key of the Node, encoded in base64. It is set by Init.
+	PublicKey() string
+	// LinkIndex returns the index of the WireGuard device, which the routes to the peer Nodes use. It is set by Init.
+	LinkIndex() int
+	// UpdatePeer adds the peer of a Node, or updates it if its public key, endpoint or Pod CIDRs changed. The
+	// traffic to the Pod CIDRs of the Node is sent to the endpoint of the peer.
+	UpdatePeer(nodeName, publicKey string, nodeIP net.IP, podCIDRs []*net.IPNet) error
+	// DeletePeer deletes the peer of a Node, if it exists.
+	DeletePeer(nodeName string) error
+	// DeleteStalePeers deletes the peers whose public key is not in publicKeys, e.g. the peers added by a previous
+	// run of the Agent for Nodes which were deleted since.
+	DeleteStalePeers(publicKeys map[string]bool) error
+}
+
+type client struct {
+	name       string
+	mtu        int
+	listenPort int
+	keyDir     string
+	publicKey  string
+	linkIndex  int
+	// wgClient configures the WireGuard device, it is created by Init.
+	wgClient wgctrlClient
+	// mutex protects peers, UpdatePeer and DeletePeer are called concurrently for different Nodes.
+	mutex sync.Mutex
+	// peers maps the name of each peer Node to the public key of its peer.
+	peers map[string]string
+}
+
+// NewClient returns the Interface of the WireGuard device of the Node. Init must be called before the other methods.
+func NewClient(mtu, listenPort int) Interface {
+	return &client{
+		name:       DeviceName,
+		mtu:        mtu,
+		listenPort: listenPort,
+		keyDir:     DefaultKeyDir,
+		peers:      make(map[string]string),
+	}
+}
+
+func (c *client) Init() error {
+	link, err := netlink.LinkByName(c.name)
+	if err != nil {
+		if _, ok := err.(netlink.LinkNotFoundError); !ok {
+			return fmt.Errorf("failed to get WireGuard device %s: %v", c.name, err)
+		}
+		// There is no WireGuard link type in netlink, the generic link sets its kind.
+		if err := netlink.LinkAdd(&netlink.GenericLink{LinkAttrs: netlink.LinkAttrs{Name: c.name}, LinkType: "wireguard"}); err != nil {
+			return fmt.Errorf("failed to create WireGuard device %s, the WireGuard kernel module may be missing: %v", c.name, err)
+		}
+		klog.Infof("Created WireGuard device %s", c.name)
+		if link, err = netlink.LinkByName(c.name); err != nil {
+			return fmt.Errorf("failed to get WireGuard device %s: %v", c.name, err)
+		}
+	}
+	if err := netlink.LinkSetMTU(link, c.mtu); err != nil {
+		return fmt.Errorf("failed to set the MTU of WireGuard device %s to %d: %v", c.name, c.mtu, err)
+	}
+
+	privateKey, err := loadOrGeneratePrivateKey(c.keyDir)
+	if err != nil {
+		return err
+	}
+	if c.wgClient == nil {
+		if c.wgClient, err = wgctrl.New(); err != nil {
+			return fmt.Errorf("failed to create WireGuard client: %v", err)
+		}
+	}
+	wgPrivateKey := wgtypes.Key(privateKey)
+	if err := c.configureDevice(wgtypes.Config{PrivateKey: &wgPrivateKey, ListenPort: &c.listenPort}); err != nil {
+		return err
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("failed to set WireGuard device %s up: %v", c.name, err)
+	}
+	c.publicKey = encodeKey(getPublicKey(privateKey))
+	c.linkIndex = link.Attrs().Index
+	klog.Infof("WireGuard device %s is listening on port %d with public key %s", c.name, c.listenPort, c.publicKey)
+	return nil
+}
+
+func (c *client) PublicKey() string {
+	return c.publicKey
+}
+
+func (c *client) LinkIndex() int {
+	return c.linkIndex
+}
+
+func (c *client) UpdatePeer(nodeName, publicKey string, nodeIP net.IP, podCIDRs []*net.IPNet) error {
+	key, err := decodeKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid WireGuard public key %q of Node %s: %v", publicKey, nodeName, err)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var peers []wgtypes.PeerConfig
+	// The peer of the former public key of the Node, if any, is replaced.
+	oldPublicKey, ok := c.peers[nodeName]
+	if ok && oldPublicKey != publicKey {
+		oldKey, _ := decodeKey(oldPublicKey)
+		peers = append(peers, wgtypes.PeerConfig{PublicKey: wgtypes.Key(oldKey), Remove: true})
+	}
+	allowedIPs := make([]net.IPNet, 0, len(podCIDRs))
+	for _, podCIDR := range podCIDRs {
+		allowedIPs = append(allowedIPs, *podCIDR)
+	}
+	// The allowed IPs of the peer are replaced.
+	peers = append(peers, wgtypes.PeerConfig{
+		PublicKey:         wgtypes.Key(key),
+		Endpoint:          &net.UDPAddr{IP: nodeIP, Port: c.listenPort},
+		ReplaceAllowedIPs: true,
+		AllowedIPs:        allowedIPs,
+	})
+	if err := c.configureDevice(wgtypes.Config{Peers: peers}); err != nil {
+		return err
+	}
+	c.peers[nodeName] = publicKey
+	return nil
+}
+
+func (c *client) DeletePeer(nodeName string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	publicKey, ok := c.peers[nodeName]
+	if !ok {
+		return nil
+	}
+	key, _ := decodeKey(publicKey)
+	if err := c.configureDevice(wgtypes.Config{Peers: []wgtypes.PeerConfig{{PublicKey: wgtypes.Key(key), Remove: true}}}); err != nil {
+		return err
+	}
+	delete(c.peers, nodeName)
+	return nil
+}
+
+func (c *client) DeleteStalePeers(publicKeys map[string]bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	device, err := c.wgClient.Device(c.name)
+	if err != nil {
+		return fmt.Errorf("failed to list the peers of WireGuard device %s: %v", c.name, err)
+	}
+	var peers []wgtypes.PeerConfig
+	for _, peer := range device.Peers {
+		if publicKeys[peer.PublicKey.String()] {
+			continue
+		}
+		klog.Infof("Deleting stale WireGuard peer %s", peer.PublicKey)
+		peers = append(peers, wgtypes.PeerConfig{PublicKey: peer.PublicKey, Remove: true})
+	}
+	if len(peers) == 0 {
+		return nil
+	}
+	return c.configureDevice(wgtypes.Config{Peers: peers})
+}
+
+// configureDevice applies cfg to the WireGuard device.
+func (c *client) configureDevice(cfg wgtypes.Config) error {
+	if err := c.wgClient.ConfigureDevice(c.name, cfg); err != nil {
+		return fmt.Errorf("failed to configure WireGuard device %s: %v", c.name, err)
+	}
+	return nil
+}
+
+// DeleteDevice deletes the WireGuard device created by a previous run of the Agent, if it exists, with the routes
+// which use it. It's idempotent.
+func DeleteDevice() error {
+	link, err := netlink.LinkByName(DeviceName)
+	if err != nil {
+		if _, ok := err.(netlink.LinkNotFoundError); ok {
+			return nil
+		}
+		return fmt.Errorf("failed to get WireGuard device %s: %v", DeviceName, err)
+	}
+	if err := netlink.LinkDel(link); err != nil {
+		return fmt.Errorf("failed to delete WireGuard device %s: %v", DeviceName, err)
+	}
+	klog.Infof("Deleted WireGuard device %s", DeviceName)
+	return nil
+}
+
+// loadOrGeneratePrivateKey returns the private key persisted in keyDir, or generates a private key and persists it in
+// keyDir if there is none. The key is persisted in base64, which is the format of the wg command.
+func loadOrGeneratePrivateKey(keyDir string) ([keyLen]byte, error) {
+	keyPath := filepath.Join(keyDir, privateKeyFileName)
+	data, err := ioutil.ReadFile(keyPath)
+	if err == nil {
+		privateKey, err := decodeKey(strings.TrimSpace(string(data)))
+		if err == nil {
+			return privateKey, nil
+		}
+		klog.Warningf("Ignoring invalid WireGuard private key in %s: %v", keyPath, err)
+	} else if !os.IsNotExist(err) {
+		return [keyLen]byte{}, fmt.Errorf("failed to read WireGuard private key from %s: %v", keyPath, err)
+	}
+
+	privateKey, err := generatePrivateKey()
+	if err != nil {
+		return [keyLen]byte{}, err
+	}
+	if err := os.MkdirAll(keyDir, 0700); err != nil {
+		return [keyLen]byte{}, fmt.Errorf("failed to create directory %s: %v", keyDir, err)
+	}
+	if err := ioutil.WriteFile(keyPath, []byte(encodeKey(privateKey)+"\n"), 0600); err != nil {
+		return [keyLen]byte{}, fmt.Errorf("failed to persist WireGuard private key to %s: %v", keyPath, err)
+	}
+	klog.Infof("Generated WireGuard private key %s", keyPath)
+	return privateKey, nil
+}
+
+// generatePrivateKey returns a random Curve25519 private key, clamped like the wg genkey command does.
+func generatePrivateKey() ([keyLen]byte, error) {
+	var key [keyLen]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return key, fmt.Errorf("failed to generate WireGuard private key: %v", err)
+	}
+	key[0] &= 248
+	key[31] = (key[31] & 127) | 64
+	return key, nil
+}
+
+// getPublicKey returns the Curve25519 public key of a private key.
+func getPublicKey(privateKey [keyLen]byte) [keyLen]byte {
+	var publicKey [keyLen]byte
+	curve25519.ScalarBaseMult(&publicKey, &privateKey)
+	return publicKey
+}
+
+func encodeKey(key [keyLen]byte) string {
+	return base64.StdEncoding.EncodeToString(key[:])
+}
+
+func decodeKey(str string) ([keyLen]byte, error) {
+	var key [keyLen]byte
+	data, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return key, err
+	}
+	if len(data) != keyLen {
+		return key, fmt.Errorf("key has %d bytes instead of %d", len(data), keyLen)
+	}
+	copy(key[:], data)
+	return key, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// fakeWgctrlClient records the configurations of the WireGuard device, and lists the peers of device.
+type fakeWgctrlClient struct {
+	device  wgtypes.Device
+	configs []wgtypes.Config
+}
+
+func (f *fakeWgctrlClient) Device(name string) (*wgtypes.Device, error) {
+	return &f.device, nil
+}
+
+func (f *fakeWgctrlClient) ConfigureDevice(name string, cfg wgtypes.Config) error {
+	f.configs = append(f.configs, cfg)
+	return nil
+}
+
+// newTestClient returns a client which configures the WireGuard device through a fakeWgctrlClient.
+func newTestClient() (*client, *fakeWgctrlClient) {
+	c := NewClient(1420, 51820).(*client)
+	fakeClient := &fakeWgctrlClient{}
+	c.wgClient = fakeClient
+	return c, fakeClient
+}
+
+func TestGetPublicKey(t *testing.T) {
+	// The test vector of RFC 7748, section 6.1.
+	privateKeyBytes, _ := hex.DecodeString("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")
+	var privateKey [keyLen]byte
+	copy(privateKey[:], privateKeyBytes)
+	publicKey := getPublicKey(privateKey)
+	assert.Equal(t, "8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a", hex.EncodeToString(publicKey[:]))
+
+	decoded, err := decodeKey(encodeKey(publicKey))
+	require.Nil(t, err)
+	assert.Equal(t, publicKey, decoded)
+	_, err = decodeKey("AAAA")
+	assert.NotNil(t, err)
+}
+
+func TestLoadOrGeneratePrivateKey(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "wireguard")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	keyDir := filepath.Join(tempDir, "keys")
+
+	privateKey, err := loadOrGeneratePrivateKey(keyDir)
+	require.Nil(t, err)
+	assert.Equal(t, byte(0), privateKey[0]&7, "private key is not clamped")
+	assert.Equal(t, byte(64), privateKey[31]&192, "private key is not clamped")
+	info, err := os.Stat(filepath.Join(keyDir, privateKeyFileName))
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The persisted key is reused.
+	loadedKey, err := loadOrGeneratePrivateKey(keyDir)
+	require.Nil(t, err)
+	assert.Equal(t, privateKey, loadedKey)
+
+	// An invalid key is replaced.
+	require.Nil(t, ioutil.WriteFile(filepath.Join(keyDir, privateKeyFileName), []byte("invalid"), 0600))
+	newKey, err := loadOrGeneratePrivateKey(keyDir)
+	require.Nil(t, err)
+	assert.NotEqual(t, privateKey, newKey)
+}
+
+func TestUpdatePeer(t *testing.T) {
+	c, fakeClient := newTestClient()
+	key1 := wgtypes.Key{1}
+	key2 := wgtypes.Key{2}
+	_, podCIDR, _ := net.ParseCIDR("10.10.1.0/24")
+	_, podIPv6CIDR, _ := net.ParseCIDR("fd00:10:10:1::/64")
+
+	require.Nil(t, c.UpdatePeer("node1", key1.String(), net.ParseIP("192.168.1.11"), []*net.IPNet{podCIDR, podIPv6CIDR}))
+	assert.Equal(t, []wgtypes.Config{{Peers: []wgtypes.PeerConfig{{
+		PublicKey:         key1,
+		Endpoint:          &net.UDPAddr{IP: net.ParseIP("192.168.1.11"), Port: 51820},
+		ReplaceAllowedIPs: true,
+		AllowedIPs:        []net.IPNet{*podCIDR, *podIPv6CIDR},
+	}}}}, fakeClient.configs)
+
+	// The peer of the former public key is removed.
+	fakeClient.configs = nil
+	require.Nil(t, c.UpdatePeer("node1", key2.String(), net.ParseIP("fd00::11"), []*net.IPNet{podCIDR}))
+	assert.Equal(t, []wgtypes.Config{{Peers: []wgtypes.PeerConfig{
+		{PublicKey: key1, Remove: true},
+		{
+			PublicKey:         key2,
+			Endpoint:          &net.UDPAddr{IP: net.ParseIP("fd00::11"), Port: 51820},
+			ReplaceAllowedIPs: true,
+			AllowedIPs:        []net.IPNet{*podCIDR},
+		},
+	}}}, fakeClient.configs)
+
+	fakeClient.configs = nil
+	require.Nil(t, c.DeletePeer("node1"))
+	require.Nil(t, c.DeletePeer("node2"))
+	assert.Equal(t, []wgtypes.Config{{Peers: []wgtypes.PeerConfig{{PublicKey: key2, Remove: true}}}}, fakeClient.configs)
+
+	assert.NotNil(t, c.UpdatePeer("node1", "invalid", net.ParseIP("192.168.1.11"), []*net.IPNet{podCIDR}))
+}
+
+func TestDeleteStalePeers(t *testing.T) {
+	c, fakeClient := newTestClient()
+	key1 := wgtypes.Key{1}
+	key2 := wgtypes.Key{2}
+	fakeClient.device.Peers = []wgtypes.Peer{{PublicKey: key1}, {PublicKey: key2}}
+
+	require.Nil(t, c.DeleteStalePeers(map[string]bool{key1.String(): true}))
+	assert.Equal(t, []wgtypes.Config{{Peers: []wgtypes.PeerConfig{{PublicKey: key2, Remove: true}}}}, fakeClient.configs)
+
+	// The device is not configured if there is no stale peer.
+	fakeClient.configs = nil
+	require.Nil(t, c.DeleteStalePeers(map[string]bool{key1.String(): true, key2.String(): true}))
+	assert.Empty(t, fakeClient.configs)
+}
@@ -31,10 +31,22 @@ type AntreaAgentInfo struct {
 	NodeRef         corev1.ObjectReference `json:"nodeRef,omitempty"`         // The Node that Antrea Agent is running in
 	NodeSubnet      []string               `json:"nodeSubnet,omitempty"`      // Node subnet
 	OVSInfo         OVSInfo                `json:"ovsInfo,omitempty"`         // OVS Information
+	NetworkInfo     NetworkInfo            `json:"networkInfo,omitempty"`     // Pod network information
 	LocalPodNum     int32                  `json:"localPodNum,omitempty"`     // The number of Pods which the agent is in charge of
 	AgentConditions []AgentCondition       `json:"agentConditions,omitempty"` // Agent condition contains types like AgentHealthy
 }
 
+type NetworkInfo struct {
+	TrafficEncapMode string         `json:"trafficEncapMode,omitempty"` // How the Pod traffic across Nodes is forwarded: encap, noEncap or hybrid
+	PeerNodes        []PeerNodeInfo `json:"peerNodes,omitempty"`        // The peer Nodes to which the Pod traffic is forwarded
+}
+
+type PeerNodeInfo struct {
+	Name   string `json:"name"`             // Node name
+	NodeIP string `json:"nodeIP,omitempty"` // The IP to which the Pod traffic is routed or tunneled
+	Encap  bool   `json:"encap"`            // Whether the Pod traffic to the Node is encapsulated in a tunnel
+}
+
 type OVSInfo struct {
 	Version    string           `json:"version,omitempty"`
 	BridgeName string           `json:"bridgeName,omitempty"`
@@ -52,6 +52,7 @@ func (in *AntreaAgentInfo) DeepCopyInto(out *AntreaAgentInfo) {
 		copy(*out, *in)
 	}
 	in.OVSInfo.DeepCopyInto(&out.OVSInfo)
+	in.NetworkInfo.DeepCopyInto(&out.NetworkInfo)
 	if in.AgentConditions != nil {
 		in, out := &in.AgentConditions, &out.AgentConditions
 		*out = make([]AgentCondition, len(*in))
@@ -200,6 +201,27 @@ func (in *ControllerCondition) DeepCopy() *ControllerCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkInfo) DeepCopyInto(out *NetworkInfo) {
+	*out = *in
+	if in.PeerNodes != nil {
+		in, out := &in.PeerNodes, &out.PeerNodes
+		*out = make([]PeerNodeInfo, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInfo.
+func (in *NetworkInfo) DeepCopy() *NetworkInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkPolicyControllerInfo) DeepCopyInto(out *NetworkPolicyControllerInfo) {
 	*out = *in
@@ -238,3 +260,19 @@ func (in *OVSInfo) DeepCopy() *OVSInfo {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerNodeInfo) DeepCopyInto(out *PeerNodeInfo) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerNodeInfo.
+func (in *PeerNodeInfo) DeepCopy() *PeerNodeInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerNodeInfo)
+	in.DeepCopyInto(out)
+	return out
+}
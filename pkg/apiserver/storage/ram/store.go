@@ -21,6 +21,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/apiserver/pkg/storage"
 	"k8s.io/client-go/tools/cache"
@@ -49,6 +50,8 @@ type store struct {
 	keyFunc cache.KeyFunc
 	// genEventFunc is used to generate InternalEvent from update of an object.
 	genEventFunc antreastorage.GenEventFunc
+	// newFunc is used to create an empty object of the stored resource.
+	newFunc func() runtime.Object
 
 	// resourceVersion up to which the store has generated.
 	resourceVersion uint64
@@ -65,7 +68,8 @@ type store struct {
 // KeyFunc decides how to get the key from an object.
 // Indexers decides how to build indices for an object.
 // GenEventFunc decides how to generate InternalEvent for an update of an object.
-func NewStore(keyFunc cache.KeyFunc, indexers cache.Indexers, genEventFunc antreastorage.GenEventFunc) *store {
+// NewFunc returns an empty object of the stored resource, which is sent to the watchers with the Bookmark event.
+func NewStore(keyFunc cache.KeyFunc, indexers cache.Indexers, genEventFunc antreastorage.GenEventFunc, newFunc func() runtime.Object) *store {
 	stopCh := make(chan struct{})
 	storage := cache.NewIndexer(keyFunc, indexers)
 	s := &store{
@@ -75,6 +79,7 @@ func NewStore(keyFunc cache.KeyFunc, indexers cache.Indexers, genEventFunc antre
 		watchers:     make(map[int]*storeWatcher),
 		keyFunc:      keyFunc,
 		genEventFunc: genEventFunc,
+		newFunc:      newFunc,
 	}
 
 	go s.dispatchEvents()
@@ -222,7 +227,7 @@ func (s *store) Watch(ctx context.Context, key string, labelSelector labels.Sele
 		s.watcherMutex.Lock()
 		defer s.watcherMutex.Unlock()
 
-		w := newStoreWatcher(10, &antreastorage.Selectors{key, labelSelector, fieldSelector}, forgetWatcher(s, s.watcherIdx))
+		w := newStoreWatcher(10, &antreastorage.Selectors{key, labelSelector, fieldSelector}, forgetWatcher(s, s.watcherIdx), s.newFunc)
 		s.watchers[s.watcherIdx] = w
 		s.watcherIdx++
 		return w
@@ -119,6 +119,10 @@ func testGenEvent(key string, prevObj, obj interface{}, resourceVersion uint64)
 	return event, nil
 }
 
+func newPod() runtime.Object {
+	return new(v1.Pod)
+}
+
 func TestRamStoreCRUD(t *testing.T) {
 	key := "pod1"
 	testCases := []struct {
@@ -150,7 +154,7 @@ func TestRamStoreCRUD(t *testing.T) {
 		},
 	}
 	for i, testCase := range testCases {
-		store := NewStore(cache.MetaNamespaceKeyFunc, cache.Indexers{}, nil)
+		store := NewStore(cache.MetaNamespaceKeyFunc, cache.Indexers{}, nil, newPod)
 
 		testCase.operations(store)
 		obj, _, err := store.Get(key)
@@ -216,7 +220,7 @@ func TestRamStoreGetByIndex(t *testing.T) {
 		},
 	}
 	for i, testCase := range testCases {
-		store := NewStore(cache.MetaNamespaceKeyFunc, indexers, testGenEvent)
+		store := NewStore(cache.MetaNamespaceKeyFunc, indexers, testGenEvent, newPod)
 
 		testCase.operations(store)
 		objs, err := store.GetByIndex(indexName, indexKey)
@@ -260,7 +264,7 @@ func TestRamStoreList(t *testing.T) {
 		},
 	}
 	for i, testCase := range testCases {
-		store := NewStore(cache.MetaNamespaceKeyFunc, cache.Indexers{}, testGenEvent)
+		store := NewStore(cache.MetaNamespaceKeyFunc, cache.Indexers{}, testGenEvent, newPod)
 
 		testCase.operations(store)
 		objs := store.List()
@@ -302,13 +306,17 @@ func TestRamStoreWatchAll(t *testing.T) {
 		},
 	}
 	for i, testCase := range testCases {
-		store := NewStore(cache.MetaNamespaceKeyFunc, cache.Indexers{}, testGenEvent)
+		store := NewStore(cache.MetaNamespaceKeyFunc, cache.Indexers{}, testGenEvent, newPod)
 		w, err := store.Watch(context.Background(), "", labels.Everything(), fields.Everything())
 		if err != nil {
 			t.Errorf("%d: failed to watch object: %v", i, err)
 		}
 		testCase.operations(store)
 		ch := w.ResultChan()
+		// The store was empty when the watch started, so the Bookmark event is sent first.
+		if event := <-ch; !reflect.DeepEqual(event, watch.Event{Type: watch.Bookmark, Object: &v1.Pod{}}) {
+			t.Errorf("%d: expected Bookmark event, got %#v", i, event)
+		}
 		for j, expectedEvent := range testCase.expected {
 			actualEvent := <-ch
 			if !reflect.DeepEqual(actualEvent, expectedEvent) {
@@ -330,7 +338,7 @@ func TestRamStoreWatchWithInitOperations(t *testing.T) {
 		// The operations that will be executed on the storage after watching
 		operations func(*store)
 		// We should see the initOperations merged and watched as "ADDED" events
-		// before the events generated by operations
+		// followed by a "BOOKMARK" event before the events generated by operations
 		expected []watch.Event
 	}{
 		{
@@ -345,6 +353,7 @@ func TestRamStoreWatchWithInitOperations(t *testing.T) {
 			},
 			expected: []watch.Event{
 				{watch.Added, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Labels: map[string]string{"app": "nginx3"}}}},
+				{watch.Bookmark, &v1.Pod{}},
 				{watch.Added, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod2", Labels: map[string]string{"app": "nginx2"}}}},
 				{watch.Modified, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod2", Labels: map[string]string{"app": "nginx3"}}}},
 			},
@@ -360,12 +369,13 @@ func TestRamStoreWatchWithInitOperations(t *testing.T) {
 			},
 			expected: []watch.Event{
 				{watch.Added, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Labels: map[string]string{"app": "nginx1"}}}},
+				{watch.Bookmark, &v1.Pod{}},
 				{watch.Deleted, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Labels: map[string]string{"app": "nginx1"}}}},
 			},
 		},
 	}
 	for i, testCase := range testCases {
-		store := NewStore(cache.MetaNamespaceKeyFunc, cache.Indexers{}, testGenEvent)
+		store := NewStore(cache.MetaNamespaceKeyFunc, cache.Indexers{}, testGenEvent, newPod)
 		// Init the storage before watching
 		testCase.initOperations(store)
 		w, err := store.Watch(context.Background(), "", labels.Everything(), fields.Everything())
@@ -433,13 +443,17 @@ func TestRamStoreWatchWithSelector(t *testing.T) {
 		},
 	}
 	for i, testCase := range testCases {
-		store := NewStore(cache.MetaNamespaceKeyFunc, cache.Indexers{}, testGenEvent)
+		store := NewStore(cache.MetaNamespaceKeyFunc, cache.Indexers{}, testGenEvent, newPod)
 		w, err := store.Watch(context.Background(), "", testCase.labelSelector, fields.Everything())
 		if err != nil {
 			t.Errorf("%d: failed to watch object: %v", i, err)
 		}
 		testCase.operations(store)
 		ch := w.ResultChan()
+		// The store was empty when the watch started, so the Bookmark event is sent first.
+		if event := <-ch; !reflect.DeepEqual(event, watch.Event{Type: watch.Bookmark, Object: &v1.Pod{}}) {
+			t.Errorf("%d: expected Bookmark event, got %#v", i, event)
+		}
 		for j, expectedEvent := range testCase.expected {
 			actualEvent := <-ch
 			if !reflect.DeepEqual(actualEvent, expectedEvent) {
@@ -17,6 +17,7 @@ package ram
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/klog"
 
@@ -34,15 +35,18 @@ type storeWatcher struct {
 	selectors *storage.Selectors
 	// forget is used to cleanup the watcher
 	forget func()
+	// newFunc returns an empty object of the watched resource, which is sent with the Bookmark event.
+	newFunc func() runtime.Object
 }
 
-func newStoreWatcher(chanSize int, selectors *storage.Selectors, forget func()) *storeWatcher {
+func newStoreWatcher(chanSize int, selectors *storage.Selectors, forget func(), newFunc func() runtime.Object) *storeWatcher {
 	return &storeWatcher{
 		input:     make(chan storage.InternalEvent, chanSize),
 		result:    make(chan watch.Event, chanSize),
 		done:      make(chan struct{}),
 		selectors: selectors,
 		forget:    forget,
+		newFunc:   newFunc,
 	}
 }
 
@@ -61,12 +65,14 @@ func (w *storeWatcher) add(event storage.InternalEvent) bool {
 	}
 }
 
-// process first sends initEvents and then keeps sending events got from channel input
-// if they are newer than the specified resourceVersion.
+// process first sends initEvents followed by a Bookmark event, and then keeps sending events got from channel
+// input if they are newer than the specified resourceVersion. The Bookmark event lets the client know that it
+// has received all the objects which existed when it started watching.
 func (w *storeWatcher) process(ctx context.Context, initEvents []storage.InternalEvent, resourceVersion uint64) {
 	for _, event := range initEvents {
 		w.sendWatchEvent(event)
 	}
+	w.send(&watch.Event{Type: watch.Bookmark, Object: w.newFunc()})
 	defer close(w.result)
 	for {
 		select {
@@ -92,7 +98,11 @@ func (w *storeWatcher) sendWatchEvent(event storage.InternalEvent) {
 		// Watcher is not interested in that object.
 		return
 	}
+	w.send(watchEvent)
+}
 
+// send sends the watch.Event to result channel, unless the watcher is stopped.
+func (w *storeWatcher) send(watchEvent *watch.Event) {
 	select {
 	case <-w.done:
 		return
@@ -0,0 +1,270 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ipseccertificate signs and approves the CertificateSigningRequests of the Antrea Agents for the certificates
+// with which the IPSec tunnels between the Nodes are authenticated.
+package ipseccertificate
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	certv1beta1 "k8s.io/api/certificates/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	certinformers "k8s.io/client-go/informers/certificates/v1beta1"
+	clientset "k8s.io/client-go/kubernetes"
+	certlisters "k8s.io/client-go/listers/certificates/v1beta1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	"github.com/vmware-tanzu/antrea/pkg/k8s"
+)
+
+const (
+	// The name of the ServiceAccount of the Agents.
+	agentServiceAccountName = "antrea-agent"
+	// The Namespace of the Agents if it is not provided.
+	defaultAgentNamespace = "kube-system"
+	// The keys of the extra info of the users authenticated with a ServiceAccount token bound to a Pod, which identify
+	// the Pod.
+	podNameExtraKey = "authentication.kubernetes.io/pod-name"
+	podUIDExtraKey  = "authentication.kubernetes.io/pod-uid"
+	// How long to wait before retrying to load the CA.
+	caRetryInterval = 10 * time.Second
+	// How long to wait before retrying the processing of a CertificateSigningRequest.
+	minRetryDelay = 5 * time.Second
+	maxRetryDelay = 300 * time.Second
+	// Default number of workers processing a CertificateSigningRequest.
+	defaultWorkers = 2
+)
+
+// CSRApprovingController signs and approves the CertificateSigningRequests created by the Agents for their IPSec
+// certificates. The certificates are signed by the IPSec CA of Antrea, which the Controller creates in agentNamespace
+// if it does not exist. A request is approved only if it was created by the ServiceAccount of the Agents, with a
+// token bound to an Agent Pod, and requests a certificate for the IPSec tunnels of the Node of this Pod, with the
+// name of the Node as Common Name. The other requests are left to the other approvers.
+type CSRApprovingController struct {
+	kubeClient      clientset.Interface
+	csrLister       certlisters.CertificateSigningRequestLister
+	csrListerSynced cache.InformerSynced
+	queue           workqueue.RateLimitingInterface
+	// namespace is the Namespace of the Agents and of the IPSec CA.
+	namespace string
+	// agentUsername is the username of the ServiceAccount of the Agents.
+	agentUsername string
+	// ca signs the certificates, it is loaded before the requests are processed.
+	ca *certificateAuthority
+}
+
+// NewCSRApprovingController returns a CSRApprovingController for the Agents running in agentNamespace.
+func NewCSRApprovingController(kubeClient clientset.Interface, csrInformer certinformers.CertificateSigningRequestInformer, agentNamespace string) *CSRApprovingController {
+	if agentNamespace == "" {
+		agentNamespace = defaultAgentNamespace
+	}
+	c := &CSRApprovingController{
+		kubeClient:      kubeClient,
+		csrLister:       csrInformer.Lister(),
+		csrListerSynced: csrInformer.Informer().HasSynced,
+		queue:           workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "ipsecCertificateSigningRequest"),
+		namespace:       agentNamespace,
+		agentUsername:   serviceaccount.MakeUsername(agentNamespace, agentServiceAccountName),
+	}
+	csrInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: c.enqueueCSR,
+			UpdateFunc: func(old, cur interface{}) {
+				c.enqueueCSR(cur)
+			},
+		},
+	)
+	return c
+}
+
+func (c *CSRApprovingController) enqueueCSR(obj interface{}) {
+	csr, ok := obj.(*certv1beta1.CertificateSigningRequest)
+	if !ok || !strings.HasPrefix(csr.Name, k8s.IPSecCSRNamePrefix) {
+		return
+	}
+	c.queue.Add(csr.Name)
+}
+
+// Run begins watching and processing the CertificateSigningRequests.
+func (c *CSRApprovingController) Run(stopCh <-chan struct{}) {
+	defer c.queue.ShutDown()
+
+	klog.Info("Starting IPSec CSR approving controller")
+	defer klog.Info("Shutting down IPSec CSR approving controller")
+
+	klog.Info("Waiting for caches to sync for IPSec CSR approving controller")
+	if !cache.WaitForCacheSync(stopCh, c.csrListerSynced) {
+		klog.Error("Unable to sync caches for IPSec CSR approving controller")
+		return
+	}
+	klog.Info("Caches are synced for IPSec CSR approving controller")
+
+	if err := wait.PollImmediateUntil(caRetryInterval, func() (bool, error) {
+		ca, err := c.loadOrCreateCA()
+		if err != nil {
+			klog.Errorf("Failed to load the IPSec CA, retrying: %v", err)
+			return false, nil
+		}
+		c.ca = ca
+		return true, nil
+	}, stopCh); err != nil {
+		return
+	}
+
+	for i := 0; i < defaultWorkers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+	<-stopCh
+}
+
+func (c *CSRApprovingController) worker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *CSRApprovingController) processNextWorkItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.syncCSR(key.(string)); err != nil {
+		c.queue.AddRateLimited(key)
+		klog.Errorf("Failed to sync CertificateSigningRequest %s: %v", key, err)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
+
+// syncCSR signs and approves a CertificateSigningRequest if it is a valid request of an Agent which is neither
+// approved nor denied yet.
+func (c *CSRApprovingController) syncCSR(name string) error {
+	csr, err := c.csrLister.Get(name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	for _, condition := range csr.Status.Conditions {
+		if condition.Type == certv1beta1.CertificateApproved || condition.Type == certv1beta1.CertificateDenied {
+			return nil
+		}
+	}
+	request, err := c.validateCSR(csr)
+	if err != nil {
+		// The request is left pending, it may be approved by another approver.
+		klog.Warningf("Not approving CertificateSigningRequest %s: %v", csr.Name, err)
+		return nil
+	}
+	// The certificate is only issued to the Agent running on the Node of the certificate, which is identified by the
+	// Pod its ServiceAccount token is bound to, as all the Agents share the same ServiceAccount.
+	nodeName := request.Subject.CommonName
+	podName, podUID := getExtraValue(csr, podNameExtraKey), getExtraValue(csr, podUIDExtraKey)
+	if podName == "" || podUID == "" {
+		klog.Warningf("Not approving CertificateSigningRequest %s: not requested with a ServiceAccount token bound to a Pod", csr.Name)
+		return nil
+	}
+	pod, err := c.kubeClient.CoreV1().Pods(c.namespace).Get(podName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			klog.Warningf("Not approving CertificateSigningRequest %s: Pod %s/%s does not exist", csr.Name, c.namespace, podName)
+			return nil
+		}
+		return fmt.Errorf("failed to get Pod %s/%s: %v", c.namespace, podName, err)
+	}
+	if string(pod.UID) != podUID || pod.Spec.NodeName != nodeName {
+		klog.Warningf("Not approving CertificateSigningRequest %s: requested by Pod %s/%s which does not run on Node %s", csr.Name, c.namespace, podName, nodeName)
+		return nil
+	}
+
+	csr = csr.DeepCopy()
+	// The certificate is set before the request is approved, so that it is not signed by the signer of
+	// kube-controller-manager, which signs all the approved requests without a certificate. The request is only
+	// signed again if the approval failed after it was signed.
+	if len(csr.Status.Certificate) == 0 {
+		if csr.Status.Certificate, err = c.ca.sign(request); err != nil {
+			return err
+		}
+		if csr, err = c.kubeClient.CertificatesV1beta1().CertificateSigningRequests().UpdateStatus(csr); err != nil {
+			return fmt.Errorf("failed to set the certificate: %v", err)
+		}
+	}
+	csr.Status.Conditions = append(csr.Status.Conditions, certv1beta1.CertificateSigningRequestCondition{
+		Type:           certv1beta1.CertificateApproved,
+		Reason:         "AutoApproved",
+		Message:        "Automatically approved IPSec certificate of Antrea Agent",
+		LastUpdateTime: metav1.Now(),
+	})
+	if _, err := c.kubeClient.CertificatesV1beta1().CertificateSigningRequests().UpdateApproval(csr); err != nil {
+		return fmt.Errorf("failed to approve: %v", err)
+	}
+	klog.Infof("Signed and approved CertificateSigningRequest %s for Node %s", csr.Name, nodeName)
+	return nil
+}
+
+// validateCSR returns an error if a CertificateSigningRequest is not a request of an Agent for its IPSec certificate,
+// and the certificate request, whose Common Name is the name of the Node, otherwise.
+func (c *CSRApprovingController) validateCSR(csr *certv1beta1.CertificateSigningRequest) (*x509.CertificateRequest, error) {
+	if csr.Spec.Username != c.agentUsername {
+		return nil, fmt.Errorf("requested by %s instead of %s", csr.Spec.Username, c.agentUsername)
+	}
+	if !reflect.DeepEqual(csr.Spec.Usages, k8s.IPSecCertificateUsages) {
+		return nil, fmt.Errorf("unexpected usages %v", csr.Spec.Usages)
+	}
+	block, _ := pem.Decode(csr.Spec.Request)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("no PEM certificate request found")
+	}
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate request: %v", err)
+	}
+	if err := request.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid signature of certificate request: %v", err)
+	}
+	if len(request.DNSNames) > 0 || len(request.IPAddresses) > 0 || len(request.EmailAddresses) > 0 || len(request.URIs) > 0 {
+		return nil, fmt.Errorf("certificate request has Subject Alternative Names")
+	}
+	nodeName := request.Subject.CommonName
+	if nodeName == "" {
+		return nil, fmt.Errorf("certificate request has no Common Name")
+	}
+	if !strings.HasPrefix(csr.Name, k8s.IPSecCSRNamePrefix+nodeName+"-") {
+		return nil, fmt.Errorf("certificate request for %q does not match the name of the CertificateSigningRequest", nodeName)
+	}
+	return request, nil
+}
+
+// getExtraValue returns the value of the extra info key of the user who created a CertificateSigningRequest, or an
+// empty string if it does not have exactly one value.
+func getExtraValue(csr *certv1beta1.CertificateSigningRequest, key string) string {
+	if values := csr.Spec.Extra[key]; len(values) == 1 {
+		return values[0]
+	}
+	return ""
+}
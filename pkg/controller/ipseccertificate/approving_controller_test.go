@@ -0,0 +1,213 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipseccertificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	certv1beta1 "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vmware-tanzu/antrea/pkg/k8s"
+)
+
+const agentUsername = "system:serviceaccount:kube-system:antrea-agent"
+
+func newCertificateRequest(t *testing.T, template *x509.CertificateRequest) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	require.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+func TestSyncCSR(t *testing.T) {
+	agentPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "antrea-agent-abcde", Namespace: "kube-system", UID: "uid1"},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+	}
+	agentPodExtra := map[string]certv1beta1.ExtraValue{
+		podNameExtraKey: {"antrea-agent-abcde"},
+		podUIDExtraKey:  {"uid1"},
+	}
+	nodeRequest := newCertificateRequest(t, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "node1"}})
+	tests := []struct {
+		name             string
+		csrName          string
+		username         string
+		extra            map[string]certv1beta1.ExtraValue
+		usages           []certv1beta1.KeyUsage
+		request          []byte
+		conditions       []certv1beta1.CertificateSigningRequestCondition
+		expectedApproval bool
+	}{
+		{
+			name:             "valid",
+			csrName:          "antrea-ipsec-node1-abcde",
+			username:         agentUsername,
+			extra:            agentPodExtra,
+			usages:           k8s.IPSecCertificateUsages,
+			request:          nodeRequest,
+			expectedApproval: true,
+		},
+		{
+			name:     "token not bound to a Pod",
+			csrName:  "antrea-ipsec-node1-abcde",
+			username: agentUsername,
+			usages:   k8s.IPSecCertificateUsages,
+			request:  nodeRequest,
+		},
+		{
+			name:     "Pod of another Node",
+			csrName:  "antrea-ipsec-node2-abcde",
+			username: agentUsername,
+			extra:    agentPodExtra,
+			usages:   k8s.IPSecCertificateUsages,
+			request:  newCertificateRequest(t, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "node2"}}),
+		},
+		{
+			name:     "deleted Pod",
+			csrName:  "antrea-ipsec-node1-abcde",
+			username: agentUsername,
+			extra: map[string]certv1beta1.ExtraValue{
+				podNameExtraKey: {"antrea-agent-abcde"},
+				podUIDExtraKey:  {"uid0"},
+			},
+			usages:  k8s.IPSecCertificateUsages,
+			request: nodeRequest,
+		},
+		{
+			name:     "other user",
+			csrName:  "antrea-ipsec-node1-abcde",
+			username: "system:serviceaccount:default:antrea-agent",
+			usages:   k8s.IPSecCertificateUsages,
+			request:  nodeRequest,
+		},
+		{
+			name:     "other usages",
+			csrName:  "antrea-ipsec-node1-abcde",
+			username: agentUsername,
+			extra:    agentPodExtra,
+			usages:   []certv1beta1.KeyUsage{certv1beta1.UsageDigitalSignature, certv1beta1.UsageCertSign},
+			request:  nodeRequest,
+		},
+		{
+			name:     "name mismatch",
+			csrName:  "antrea-ipsec-node2-abcde",
+			username: agentUsername,
+			extra:    agentPodExtra,
+			usages:   k8s.IPSecCertificateUsages,
+			request:  nodeRequest,
+		},
+		{
+			name:     "subject alternative name",
+			csrName:  "antrea-ipsec-node1-abcde",
+			username: agentUsername,
+			extra:    agentPodExtra,
+			usages:   k8s.IPSecCertificateUsages,
+			request:  newCertificateRequest(t, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "node1"}, DNSNames: []string{"kubernetes"}}),
+		},
+		{
+			name:       "denied",
+			csrName:    "antrea-ipsec-node1-abcde",
+			username:   agentUsername,
+			extra:      agentPodExtra,
+			usages:     k8s.IPSecCertificateUsages,
+			request:    nodeRequest,
+			conditions: []certv1beta1.CertificateSigningRequestCondition{{Type: certv1beta1.CertificateDenied}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certv1beta1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: tt.csrName},
+				Spec: certv1beta1.CertificateSigningRequestSpec{
+					Request:  tt.request,
+					Usages:   tt.usages,
+					Username: tt.username,
+					Extra:    tt.extra,
+				},
+				Status: certv1beta1.CertificateSigningRequestStatus{Conditions: tt.conditions},
+			}
+			kubeClient := fake.NewSimpleClientset(agentPod, csr)
+			informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+			csrInformer := informerFactory.Certificates().V1beta1().CertificateSigningRequests()
+			c := NewCSRApprovingController(kubeClient, csrInformer, "")
+			require.Nil(t, csrInformer.Informer().GetIndexer().Add(csr))
+			ca, err := c.loadOrCreateCA()
+			require.Nil(t, err)
+			c.ca = ca
+
+			require.Nil(t, c.syncCSR(tt.csrName))
+			csr, err = kubeClient.CertificatesV1beta1().CertificateSigningRequests().Get(tt.csrName, metav1.GetOptions{})
+			require.Nil(t, err)
+			approved := false
+			for _, condition := range csr.Status.Conditions {
+				if condition.Type == certv1beta1.CertificateApproved {
+					approved = true
+				}
+			}
+			assert.Equal(t, tt.expectedApproval, approved)
+			if !tt.expectedApproval {
+				assert.Empty(t, csr.Status.Certificate)
+				return
+			}
+			// The certificate is signed by the IPSec CA.
+			block, _ := pem.Decode(csr.Status.Certificate)
+			require.NotNil(t, block)
+			cert, err := x509.ParseCertificate(block.Bytes)
+			require.Nil(t, err)
+			assert.Nil(t, cert.CheckSignatureFrom(ca.cert))
+			assert.Equal(t, "node1", cert.Subject.CommonName)
+			assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}, cert.ExtKeyUsage)
+		})
+	}
+}
+
+func TestLoadOrCreateCA(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	csrInformer := informers.NewSharedInformerFactory(kubeClient, 0).Certificates().V1beta1().CertificateSigningRequests()
+	c := NewCSRApprovingController(kubeClient, csrInformer, "")
+
+	// The CA is created, and its certificate published, if it does not exist.
+	ca, err := c.loadOrCreateCA()
+	require.Nil(t, err)
+	assert.True(t, ca.cert.IsCA)
+	configMap, err := kubeClient.CoreV1().ConfigMaps("kube-system").Get(k8s.IPSecCAName, metav1.GetOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, string(ca.certPEM), configMap.Data[k8s.IPSecCACertKey])
+
+	// The existing CA is reused, and the certificate published again if it was modified.
+	configMap.Data[k8s.IPSecCACertKey] = "invalid"
+	_, err = kubeClient.CoreV1().ConfigMaps("kube-system").Update(configMap)
+	require.Nil(t, err)
+	reloadedCA, err := c.loadOrCreateCA()
+	require.Nil(t, err)
+	assert.Equal(t, ca.certPEM, reloadedCA.certPEM)
+	configMap, err = kubeClient.CoreV1().ConfigMaps("kube-system").Get(k8s.IPSecCAName, metav1.GetOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, string(ca.certPEM), configMap.Data[k8s.IPSecCACertKey])
+}
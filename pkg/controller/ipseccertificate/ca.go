@@ -0,0 +1,208 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipseccertificate
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	"github.com/vmware-tanzu/antrea/pkg/k8s"
+)
+
+const (
+	caCommonName = "antrea-ipsec-ca"
+	caKeySize    = 2048
+	caValidity   = 10 * 365 * 24 * time.Hour
+	// certificateValidity is the validity period of the IPSec certificates of the Agents, which renew them before
+	// they expire.
+	certificateValidity = 365 * 24 * time.Hour
+)
+
+// certificateAuthority is the CA which signs the IPSec certificates of the Agents. It is dedicated to the IPSec
+// tunnels, so that the peer Nodes only accept the certificates of the Agents, and not any certificate signed by the
+// CA of the cluster.
+type certificateAuthority struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     crypto.Signer
+}
+
+// loadOrCreateCA returns the CA stored in the Secret of the IPSec CA, which is created with a new self-signed CA if
+// it does not exist, and publishes the CA certificate in the ConfigMap from which the Agents read it.
+func (c *CSRApprovingController) loadOrCreateCA() (*certificateAuthority, error) {
+	secret, err := c.kubeClient.CoreV1().Secrets(c.namespace).Get(k8s.IPSecCAName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		secret, err = c.createCASecret()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Secret %s/%s: %v", c.namespace, k8s.IPSecCAName, err)
+	}
+	ca, err := parseCA(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA in Secret %s/%s: %v", c.namespace, k8s.IPSecCAName, err)
+	}
+	if err := c.publishCACert(ca.certPEM); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// createCASecret generates a self-signed CA and stores it in the Secret of the IPSec CA. If the Secret was created
+// concurrently, the existing one is returned.
+func (c *CSRApprovingController) createCASecret() (*corev1.Secret, error) {
+	key, err := rsa.GenerateKey(rand.Reader, caKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA private key: %v", err)
+	}
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: caCommonName},
+		NotBefore:             now,
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %v", err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: k8s.IPSecCAName, Namespace: c.namespace},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+	}
+	secret, err = c.kubeClient.CoreV1().Secrets(c.namespace).Create(secret)
+	if errors.IsAlreadyExists(err) {
+		return c.kubeClient.CoreV1().Secrets(c.namespace).Get(k8s.IPSecCAName, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	klog.Infof("Created IPSec CA in Secret %s/%s", c.namespace, k8s.IPSecCAName)
+	return secret, nil
+}
+
+// publishCACert creates or updates the ConfigMap of the IPSec CA with the CA certificate.
+func (c *CSRApprovingController) publishCACert(certPEM []byte) error {
+	configMaps := c.kubeClient.CoreV1().ConfigMaps(c.namespace)
+	configMap, err := configMaps.Get(k8s.IPSecCAName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: k8s.IPSecCAName, Namespace: c.namespace},
+			Data:       map[string]string{k8s.IPSecCACertKey: string(certPEM)},
+		}
+		if _, err := configMaps.Create(configMap); err != nil {
+			return fmt.Errorf("failed to create ConfigMap %s/%s: %v", c.namespace, k8s.IPSecCAName, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get ConfigMap %s/%s: %v", c.namespace, k8s.IPSecCAName, err)
+	}
+	if configMap.Data[k8s.IPSecCACertKey] == string(certPEM) {
+		return nil
+	}
+	configMap = configMap.DeepCopy()
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[k8s.IPSecCACertKey] = string(certPEM)
+	if _, err := configMaps.Update(configMap); err != nil {
+		return fmt.Errorf("failed to update ConfigMap %s/%s: %v", c.namespace, k8s.IPSecCAName, err)
+	}
+	return nil
+}
+
+// parseCA parses the PEM encoded certificate and private key of a CA.
+func parseCA(certPEM, keyPEM []byte) (*certificateAuthority, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil || certBlock.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %v", err)
+	}
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil || keyBlock.Type != "RSA PRIVATE KEY" {
+		return nil, fmt.Errorf("no PEM RSA private key found")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+	if publicKey, ok := cert.PublicKey.(*rsa.PublicKey); !ok || publicKey.N.Cmp(key.N) != 0 || publicKey.E != key.E {
+		return nil, fmt.Errorf("private key does not match the certificate")
+	}
+	return &certificateAuthority{cert: cert, certPEM: certPEM, key: key}, nil
+}
+
+// sign returns the PEM encoded certificate of the Node requested by an Agent, which is valid for certificateValidity,
+// or until the CA expires.
+func (ca *certificateAuthority) sign(request *x509.CertificateRequest) ([]byte, error) {
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	notAfter := now.Add(certificateValidity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: request.Subject.CommonName},
+		NotBefore:             now,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, request.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// newSerialNumber returns a random 128-bit certificate serial number.
+func newSerialNumber() (*big.Int, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+	return serialNumber, nil
+}
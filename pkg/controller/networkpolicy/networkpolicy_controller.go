@@ -650,6 +650,22 @@ func (n *NetworkPolicyController) addPod(obj interface{}) {
 	}
 }
 
+// getPodIPs returns the IPs of the Pod, there is one IP per IP family with
+// dual-stack. Status.PodIPs is not set by the clusters which are not
+// dual-stack aware, Status.PodIP is used then.
+func getPodIPs(pod *v1.Pod) []string {
+	var ips []string
+	for _, podIP := range pod.Status.PodIPs {
+		if podIP.IP != "" {
+			ips = append(ips, podIP.IP)
+		}
+	}
+	if len(ips) == 0 && pod.Status.PodIP != "" {
+		ips = append(ips, pod.Status.PodIP)
+	}
+	return ips
+}
+
 // updatePod retrieves all AddressGroups and AppliedToGroups which match the
 // updated and old Pod's labels and enqueues the group keys for further
 // processing.
@@ -660,7 +676,8 @@ func (n *NetworkPolicyController) updatePod(oldObj, curObj interface{}) {
 	// No need to trigger processing of groups if there is no change in the
 	// Pod labels or Pods Node or Pods IP.
 	labelsEqual := labels.Equals(labels.Set(oldPod.Labels), labels.Set(curPod.Labels))
-	if labelsEqual && oldPod.Spec.NodeName == curPod.Spec.NodeName && oldPod.Status.PodIP == curPod.Status.PodIP {
+	ipsEqual := sets.NewString(getPodIPs(oldPod)...).Equal(sets.NewString(getPodIPs(curPod)...))
+	if labelsEqual && oldPod.Spec.NodeName == curPod.Spec.NodeName && ipsEqual {
 		klog.V(4).Infof("No change in Pod %s/%s. Skipping NetworkPolicy evaluation.", curPod.Namespace, curPod.Name)
 		return
 	}
@@ -675,7 +692,7 @@ func (n *NetworkPolicyController) updatePod(oldObj, curObj interface{}) {
 	var addressGroupKeys sets.String
 	// AppliedToGroup keys must be enqueued only if the Pod's Node or IP has changed or
 	// if Pod's label change causes it to match new Groups.
-	if oldPod.Status.PodIP != curPod.Status.PodIP || oldPod.Spec.NodeName != curPod.Spec.NodeName {
+	if !ipsEqual || oldPod.Spec.NodeName != curPod.Spec.NodeName {
 		appliedToGroupKeys = oldAppliedToGroupKeySet.Union(curAppliedToGroupKeySet)
 	} else if !labelsEqual {
 		// No need to enqueue common AppliedToGroups as they already have latest Pod
@@ -684,7 +701,7 @@ func (n *NetworkPolicyController) updatePod(oldObj, curObj interface{}) {
 	}
 	// AddressGroup keys must be enqueued only if the Pod's IP has changed or
 	// if Pod's label change causes it to match new Groups.
-	if oldPod.Status.PodIP != curPod.Status.PodIP {
+	if !ipsEqual {
 		addressGroupKeys = oldAddressGroupKeySet.Union(curAddressGroupKeySet)
 	} else if !labelsEqual {
 		// No need to enqueue common AddressGroups as they already have latest Pod
@@ -1011,11 +1028,8 @@ func (n *NetworkPolicyController) syncAddressGroup(key string) error {
 	}
 	addresses := sets.String{}
 	for _, pod := range pods {
-		if pod.Status.PodIP == "" {
-			// No need to insert Pod IPAdddress when it is unset.
-			continue
-		}
-		addresses.Insert(pod.Status.PodIP)
+		// No need to insert Pod IPAdddress when it is unset. With dual-stack, both addresses of the Pod are inserted.
+		addresses.Insert(getPodIPs(pod)...)
 	}
 	updatedAddressGroup := &antreatypes.AddressGroup{
 		Name:      addressGroup.Name,
@@ -1059,7 +1073,7 @@ func (n *NetworkPolicyController) syncAppliedToGroup(key string) error {
 	// Retrieve all Pods matching the podSelector.
 	pods, err = n.podLister.Pods(appliedToGroup.Selector.Namespace).List(selector)
 	for _, pod := range pods {
-		if pod.Status.PodIP == "" {
+		if len(getPodIPs(pod)) == 0 {
 			// No need to process Pod when IPAddress is unset.
 			continue
 		}
@@ -241,6 +241,32 @@ func TestToAntreaServices(t *testing.T) {
 	}
 }
 
+func TestGetPodIPs(t *testing.T) {
+	tables := []struct {
+		status v1.PodStatus
+		expIPs []string
+	}{
+		{
+			v1.PodStatus{},
+			nil,
+		},
+		{
+			v1.PodStatus{PodIP: "10.10.1.2"},
+			[]string{"10.10.1.2"},
+		},
+		{
+			v1.PodStatus{PodIP: "10.10.1.2", PodIPs: []v1.PodIP{{IP: "10.10.1.2"}, {IP: "fd00:10:0:1::2"}}},
+			[]string{"10.10.1.2", "fd00:10:0:1::2"},
+		},
+	}
+	for _, table := range tables {
+		ips := getPodIPs(&v1.Pod{Status: table.status})
+		if fmt.Sprint(ips) != fmt.Sprint(table.expIPs) {
+			t.Errorf("Unexpected Pod IPs. Expected %v, got %v", table.expIPs, ips)
+		}
+	}
+}
+
 func getK8sNetworkPolicyPorts(proto v1.Protocol) []networkingv1.NetworkPolicyPort {
 	portNum := intstr.FromInt(80)
 	port := networkingv1.NetworkPolicyPort{
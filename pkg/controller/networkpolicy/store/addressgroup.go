@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"reflect"
 
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
 
@@ -159,5 +160,5 @@ func AddressGroupKeyFunc(obj interface{}) (string, error) {
 
 // NewAddressGroupStore creates a store of AddressGroup.
 func NewAddressGroupStore() storage.Interface {
-	return ram.NewStore(AddressGroupKeyFunc, cache.Indexers{}, genAddressGroupEvent)
+	return ram.NewStore(AddressGroupKeyFunc, cache.Indexers{}, genAddressGroupEvent, func() runtime.Object { return new(networkpolicy.AddressGroup) })
 }
@@ -130,6 +130,10 @@ func TestWatchAppliedToGroupEvent(t *testing.T) {
 			}
 			testCase.operations(store)
 			ch := w.ResultChan()
+			// The store was empty when the watch started, so the Bookmark event is sent first.
+			if event := <-ch; event.Type != watch.Bookmark {
+				t.Fatalf("Expected event type %v, got %v", watch.Bookmark, event.Type)
+			}
 			for _, expectedEvent := range testCase.expected {
 				actualEvent := <-ch
 				if actualEvent.Type != expectedEvent.Type {
@@ -26,6 +26,39 @@ import (
 
 // CreateClients creates kube clients from the given config.
 func CreateClients(config componentbaseconfig.ClientConnectionConfiguration) (clientset.Interface, crdclientset.Interface, error) {
+	kubeConfig, err := createRestConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := clientset.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Create client for crd operations
+	crdClient, err := crdclientset.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, crdClient, nil
+}
+
+// CreateBoundTokenClient creates a kube client from the given config which, with the in-cluster config, authenticates
+// with the ServiceAccount token projected in tokenFile, which is bound to the Pod, instead of the token of the
+// ServiceAccount. The token is read again from tokenFile when it is rotated by the kubelet.
+func CreateBoundTokenClient(config componentbaseconfig.ClientConnectionConfiguration, tokenFile string) (clientset.Interface, error) {
+	kubeConfig, err := createRestConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Kubeconfig) == 0 {
+		kubeConfig.BearerToken = ""
+		kubeConfig.BearerTokenFile = tokenFile
+	}
+	return clientset.NewForConfig(kubeConfig)
+}
+
+func createRestConfig(config componentbaseconfig.ClientConnectionConfiguration) (*rest.Config, error) {
 	var kubeConfig *rest.Config
 	var err error
 
@@ -38,22 +71,12 @@ func CreateClients(config componentbaseconfig.ClientConnectionConfiguration) (cl
 			&clientcmd.ConfigOverrides{}).ClientConfig()
 	}
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	kubeConfig.AcceptContentTypes = config.AcceptContentTypes
 	kubeConfig.ContentType = config.ContentType
 	kubeConfig.QPS = config.QPS
 	kubeConfig.Burst = int(config.Burst)
-
-	client, err := clientset.NewForConfig(kubeConfig)
-	if err != nil {
-		return nil, nil, err
-	}
-	// Create client for crd operations
-	crdClient, err := crdclientset.NewForConfig(kubeConfig)
-	if err != nil {
-		return nil, nil, err
-	}
-	return client, crdClient, nil
+	return kubeConfig, nil
 }
@@ -0,0 +1,40 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	certv1beta1 "k8s.io/api/certificates/v1beta1"
+)
+
+// IPSecCSRNamePrefix is the prefix of the name of the CertificateSigningRequests created by the Agents for the
+// certificates they authenticate the IPSec tunnels with. The name of the Node follows the prefix.
+const IPSecCSRNamePrefix = "antrea-ipsec-"
+
+// IPSecCertificateUsages are the usages of the certificates of the Agents for the IPSec tunnels. The same certificate
+// authenticates the Node both when it initiates the IKE negotiation and when it responds to it.
+var IPSecCertificateUsages = []certv1beta1.KeyUsage{
+	certv1beta1.UsageDigitalSignature,
+	certv1beta1.UsageKeyEncipherment,
+	certv1beta1.UsageServerAuth,
+	certv1beta1.UsageClientAuth,
+}
+
+// IPSecCAName is the name of the Secret in which the Antrea Controller stores the CA which signs the IPSec
+// certificates of the Agents, and of the ConfigMap in which it publishes the CA certificate for the Agents. Both are
+// in the Namespace of Antrea.
+const IPSecCAName = "antrea-ipsec-ca"
+
+// IPSecCACertKey is the key of the CA certificate in the ConfigMap of the IPSec CA.
+const IPSecCACertKey = "ca.crt"
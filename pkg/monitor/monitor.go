@@ -38,21 +38,23 @@ type controllerMonitor struct {
 }
 
 type agentMonitor struct {
-	client          clientset.Interface
-	ovsBridge       string
-	nodeName        string
-	nodeSubnet      string
-	interfaceStore  interfacestore.InterfaceStore
-	ofClient        openflow.Client
-	ovsBridgeClient ovsconfig.OVSBridgeClient
+	client           clientset.Interface
+	ovsBridge        string
+	nodeName         string
+	nodeSubnets      []string
+	trafficEncapMode string
+	interfaceStore   interfacestore.InterfaceStore
+	ofClient         openflow.Client
+	ovsBridgeClient  ovsconfig.OVSBridgeClient
+	nodeRouteQuerier NodeRouteQuerier
 }
 
 func NewControllerMonitor(client clientset.Interface) *controllerMonitor {
 	return &controllerMonitor{client: client}
 }
 
-func NewAgentMonitor(client clientset.Interface, ovsBridge string, nodeName string, nodeSubnet string, interfaceStore interfacestore.InterfaceStore, ofClient openflow.Client, ovsBridgeClient ovsconfig.OVSBridgeClient) *agentMonitor {
-	return &agentMonitor{client: client, ovsBridge: ovsBridge, nodeName: nodeName, nodeSubnet: nodeSubnet, interfaceStore: interfaceStore, ofClient: ofClient, ovsBridgeClient: ovsBridgeClient}
+func NewAgentMonitor(client clientset.Interface, ovsBridge string, nodeName string, nodeSubnets []string, trafficEncapMode string, interfaceStore interfacestore.InterfaceStore, ofClient openflow.Client, ovsBridgeClient ovsconfig.OVSBridgeClient, nodeRouteQuerier NodeRouteQuerier) *agentMonitor {
+	return &agentMonitor{client: client, ovsBridge: ovsBridge, nodeName: nodeName, nodeSubnets: nodeSubnets, trafficEncapMode: trafficEncapMode, interfaceStore: interfaceStore, ofClient: ofClient, ovsBridgeClient: ovsBridgeClient, nodeRouteQuerier: nodeRouteQuerier}
 }
 
 // Run creates AntreaControllerInfo CRD first after controller is running.
@@ -169,8 +171,9 @@ func (monitor *agentMonitor) createAgentCRD() (*v1beta1.AntreaAgentInfo, error)
 		Version:     version.GetFullVersion(),
 		PodRef:      monitor.GetSelfPod(),
 		NodeRef:     monitor.GetSelfNode(),
-		NodeSubnet:  []string{monitor.nodeSubnet},
+		NodeSubnet:  monitor.nodeSubnets,
 		OVSInfo:     v1beta1.OVSInfo{Version: monitor.GetOVSVersion(), BridgeName: monitor.ovsBridge, FlowTable: monitor.GetOVSFlowTable()},
+		NetworkInfo: v1beta1.NetworkInfo{TrafficEncapMode: monitor.trafficEncapMode, PeerNodes: monitor.GetPeerNodes()},
 		LocalPodNum: monitor.GetLocalPodNum(),
 		AgentConditions: []v1beta1.AgentCondition{
 			{
@@ -185,9 +188,10 @@ func (monitor *agentMonitor) createAgentCRD() (*v1beta1.AntreaAgentInfo, error)
 }
 
 func (monitor *agentMonitor) updateAgentCRD(agentCRD *v1beta1.AntreaAgentInfo) (*v1beta1.AntreaAgentInfo, error) {
-	// LocalPodNum and FlowTable can be changed, so reset these fields.
+	// LocalPodNum, FlowTable and PeerNodes can be changed, so reset these fields.
 	agentCRD.LocalPodNum = monitor.GetLocalPodNum()
 	agentCRD.OVSInfo.FlowTable = monitor.GetOVSFlowTable()
+	agentCRD.NetworkInfo = v1beta1.NetworkInfo{TrafficEncapMode: monitor.trafficEncapMode, PeerNodes: monitor.GetPeerNodes()}
 	agentCRD.AgentConditions = []v1beta1.AgentCondition{
 		{
 			Type:              v1beta1.AgentHealthy,
@@ -20,6 +20,8 @@ import (
 
 	"k8s.io/api/core/v1"
 	"k8s.io/klog"
+
+	"github.com/vmware-tanzu/antrea/pkg/apis/clusterinformation/crd/antrea/v1beta1"
 )
 
 const (
@@ -39,6 +41,13 @@ type AgentQuerier interface {
 	Querier
 	GetOVSFlowTable() map[string]int32
 	GetLocalPodNum() int32
+	GetPeerNodes() []v1beta1.PeerNodeInfo
+}
+
+// NodeRouteQuerier provides the state of the routes to the peer Nodes, e.g. whether the Pod traffic to each of them
+// is encapsulated.
+type NodeRouteQuerier interface {
+	GetPeerNodes() []v1beta1.PeerNodeInfo
 }
 
 type ControllerQuerier interface {
@@ -78,6 +87,14 @@ func (monitor *agentMonitor) GetOVSFlowTable() map[string]int32 {
 	return flowTable
 }
 
+// GetPeerNodes gets the peer Nodes to which the Pod traffic is forwarded, and whether it is encapsulated.
+func (monitor *agentMonitor) GetPeerNodes() []v1beta1.PeerNodeInfo {
+	if monitor.nodeRouteQuerier == nil {
+		return nil
+	}
+	return monitor.nodeRouteQuerier.GetPeerNodes()
+}
+
 // GetLocalPodNum gets the number of Pod which the Agent is in charge of.
 func (monitor *agentMonitor) GetLocalPodNum() int32 {
 	return int32(monitor.interfaceStore.GetContainerInterfaceNum())
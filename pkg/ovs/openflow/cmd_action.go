@@ -50,10 +50,55 @@ func (a *commandAction) OutputInPort() FlowBuilder {
 
 type cmdCT struct {
 	ctBase
+	nat     string
 	actions []string
 	builder *commandBuilder
 }
 
+func (a *cmdCT) NAT() CTAction {
+	a.nat = "nat"
+	return a
+}
+
+func (a *cmdCT) DNAT(ipRange *IPRange, portRange *PortRange) CTAction {
+	a.nat = formatNAT("dst", ipRange, portRange)
+	return a
+}
+
+func (a *cmdCT) SNAT(ipRange *IPRange, portRange *PortRange) CTAction {
+	a.nat = formatNAT("src", ipRange, portRange)
+	return a
+}
+
+// formatNAT formats the nat argument of the ct action, e.g. "nat(dst=10.0.0.1:80)", or "nat(dst)" if ipRange is nil.
+func formatNAT(direction string, ipRange *IPRange, portRange *PortRange) string {
+	if ipRange == nil {
+		return fmt.Sprintf("nat(%s)", direction)
+	}
+	return fmt.Sprintf("nat(%s=%s)", direction, formatNATRange(ipRange, portRange))
+}
+
+// formatNATRange formats the address range of a nat argument, e.g. "10.0.0.1-10.0.0.2:80-81" or "[fd00::1]:80".
+func formatNATRange(ipRange *IPRange, portRange *PortRange) string {
+	formatIP := func(ip net.IP) string {
+		if ip.To4() == nil {
+			return "[" + ip.String() + "]"
+		}
+		return ip.String()
+	}
+	repr := formatIP(ipRange.StartIP)
+	if ipRange.EndIP != nil && !ipRange.EndIP.Equal(ipRange.StartIP) {
+		repr += "-" + formatIP(ipRange.EndIP)
+	}
+	if portRange != nil && portRange.StartPort != 0 {
+		repr += fmt.Sprintf(":%d", portRange.StartPort)
+		if portRange.EndPort != 0 && portRange.EndPort != portRange.StartPort {
+			repr += fmt.Sprintf("-%d", portRange.EndPort)
+		}
+	}
+	return repr
+}
+
 func (a *cmdCT) LoadToMark(value uint32) CTAction {
 	action := fmt.Sprintf("load:0x%x->%s[]", value, NxmFieldCtMark)
 	return a.addAction(action)
@@ -99,6 +144,12 @@ func (a *cmdCT) CTDone() FlowBuilder {
 		}
 		repr += fmt.Sprintf("zone=%d", a.ctZone)
 	}
+	if a.nat != "" {
+		if repr != "" {
+			repr += ","
+		}
+		repr += a.nat
+	}
 	if len(a.actions) > 0 {
 		if repr != "" {
 			repr += ","
@@ -123,6 +174,17 @@ func (a *commandAction) CT(commit bool, tableID TableIDType, zone int) CTAction
 	return ct
 }
 
+func (a *commandAction) Learn(table TableIDType, priority uint16, idleTimeout, hardTimeout uint16, cookieID uint64) LearnAction {
+	return &commandLearnAction{
+		builder:     a.builder,
+		table:       table,
+		priority:    priority,
+		idleTimeout: idleTimeout,
+		hardTimeout: hardTimeout,
+		cookieID:    cookieID,
+	}
+}
+
 func (a *commandAction) setField(key, value string) FlowBuilder {
 	a.builder.actions = append(a.builder.actions, fmt.Sprintf("set_field:%s->%s", value, key))
 	return a.builder
@@ -182,6 +244,11 @@ func (a *commandAction) Conjunction(conjID uint32, clauseID uint8, nClause uint8
 	return a.builder
 }
 
+func (a *commandAction) Group(id GroupIDType) FlowBuilder {
+	a.builder.actions = append(a.builder.actions, fmt.Sprintf("group:%d", id))
+	return a.builder
+}
+
 func (a *commandAction) SetDstMAC(addr net.HardwareAddr) FlowBuilder {
 	return a.setField("dl_dst", addr.String())
 }
@@ -207,13 +274,16 @@ func (a *commandAction) SetARPTpa(addr net.IP) FlowBuilder {
 }
 
 func (a *commandAction) SetSrcIP(addr net.IP) FlowBuilder {
-	return a.setField("nw_src", addr.String())
+	return a.setField(ipFieldName("nw_src", addr), addr.String())
 }
 
 func (a *commandAction) SetDstIP(addr net.IP) FlowBuilder {
-	return a.setField("nw_dst", addr.String())
+	return a.setField(ipFieldName("nw_dst", addr), addr.String())
 }
 
 func (a *commandAction) SetTunnelDst(addr net.IP) FlowBuilder {
+	if addr.To4() == nil {
+		return a.setField("tun_ipv6_dst", addr.String())
+	}
 	return a.setField("tun_dst", addr.String())
 }
@@ -16,18 +16,30 @@ package openflow
 
 import (
 	"fmt"
-	"os/exec"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"k8s.io/klog"
 )
 
+// defaultFlowPriority is the priority of the flows added without a priority.
+const defaultFlowPriority = 32768
+
+// cmdConnectionCheckInterval is the interval at which commandBridge checks that the OFSwitch is still running.
+var cmdConnectionCheckInterval = 5 * time.Second
+
 type commandBridge struct {
 	sync.Mutex
 
 	name       string
 	tableCache map[TableIDType]Table
+	// disconnectCh is closed by Disconnect, which stops checking the OFSwitch.
+	disconnectCh chan struct{}
 }
 
 func (b *commandBridge) CreateTable(id, next TableIDType, missAction MissActionType) Table {
@@ -53,30 +65,305 @@ func (b *commandBridge) DeleteTable(id TableIDType) bool {
 	return true
 }
 
+// CreateGroup returns a Group which is installed with ovs-ofctl commands.
+func (b *commandBridge) CreateGroup(id GroupIDType, groupType GroupType) Group {
+	return &commandGroup{
+		bridge:    b.name,
+		id:        id,
+		groupType: groupType,
+	}
+}
+
+// DumpTableStatus returns the status of the tables. The flow counts are read from the OFSwitch.
 func (b *commandBridge) DumpTableStatus() []TableStatus {
-	var r []TableStatus
+	b.Lock()
+	var tables []Table
 	for _, t := range b.tableCache {
-		r = append(r, t.Status())
+		tables = append(tables, t)
+	}
+	b.Unlock()
+
+	return dumpTableStatus(b, tables)
+}
+
+// DeleteFlowsByCookie executes "ovs-ofctl del-flows" with a cookie match.
+func (b *commandBridge) DeleteFlowsByCookie(cookieID, cookieMask uint64) error {
+	cookie := fmt.Sprintf("cookie=0x%x/0x%x", cookieID, cookieMask)
+	if output, err := executor("ovs-ofctl", "del-flows", b.name, "-O"+Version13, cookie).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete flows with %s: %v (%q)", cookie, err, output)
+	}
+	return nil
+}
+
+// DumpFlows executes "ovs-ofctl dump-flows" and parses its output.
+func (b *commandBridge) DumpFlows(table TableIDType, cookieID, cookieMask uint64) ([]*FlowStats, error) {
+	args := []string{"dump-flows", b.name, "-O" + Version13}
+	var filters []string
+	if table != AllTables {
+		filters = append(filters, fmt.Sprintf("table=%d", table))
+	}
+	if cookieMask != 0 {
+		filters = append(filters, fmt.Sprintf("cookie=0x%x/0x%x", cookieID, cookieMask))
+	}
+	if len(filters) > 0 {
+		args = append(args, strings.Join(filters, ","))
+	}
+	output, err := executor("ovs-ofctl", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump flows: %v", err)
+	}
+	var flows []*FlowStats
+	for _, line := range strings.Split(string(output), "\n") {
+		// The first line is the header of the reply, e.g. "OFPST_FLOW reply (OF1.3) (xid=0x2):".
+		if !strings.HasPrefix(strings.TrimSpace(line), "cookie=") {
+			continue
+		}
+		flow, err := parseDumpedFlow(line)
+		if err != nil {
+			return nil, err
+		}
+		flows = append(flows, flow)
+	}
+	return flows, nil
+}
+
+// DumpGroupIDs executes "ovs-ofctl dump-groups" and parses the group IDs of its output.
+func (b *commandBridge) DumpGroupIDs() ([]GroupIDType, error) {
+	output, err := executor("ovs-ofctl", "dump-groups", b.name, "-O"+Version13).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump groups: %v", err)
+	}
+	var groupIDs []GroupIDType
+	for _, line := range strings.Split(string(output), "\n") {
+		// The groups are printed one per line, e.g. " group_id=1,type=select,bucket=weight:100,actions=drop", after
+		// the header of the reply.
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "group_id=") {
+			continue
+		}
+		id := strings.SplitN(strings.TrimPrefix(line, "group_id="), ",", 2)[0]
+		groupID, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group ID in dumped group %q: %v", line, err)
+		}
+		groupIDs = append(groupIDs, GroupIDType(groupID))
 	}
-	return r
+	return groupIDs, nil
+}
+
+// parseDumpedFlow parses a flow printed by "ovs-ofctl dump-flows", e.g.
+// " cookie=0x0, duration=7.264s, table=0, n_packets=5, n_bytes=378, priority=200,in_port=1 actions=resubmit(,10)".
+func parseDumpedFlow(line string) (*FlowStats, error) {
+	line = strings.TrimSpace(line)
+	actionsIndex := strings.Index(line, "actions=")
+	if actionsIndex < 0 {
+		return nil, fmt.Errorf("no actions in dumped flow %q", line)
+	}
+	flow := &FlowStats{
+		Priority: defaultFlowPriority,
+		Actions:  line[actionsIndex+len("actions="):],
+	}
+	tokens := strings.Split(strings.TrimRight(line[:actionsIndex], ", "), ", ")
+	for i, token := range tokens {
+		// Flags such as "reset_counts" and the match are separated by spaces in the last token.
+		words := strings.Fields(token)
+		if len(words) == 0 {
+			continue
+		}
+		kv := strings.SplitN(words[0], "=", 2)
+		var err error
+		switch kv[0] {
+		case "cookie":
+			flow.Cookie, err = strconv.ParseUint(kv[1], 0, 64)
+		case "duration":
+			flow.Duration, err = time.ParseDuration(kv[1])
+		case "table":
+			var table uint64
+			table, err = strconv.ParseUint(kv[1], 10, 8)
+			flow.TableID = TableIDType(table)
+		case "n_packets":
+			flow.PacketCount, err = strconv.ParseUint(kv[1], 10, 64)
+		case "n_bytes":
+			flow.ByteCount, err = strconv.ParseUint(kv[1], 10, 64)
+		default:
+			if i == len(tokens)-1 {
+				flow.Match, flow.Priority, err = parseDumpedMatch(words[len(words)-1])
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid dumped flow %q: %v", line, err)
+		}
+	}
+	return flow, nil
+}
+
+// parseDumpedMatch splits the priority, which ovs-ofctl prints before the match unless it is the default one, from
+// the match.
+func parseDumpedMatch(s string) (string, uint32, error) {
+	if !strings.HasPrefix(s, "priority=") {
+		return s, defaultFlowPriority, nil
+	}
+	parts := strings.SplitN(s, ",", 2)
+	priority, err := strconv.ParseUint(strings.TrimPrefix(parts[0], "priority="), 10, 16)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(parts) == 1 {
+		return "", uint32(priority), nil
+	}
+	return parts[1], uint32(priority), nil
 }
 
 // Connect initiates connection to the OFSwitch. commandBridge executes command "ovs-ofctl show" to check if target
-// switch is connected or not.
-func (b *commandBridge) Connect(maxRetry int) error {
+// switch is connected or not. As there is no persistent connection, the OFSwitch is then checked periodically, see
+// watchOFSwitch.
+func (b *commandBridge) Connect(maxRetry int, connectionCh chan<- struct{}) error {
 	for retry := 0; retry < maxRetry; retry++ {
 		klog.V(2).Infof("Trying to connect to OpenFlow switch...")
-		cmd := exec.Command("ovs-ofctl", "show", b.name)
-		if err := cmd.Run(); err != nil {
+		datapathID, err := b.getDatapathID()
+		if err != nil {
 			time.Sleep(1 * time.Second)
-		} else {
-			return nil
+			continue
+		}
+		instance, err := getOVSVswitchdInstance()
+		if err != nil {
+			klog.Warningf("Failed to identify the ovs-vswitchd instance, its restarts may not be detected: %v", err)
 		}
+		disconnectCh := make(chan struct{})
+		b.Lock()
+		b.disconnectCh = disconnectCh
+		b.Unlock()
+		go b.watchOFSwitch(datapathID, instance, connectionCh, disconnectCh)
+		return nil
 	}
 	return fmt.Errorf("failed to connect to OpenFlow switch after %d tries", maxRetry)
 }
 
-// Disconnect stops connection to the OFSwitch. commandBridge has no handling in Disconnect method.
+// getDatapathID executes command "ovs-ofctl show" and returns the datapath ID of the OFSwitch.
+func (b *commandBridge) getDatapathID() (string, error) {
+	output, err := executor("ovs-ofctl", "show", b.name).Output()
+	if err != nil {
+		return "", err
+	}
+	for _, field := range strings.Fields(string(output)) {
+		if strings.HasPrefix(field, "dpid:") {
+			return strings.TrimPrefix(field, "dpid:"), nil
+		}
+	}
+	return "", fmt.Errorf("no datapath ID in the output of ovs-ofctl show: %s", output)
+}
+
+// getOVSVswitchdInstance returns a value which identifies the running ovs-vswitchd process: its pid and the
+// modification time of its pid file, which ovs-vswitchd rewrites every time it starts. The value changes with every
+// restart, even if the new process gets the same pid.
+func getOVSVswitchdInstance() (string, error) {
+	pidFile := path.Join(ovsRunDir, "ovs-vswitchd.pid")
+	info, err := os.Stat(pidFile)
+	if err != nil {
+		return "", err
+	}
+	pid, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s@%d", strings.TrimSpace(string(pid)), info.ModTime().UnixNano()), nil
+}
+
+// watchOFSwitch checks the OFSwitch every cmdConnectionCheckInterval until disconnectCh is closed. connectionCh is
+// notified when the OFSwitch is reachable again after a failed check, or when ovs-vswitchd restarted since the last
+// check, which is detected with its instance even when the restart completed between two checks.
+func (b *commandBridge) watchOFSwitch(datapathID, instance string, connectionCh chan<- struct{}, disconnectCh <-chan struct{}) {
+	connected := true
+	for {
+		select {
+		case <-time.After(cmdConnectionCheckInterval):
+		case <-disconnectCh:
+			return
+		}
+		newDatapathID, err := b.getDatapathID()
+		if err != nil {
+			if connected {
+				klog.Warningf("Lost connection to OpenFlow switch %s: %v", b.name, err)
+				connected = false
+			}
+			continue
+		}
+		newInstance, err := getOVSVswitchdInstance()
+		if err != nil {
+			klog.V(2).Infof("Failed to identify the ovs-vswitchd instance: %v", err)
+			newInstance = instance
+		}
+		restarted := instance != "" && newInstance != instance
+		if instance == "" {
+			instance = newInstance
+		}
+		if connected && !restarted && newDatapathID == datapathID {
+			continue
+		}
+		if newDatapathID != datapathID {
+			klog.Warningf("Datapath ID of OpenFlow switch %s changed from %s to %s", b.name, datapathID, newDatapathID)
+		}
+		if restarted {
+			klog.Warningf("ovs-vswitchd restarted, instance changed from %s to %s", instance, newInstance)
+		}
+		klog.Infof("Reconnected to OpenFlow switch %s, datapath ID %s", b.name, newDatapathID)
+		connected, datapathID, instance = true, newDatapathID, newInstance
+		notifyConnection(connectionCh)
+	}
+}
+
+// Disconnect stops checking the OFSwitch. commandBridge has no connection to close.
 func (b *commandBridge) Disconnect() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.disconnectCh != nil {
+		close(b.disconnectCh)
+		b.disconnectCh = nil
+	}
+	return nil
+}
+
+// AddFlowsInBundle applies the flow changes with a single "ovs-ofctl bundle" command, which reads the changes from
+// its standard input and commits them in an atomic bundle. Like ofBridge, the modifications and deletions are
+// strict, so that they only apply to the flows with the same priority and matchers.
+func (b *commandBridge) AddFlowsInBundle(addFlows []Flow, modFlows []Flow, delFlows []Flow) error {
+	var lines []string
+	for _, changes := range []struct {
+		flows   []Flow
+		command string
+		format  func(f *commandFlow) string
+	}{
+		{addFlows, "add", func(f *commandFlow) string { return f.format(true) }},
+		{modFlows, "modify_strict", func(f *commandFlow) string { return f.format(true) }},
+		{delFlows, "delete_strict", (*commandFlow).formatStrictMatch},
+	} {
+		for _, flow := range changes.flows {
+			f, ok := flow.(*commandFlow)
+			if !ok {
+				return fmt.Errorf("flow %q was not built for ovs-ofctl", flow.String())
+			}
+			lines = append(lines, fmt.Sprintf("%s %s", changes.command, changes.format(f)))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	cmd := executor("ovs-ofctl", "bundle", b.name, "-O"+Version13, "-")
+	cmd.Stdin = strings.NewReader(strings.Join(lines, "\n") + "\n")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to apply %d flow changes in bundle: %v (%q)", len(lines), err, output)
+	}
+
+	for _, flow := range addFlows {
+		flow.(*commandFlow).updateTableStatus(1)
+	}
+	for _, flow := range modFlows {
+		flow.(*commandFlow).updateTableStatus(0)
+	}
+	for _, flow := range delFlows {
+		flow.(*commandFlow).updateTableStatus(-1)
+	}
 	return nil
 }
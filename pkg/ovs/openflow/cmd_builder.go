@@ -60,20 +60,28 @@ func (b *commandBuilder) MatchInPort(inPort uint32) FlowBuilder {
 	return b.MatchField("in_port", fmt.Sprint(inPort))
 }
 
+// ipFieldName returns the IPv6 variant of the field name for an IPv6 address, e.g. "ipv6_src" for "nw_src".
+func ipFieldName(name string, ip net.IP) string {
+	if ip.To4() != nil {
+		return name
+	}
+	return strings.Replace(name, "nw_", "ipv6_", 1)
+}
+
 func (b *commandBuilder) MatchDstIP(ip net.IP) FlowBuilder {
-	return b.MatchField("nw_dst", ip.String())
+	return b.MatchField(ipFieldName("nw_dst", ip), ip.String())
 }
 
 func (b *commandBuilder) MatchDstIPNet(ipNet net.IPNet) FlowBuilder {
-	return b.MatchField("nw_dst", ipNet.String())
+	return b.MatchField(ipFieldName("nw_dst", ipNet.IP), ipNet.String())
 }
 
 func (b *commandBuilder) MatchSrcIP(ip net.IP) FlowBuilder {
-	return b.MatchField("nw_src", ip.String())
+	return b.MatchField(ipFieldName("nw_src", ip), ip.String())
 }
 
 func (b *commandBuilder) MatchSrcIPNet(ipNet net.IPNet) FlowBuilder {
-	return b.MatchField("nw_src", ipNet.String())
+	return b.MatchField(ipFieldName("nw_src", ipNet.IP), ipNet.String())
 }
 
 func (b *commandBuilder) MatchDstMAC(mac net.HardwareAddr) FlowBuilder {
@@ -104,6 +112,26 @@ func (b *commandBuilder) MatchARPOp(op uint16) FlowBuilder {
 	return b.MatchField("arp_op", fmt.Sprintf("%d", op))
 }
 
+func (b *commandBuilder) MatchICMPv6Type(icmp6Type uint8) FlowBuilder {
+	return b.MatchField("icmpv6_type", fmt.Sprintf("%d", icmp6Type))
+}
+
+func (b *commandBuilder) MatchICMPv6Code(icmp6Code uint8) FlowBuilder {
+	return b.MatchField("icmpv6_code", fmt.Sprintf("%d", icmp6Code))
+}
+
+func (b *commandBuilder) MatchNDTarget(ip net.IP) FlowBuilder {
+	return b.MatchField("nd_target", ip.String())
+}
+
+func (b *commandBuilder) MatchNDSll(mac net.HardwareAddr) FlowBuilder {
+	return b.MatchField("nd_sll", mac.String())
+}
+
+func (b *commandBuilder) MatchNDTll(mac net.HardwareAddr) FlowBuilder {
+	return b.MatchField("nd_tll", mac.String())
+}
+
 func (b *commandBuilder) MatchConjID(value uint32) FlowBuilder {
 	return b.MatchField("conj_id", fmt.Sprintf("%d", value))
 }
@@ -130,8 +158,11 @@ func (b *commandBuilder) MatchProtocol(protocol protocol) FlowBuilder {
 	return b
 }
 
+// Cookie sets the cookie of the flow. The cookie is only set when the flow is added or modified, it is not part of
+// MatchString since ovs-ofctl requires a mask to match on the cookie.
 func (b *commandBuilder) Cookie(cookieID uint64) FlowBuilder {
-	return b.MatchField("cookie", fmt.Sprintf("%d", cookieID))
+	b.cookie = cookieID
+	return b
 }
 
 func (b *commandBuilder) Action() Action {
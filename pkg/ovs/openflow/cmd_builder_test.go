@@ -15,9 +15,14 @@
 package openflow
 
 import (
+	"io/ioutil"
+	"net"
+	"os"
 	"os/exec"
+	"path"
 	"strings"
 	"testing"
+	"time"
 )
 
 func withUnitTestExecutor(f func()) string {
@@ -51,3 +56,367 @@ func TestBasic(t *testing.T) {
 		t.Fatalf("Expected running <%s>, got <%s>", expectedCommand, executedCommand)
 	}
 }
+
+func TestBundle(t *testing.T) {
+	dummyBridge := NewBridge("ut0")
+	dummyTable := dummyBridge.CreateTable(TableIDType(0), TableIDType(10), TableMissActionNext)
+	flow1 := dummyTable.BuildFlow().MatchInPort(1).Action().Resubmit("", TableIDType(10)).Done()
+	flow2 := dummyTable.BuildFlow().MatchInPort(2).Action().Drop().Done()
+
+	stdinFile, err := ioutil.TempFile("", "bundle")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+	stdinFile.Close()
+	defer os.Remove(stdinFile.Name())
+
+	var executedCommand string
+	executor = func(name string, args ...string) *exec.Cmd {
+		executedCommand = name + " " + strings.Join(args, " ")
+		return exec.Command("sh", "-c", `cat > "$0"`, stdinFile.Name())
+	}
+	defer func() { executor = exec.Command }()
+
+	flow3 := dummyTable.BuildFlow().MatchInPort(3).Priority(100).Action().Drop().Done()
+	if err := dummyBridge.AddFlowsInBundle([]Flow{flow1}, []Flow{flow3}, []Flow{flow2}); err != nil {
+		t.Fatalf("Failed to apply flow changes in bundle: %v", err)
+	}
+	expectedCommand := "ovs-ofctl bundle ut0 -OOpenflow13 -"
+	if executedCommand != expectedCommand {
+		t.Errorf("Expected running <%s>, got <%s>", expectedCommand, executedCommand)
+	}
+	input, _ := ioutil.ReadFile(stdinFile.Name())
+	expectedInput := "add table=0,priority=0,in_port=1,actions=resubmit(,10)\n" +
+		"modify_strict table=0,priority=100,in_port=3,actions=drop\n" +
+		"delete_strict table=0,priority=0,in_port=2\n"
+	if string(input) != expectedInput {
+		t.Errorf("Expected bundle <%s>, got <%s>", expectedInput, string(input))
+	}
+}
+
+func TestCookie(t *testing.T) {
+	dummyBridge := NewBridge("ut0")
+	dummyTable := dummyBridge.CreateTable(TableIDType(0), TableIDType(10), TableMissActionNext)
+	flow := dummyTable.BuildFlow().Cookie(0x1000).MatchInPort(1).Action().Drop().Done()
+
+	// The cookie is set when adding the flow, but it is not part of the match of the flow.
+	expected := "table=0,cookie=0x1000,priority=0,in_port=1,actions=drop"
+	if flow.String() != expected {
+		t.Errorf("Expected flow <%s>, got <%s>", expected, flow.String())
+	}
+	expected = "table=0,in_port=1"
+	if flow.MatchString() != expected {
+		t.Errorf("Expected match <%s>, got <%s>", expected, flow.MatchString())
+	}
+	if copied := flow.CopyToBuilder().Action().Drop().Done(); copied.String() != flow.String() {
+		t.Errorf("Expected copied flow <%s>, got <%s>", flow.String(), copied.String())
+	}
+}
+
+func TestDeleteFlowsByCookie(t *testing.T) {
+	dummyBridge := NewBridge("ut0")
+	executedCommand := withUnitTestExecutor(func() {
+		if err := dummyBridge.DeleteFlowsByCookie(0x1000, 0xf000); err != nil {
+			t.Fatalf("Failed to delete flows by cookie: %v", err)
+		}
+	})
+	expectedCommand := "ovs-ofctl del-flows ut0 -OOpenflow13 cookie=0x1000/0xf000"
+	if executedCommand != expectedCommand {
+		t.Errorf("Expected running <%s>, got <%s>", expectedCommand, executedCommand)
+	}
+}
+
+func TestDumpFlows(t *testing.T) {
+	dummyBridge := NewBridge("ut0")
+	dummyBridge.CreateTable(TableIDType(0), TableIDType(10), TableMissActionNext)
+	dummyBridge.CreateTable(TableIDType(10), LastTableID, TableMissActionDrop)
+
+	output := `OFPST_FLOW reply (OF1.3) (xid=0x2):
+ cookie=0x1000, duration=7.264s, table=0, n_packets=5, n_bytes=378, idle_timeout=60, reset_counts priority=200,in_port=1 actions=resubmit(,10)
+ cookie=0x1000, duration=0.5s, table=0, n_packets=0, n_bytes=0, ip actions=drop
+ cookie=0x0, duration=10s, table=10, n_packets=1, n_bytes=42, actions=NORMAL
+`
+	var executedCommand string
+	executor = func(name string, args ...string) *exec.Cmd {
+		executedCommand = name + " " + strings.Join(args, " ")
+		return exec.Command("printf", "%s", output)
+	}
+	defer func() { executor = exec.Command }()
+
+	flows, err := dummyBridge.DumpFlows(TableIDType(0), 0x1000, 0xf000)
+	if err != nil {
+		t.Fatalf("Failed to dump flows: %v", err)
+	}
+	expectedCommand := "ovs-ofctl dump-flows ut0 -OOpenflow13 table=0,cookie=0x1000/0xf000"
+	if executedCommand != expectedCommand {
+		t.Errorf("Expected running <%s>, got <%s>", expectedCommand, executedCommand)
+	}
+	expectedFlows := []FlowStats{
+		{TableID: 0, Priority: 200, Cookie: 0x1000, Match: "in_port=1", Actions: "resubmit(,10)", PacketCount: 5, ByteCount: 378, Duration: 7264 * time.Millisecond},
+		{TableID: 0, Priority: 32768, Cookie: 0x1000, Match: "ip", Actions: "drop", Duration: 500 * time.Millisecond},
+		{TableID: 10, Priority: 32768, Actions: "NORMAL", PacketCount: 1, ByteCount: 42, Duration: 10 * time.Second},
+	}
+	if len(flows) != len(expectedFlows) {
+		t.Fatalf("Expected %d flows, got %d", len(expectedFlows), len(flows))
+	}
+	for i := range flows {
+		if *flows[i] != expectedFlows[i] {
+			t.Errorf("Expected flow %+v, got %+v", expectedFlows[i], *flows[i])
+		}
+	}
+
+	for _, status := range dummyBridge.DumpTableStatus() {
+		if expected := map[uint]uint{0: 2, 10: 1}[status.ID]; status.FlowCount != expected {
+			t.Errorf("Expected %d flows in table %d, got %d", expected, status.ID, status.FlowCount)
+		}
+	}
+}
+
+func TestDumpGroupIDs(t *testing.T) {
+	dummyBridge := NewBridge("ut0")
+	output := `OFPST_GROUP_DESC reply (OF1.3) (xid=0x2):
+ group_id=1,type=select,bucket=weight:100,actions=load:0xa0a0002->NXM_NX_REG3[],resubmit(,42)
+ group_id=20,type=select
+`
+	var executedCommand string
+	executor = func(name string, args ...string) *exec.Cmd {
+		executedCommand = name + " " + strings.Join(args, " ")
+		return exec.Command("printf", "%s", output)
+	}
+	defer func() { executor = exec.Command }()
+
+	groupIDs, err := dummyBridge.DumpGroupIDs()
+	if err != nil {
+		t.Fatalf("Failed to dump groups: %v", err)
+	}
+	if expectedCommand := "ovs-ofctl dump-groups ut0 -OOpenflow13"; executedCommand != expectedCommand {
+		t.Errorf("Expected running <%s>, got <%s>", expectedCommand, executedCommand)
+	}
+	if len(groupIDs) != 2 || groupIDs[0] != 1 || groupIDs[1] != 20 {
+		t.Errorf("Expected group IDs [1 20], got %v", groupIDs)
+	}
+}
+
+func TestGetDatapathID(t *testing.T) {
+	br := NewBridge("ut0").(*commandBridge)
+	output := `OFPT_FEATURES_REPLY (xid=0x2): dpid:00001e8e9fd6ae4c
+n_tables:254, n_buffers:0
+`
+	executor = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("printf", "%s", output)
+	}
+	defer func() { executor = exec.Command }()
+
+	datapathID, err := br.getDatapathID()
+	if err != nil {
+		t.Fatalf("Failed to get datapath ID: %v", err)
+	}
+	if datapathID != "00001e8e9fd6ae4c" {
+		t.Errorf("Expected datapath ID 00001e8e9fd6ae4c, got %s", datapathID)
+	}
+}
+
+func TestWatchOFSwitchRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ovs")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(runDir string) { ovsRunDir = runDir }(ovsRunDir)
+	ovsRunDir = dir
+	defer func(interval time.Duration) { cmdConnectionCheckInterval = interval }(cmdConnectionCheckInterval)
+	cmdConnectionCheckInterval = 10 * time.Millisecond
+	defer func() { executor = exec.Command }()
+	executor = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("printf", "%s", "OFPT_FEATURES_REPLY (xid=0x2): dpid:00001e8e9fd6ae4c\n")
+	}
+
+	pidFile := path.Join(dir, "ovs-vswitchd.pid")
+	startTime := time.Now().Add(-time.Hour)
+	writePidFile := func(modTime time.Time) {
+		if err := ioutil.WriteFile(pidFile, []byte("100\n"), 0644); err != nil {
+			t.Fatalf("Failed to write pid file: %v", err)
+		}
+		if err := os.Chtimes(pidFile, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set modification time of pid file: %v", err)
+		}
+	}
+	writePidFile(startTime)
+
+	br := NewBridge("ut0")
+	connectionCh := make(chan struct{}, 1)
+	if err := br.Connect(1, connectionCh); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer br.Disconnect()
+	select {
+	case <-connectionCh:
+		t.Fatal("Expected no notification while ovs-vswitchd is running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// ovs-vswitchd restarts between two checks with the same pid and datapath ID.
+	writePidFile(startTime.Add(time.Second))
+	select {
+	case <-connectionCh:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a notification after ovs-vswitchd restarted")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	dummyBridge := NewBridge("ut0")
+	group := dummyBridge.CreateGroup(GroupIDType(5), GroupTypeSelect).
+		Bucket().Weight(100).LoadRegRange(3, 0x0a0a0002, Range{0, 31}).Resubmit("", TableIDType(42)).Done().
+		Bucket().Weight(50).SetDstIP(net.ParseIP("10.10.0.3")).Resubmit("", TableIDType(42)).Done()
+
+	executedCommand := withUnitTestExecutor(func() {
+		if err := group.Add(); err != nil {
+			t.Fatalf("Failed to add group: %v", err)
+		}
+	})
+	expectedCommand := "ovs-ofctl add-group ut0 -OOpenflow13 group_id=5,type=select," +
+		"bucket=weight:100,actions=load:0xa0a0002->reg3[0..31],resubmit(,42)," +
+		"bucket=weight:50,actions=set_field:10.10.0.3->nw_dst,resubmit(,42)"
+	if executedCommand != expectedCommand {
+		t.Errorf("Expected running <%s>, got <%s>", expectedCommand, executedCommand)
+	}
+
+	group.ResetBuckets().Bucket().Weight(100).Output(2).Done()
+	executedCommand = withUnitTestExecutor(func() {
+		if err := group.Modify(); err != nil {
+			t.Fatalf("Failed to modify group: %v", err)
+		}
+	})
+	expectedCommand = "ovs-ofctl mod-group ut0 -OOpenflow13 group_id=5,type=select,bucket=weight:100,actions=output:2"
+	if executedCommand != expectedCommand {
+		t.Errorf("Expected running <%s>, got <%s>", expectedCommand, executedCommand)
+	}
+
+	executedCommand = withUnitTestExecutor(func() {
+		if err := group.Delete(); err != nil {
+			t.Fatalf("Failed to delete group: %v", err)
+		}
+	})
+	expectedCommand = "ovs-ofctl del-groups ut0 -OOpenflow13 group_id=5"
+	if executedCommand != expectedCommand {
+		t.Errorf("Expected running <%s>, got <%s>", expectedCommand, executedCommand)
+	}
+
+	dummyTable := dummyBridge.CreateTable(TableIDType(0), TableIDType(10), TableMissActionNext)
+	flow := dummyTable.BuildFlow().MatchProtocol(ProtocolTCP).Action().Group(group.GetID()).Done()
+	expected := "table=0,priority=0,tcp,actions=group:5"
+	if flow.String() != expected {
+		t.Errorf("Expected flow <%s>, got <%s>", expected, flow.String())
+	}
+}
+
+func TestServiceActions(t *testing.T) {
+	dummyBridge := NewBridge("ut0")
+	dummyTable := dummyBridge.CreateTable(TableIDType(41), TableIDType(42), TableMissActionNext)
+
+	flow := dummyTable.BuildFlow().MatchProtocol(ProtocolTCP).Priority(200).
+		Action().Learn(TableIDType(40), 200, 0, 300, 0x1).
+		MatchProtocol(ProtocolTCP).MatchLearnedSrcIP().MatchLearnedDstIP().MatchLearnedDstPort(ProtocolTCP).
+		LoadLearnedRegRange(3, Range{0, 31}).LoadRegRange(4, 1, Range{16, 16}).Done().
+		Action().CT(true, TableIDType(50), 0xfff0).DNAT(&IPRange{StartIP: net.ParseIP("10.10.0.2")}, &PortRange{StartPort: 8080}).CTDone().
+		Done()
+	expected := "table=41,priority=200,tcp,actions=" +
+		"learn(table=40,hard_timeout=300,priority=200,cookie=0x1,dl_type=0x800,nw_proto=0x6," +
+		"NXM_OF_IP_SRC[],NXM_OF_IP_DST[],NXM_OF_TCP_DST[],load:NXM_NX_REG3[0..31]->NXM_NX_REG3[0..31],load:0x1->NXM_NX_REG4[16..16])," +
+		"ct(commit,table=50,zone=65520,nat(dst=10.10.0.2:8080))"
+	if flow.String() != expected {
+		t.Errorf("Expected flow <%s>, got <%s>", expected, flow.String())
+	}
+
+	replyFlow := dummyTable.BuildFlow().MatchProtocol(ProtocolIP).Action().CT(false, TableIDType(31), 0xfff0).NAT().CTDone().Done()
+	if expected := "table=41,priority=0,ip,actions=ct(table=31,zone=65520,nat)"; replyFlow.String() != expected {
+		t.Errorf("Expected flow <%s>, got <%s>", expected, replyFlow.String())
+	}
+}
+
+func TestCTNATActions(t *testing.T) {
+	dummyBridge := NewBridge("ut0")
+	dummyTable := dummyBridge.CreateTable(TableIDType(70), TableIDType(80), TableMissActionNext)
+
+	tests := []struct {
+		name     string
+		ctAction func(ct CTAction) CTAction
+		expected string
+	}{
+		{"nat", func(ct CTAction) CTAction { return ct.NAT() }, "ct(commit,table=80,zone=65521,nat)"},
+		{"snat-ip", func(ct CTAction) CTAction {
+			return ct.SNAT(&IPRange{StartIP: net.ParseIP("10.10.0.1")}, nil)
+		}, "ct(commit,table=80,zone=65521,nat(src=10.10.0.1))"},
+		{"snat-ip-range-port-range", func(ct CTAction) CTAction {
+			return ct.SNAT(&IPRange{StartIP: net.ParseIP("10.10.0.1"), EndIP: net.ParseIP("10.10.0.2")}, &PortRange{StartPort: 1000, EndPort: 2000})
+		}, "ct(commit,table=80,zone=65521,nat(src=10.10.0.1-10.10.0.2:1000-2000))"},
+		{"dnat-port-range", func(ct CTAction) CTAction {
+			return ct.DNAT(&IPRange{StartIP: net.ParseIP("10.10.0.2"), EndIP: net.ParseIP("10.10.0.2")}, &PortRange{StartPort: 8080, EndPort: 8081})
+		}, "ct(commit,table=80,zone=65521,nat(dst=10.10.0.2:8080-8081))"},
+		{"dnat-ipv6", func(ct CTAction) CTAction {
+			return ct.DNAT(&IPRange{StartIP: net.ParseIP("fd00::2")}, &PortRange{StartPort: 8080})
+		}, "ct(commit,table=80,zone=65521,nat(dst=[fd00::2]:8080))"},
+		{"dnat-no-range", func(ct CTAction) CTAction {
+			return ct.DNAT(nil, &PortRange{StartPort: 8080})
+		}, "ct(commit,table=80,zone=65521,nat(dst))"},
+		{"snat-no-range", func(ct CTAction) CTAction { return ct.SNAT(nil, nil) }, "ct(commit,table=80,zone=65521,nat(src))"},
+		{"snat-exec", func(ct CTAction) CTAction {
+			return ct.SNAT(&IPRange{StartIP: net.ParseIP("10.10.0.1")}, nil).LoadToMark(0x40)
+		}, "ct(commit,table=80,zone=65521,nat(src=10.10.0.1),exec(load:0x40->NXM_NX_CT_MARK[]))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flow := tt.ctAction(dummyTable.BuildFlow().MatchProtocol(ProtocolIP).Action().CT(true, TableIDType(80), 0xfff1)).CTDone().Done()
+			if expected := "table=70,priority=0,ip,actions=" + tt.expected; flow.String() != expected {
+				t.Errorf("Expected flow <%s>, got <%s>", expected, flow.String())
+			}
+		})
+	}
+}
+
+func TestIPv6(t *testing.T) {
+	dummyBridge := NewBridge("ut0")
+	dummyTable := dummyBridge.CreateTable(TableIDType(20), TableIDType(30), TableMissActionNext)
+	mac, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	_, podCIDR, _ := net.ParseCIDR("fd00:10:10::/64")
+
+	tests := []struct {
+		name     string
+		flow     Flow
+		expected string
+	}{
+		{
+			"neighbor-advertisement",
+			dummyTable.BuildFlow().MatchProtocol(ProtocolICMPv6).MatchICMPv6Type(136).MatchICMPv6Code(0).
+				MatchNDTarget(net.ParseIP("fd00:10:10::1")).MatchNDTll(mac).Action().Normal().Done(),
+			"table=20,priority=0,icmp6,icmpv6_type=136,icmpv6_code=0,nd_target=fd00:10:10::1,nd_tll=aa:bb:cc:dd:ee:ff,actions=Normal",
+		},
+		{
+			"ipv6-address-rewrite",
+			dummyTable.BuildFlow().MatchProtocol(ProtocolIPv6).MatchSrcIPNet(*podCIDR).MatchDstIP(net.ParseIP("fd00:10:20::5")).
+				Action().SetSrcIP(net.ParseIP("fd00:10:10::1")).Action().SetDstIP(net.ParseIP("fd00:10:20::6")).
+				Action().DecTTL().Done(),
+			"table=20,priority=0,ipv6,ipv6_src=fd00:10:10::/64,ipv6_dst=fd00:10:20::5," +
+				"actions=set_field:fd00:10:10::1->ipv6_src,set_field:fd00:10:20::6->ipv6_dst,dec_ttl",
+		},
+		{
+			"ipv4-address-rewrite",
+			dummyTable.BuildFlow().MatchProtocol(ProtocolIP).MatchSrcIP(net.ParseIP("10.10.0.1")).
+				Action().SetDstIP(net.ParseIP("10.10.0.2")).Done(),
+			"table=20,priority=0,ip,nw_src=10.10.0.1,actions=set_field:10.10.0.2->nw_dst",
+		},
+		{
+			"ipv6-tunnel",
+			dummyTable.BuildFlow().MatchProtocol(ProtocolUDPv6).Action().SetTunnelDst(net.ParseIP("fd00::2")).Done(),
+			"table=20,priority=0,udp6,actions=set_field:fd00::2->tun_ipv6_dst",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.flow.String() != tt.expected {
+				t.Errorf("Expected flow <%s>, got <%s>", tt.expected, tt.flow.String())
+			}
+		})
+	}
+}
@@ -23,6 +23,7 @@ type commandFlow struct {
 	table    Table
 	bridge   string
 	priority uint32
+	cookie   uint64
 	matchers []string
 	actions  []string
 }
@@ -35,6 +36,9 @@ func (f *commandFlow) format(withActions bool) string {
 	repr := fmt.Sprintf("table=%d", f.table.GetID())
 
 	if withActions {
+		if f.cookie != 0 {
+			repr += fmt.Sprintf(",cookie=0x%x", f.cookie)
+		}
 		repr += fmt.Sprintf(",priority=%d", f.priority)
 	}
 	if len(f.matchers) > 0 {
@@ -47,6 +51,16 @@ func (f *commandFlow) format(withActions bool) string {
 	return repr
 }
 
+// formatStrictMatch returns the table, the priority and the matchers of the flow, which are used by strict
+// operations to identify the flow.
+func (f *commandFlow) formatStrictMatch() string {
+	repr := fmt.Sprintf("table=%d,priority=%d", f.table.GetID(), f.priority)
+	if len(f.matchers) > 0 {
+		repr += fmt.Sprintf(",%s", strings.Join(f.matchers, ","))
+	}
+	return repr
+}
+
 func (f *commandFlow) Add() error {
 	if output, err := executor("ovs-ofctl", "add-flow", f.bridge, "-O"+Version13, f.format(true)).CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to add flow %q: %v (%q)", f.format(true), err, output)
@@ -95,6 +109,7 @@ func (f *commandFlow) CopyToBuilder() FlowBuilder {
 		table:    f.table,
 		bridge:   f.bridge,
 		priority: f.priority,
+		cookie:   f.cookie,
 		matchers: f.matchers,
 	}
 	return &commandBuilder{newFlow}
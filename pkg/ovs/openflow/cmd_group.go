@@ -0,0 +1,131 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openflow
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+type commandGroup struct {
+	bridge    string
+	id        GroupIDType
+	groupType GroupType
+	buckets   []string
+}
+
+func (g *commandGroup) GetID() GroupIDType {
+	return g.id
+}
+
+func (g *commandGroup) GetType() GroupType {
+	return g.groupType
+}
+
+func (g *commandGroup) Bucket() BucketBuilder {
+	return &commandBucketBuilder{
+		group:   g,
+		weight:  0,
+		actions: &commandBuilder{},
+	}
+}
+
+func (g *commandGroup) ResetBuckets() Group {
+	g.buckets = nil
+	return g
+}
+
+func (g *commandGroup) format(withBuckets bool) string {
+	repr := fmt.Sprintf("group_id=%d", g.id)
+
+	if withBuckets {
+		repr += fmt.Sprintf(",type=%s", g.groupType)
+		if len(g.buckets) > 0 {
+			repr += fmt.Sprintf(",%s", strings.Join(g.buckets, ","))
+		}
+	}
+
+	return repr
+}
+
+func (g *commandGroup) Add() error {
+	if output, err := executor("ovs-ofctl", "add-group", g.bridge, "-O"+Version13, g.format(true)).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to add group %q: %v (%q)", g.format(true), err, output)
+	}
+	return nil
+}
+
+func (g *commandGroup) Modify() error {
+	if output, err := executor("ovs-ofctl", "mod-group", g.bridge, "-O"+Version13, g.format(true)).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to modify group %q: %v (%q)", g.format(true), err, output)
+	}
+	return nil
+}
+
+func (g *commandGroup) Delete() error {
+	if output, err := executor("ovs-ofctl", "del-groups", g.bridge, "-O"+Version13, g.format(false)).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete group %q: %v (%q)", g.format(false), err, output)
+	}
+	return nil
+}
+
+func (g *commandGroup) String() string {
+	return g.format(true)
+}
+
+// commandBucketBuilder builds a bucket with a commandBuilder, so that the bucket actions are formatted like flow
+// actions.
+type commandBucketBuilder struct {
+	group   *commandGroup
+	weight  uint16
+	actions *commandBuilder
+}
+
+func (b *commandBucketBuilder) Weight(val uint16) BucketBuilder {
+	b.weight = val
+	return b
+}
+
+func (b *commandBucketBuilder) LoadRegRange(regID int, value uint32, to Range) BucketBuilder {
+	b.actions.Action().LoadRegRange(regID, value, to)
+	return b
+}
+
+func (b *commandBucketBuilder) SetDstMAC(addr net.HardwareAddr) BucketBuilder {
+	b.actions.Action().SetDstMAC(addr)
+	return b
+}
+
+func (b *commandBucketBuilder) SetDstIP(addr net.IP) BucketBuilder {
+	b.actions.Action().SetDstIP(addr)
+	return b
+}
+
+func (b *commandBucketBuilder) Output(port int) BucketBuilder {
+	b.actions.Action().Output(port)
+	return b
+}
+
+func (b *commandBucketBuilder) Resubmit(port string, table TableIDType) BucketBuilder {
+	b.actions.Action().Resubmit(port, table)
+	return b
+}
+
+func (b *commandBucketBuilder) Done() Group {
+	bucket := fmt.Sprintf("bucket=weight:%d,actions=%s", b.weight, strings.Join(b.actions.actions, ","))
+	b.group.buckets = append(b.group.buckets, bucket)
+	return b.group
+}
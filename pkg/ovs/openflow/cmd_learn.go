@@ -0,0 +1,82 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commandLearnAction formats the specs of a learn action like ovs-ofctl.
+type commandLearnAction struct {
+	builder     *commandBuilder
+	table       TableIDType
+	priority    uint16
+	idleTimeout uint16
+	hardTimeout uint16
+	cookieID    uint64
+	specs       []string
+}
+
+func (a *commandLearnAction) addSpec(spec string) LearnAction {
+	a.specs = append(a.specs, spec)
+	return a
+}
+
+func (a *commandLearnAction) MatchProtocol(name protocol) LearnAction {
+	a.addSpec(fmt.Sprintf("dl_type=0x%x", ethTypeIPv4))
+	if proto, ok := ipProtocols[name]; ok {
+		a.addSpec(fmt.Sprintf("nw_proto=0x%x", proto))
+	}
+	return a
+}
+
+func (a *commandLearnAction) MatchLearnedSrcIP() LearnAction {
+	return a.addSpec(NxmFieldSrcIPv4 + "[]")
+}
+
+func (a *commandLearnAction) MatchLearnedDstIP() LearnAction {
+	return a.addSpec(NxmFieldDstIPv4 + "[]")
+}
+
+func (a *commandLearnAction) MatchLearnedDstPort(name protocol) LearnAction {
+	return a.addSpec(transportDstFields[name] + "[]")
+}
+
+func (a *commandLearnAction) LoadRegRange(regID int, value uint32, to Range) LearnAction {
+	return a.addSpec(fmt.Sprintf("load:0x%x->%s%d[%d..%d]", value, NxmFieldReg, regID, to[0], to[1]))
+}
+
+func (a *commandLearnAction) LoadLearnedRegRange(regID int, rng Range) LearnAction {
+	field := fmt.Sprintf("%s%d[%d..%d]", NxmFieldReg, regID, rng[0], rng[1])
+	return a.addSpec(fmt.Sprintf("load:%s->%s", field, field))
+}
+
+func (a *commandLearnAction) Done() FlowBuilder {
+	params := []string{fmt.Sprintf("table=%d", a.table)}
+	if a.idleTimeout != 0 {
+		params = append(params, fmt.Sprintf("idle_timeout=%d", a.idleTimeout))
+	}
+	if a.hardTimeout != 0 {
+		params = append(params, fmt.Sprintf("hard_timeout=%d", a.hardTimeout))
+	}
+	params = append(params, fmt.Sprintf("priority=%d", a.priority))
+	if a.cookieID != 0 {
+		params = append(params, fmt.Sprintf("cookie=0x%x", a.cookieID))
+	}
+	params = append(params, a.specs...)
+	a.builder.actions = append(a.builder.actions, fmt.Sprintf("learn(%s)", strings.Join(params, ",")))
+	return a.builder
+}
@@ -18,6 +18,8 @@ import (
 	"net"
 	"os/exec"
 	"time"
+
+	"k8s.io/klog"
 )
 
 var executor = exec.Command
@@ -28,8 +30,13 @@ type TableIDType uint8
 
 const LastTableID TableIDType = 0xff
 
+// AllTables can be passed to Bridge.DumpFlows to dump the flows of all the tables.
+const AllTables TableIDType = 0xff
+
 type MissActionType uint32
 type Range [2]uint32
+type GroupIDType uint32
+type GroupType string
 
 const (
 	Version13 versionType = "Openflow13"
@@ -40,6 +47,23 @@ const (
 	ProtocolUDP  protocol = "udp"
 	ProtocolSCTP protocol = "sctp"
 	ProtocolICMP protocol = "icmp"
+
+	ProtocolIPv6   protocol = "ipv6"
+	ProtocolTCPv6  protocol = "tcp6"
+	ProtocolUDPv6  protocol = "udp6"
+	ProtocolSCTPv6 protocol = "sctp6"
+	ProtocolICMPv6 protocol = "icmp6"
+)
+
+const (
+	// GroupTypeAll applies all the buckets of the group to the packet, e.g. for flooding.
+	GroupTypeAll GroupType = "all"
+	// GroupTypeSelect applies one bucket of the group to the packet, selected by hashing the packet fields so that
+	// the packets of a connection always use the same bucket. The buckets are selected in proportion to their
+	// weights.
+	GroupTypeSelect GroupType = "select"
+	// GroupTypeIndirect applies the single bucket of the group, which lets multiple flows share the same actions.
+	GroupTypeIndirect GroupType = "indirect"
 )
 
 const (
@@ -59,22 +83,54 @@ const (
 	NxmFieldCtMark  = "NXM_NX_CT_MARK"
 	NxmFieldARPOp   = "NXM_OF_ARP_OP"
 	NxmFieldReg     = "NXM_NX_REG"
+	NxmFieldSrcIPv4 = "NXM_OF_IP_SRC"
+	NxmFieldDstIPv4 = "NXM_OF_IP_DST"
+	NxmFieldTCPDst  = "NXM_OF_TCP_DST"
+	NxmFieldUDPDst  = "NXM_OF_UDP_DST"
+	NxmFieldSCTPDst = "OXM_OF_SCTP_DST"
 )
 
-//go:generate mockgen -copyright_file ../../../hack/boilerplate/license_header.go.txt -destination testing/mock_openflow.go -package=testing github.com/vmware-tanzu/antrea/pkg/ovs/openflow Bridge,Table,Flow,Action,FlowBuilder
+//go:generate mockgen -copyright_file ../../../hack/boilerplate/license_header.go.txt -destination testing/mock_openflow.go -package=testing github.com/vmware-tanzu/antrea/pkg/ovs/openflow Bridge,Table,Flow,Action,FlowBuilder,Group,BucketBuilder
 // Bridge defines operations on an openflow bridge.
 type Bridge interface {
 	CreateTable(id, next TableIDType, missAction MissActionType) Table
 	GetName() string
 	DeleteTable(id TableIDType) bool
 	DumpTableStatus() []TableStatus
+	// CreateGroup returns a Group with the ID and type. The Group is not installed in the OFSwitch until its Add method
+	// is called.
+	CreateGroup(id GroupIDType, groupType GroupType) Group
 	// Connect initiates connection to the OFSwitch. It will block until the connection is established.
-	// If Bridge is not connected in maxRetry times, it will return error.
-	Connect(maxRetry int) error
+	// If Bridge is not connected in maxRetry times, it will return error. After that, the Bridge keeps watching the
+	// OFSwitch until Disconnect is called, and notifies connectionCh, if not nil, when the OFSwitch was disconnected
+	// and is connected again, or when its datapath ID changed: in both cases all the flows may have been lost and
+	// must be installed again. Notifications are dropped while connectionCh is full, so a buffered channel should
+	// be used.
+	Connect(maxRetry int, connectionCh chan<- struct{}) error
 	// Disconnect stops connection to the OFSwitch.
 	Disconnect() error
+	// AddFlowsInBundle applies the flow additions, modifications and deletions in one atomic bundle: either all the
+	// changes are applied, or none of them is and an error is returned.
+	AddFlowsInBundle(addFlows []Flow, modFlows []Flow, delFlows []Flow) error
+	// DumpFlows returns the flows installed in the OFSwitch in the table, or in all the tables if table is AllTables,
+	// whose cookie matches cookieID in the bits set in cookieMask. A zero cookieMask matches all the flows.
+	DumpFlows(table TableIDType, cookieID, cookieMask uint64) ([]*FlowStats, error)
+	// DeleteFlowsByCookie deletes the flows of all the tables whose cookie matches cookieID in the bits set in
+	// cookieMask.
+	DeleteFlowsByCookie(cookieID, cookieMask uint64) error
+	// DumpGroupIDs returns the IDs of the groups installed in the OFSwitch.
+	DumpGroupIDs() ([]GroupIDType, error)
 }
 
+// Backends which can be used to program the OFSwitch.
+const (
+	// BackendNative talks OpenFlow 1.3 with the Nicira extensions directly to the management socket of the bridge.
+	BackendNative = "native"
+	// BackendOFCtl executes an ovs-ofctl command for each flow operation.
+	BackendOFCtl = "ovs-ofctl"
+)
+
+// NewBridge returns a Bridge which programs flows with ovs-ofctl commands.
 func NewBridge(name string) Bridge {
 	return &commandBridge{
 		name:       name,
@@ -82,6 +138,15 @@ func NewBridge(name string) Bridge {
 	}
 }
 
+// NewOFBridge returns a Bridge which programs flows over an OpenFlow connection to the management socket of the
+// bridge.
+func NewOFBridge(name string) Bridge {
+	return &ofBridge{
+		name:       name,
+		tableCache: map[TableIDType]Table{},
+	}
+}
+
 // TableStatus represents the status of a specific flow table. The status is useful for debugging.
 type TableStatus struct {
 	ID         uint      `json:"id"`
@@ -89,6 +154,52 @@ type TableStatus struct {
 	UpdateTime time.Time `json:"updateTime"`
 }
 
+// FlowStats is a flow installed in the OFSwitch, together with its statistics. Match and Actions use the ovs-ofctl
+// syntax.
+type FlowStats struct {
+	TableID     TableIDType
+	Priority    uint32
+	Cookie      uint64
+	Match       string
+	Actions     string
+	PacketCount uint64
+	ByteCount   uint64
+	Duration    time.Duration
+}
+
+// notifyConnection notifies connectionCh that the OFSwitch was reconnected, unless a notification is already pending.
+func notifyConnection(connectionCh chan<- struct{}) {
+	if connectionCh == nil {
+		return
+	}
+	select {
+	case connectionCh <- struct{}{}:
+	default:
+	}
+}
+
+// dumpTableStatus returns the status of the tables, with the number of flows read from the OFSwitch. The locally
+// tracked number of flows is used if the flows cannot be dumped.
+func dumpTableStatus(b Bridge, tables []Table) []TableStatus {
+	var r []TableStatus
+	for _, t := range tables {
+		r = append(r, t.Status())
+	}
+	flows, err := b.DumpFlows(AllTables, 0, 0)
+	if err != nil {
+		klog.Warningf("Failed to dump flows of bridge %s, using the locally tracked flow counts: %v", b.GetName(), err)
+		return r
+	}
+	flowCounts := map[TableIDType]uint{}
+	for _, flow := range flows {
+		flowCounts[flow.TableID]++
+	}
+	for i := range r {
+		r[i].FlowCount = flowCounts[TableIDType(r[i].ID)]
+	}
+	return r
+}
+
 type Table interface {
 	GetID() TableIDType
 	BuildFlow() FlowBuilder
@@ -131,12 +242,20 @@ type Action interface {
 	SetARPTha(addr net.HardwareAddr) FlowBuilder
 	SetARPSpa(addr net.IP) FlowBuilder
 	SetARPTpa(addr net.IP) FlowBuilder
+	// SetSrcIP and SetDstIP set the nw_src and nw_dst fields for an IPv4 address, and the ipv6_src and ipv6_dst
+	// fields for an IPv6 address.
 	SetSrcIP(addr net.IP) FlowBuilder
 	SetDstIP(addr net.IP) FlowBuilder
+	// SetTunnelDst sets the tun_dst field for an IPv4 address, and the tun_ipv6_dst field for an IPv6 address.
 	SetTunnelDst(addr net.IP) FlowBuilder
+	// DecTTL decrements the TTL of IPv4 packets and the hop limit of IPv6 packets.
 	DecTTL() FlowBuilder
 	Normal() FlowBuilder
 	Conjunction(conjID uint32, clauseID uint8, nClause uint8) FlowBuilder
+	Group(id GroupIDType) FlowBuilder
+	// Learn adds a learn action, which installs a flow in the table every time it is executed. The learned flow is
+	// built with the returned LearnAction.
+	Learn(table TableIDType, priority uint16, idleTimeout, hardTimeout uint16, cookieID uint64) LearnAction
 }
 
 type FlowBuilder interface {
@@ -145,6 +264,8 @@ type FlowBuilder interface {
 	MatchReg(regID int, data uint32) FlowBuilder
 	MatchRegRange(regID int, data uint32, rng Range) FlowBuilder
 	MatchInPort(inPort uint32) FlowBuilder
+	// The IP matches use the nw_src and nw_dst fields for IPv4 addresses, and the ipv6_src and ipv6_dst fields for
+	// IPv6 addresses.
 	MatchDstIP(ip net.IP) FlowBuilder
 	MatchDstIPNet(ipNet net.IPNet) FlowBuilder
 	MatchSrcIP(ip net.IP) FlowBuilder
@@ -156,6 +277,14 @@ type FlowBuilder interface {
 	MatchARPSpa(ip net.IP) FlowBuilder
 	MatchARPTpa(ip net.IP) FlowBuilder
 	MatchARPOp(op uint16) FlowBuilder
+	MatchICMPv6Type(icmp6Type uint8) FlowBuilder
+	MatchICMPv6Code(icmp6Code uint8) FlowBuilder
+	// MatchNDTarget matches the target address of the IPv6 Neighbor Solicitation and Advertisement messages.
+	MatchNDTarget(ip net.IP) FlowBuilder
+	// MatchNDSll and MatchNDTll match the source and target link-layer address options of the IPv6 Neighbor
+	// Solicitation and Advertisement messages.
+	MatchNDSll(mac net.HardwareAddr) FlowBuilder
+	MatchNDTll(mac net.HardwareAddr) FlowBuilder
 	MatchCTState(value string) FlowBuilder
 	MatchCTMark(value string) FlowBuilder
 	MatchConjID(value uint32) FlowBuilder
@@ -167,10 +296,83 @@ type FlowBuilder interface {
 	Done() Flow
 }
 
+// Group is an OpenFlow group, whose buckets can be used by flows with the group action.
+type Group interface {
+	GetID() GroupIDType
+	GetType() GroupType
+	// Bucket returns a BucketBuilder which appends a bucket to the Group when its Done method is called.
+	Bucket() BucketBuilder
+	// ResetBuckets removes all the buckets of the Group, so that Modify can replace them with new ones.
+	ResetBuckets() Group
+	// Add installs the Group in the OFSwitch.
+	Add() error
+	// Modify replaces the buckets of the Group installed in the OFSwitch.
+	Modify() error
+	// Delete removes the Group from the OFSwitch. The flows using the Group are removed too.
+	Delete() error
+	String() string
+}
+
+// BucketBuilder builds a bucket of a Group. The actions of the bucket are applied in the order in which they are
+// added.
+type BucketBuilder interface {
+	// Weight sets the weight of the bucket, which is only used by the select groups.
+	Weight(val uint16) BucketBuilder
+	LoadRegRange(regID int, value uint32, to Range) BucketBuilder
+	SetDstMAC(addr net.HardwareAddr) BucketBuilder
+	SetDstIP(addr net.IP) BucketBuilder
+	Output(port int) BucketBuilder
+	Resubmit(port string, table TableIDType) BucketBuilder
+	Done() Group
+}
+
+// LearnAction builds the flow learned by a learn action. The matches and the actions of the learned flow are either
+// immediate values or copied from the packet which executes the learn action.
+type LearnAction interface {
+	// MatchProtocol makes the learned flow match the protocol.
+	MatchProtocol(name protocol) LearnAction
+	// MatchLearnedSrcIP makes the learned flow match the IPv4 source address of the packet.
+	MatchLearnedSrcIP() LearnAction
+	// MatchLearnedDstIP makes the learned flow match the IPv4 destination address of the packet.
+	MatchLearnedDstIP() LearnAction
+	// MatchLearnedDstPort makes the learned flow match the transport destination port of the packet, which must be
+	// a TCP, UDP or SCTP packet.
+	MatchLearnedDstPort(name protocol) LearnAction
+	// LoadRegRange makes the learned flow load the value to the range of the register.
+	LoadRegRange(regID int, value uint32, to Range) LearnAction
+	// LoadLearnedRegRange makes the learned flow load the range of the register of the packet to the same range of
+	// the register.
+	LoadLearnedRegRange(regID int, rng Range) LearnAction
+	Done() FlowBuilder
+}
+
+// IPRange is a range of IP addresses, from StartIP to EndIP included. EndIP is optional.
+type IPRange struct {
+	StartIP net.IP
+	EndIP   net.IP
+}
+
+// PortRange is a range of transport ports, from StartPort to EndPort included. EndPort is optional.
+type PortRange struct {
+	StartPort uint16
+	EndPort   uint16
+}
+
 type CTAction interface {
 	LoadToMark(value uint32) CTAction
 	LoadToLabelRange(value uint64, rng *Range) CTAction
 	MoveToLabel(fromName string, fromRng, labelRng *Range) CTAction
+	// NAT applies the address translation of the connection, e.g. to un-DNAT the reply traffic of a connection
+	// committed with DNAT.
+	NAT() CTAction
+	// DNAT translates the destination address of the connection to an address of ipRange and, if portRange is not
+	// nil, to a port of portRange. If ipRange is nil, the connection is committed with nat(dst) and no range, and
+	// portRange is ignored. It is only effective when the connection is committed.
+	DNAT(ipRange *IPRange, portRange *PortRange) CTAction
+	// SNAT translates the source address of the connection to an address of ipRange and, if portRange is not nil,
+	// to a port of portRange. If ipRange is nil, the connection is committed with nat(src) and no range, and
+	// portRange is ignored. It is only effective when the connection is committed.
+	SNAT(ipRange *IPRange, portRange *PortRange) CTAction
 	CTDone() FlowBuilder
 }
 
@@ -0,0 +1,323 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openflow
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/openflow/ofproto"
+)
+
+type ofFlowActions struct {
+	builder *ofFlowBuilder
+}
+
+func (a *ofFlowActions) add(repr string, action ofproto.Action) FlowBuilder {
+	a.builder.actions = append(a.builder.actions, ofFlowAction{repr: repr, action: action})
+	return a.builder
+}
+
+// field looks up a field by name, the error is recorded in the builder.
+func (a *ofFlowActions) field(name string) *ofproto.Field {
+	f, err := ofproto.FieldByName(name)
+	if err != nil {
+		a.builder.setError(err)
+	}
+	return f
+}
+
+func (a *ofFlowActions) Drop() FlowBuilder {
+	return a.add("drop", nil)
+}
+
+func (a *ofFlowActions) Output(port int) FlowBuilder {
+	return a.add(fmt.Sprintf("output:%d", port), &ofproto.ActionOutput{Port: uint32(port)})
+}
+
+func (a *ofFlowActions) OutputFieldRange(name string, rng Range) FlowBuilder {
+	repr := fmt.Sprintf("output:%s[%d..%d]", name, rng[0], rng[1])
+	f := a.field(name)
+	if f == nil {
+		return a.add(repr, nil)
+	}
+	return a.add(repr, &ofproto.NXActionOutputReg{Src: f, Ofs: int(rng[0]), NBits: int(rng[1] - rng[0] + 1)})
+}
+
+func (a *ofFlowActions) OutputRegRange(regID int, rng Range) FlowBuilder {
+	return a.OutputFieldRange(fmt.Sprintf("%s%d", NxmFieldReg, regID), rng)
+}
+
+func (a *ofFlowActions) OutputInPort() FlowBuilder {
+	return a.add("in_port", &ofproto.ActionOutput{Port: ofproto.PortInPort})
+}
+
+type ofCTAction struct {
+	ctBase
+	nat     *ofproto.NXActionNAT
+	actions []ofFlowAction
+	builder *ofFlowBuilder
+}
+
+func (a *ofCTAction) addAction(repr string, action ofproto.Action) CTAction {
+	a.actions = append(a.actions, ofFlowAction{repr: repr, action: action})
+	return a
+}
+
+func (a *ofCTAction) LoadToMark(value uint32) CTAction {
+	repr := fmt.Sprintf("load:0x%x->%s[]", value, NxmFieldCtMark)
+	return a.addAction(repr, &ofproto.NXActionRegLoad{Dst: ofproto.FieldCtMark, NBits: 32, Value: uint64(value)})
+}
+
+func (a *ofCTAction) LoadToLabelRange(value uint64, labelRange *Range) CTAction {
+	repr := fmt.Sprintf("load:0x%x->%s[%d..%d]", value, NxmFieldCtLabel, labelRange[0], labelRange[1])
+	return a.addAction(repr, &ofproto.NXActionRegLoad{
+		Dst:   ofproto.FieldCtLabel,
+		Ofs:   int(labelRange[0]),
+		NBits: int(labelRange[1] - labelRange[0] + 1),
+		Value: value,
+	})
+}
+
+func (a *ofCTAction) MoveToLabel(fromName string, fromRng, labelRange *Range) CTAction {
+	repr := fmt.Sprintf("move:%s[%d..%d]->%s[%d..%d]", fromName, fromRng[0], fromRng[1], NxmFieldCtLabel, labelRange[0], labelRange[1])
+	from, err := ofproto.FieldByName(fromName)
+	if err != nil {
+		a.builder.setError(err)
+		return a.addAction(repr, nil)
+	}
+	return a.addAction(repr, &ofproto.NXActionRegMove{
+		Src:    from,
+		Dst:    ofproto.FieldCtLabel,
+		SrcOfs: int(fromRng[0]),
+		DstOfs: int(labelRange[0]),
+		NBits:  int(fromRng[1] - fromRng[0] + 1),
+	})
+}
+
+func (a *ofCTAction) NAT() CTAction {
+	a.nat = &ofproto.NXActionNAT{}
+	return a
+}
+
+func (a *ofCTAction) DNAT(ipRange *IPRange, portRange *PortRange) CTAction {
+	return a.setNAT(ofproto.NXNATFlagDst, ipRange, portRange)
+}
+
+func (a *ofCTAction) SNAT(ipRange *IPRange, portRange *PortRange) CTAction {
+	return a.setNAT(ofproto.NXNATFlagSrc, ipRange, portRange)
+}
+
+// setNAT sets the NAT action of the ct action, flag is either ofproto.NXNATFlagSrc or ofproto.NXNATFlagDst.
+func (a *ofCTAction) setNAT(flag uint16, ipRange *IPRange, portRange *PortRange) CTAction {
+	a.nat = &ofproto.NXActionNAT{Flags: flag}
+	// Like ovs-ofctl, a port range is only accepted along with an address range.
+	if ipRange == nil {
+		return a
+	}
+	a.nat.IPMin, a.nat.IPMax = ipRange.StartIP, ipRange.EndIP
+	if portRange != nil {
+		a.nat.PortMin, a.nat.PortMax = portRange.StartPort, portRange.EndPort
+	}
+	return a
+}
+
+func (a *ofCTAction) CTDone() FlowBuilder {
+	var params []string
+	ct := &ofproto.NXActionConntrack{
+		Zone:        a.ctZone,
+		RecircTable: ofproto.NXConntrackRecircNone,
+	}
+	if a.commit {
+		params = append(params, "commit")
+		ct.Flags |= ofproto.NXConntrackFlagCommit
+	}
+	if a.ctTable > 0 {
+		params = append(params, fmt.Sprintf("table=%d", a.ctTable))
+		ct.RecircTable = a.ctTable
+	}
+	if a.ctZone > 0 {
+		params = append(params, fmt.Sprintf("zone=%d", a.ctZone))
+	}
+	// The NAT action must be the first action of the ct action.
+	if a.nat != nil {
+		params = append(params, a.nat.String())
+		ct.Actions = append(ct.Actions, a.nat)
+	}
+	if len(a.actions) > 0 {
+		var reprs []string
+		for _, action := range a.actions {
+			reprs = append(reprs, action.repr)
+			if action.action != nil {
+				ct.Actions = append(ct.Actions, action.action)
+			}
+		}
+		params = append(params, fmt.Sprintf("exec(%s)", strings.Join(reprs, ",")))
+	}
+	a.builder.actions = append(a.builder.actions, ofFlowAction{repr: "ct(" + strings.Join(params, ",") + ")", action: ct})
+	return a.builder
+}
+
+func (a *ofFlowActions) CT(commit bool, tableID TableIDType, zone int) CTAction {
+	base := ctBase{
+		commit:  commit,
+		force:   false,
+		ctTable: uint8(tableID),
+		ctZone:  uint16(zone),
+	}
+	return &ofCTAction{
+		ctBase:  base,
+		builder: a.builder,
+	}
+}
+
+func (a *ofFlowActions) Learn(table TableIDType, priority uint16, idleTimeout, hardTimeout uint16, cookieID uint64) LearnAction {
+	return &ofLearnAction{
+		builder: a.builder,
+		learn: &ofproto.NXActionLearn{
+			IdleTimeout: idleTimeout,
+			HardTimeout: hardTimeout,
+			Priority:    priority,
+			Cookie:      cookieID,
+			Table:       uint8(table),
+		},
+	}
+}
+
+func (a *ofFlowActions) setField(field *ofproto.Field, name string, repr string, value []byte) FlowBuilder {
+	return a.add(fmt.Sprintf("set_field:%s->%s", repr, name), &ofproto.ActionSetField{Field: ofproto.NewMatchField(field, value)})
+}
+
+func (a *ofFlowActions) setIPField(v4Field, v6Field *ofproto.Field, addr net.IP) FlowBuilder {
+	field, ip, err := addressField(v4Field, v6Field, addr)
+	if err != nil {
+		a.builder.setError(err)
+		return a.add(fmt.Sprintf("set_field:%s->%s", addr.String(), field.Name), nil)
+	}
+	return a.setField(field, field.Name, addr.String(), ip)
+}
+
+func (a *ofFlowActions) loadRange(name string, f *ofproto.Field, value uint64, to Range) FlowBuilder {
+	repr := fmt.Sprintf("load:0x%x->%s[%d..%d]", value, name, to[0], to[1])
+	if f == nil {
+		return a.add(repr, nil)
+	}
+	return a.add(repr, &ofproto.NXActionRegLoad{Dst: f, Ofs: int(to[0]), NBits: int(to[1] - to[0] + 1), Value: value})
+}
+
+func (a *ofFlowActions) LoadARPOperation(value uint16) FlowBuilder {
+	repr := fmt.Sprintf("load:0x%x->%s[]", value, NxmFieldARPOp)
+	return a.add(repr, &ofproto.NXActionRegLoad{Dst: ofproto.FieldARPOp, NBits: 16, Value: uint64(value)})
+}
+
+func (a *ofFlowActions) LoadRange(name string, addr uint32, to Range) FlowBuilder {
+	return a.loadRange(name, a.field(name), uint64(addr), to)
+}
+
+func (a *ofFlowActions) LoadRegRange(regID int, value uint32, to Range) FlowBuilder {
+	return a.loadRange(fmt.Sprintf("reg%d", regID), ofproto.FieldReg(regID), uint64(value), to)
+}
+
+func (a *ofFlowActions) Move(from, to string) FlowBuilder {
+	repr := fmt.Sprintf("move:%s[]->%s[]", from, to)
+	src, dst := a.field(from), a.field(to)
+	if src == nil || dst == nil {
+		return a.add(repr, nil)
+	}
+	return a.add(repr, &ofproto.NXActionRegMove{Src: src, Dst: dst, NBits: src.Bits()})
+}
+
+func (a *ofFlowActions) MoveRange(fromName, toName string, from, to Range) FlowBuilder {
+	repr := fmt.Sprintf("move:%s[%d..%d]->%s[%d..%d]", fromName, from[0], from[1], toName, to[0], to[1])
+	src, dst := a.field(fromName), a.field(toName)
+	if src == nil || dst == nil {
+		return a.add(repr, nil)
+	}
+	return a.add(repr, &ofproto.NXActionRegMove{
+		Src:    src,
+		Dst:    dst,
+		SrcOfs: int(from[0]),
+		DstOfs: int(to[0]),
+		NBits:  int(from[1] - from[0] + 1),
+	})
+}
+
+// Resubmit resubmits the packet to the table. An empty port or "in_port" keeps the in_port of the packet.
+func (a *ofFlowActions) Resubmit(port string, table TableIDType) FlowBuilder {
+	inPort := ofproto.NXResubmitInPort
+	if port != "" && port != "in_port" {
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			a.builder.setError(fmt.Errorf("invalid resubmit port %q: %v", port, err))
+		}
+		inPort = uint16(p)
+	}
+	return a.add(fmt.Sprintf("resubmit(%s,%d)", port, table), &ofproto.NXActionResubmitTable{InPort: inPort, Table: uint8(table)})
+}
+
+func (a *ofFlowActions) DecTTL() FlowBuilder {
+	return a.add("dec_ttl", &ofproto.ActionDecNwTTL{})
+}
+
+func (a *ofFlowActions) Normal() FlowBuilder {
+	return a.add("Normal", &ofproto.ActionOutput{Port: ofproto.PortNormal})
+}
+
+func (a *ofFlowActions) Conjunction(conjID uint32, clauseID uint8, nClause uint8) FlowBuilder {
+	repr := fmt.Sprintf("conjunction(%d,%d/%d)", conjID, clauseID, nClause)
+	return a.add(repr, &ofproto.NXActionConjunction{ID: conjID, Clause: clauseID, NClauses: nClause})
+}
+
+func (a *ofFlowActions) Group(id GroupIDType) FlowBuilder {
+	return a.add(fmt.Sprintf("group:%d", id), &ofproto.ActionGroup{GroupID: uint32(id)})
+}
+
+func (a *ofFlowActions) SetDstMAC(addr net.HardwareAddr) FlowBuilder {
+	return a.setField(ofproto.FieldEthDst, "dl_dst", addr.String(), addr)
+}
+
+func (a *ofFlowActions) SetSrcMAC(addr net.HardwareAddr) FlowBuilder {
+	return a.setField(ofproto.FieldEthSrc, "dl_src", addr.String(), addr)
+}
+
+func (a *ofFlowActions) SetARPSha(addr net.HardwareAddr) FlowBuilder {
+	return a.setField(ofproto.FieldARPSha, "arp_sha", addr.String(), addr)
+}
+
+func (a *ofFlowActions) SetARPTha(addr net.HardwareAddr) FlowBuilder {
+	return a.setField(ofproto.FieldARPTha, "arp_tha", addr.String(), addr)
+}
+
+func (a *ofFlowActions) SetARPSpa(addr net.IP) FlowBuilder {
+	return a.setIPField(ofproto.FieldARPSpa, nil, addr)
+}
+
+func (a *ofFlowActions) SetARPTpa(addr net.IP) FlowBuilder {
+	return a.setIPField(ofproto.FieldARPTpa, nil, addr)
+}
+
+func (a *ofFlowActions) SetSrcIP(addr net.IP) FlowBuilder {
+	return a.setIPField(ofproto.FieldIPv4Src, ofproto.FieldIPv6Src, addr)
+}
+
+func (a *ofFlowActions) SetDstIP(addr net.IP) FlowBuilder {
+	return a.setIPField(ofproto.FieldIPv4Dst, ofproto.FieldIPv6Dst, addr)
+}
+
+func (a *ofFlowActions) SetTunnelDst(addr net.IP) FlowBuilder {
+	return a.setIPField(ofproto.FieldTunIPv4Dst, ofproto.FieldTunIPv6Dst, addr)
+}
@@ -0,0 +1,350 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openflow
+
+import (
+	"fmt"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/openflow/ofproto"
+)
+
+const (
+	ofConnectTimeout = 5 * time.Second
+	// ofReconnectInterval is the interval between two attempts to reconnect to the OFSwitch after the connection
+	// was lost.
+	ofReconnectInterval = 1 * time.Second
+)
+
+// ovsRunDir is the directory in which ovs-vswitchd creates the management socket of each bridge.
+var ovsRunDir = "/var/run/openvswitch"
+
+// ofBridge programs the OFSwitch over an OpenFlow 1.3 connection to the management socket of the bridge,
+// "<ovsRunDir>/<bridge>.mgmt", which ovs-vswitchd creates for every bridge.
+type ofBridge struct {
+	sync.Mutex
+
+	name       string
+	tableCache map[TableIDType]Table
+	conn       *ofproto.Conn
+	// disconnectCh is closed by Disconnect, which stops the reconnection to the OFSwitch.
+	disconnectCh chan struct{}
+	// lastBundleID is the ID of the last bundle opened on the connection.
+	lastBundleID uint32
+}
+
+func (b *ofBridge) CreateTable(id, next TableIDType, missAction MissActionType) Table {
+	t := &ofTable{
+		bridge:     b,
+		id:         id,
+		next:       next,
+		missAction: missAction,
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	b.tableCache[t.id] = t
+	return t
+}
+
+func (b *ofBridge) GetName() string {
+	return b.name
+}
+
+func (b *ofBridge) DeleteTable(id TableIDType) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	delete(b.tableCache, id)
+	return true
+}
+
+// CreateGroup returns a Group which is installed with GroupMod messages.
+func (b *ofBridge) CreateGroup(id GroupIDType, groupType GroupType) Group {
+	g := &ofGroup{
+		bridge:    b,
+		id:        id,
+		groupType: groupType,
+	}
+	if _, ok := ofGroupTypes[groupType]; !ok {
+		g.setError(fmt.Errorf("unsupported group type %q", groupType))
+	}
+	return g
+}
+
+// DumpTableStatus returns the status of the tables. The flow counts are read from the OFSwitch.
+func (b *ofBridge) DumpTableStatus() []TableStatus {
+	b.Lock()
+	var tables []Table
+	for _, t := range b.tableCache {
+		tables = append(tables, t)
+	}
+	b.Unlock()
+
+	return dumpTableStatus(b, tables)
+}
+
+// DeleteFlowsByCookie sends a non-strict FlowMod deleting the flows of all the tables whose cookie matches.
+func (b *ofBridge) DeleteFlowsByCookie(cookieID, cookieMask uint64) error {
+	fm := ofproto.NewFlowMod(ofproto.FlowDelete)
+	fm.TableID = ofproto.TableAll
+	fm.Cookie = cookieID
+	fm.CookieMask = cookieMask
+	if err := b.transact(fm); err != nil {
+		return fmt.Errorf("failed to delete flows with cookie 0x%x/0x%x: %v", cookieID, cookieMask, err)
+	}
+	return nil
+}
+
+// DumpFlows sends a flow statistics request to the OFSwitch and returns the flows of the reply.
+func (b *ofBridge) DumpFlows(table TableIDType, cookieID, cookieMask uint64) ([]*FlowStats, error) {
+	conn, err := b.getConn()
+	if err != nil {
+		return nil, err
+	}
+	req := ofproto.NewFlowStatsRequest(uint8(table))
+	req.Cookie = cookieID
+	req.CookieMask = cookieMask
+	replies, err := conn.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump flows: %v", err)
+	}
+	var flows []*FlowStats
+	for _, reply := range replies {
+		stats, err := ofproto.ParseFlowStatsReply(reply)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse flow stats: %v", err)
+		}
+		for _, s := range stats {
+			flows = append(flows, &FlowStats{
+				TableID:     TableIDType(s.TableID),
+				Priority:    uint32(s.Priority),
+				Cookie:      s.Cookie,
+				Match:       s.Match.String(),
+				Actions:     ofproto.FormatInstructions(s.Instructions),
+				PacketCount: s.PacketCount,
+				ByteCount:   s.ByteCount,
+				Duration:    time.Duration(s.DurationSec)*time.Second + time.Duration(s.DurationNsec),
+			})
+		}
+	}
+	return flows, nil
+}
+
+// DumpGroupIDs sends a group description request to the OFSwitch and returns the group IDs of the reply.
+func (b *ofBridge) DumpGroupIDs() ([]GroupIDType, error) {
+	conn, err := b.getConn()
+	if err != nil {
+		return nil, err
+	}
+	replies, err := conn.Request(&ofproto.GroupDescRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump groups: %v", err)
+	}
+	var groupIDs []GroupIDType
+	for _, reply := range replies {
+		descs, err := ofproto.ParseGroupDescReply(reply)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse group descs: %v", err)
+		}
+		for _, d := range descs {
+			groupIDs = append(groupIDs, GroupIDType(d.GroupID))
+		}
+	}
+	return groupIDs, nil
+}
+
+func (b *ofBridge) mgmtSocketPath() string {
+	return path.Join(ovsRunDir, b.name+".mgmt")
+}
+
+// Connect initiates the OpenFlow connection to the management socket of the bridge. It retries every second until
+// the handshake with the OFSwitch succeeds. The connection is then maintained until Disconnect is called: when it is
+// lost, e.g. because ovs-vswitchd restarted, the ofBridge reconnects and notifies connectionCh.
+func (b *ofBridge) Connect(maxRetry int, connectionCh chan<- struct{}) error {
+	socketPath := b.mgmtSocketPath()
+	for retry := 0; retry < maxRetry; retry++ {
+		klog.V(2).Infof("Trying to connect to OpenFlow switch %s...", socketPath)
+		conn, err := ofproto.Dial("unix", socketPath, ofConnectTimeout)
+		if err != nil {
+			klog.V(2).Infof("Failed to connect to OpenFlow switch %s: %v", socketPath, err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
+		disconnectCh := make(chan struct{})
+		b.Lock()
+		b.conn = conn
+		b.disconnectCh = disconnectCh
+		b.Unlock()
+		klog.Infof("Connected to OpenFlow switch %s, datapath ID %016x", socketPath, conn.DatapathID())
+		go b.maintainConnection(conn, connectionCh, disconnectCh)
+		return nil
+	}
+	return fmt.Errorf("failed to connect to OpenFlow switch after %d tries", maxRetry)
+}
+
+// maintainConnection reconnects to the OFSwitch every time conn is lost, until disconnectCh is closed. A new
+// connection means that the OFSwitch may have lost its flows, so connectionCh is notified once it is established.
+func (b *ofBridge) maintainConnection(conn *ofproto.Conn, connectionCh chan<- struct{}, disconnectCh <-chan struct{}) {
+	socketPath := b.mgmtSocketPath()
+	for {
+		select {
+		case <-conn.Done():
+		case <-disconnectCh:
+			return
+		}
+		klog.Warningf("Lost OpenFlow connection to switch %s, reconnecting", socketPath)
+		b.Lock()
+		if b.conn == conn {
+			b.conn = nil
+		}
+		b.Unlock()
+
+		var newConn *ofproto.Conn
+		for newConn == nil {
+			select {
+			case <-time.After(ofReconnectInterval):
+			case <-disconnectCh:
+				return
+			}
+			var err error
+			if newConn, err = ofproto.Dial("unix", socketPath, ofConnectTimeout); err != nil {
+				klog.V(2).Infof("Failed to reconnect to OpenFlow switch %s: %v", socketPath, err)
+			}
+		}
+		if newConn.DatapathID() != conn.DatapathID() {
+			klog.Warningf("Datapath ID of OpenFlow switch %s changed from %016x to %016x", socketPath, conn.DatapathID(), newConn.DatapathID())
+		}
+		b.Lock()
+		select {
+		case <-disconnectCh:
+			b.Unlock()
+			newConn.Close()
+			return
+		default:
+		}
+		b.conn = newConn
+		for _, t := range b.tableCache {
+			t.(*ofTable).resetStatus()
+		}
+		b.Unlock()
+		klog.Infof("Reconnected to OpenFlow switch %s, datapath ID %016x", socketPath, newConn.DatapathID())
+		notifyConnection(connectionCh)
+		conn = newConn
+	}
+}
+
+// Disconnect closes the OpenFlow connection and stops reconnecting to the OFSwitch.
+func (b *ofBridge) Disconnect() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.disconnectCh != nil {
+		close(b.disconnectCh)
+		b.disconnectCh = nil
+	}
+	if b.conn == nil {
+		return nil
+	}
+	err := b.conn.Close()
+	b.conn = nil
+	return err
+}
+
+// AddFlowsInBundle applies the flow changes in an atomic and ordered bundle: the flows are added, modified and
+// deleted in this order, and the OFSwitch either applies all of the changes or none of them.
+func (b *ofBridge) AddFlowsInBundle(addFlows []Flow, modFlows []Flow, delFlows []Flow) error {
+	var msgs []ofproto.Message
+	for _, changes := range []struct {
+		flows   []Flow
+		command uint8
+	}{
+		{addFlows, ofproto.FlowAdd},
+		{modFlows, ofproto.FlowModifyStrict},
+		{delFlows, ofproto.FlowDeleteStrict},
+	} {
+		for _, flow := range changes.flows {
+			f, ok := flow.(*ofFlow)
+			if !ok {
+				return fmt.Errorf("flow %q was not built for an OpenFlow connection", flow.String())
+			}
+			if f.err != nil {
+				return fmt.Errorf("invalid flow %q: %v", f.format(true), f.err)
+			}
+			msgs = append(msgs, f.flowMod(changes.command))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	conn, err := b.getConn()
+	if err != nil {
+		return err
+	}
+	bundleID := atomic.AddUint32(&b.lastBundleID, 1)
+	flags := ofproto.BundleAtomic | ofproto.BundleOrdered
+	if _, err := conn.Request(&ofproto.BundleControl{BundleID: bundleID, Type: ofproto.BundleOpenRequest, Flags: flags}); err != nil {
+		return fmt.Errorf("failed to open bundle %d: %v", bundleID, err)
+	}
+	bundleMsgs := make([]ofproto.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		bundleMsgs = append(bundleMsgs, &ofproto.BundleAdd{BundleID: bundleID, Flags: flags, Message: msg})
+	}
+	if err := conn.Transact(bundleMsgs...); err != nil {
+		if _, discardErr := conn.Request(&ofproto.BundleControl{BundleID: bundleID, Type: ofproto.BundleDiscardRequest, Flags: flags}); discardErr != nil {
+			klog.Errorf("Failed to discard bundle %d: %v", bundleID, discardErr)
+		}
+		return fmt.Errorf("failed to add flows to bundle %d: %v", bundleID, err)
+	}
+	if _, err := conn.Request(&ofproto.BundleControl{BundleID: bundleID, Type: ofproto.BundleCommitRequest, Flags: flags}); err != nil {
+		return fmt.Errorf("failed to commit bundle %d: %v", bundleID, err)
+	}
+
+	for _, flow := range addFlows {
+		flow.(*ofFlow).updateTableStatus(1)
+	}
+	for _, flow := range modFlows {
+		flow.(*ofFlow).updateTableStatus(0)
+	}
+	for _, flow := range delFlows {
+		flow.(*ofFlow).updateTableStatus(-1)
+	}
+	return nil
+}
+
+func (b *ofBridge) getConn() (*ofproto.Conn, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.conn == nil {
+		return nil, fmt.Errorf("not connected to OpenFlow switch %s", b.name)
+	}
+	return b.conn, nil
+}
+
+// transact sends the messages to the OFSwitch and waits until they have all been processed.
+func (b *ofBridge) transact(msgs ...ofproto.Message) error {
+	conn, err := b.getConn()
+	if err != nil {
+		return err
+	}
+	return conn.Transact(msgs...)
+}
@@ -0,0 +1,652 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openflow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/openflow/ofproto"
+	oftest "github.com/vmware-tanzu/antrea/pkg/ovs/openflow/ofproto/testing"
+)
+
+// withFakeSwitch runs f with an ofBridge connected to a FakeSwitch.
+func withFakeSwitch(t *testing.T, f func(br *ofBridge, sw *oftest.FakeSwitch)) {
+	dir, err := ioutil.TempDir("", "ofbridge")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(runDir string) { ovsRunDir = runDir }(ovsRunDir)
+	ovsRunDir = dir
+
+	sw := oftest.NewFakeSwitch(1)
+	l, err := sw.Listen(path.Join(dir, "ut0.mgmt"))
+	if err != nil {
+		t.Fatalf("Failed to start fake switch: %v", err)
+	}
+	defer l.Close()
+
+	br := NewOFBridge("ut0").(*ofBridge)
+	if err := br.Connect(1, nil); err != nil {
+		t.Fatalf("Failed to connect to fake switch: %v", err)
+	}
+	defer br.Disconnect()
+	f(br, sw)
+}
+
+func TestOFBridgeFlowOperations(t *testing.T) {
+	withFakeSwitch(t, func(br *ofBridge, sw *oftest.FakeSwitch) {
+		table := br.CreateTable(TableIDType(0), TableIDType(10), TableMissActionNext)
+		flow := table.BuildFlow().Priority(200).
+			MatchInPort(1).
+			MatchProtocol(ProtocolIP).
+			Action().Resubmit("", TableIDType(10)).
+			Done()
+
+		expected := "table=0,priority=200,in_port=1,ip,actions=resubmit(,10)"
+		if flow.String() != expected {
+			t.Errorf("Expected flow <%s>, got <%s>", expected, flow.String())
+		}
+		if err := flow.Add(); err != nil {
+			t.Fatalf("Failed to add flow: %v", err)
+		}
+		if err := flow.Delete(); err != nil {
+			t.Fatalf("Failed to delete flow: %v", err)
+		}
+
+		msgs := sw.Messages(ofproto.TypeFlowMod)
+		if len(msgs) != 2 {
+			t.Fatalf("Expected 2 FlowMod messages, got %d", len(msgs))
+		}
+		for i, command := range []uint8{ofproto.FlowAdd, ofproto.FlowDeleteStrict} {
+			body := msgs[i].Body
+			if body[17] != command {
+				t.Errorf("Expected FlowMod command %d, got %d", command, body[17])
+			}
+			if priority := binary.BigEndian.Uint16(body[22:24]); priority != 200 {
+				t.Errorf("Expected priority 200, got %d", priority)
+			}
+			// The eth_type prerequisite must be the first OXM field, even if it was added after in_port.
+			if header := binary.BigEndian.Uint32(body[44:48]); header != ofproto.FieldEthType.Header(false) {
+				t.Errorf("Expected eth_type as first match field, got header 0x%x", header)
+			}
+		}
+		if status := table.Status(); status.FlowCount != 0 {
+			t.Errorf("Expected 0 flow in table status, got %d", status.FlowCount)
+		}
+	})
+}
+
+func TestOFBridgeDumpGroupIDs(t *testing.T) {
+	withFakeSwitch(t, func(br *ofBridge, sw *oftest.FakeSwitch) {
+		sw.Handler = func(header ofproto.Header, body []byte) []ofproto.Message {
+			descs := []*ofproto.GroupDesc{
+				{Type: ofproto.GroupTypeSelect, GroupID: 1, Buckets: []*ofproto.Bucket{ofproto.NewBucket(100)}},
+				{Type: ofproto.GroupTypeSelect, GroupID: 20},
+			}
+			return []ofproto.Message{ofproto.NewRawMessage(ofproto.TypeMultipartReply, ofproto.MarshalGroupDescs(descs))}
+		}
+		groupIDs, err := br.DumpGroupIDs()
+		if err != nil {
+			t.Fatalf("Failed to dump groups: %v", err)
+		}
+		if expected := []GroupIDType{1, 20}; fmt.Sprint(groupIDs) != fmt.Sprint(expected) {
+			t.Errorf("Expected group IDs %v, got %v", expected, groupIDs)
+		}
+		req := sw.Messages(ofproto.TypeMultipartRequest)[0].Body
+		if multipartType := binary.BigEndian.Uint16(req[0:2]); multipartType != ofproto.MultipartTypeGroupDesc {
+			t.Errorf("Expected group desc request, got multipart type %d", multipartType)
+		}
+	})
+}
+
+func TestOFBridgeFlowError(t *testing.T) {
+	withFakeSwitch(t, func(br *ofBridge, sw *oftest.FakeSwitch) {
+		sw.Handler = func(header ofproto.Header, body []byte) []ofproto.Message {
+			// OFPET_BAD_ACTION, OFPBAC_BAD_OUT_PORT
+			return []ofproto.Message{ofproto.NewRawMessage(ofproto.TypeError, []byte{0, 2, 0, 4})}
+		}
+		table := br.CreateTable(TableIDType(0), TableIDType(10), TableMissActionNext)
+		flow := table.BuildFlow().Action().Output(1000).Done()
+		if err := flow.Add(); err == nil {
+			t.Errorf("Expected error when the switch rejects the flow")
+		}
+		if status := table.Status(); status.FlowCount != 0 {
+			t.Errorf("Expected 0 flow in table status, got %d", status.FlowCount)
+		}
+	})
+}
+
+func TestOFFlowBuilder(t *testing.T) {
+	br := NewOFBridge("ut0")
+	table := br.CreateTable(TableIDType(31), TableIDType(40), TableMissActionNext)
+	mac, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+
+	flow := table.BuildFlow().MatchProtocol(ProtocolIP).Priority(200).
+		MatchRegRange(0, 1, Range{0, 15}).
+		MatchCTMark("0x20").
+		MatchCTState("-new+trk").
+		MatchDstMAC(mac).
+		MatchProtocol(ProtocolTCP).
+		Action().CT(true, TableIDType(40), 0xfff0).LoadToMark(0x20).MoveToLabel(NxmFieldSrcMAC, &Range{0, 47}, &Range{0, 47}).CTDone().
+		Done()
+	expected := "table=31,priority=200,tcp,reg0[0..15]=0x1,ct_mark=0x20,ct_state=-new+trk,dl_dst=aa:bb:cc:dd:ee:ff," +
+		"actions=ct(commit,table=40,zone=65520,exec(load:0x20->NXM_NX_CT_MARK[],move:NXM_OF_ETH_SRC[0..47]->NXM_NX_CT_LABEL[0..47]))"
+	if flow.String() != expected {
+		t.Errorf("Expected flow <%s>, got <%s>", expected, flow.String())
+	}
+
+	fm := flow.(*ofFlow).flowMod(ofproto.FlowAdd)
+	var fields []*ofproto.Field
+	for _, f := range fm.Match.Fields {
+		fields = append(fields, f.Field)
+	}
+	expectedFields := []*ofproto.Field{ofproto.FieldEthType, ofproto.FieldIPProto, ofproto.FieldReg(0),
+		ofproto.FieldCtMark, ofproto.FieldCtState, ofproto.FieldEthDst}
+	if len(fields) != len(expectedFields) {
+		t.Fatalf("Expected %d match fields, got %d", len(expectedFields), len(fields))
+	}
+	for i := range fields {
+		if fields[i] != expectedFields[i] {
+			t.Errorf("Expected match field %s at index %d, got %s", expectedFields[i].Name, i, fields[i].Name)
+		}
+	}
+	ctState := fm.Match.Fields[4]
+	if binary.BigEndian.Uint32(ctState.Value) != 0x20 || binary.BigEndian.Uint32(ctState.Mask) != 0x21 {
+		t.Errorf("Unexpected ct_state value 0x%x mask 0x%x", ctState.Value, ctState.Mask)
+	}
+
+	badFlow := table.BuildFlow().MatchCTState("+foo").Action().Move("NXM_NX_UNKNOWN", NxmFieldDstMAC).Done()
+	if err := badFlow.Add(); err == nil {
+		t.Errorf("Expected error when adding a flow with invalid matches")
+	}
+}
+
+// bundleMessages returns the bundle control types and the FlowMod commands added to bundles, in the order in which
+// they were received by the switch.
+func bundleMessages(t *testing.T, sw *oftest.FakeSwitch) (controls []uint16, commands []uint8) {
+	for _, msg := range sw.Messages(ofproto.TypeExperimenter) {
+		if ctrl, err := ofproto.ParseBundleControl(msg.Body); err == nil {
+			controls = append(controls, ctrl.Type)
+			continue
+		}
+		inner, _ := ofproto.ParseHeader(msg.Body[16:])
+		if inner.Type != ofproto.TypeFlowMod || inner.Xid != msg.Header.Xid {
+			t.Errorf("Unexpected message type %d xid %d in bundle", inner.Type, inner.Xid)
+		}
+		commands = append(commands, msg.Body[16+ofproto.HeaderLen+17])
+	}
+	return controls, commands
+}
+
+func TestOFBridgeAddFlowsInBundle(t *testing.T) {
+	withFakeSwitch(t, func(br *ofBridge, sw *oftest.FakeSwitch) {
+		table := br.CreateTable(TableIDType(0), TableIDType(10), TableMissActionNext)
+		var flows []Flow
+		for i := uint32(1); i <= 3; i++ {
+			flows = append(flows, table.BuildFlow().Priority(200).MatchInPort(i).Action().Resubmit("", TableIDType(10)).Done())
+		}
+		if err := br.AddFlowsInBundle(flows, nil, nil); err != nil {
+			t.Fatalf("Failed to add flows in bundle: %v", err)
+		}
+		if err := br.AddFlowsInBundle(flows[2:], flows[1:2], flows[:1]); err != nil {
+			t.Fatalf("Failed to apply flow changes in bundle: %v", err)
+		}
+
+		controls, commands := bundleMessages(t, sw)
+		expectedControls := []uint16{ofproto.BundleOpenRequest, ofproto.BundleCommitRequest, ofproto.BundleOpenRequest, ofproto.BundleCommitRequest}
+		if fmt.Sprint(controls) != fmt.Sprint(expectedControls) {
+			t.Errorf("Expected bundle control messages %v, got %v", expectedControls, controls)
+		}
+		expectedCommands := []uint8{ofproto.FlowAdd, ofproto.FlowAdd, ofproto.FlowAdd, ofproto.FlowAdd, ofproto.FlowModifyStrict, ofproto.FlowDeleteStrict}
+		if fmt.Sprint(commands) != fmt.Sprint(expectedCommands) {
+			t.Errorf("Expected FlowMod commands %v, got %v", expectedCommands, commands)
+		}
+		if len(sw.Messages(ofproto.TypeFlowMod)) != 0 {
+			t.Errorf("Expected all FlowMod messages to be sent in bundles")
+		}
+		if status := table.Status(); status.FlowCount != 3 {
+			t.Errorf("Expected 3 flows in table status, got %d", status.FlowCount)
+		}
+	})
+}
+
+func TestOFBridgeAddFlowsInBundleError(t *testing.T) {
+	withFakeSwitch(t, func(br *ofBridge, sw *oftest.FakeSwitch) {
+		var added int
+		sw.Handler = func(header ofproto.Header, body []byte) []ofproto.Message {
+			if _, err := ofproto.ParseBundleControl(body); err == nil {
+				return nil
+			}
+			if added++; added == 2 {
+				// OFPET_FLOW_MOD_FAILED, OFPFMFC_TABLE_FULL
+				return []ofproto.Message{ofproto.NewRawMessage(ofproto.TypeError, []byte{0, 5, 0, 1})}
+			}
+			return nil
+		}
+		table := br.CreateTable(TableIDType(0), TableIDType(10), TableMissActionNext)
+		flows := []Flow{
+			table.BuildFlow().MatchInPort(1).Action().Drop().Done(),
+			table.BuildFlow().MatchInPort(2).Action().Drop().Done(),
+		}
+		if err := br.AddFlowsInBundle(flows, nil, nil); err == nil {
+			t.Fatalf("Expected error when the switch rejects a flow of the bundle")
+		}
+		controls, _ := bundleMessages(t, sw)
+		expectedControls := []uint16{ofproto.BundleOpenRequest, ofproto.BundleDiscardRequest}
+		if fmt.Sprint(controls) != fmt.Sprint(expectedControls) {
+			t.Errorf("Expected bundle control messages %v, got %v", expectedControls, controls)
+		}
+		if status := table.Status(); status.FlowCount != 0 {
+			t.Errorf("Expected 0 flow in table status, got %d", status.FlowCount)
+		}
+
+		badFlow := table.BuildFlow().MatchCTState("+foo").Action().Drop().Done()
+		if err := br.AddFlowsInBundle([]Flow{badFlow}, nil, nil); err == nil {
+			t.Errorf("Expected error when adding an invalid flow in bundle")
+		}
+	})
+}
+
+func TestOFBridgeDumpFlows(t *testing.T) {
+	withFakeSwitch(t, func(br *ofBridge, sw *oftest.FakeSwitch) {
+		stats := []*ofproto.FlowStats{
+			{
+				TableID:      0,
+				DurationSec:  7,
+				DurationNsec: 264000000,
+				Priority:     200,
+				Cookie:       0x1000,
+				PacketCount:  5,
+				ByteCount:    378,
+				Match:        ofproto.Match{Fields: []ofproto.MatchField{ofproto.NewUintMatchField(ofproto.FieldInPort, 1)}},
+				Instructions: []ofproto.Instruction{&ofproto.InstructionApplyActions{Actions: []ofproto.Action{
+					&ofproto.NXActionResubmitTable{InPort: ofproto.NXResubmitInPort, Table: 10},
+				}}},
+			},
+			{TableID: 0, Cookie: 0x1000},
+			{TableID: 10, Cookie: 0x2000},
+		}
+		sw.Handler = func(header ofproto.Header, body []byte) []ofproto.Message {
+			// Send the flow stats in two replies, the first one has the OFPMPF_REPLY_MORE flag.
+			first := ofproto.MarshalFlowStats(stats[:2])
+			binary.BigEndian.PutUint16(first[2:4], 1)
+			return []ofproto.Message{
+				ofproto.NewRawMessage(ofproto.TypeMultipartReply, first),
+				ofproto.NewRawMessage(ofproto.TypeMultipartReply, ofproto.MarshalFlowStats(stats[2:])),
+			}
+		}
+		br.CreateTable(TableIDType(0), TableIDType(10), TableMissActionNext)
+		br.CreateTable(TableIDType(10), TableIDType(20), TableMissActionNext)
+		br.CreateTable(TableIDType(20), LastTableID, TableMissActionDrop)
+
+		flows, err := br.DumpFlows(TableIDType(0), 0x1000, 0xf000)
+		if err != nil {
+			t.Fatalf("Failed to dump flows: %v", err)
+		}
+		if len(flows) != 3 {
+			t.Fatalf("Expected 3 flows, got %d", len(flows))
+		}
+		expected := FlowStats{
+			TableID:     0,
+			Priority:    200,
+			Cookie:      0x1000,
+			Match:       "in_port=1",
+			Actions:     "resubmit(,10)",
+			PacketCount: 5,
+			ByteCount:   378,
+			Duration:    7264 * time.Millisecond,
+		}
+		if *flows[0] != expected {
+			t.Errorf("Expected flow %+v, got %+v", expected, *flows[0])
+		}
+		req := sw.Messages(ofproto.TypeMultipartRequest)[0].Body
+		if table, cookie, mask := req[8], binary.BigEndian.Uint64(req[24:32]), binary.BigEndian.Uint64(req[32:40]); table != 0 || cookie != 0x1000 || mask != 0xf000 {
+			t.Errorf("Unexpected flow stats request: table %d, cookie 0x%x/0x%x", table, cookie, mask)
+		}
+
+		flowCounts := map[uint]uint{}
+		for _, status := range br.DumpTableStatus() {
+			flowCounts[status.ID] = status.FlowCount
+		}
+		expectedCounts := map[uint]uint{0: 2, 10: 1, 20: 0}
+		if fmt.Sprint(flowCounts) != fmt.Sprint(expectedCounts) {
+			t.Errorf("Expected flow counts %v, got %v", expectedCounts, flowCounts)
+		}
+	})
+}
+
+func TestOFBridgeDeleteFlowsByCookie(t *testing.T) {
+	withFakeSwitch(t, func(br *ofBridge, sw *oftest.FakeSwitch) {
+		if err := br.DeleteFlowsByCookie(0x1000, 0xf000); err != nil {
+			t.Fatalf("Failed to delete flows by cookie: %v", err)
+		}
+		msgs := sw.Messages(ofproto.TypeFlowMod)
+		if len(msgs) != 1 {
+			t.Fatalf("Expected 1 FlowMod message, got %d", len(msgs))
+		}
+		body := msgs[0].Body
+		if cookie, mask := binary.BigEndian.Uint64(body[0:8]), binary.BigEndian.Uint64(body[8:16]); cookie != 0x1000 || mask != 0xf000 {
+			t.Errorf("Expected cookie 0x1000/0xf000, got 0x%x/0x%x", cookie, mask)
+		}
+		if table, command := body[16], body[17]; table != ofproto.TableAll || command != ofproto.FlowDelete {
+			t.Errorf("Expected non-strict deletion in all tables, got command %d in table %d", command, table)
+		}
+	})
+}
+
+func TestOFBridgeReconnect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ofbridge")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(runDir string) { ovsRunDir = runDir }(ovsRunDir)
+	ovsRunDir = dir
+
+	sw := oftest.NewFakeSwitch(1)
+	l, err := sw.Listen(path.Join(dir, "ut0.mgmt"))
+	if err != nil {
+		t.Fatalf("Failed to start fake switch: %v", err)
+	}
+	defer l.Close()
+
+	br := NewOFBridge("ut0").(*ofBridge)
+	connectionCh := make(chan struct{}, 1)
+	if err := br.Connect(1, connectionCh); err != nil {
+		t.Fatalf("Failed to connect to fake switch: %v", err)
+	}
+	defer br.Disconnect()
+	table := br.CreateTable(TableIDType(0), TableIDType(10), TableMissActionNext)
+	if err := table.BuildFlow().Action().Drop().Done().Add(); err != nil {
+		t.Fatalf("Failed to add flow: %v", err)
+	}
+
+	// Simulate a restart of ovs-vswitchd.
+	sw.CloseConnections()
+	select {
+	case <-connectionCh:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected a notification after reconnecting to the switch")
+	}
+	if status := table.Status(); status.FlowCount != 0 {
+		t.Errorf("Expected 0 flow in table status after reconnection, got %d", status.FlowCount)
+	}
+	if err := table.BuildFlow().Action().Drop().Done().Add(); err != nil {
+		t.Errorf("Failed to add flow after reconnection: %v", err)
+	}
+
+	// No reconnection after Disconnect.
+	br.Disconnect()
+	sw.CloseConnections()
+	select {
+	case <-connectionCh:
+		t.Errorf("Expected no notification after Disconnect")
+	case <-time.After(2 * ofReconnectInterval):
+	}
+}
+
+func TestOFBridgeGroup(t *testing.T) {
+	withFakeSwitch(t, func(br *ofBridge, sw *oftest.FakeSwitch) {
+		group := br.CreateGroup(GroupIDType(5), GroupTypeSelect).
+			Bucket().Weight(100).LoadRegRange(3, 0x0a0a0002, Range{0, 31}).Resubmit("", TableIDType(42)).Done().
+			Bucket().Weight(50).SetDstIP(net.ParseIP("10.10.0.3")).Resubmit("", TableIDType(42)).Done()
+		expected := "group_id=5,type=select," +
+			"bucket=weight:100,actions=load:0xa0a0002->NXM_NX_REG3[],resubmit(,42)," +
+			"bucket=weight:50,actions=set_field:10.10.0.3->nw_dst,resubmit(,42)"
+		if group.String() != expected {
+			t.Errorf("Expected group <%s>, got <%s>", expected, group.String())
+		}
+		if err := group.Add(); err != nil {
+			t.Fatalf("Failed to add group: %v", err)
+		}
+		if err := group.ResetBuckets().Bucket().Weight(100).Output(2).Done().Modify(); err != nil {
+			t.Fatalf("Failed to modify group: %v", err)
+		}
+		if err := group.Delete(); err != nil {
+			t.Fatalf("Failed to delete group: %v", err)
+		}
+
+		msgs := sw.Messages(ofproto.TypeGroupMod)
+		if len(msgs) != 3 {
+			t.Fatalf("Expected 3 GroupMod messages, got %d", len(msgs))
+		}
+		expectedMods := []string{
+			expected,
+			"group_id=5,type=select,bucket=weight:100,actions=output:2",
+			"group_id=5,type=select",
+		}
+		for i, command := range []uint16{ofproto.GroupAdd, ofproto.GroupModify, ofproto.GroupDelete} {
+			gm, err := ofproto.ParseGroupMod(msgs[i].Body)
+			if err != nil {
+				t.Fatalf("Failed to parse GroupMod message: %v", err)
+			}
+			if gm.Command != command {
+				t.Errorf("Expected GroupMod command %d, got %d", command, gm.Command)
+			}
+			if gm.String() != expectedMods[i] {
+				t.Errorf("Expected GroupMod <%s>, got <%s>", expectedMods[i], gm.String())
+			}
+		}
+
+		table := br.CreateTable(TableIDType(0), TableIDType(10), TableMissActionNext)
+		flow := table.BuildFlow().MatchProtocol(ProtocolTCP).Action().Group(group.GetID()).Done()
+		if expected := "table=0,priority=0,tcp,actions=group:5"; flow.String() != expected {
+			t.Errorf("Expected flow <%s>, got <%s>", expected, flow.String())
+		}
+		actions := flow.(*ofFlow).flowMod(ofproto.FlowAdd).Instructions[0].(*ofproto.InstructionApplyActions).Actions
+		if len(actions) != 1 || actions[0].String() != "group:5" {
+			t.Errorf("Expected group action, got <%s>", ofproto.FormatActions(actions))
+		}
+
+		badGroup := br.CreateGroup(GroupIDType(6), GroupTypeSelect).Bucket().SetDstIP(net.IP{10, 10, 0}).Done()
+		if err := badGroup.Add(); err == nil {
+			t.Errorf("Expected error when adding a group with invalid bucket actions")
+		}
+		if err := br.CreateGroup(GroupIDType(7), GroupType("ff")).Add(); err == nil {
+			t.Errorf("Expected error when adding a group of unsupported type")
+		}
+	})
+}
+
+func TestOFFlowBuilderServiceActions(t *testing.T) {
+	br := NewOFBridge("ut0")
+	table := br.CreateTable(TableIDType(41), TableIDType(42), TableMissActionNext)
+
+	flow := table.BuildFlow().MatchProtocol(ProtocolTCP).Priority(200).
+		Action().Learn(TableIDType(40), 200, 0, 300, 0x1).
+		MatchProtocol(ProtocolTCP).MatchLearnedSrcIP().MatchLearnedDstIP().MatchLearnedDstPort(ProtocolTCP).
+		LoadLearnedRegRange(3, Range{0, 31}).LoadRegRange(4, 1, Range{16, 16}).Done().
+		Action().CT(true, TableIDType(50), 0xfff0).DNAT(&IPRange{StartIP: net.ParseIP("10.10.0.2")}, &PortRange{StartPort: 8080}).CTDone().
+		Done()
+	expectedActions := "learn(table=40,hard_timeout=300,priority=200,cookie=0x1,dl_type=0x800,nw_proto=0x6," +
+		"NXM_OF_IP_SRC[],NXM_OF_IP_DST[],NXM_OF_TCP_DST[],load:NXM_NX_REG3[]->NXM_NX_REG3[],load:0x1->NXM_NX_REG4[16..16])," +
+		"ct(commit,table=50,zone=65520,nat(dst=10.10.0.2:8080))"
+	if expected := "table=41,priority=200,tcp,actions=" + expectedActions; flow.String() != expected {
+		t.Errorf("Expected flow <%s>, got <%s>", expected, flow.String())
+	}
+	// The actions are decoded back to the same representation.
+	fm := flow.(*ofFlow).flowMod(ofproto.FlowAdd)
+	actions, err := ofproto.ParseActions(fm.Instructions[0].(*ofproto.InstructionApplyActions).Marshal()[8:])
+	if err != nil {
+		t.Fatalf("Failed to parse actions: %v", err)
+	}
+	if ofproto.FormatActions(actions) != expectedActions {
+		t.Errorf("Expected actions <%s>, got <%s>", expectedActions, ofproto.FormatActions(actions))
+	}
+
+	replyFlow := table.BuildFlow().MatchProtocol(ProtocolIP).Action().CT(false, TableIDType(31), 0xfff0).NAT().CTDone().Done()
+	if expected := "table=41,priority=0,ip,actions=ct(table=31,zone=65520,nat)"; replyFlow.String() != expected {
+		t.Errorf("Expected flow <%s>, got <%s>", expected, replyFlow.String())
+	}
+
+	badFlow := table.BuildFlow().Action().Learn(TableIDType(40), 200, 0, 300, 0).MatchLearnedDstPort(ProtocolICMP).Done().Done()
+	if err := badFlow.Add(); err == nil {
+		t.Errorf("Expected error when adding a flow with an invalid learn action")
+	}
+}
+
+func TestOFFlowBuilderCTNATActions(t *testing.T) {
+	br := NewOFBridge("ut0")
+	table := br.CreateTable(TableIDType(70), TableIDType(80), TableMissActionNext)
+
+	tests := []struct {
+		name     string
+		ctAction func(ct CTAction) CTAction
+		expected string
+	}{
+		{"nat", func(ct CTAction) CTAction { return ct.NAT() }, "ct(commit,table=80,zone=65521,nat)"},
+		{"snat-ip", func(ct CTAction) CTAction {
+			return ct.SNAT(&IPRange{StartIP: net.ParseIP("10.10.0.1")}, nil)
+		}, "ct(commit,table=80,zone=65521,nat(src=10.10.0.1))"},
+		{"snat-ip-range-port-range", func(ct CTAction) CTAction {
+			return ct.SNAT(&IPRange{StartIP: net.ParseIP("10.10.0.1"), EndIP: net.ParseIP("10.10.0.2")}, &PortRange{StartPort: 1000, EndPort: 2000})
+		}, "ct(commit,table=80,zone=65521,nat(src=10.10.0.1-10.10.0.2:1000-2000))"},
+		{"dnat-port-range", func(ct CTAction) CTAction {
+			return ct.DNAT(&IPRange{StartIP: net.ParseIP("10.10.0.2"), EndIP: net.ParseIP("10.10.0.2")}, &PortRange{StartPort: 8080, EndPort: 8081})
+		}, "ct(commit,table=80,zone=65521,nat(dst=10.10.0.2:8080-8081))"},
+		{"dnat-ipv6", func(ct CTAction) CTAction {
+			return ct.DNAT(&IPRange{StartIP: net.ParseIP("fd00::2")}, &PortRange{StartPort: 8080})
+		}, "ct(commit,table=80,zone=65521,nat(dst=[fd00::2]:8080))"},
+		{"dnat-no-range", func(ct CTAction) CTAction {
+			return ct.DNAT(nil, &PortRange{StartPort: 8080})
+		}, "ct(commit,table=80,zone=65521,nat(dst))"},
+		{"snat-no-range", func(ct CTAction) CTAction { return ct.SNAT(nil, nil) }, "ct(commit,table=80,zone=65521,nat(src))"},
+		{"snat-exec", func(ct CTAction) CTAction {
+			return ct.SNAT(&IPRange{StartIP: net.ParseIP("10.10.0.1")}, nil).LoadToMark(0x40)
+		}, "ct(commit,table=80,zone=65521,nat(src=10.10.0.1),exec(load:0x40->NXM_NX_CT_MARK[]))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flow := tt.ctAction(table.BuildFlow().MatchProtocol(ProtocolIP).Action().CT(true, TableIDType(80), 0xfff1)).CTDone().Done()
+			if expected := "table=70,priority=0,ip,actions=" + tt.expected; flow.String() != expected {
+				t.Errorf("Expected flow <%s>, got <%s>", expected, flow.String())
+			}
+			// The actions are decoded back to the same representation.
+			fm := flow.(*ofFlow).flowMod(ofproto.FlowAdd)
+			actions, err := ofproto.ParseActions(fm.Instructions[0].(*ofproto.InstructionApplyActions).Marshal()[8:])
+			if err != nil {
+				t.Fatalf("Failed to parse actions: %v", err)
+			}
+			if ofproto.FormatActions(actions) != tt.expected {
+				t.Errorf("Expected actions <%s>, got <%s>", tt.expected, ofproto.FormatActions(actions))
+			}
+		})
+	}
+}
+
+func TestOFFlowBuilderIPv6(t *testing.T) {
+	br := NewOFBridge("ut0")
+	table := br.CreateTable(TableIDType(20), TableIDType(30), TableMissActionNext)
+	mac, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	_, podCIDR, _ := net.ParseCIDR("fd00:10:10::/64")
+
+	flow := table.BuildFlow().MatchProtocol(ProtocolICMPv6).Priority(200).
+		MatchICMPv6Type(135).
+		MatchICMPv6Code(0).
+		MatchNDTarget(net.ParseIP("fd00:10:10::1")).
+		MatchNDSll(mac).
+		Action().Normal().
+		Done()
+	expected := "table=20,priority=200,icmp6,icmpv6_type=135,icmpv6_code=0,nd_target=fd00:10:10::1,nd_sll=aa:bb:cc:dd:ee:ff,actions=Normal"
+	if flow.String() != expected {
+		t.Errorf("Expected flow <%s>, got <%s>", expected, flow.String())
+	}
+	fm := flow.(*ofFlow).flowMod(ofproto.FlowAdd)
+	expectedFields := []*ofproto.Field{ofproto.FieldEthType, ofproto.FieldIPProto, ofproto.FieldICMPv6Type,
+		ofproto.FieldICMPv6Code, ofproto.FieldNDTarget, ofproto.FieldNDSll}
+	if len(fm.Match.Fields) != len(expectedFields) {
+		t.Fatalf("Expected %d match fields, got %d", len(expectedFields), len(fm.Match.Fields))
+	}
+	for i, f := range fm.Match.Fields {
+		if f.Field != expectedFields[i] {
+			t.Errorf("Expected match field %s at index %d, got %s", expectedFields[i].Name, i, f.Field.Name)
+		}
+	}
+	if ethType := binary.BigEndian.Uint16(fm.Match.Fields[0].Value); ethType != ethTypeIPv6 {
+		t.Errorf("Expected eth_type 0x%x, got 0x%x", ethTypeIPv6, ethType)
+	}
+	if len(fm.Match.Fields[4].Value) != net.IPv6len {
+		t.Errorf("Expected nd_target of %d bytes, got %d", net.IPv6len, len(fm.Match.Fields[4].Value))
+	}
+
+	// The ND fields require icmpv6_type, which must precede them in the OXM match even if it is added after them.
+	flow = table.BuildFlow().MatchNDTarget(net.ParseIP("fd00:10:10::1")).
+		MatchNDSll(mac).
+		MatchICMPv6Code(0).
+		MatchICMPv6Type(135).
+		MatchProtocol(ProtocolICMPv6).
+		Action().Normal().
+		Done()
+	expected = "table=20,priority=0,nd_target=fd00:10:10::1,nd_sll=aa:bb:cc:dd:ee:ff,icmpv6_code=0,icmpv6_type=135,icmp6,actions=Normal"
+	if flow.String() != expected {
+		t.Errorf("Expected flow <%s>, got <%s>", expected, flow.String())
+	}
+	fm = flow.(*ofFlow).flowMod(ofproto.FlowAdd)
+	expectedFields = []*ofproto.Field{ofproto.FieldEthType, ofproto.FieldIPProto, ofproto.FieldICMPv6Type,
+		ofproto.FieldNDTarget, ofproto.FieldNDSll, ofproto.FieldICMPv6Code}
+	if len(fm.Match.Fields) != len(expectedFields) {
+		t.Fatalf("Expected %d match fields, got %d", len(expectedFields), len(fm.Match.Fields))
+	}
+	for i, f := range fm.Match.Fields {
+		if f.Field != expectedFields[i] {
+			t.Errorf("Expected match field %s at index %d, got %s", expectedFields[i].Name, i, f.Field.Name)
+		}
+	}
+
+	flow = table.BuildFlow().MatchProtocol(ProtocolTCPv6).
+		MatchSrcIPNet(*podCIDR).
+		MatchDstIP(net.ParseIP("fd00:10:20::5")).
+		Action().SetSrcIP(net.ParseIP("fd00:10:10::1")).
+		Action().SetDstIP(net.ParseIP("fd00:10:20::6")).
+		Action().DecTTL().
+		Done()
+	expectedActions := "set_field:fd00:10:10::1->ipv6_src,set_field:fd00:10:20::6->ipv6_dst,dec_ttl"
+	expected = "table=20,priority=0,tcp6,ipv6_src=fd00:10:10::/64,ipv6_dst=fd00:10:20::5,actions=" + expectedActions
+	if flow.String() != expected {
+		t.Errorf("Expected flow <%s>, got <%s>", expected, flow.String())
+	}
+	fm = flow.(*ofFlow).flowMod(ofproto.FlowAdd)
+	srcField := fm.Match.Fields[2]
+	if srcField.Field != ofproto.FieldIPv6Src || len(srcField.Mask) != net.IPv6len {
+		t.Errorf("Expected masked ipv6_src match, got %s", srcField.Field.Name)
+	}
+	// The actions are decoded back to the same representation.
+	actions, err := ofproto.ParseActions(fm.Instructions[0].(*ofproto.InstructionApplyActions).Marshal()[8:])
+	if err != nil {
+		t.Fatalf("Failed to parse actions: %v", err)
+	}
+	if ofproto.FormatActions(actions) != expectedActions {
+		t.Errorf("Expected actions <%s>, got <%s>", expectedActions, ofproto.FormatActions(actions))
+	}
+
+	for name, badFlow := range map[string]Flow{
+		"nd_target-ipv4": table.BuildFlow().MatchProtocol(ProtocolICMPv6).MatchNDTarget(net.ParseIP("10.10.0.1")).Done(),
+		"arp_spa-ipv6":   table.BuildFlow().MatchProtocol(ProtocolARP).MatchARPSpa(net.ParseIP("fd00::1")).Done(),
+		"set-arp_tpa-ipv6": table.BuildFlow().MatchProtocol(ProtocolARP).
+			Action().SetARPTpa(net.ParseIP("fd00::1")).Done(),
+	} {
+		if err := badFlow.Add(); err == nil {
+			t.Errorf("Expected error when adding flow %s", name)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openflow
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/openflow/ofproto"
+)
+
+const (
+	ethTypeIPv4 = 0x0800
+	ethTypeARP  = 0x0806
+	ethTypeIPv6 = 0x86dd
+
+	ipProtoICMP   = 1
+	ipProtoTCP    = 6
+	ipProtoUDP    = 17
+	ipProtoICMPv6 = 58
+	ipProtoSCTP   = 132
+)
+
+// ipProtocols maps the protocols carried over IPv4 to their IP protocol numbers.
+var ipProtocols = map[protocol]uint64{
+	ProtocolTCP:  ipProtoTCP,
+	ProtocolUDP:  ipProtoUDP,
+	ProtocolSCTP: ipProtoSCTP,
+	ProtocolICMP: ipProtoICMP,
+}
+
+// transportDstFields maps the transport protocols to the NXM name of their destination port field.
+var transportDstFields = map[protocol]string{
+	ProtocolTCP:  NxmFieldTCPDst,
+	ProtocolUDP:  NxmFieldUDPDst,
+	ProtocolSCTP: NxmFieldSCTPDst,
+}
+
+// ctStateBits maps the connection tracking states to their bits in the ct_state field.
+var ctStateBits = map[string]uint32{
+	"new":  1 << 0,
+	"est":  1 << 1,
+	"rel":  1 << 2,
+	"rpl":  1 << 3,
+	"inv":  1 << 4,
+	"trk":  1 << 5,
+	"snat": 1 << 6,
+	"dnat": 1 << 7,
+}
+
+type ofFlowBuilder struct {
+	ofFlow
+}
+
+func (b *ofFlowBuilder) Done() Flow {
+	return &b.ofFlow
+}
+
+func (b *ofFlowBuilder) setError(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func (b *ofFlowBuilder) addMatcher(repr string, fields ...ofproto.MatchField) FlowBuilder {
+	b.matchers = append(b.matchers, ofMatcher{repr: repr, fields: fields})
+	return b
+}
+
+func (b *ofFlowBuilder) MatchReg(regID int, data uint32) FlowBuilder {
+	return b.addMatcher(fmt.Sprintf("reg%d=0x%x", regID, data), ofproto.NewUintMatchField(ofproto.FieldReg(regID), uint64(data)))
+}
+
+func (b *ofFlowBuilder) MatchRegRange(regID int, data uint32, rng Range) FlowBuilder {
+	field := ofproto.NewRangeMatchField(ofproto.FieldReg(regID), uint64(data), int(rng[0]), int(rng[1]-rng[0]+1))
+	return b.addMatcher(fmt.Sprintf("reg%d[%d..%d]=0x%x", regID, rng[0], rng[1], data), field)
+}
+
+// MatchCTState adds a match on the connection tracking state. The value uses the ovs-ofctl syntax, e.g. "+new+trk".
+func (b *ofFlowBuilder) MatchCTState(value string) FlowBuilder {
+	state, mask, err := parseCTState(value)
+	if err != nil {
+		b.setError(err)
+	}
+	field := ofproto.NewMaskedMatchField(ofproto.FieldCtState, ofproto.UintBytes(uint64(state), 4), ofproto.UintBytes(uint64(mask), 4))
+	return b.addMatcher(fmt.Sprintf("ct_state=%s", value), field)
+}
+
+func parseCTState(value string) (state, mask uint32, err error) {
+	for len(value) > 0 {
+		sign := value[0]
+		if sign != '+' && sign != '-' {
+			return 0, 0, fmt.Errorf("invalid ct_state %q", value)
+		}
+		value = value[1:]
+		end := strings.IndexAny(value, "+-")
+		if end < 0 {
+			end = len(value)
+		}
+		bit, ok := ctStateBits[value[:end]]
+		if !ok {
+			return 0, 0, fmt.Errorf("unknown ct_state flag %q", value[:end])
+		}
+		mask |= bit
+		if sign == '+' {
+			state |= bit
+		}
+		value = value[end:]
+	}
+	return state, mask, nil
+}
+
+// MatchCTMark adds a match on the connection tracking mark. The value is either "<mark>" or "<mark>/<mask>".
+func (b *ofFlowBuilder) MatchCTMark(value string) FlowBuilder {
+	repr := fmt.Sprintf("ct_mark=%s", value)
+	parts := strings.SplitN(value, "/", 2)
+	mark, err := strconv.ParseUint(parts[0], 0, 32)
+	if err != nil {
+		b.setError(fmt.Errorf("invalid ct_mark %q: %v", value, err))
+		return b.addMatcher(repr)
+	}
+	if len(parts) == 1 {
+		return b.addMatcher(repr, ofproto.NewUintMatchField(ofproto.FieldCtMark, mark))
+	}
+	mask, err := strconv.ParseUint(parts[1], 0, 32)
+	if err != nil {
+		b.setError(fmt.Errorf("invalid ct_mark %q: %v", value, err))
+		return b.addMatcher(repr)
+	}
+	return b.addMatcher(repr, ofproto.NewMaskedMatchField(ofproto.FieldCtMark, ofproto.UintBytes(mark, 4), ofproto.UintBytes(mask, 4)))
+}
+
+func (b *ofFlowBuilder) MatchInPort(inPort uint32) FlowBuilder {
+	return b.addMatcher(fmt.Sprintf("in_port=%d", inPort), ofproto.NewUintMatchField(ofproto.FieldInPort, uint64(inPort)))
+}
+
+// addressField selects the field for the family of the address: v4Field for an IPv4 address, v6Field for an IPv6
+// address, and returns the address in the length of that field. A nil field means that the family is not supported:
+// an error is returned together with the other field, which can still be used to describe the flow.
+func addressField(v4Field, v6Field *ofproto.Field, ip net.IP) (*ofproto.Field, net.IP, error) {
+	field, addr := v6Field, ip.To16()
+	if ipv4 := ip.To4(); ipv4 != nil {
+		field, addr = v4Field, ipv4
+	}
+	switch {
+	case addr == nil:
+		if v4Field != nil {
+			return v4Field, nil, fmt.Errorf("%s %s is not an IP address", v4Field.Name, ip.String())
+		}
+		return v6Field, nil, fmt.Errorf("%s %s is not an IP address", v6Field.Name, ip.String())
+	case field == nil && v4Field != nil:
+		return v4Field, nil, fmt.Errorf("%s %s is not an IPv4 address", v4Field.Name, ip.String())
+	case field == nil:
+		return v6Field, nil, fmt.Errorf("%s %s is not an IPv6 address", v6Field.Name, ip.String())
+	}
+	return field, addr, nil
+}
+
+func (b *ofFlowBuilder) matchIPNet(v4Field, v6Field *ofproto.Field, ipNet net.IPNet) FlowBuilder {
+	field, ip, err := addressField(v4Field, v6Field, ipNet.IP)
+	repr := fmt.Sprintf("%s=%s", field.Name, ipNet.String())
+	if err == nil && len(ipNet.Mask) < len(ip) {
+		err = fmt.Errorf("%s %s has an invalid mask", field.Name, ipNet.String())
+	}
+	if err != nil {
+		b.setError(err)
+		return b.addMatcher(repr)
+	}
+	mask := []byte(ipNet.Mask[len(ipNet.Mask)-len(ip):])
+	if ones, bits := ipNet.Mask.Size(); ones == bits {
+		return b.addMatcher(repr, ofproto.NewMatchField(field, ip))
+	}
+	return b.addMatcher(repr, ofproto.NewMaskedMatchField(field, ip.Mask(mask), mask))
+}
+
+func (b *ofFlowBuilder) matchIP(v4Field, v6Field *ofproto.Field, ip net.IP) FlowBuilder {
+	field, addr, err := addressField(v4Field, v6Field, ip)
+	repr := fmt.Sprintf("%s=%s", field.Name, ip.String())
+	if err != nil {
+		b.setError(err)
+		return b.addMatcher(repr)
+	}
+	return b.addMatcher(repr, ofproto.NewMatchField(field, addr))
+}
+
+func (b *ofFlowBuilder) MatchDstIP(ip net.IP) FlowBuilder {
+	return b.matchIP(ofproto.FieldIPv4Dst, ofproto.FieldIPv6Dst, ip)
+}
+
+func (b *ofFlowBuilder) MatchDstIPNet(ipNet net.IPNet) FlowBuilder {
+	return b.matchIPNet(ofproto.FieldIPv4Dst, ofproto.FieldIPv6Dst, ipNet)
+}
+
+func (b *ofFlowBuilder) MatchSrcIP(ip net.IP) FlowBuilder {
+	return b.matchIP(ofproto.FieldIPv4Src, ofproto.FieldIPv6Src, ip)
+}
+
+func (b *ofFlowBuilder) MatchSrcIPNet(ipNet net.IPNet) FlowBuilder {
+	return b.matchIPNet(ofproto.FieldIPv4Src, ofproto.FieldIPv6Src, ipNet)
+}
+
+func (b *ofFlowBuilder) matchMAC(field *ofproto.Field, name string, mac net.HardwareAddr) FlowBuilder {
+	return b.addMatcher(fmt.Sprintf("%s=%s", name, mac.String()), ofproto.NewMatchField(field, mac))
+}
+
+func (b *ofFlowBuilder) MatchDstMAC(mac net.HardwareAddr) FlowBuilder {
+	return b.matchMAC(ofproto.FieldEthDst, "dl_dst", mac)
+}
+
+func (b *ofFlowBuilder) MatchSrcMAC(mac net.HardwareAddr) FlowBuilder {
+	return b.matchMAC(ofproto.FieldEthSrc, "dl_src", mac)
+}
+
+func (b *ofFlowBuilder) MatchARPSha(mac net.HardwareAddr) FlowBuilder {
+	return b.matchMAC(ofproto.FieldARPSha, "arp_sha", mac)
+}
+
+func (b *ofFlowBuilder) MatchARPTha(mac net.HardwareAddr) FlowBuilder {
+	return b.matchMAC(ofproto.FieldARPTha, "arp_tha", mac)
+}
+
+func (b *ofFlowBuilder) MatchARPSpa(ip net.IP) FlowBuilder {
+	return b.matchIP(ofproto.FieldARPSpa, nil, ip)
+}
+
+func (b *ofFlowBuilder) MatchARPTpa(ip net.IP) FlowBuilder {
+	return b.matchIP(ofproto.FieldARPTpa, nil, ip)
+}
+
+func (b *ofFlowBuilder) MatchARPOp(op uint16) FlowBuilder {
+	return b.addMatcher(fmt.Sprintf("arp_op=%d", op), ofproto.NewUintMatchField(ofproto.FieldARPOp, uint64(op)))
+}
+
+// MatchICMPv6Type adds a match on the ICMPv6 type. It is encoded after the protocol and before the nd_* fields which
+// depend on it, whatever the order of the calls.
+func (b *ofFlowBuilder) MatchICMPv6Type(icmp6Type uint8) FlowBuilder {
+	b.matchers = append(b.matchers, ofMatcher{
+		repr:         fmt.Sprintf("icmpv6_type=%d", icmp6Type),
+		fields:       []ofproto.MatchField{ofproto.NewUintMatchField(ofproto.FieldICMPv6Type, uint64(icmp6Type))},
+		prerequisite: icmpv6TypePrerequisite,
+	})
+	return b
+}
+
+func (b *ofFlowBuilder) MatchICMPv6Code(icmp6Code uint8) FlowBuilder {
+	return b.addMatcher(fmt.Sprintf("icmpv6_code=%d", icmp6Code), ofproto.NewUintMatchField(ofproto.FieldICMPv6Code, uint64(icmp6Code)))
+}
+
+func (b *ofFlowBuilder) MatchNDTarget(ip net.IP) FlowBuilder {
+	return b.matchIP(nil, ofproto.FieldNDTarget, ip)
+}
+
+func (b *ofFlowBuilder) MatchNDSll(mac net.HardwareAddr) FlowBuilder {
+	return b.matchMAC(ofproto.FieldNDSll, "nd_sll", mac)
+}
+
+func (b *ofFlowBuilder) MatchNDTll(mac net.HardwareAddr) FlowBuilder {
+	return b.matchMAC(ofproto.FieldNDTll, "nd_tll", mac)
+}
+
+func (b *ofFlowBuilder) MatchConjID(value uint32) FlowBuilder {
+	return b.addMatcher(fmt.Sprintf("conj_id=%d", value), ofproto.NewUintMatchField(ofproto.FieldConjID, uint64(value)))
+}
+
+func (b *ofFlowBuilder) Priority(priority uint32) FlowBuilder {
+	b.priority = priority
+	return b
+}
+
+func (b *ofFlowBuilder) MatchTCPDstPort(port uint16) FlowBuilder {
+	return b.addMatcher(fmt.Sprintf("tcp_dst=%d", port), ofproto.NewUintMatchField(ofproto.FieldTCPDst, uint64(port)))
+}
+
+func (b *ofFlowBuilder) MatchUDPDstPort(port uint16) FlowBuilder {
+	return b.addMatcher(fmt.Sprintf("udp_dst=%d", port), ofproto.NewUintMatchField(ofproto.FieldUDPDst, uint64(port)))
+}
+
+func (b *ofFlowBuilder) MatchSCTPDstPort(port uint16) FlowBuilder {
+	return b.addMatcher(fmt.Sprintf("sctp_dst=%d", port), ofproto.NewUintMatchField(ofproto.FieldSCTPDst, uint64(port)))
+}
+
+// MatchProtocol adds the eth_type and ip_proto matches of the protocol. A flow matches a single protocol, calling
+// MatchProtocol again replaces the previous protocol, e.g. "tcp" replaces "ip".
+func (b *ofFlowBuilder) MatchProtocol(protocol protocol) FlowBuilder {
+	protocol = strings.ToLower(protocol)
+	var fields []ofproto.MatchField
+	switch protocol {
+	case ProtocolIP:
+		fields = []ofproto.MatchField{ofproto.NewUintMatchField(ofproto.FieldEthType, ethTypeIPv4)}
+	case ProtocolARP:
+		fields = []ofproto.MatchField{ofproto.NewUintMatchField(ofproto.FieldEthType, ethTypeARP)}
+	case ProtocolTCP:
+		fields = ipProtocolFields(ipProtoTCP)
+	case ProtocolUDP:
+		fields = ipProtocolFields(ipProtoUDP)
+	case ProtocolSCTP:
+		fields = ipProtocolFields(ipProtoSCTP)
+	case ProtocolICMP:
+		fields = ipProtocolFields(ipProtoICMP)
+	case ProtocolIPv6:
+		fields = []ofproto.MatchField{ofproto.NewUintMatchField(ofproto.FieldEthType, ethTypeIPv6)}
+	case ProtocolTCPv6:
+		fields = ipv6ProtocolFields(ipProtoTCP)
+	case ProtocolUDPv6:
+		fields = ipv6ProtocolFields(ipProtoUDP)
+	case ProtocolSCTPv6:
+		fields = ipv6ProtocolFields(ipProtoSCTP)
+	case ProtocolICMPv6:
+		fields = ipv6ProtocolFields(ipProtoICMPv6)
+	default:
+		b.setError(fmt.Errorf("unsupported protocol %q", protocol))
+	}
+	matcher := ofMatcher{repr: protocol, fields: fields, prerequisite: protocolPrerequisite}
+	for i := range b.matchers {
+		if b.matchers[i].prerequisite == protocolPrerequisite {
+			b.matchers[i] = matcher
+			return b
+		}
+	}
+	b.matchers = append(b.matchers, matcher)
+	return b
+}
+
+func ipProtocolFields(proto uint64) []ofproto.MatchField {
+	return []ofproto.MatchField{
+		ofproto.NewUintMatchField(ofproto.FieldEthType, ethTypeIPv4),
+		ofproto.NewUintMatchField(ofproto.FieldIPProto, proto),
+	}
+}
+
+func ipv6ProtocolFields(proto uint64) []ofproto.MatchField {
+	return []ofproto.MatchField{
+		ofproto.NewUintMatchField(ofproto.FieldEthType, ethTypeIPv6),
+		ofproto.NewUintMatchField(ofproto.FieldIPProto, proto),
+	}
+}
+
+// Cookie sets the cookie of the flow. Unlike the other match conditions, the cookie is not part of MatchString.
+func (b *ofFlowBuilder) Cookie(cookieID uint64) FlowBuilder {
+	b.cookie = cookieID
+	return b
+}
+
+func (b *ofFlowBuilder) Action() Action {
+	return &ofFlowActions{b}
+}
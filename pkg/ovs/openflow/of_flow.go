@@ -0,0 +1,180 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openflow
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/openflow/ofproto"
+)
+
+// ofMatcher is a match condition of an ofFlow. repr uses the ovs-ofctl syntax, so that String and MatchString are
+// the same as for commandFlow.
+type ofMatcher struct {
+	repr   string
+	fields []ofproto.MatchField
+	// prerequisite is set for the matchers whose fields are prerequisites of other fields, and must precede them in
+	// the OXM match.
+	prerequisite prerequisiteOrder
+}
+
+// prerequisiteOrder is the position of the fields of a matcher in the OXM match.
+type prerequisiteOrder int
+
+const (
+	noPrerequisite prerequisiteOrder = iota
+	// protocolPrerequisite is the order of the eth_type and ip_proto fields, which come first.
+	protocolPrerequisite
+	// icmpv6TypePrerequisite is the order of the icmpv6_type field, which requires ip_proto and is required by the
+	// nd_* fields.
+	icmpv6TypePrerequisite
+)
+
+// ofFlowAction is an action of an ofFlow. action is nil for actions which are not encoded, e.g. "drop".
+type ofFlowAction struct {
+	repr   string
+	action ofproto.Action
+}
+
+type ofFlow struct {
+	table    Table
+	bridge   *ofBridge
+	priority uint32
+	cookie   uint64
+	matchers []ofMatcher
+	actions  []ofFlowAction
+	// err is the first error met when building the flow. It is returned by Add, Modify and Delete.
+	err error
+}
+
+func (f *ofFlow) GetTable() Table {
+	return f.table
+}
+
+func (f *ofFlow) format(withActions bool) string {
+	repr := fmt.Sprintf("table=%d", f.table.GetID())
+
+	if withActions {
+		if f.cookie != 0 {
+			repr += fmt.Sprintf(",cookie=0x%x", f.cookie)
+		}
+		repr += fmt.Sprintf(",priority=%d", f.priority)
+	}
+	if len(f.matchers) > 0 {
+		var matchers []string
+		for _, m := range f.matchers {
+			matchers = append(matchers, m.repr)
+		}
+		repr += fmt.Sprintf(",%s", strings.Join(matchers, ","))
+	}
+	if withActions && len(f.actions) > 0 {
+		var actions []string
+		for _, a := range f.actions {
+			actions = append(actions, a.repr)
+		}
+		repr += fmt.Sprintf(",actions=%s", strings.Join(actions, ","))
+	}
+
+	return repr
+}
+
+// flowMod encodes the flow as a FlowMod message with the provided command. Match fields required as prerequisites
+// are encoded first.
+func (f *ofFlow) flowMod(command uint8) *ofproto.FlowMod {
+	msg := ofproto.NewFlowMod(command)
+	msg.TableID = uint8(f.table.GetID())
+	msg.Priority = uint16(f.priority)
+	msg.Cookie = f.cookie
+	for _, prerequisite := range []prerequisiteOrder{protocolPrerequisite, icmpv6TypePrerequisite, noPrerequisite} {
+		for _, m := range f.matchers {
+			if m.prerequisite == prerequisite {
+				msg.Match.Fields = append(msg.Match.Fields, m.fields...)
+			}
+		}
+	}
+	if command == ofproto.FlowDelete || command == ofproto.FlowDeleteStrict {
+		return msg
+	}
+	var actions []ofproto.Action
+	for _, a := range f.actions {
+		if a.action != nil {
+			actions = append(actions, a.action)
+		}
+	}
+	if len(actions) > 0 {
+		msg.Instructions = []ofproto.Instruction{&ofproto.InstructionApplyActions{Actions: actions}}
+	}
+	return msg
+}
+
+func (f *ofFlow) Add() error {
+	if err := f.send(ofproto.FlowAdd); err != nil {
+		return fmt.Errorf("failed to add flow %q: %v", f.format(true), err)
+	}
+
+	f.updateTableStatus(1)
+	return nil
+}
+
+func (f *ofFlow) Modify() error {
+	if err := f.send(ofproto.FlowModifyStrict); err != nil {
+		return fmt.Errorf("failed to modify flow %q: %v", f.format(true), err)
+	}
+
+	f.updateTableStatus(0)
+	return nil
+}
+
+func (f *ofFlow) Delete() error {
+	if err := f.send(ofproto.FlowDeleteStrict); err != nil {
+		return fmt.Errorf("failed to delete flow %q: %v", f.format(false), err)
+	}
+	f.updateTableStatus(-1)
+	return nil
+}
+
+func (f *ofFlow) send(command uint8) error {
+	if f.err != nil {
+		return f.err
+	}
+	return f.bridge.transact(f.flowMod(command))
+}
+
+func (f *ofFlow) updateTableStatus(delta int) {
+	if updater, ok := f.table.(updater); ok {
+		updater.UpdateStatus(delta)
+	}
+}
+
+func (f *ofFlow) String() string {
+	return f.format(true)
+}
+
+func (f *ofFlow) MatchString() string {
+	return f.format(false)
+}
+
+func (f *ofFlow) CopyToBuilder() FlowBuilder {
+	var newFlow = ofFlow{
+		table:    f.table,
+		bridge:   f.bridge,
+		priority: f.priority,
+		cookie:   f.cookie,
+		matchers: append([]ofMatcher(nil), f.matchers...),
+		err:      f.err,
+	}
+	return &ofFlowBuilder{newFlow}
+}
@@ -0,0 +1,160 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openflow
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/openflow/ofproto"
+)
+
+var ofGroupTypes = map[GroupType]uint8{
+	GroupTypeAll:      ofproto.GroupTypeAll,
+	GroupTypeSelect:   ofproto.GroupTypeSelect,
+	GroupTypeIndirect: ofproto.GroupTypeIndirect,
+}
+
+type ofGroup struct {
+	bridge    *ofBridge
+	id        GroupIDType
+	groupType GroupType
+	buckets   []*ofproto.Bucket
+	// err is the first error met when building the group. It is returned by Add and Modify.
+	err error
+}
+
+func (g *ofGroup) GetID() GroupIDType {
+	return g.id
+}
+
+func (g *ofGroup) GetType() GroupType {
+	return g.groupType
+}
+
+func (g *ofGroup) setError(err error) {
+	if g.err == nil {
+		g.err = err
+	}
+}
+
+func (g *ofGroup) Bucket() BucketBuilder {
+	return &ofBucketBuilder{
+		group:   g,
+		weight:  0,
+		actions: &ofFlowBuilder{},
+	}
+}
+
+func (g *ofGroup) ResetBuckets() Group {
+	g.buckets = nil
+	return g
+}
+
+// groupMod encodes the group as a GroupMod message with the provided command.
+func (g *ofGroup) groupMod(command uint16) *ofproto.GroupMod {
+	msg := &ofproto.GroupMod{
+		Command: command,
+		Type:    ofGroupTypes[g.groupType],
+		GroupID: uint32(g.id),
+	}
+	if command != ofproto.GroupDelete {
+		msg.Buckets = g.buckets
+	}
+	return msg
+}
+
+func (g *ofGroup) send(command uint16) error {
+	if g.err != nil {
+		return g.err
+	}
+	return g.bridge.transact(g.groupMod(command))
+}
+
+func (g *ofGroup) Add() error {
+	if err := g.send(ofproto.GroupAdd); err != nil {
+		return fmt.Errorf("failed to add group %q: %v", g.String(), err)
+	}
+	return nil
+}
+
+func (g *ofGroup) Modify() error {
+	if err := g.send(ofproto.GroupModify); err != nil {
+		return fmt.Errorf("failed to modify group %q: %v", g.String(), err)
+	}
+	return nil
+}
+
+func (g *ofGroup) Delete() error {
+	if err := g.bridge.transact(g.groupMod(ofproto.GroupDelete)); err != nil {
+		return fmt.Errorf("failed to delete group %d: %v", g.id, err)
+	}
+	return nil
+}
+
+func (g *ofGroup) String() string {
+	return g.groupMod(ofproto.GroupAdd).String()
+}
+
+// ofBucketBuilder builds a bucket with an ofFlowBuilder, so that the bucket actions are encoded like flow actions.
+type ofBucketBuilder struct {
+	group   *ofGroup
+	weight  uint16
+	actions *ofFlowBuilder
+}
+
+func (b *ofBucketBuilder) Weight(val uint16) BucketBuilder {
+	b.weight = val
+	return b
+}
+
+func (b *ofBucketBuilder) LoadRegRange(regID int, value uint32, to Range) BucketBuilder {
+	b.actions.Action().LoadRegRange(regID, value, to)
+	return b
+}
+
+func (b *ofBucketBuilder) SetDstMAC(addr net.HardwareAddr) BucketBuilder {
+	b.actions.Action().SetDstMAC(addr)
+	return b
+}
+
+func (b *ofBucketBuilder) SetDstIP(addr net.IP) BucketBuilder {
+	b.actions.Action().SetDstIP(addr)
+	return b
+}
+
+func (b *ofBucketBuilder) Output(port int) BucketBuilder {
+	b.actions.Action().Output(port)
+	return b
+}
+
+func (b *ofBucketBuilder) Resubmit(port string, table TableIDType) BucketBuilder {
+	b.actions.Action().Resubmit(port, table)
+	return b
+}
+
+func (b *ofBucketBuilder) Done() Group {
+	if b.actions.err != nil {
+		b.group.setError(b.actions.err)
+	}
+	bucket := ofproto.NewBucket(b.weight)
+	for _, a := range b.actions.actions {
+		if a.action != nil {
+			bucket.Actions = append(bucket.Actions, a.action)
+		}
+	}
+	b.group.buckets = append(b.group.buckets, bucket)
+	return b.group
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openflow
+
+import (
+	"fmt"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/openflow/ofproto"
+)
+
+// ofLearnAction builds the specs of an NXActionLearn, which is added to the flow actions by Done.
+type ofLearnAction struct {
+	builder *ofFlowBuilder
+	learn   *ofproto.NXActionLearn
+}
+
+func (a *ofLearnAction) addSpec(spec *ofproto.LearnSpec) LearnAction {
+	a.learn.Specs = append(a.learn.Specs, spec)
+	return a
+}
+
+func (a *ofLearnAction) matchImmediate(field *ofproto.Field, value uint64) LearnAction {
+	return a.addSpec(&ofproto.LearnSpec{Dst: field, NBits: field.Bits(), Value: value})
+}
+
+func (a *ofLearnAction) matchLearned(field *ofproto.Field) LearnAction {
+	return a.addSpec(&ofproto.LearnSpec{Src: field, Dst: field, NBits: field.Bits()})
+}
+
+func (a *ofLearnAction) MatchProtocol(name protocol) LearnAction {
+	a.matchImmediate(ofproto.FieldEthType, ethTypeIPv4)
+	if name == ProtocolIP {
+		return a
+	}
+	proto, ok := ipProtocols[name]
+	if !ok {
+		a.builder.setError(fmt.Errorf("unsupported protocol %q in learn action", name))
+		return a
+	}
+	return a.matchImmediate(ofproto.FieldIPProto, proto)
+}
+
+func (a *ofLearnAction) MatchLearnedSrcIP() LearnAction {
+	return a.matchLearned(ofproto.FieldIPv4Src)
+}
+
+func (a *ofLearnAction) MatchLearnedDstIP() LearnAction {
+	return a.matchLearned(ofproto.FieldIPv4Dst)
+}
+
+func (a *ofLearnAction) MatchLearnedDstPort(name protocol) LearnAction {
+	fieldName, ok := transportDstFields[name]
+	if !ok {
+		a.builder.setError(fmt.Errorf("unsupported transport protocol %q in learn action", name))
+		return a
+	}
+	field, err := ofproto.FieldByName(fieldName)
+	if err != nil {
+		a.builder.setError(err)
+		return a
+	}
+	return a.matchLearned(field)
+}
+
+func (a *ofLearnAction) LoadRegRange(regID int, value uint32, to Range) LearnAction {
+	return a.addSpec(&ofproto.LearnSpec{
+		Dst:    ofproto.FieldReg(regID),
+		DstOfs: int(to[0]),
+		NBits:  int(to[1] - to[0] + 1),
+		Value:  uint64(value),
+		Load:   true,
+	})
+}
+
+func (a *ofLearnAction) LoadLearnedRegRange(regID int, rng Range) LearnAction {
+	reg := ofproto.FieldReg(regID)
+	return a.addSpec(&ofproto.LearnSpec{
+		Src:    reg,
+		SrcOfs: int(rng[0]),
+		Dst:    reg,
+		DstOfs: int(rng[0]),
+		NBits:  int(rng[1] - rng[0] + 1),
+		Load:   true,
+	})
+}
+
+func (a *ofLearnAction) Done() FlowBuilder {
+	a.builder.actions = append(a.builder.actions, ofFlowAction{repr: a.learn.String(), action: a.learn})
+	return a.builder
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openflow
+
+import (
+	"sync"
+	"time"
+)
+
+type ofTable struct {
+	sync.Mutex
+
+	bridge     *ofBridge
+	id         TableIDType
+	next       TableIDType
+	missAction MissActionType
+	flowCount  uint
+	updateTime time.Time
+}
+
+func (t *ofTable) GetID() TableIDType {
+	return t.id
+}
+
+func (t *ofTable) BuildFlow() FlowBuilder {
+	fb := new(ofFlowBuilder)
+	fb.table = t
+	fb.bridge = t.bridge
+	return fb
+}
+
+func (t *ofTable) Status() TableStatus {
+	t.Lock()
+	defer t.Unlock()
+
+	return TableStatus{
+		ID:         uint(t.id),
+		FlowCount:  t.flowCount,
+		UpdateTime: t.updateTime,
+	}
+}
+
+func (t *ofTable) GetMissAction() MissActionType {
+	return t.missAction
+}
+
+func (t *ofTable) GetNext() TableIDType {
+	return t.next
+}
+
+func (t *ofTable) UpdateStatus(flowCountDelta int) {
+	t.Lock()
+	defer t.Unlock()
+
+	if flowCountDelta < 0 {
+		t.flowCount -= uint(-flowCountDelta)
+	} else {
+		t.flowCount += uint(flowCountDelta)
+	}
+	t.updateTime = time.Now()
+}
+
+// resetStatus resets the number of flows of the table, after the OFSwitch lost all its flows.
+func (t *ofTable) resetStatus() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.flowCount = 0
+	t.updateTime = time.Now()
+}
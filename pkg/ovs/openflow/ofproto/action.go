@@ -0,0 +1,681 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ofproto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// OpenFlow 1.3 action types.
+const (
+	ActionTypeOutput       uint16 = 0
+	ActionTypeGroup        uint16 = 22
+	ActionTypeDecNwTTL     uint16 = 24
+	ActionTypeSetField     uint16 = 25
+	ActionTypeExperimenter uint16 = 0xffff
+)
+
+// NiciraExperimenterID is the experimenter ID of the Nicira extensions.
+const NiciraExperimenterID uint32 = 0x00002320
+
+// Nicira extension action subtypes.
+const (
+	NXActionSubtypeRegMove       uint16 = 6
+	NXActionSubtypeRegLoad       uint16 = 7
+	NXActionSubtypeResubmitTable uint16 = 14
+	NXActionSubtypeOutputReg     uint16 = 15
+	NXActionSubtypeLearn         uint16 = 16
+	NXActionSubtypeConjunction   uint16 = 34
+	NXActionSubtypeConntrack     uint16 = 35
+	NXActionSubtypeNAT           uint16 = 36
+)
+
+// Flags of the Nicira conntrack action.
+const (
+	NXConntrackFlagCommit uint16 = 1 << 0
+	NXConntrackFlagForce  uint16 = 1 << 1
+)
+
+// NXConntrackRecircNone means that the conntrack action does not recirculate the packet.
+const NXConntrackRecircNone uint8 = 0xff
+
+// Action is an OpenFlow action.
+type Action interface {
+	// Marshal encodes the action. The length of the encoded action is always a multiple of 8.
+	Marshal() []byte
+	// String returns the action in ovs-ofctl syntax.
+	String() string
+}
+
+// actionHeader returns a buffer of the given length starting with the action type and length.
+func actionHeader(actionType uint16, length int) []byte {
+	data := make([]byte, length)
+	binary.BigEndian.PutUint16(data[0:2], actionType)
+	binary.BigEndian.PutUint16(data[2:4], uint16(length))
+	return data
+}
+
+// nxActionHeader returns a buffer of the given length starting with the header of a Nicira action.
+func nxActionHeader(subtype uint16, length int) []byte {
+	data := actionHeader(ActionTypeExperimenter, length)
+	binary.BigEndian.PutUint32(data[4:8], NiciraExperimenterID)
+	binary.BigEndian.PutUint16(data[8:10], subtype)
+	return data
+}
+
+// ofsNbits encodes a bit range as used by the Nicira register actions.
+func ofsNbits(ofs, nbits int) uint16 {
+	return uint16(ofs<<6 | (nbits - 1))
+}
+
+// fieldRange formats the bits [ofs, ofs+nbits) of a field, e.g. "NXM_NX_REG0[0..15]". The whole field is formatted
+// as "NXM_NX_REG0[]".
+func fieldRange(f *Field, ofs, nbits int) string {
+	if ofs == 0 && nbits == f.Bits() {
+		return f.NXMName + "[]"
+	}
+	return fmt.Sprintf("%s[%d..%d]", f.NXMName, ofs, ofs+nbits-1)
+}
+
+// portNames are the names used by ovs-ofctl for the reserved ports.
+var portNames = map[uint32]string{
+	PortInPort: "IN_PORT",
+	0xfffffff9: "TABLE",
+	PortNormal: "NORMAL",
+	0xfffffffb: "FLOOD",
+	0xfffffffc: "ALL",
+	0xfffffffd: "CONTROLLER",
+	0xfffffffe: "LOCAL",
+	PortAny:    "ANY",
+}
+
+func portString(port uint32) string {
+	if name, ok := portNames[port]; ok {
+		return name
+	}
+	return fmt.Sprint(port)
+}
+
+// ActionOutput outputs the packet to a port.
+type ActionOutput struct {
+	Port   uint32
+	MaxLen uint16
+}
+
+func (a *ActionOutput) Marshal() []byte {
+	data := actionHeader(ActionTypeOutput, 16)
+	binary.BigEndian.PutUint32(data[4:8], a.Port)
+	binary.BigEndian.PutUint16(data[8:10], a.MaxLen)
+	return data
+}
+
+func (a *ActionOutput) String() string {
+	if a.Port == PortNormal {
+		return "NORMAL"
+	}
+	return "output:" + portString(a.Port)
+}
+
+// ActionGroup applies the buckets of a group to the packet.
+type ActionGroup struct {
+	GroupID uint32
+}
+
+func (a *ActionGroup) Marshal() []byte {
+	data := actionHeader(ActionTypeGroup, 8)
+	binary.BigEndian.PutUint32(data[4:8], a.GroupID)
+	return data
+}
+
+func (a *ActionGroup) String() string {
+	return fmt.Sprintf("group:%d", a.GroupID)
+}
+
+// ActionDecNwTTL decrements the IP TTL of the packet.
+type ActionDecNwTTL struct{}
+
+func (a *ActionDecNwTTL) Marshal() []byte {
+	return actionHeader(ActionTypeDecNwTTL, 8)
+}
+
+func (a *ActionDecNwTTL) String() string {
+	return "dec_ttl"
+}
+
+// ActionSetField sets a header field of the packet.
+type ActionSetField struct {
+	Field MatchField
+}
+
+func (a *ActionSetField) Marshal() []byte {
+	oxm := a.Field.Marshal()
+	length := 4 + len(oxm)
+	data := actionHeader(ActionTypeSetField, length+pad8(length))
+	copy(data[4:], oxm)
+	return data
+}
+
+func (a *ActionSetField) String() string {
+	return fmt.Sprintf("set_field:%s->%s", a.Field.ValueString(), a.Field.Field.Name)
+}
+
+// NXActionResubmitTable resubmits the packet to a table, optionally with a different in_port.
+type NXActionResubmitTable struct {
+	// InPort is the port to use as in_port, or NXResubmitInPort to keep the current one.
+	InPort uint16
+	Table  uint8
+}
+
+// NXResubmitInPort is the port number which keeps the in_port of the packet when resubmitting.
+const NXResubmitInPort uint16 = 0xfff8
+
+func (a *NXActionResubmitTable) Marshal() []byte {
+	data := nxActionHeader(NXActionSubtypeResubmitTable, 16)
+	binary.BigEndian.PutUint16(data[10:12], a.InPort)
+	data[12] = a.Table
+	return data
+}
+
+func (a *NXActionResubmitTable) String() string {
+	port := ""
+	if a.InPort != NXResubmitInPort {
+		port = portString(uint32(a.InPort))
+	}
+	return fmt.Sprintf("resubmit(%s,%d)", port, a.Table)
+}
+
+// NXActionRegLoad loads an immediate value to the bits [Ofs, Ofs+NBits) of a field.
+type NXActionRegLoad struct {
+	Dst   *Field
+	Ofs   int
+	NBits int
+	Value uint64
+}
+
+func (a *NXActionRegLoad) Marshal() []byte {
+	data := nxActionHeader(NXActionSubtypeRegLoad, 24)
+	binary.BigEndian.PutUint16(data[10:12], ofsNbits(a.Ofs, a.NBits))
+	binary.BigEndian.PutUint32(data[12:16], a.Dst.Header(false))
+	binary.BigEndian.PutUint64(data[16:24], a.Value)
+	return data
+}
+
+func (a *NXActionRegLoad) String() string {
+	return fmt.Sprintf("load:0x%x->%s", a.Value, fieldRange(a.Dst, a.Ofs, a.NBits))
+}
+
+// NXActionRegMove copies NBits bits from a field to another one.
+type NXActionRegMove struct {
+	Src    *Field
+	Dst    *Field
+	SrcOfs int
+	DstOfs int
+	NBits  int
+}
+
+func (a *NXActionRegMove) Marshal() []byte {
+	data := nxActionHeader(NXActionSubtypeRegMove, 24)
+	binary.BigEndian.PutUint16(data[10:12], uint16(a.NBits))
+	binary.BigEndian.PutUint16(data[12:14], uint16(a.SrcOfs))
+	binary.BigEndian.PutUint16(data[14:16], uint16(a.DstOfs))
+	binary.BigEndian.PutUint32(data[16:20], a.Src.Header(false))
+	binary.BigEndian.PutUint32(data[20:24], a.Dst.Header(false))
+	return data
+}
+
+func (a *NXActionRegMove) String() string {
+	return fmt.Sprintf("move:%s->%s", fieldRange(a.Src, a.SrcOfs, a.NBits), fieldRange(a.Dst, a.DstOfs, a.NBits))
+}
+
+// NXActionOutputReg outputs the packet to the port read from the bits [Ofs, Ofs+NBits) of a field.
+type NXActionOutputReg struct {
+	Src    *Field
+	Ofs    int
+	NBits  int
+	MaxLen uint16
+}
+
+func (a *NXActionOutputReg) Marshal() []byte {
+	data := nxActionHeader(NXActionSubtypeOutputReg, 24)
+	binary.BigEndian.PutUint16(data[10:12], ofsNbits(a.Ofs, a.NBits))
+	binary.BigEndian.PutUint32(data[12:16], a.Src.Header(false))
+	binary.BigEndian.PutUint16(data[16:18], a.MaxLen)
+	return data
+}
+
+func (a *NXActionOutputReg) String() string {
+	return "output:" + fieldRange(a.Src, a.Ofs, a.NBits)
+}
+
+// NXActionConjunction adds the flow to a clause of a conjunctive match.
+type NXActionConjunction struct {
+	ID uint32
+	// Clause is the 1-based clause number, as used by ovs-ofctl.
+	Clause   uint8
+	NClauses uint8
+}
+
+func (a *NXActionConjunction) Marshal() []byte {
+	data := nxActionHeader(NXActionSubtypeConjunction, 16)
+	data[10] = a.Clause - 1
+	data[11] = a.NClauses
+	binary.BigEndian.PutUint32(data[12:16], a.ID)
+	return data
+}
+
+func (a *NXActionConjunction) String() string {
+	return fmt.Sprintf("conjunction(%d,%d/%d)", a.ID, a.Clause, a.NClauses)
+}
+
+// NXActionConntrack sends the packet through the connection tracker.
+type NXActionConntrack struct {
+	Flags uint16
+	// Zone is the immediate conntrack zone.
+	Zone uint16
+	// RecircTable is the table to recirculate the packet to, or NXConntrackRecircNone.
+	RecircTable uint8
+	Alg         uint16
+	// Actions are executed on the connection when it is committed.
+	Actions []Action
+}
+
+func (a *NXActionConntrack) Marshal() []byte {
+	var nested []byte
+	for _, action := range a.Actions {
+		nested = append(nested, action.Marshal()...)
+	}
+	data := nxActionHeader(NXActionSubtypeConntrack, 24+len(nested))
+	binary.BigEndian.PutUint16(data[10:12], a.Flags)
+	binary.BigEndian.PutUint16(data[16:18], a.Zone)
+	data[18] = a.RecircTable
+	binary.BigEndian.PutUint16(data[22:24], a.Alg)
+	copy(data[24:], nested)
+	return data
+}
+
+func (a *NXActionConntrack) String() string {
+	var args []string
+	if a.Flags&NXConntrackFlagCommit != 0 {
+		args = append(args, "commit")
+	}
+	if a.Flags&NXConntrackFlagForce != 0 {
+		args = append(args, "force")
+	}
+	if a.RecircTable != NXConntrackRecircNone {
+		args = append(args, fmt.Sprintf("table=%d", a.RecircTable))
+	}
+	if a.Zone != 0 {
+		args = append(args, fmt.Sprintf("zone=%d", a.Zone))
+	}
+	if a.Alg != 0 {
+		args = append(args, fmt.Sprintf("alg=%d", a.Alg))
+	}
+	actions := a.Actions
+	// Like ovs-ofctl, a leading NAT action is formatted outside of exec.
+	if len(actions) > 0 {
+		if nat, ok := actions[0].(*NXActionNAT); ok {
+			args = append(args, nat.String())
+			actions = actions[1:]
+		}
+	}
+	if len(actions) > 0 {
+		args = append(args, fmt.Sprintf("exec(%s)", FormatActions(actions)))
+	}
+	return fmt.Sprintf("ct(%s)", strings.Join(args, ","))
+}
+
+// Flags of the Nicira NAT action.
+const (
+	NXNATFlagSrc         uint16 = 1 << 0
+	NXNATFlagDst         uint16 = 1 << 1
+	NXNATFlagPersistent  uint16 = 1 << 2
+	NXNATFlagProtoHash   uint16 = 1 << 3
+	NXNATFlagProtoRandom uint16 = 1 << 4
+)
+
+// Bits of the NAT action telling which parameters of the range are present.
+const (
+	nxNATRangeIPv4Min  uint16 = 1 << 0
+	nxNATRangeIPv4Max  uint16 = 1 << 1
+	nxNATRangeIPv6Min  uint16 = 1 << 2
+	nxNATRangeIPv6Max  uint16 = 1 << 3
+	nxNATRangeProtoMin uint16 = 1 << 4
+	nxNATRangeProtoMax uint16 = 1 << 5
+)
+
+// NXActionNAT translates the addresses of a connection. It is only valid in the actions of a conntrack action.
+// Without the NXNATFlagSrc and NXNATFlagDst flags, it only applies the translation of a connection which was
+// committed with one, e.g. to un-NAT the reply traffic.
+type NXActionNAT struct {
+	Flags uint16
+	// IPMin and IPMax are the range of addresses to translate to. IPMax is optional.
+	IPMin net.IP
+	IPMax net.IP
+	// PortMin and PortMax are the range of transport ports to translate to, 0 if unset.
+	PortMin uint16
+	PortMax uint16
+}
+
+func (a *NXActionNAT) Marshal() []byte {
+	var rangePresent uint16
+	var params []byte
+	appendIP := func(ip net.IP, ipv4Bit, ipv6Bit uint16) {
+		if ip == nil {
+			return
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			rangePresent |= ipv4Bit
+			params = append(params, ip4...)
+		} else {
+			rangePresent |= ipv6Bit
+			params = append(params, ip.To16()...)
+		}
+	}
+	appendIP(a.IPMin, nxNATRangeIPv4Min, nxNATRangeIPv6Min)
+	appendIP(a.IPMax, nxNATRangeIPv4Max, nxNATRangeIPv6Max)
+	for _, p := range []struct {
+		port uint16
+		bit  uint16
+	}{{a.PortMin, nxNATRangeProtoMin}, {a.PortMax, nxNATRangeProtoMax}} {
+		if p.port != 0 {
+			rangePresent |= p.bit
+			params = append(params, byte(p.port>>8), byte(p.port))
+		}
+	}
+	length := 16 + len(params)
+	data := nxActionHeader(NXActionSubtypeNAT, length+pad8(length))
+	binary.BigEndian.PutUint16(data[12:14], a.Flags)
+	binary.BigEndian.PutUint16(data[14:16], rangePresent)
+	copy(data[16:], params)
+	return data
+}
+
+func formatNATIP(ip net.IP) string {
+	if ip.To4() == nil {
+		return "[" + ip.String() + "]"
+	}
+	return ip.String()
+}
+
+func (a *NXActionNAT) String() string {
+	if a.Flags&(NXNATFlagSrc|NXNATFlagDst) == 0 {
+		return "nat"
+	}
+	direction := "dst"
+	if a.Flags&NXNATFlagSrc != 0 {
+		direction = "src"
+	}
+	var addr string
+	if a.IPMin != nil {
+		addr = formatNATIP(a.IPMin)
+		if a.IPMax != nil && !a.IPMax.Equal(a.IPMin) {
+			addr += "-" + formatNATIP(a.IPMax)
+		}
+	}
+	if a.PortMin != 0 {
+		addr += fmt.Sprintf(":%d", a.PortMin)
+		if a.PortMax != 0 && a.PortMax != a.PortMin {
+			addr += fmt.Sprintf("-%d", a.PortMax)
+		}
+	}
+	args := []string{direction}
+	if addr != "" {
+		args[0] += "=" + addr
+	}
+	if a.Flags&NXNATFlagPersistent != 0 {
+		args = append(args, "persistent")
+	}
+	if a.Flags&NXNATFlagProtoHash != 0 {
+		args = append(args, "hash")
+	}
+	if a.Flags&NXNATFlagProtoRandom != 0 {
+		args = append(args, "random")
+	}
+	return fmt.Sprintf("nat(%s)", strings.Join(args, ","))
+}
+
+// Bits of the header of a learn spec.
+const (
+	nxLearnSrcImmediate uint16 = 1 << 13
+	nxLearnDstLoad      uint16 = 1 << 11
+	nxLearnNBitsMask    uint16 = 0x3ff
+)
+
+// LearnSpec is a spec of a learn action, which adds a match or a load action to the learned flow. The value is
+// either copied from the bits [SrcOfs, SrcOfs+NBits) of the Src field of the packet, or the immediate Value if Src
+// is nil. Immediate values are limited to 64 bits.
+type LearnSpec struct {
+	Src    *Field
+	SrcOfs int
+	Value  uint64
+	Dst    *Field
+	DstOfs int
+	NBits  int
+	// Load makes the learned flow load the value to the bits of Dst, otherwise the learned flow matches them.
+	Load bool
+}
+
+func (s *LearnSpec) marshal() []byte {
+	header := uint16(s.NBits) & nxLearnNBitsMask
+	if s.Src == nil {
+		header |= nxLearnSrcImmediate
+	}
+	if s.Load {
+		header |= nxLearnDstLoad
+	}
+	data := []byte{byte(header >> 8), byte(header)}
+	if s.Src == nil {
+		value := make([]byte, 8)
+		binary.BigEndian.PutUint64(value, s.Value)
+		n := (s.NBits + 15) / 16 * 2
+		if n > 8 {
+			data = append(data, make([]byte, n-8)...)
+			n = 8
+		}
+		data = append(data, value[8-n:]...)
+	} else {
+		data = append(data, fieldOfs(s.Src, s.SrcOfs)...)
+	}
+	return append(data, fieldOfs(s.Dst, s.DstOfs)...)
+}
+
+// fieldOfs encodes a field and a bit offset as used by the learn specs.
+func fieldOfs(f *Field, ofs int) []byte {
+	data := make([]byte, 6)
+	binary.BigEndian.PutUint32(data[0:4], f.Header(false))
+	binary.BigEndian.PutUint16(data[4:6], uint16(ofs))
+	return data
+}
+
+func (s *LearnSpec) String() string {
+	dst := fieldRange(s.Dst, s.DstOfs, s.NBits)
+	if s.Load {
+		if s.Src == nil {
+			return fmt.Sprintf("load:0x%x->%s", s.Value, dst)
+		}
+		return fmt.Sprintf("load:%s->%s", fieldRange(s.Src, s.SrcOfs, s.NBits), dst)
+	}
+	if s.Src == nil {
+		if s.DstOfs == 0 && s.NBits == s.Dst.Bits() {
+			return fmt.Sprintf("%s=0x%x", s.Dst.Name, s.Value)
+		}
+		return fmt.Sprintf("%s=0x%x", dst, s.Value)
+	}
+	if s.Src == s.Dst && s.SrcOfs == s.DstOfs {
+		return dst
+	}
+	return fmt.Sprintf("%s=%s", dst, fieldRange(s.Src, s.SrcOfs, s.NBits))
+}
+
+// NXActionLearn adds or modifies a flow built from the specs and the packet.
+type NXActionLearn struct {
+	IdleTimeout uint16
+	HardTimeout uint16
+	Priority    uint16
+	Cookie      uint64
+	Flags       uint16
+	Table       uint8
+	Specs       []*LearnSpec
+}
+
+func (a *NXActionLearn) Marshal() []byte {
+	var specs []byte
+	for _, spec := range a.Specs {
+		specs = append(specs, spec.marshal()...)
+	}
+	length := 32 + len(specs)
+	// The padding also terminates the list of specs, as a spec header is never 0.
+	data := nxActionHeader(NXActionSubtypeLearn, length+pad8(length))
+	binary.BigEndian.PutUint16(data[10:12], a.IdleTimeout)
+	binary.BigEndian.PutUint16(data[12:14], a.HardTimeout)
+	binary.BigEndian.PutUint16(data[14:16], a.Priority)
+	binary.BigEndian.PutUint64(data[16:24], a.Cookie)
+	binary.BigEndian.PutUint16(data[24:26], a.Flags)
+	data[26] = a.Table
+	copy(data[32:], specs)
+	return data
+}
+
+func (a *NXActionLearn) String() string {
+	args := []string{fmt.Sprintf("table=%d", a.Table)}
+	if a.IdleTimeout != 0 {
+		args = append(args, fmt.Sprintf("idle_timeout=%d", a.IdleTimeout))
+	}
+	if a.HardTimeout != 0 {
+		args = append(args, fmt.Sprintf("hard_timeout=%d", a.HardTimeout))
+	}
+	args = append(args, fmt.Sprintf("priority=%d", a.Priority))
+	if a.Cookie != 0 {
+		args = append(args, fmt.Sprintf("cookie=0x%x", a.Cookie))
+	}
+	for _, spec := range a.Specs {
+		args = append(args, spec.String())
+	}
+	return fmt.Sprintf("learn(%s)", strings.Join(args, ","))
+}
+
+// RawAction is an action which is not decoded by this package.
+type RawAction struct {
+	Data []byte
+}
+
+func (a *RawAction) Marshal() []byte {
+	return a.Data
+}
+
+func (a *RawAction) String() string {
+	if len(a.Data) >= 10 && binary.BigEndian.Uint16(a.Data[0:2]) == ActionTypeExperimenter {
+		return fmt.Sprintf("experimenter(0x%x,subtype=%d)", binary.BigEndian.Uint32(a.Data[4:8]), binary.BigEndian.Uint16(a.Data[8:10]))
+	}
+	if len(a.Data) >= 2 {
+		return fmt.Sprintf("action(type=%d)", binary.BigEndian.Uint16(a.Data[0:2]))
+	}
+	return "action()"
+}
+
+// FormatActions formats the actions in ovs-ofctl syntax. An empty list of actions is formatted as "drop".
+func FormatActions(actions []Action) string {
+	if len(actions) == 0 {
+		return "drop"
+	}
+	reprs := make([]string, 0, len(actions))
+	for _, action := range actions {
+		reprs = append(reprs, action.String())
+	}
+	return strings.Join(reprs, ",")
+}
+
+// OpenFlow 1.3 instruction types.
+const (
+	InstructionTypeGotoTable    uint16 = 1
+	InstructionTypeApplyActions uint16 = 4
+)
+
+// Instruction is an OpenFlow instruction.
+type Instruction interface {
+	Marshal() []byte
+	// String returns the instruction in ovs-ofctl syntax.
+	String() string
+}
+
+// InstructionApplyActions applies the actions immediately.
+type InstructionApplyActions struct {
+	Actions []Action
+}
+
+func (i *InstructionApplyActions) Marshal() []byte {
+	data := make([]byte, 8)
+	for _, action := range i.Actions {
+		data = append(data, action.Marshal()...)
+	}
+	binary.BigEndian.PutUint16(data[0:2], InstructionTypeApplyActions)
+	binary.BigEndian.PutUint16(data[2:4], uint16(len(data)))
+	return data
+}
+
+func (i *InstructionApplyActions) String() string {
+	return FormatActions(i.Actions)
+}
+
+// InstructionGotoTable sends the packet to the next table.
+type InstructionGotoTable struct {
+	Table uint8
+}
+
+func (i *InstructionGotoTable) Marshal() []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint16(data[0:2], InstructionTypeGotoTable)
+	binary.BigEndian.PutUint16(data[2:4], 8)
+	data[4] = i.Table
+	return data
+}
+
+func (i *InstructionGotoTable) String() string {
+	return fmt.Sprintf("goto_table:%d", i.Table)
+}
+
+// RawInstruction is an instruction which is not decoded by this package.
+type RawInstruction struct {
+	Data []byte
+}
+
+func (i *RawInstruction) Marshal() []byte {
+	return i.Data
+}
+
+func (i *RawInstruction) String() string {
+	if len(i.Data) >= 2 {
+		return fmt.Sprintf("instruction(type=%d)", binary.BigEndian.Uint16(i.Data[0:2]))
+	}
+	return "instruction()"
+}
+
+// FormatInstructions formats the actions of the instructions in ovs-ofctl syntax.
+func FormatInstructions(instructions []Instruction) string {
+	var reprs []string
+	for _, inst := range instructions {
+		if apply, ok := inst.(*InstructionApplyActions); ok && len(apply.Actions) == 0 {
+			continue
+		}
+		reprs = append(reprs, inst.String())
+	}
+	if len(reprs) == 0 {
+		return "drop"
+	}
+	return strings.Join(reprs, ",")
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ofproto
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ONFExperimenterID is the experimenter ID of the ONF extensions. Bundles are an OpenFlow 1.4 feature which Open
+// vSwitch supports in OpenFlow 1.3 through the ONF extension messages.
+const ONFExperimenterID uint32 = 0x4f4e4600
+
+// ONF experimenter message types of the bundle extension.
+const (
+	ONFBundleControl    uint32 = 2300
+	ONFBundleAddMessage uint32 = 2301
+)
+
+// Bundle control message types.
+const (
+	BundleOpenRequest    uint16 = 0
+	BundleOpenReply      uint16 = 1
+	BundleCloseRequest   uint16 = 2
+	BundleCloseReply     uint16 = 3
+	BundleCommitRequest  uint16 = 4
+	BundleCommitReply    uint16 = 5
+	BundleDiscardRequest uint16 = 6
+	BundleDiscardReply   uint16 = 7
+)
+
+// Bundle flags.
+const (
+	BundleAtomic  uint16 = 1 << 0
+	BundleOrdered uint16 = 1 << 1
+)
+
+// xidMessage is implemented by messages whose body embeds the xid of the message itself.
+type xidMessage interface {
+	marshalBodyWithXid(xid uint32) []byte
+}
+
+// experimenterBody encodes the body of an OFPT_EXPERIMENTER message.
+func experimenterBody(experimenter, expType uint32, data []byte) []byte {
+	body := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint32(body[0:4], experimenter)
+	binary.BigEndian.PutUint32(body[4:8], expType)
+	return append(body, data...)
+}
+
+// BundleControl is a bundle control message, which opens, commits or discards a bundle.
+type BundleControl struct {
+	BundleID uint32
+	Type     uint16
+	Flags    uint16
+}
+
+func (m *BundleControl) MessageType() uint8 {
+	return TypeExperimenter
+}
+
+func (m *BundleControl) MarshalBody() []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data[0:4], m.BundleID)
+	binary.BigEndian.PutUint16(data[4:6], m.Type)
+	binary.BigEndian.PutUint16(data[6:8], m.Flags)
+	return experimenterBody(ONFExperimenterID, ONFBundleControl, data)
+}
+
+// ParseBundleControl decodes the body of a bundle control message received from the switch.
+func ParseBundleControl(body []byte) (*BundleControl, error) {
+	if len(body) < 16 {
+		return nil, fmt.Errorf("bundle control message too short: %d bytes", len(body))
+	}
+	experimenter := binary.BigEndian.Uint32(body[0:4])
+	expType := binary.BigEndian.Uint32(body[4:8])
+	if experimenter != ONFExperimenterID || expType != ONFBundleControl {
+		return nil, fmt.Errorf("not a bundle control message: experimenter 0x%x type %d", experimenter, expType)
+	}
+	return &BundleControl{
+		BundleID: binary.BigEndian.Uint32(body[8:12]),
+		Type:     binary.BigEndian.Uint16(body[12:14]),
+		Flags:    binary.BigEndian.Uint16(body[14:16]),
+	}, nil
+}
+
+// BundleAdd adds a message to an open bundle. The switch requires the embedded message to have the same xid as the
+// BundleAdd message, which Marshal takes care of.
+type BundleAdd struct {
+	BundleID uint32
+	Flags    uint16
+	Message  Message
+}
+
+func (m *BundleAdd) MessageType() uint8 {
+	return TypeExperimenter
+}
+
+func (m *BundleAdd) MarshalBody() []byte {
+	return m.marshalBodyWithXid(0)
+}
+
+func (m *BundleAdd) marshalBodyWithXid(xid uint32) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data[0:4], m.BundleID)
+	binary.BigEndian.PutUint16(data[6:8], m.Flags)
+	// The length of the embedded message is checked together with the BundleAdd message which is longer.
+	data = append(data, marshal(m.Message, xid)...)
+	return experimenterBody(ONFExperimenterID, ONFBundleAddMessage, data)
+}
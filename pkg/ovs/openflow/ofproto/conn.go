@@ -0,0 +1,315 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ofproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// DefaultRequestTimeout is the default time to wait for the reply of a request.
+const DefaultRequestTimeout = 10 * time.Second
+
+// multipartReplyMore is the flag set in multipart replies which are followed by other replies.
+const multipartReplyMore uint16 = 1
+
+// ErrConnectionClosed is returned for requests pending or issued after the connection is closed.
+var ErrConnectionClosed = errors.New("OpenFlow connection closed")
+
+// request tracks the messages sent in one call to Transact or Request.
+type request struct {
+	xids []uint32
+	// finalXid is the xid of the message whose reply completes the request.
+	finalXid uint32
+	err      error
+	replies  [][]byte
+	done     chan struct{}
+}
+
+// Conn is an OpenFlow 1.3 connection to a switch. It answers echo requests from the switch and correlates
+// replies and errors with the requests which caused them. Conn is safe for concurrent use.
+type Conn struct {
+	conn net.Conn
+	// RequestTimeout is the time to wait for the reply of a request.
+	RequestTimeout time.Duration
+
+	writeMutex sync.Mutex
+	lastXid    uint32
+
+	mutex    sync.Mutex
+	pending  map[uint32]*request
+	closed   bool
+	closeErr error
+	done     chan struct{}
+
+	features *FeaturesReply
+}
+
+// Dial connects to the switch at the given address, e.g. the Unix socket "/var/run/openvswitch/br-int.mgmt", and
+// performs the OpenFlow handshake.
+func Dial(network, address string, timeout time.Duration) (*Conn, error) {
+	c, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := NewConn(c)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// NewConn performs the OpenFlow handshake on an established transport connection and starts processing
+// messages received from the switch.
+func NewConn(c net.Conn) (*Conn, error) {
+	conn := &Conn{
+		conn:           c,
+		RequestTimeout: DefaultRequestTimeout,
+		pending:        map[uint32]*request{},
+		done:           make(chan struct{}),
+	}
+	if err := conn.hello(); err != nil {
+		return nil, err
+	}
+	go conn.receiveLoop()
+
+	replies, err := conn.Request(NewFeaturesRequest())
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to get switch features: %v", err)
+	}
+	if conn.features, err = ParseFeaturesReply(replies[0]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// hello exchanges HELLO messages with the switch and checks that OpenFlow 1.3 can be used.
+func (c *Conn) hello() error {
+	data, err := Marshal(NewHello(), c.nextXid())
+	if err != nil {
+		return err
+	}
+	if err := c.write(data); err != nil {
+		return err
+	}
+	c.conn.SetReadDeadline(time.Now().Add(DefaultRequestTimeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	header, _, err := ReadMessage(c.conn)
+	if err != nil {
+		return fmt.Errorf("failed to receive HELLO: %v", err)
+	}
+	if header.Type != TypeHello {
+		return fmt.Errorf("expected HELLO, received message type %d", header.Type)
+	}
+	if header.Version < Version13 {
+		return fmt.Errorf("switch does not support OpenFlow 1.3 (version 0x%02x)", header.Version)
+	}
+	return nil
+}
+
+// DatapathID returns the datapath ID reported by the switch during the handshake.
+func (c *Conn) DatapathID() uint64 {
+	return c.features.DatapathID
+}
+
+// Done returns a channel which is closed when the connection is closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close closes the connection. Pending requests fail with ErrConnectionClosed.
+func (c *Conn) Close() error {
+	c.shutdown(ErrConnectionClosed)
+	return nil
+}
+
+// Transact sends the messages followed by a barrier request and waits for the barrier reply. The first error
+// reported by the switch for any of the messages is returned.
+func (c *Conn) Transact(msgs ...Message) error {
+	_, err := c.send(append(msgs, NewBarrierRequest()))
+	return err
+}
+
+// Request sends the message and waits for its reply. The bodies of all reply messages are returned, there is
+// more than one for multipart replies.
+func (c *Conn) Request(msg Message) ([][]byte, error) {
+	return c.send([]Message{msg})
+}
+
+// send writes the messages and waits for the reply to the last one.
+func (c *Conn) send(msgs []Message) ([][]byte, error) {
+	req := &request{done: make(chan struct{})}
+	var data []byte
+	for _, msg := range msgs {
+		xid := c.nextXid()
+		req.xids = append(req.xids, xid)
+		msgData, err := Marshal(msg, xid)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, msgData...)
+	}
+	req.finalXid = req.xids[len(req.xids)-1]
+
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil, ErrConnectionClosed
+	}
+	for _, xid := range req.xids {
+		c.pending[xid] = req
+	}
+	c.mutex.Unlock()
+
+	if err := c.write(data); err != nil {
+		c.shutdown(err)
+		return nil, err
+	}
+
+	timer := time.NewTimer(c.RequestTimeout)
+	defer timer.Stop()
+	select {
+	case <-req.done:
+		return req.replies, req.err
+	case <-c.done:
+		return nil, c.closeErr
+	case <-timer.C:
+		c.mutex.Lock()
+		c.complete(req)
+		c.mutex.Unlock()
+		return nil, fmt.Errorf("timed out after %v waiting for reply", c.RequestTimeout)
+	}
+}
+
+func (c *Conn) nextXid() uint32 {
+	return atomic.AddUint32(&c.lastXid, 1)
+}
+
+func (c *Conn) write(data []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// complete removes the request from the pending requests and wakes up the waiting caller. c.mutex must be held.
+func (c *Conn) complete(req *request) {
+	for _, xid := range req.xids {
+		delete(c.pending, xid)
+	}
+	select {
+	case <-req.done:
+	default:
+		close(req.done)
+	}
+}
+
+func (c *Conn) shutdown(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.closeErr = err
+	c.conn.Close()
+	close(c.done)
+}
+
+func (c *Conn) receiveLoop() {
+	for {
+		header, body, err := ReadMessage(c.conn)
+		if err != nil {
+			if err == io.EOF {
+				err = ErrConnectionClosed
+			}
+			klog.V(2).Infof("OpenFlow connection terminated: %v", err)
+			c.shutdown(err)
+			return
+		}
+		c.handleMessage(header, body)
+	}
+}
+
+func (c *Conn) handleMessage(header Header, body []byte) {
+	if header.Type == TypeEchoRequest {
+		data, err := Marshal(NewEchoReply(body), header.Xid)
+		if err == nil {
+			err = c.write(data)
+		}
+		if err != nil {
+			klog.Errorf("Failed to send OpenFlow echo reply: %v", err)
+		}
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	req, ok := c.pending[header.Xid]
+	if !ok {
+		klog.V(4).Infof("Ignoring unsolicited OpenFlow message type %d xid %d", header.Type, header.Xid)
+		return
+	}
+	if header.Type == TypeError {
+		if req.err == nil {
+			errMsg, err := ParseErrorMsg(body)
+			if err != nil {
+				req.err = err
+			} else {
+				req.err = errMsg
+			}
+		}
+		if header.Xid == req.finalXid {
+			c.complete(req)
+		}
+		return
+	}
+	if header.Xid != req.finalXid {
+		return
+	}
+	req.replies = append(req.replies, body)
+	if header.Type == TypeMultipartReply && len(body) >= 4 && binary.BigEndian.Uint16(body[2:4])&multipartReplyMore != 0 {
+		return
+	}
+	c.complete(req)
+}
+
+// ReadMessage reads one OpenFlow message and returns its header and body.
+func ReadMessage(r io.Reader) (Header, []byte, error) {
+	buf := make([]byte, HeaderLen)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return Header{}, nil, err
+	}
+	header, _ := ParseHeader(buf)
+	if header.Length < HeaderLen {
+		return header, nil, fmt.Errorf("invalid message length %d", header.Length)
+	}
+	body := make([]byte, int(header.Length)-HeaderLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return header, nil, err
+	}
+	return header, body, nil
+}
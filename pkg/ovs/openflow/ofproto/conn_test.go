@@ -0,0 +1,148 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ofproto
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+const testDatapathID = 0x0000aabbccddeeff
+
+// fakeSwitch is the switch side of a connection. It completes the handshake and lets the test decide how to
+// answer the other messages.
+type fakeSwitch struct {
+	conn net.Conn
+	// handler returns the messages to send back for a received message.
+	handler func(header Header, body []byte) [][]byte
+}
+
+func reply(msgType uint8, xid uint32, body []byte) []byte {
+	return marshal(&rawMessage{msgType: msgType, body: body}, xid)
+}
+
+func (s *fakeSwitch) run() {
+	for {
+		header, body, err := ReadMessage(s.conn)
+		if err != nil {
+			return
+		}
+		var replies [][]byte
+		switch header.Type {
+		case TypeHello:
+			replies = [][]byte{reply(TypeHello, header.Xid, nil)}
+		case TypeFeaturesRequest:
+			features := make([]byte, 24)
+			binary.BigEndian.PutUint64(features[0:8], testDatapathID)
+			features[12] = 254
+			replies = [][]byte{reply(TypeFeaturesReply, header.Xid, features)}
+		case TypeBarrierRequest:
+			replies = [][]byte{reply(TypeBarrierReply, header.Xid, nil)}
+		default:
+			if s.handler != nil {
+				replies = s.handler(header, body)
+			}
+		}
+		for _, r := range replies {
+			if _, err := s.conn.Write(r); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func newTestConn(t *testing.T, handler func(header Header, body []byte) [][]byte) (*Conn, net.Conn) {
+	client, server := net.Pipe()
+	sw := &fakeSwitch{conn: server, handler: handler}
+	go sw.run()
+	conn, err := NewConn(client)
+	if err != nil {
+		t.Fatalf("Failed to establish connection: %v", err)
+	}
+	return conn, server
+}
+
+func TestHandshake(t *testing.T) {
+	conn, _ := newTestConn(t, nil)
+	defer conn.Close()
+	if conn.DatapathID() != testDatapathID {
+		t.Errorf("Expected datapath ID 0x%x, got 0x%x", testDatapathID, conn.DatapathID())
+	}
+}
+
+func TestTransact(t *testing.T) {
+	var received []uint8
+	conn, _ := newTestConn(t, func(header Header, body []byte) [][]byte {
+		received = append(received, header.Type)
+		if header.Type == TypeFlowMod && body[17] == FlowDelete {
+			errBody := make([]byte, 4)
+			binary.BigEndian.PutUint16(errBody[0:2], 5)
+			binary.BigEndian.PutUint16(errBody[2:4], 2)
+			return [][]byte{reply(TypeError, header.Xid, errBody)}
+		}
+		return nil
+	})
+	defer conn.Close()
+
+	if err := conn.Transact(NewFlowMod(FlowAdd), NewFlowMod(FlowModifyStrict)); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if len(received) != 2 {
+		t.Errorf("Expected 2 messages received by the switch, got %d", len(received))
+	}
+
+	err := conn.Transact(NewFlowMod(FlowAdd), NewFlowMod(FlowDelete))
+	errMsg, ok := err.(*ErrorMsg)
+	if !ok {
+		t.Fatalf("Expected OpenFlow error, got %v", err)
+	}
+	if errMsg.Type != 5 || errMsg.Code != 2 {
+		t.Errorf("Expected error type 5 code 2, got type %d code %d", errMsg.Type, errMsg.Code)
+	}
+}
+
+func TestEchoAndClose(t *testing.T) {
+	echoReplies := make(chan []byte, 1)
+	conn, server := newTestConn(t, func(header Header, body []byte) [][]byte {
+		if header.Type == TypeEchoReply && header.Xid == 100 {
+			echoReplies <- body
+		}
+		return nil
+	})
+
+	if _, err := server.Write(reply(TypeEchoRequest, 100, []byte("ping"))); err != nil {
+		t.Fatalf("Failed to send echo request: %v", err)
+	}
+	select {
+	case body := <-echoReplies:
+		if string(body) != "ping" {
+			t.Errorf("Expected echo reply data %q, got %q", "ping", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No echo reply received")
+	}
+
+	server.Close()
+	select {
+	case <-conn.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Connection not closed after the switch closed the transport")
+	}
+	if err := conn.Transact(NewFlowMod(FlowAdd)); err != ErrConnectionClosed {
+		t.Errorf("Expected ErrConnectionClosed, got %v", err)
+	}
+}
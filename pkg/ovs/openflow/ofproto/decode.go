@@ -0,0 +1,338 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ofproto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// fieldFromHeader returns the field of an OXM header. Fields which are not supported by this package are returned
+// with a name built from the header, so that they can still be formatted.
+func fieldFromHeader(header uint32) *Field {
+	if f, ok := FieldByHeader(header); ok {
+		return f
+	}
+	name := fmt.Sprintf("oxm(0x%08x)", header&^0x1ff)
+	length := uint8(header & 0xff)
+	if header&0x100 != 0 {
+		length /= 2
+	}
+	return newField(name, name, uint16(header>>16), uint8(header>>9&0x7f), length)
+}
+
+// ParseMatch decodes an OXM match. It returns the match and the number of bytes consumed, including the padding.
+func ParseMatch(data []byte) (Match, int, error) {
+	var m Match
+	if len(data) < 4 {
+		return m, 0, fmt.Errorf("match too short: %d bytes", len(data))
+	}
+	if matchType := binary.BigEndian.Uint16(data[0:2]); matchType != 1 {
+		return m, 0, fmt.Errorf("unsupported match type %d", matchType)
+	}
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	padded := length + pad8(length)
+	if length < 4 || padded > len(data) {
+		return m, 0, fmt.Errorf("invalid match length %d", length)
+	}
+	for oxm := data[4:length]; len(oxm) > 0; {
+		field, n, err := parseOXM(oxm)
+		if err != nil {
+			return m, 0, err
+		}
+		m.Fields = append(m.Fields, field)
+		oxm = oxm[n:]
+	}
+	return m, padded, nil
+}
+
+// parseOXM decodes the OXM TLV at the start of data. It returns the field and the length of the TLV.
+func parseOXM(data []byte) (MatchField, int, error) {
+	if len(data) < 4 {
+		return MatchField{}, 0, fmt.Errorf("truncated OXM header")
+	}
+	header := binary.BigEndian.Uint32(data[0:4])
+	payloadLen := int(header & 0xff)
+	if 4+payloadLen > len(data) {
+		return MatchField{}, 0, fmt.Errorf("truncated OXM field 0x%08x", header)
+	}
+	payload := data[4 : 4+payloadLen]
+	field := MatchField{Field: fieldFromHeader(header)}
+	if header&0x100 != 0 {
+		field.Value, field.Mask = payload[:payloadLen/2], payload[payloadLen/2:]
+	} else {
+		field.Value = payload
+	}
+	return field, 4 + payloadLen, nil
+}
+
+// ParseActions decodes a list of actions. Actions which are not supported by this package are returned as
+// RawAction.
+func ParseActions(data []byte) ([]Action, error) {
+	var actions []Action
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("truncated action header")
+		}
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 8 || length > len(data) {
+			return nil, fmt.Errorf("invalid action length %d", length)
+		}
+		action, err := parseAction(data[:length])
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+		data = data[length:]
+	}
+	return actions, nil
+}
+
+func parseAction(data []byte) (Action, error) {
+	switch binary.BigEndian.Uint16(data[0:2]) {
+	case ActionTypeOutput:
+		if len(data) < 16 {
+			break
+		}
+		return &ActionOutput{Port: binary.BigEndian.Uint32(data[4:8]), MaxLen: binary.BigEndian.Uint16(data[8:10])}, nil
+	case ActionTypeGroup:
+		return &ActionGroup{GroupID: binary.BigEndian.Uint32(data[4:8])}, nil
+	case ActionTypeDecNwTTL:
+		return &ActionDecNwTTL{}, nil
+	case ActionTypeSetField:
+		field, _, err := parseOXM(data[4:])
+		if err != nil {
+			break
+		}
+		return &ActionSetField{Field: field}, nil
+	case ActionTypeExperimenter:
+		if len(data) < 16 || binary.BigEndian.Uint32(data[4:8]) != NiciraExperimenterID {
+			break
+		}
+		return parseNXAction(data)
+	}
+	return &RawAction{Data: data}, nil
+}
+
+// parseOfsNbits decodes a bit range encoded by ofsNbits.
+func parseOfsNbits(v uint16) (ofs, nbits int) {
+	return int(v >> 6), int(v&0x3f) + 1
+}
+
+func parseNXAction(data []byte) (Action, error) {
+	switch binary.BigEndian.Uint16(data[8:10]) {
+	case NXActionSubtypeResubmitTable:
+		return &NXActionResubmitTable{InPort: binary.BigEndian.Uint16(data[10:12]), Table: data[12]}, nil
+	case NXActionSubtypeRegLoad:
+		if len(data) < 24 {
+			break
+		}
+		ofs, nbits := parseOfsNbits(binary.BigEndian.Uint16(data[10:12]))
+		return &NXActionRegLoad{
+			Dst:   fieldFromHeader(binary.BigEndian.Uint32(data[12:16])),
+			Ofs:   ofs,
+			NBits: nbits,
+			Value: binary.BigEndian.Uint64(data[16:24]),
+		}, nil
+	case NXActionSubtypeRegMove:
+		if len(data) < 24 {
+			break
+		}
+		return &NXActionRegMove{
+			NBits:  int(binary.BigEndian.Uint16(data[10:12])),
+			SrcOfs: int(binary.BigEndian.Uint16(data[12:14])),
+			DstOfs: int(binary.BigEndian.Uint16(data[14:16])),
+			Src:    fieldFromHeader(binary.BigEndian.Uint32(data[16:20])),
+			Dst:    fieldFromHeader(binary.BigEndian.Uint32(data[20:24])),
+		}, nil
+	case NXActionSubtypeOutputReg:
+		if len(data) < 24 {
+			break
+		}
+		ofs, nbits := parseOfsNbits(binary.BigEndian.Uint16(data[10:12]))
+		return &NXActionOutputReg{
+			Src:    fieldFromHeader(binary.BigEndian.Uint32(data[12:16])),
+			Ofs:    ofs,
+			NBits:  nbits,
+			MaxLen: binary.BigEndian.Uint16(data[16:18]),
+		}, nil
+	case NXActionSubtypeConjunction:
+		return &NXActionConjunction{
+			Clause:   data[10] + 1,
+			NClauses: data[11],
+			ID:       binary.BigEndian.Uint32(data[12:16]),
+		}, nil
+	case NXActionSubtypeConntrack:
+		if len(data) < 24 {
+			break
+		}
+		nested, err := ParseActions(data[24:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid actions in ct action: %v", err)
+		}
+		return &NXActionConntrack{
+			Flags:       binary.BigEndian.Uint16(data[10:12]),
+			Zone:        binary.BigEndian.Uint16(data[16:18]),
+			RecircTable: data[18],
+			Alg:         binary.BigEndian.Uint16(data[22:24]),
+			Actions:     nested,
+		}, nil
+	case NXActionSubtypeNAT:
+		if action, ok := parseNXActionNAT(data); ok {
+			return action, nil
+		}
+	case NXActionSubtypeLearn:
+		if action, ok := parseNXActionLearn(data); ok {
+			return action, nil
+		}
+	}
+	return &RawAction{Data: data}, nil
+}
+
+// parseNXActionNAT decodes a NAT action. It returns false if the action is truncated.
+func parseNXActionNAT(data []byte) (*NXActionNAT, bool) {
+	action := &NXActionNAT{Flags: binary.BigEndian.Uint16(data[12:14])}
+	rangePresent := binary.BigEndian.Uint16(data[14:16])
+	params := data[16:]
+	next := func(n int) []byte {
+		if len(params) < n {
+			return nil
+		}
+		b := params[:n]
+		params = params[n:]
+		return b
+	}
+	for _, p := range []struct {
+		bit uint16
+		len int
+		ip  *net.IP
+	}{
+		{nxNATRangeIPv4Min, 4, &action.IPMin},
+		{nxNATRangeIPv4Max, 4, &action.IPMax},
+		{nxNATRangeIPv6Min, 16, &action.IPMin},
+		{nxNATRangeIPv6Max, 16, &action.IPMax},
+	} {
+		if rangePresent&p.bit == 0 {
+			continue
+		}
+		b := next(p.len)
+		if b == nil {
+			return nil, false
+		}
+		*p.ip = net.IP(append([]byte(nil), b...))
+	}
+	for _, p := range []struct {
+		bit  uint16
+		port *uint16
+	}{{nxNATRangeProtoMin, &action.PortMin}, {nxNATRangeProtoMax, &action.PortMax}} {
+		if rangePresent&p.bit == 0 {
+			continue
+		}
+		b := next(2)
+		if b == nil {
+			return nil, false
+		}
+		*p.port = binary.BigEndian.Uint16(b)
+	}
+	return action, true
+}
+
+// parseNXActionLearn decodes a learn action. It returns false if the action is truncated or has specs which are not
+// supported by LearnSpec.
+func parseNXActionLearn(data []byte) (*NXActionLearn, bool) {
+	if len(data) < 32 {
+		return nil, false
+	}
+	action := &NXActionLearn{
+		IdleTimeout: binary.BigEndian.Uint16(data[10:12]),
+		HardTimeout: binary.BigEndian.Uint16(data[12:14]),
+		Priority:    binary.BigEndian.Uint16(data[14:16]),
+		Cookie:      binary.BigEndian.Uint64(data[16:24]),
+		Flags:       binary.BigEndian.Uint16(data[24:26]),
+		Table:       data[26],
+	}
+	specs := data[32:]
+	parseFieldOfs := func() (*Field, int, bool) {
+		if len(specs) < 6 {
+			return nil, 0, false
+		}
+		f, ofs := fieldFromHeader(binary.BigEndian.Uint32(specs[0:4])), int(binary.BigEndian.Uint16(specs[4:6]))
+		specs = specs[6:]
+		return f, ofs, true
+	}
+	for len(specs) >= 2 {
+		header := binary.BigEndian.Uint16(specs[0:2])
+		if header == 0 {
+			break
+		}
+		specs = specs[2:]
+		// Only the match and load destinations are supported, not output.
+		if header>>11&0x3 > 1 {
+			return nil, false
+		}
+		spec := &LearnSpec{NBits: int(header & nxLearnNBitsMask), Load: header&nxLearnDstLoad != 0}
+		if header&nxLearnSrcImmediate != 0 {
+			n := (spec.NBits + 15) / 16 * 2
+			if n > 8 || len(specs) < n {
+				return nil, false
+			}
+			for _, b := range specs[:n] {
+				spec.Value = spec.Value<<8 | uint64(b)
+			}
+			specs = specs[n:]
+		} else {
+			var ok bool
+			if spec.Src, spec.SrcOfs, ok = parseFieldOfs(); !ok {
+				return nil, false
+			}
+		}
+		var ok bool
+		if spec.Dst, spec.DstOfs, ok = parseFieldOfs(); !ok {
+			return nil, false
+		}
+		action.Specs = append(action.Specs, spec)
+	}
+	return action, true
+}
+
+// ParseInstructions decodes a list of instructions. Instructions which are not supported by this package are
+// returned as RawInstruction.
+func ParseInstructions(data []byte) ([]Instruction, error) {
+	var instructions []Instruction
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("truncated instruction header")
+		}
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 8 || length > len(data) {
+			return nil, fmt.Errorf("invalid instruction length %d", length)
+		}
+		switch binary.BigEndian.Uint16(data[0:2]) {
+		case InstructionTypeApplyActions:
+			actions, err := ParseActions(data[8:length])
+			if err != nil {
+				return nil, err
+			}
+			instructions = append(instructions, &InstructionApplyActions{Actions: actions})
+		case InstructionTypeGotoTable:
+			instructions = append(instructions, &InstructionGotoTable{Table: data[4]})
+		default:
+			instructions = append(instructions, &RawInstruction{Data: data[:length]})
+		}
+		data = data[length:]
+	}
+	return instructions, nil
+}
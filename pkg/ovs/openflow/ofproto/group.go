@@ -0,0 +1,162 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ofproto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// GroupMod commands.
+const (
+	GroupAdd    uint16 = 0
+	GroupModify uint16 = 1
+	GroupDelete uint16 = 2
+)
+
+// Group types.
+const (
+	GroupTypeAll      uint8 = 0
+	GroupTypeSelect   uint8 = 1
+	GroupTypeIndirect uint8 = 2
+)
+
+// GroupAll is the group ID matching all the groups in a GroupMod deletion.
+const GroupAll uint32 = 0xfffffffc
+
+var groupTypeNames = map[uint8]string{
+	GroupTypeAll:      "all",
+	GroupTypeSelect:   "select",
+	GroupTypeIndirect: "indirect",
+}
+
+// bucketLen is the length of the fixed part of a bucket, before the actions.
+const bucketLen = 16
+
+// Bucket is a list of actions of a group. Weight is only relevant for select groups.
+type Bucket struct {
+	Weight     uint16
+	WatchPort  uint32
+	WatchGroup uint32
+	Actions    []Action
+}
+
+// NewBucket returns a Bucket with the given weight, which does not watch any port or group.
+func NewBucket(weight uint16) *Bucket {
+	return &Bucket{
+		Weight:     weight,
+		WatchPort:  PortAny,
+		WatchGroup: GroupAny,
+	}
+}
+
+func (b *Bucket) Marshal() []byte {
+	data := make([]byte, bucketLen)
+	binary.BigEndian.PutUint16(data[2:4], b.Weight)
+	binary.BigEndian.PutUint32(data[4:8], b.WatchPort)
+	binary.BigEndian.PutUint32(data[8:12], b.WatchGroup)
+	for _, action := range b.Actions {
+		data = append(data, action.Marshal()...)
+	}
+	binary.BigEndian.PutUint16(data[0:2], uint16(len(data)))
+	return data
+}
+
+// String returns the bucket in ovs-ofctl syntax.
+func (b *Bucket) String() string {
+	return fmt.Sprintf("bucket=weight:%d,actions=%s", b.Weight, FormatActions(b.Actions))
+}
+
+// GroupMod is an OFPT_GROUP_MOD message.
+type GroupMod struct {
+	Command uint16
+	Type    uint8
+	GroupID uint32
+	Buckets []*Bucket
+}
+
+func (m *GroupMod) MessageType() uint8 {
+	return TypeGroupMod
+}
+
+func (m *GroupMod) MarshalBody() []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint16(data[0:2], m.Command)
+	data[2] = m.Type
+	binary.BigEndian.PutUint32(data[4:8], m.GroupID)
+	for _, bucket := range m.Buckets {
+		data = append(data, bucket.Marshal()...)
+	}
+	return data
+}
+
+// String returns the group in ovs-ofctl syntax.
+func (m *GroupMod) String() string {
+	name, ok := groupTypeNames[m.Type]
+	if !ok {
+		name = fmt.Sprintf("type(%d)", m.Type)
+	}
+	reprs := []string{fmt.Sprintf("group_id=%d", m.GroupID), "type=" + name}
+	for _, bucket := range m.Buckets {
+		reprs = append(reprs, bucket.String())
+	}
+	return strings.Join(reprs, ",")
+}
+
+// ParseGroupMod decodes the body of an OFPT_GROUP_MOD message.
+func ParseGroupMod(body []byte) (*GroupMod, error) {
+	if len(body) < 8 {
+		return nil, fmt.Errorf("group mod too short: %d bytes", len(body))
+	}
+	m := &GroupMod{
+		Command: binary.BigEndian.Uint16(body[0:2]),
+		Type:    body[2],
+		GroupID: binary.BigEndian.Uint32(body[4:8]),
+	}
+	buckets, err := parseBuckets(body[8:])
+	if err != nil {
+		return nil, err
+	}
+	m.Buckets = buckets
+	return m, nil
+}
+
+// parseBuckets decodes the buckets of a group, which are encoded the same way in a GroupMod and in a group
+// description.
+func parseBuckets(data []byte) ([]*Bucket, error) {
+	var buckets []*Bucket
+	for len(data) > 0 {
+		if len(data) < bucketLen {
+			return nil, fmt.Errorf("truncated bucket")
+		}
+		length := int(binary.BigEndian.Uint16(data[0:2]))
+		if length < bucketLen || length > len(data) {
+			return nil, fmt.Errorf("invalid bucket length %d", length)
+		}
+		actions, err := ParseActions(data[bucketLen:length])
+		if err != nil {
+			return nil, fmt.Errorf("invalid actions in bucket: %v", err)
+		}
+		buckets = append(buckets, &Bucket{
+			Weight:     binary.BigEndian.Uint16(data[2:4]),
+			WatchPort:  binary.BigEndian.Uint32(data[4:8]),
+			WatchGroup: binary.BigEndian.Uint32(data[8:12]),
+			Actions:    actions,
+		})
+		data = data[length:]
+	}
+	return buckets, nil
+}
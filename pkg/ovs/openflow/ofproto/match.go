@@ -0,0 +1,362 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ofproto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+)
+
+// OXM classes.
+const (
+	ClassNXM0     uint16 = 0x0000
+	ClassNXM1     uint16 = 0x0001
+	ClassOpenFlow uint16 = 0x8000
+)
+
+// Field describes a match field which can be used in OXM matches and as source or destination of the Nicira
+// register actions.
+type Field struct {
+	// Name is the name used by ovs-ofctl, e.g. "dl_src".
+	Name string
+	// NXMName is the Nicira extensible match name, e.g. "NXM_OF_ETH_SRC".
+	NXMName string
+	Class   uint16
+	ID      uint8
+	// Length is the length of the field value in bytes.
+	Length uint8
+}
+
+// Header returns the 32-bit OXM header of the field.
+func (f *Field) Header(hasMask bool) uint32 {
+	length := uint32(f.Length)
+	var maskBit uint32
+	if hasMask {
+		length *= 2
+		maskBit = 1
+	}
+	return uint32(f.Class)<<16 | uint32(f.ID)<<9 | maskBit<<8 | length
+}
+
+// Bits returns the width of the field in bits.
+func (f *Field) Bits() int {
+	return int(f.Length) * 8
+}
+
+func newField(name, nxmName string, class uint16, id, length uint8) *Field {
+	return &Field{Name: name, NXMName: nxmName, Class: class, ID: id, Length: length}
+}
+
+// Match fields supported by this package. Standard fields use the OpenFlow basic class, other fields use the
+// Nicira extension classes.
+var (
+	FieldInPort     = newField("in_port", "NXM_OF_IN_PORT", ClassOpenFlow, 0, 4)
+	FieldEthDst     = newField("dl_dst", "NXM_OF_ETH_DST", ClassOpenFlow, 3, 6)
+	FieldEthSrc     = newField("dl_src", "NXM_OF_ETH_SRC", ClassOpenFlow, 4, 6)
+	FieldEthType    = newField("dl_type", "NXM_OF_ETH_TYPE", ClassOpenFlow, 5, 2)
+	FieldIPProto    = newField("nw_proto", "NXM_OF_IP_PROTO", ClassOpenFlow, 10, 1)
+	FieldIPv4Src    = newField("nw_src", "NXM_OF_IP_SRC", ClassOpenFlow, 11, 4)
+	FieldIPv4Dst    = newField("nw_dst", "NXM_OF_IP_DST", ClassOpenFlow, 12, 4)
+	FieldTCPSrc     = newField("tcp_src", "NXM_OF_TCP_SRC", ClassOpenFlow, 13, 2)
+	FieldTCPDst     = newField("tcp_dst", "NXM_OF_TCP_DST", ClassOpenFlow, 14, 2)
+	FieldUDPSrc     = newField("udp_src", "NXM_OF_UDP_SRC", ClassOpenFlow, 15, 2)
+	FieldUDPDst     = newField("udp_dst", "NXM_OF_UDP_DST", ClassOpenFlow, 16, 2)
+	FieldSCTPSrc    = newField("sctp_src", "OXM_OF_SCTP_SRC", ClassOpenFlow, 17, 2)
+	FieldSCTPDst    = newField("sctp_dst", "OXM_OF_SCTP_DST", ClassOpenFlow, 18, 2)
+	FieldICMPType   = newField("icmp_type", "NXM_OF_ICMP_TYPE", ClassOpenFlow, 19, 1)
+	FieldICMPCode   = newField("icmp_code", "NXM_OF_ICMP_CODE", ClassOpenFlow, 20, 1)
+	FieldARPOp      = newField("arp_op", "NXM_OF_ARP_OP", ClassOpenFlow, 21, 2)
+	FieldARPSpa     = newField("arp_spa", "NXM_OF_ARP_SPA", ClassOpenFlow, 22, 4)
+	FieldARPTpa     = newField("arp_tpa", "NXM_OF_ARP_TPA", ClassOpenFlow, 23, 4)
+	FieldARPSha     = newField("arp_sha", "NXM_NX_ARP_SHA", ClassOpenFlow, 24, 6)
+	FieldARPTha     = newField("arp_tha", "NXM_NX_ARP_THA", ClassOpenFlow, 25, 6)
+	FieldIPv6Src    = newField("ipv6_src", "NXM_NX_IPV6_SRC", ClassOpenFlow, 26, 16)
+	FieldIPv6Dst    = newField("ipv6_dst", "NXM_NX_IPV6_DST", ClassOpenFlow, 27, 16)
+	FieldICMPv6Type = newField("icmpv6_type", "NXM_NX_ICMPV6_TYPE", ClassOpenFlow, 29, 1)
+	FieldICMPv6Code = newField("icmpv6_code", "NXM_NX_ICMPV6_CODE", ClassOpenFlow, 30, 1)
+	FieldNDTarget   = newField("nd_target", "NXM_NX_ND_TARGET", ClassOpenFlow, 31, 16)
+	FieldNDSll      = newField("nd_sll", "NXM_NX_ND_SLL", ClassOpenFlow, 32, 6)
+	FieldNDTll      = newField("nd_tll", "NXM_NX_ND_TLL", ClassOpenFlow, 33, 6)
+	FieldTunIPv4Src = newField("tun_src", "NXM_NX_TUN_IPV4_SRC", ClassNXM1, 31, 4)
+	FieldTunIPv4Dst = newField("tun_dst", "NXM_NX_TUN_IPV4_DST", ClassNXM1, 32, 4)
+	FieldPktMark    = newField("pkt_mark", "NXM_NX_PKT_MARK", ClassNXM1, 33, 4)
+	FieldConjID     = newField("conj_id", "NXM_NX_CONJ_ID", ClassNXM1, 84, 4)
+	FieldCtState    = newField("ct_state", "NXM_NX_CT_STATE", ClassNXM1, 105, 4)
+	FieldCtZone     = newField("ct_zone", "NXM_NX_CT_ZONE", ClassNXM1, 106, 2)
+	FieldCtMark     = newField("ct_mark", "NXM_NX_CT_MARK", ClassNXM1, 107, 4)
+	FieldCtLabel    = newField("ct_label", "NXM_NX_CT_LABEL", ClassNXM1, 108, 16)
+	FieldTunIPv6Src = newField("tun_ipv6_src", "NXM_NX_TUN_IPV6_SRC", ClassNXM1, 109, 16)
+	FieldTunIPv6Dst = newField("tun_ipv6_dst", "NXM_NX_TUN_IPV6_DST", ClassNXM1, 110, 16)
+)
+
+var (
+	fieldsByName   = map[string]*Field{}
+	fieldsByHeader = map[uint32]*Field{}
+)
+
+func registerField(f *Field, aliases ...string) {
+	fieldsByName[f.Name] = f
+	fieldsByName[strings.ToLower(f.NXMName)] = f
+	for _, alias := range aliases {
+		fieldsByName[alias] = f
+	}
+	fieldsByHeader[f.Header(false)&^0x1ff] = f
+}
+
+func init() {
+	registerField(FieldInPort)
+	registerField(FieldEthDst, "eth_dst")
+	registerField(FieldEthSrc, "eth_src")
+	registerField(FieldEthType, "eth_type")
+	registerField(FieldIPProto, "ip_proto")
+	registerField(FieldIPv4Src, "ip_src")
+	registerField(FieldIPv4Dst, "ip_dst")
+	registerField(FieldTCPSrc)
+	registerField(FieldTCPDst)
+	registerField(FieldUDPSrc)
+	registerField(FieldUDPDst)
+	registerField(FieldSCTPSrc, "sct_src")
+	registerField(FieldSCTPDst, "sct_dst")
+	registerField(FieldICMPType, "icmpv4_type")
+	registerField(FieldICMPCode, "icmpv4_code")
+	registerField(FieldARPOp)
+	registerField(FieldARPSpa)
+	registerField(FieldARPTpa)
+	registerField(FieldARPSha)
+	registerField(FieldARPTha)
+	registerField(FieldIPv6Src)
+	registerField(FieldIPv6Dst)
+	registerField(FieldICMPv6Type)
+	registerField(FieldICMPv6Code)
+	registerField(FieldNDTarget)
+	registerField(FieldNDSll)
+	registerField(FieldNDTll)
+	registerField(FieldTunIPv4Src)
+	registerField(FieldTunIPv4Dst)
+	registerField(FieldPktMark)
+	registerField(FieldConjID)
+	registerField(FieldCtState)
+	registerField(FieldCtZone)
+	registerField(FieldCtMark)
+	registerField(FieldCtLabel)
+	registerField(FieldTunIPv6Src)
+	registerField(FieldTunIPv6Dst)
+	for i := 0; i < 16; i++ {
+		registerField(FieldReg(i))
+	}
+}
+
+var regFields = func() []*Field {
+	fields := make([]*Field, 16)
+	for i := range fields {
+		fields[i] = newField(fmt.Sprintf("reg%d", i), fmt.Sprintf("NXM_NX_REG%d", i), ClassNXM1, uint8(i), 4)
+	}
+	return fields
+}()
+
+// FieldReg returns the field of the Nicira register with the given index.
+func FieldReg(id int) *Field {
+	return regFields[id]
+}
+
+// FieldByName looks up a field by its ovs-ofctl name or its NXM name. The lookup is case-insensitive.
+func FieldByName(name string) (*Field, error) {
+	if f, ok := fieldsByName[strings.ToLower(name)]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported field %q", name)
+}
+
+// FieldByHeader looks up a field by its OXM header. The has-mask bit and the length of the header are ignored.
+func FieldByHeader(header uint32) (*Field, bool) {
+	f, ok := fieldsByHeader[header&^0x1ff]
+	return f, ok
+}
+
+// MatchField is a single OXM TLV. Mask is nil for an exact match.
+type MatchField struct {
+	Field *Field
+	Value []byte
+	Mask  []byte
+}
+
+// Marshal encodes the OXM TLV.
+func (m *MatchField) Marshal() []byte {
+	data := make([]byte, 4, 4+len(m.Value)+len(m.Mask))
+	binary.BigEndian.PutUint32(data, m.Field.Header(m.Mask != nil))
+	data = append(data, m.Value...)
+	return append(data, m.Mask...)
+}
+
+// ValueString formats the value of the match field in ovs-ofctl syntax, e.g. "10.0.0.0/24" or "0x1/0xffff".
+func (m *MatchField) ValueString() string {
+	switch {
+	case m.Field.Length == 6:
+		value := net.HardwareAddr(m.Value).String()
+		if m.Mask != nil {
+			return value + "/" + net.HardwareAddr(m.Mask).String()
+		}
+		return value
+	case ipFields[m.Field]:
+		value := net.IP(m.Value).String()
+		if m.Mask != nil {
+			// Contiguous masks are formatted as prefix lengths, like ovs-ofctl does.
+			if ones, bits := net.IPMask(m.Mask).Size(); bits != 0 {
+				return fmt.Sprintf("%s/%d", value, ones)
+			}
+			return value + "/" + net.IP(m.Mask).String()
+		}
+		return value
+	case decimalFields[m.Field] && m.Mask == nil:
+		return new(big.Int).SetBytes(m.Value).String()
+	}
+	value := fmt.Sprintf("0x%x", new(big.Int).SetBytes(m.Value))
+	if m.Mask != nil {
+		value += fmt.Sprintf("/0x%x", new(big.Int).SetBytes(m.Mask))
+	}
+	return value
+}
+
+// String formats the match field in ovs-ofctl syntax, e.g. "reg0=0x1/0xffff".
+func (m *MatchField) String() string {
+	return m.Field.Name + "=" + m.ValueString()
+}
+
+// ipFields are the fields formatted as IP addresses.
+var ipFields = map[*Field]bool{
+	FieldIPv4Src:    true,
+	FieldIPv4Dst:    true,
+	FieldARPSpa:     true,
+	FieldARPTpa:     true,
+	FieldTunIPv4Src: true,
+	FieldTunIPv4Dst: true,
+	FieldIPv6Src:    true,
+	FieldIPv6Dst:    true,
+	FieldNDTarget:   true,
+	FieldTunIPv6Src: true,
+	FieldTunIPv6Dst: true,
+}
+
+// decimalFields are the fields formatted as decimal numbers when they are matched exactly.
+var decimalFields = map[*Field]bool{
+	FieldInPort:     true,
+	FieldIPProto:    true,
+	FieldTCPSrc:     true,
+	FieldTCPDst:     true,
+	FieldUDPSrc:     true,
+	FieldUDPDst:     true,
+	FieldSCTPSrc:    true,
+	FieldSCTPDst:    true,
+	FieldICMPType:   true,
+	FieldICMPCode:   true,
+	FieldARPOp:      true,
+	FieldICMPv6Type: true,
+	FieldICMPv6Code: true,
+	FieldConjID:     true,
+	FieldCtZone:     true,
+}
+
+// NewMatchField returns an exact match on the field with the given value, which is left-padded to the field length.
+func NewMatchField(f *Field, value []byte) MatchField {
+	return MatchField{Field: f, Value: fit(value, int(f.Length))}
+}
+
+// NewMaskedMatchField returns a masked match on the field.
+func NewMaskedMatchField(f *Field, value, mask []byte) MatchField {
+	return MatchField{Field: f, Value: fit(value, int(f.Length)), Mask: fit(mask, int(f.Length))}
+}
+
+// NewUintMatchField returns an exact match on the field with an integer value.
+func NewUintMatchField(f *Field, value uint64) MatchField {
+	return MatchField{Field: f, Value: UintBytes(value, int(f.Length))}
+}
+
+// NewRangeMatchField returns a match on the bits [ofs, ofs+nbits) of the field. Bit 0 is the least significant bit.
+func NewRangeMatchField(f *Field, value uint64, ofs, nbits int) MatchField {
+	length := int(f.Length)
+	v := make([]byte, length)
+	m := make([]byte, length)
+	SetBits(v, ofs, nbits, value)
+	SetBits(m, ofs, nbits, ^uint64(0))
+	if ofs == 0 && nbits == length*8 {
+		return MatchField{Field: f, Value: v}
+	}
+	return MatchField{Field: f, Value: v, Mask: m}
+}
+
+// fit left-pads or truncates the big-endian value to n bytes.
+func fit(value []byte, n int) []byte {
+	if len(value) >= n {
+		return value[len(value)-n:]
+	}
+	data := make([]byte, n)
+	copy(data[n-len(value):], value)
+	return data
+}
+
+// UintBytes encodes the value as a big-endian integer of n bytes.
+func UintBytes(value uint64, n int) []byte {
+	data := make([]byte, n)
+	for i := n - 1; i >= 0 && value != 0; i-- {
+		data[i] = byte(value)
+		value >>= 8
+	}
+	return data
+}
+
+// SetBits writes the nbits low-order bits of value to the bits [ofs, ofs+nbits) of the big-endian buffer. Bit 0
+// is the least significant bit of the buffer.
+func SetBits(buf []byte, ofs, nbits int, value uint64) {
+	for i := 0; i < nbits && i < 64; i++ {
+		bit := ofs + i
+		idx := len(buf) - 1 - bit/8
+		if idx < 0 {
+			return
+		}
+		if value&(1<<uint(i)) != 0 {
+			buf[idx] |= 1 << uint(bit%8)
+		} else {
+			buf[idx] &^= 1 << uint(bit%8)
+		}
+	}
+}
+
+// Match is an OpenFlow extensible match (ofp_match of type OFPMT_OXM).
+type Match struct {
+	Fields []MatchField
+}
+
+// Marshal encodes the match, including the trailing padding.
+func (m *Match) Marshal() []byte {
+	data := make([]byte, 4)
+	for i := range m.Fields {
+		data = append(data, m.Fields[i].Marshal()...)
+	}
+	binary.BigEndian.PutUint16(data[0:2], 1)
+	binary.BigEndian.PutUint16(data[2:4], uint16(len(data)))
+	return append(data, make([]byte, pad8(len(data)))...)
+}
+
+// String formats the match in ovs-ofctl syntax.
+func (m *Match) String() string {
+	reprs := make([]string, 0, len(m.Fields))
+	for i := range m.Fields {
+		reprs = append(reprs, m.Fields[i].String())
+	}
+	return strings.Join(reprs, ",")
+}
@@ -0,0 +1,288 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ofproto implements the subset of the OpenFlow 1.3 wire protocol and the Nicira extensions which is
+// required to program an Open vSwitch bridge through its management socket.
+package ofproto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Version13 is the OpenFlow protocol version number negotiated with the switch.
+const Version13 uint8 = 0x04
+
+// HeaderLen is the length of the OpenFlow message header.
+const HeaderLen = 8
+
+// OpenFlow 1.3 message types.
+const (
+	TypeHello            uint8 = 0
+	TypeError            uint8 = 1
+	TypeEchoRequest      uint8 = 2
+	TypeEchoReply        uint8 = 3
+	TypeExperimenter     uint8 = 4
+	TypeFeaturesRequest  uint8 = 5
+	TypeFeaturesReply    uint8 = 6
+	TypeFlowMod          uint8 = 14
+	TypeGroupMod         uint8 = 15
+	TypeMultipartRequest uint8 = 18
+	TypeMultipartReply   uint8 = 19
+	TypeBarrierRequest   uint8 = 20
+	TypeBarrierReply     uint8 = 21
+)
+
+// FlowMod commands.
+const (
+	FlowAdd          uint8 = 0
+	FlowModify       uint8 = 1
+	FlowModifyStrict uint8 = 2
+	FlowDelete       uint8 = 3
+	FlowDeleteStrict uint8 = 4
+)
+
+// Reserved port, group and buffer numbers.
+const (
+	PortInPort uint32 = 0xfffffff8
+	PortNormal uint32 = 0xfffffffa
+	PortAny    uint32 = 0xffffffff
+	GroupAny   uint32 = 0xffffffff
+	NoBuffer   uint32 = 0xffffffff
+)
+
+// Header is the fixed header of every OpenFlow message.
+type Header struct {
+	Version uint8
+	Type    uint8
+	Length  uint16
+	Xid     uint32
+}
+
+// ParseHeader decodes an OpenFlow message header.
+func ParseHeader(data []byte) (Header, error) {
+	if len(data) < HeaderLen {
+		return Header{}, fmt.Errorf("message too short: %d bytes", len(data))
+	}
+	return Header{
+		Version: data[0],
+		Type:    data[1],
+		Length:  binary.BigEndian.Uint16(data[2:4]),
+		Xid:     binary.BigEndian.Uint32(data[4:8]),
+	}, nil
+}
+
+// Message is an OpenFlow message which can be sent to the switch.
+type Message interface {
+	// MessageType returns the OpenFlow message type.
+	MessageType() uint8
+	// MarshalBody returns the encoded message without the OpenFlow header.
+	MarshalBody() []byte
+}
+
+// Marshal encodes the message together with its OpenFlow header. An error is returned if the encoded message
+// does not fit in the 16-bit length field of the header.
+func Marshal(msg Message, xid uint32) ([]byte, error) {
+	data := marshal(msg, xid)
+	if len(data) > math.MaxUint16 {
+		return nil, fmt.Errorf("message type %d too long: %d bytes", msg.MessageType(), len(data))
+	}
+	return data, nil
+}
+
+// marshal encodes the message together with its OpenFlow header without checking its length.
+func marshal(msg Message, xid uint32) []byte {
+	var body []byte
+	if m, ok := msg.(xidMessage); ok {
+		body = m.marshalBodyWithXid(xid)
+	} else {
+		body = msg.MarshalBody()
+	}
+	data := make([]byte, HeaderLen, HeaderLen+len(body))
+	data[0] = Version13
+	data[1] = msg.MessageType()
+	binary.BigEndian.PutUint16(data[2:4], uint16(HeaderLen+len(body)))
+	binary.BigEndian.PutUint32(data[4:8], xid)
+	return append(data, body...)
+}
+
+// rawMessage is a Message with a fixed type and body.
+type rawMessage struct {
+	msgType uint8
+	body    []byte
+}
+
+func (m *rawMessage) MessageType() uint8 {
+	return m.msgType
+}
+
+func (m *rawMessage) MarshalBody() []byte {
+	return m.body
+}
+
+// NewRawMessage returns a message of the given type with a pre-encoded body.
+func NewRawMessage(msgType uint8, body []byte) Message {
+	return &rawMessage{msgType: msgType, body: body}
+}
+
+// NewHello returns an OFPT_HELLO message.
+func NewHello() Message {
+	return &rawMessage{msgType: TypeHello}
+}
+
+// NewEchoRequest returns an OFPT_ECHO_REQUEST message.
+func NewEchoRequest() Message {
+	return &rawMessage{msgType: TypeEchoRequest}
+}
+
+// NewEchoReply returns an OFPT_ECHO_REPLY message carrying the data of the request.
+func NewEchoReply(data []byte) Message {
+	return &rawMessage{msgType: TypeEchoReply, body: data}
+}
+
+// NewFeaturesRequest returns an OFPT_FEATURES_REQUEST message.
+func NewFeaturesRequest() Message {
+	return &rawMessage{msgType: TypeFeaturesRequest}
+}
+
+// NewBarrierRequest returns an OFPT_BARRIER_REQUEST message.
+func NewBarrierRequest() Message {
+	return &rawMessage{msgType: TypeBarrierRequest}
+}
+
+// FeaturesReply is the body of an OFPT_FEATURES_REPLY message.
+type FeaturesReply struct {
+	DatapathID   uint64
+	NBuffers     uint32
+	NTables      uint8
+	AuxiliaryID  uint8
+	Capabilities uint32
+}
+
+// ParseFeaturesReply decodes the body of an OFPT_FEATURES_REPLY message.
+func ParseFeaturesReply(body []byte) (*FeaturesReply, error) {
+	if len(body) < 24 {
+		return nil, fmt.Errorf("features reply too short: %d bytes", len(body))
+	}
+	return &FeaturesReply{
+		DatapathID:   binary.BigEndian.Uint64(body[0:8]),
+		NBuffers:     binary.BigEndian.Uint32(body[8:12]),
+		NTables:      body[12],
+		AuxiliaryID:  body[13],
+		Capabilities: binary.BigEndian.Uint32(body[16:20]),
+	}, nil
+}
+
+var errorTypeNames = map[uint16]string{
+	0:      "HELLO_FAILED",
+	1:      "BAD_REQUEST",
+	2:      "BAD_ACTION",
+	3:      "BAD_INSTRUCTION",
+	4:      "BAD_MATCH",
+	5:      "FLOW_MOD_FAILED",
+	6:      "GROUP_MOD_FAILED",
+	7:      "PORT_MOD_FAILED",
+	8:      "TABLE_MOD_FAILED",
+	9:      "QUEUE_OP_FAILED",
+	10:     "SWITCH_CONFIG_FAILED",
+	11:     "ROLE_REQUEST_FAILED",
+	12:     "METER_MOD_FAILED",
+	13:     "TABLE_FEATURES_FAILED",
+	0xffff: "EXPERIMENTER",
+}
+
+// ErrorMsg is the body of an OFPT_ERROR message. It implements the error interface.
+type ErrorMsg struct {
+	Type uint16
+	Code uint16
+	// Data contains at least the first 64 bytes of the failed request.
+	Data []byte
+}
+
+// ParseErrorMsg decodes the body of an OFPT_ERROR message.
+func ParseErrorMsg(body []byte) (*ErrorMsg, error) {
+	if len(body) < 4 {
+		return nil, fmt.Errorf("error message too short: %d bytes", len(body))
+	}
+	return &ErrorMsg{
+		Type: binary.BigEndian.Uint16(body[0:2]),
+		Code: binary.BigEndian.Uint16(body[2:4]),
+		Data: body[4:],
+	}, nil
+}
+
+func (e *ErrorMsg) Error() string {
+	name, ok := errorTypeNames[e.Type]
+	if !ok {
+		name = fmt.Sprintf("type %d", e.Type)
+	}
+	return fmt.Sprintf("OpenFlow error %s, code %d", name, e.Code)
+}
+
+// FlowMod is an OFPT_FLOW_MOD message.
+type FlowMod struct {
+	Cookie       uint64
+	CookieMask   uint64
+	TableID      uint8
+	Command      uint8
+	IdleTimeout  uint16
+	HardTimeout  uint16
+	Priority     uint16
+	BufferID     uint32
+	OutPort      uint32
+	OutGroup     uint32
+	Flags        uint16
+	Match        Match
+	Instructions []Instruction
+}
+
+// NewFlowMod returns a FlowMod with the given command, which applies to any buffer, output port and group.
+func NewFlowMod(command uint8) *FlowMod {
+	return &FlowMod{
+		Command:  command,
+		BufferID: NoBuffer,
+		OutPort:  PortAny,
+		OutGroup: GroupAny,
+	}
+}
+
+func (m *FlowMod) MessageType() uint8 {
+	return TypeFlowMod
+}
+
+func (m *FlowMod) MarshalBody() []byte {
+	data := make([]byte, 40)
+	binary.BigEndian.PutUint64(data[0:8], m.Cookie)
+	binary.BigEndian.PutUint64(data[8:16], m.CookieMask)
+	data[16] = m.TableID
+	data[17] = m.Command
+	binary.BigEndian.PutUint16(data[18:20], m.IdleTimeout)
+	binary.BigEndian.PutUint16(data[20:22], m.HardTimeout)
+	binary.BigEndian.PutUint16(data[22:24], m.Priority)
+	binary.BigEndian.PutUint32(data[24:28], m.BufferID)
+	binary.BigEndian.PutUint32(data[28:32], m.OutPort)
+	binary.BigEndian.PutUint32(data[32:36], m.OutGroup)
+	binary.BigEndian.PutUint16(data[36:38], m.Flags)
+	data = append(data, m.Match.Marshal()...)
+	for _, inst := range m.Instructions {
+		data = append(data, inst.Marshal()...)
+	}
+	return data
+}
+
+// pad8 returns the number of bytes required to pad n to a multiple of 8.
+func pad8(n int) int {
+	return (8 - n%8) % 8
+}
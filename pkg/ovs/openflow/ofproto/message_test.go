@@ -0,0 +1,360 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ofproto
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		t.Fatalf("Invalid hex string %q: %v", s, err)
+	}
+	return data
+}
+
+func checkBytes(t *testing.T, name string, expected string, actual []byte) {
+	if e := hex.EncodeToString(mustDecodeHex(t, expected)); e != hex.EncodeToString(actual) {
+		t.Errorf("Unexpected encoding of %s:\nexpected %s\ngot      %s", name, e, hex.EncodeToString(actual))
+	}
+}
+
+func mustMarshal(t *testing.T, msg Message, xid uint32) []byte {
+	data, err := Marshal(msg, xid)
+	if err != nil {
+		t.Fatalf("Failed to marshal message: %v", err)
+	}
+	return data
+}
+
+func TestFlowModMarshal(t *testing.T) {
+	fm := NewFlowMod(FlowAdd)
+	fm.Cookie = 1
+	fm.Priority = 200
+	fm.Match.Fields = []MatchField{
+		NewUintMatchField(FieldEthType, 0x0800),
+		NewUintMatchField(FieldInPort, 1),
+	}
+	fm.Instructions = []Instruction{&InstructionApplyActions{Actions: []Action{
+		&NXActionResubmitTable{InPort: NXResubmitInPort, Table: 10},
+	}}}
+	expected := `
+		04 0e 0060 00000001
+		0000000000000001 0000000000000000 00 00 0000 0000 00c8 ffffffff ffffffff ffffffff 0000 0000
+		0001 0012 80000a02 0800 80000004 00000001 000000000000
+		0004 0018 00000000
+		ffff 0010 00002320 000e fff8 0a 000000`
+	checkBytes(t, "flow mod", expected, mustMarshal(t, fm, 1))
+}
+
+func TestMarshalTooLong(t *testing.T) {
+	if _, err := Marshal(NewRawMessage(TypeExperimenter, make([]byte, 0xffff-HeaderLen)), 1); err != nil {
+		t.Errorf("Expected message of maximum length to be marshalled, got error: %v", err)
+	}
+	if _, err := Marshal(NewRawMessage(TypeExperimenter, make([]byte, 0xffff-HeaderLen+1)), 1); err == nil {
+		t.Errorf("Expected error when marshalling message longer than 0xffff bytes")
+	}
+	add := &BundleAdd{BundleID: 1, Message: NewRawMessage(TypeExperimenter, make([]byte, 0xffff-HeaderLen))}
+	if _, err := Marshal(add, 1); err == nil {
+		t.Errorf("Expected error when marshalling BundleAdd message longer than 0xffff bytes")
+	}
+}
+
+func TestMatchFieldMarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    MatchField
+		expected string
+	}{
+		{"reg range", NewRangeMatchField(FieldReg(0), 1, 0, 16), "00010108 00000001 0000ffff"},
+		{"full reg", NewRangeMatchField(FieldReg(1), 0x20, 0, 32), "00010204 00000020"},
+		{"ct_mark", NewMaskedMatchField(FieldCtMark, []byte{0x20}, []byte{0xff}), "0001d708 00000020 000000ff"},
+		{"eth_src", NewMatchField(FieldEthSrc, []byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}), "80000806 aabbccddeeff"},
+		{"ct_label range", NewRangeMatchField(FieldCtLabel, 0x1, 64, 32),
+			"0001d920 00000000000000010000000000000000 00000000ffffffff0000000000000000"},
+	}
+	for _, tc := range tests {
+		checkBytes(t, tc.name, tc.expected, tc.field.Marshal())
+	}
+}
+
+func TestActionMarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   Action
+		expected string
+	}{
+		{"output", &ActionOutput{Port: 2}, "0000 0010 00000002 0000 000000000000"},
+		{"dec_ttl", &ActionDecNwTTL{}, "0018 0008 00000000"},
+		{"group", &ActionGroup{GroupID: 5}, "0016 0008 00000005"},
+		{"set_field", &ActionSetField{Field: NewMatchField(FieldIPv4Dst, []byte{10, 0, 0, 1})},
+			"0019 0010 80001804 0a000001 00000000"},
+		{"load", &NXActionRegLoad{Dst: FieldReg(0), Ofs: 0, NBits: 16, Value: 1},
+			"ffff 0018 00002320 0007 000f 00010004 0000000000000001"},
+		{"move", &NXActionRegMove{Src: FieldEthSrc, Dst: FieldEthDst, NBits: 48},
+			"ffff 0018 00002320 0006 0030 0000 0000 80000806 80000606"},
+		{"output reg", &NXActionOutputReg{Src: FieldReg(1), Ofs: 0, NBits: 32},
+			"ffff 0018 00002320 000f 001f 00010204 0000 000000000000"},
+		{"conjunction", &NXActionConjunction{ID: 10, Clause: 2, NClauses: 3},
+			"ffff 0010 00002320 0022 01 03 0000000a"},
+		{"ct", &NXActionConntrack{
+			Flags:       NXConntrackFlagCommit,
+			Zone:        0xfff0,
+			RecircTable: 31,
+			Actions:     []Action{&NXActionRegLoad{Dst: FieldCtMark, NBits: 32, Value: 0x20}},
+		}, "ffff 0030 00002320 0023 0001 00000000 fff0 1f 000000 0000" +
+			"ffff 0018 00002320 0007 001f 0001d604 0000000000000020"},
+		{"nat", &NXActionNAT{Flags: NXNATFlagDst, IPMin: net.ParseIP("10.0.0.1"), PortMin: 80},
+			"ffff 0018 00002320 0024 0000 0002 0011 0a000001 0050 0000"},
+		{"nat-src-range", &NXActionNAT{Flags: NXNATFlagSrc, IPMin: net.ParseIP("10.0.0.1"), IPMax: net.ParseIP("10.0.0.2"), PortMin: 1000, PortMax: 2000},
+			"ffff 0020 00002320 0024 0000 0001 0033 0a000001 0a000002 03e8 07d0 00000000"},
+		{"nat-none", &NXActionNAT{}, "ffff 0010 00002320 0024 0000 0000 0000"},
+		{"nat-dst-no-range", &NXActionNAT{Flags: NXNATFlagDst}, "ffff 0010 00002320 0024 0000 0002 0000"},
+		{"learn", &NXActionLearn{HardTimeout: 300, Priority: 200, Table: 40, Specs: []*LearnSpec{
+			{Dst: FieldEthType, NBits: 16, Value: 0x800},
+			{Src: FieldIPv4Src, Dst: FieldIPv4Src, NBits: 32},
+			{Dst: FieldReg(4), DstOfs: 16, NBits: 1, Value: 1, Load: true},
+		}}, "ffff 0048 00002320 0010 0000 012c 00c8 0000000000000000 0000 28 00 0000 0000" +
+			"2010 0800 80000a02 0000" +
+			"0020 80001604 0000 80001604 0000" +
+			"2801 0001 00010804 0010" +
+			"000000000000"},
+	}
+	for _, tc := range tests {
+		checkBytes(t, tc.name, tc.expected, tc.action.Marshal())
+	}
+}
+
+func TestFieldByName(t *testing.T) {
+	for _, name := range []string{"nw_dst", "NXM_OF_IP_DST", "ip_dst"} {
+		f, err := FieldByName(name)
+		if err != nil || f != FieldIPv4Dst {
+			t.Errorf("Expected field %s for name %s, got %v (%v)", FieldIPv4Dst.Name, name, f, err)
+		}
+	}
+	if f, _ := FieldByName("NXM_NX_REG3"); f != FieldReg(3) {
+		t.Errorf("Expected reg3 for NXM_NX_REG3, got %v", f)
+	}
+	if _, err := FieldByName("unknown"); err == nil {
+		t.Errorf("Expected error for unknown field")
+	}
+	if f, ok := FieldByHeader(FieldCtLabel.Header(true)); !ok || f != FieldCtLabel {
+		t.Errorf("Expected ct_label for header 0x%x, got %v", FieldCtLabel.Header(true), f)
+	}
+}
+
+func TestBundleMarshal(t *testing.T) {
+	open := &BundleControl{BundleID: 7, Type: BundleOpenRequest, Flags: BundleAtomic | BundleOrdered}
+	checkBytes(t, "bundle open", "04 04 0018 00000005 4f4e4600 000008fc 00000007 0000 0003", mustMarshal(t, open, 5))
+
+	add := &BundleAdd{BundleID: 7, Flags: BundleAtomic | BundleOrdered, Message: NewBarrierRequest()}
+	// The xid of the embedded message must be the xid of the BundleAdd message.
+	checkBytes(t, "bundle add", "04 04 0020 00000006 4f4e4600 000008fd 00000007 0000 0003 04 14 0008 00000006", mustMarshal(t, add, 6))
+
+	reply, err := ParseBundleControl(mustDecodeHex(t, "4f4e4600 000008fc 00000007 0005 0003"))
+	if err != nil {
+		t.Fatalf("Failed to parse bundle control message: %v", err)
+	}
+	if reply.BundleID != 7 || reply.Type != BundleCommitReply || reply.Flags != BundleAtomic|BundleOrdered {
+		t.Errorf("Unexpected bundle control message %+v", reply)
+	}
+	if _, err := ParseBundleControl(mustDecodeHex(t, "00002320 000008fc 00000007 0005 0003")); err == nil {
+		t.Errorf("Expected error when parsing a message of another experimenter")
+	}
+}
+
+func TestActionParse(t *testing.T) {
+	tests := []struct {
+		action   Action
+		expected string
+	}{
+		{&ActionOutput{Port: 2}, "output:2"},
+		{&ActionOutput{Port: PortNormal}, "NORMAL"},
+		{&ActionDecNwTTL{}, "dec_ttl"},
+		{&ActionGroup{GroupID: 5}, "group:5"},
+		{&ActionSetField{Field: NewMatchField(FieldIPv4Dst, []byte{10, 0, 0, 1})}, "set_field:10.0.0.1->nw_dst"},
+		{&NXActionResubmitTable{InPort: NXResubmitInPort, Table: 10}, "resubmit(,10)"},
+		{&NXActionRegLoad{Dst: FieldReg(0), Ofs: 0, NBits: 16, Value: 1}, "load:0x1->NXM_NX_REG0[0..15]"},
+		{&NXActionRegMove{Src: FieldEthSrc, Dst: FieldEthDst, NBits: 48}, "move:NXM_OF_ETH_SRC[]->NXM_OF_ETH_DST[]"},
+		{&NXActionOutputReg{Src: FieldReg(1), Ofs: 0, NBits: 32}, "output:NXM_NX_REG1[]"},
+		{&NXActionConjunction{ID: 10, Clause: 2, NClauses: 3}, "conjunction(10,2/3)"},
+		{&NXActionConntrack{
+			Flags:       NXConntrackFlagCommit,
+			Zone:        0xfff0,
+			RecircTable: 31,
+			Actions:     []Action{&NXActionRegLoad{Dst: FieldCtMark, NBits: 32, Value: 0x20}},
+		}, "ct(commit,table=31,zone=65520,exec(load:0x20->NXM_NX_CT_MARK[]))"},
+		{&NXActionConntrack{
+			Flags:       NXConntrackFlagCommit,
+			Zone:        0xfff0,
+			RecircTable: 50,
+			Actions:     []Action{&NXActionNAT{Flags: NXNATFlagDst, IPMin: net.ParseIP("10.0.0.1"), PortMin: 80}},
+		}, "ct(commit,table=50,zone=65520,nat(dst=10.0.0.1:80))"},
+		{&NXActionConntrack{Zone: 0xfff0, RecircTable: 31, Actions: []Action{&NXActionNAT{}}},
+			"ct(table=31,zone=65520,nat)"},
+		{&NXActionConntrack{Flags: NXConntrackFlagCommit, Zone: 0xfff0, RecircTable: NXConntrackRecircNone,
+			Actions: []Action{&NXActionNAT{Flags: NXNATFlagSrc}}},
+			"ct(commit,zone=65520,nat(src))"},
+		{&NXActionLearn{HardTimeout: 300, Priority: 200, Table: 40, Specs: []*LearnSpec{
+			{Dst: FieldEthType, NBits: 16, Value: 0x800},
+			{Src: FieldIPv4Src, Dst: FieldIPv4Src, NBits: 32},
+			{Src: FieldReg(3), Dst: FieldReg(3), NBits: 32, Load: true},
+			{Dst: FieldReg(4), DstOfs: 16, NBits: 1, Value: 1, Load: true},
+		}}, "learn(table=40,hard_timeout=300,priority=200,dl_type=0x800,NXM_OF_IP_SRC[]," +
+			"load:NXM_NX_REG3[]->NXM_NX_REG3[],load:0x1->NXM_NX_REG4[16..16])"},
+	}
+	for _, tc := range tests {
+		actions, err := ParseActions(tc.action.Marshal())
+		if err != nil {
+			t.Errorf("Failed to parse action %s: %v", tc.expected, err)
+			continue
+		}
+		if len(actions) != 1 || actions[0].String() != tc.expected {
+			t.Errorf("Expected action <%s>, got <%s>", tc.expected, FormatActions(actions))
+		}
+	}
+
+	// Actions which are not decoded are kept as is.
+	raw := mustDecodeHex(t, "ffff 0010 00002320 00ff 000000000000")
+	actions, err := ParseActions(raw)
+	if err != nil || len(actions) != 1 {
+		t.Fatalf("Failed to parse unknown action: %v", err)
+	}
+	checkBytes(t, "raw action", "ffff 0010 00002320 00ff 000000000000", actions[0].Marshal())
+	if _, err := ParseActions(raw[:12]); err == nil {
+		t.Errorf("Expected error when parsing a truncated action")
+	}
+}
+
+func TestFlowStatsRequestMarshal(t *testing.T) {
+	req := NewFlowStatsRequest(TableAll)
+	req.Cookie = 0x1000
+	req.CookieMask = 0xf000
+	expected := `
+		04 12 0038 00000001
+		0001 0000 00000000
+		ff 000000 ffffffff ffffffff 00000000 0000000000001000 000000000000f000
+		0001 0004 00000000`
+	checkBytes(t, "flow stats request", expected, mustMarshal(t, req, 1))
+}
+
+func TestFlowStatsReplyParse(t *testing.T) {
+	stats := []*FlowStats{
+		{
+			TableID:      10,
+			DurationSec:  7,
+			DurationNsec: 264000000,
+			Priority:     200,
+			Cookie:       0x1000,
+			PacketCount:  3,
+			ByteCount:    180,
+			Match: Match{Fields: []MatchField{
+				NewUintMatchField(FieldEthType, 0x0800),
+				NewUintMatchField(FieldInPort, 1),
+				NewMaskedMatchField(FieldIPv4Src, []byte{10, 10, 0, 0}, []byte{255, 255, 255, 0}),
+			}},
+			Instructions: []Instruction{&InstructionApplyActions{Actions: []Action{
+				&NXActionResubmitTable{InPort: NXResubmitInPort, Table: 20},
+			}}},
+		},
+		{TableID: 20},
+	}
+	parsed, err := ParseFlowStatsReply(MarshalFlowStats(stats))
+	if err != nil {
+		t.Fatalf("Failed to parse flow stats reply: %v", err)
+	}
+	if len(parsed) != 2 {
+		t.Fatalf("Expected 2 flow stats entries, got %d", len(parsed))
+	}
+	s := parsed[0]
+	if s.TableID != 10 || s.DurationSec != 7 || s.DurationNsec != 264000000 || s.Priority != 200 ||
+		s.Cookie != 0x1000 || s.PacketCount != 3 || s.ByteCount != 180 {
+		t.Errorf("Unexpected flow stats %+v", s)
+	}
+	if match := s.Match.String(); match != "dl_type=0x800,in_port=1,nw_src=10.10.0.0/24" {
+		t.Errorf("Unexpected match <%s>", match)
+	}
+	if actions := FormatInstructions(s.Instructions); actions != "resubmit(,20)" {
+		t.Errorf("Unexpected actions <%s>", actions)
+	}
+	if actions := FormatInstructions(parsed[1].Instructions); actions != "drop" {
+		t.Errorf("Expected drop actions for a flow without instructions, got <%s>", actions)
+	}
+
+	if _, err := ParseFlowStatsReply(mustDecodeHex(t, "0002 0000 00000000")); err == nil {
+		t.Errorf("Expected error when parsing a reply of another multipart type")
+	}
+}
+
+func TestGroupDescReplyParse(t *testing.T) {
+	checkBytes(t, "group desc request", "0007 0000 00000000", (&GroupDescRequest{}).MarshalBody())
+	bucket := NewBucket(100)
+	bucket.Actions = []Action{&NXActionResubmitTable{InPort: NXResubmitInPort, Table: 42}}
+	descs := []*GroupDesc{
+		{Type: GroupTypeSelect, GroupID: 1, Buckets: []*Bucket{bucket, NewBucket(100)}},
+		{Type: GroupTypeAll, GroupID: 2},
+	}
+	parsed, err := ParseGroupDescReply(MarshalGroupDescs(descs))
+	if err != nil {
+		t.Fatalf("Failed to parse group desc reply: %v", err)
+	}
+	if len(parsed) != 2 {
+		t.Fatalf("Expected 2 group descs, got %d", len(parsed))
+	}
+	if d := parsed[0]; d.Type != GroupTypeSelect || d.GroupID != 1 || len(d.Buckets) != 2 {
+		t.Errorf("Unexpected group desc %+v", d)
+	} else if bucket := d.Buckets[0].String(); bucket != "bucket=weight:100,actions=resubmit(,42)" {
+		t.Errorf("Unexpected bucket <%s>", bucket)
+	}
+	if d := parsed[1]; d.Type != GroupTypeAll || d.GroupID != 2 || len(d.Buckets) != 0 {
+		t.Errorf("Unexpected group desc %+v", d)
+	}
+
+	if _, err := ParseGroupDescReply(mustDecodeHex(t, "0001 0000 00000000")); err == nil {
+		t.Errorf("Expected error when parsing a reply of another multipart type")
+	}
+}
+
+func TestGroupModMarshal(t *testing.T) {
+	bucket := NewBucket(100)
+	bucket.Actions = []Action{
+		&NXActionRegLoad{Dst: FieldReg(3), NBits: 32, Value: 0x0a0a0002},
+		&NXActionResubmitTable{InPort: NXResubmitInPort, Table: 42},
+	}
+	gm := &GroupMod{Command: GroupAdd, Type: GroupTypeSelect, GroupID: 5, Buckets: []*Bucket{bucket}}
+	expected := `
+		04 0f 0048 00000001
+		0000 01 00 00000005
+		0038 0064 ffffffff ffffffff 00000000
+		ffff 0018 00002320 0007 001f 00010604 000000000a0a0002
+		ffff 0010 00002320 000e fff8 2a 000000`
+	checkBytes(t, "group mod", expected, mustMarshal(t, gm, 1))
+
+	parsed, err := ParseGroupMod(gm.MarshalBody())
+	if err != nil {
+		t.Fatalf("Failed to parse group mod: %v", err)
+	}
+	expectedRepr := "group_id=5,type=select,bucket=weight:100,actions=load:0xa0a0002->NXM_NX_REG3[],resubmit(,42)"
+	if parsed.String() != expectedRepr {
+		t.Errorf("Expected group <%s>, got <%s>", expectedRepr, parsed.String())
+	}
+	if _, err := ParseGroupMod(gm.MarshalBody()[:20]); err == nil {
+		t.Errorf("Expected error when parsing a truncated bucket")
+	}
+}
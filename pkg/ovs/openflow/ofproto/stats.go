@@ -0,0 +1,230 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ofproto
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Multipart message types.
+const (
+	// MultipartTypeFlow is the multipart message type of individual flow statistics.
+	MultipartTypeFlow uint16 = 1
+	// MultipartTypeGroupDesc is the multipart message type of the group descriptions.
+	MultipartTypeGroupDesc uint16 = 7
+)
+
+// TableAll is the table ID matching all the tables in a statistics request.
+const TableAll uint8 = 0xff
+
+// multipartHeaderLen is the length of the header shared by multipart requests and replies.
+const multipartHeaderLen = 8
+
+// flowStatsLen is the length of the fixed part of a flow statistics entry, before the match.
+const flowStatsLen = 48
+
+// groupDescLen is the length of the fixed part of a group description, before the buckets.
+const groupDescLen = 8
+
+// FlowStatsRequest requests the statistics of the flows matching its table, cookie and match.
+type FlowStatsRequest struct {
+	TableID    uint8
+	OutPort    uint32
+	OutGroup   uint32
+	Cookie     uint64
+	CookieMask uint64
+	Match      Match
+}
+
+// NewFlowStatsRequest returns a FlowStatsRequest for all the flows of the table, which can be TableAll.
+func NewFlowStatsRequest(tableID uint8) *FlowStatsRequest {
+	return &FlowStatsRequest{
+		TableID:  tableID,
+		OutPort:  PortAny,
+		OutGroup: GroupAny,
+	}
+}
+
+func (m *FlowStatsRequest) MessageType() uint8 {
+	return TypeMultipartRequest
+}
+
+func (m *FlowStatsRequest) MarshalBody() []byte {
+	data := make([]byte, multipartHeaderLen+32)
+	binary.BigEndian.PutUint16(data[0:2], MultipartTypeFlow)
+	data[8] = m.TableID
+	binary.BigEndian.PutUint32(data[12:16], m.OutPort)
+	binary.BigEndian.PutUint32(data[16:20], m.OutGroup)
+	binary.BigEndian.PutUint64(data[24:32], m.Cookie)
+	binary.BigEndian.PutUint64(data[32:40], m.CookieMask)
+	return append(data, m.Match.Marshal()...)
+}
+
+// FlowStats is the statistics entry of a flow, as reported by the switch.
+type FlowStats struct {
+	TableID      uint8
+	DurationSec  uint32
+	DurationNsec uint32
+	Priority     uint16
+	IdleTimeout  uint16
+	HardTimeout  uint16
+	Flags        uint16
+	Cookie       uint64
+	PacketCount  uint64
+	ByteCount    uint64
+	Match        Match
+	Instructions []Instruction
+}
+
+// MarshalFlowStats encodes flow statistics entries in the body of a multipart reply. The switch uses the same
+// encoding, this is mostly useful to fake a switch in tests.
+func MarshalFlowStats(stats []*FlowStats) []byte {
+	data := make([]byte, multipartHeaderLen)
+	binary.BigEndian.PutUint16(data[0:2], MultipartTypeFlow)
+	for _, s := range stats {
+		entry := make([]byte, flowStatsLen)
+		entry[2] = s.TableID
+		binary.BigEndian.PutUint32(entry[4:8], s.DurationSec)
+		binary.BigEndian.PutUint32(entry[8:12], s.DurationNsec)
+		binary.BigEndian.PutUint16(entry[12:14], s.Priority)
+		binary.BigEndian.PutUint16(entry[14:16], s.IdleTimeout)
+		binary.BigEndian.PutUint16(entry[16:18], s.HardTimeout)
+		binary.BigEndian.PutUint16(entry[18:20], s.Flags)
+		binary.BigEndian.PutUint64(entry[24:32], s.Cookie)
+		binary.BigEndian.PutUint64(entry[32:40], s.PacketCount)
+		binary.BigEndian.PutUint64(entry[40:48], s.ByteCount)
+		entry = append(entry, s.Match.Marshal()...)
+		for _, inst := range s.Instructions {
+			entry = append(entry, inst.Marshal()...)
+		}
+		binary.BigEndian.PutUint16(entry[0:2], uint16(len(entry)))
+		data = append(data, entry...)
+	}
+	return data
+}
+
+// ParseFlowStatsReply decodes the body of a flow statistics multipart reply.
+func ParseFlowStatsReply(body []byte) ([]*FlowStats, error) {
+	if len(body) < multipartHeaderLen {
+		return nil, fmt.Errorf("multipart reply too short: %d bytes", len(body))
+	}
+	if replyType := binary.BigEndian.Uint16(body[0:2]); replyType != MultipartTypeFlow {
+		return nil, fmt.Errorf("unexpected multipart reply type %d", replyType)
+	}
+	var stats []*FlowStats
+	for data := body[multipartHeaderLen:]; len(data) > 0; {
+		if len(data) < flowStatsLen {
+			return nil, fmt.Errorf("truncated flow stats entry")
+		}
+		length := int(binary.BigEndian.Uint16(data[0:2]))
+		if length < flowStatsLen || length > len(data) {
+			return nil, fmt.Errorf("invalid flow stats length %d", length)
+		}
+		entry := data[:length]
+		s := &FlowStats{
+			TableID:      entry[2],
+			DurationSec:  binary.BigEndian.Uint32(entry[4:8]),
+			DurationNsec: binary.BigEndian.Uint32(entry[8:12]),
+			Priority:     binary.BigEndian.Uint16(entry[12:14]),
+			IdleTimeout:  binary.BigEndian.Uint16(entry[14:16]),
+			HardTimeout:  binary.BigEndian.Uint16(entry[16:18]),
+			Flags:        binary.BigEndian.Uint16(entry[18:20]),
+			Cookie:       binary.BigEndian.Uint64(entry[24:32]),
+			PacketCount:  binary.BigEndian.Uint64(entry[32:40]),
+			ByteCount:    binary.BigEndian.Uint64(entry[40:48]),
+		}
+		match, n, err := ParseMatch(entry[flowStatsLen:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid match in flow stats: %v", err)
+		}
+		s.Match = match
+		if s.Instructions, err = ParseInstructions(entry[flowStatsLen+n:]); err != nil {
+			return nil, fmt.Errorf("invalid instructions in flow stats: %v", err)
+		}
+		stats = append(stats, s)
+		data = data[length:]
+	}
+	return stats, nil
+}
+
+// GroupDescRequest requests the description of all the groups.
+type GroupDescRequest struct{}
+
+func (m *GroupDescRequest) MessageType() uint8 {
+	return TypeMultipartRequest
+}
+
+func (m *GroupDescRequest) MarshalBody() []byte {
+	data := make([]byte, multipartHeaderLen)
+	binary.BigEndian.PutUint16(data[0:2], MultipartTypeGroupDesc)
+	return data
+}
+
+// GroupDesc is the description of a group, as reported by the switch.
+type GroupDesc struct {
+	Type    uint8
+	GroupID uint32
+	Buckets []*Bucket
+}
+
+// MarshalGroupDescs encodes group descriptions in the body of a multipart reply. Like MarshalFlowStats, this is
+// mostly useful to fake a switch in tests.
+func MarshalGroupDescs(descs []*GroupDesc) []byte {
+	data := make([]byte, multipartHeaderLen)
+	binary.BigEndian.PutUint16(data[0:2], MultipartTypeGroupDesc)
+	for _, d := range descs {
+		entry := make([]byte, groupDescLen)
+		entry[2] = d.Type
+		binary.BigEndian.PutUint32(entry[4:8], d.GroupID)
+		for _, bucket := range d.Buckets {
+			entry = append(entry, bucket.Marshal()...)
+		}
+		binary.BigEndian.PutUint16(entry[0:2], uint16(len(entry)))
+		data = append(data, entry...)
+	}
+	return data
+}
+
+// ParseGroupDescReply decodes the body of a group description multipart reply.
+func ParseGroupDescReply(body []byte) ([]*GroupDesc, error) {
+	if len(body) < multipartHeaderLen {
+		return nil, fmt.Errorf("multipart reply too short: %d bytes", len(body))
+	}
+	if replyType := binary.BigEndian.Uint16(body[0:2]); replyType != MultipartTypeGroupDesc {
+		return nil, fmt.Errorf("unexpected multipart reply type %d", replyType)
+	}
+	var descs []*GroupDesc
+	for data := body[multipartHeaderLen:]; len(data) > 0; {
+		if len(data) < groupDescLen {
+			return nil, fmt.Errorf("truncated group desc entry")
+		}
+		length := int(binary.BigEndian.Uint16(data[0:2]))
+		if length < groupDescLen || length > len(data) {
+			return nil, fmt.Errorf("invalid group desc length %d", length)
+		}
+		buckets, err := parseBuckets(data[groupDescLen:length])
+		if err != nil {
+			return nil, fmt.Errorf("invalid buckets in group desc: %v", err)
+		}
+		descs = append(descs, &GroupDesc{
+			Type:    data[2],
+			GroupID: binary.BigEndian.Uint32(data[4:8]),
+			Buckets: buckets,
+		})
+		data = data[length:]
+	}
+	return descs, nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testing
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/vmware-tanzu/antrea/pkg/ovs/openflow/ofproto"
+)
+
+// Message is an OpenFlow message received by the FakeSwitch.
+type Message struct {
+	Header ofproto.Header
+	Body   []byte
+}
+
+// FakeSwitch is an in-memory OpenFlow switch for unit tests. It completes the handshake, answers barrier requests
+// and records every other message it receives. Bundle control requests are acknowledged unless Handler replies.
+type FakeSwitch struct {
+	DatapathID uint64
+	// Handler, if set, returns the replies to send for a received message which is not handled by the FakeSwitch.
+	Handler func(header ofproto.Header, body []byte) []ofproto.Message
+
+	mutex    sync.Mutex
+	messages []Message
+	conns    []net.Conn
+}
+
+// NewFakeSwitch returns a FakeSwitch reporting the provided datapath ID.
+func NewFakeSwitch(datapathID uint64) *FakeSwitch {
+	return &FakeSwitch{DatapathID: datapathID}
+}
+
+// Listen accepts connections on the Unix socket until the listener is closed.
+func (s *FakeSwitch) Listen(socketPath string) (net.Listener, error) {
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.Serve(conn)
+		}
+	}()
+	return l, nil
+}
+
+// Serve processes the messages received on the connection until it is closed.
+func (s *FakeSwitch) Serve(conn net.Conn) {
+	s.mutex.Lock()
+	s.conns = append(s.conns, conn)
+	s.mutex.Unlock()
+	defer conn.Close()
+
+	for {
+		header, body, err := ofproto.ReadMessage(conn)
+		if err != nil {
+			return
+		}
+		var replies []ofproto.Message
+		switch header.Type {
+		case ofproto.TypeHello:
+			replies = []ofproto.Message{ofproto.NewHello()}
+		case ofproto.TypeFeaturesRequest:
+			features := make([]byte, 24)
+			binary.BigEndian.PutUint64(features[0:8], s.DatapathID)
+			features[12] = 254
+			replies = []ofproto.Message{ofproto.NewRawMessage(ofproto.TypeFeaturesReply, features)}
+		case ofproto.TypeBarrierRequest:
+			replies = []ofproto.Message{ofproto.NewRawMessage(ofproto.TypeBarrierReply, nil)}
+		case ofproto.TypeEchoReply:
+		default:
+			s.mutex.Lock()
+			s.messages = append(s.messages, Message{Header: header, Body: body})
+			s.mutex.Unlock()
+			if s.Handler != nil {
+				replies = s.Handler(header, body)
+			}
+			if replies == nil && header.Type == ofproto.TypeExperimenter {
+				if ctrl, err := ofproto.ParseBundleControl(body); err == nil {
+					// The type of each reply immediately follows the type of the request.
+					ctrl.Type++
+					replies = []ofproto.Message{ctrl}
+				}
+			}
+		}
+		for _, reply := range replies {
+			data, err := ofproto.Marshal(reply, header.Xid)
+			if err != nil {
+				return
+			}
+			if _, err := conn.Write(data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Messages returns the messages of the given type received so far.
+func (s *FakeSwitch) Messages(msgType uint8) []Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var msgs []Message
+	for _, msg := range s.messages {
+		if msg.Header.Type == msgType {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// Reset forgets the messages received so far.
+func (s *FakeSwitch) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.messages = nil
+}
+
+// CloseConnections closes all the connections accepted by the FakeSwitch, which simulates a restart of the switch.
+func (s *FakeSwitch) CloseConnections() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
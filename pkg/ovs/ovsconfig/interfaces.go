@@ -24,17 +24,20 @@ const (
 	OVSDatapathNetdev = "netdev"
 )
 
-//go:generate mockgen -copyright_file ../../../hack/boilerplate/license_header.raw.txt -destination testing/mock_ovsconfig.go -package=testing github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig OVSBridgeClient
+//go:generate mockgen -copyright_file ../../../hack/boilerplate/license_header.raw.txt -destination testing/mock_ovsconfig.go -package=testing github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig OVSBridgeClient,PortTransaction
 
 type OVSBridgeClient interface {
 	Create() Error
 	Delete() Error
 	GetExternalIDs() (map[string]string, Error)
 	SetExternalIDs(externalIDs map[string]interface{}) Error
+	GetOVSOtherConfig() (map[string]string, Error)
+	UpdateOVSOtherConfig(configs map[string]interface{}) Error
+	DeleteOVSOtherConfig(keys []string) Error
 	CreatePort(name, ifDev string, externalIDs map[string]interface{}) (string, Error)
 	CreateInternalPort(name string, ofPortRequest int32, externalIDs map[string]interface{}) (string, Error)
 	CreateTunnelPort(name string, tunnelType TunnelType, ofPortRequest int32) (string, Error)
-	CreateTunnelPortExt(name string, tunnelType TunnelType, ofPortRequest int32, remoteIP string, psk string, externalIDs map[string]interface{}) (string, Error)
+	CreateTunnelPortExt(name string, tunnelType TunnelType, ofPortRequest int32, remoteIP, remoteName, psk string, externalIDs map[string]interface{}) (string, Error)
 	DeletePort(portUUID string) Error
 	DeletePorts(portUUIDList []string) Error
 	GetOFPort(ifName string) (int32, Error)
@@ -42,4 +45,30 @@ type OVSBridgeClient interface {
 	GetPortList() ([]OVSPortData, Error)
 	SetInterfaceMTU(name string, MTU int) error
 	GetOVSVersion() (string, Error)
+	MonitorInterfaces(handler InterfaceEventHandler) Error
+	NewPortTransaction() PortTransaction
+}
+
+// PortTransaction batches the creation and the configuration of ports of the bridge, which are then committed in one
+// OVSDB transaction: either all the operations are applied, or none is. The operations on a port which is not created
+// by the transaction apply to the existing port, and to its interface which has the same name.
+type PortTransaction interface {
+	// CreatePort adds the creation of a port with the specified name, connected to the interface ifDev of type
+	// ifType. If ofPortRequest is not zero, it will be passed to the OVS port creation.
+	CreatePort(name, ifDev, ifType string, ofPortRequest int32)
+	// SetInterfaceOptions sets the options of the interface of the port, replacing the existing ones.
+	SetInterfaceOptions(name string, options map[string]interface{})
+	// SetInterfaceMTU requests the MTU of the interface of the port.
+	SetInterfaceMTU(name string, MTU int)
+	// SetPortExternalIDs sets the external_ids of the port, replacing the existing ones.
+	SetPortExternalIDs(name string, externalIDs map[string]interface{})
+	// SetPortQoS limits the rate of the traffic output to the port to maxRate bps, with bursts of up to burst bits,
+	// or removes the limit if maxRate is 0. burst 0 selects the default of OVS.
+	SetPortQoS(name string, maxRate, burst uint64)
+	// SetInterfaceIngressPolicing limits the rate of the traffic received on the interface of the port to rate kbps,
+	// with bursts of up to burst kb, by dropping the packets which exceed it, or removes the limit if rate is 0.
+	// burst 0 selects the default of OVS.
+	SetInterfaceIngressPolicing(name string, rate, burst uint64)
+	// Commit runs the operations in one transaction, and returns the UUIDs of the created ports by name.
+	Commit() (map[string]string, Error)
 }
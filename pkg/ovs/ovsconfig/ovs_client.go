@@ -17,6 +17,7 @@ package ovsconfig
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/TomCodeLV/OVSDB-golang-lib/pkg/dbtransaction"
@@ -25,8 +26,48 @@ import (
 	"k8s.io/klog"
 )
 
+// OVSDBConnection is a connection to the OVSDB server. When the connection is lost, e.g. because ovsdb-server
+// restarted, it is re-established with an exponential backoff of up to 8 seconds. The calls made while it is lost
+// fail with a temporary error.
+type OVSDBConnection struct {
+	*ovsdb.OVSDB
+	address string
+	mutex   sync.Mutex
+	// connected is true once the first connection is established.
+	connected bool
+	// reconnectHandlers are called each time the connection is re-established, e.g. to re-establish the monitors.
+	reconnectHandlers []func()
+}
+
+// onConnected is called by the OVSDB client each time the connection is established.
+func (c *OVSDBConnection) onConnected() error {
+	c.mutex.Lock()
+	reconnected := c.connected
+	c.connected = true
+	handlers := make([]func(), len(c.reconnectHandlers))
+	copy(handlers, c.reconnectHandlers)
+	c.mutex.Unlock()
+
+	if reconnected {
+		klog.Infof("Reconnected to OVSDB at address %s", c.address)
+	}
+	for _, handler := range handlers {
+		handler()
+	}
+	// ovsdb.Dial does not return until this function succeeds once, the handlers log their errors instead.
+	return nil
+}
+
+// addReconnectHandler adds a function which is called each time the connection is re-established. The handlers are
+// called in a goroutine of the OVSDB client, concurrently with the other calls.
+func (c *OVSDBConnection) addReconnectHandler(handler func()) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reconnectHandlers = append(c.reconnectHandlers, handler)
+}
+
 type OVSBridge struct {
-	ovsdb        *ovsdb.OVSDB
+	ovsdb        *OVSDBConnection
 	name         string
 	datapathType string
 	uuid         string
@@ -56,8 +97,9 @@ const (
 // specified by address.
 // If address is set to "", the default UNIX domain socket path
 // "/run/openvswitch/db.sock" will be used.
-// Returns the OVSDB struct on success.
-func NewOVSDBConnectionUDS(address string) (*ovsdb.OVSDB, Error) {
+// Returns the OVSDBConnection on success. The connection is re-established
+// if it is lost.
+func NewOVSDBConnectionUDS(address string) (*OVSDBConnection, Error) {
 	klog.Infof("Connecting to OVSDB at address %s", address)
 
 	if address == "" {
@@ -86,13 +128,18 @@ func NewOVSDBConnectionUDS(address string) (*ovsdb.OVSDB, Error) {
 		}
 	}()
 
-	db := ovsdb.Dial([][]string{{"unix", address}}, nil, nil)
+	conn := &OVSDBConnection{address: address}
+	// The OVSDB client reconnects with the same backoff when the connection is lost, and calls the initialize
+	// function each time it is established.
+	conn.OVSDB = ovsdb.Dial([][]string{{"unix", address}}, func(*ovsdb.OVSDB) error {
+		return conn.onConnected()
+	}, nil)
 	success <- true
-	return db, nil
+	return conn, nil
 }
 
 // NewOVSBridge creates and returns a new OVSBridge struct.
-func NewOVSBridge(bridgeName string, ovsDatapathType string, ovsdb *ovsdb.OVSDB) *OVSBridge {
+func NewOVSBridge(bridgeName string, ovsDatapathType string, ovsdb *OVSDBConnection) *OVSBridge {
 	return &OVSBridge{ovsdb, bridgeName, ovsDatapathType, ""}
 }
 
@@ -245,6 +292,73 @@ func (br *OVSBridge) SetExternalIDs(externalIDs map[string]interface{}) Error {
 	return nil
 }
 
+// GetOVSOtherConfig returns the other_config of the Open_vSwitch table, which configures ovs-vswitchd.
+func (br *OVSBridge) GetOVSOtherConfig() (map[string]string, Error) {
+	tx := br.ovsdb.Transaction(openvSwitchSchema)
+	tx.Select(dbtransaction.Select{
+		Table:   openvSwitchSchema,
+		Columns: []string{"other_config"},
+	})
+
+	res, err, temporary := tx.Commit()
+	if err != nil {
+		klog.Error("Transaction failed: ", err)
+		return nil, NewTransactionError(err, temporary)
+	}
+	if len(res[0].Rows) == 0 {
+		klog.Warning("Could not find other_config")
+		return nil, nil
+	}
+
+	otherConfigRes := res[0].Rows[0].(map[string]interface{})["other_config"].([]interface{})
+	return buildMapFromOVSDBMap(otherConfigRes), nil
+}
+
+// UpdateOVSOtherConfig adds the provided keys to the other_config of the Open_vSwitch table, or updates their values
+// if they already exist. The other keys are left untouched.
+func (br *OVSBridge) UpdateOVSOtherConfig(configs map[string]interface{}) Error {
+	keys := make([]interface{}, 0, len(configs))
+	for k := range configs {
+		keys = append(keys, k)
+	}
+	tx := br.ovsdb.Transaction(openvSwitchSchema)
+	// The insert mutation does not overwrite existing keys, so they are deleted first.
+	tx.Mutate(dbtransaction.Mutate{
+		Table: openvSwitchSchema,
+		Mutations: [][]interface{}{
+			{"other_config", "delete", []interface{}{"set", keys}},
+			{"other_config", "insert", helpers.MakeOVSDBMap(configs)},
+		},
+	})
+
+	_, err, temporary := tx.Commit()
+	if err != nil {
+		klog.Error("Transaction failed: ", err)
+		return NewTransactionError(err, temporary)
+	}
+	return nil
+}
+
+// DeleteOVSOtherConfig deletes the provided keys from the other_config of the Open_vSwitch table.
+func (br *OVSBridge) DeleteOVSOtherConfig(keys []string) Error {
+	keySet := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		keySet = append(keySet, k)
+	}
+	tx := br.ovsdb.Transaction(openvSwitchSchema)
+	tx.Mutate(dbtransaction.Mutate{
+		Table:     openvSwitchSchema,
+		Mutations: [][]interface{}{{"other_config", "delete", []interface{}{"set", keySet}}},
+	})
+
+	_, err, temporary := tx.Commit()
+	if err != nil {
+		klog.Error("Transaction failed: ", err)
+		return NewTransactionError(err, temporary)
+	}
+	return nil
+}
+
 // GetPortUUIDList returns UUIDs of all ports on the bridge.
 func (br *OVSBridge) GetPortUUIDList() ([]string, Error) {
 	tx := br.ovsdb.Transaction(openvSwitchSchema)
@@ -270,6 +384,9 @@ func (br *OVSBridge) DeletePorts(portUUIDList []string) Error {
 	mutateSet := helpers.MakeOVSDBSet(map[string]interface{}{
 		"uuid": portUUIDList,
 	})
+	if err := br.addDeletePortsQoS(tx, portUUIDList); err != nil {
+		return err
+	}
 	tx.Mutate(dbtransaction.Mutate{
 		Table:     "Bridge",
 		Mutations: [][]interface{}{{"ports", "delete", mutateSet}},
@@ -290,6 +407,9 @@ func (br *OVSBridge) DeletePort(portUUID string) Error {
 	mutateSet := helpers.MakeOVSDBSet(map[string]interface{}{
 		"uuid": []string{portUUID},
 	})
+	if err := br.addDeletePortsQoS(tx, []string{portUUID}); err != nil {
+		return err
+	}
 	tx.Mutate(dbtransaction.Mutate{
 		Table:     "Bridge",
 		Mutations: [][]interface{}{{"ports", "delete", mutateSet}},
@@ -319,7 +439,7 @@ func (br *OVSBridge) CreateInternalPort(name string, ofPortRequest int32, extern
 // the bridge.
 // If ofPortRequest is not zero, it will be passed to the OVS port creation.
 func (br *OVSBridge) CreateTunnelPort(name string, tunnelType TunnelType, ofPortRequest int32) (string, Error) {
-	return br.createTunnelPort(name, tunnelType, ofPortRequest, "", "", nil)
+	return br.createTunnelPort(name, tunnelType, ofPortRequest, "", "", "", nil)
 }
 
 // CreateTunnelPortExt creates a tunnel port with the specified name and type
@@ -327,9 +447,13 @@ func (br *OVSBridge) CreateTunnelPort(name string, tunnelType TunnelType, ofPort
 // If ofPortRequest is not zero, it will be passed to the OVS port creation.
 // If remoteIP is not empty, it will be set to the tunnel port interface
 // options; otherwise flow based tunneling will be configured.
-// psk is for the pre-shared key of IPSec ESP tunnel. If it is not empty, it
-// will be set to the tunnel port interface options. Flow based IPSec tunnel is
-// not supported, so remoteIP must be provided too when psk is not empty.
+// remoteName is for the name of the peer in its certificate, when the IKE peers
+// of the IPSec ESP tunnel authenticate each other with certificates signed by
+// the CA set in the Open_vSwitch table. psk is for the pre-shared key of IPSec
+// ESP tunnel. If they are not empty, they will be set to the tunnel port
+// interface options, at most one of them can be set. Flow based IPSec tunnel is
+// not supported, so remoteIP must be provided too when psk or remoteName is not
+// empty.
 // If externalIDs is not nill, the IDs in it will be added to the port's
 // external_ids.
 func (br *OVSBridge) CreateTunnelPortExt(
@@ -337,12 +461,16 @@ func (br *OVSBridge) CreateTunnelPortExt(
 	tunnelType TunnelType,
 	ofPortRequest int32,
 	remoteIP string,
+	remoteName string,
 	psk string,
 	externalIDs map[string]interface{}) (string, Error) {
-	if psk != "" && remoteIP != "" {
-		return "", newInvalidArgumentsError("IPSec tunnel can not be flow based. remoteIP must not be set")
+	if (psk != "" || remoteName != "") && remoteIP == "" {
+		return "", newInvalidArgumentsError("IPSec tunnel can not be flow based. remoteIP must be set")
 	}
-	return br.createTunnelPort(name, tunnelType, ofPortRequest, remoteIP, psk, externalIDs)
+	if psk != "" && remoteName != "" {
+		return "", newInvalidArgumentsError("IPSec tunnel can not use both PSK and certificate authentication")
+	}
+	return br.createTunnelPort(name, tunnelType, ofPortRequest, remoteIP, remoteName, psk, externalIDs)
 }
 
 func (br *OVSBridge) createTunnelPort(
@@ -350,6 +478,7 @@ func (br *OVSBridge) createTunnelPort(
 	tunnelType TunnelType,
 	ofPortRequest int32,
 	remoteIP string,
+	remoteName string,
 	psk string,
 	externalIDs map[string]interface{}) (string, Error) {
 
@@ -369,6 +498,9 @@ func (br *OVSBridge) createTunnelPort(
 		options["remote_ip"] = "flow"
 	}
 
+	if remoteName != "" {
+		options["remote_name"] = remoteName
+	}
 	if psk != "" {
 		options["psk"] = psk
 	}
@@ -385,57 +517,19 @@ func (br *OVSBridge) CreatePort(name, ifDev string, externalIDs map[string]inter
 }
 
 func (br *OVSBridge) createPort(name, ifName, ifType string, ofPortRequest int32, externalIDs, options map[string]interface{}) (string, Error) {
-	var externalIDMap []interface{}
-	var optionMap []interface{}
-
-	if externalIDs != nil {
-		externalIDMap = helpers.MakeOVSDBMap(externalIDs)
-	}
+	tx := br.NewPortTransaction()
+	tx.CreatePort(name, ifName, ifType, ofPortRequest)
 	if options != nil {
-		optionMap = helpers.MakeOVSDBMap(options)
+		tx.SetInterfaceOptions(name, options)
 	}
-
-	tx := br.ovsdb.Transaction(openvSwitchSchema)
-
-	interf := Interface{
-		Name:          ifName,
-		Type:          ifType,
-		OFPortRequest: ofPortRequest,
-		Options:       optionMap,
-	}
-	ifNamedUUID := tx.Insert(dbtransaction.Insert{
-		Table: "Interface",
-		Row:   interf,
-	})
-
-	port := Port{
-		Name: name,
-		Interfaces: helpers.MakeOVSDBSet(map[string]interface{}{
-			"named-uuid": []string{ifNamedUUID},
-		}),
-		ExternalIDs: externalIDMap,
+	if externalIDs != nil {
+		tx.SetPortExternalIDs(name, externalIDs)
 	}
-	portNamedUUID := tx.Insert(dbtransaction.Insert{
-		Table: "Port",
-		Row:   port,
-	})
-
-	mutateSet := helpers.MakeOVSDBSet(map[string]interface{}{
-		"named-uuid": []string{portNamedUUID},
-	})
-	tx.Mutate(dbtransaction.Mutate{
-		Table:     "Bridge",
-		Mutations: [][]interface{}{{"ports", "insert", mutateSet}},
-		Where:     [][]interface{}{{"name", "==", br.name}},
-	})
-
-	res, err, temporary := tx.Commit()
+	portUUIDs, err := tx.Commit()
 	if err != nil {
-		klog.Error("Transaction failed: ", err)
-		return "", NewTransactionError(err, temporary)
+		return "", err
 	}
-
-	return res[1].UUID[1], nil
+	return portUUIDs[name], nil
 }
 
 // GetOFPort retrieves the ofport value of an interface given the interface name.
@@ -0,0 +1,227 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovsconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/TomCodeLV/OVSDB-golang-lib/pkg/dbmonitor"
+	"github.com/TomCodeLV/OVSDB-golang-lib/pkg/helpers"
+	"k8s.io/klog"
+)
+
+// InterfaceEventType is the type of change of an interface of the bridge.
+type InterfaceEventType int
+
+const (
+	// InterfaceAdded is notified when an interface is added to the bridge.
+	InterfaceAdded InterfaceEventType = iota
+	// InterfaceUpdated is notified when the ofport of an interface of the bridge changes, e.g. when OVS assigns it.
+	InterfaceUpdated
+	// InterfaceDeleted is notified when an interface is removed from the bridge, or deleted from OVS.
+	InterfaceDeleted
+)
+
+var interfaceEventTypeStrs = [...]string{
+	InterfaceAdded:   "added",
+	InterfaceUpdated: "updated",
+	InterfaceDeleted: "deleted",
+}
+
+func (t InterfaceEventType) String() string {
+	if t < 0 || int(t) >= len(interfaceEventTypeStrs) {
+		return fmt.Sprintf("InterfaceEventType(%d)", int(t))
+	}
+	return interfaceEventTypeStrs[t]
+}
+
+// InterfaceEvent is a change of an interface of the bridge.
+type InterfaceEvent struct {
+	Type InterfaceEventType
+	// Name is the name of the interface.
+	Name string
+	// OFPort is the ofport of the interface, it is 0 if OVS has not assigned it yet and -1 if OVS failed to create
+	// the interface. It is the last ofport of the interface for InterfaceDeleted.
+	OFPort int32
+}
+
+// InterfaceEventHandler handles the changes of the interfaces of the bridge. It is called in the goroutine which
+// receives the messages of the OVSDB server, so it must not block nor call the OVSDB server.
+type InterfaceEventHandler func(event InterfaceEvent)
+
+// MonitorInterfaces monitors the Bridge, Port and Interface tables, and calls handler for each change of the
+// interfaces of the bridge, starting with an InterfaceAdded event for each existing interface. The monitor is
+// re-established when the connection to the OVSDB server is re-established, and the changes which happened while it
+// was lost are notified then.
+func (br *OVSBridge) MonitorInterfaces(handler InterfaceEventHandler) Error {
+	m := newInterfaceMonitor(br.name, handler)
+	if err := m.start(br.ovsdb); err != nil {
+		return err
+	}
+	br.ovsdb.addReconnectHandler(func() {
+		if err := m.start(br.ovsdb); err != nil {
+			klog.Errorf("Failed to re-establish the monitor of the interfaces of bridge %s: %v", br.name, err)
+		}
+	})
+	return nil
+}
+
+// interfaceMonitor tracks the interfaces of a bridge with the updates of an OVSDB monitor.
+type interfaceMonitor struct {
+	bridgeName string
+	handler    InterfaceEventHandler
+	// mutex protects the fields below, the initial state and the updates are applied by different goroutines.
+	mutex sync.Mutex
+	// bridgePorts maps the UUID of each bridge to its name and its port UUIDs.
+	bridges map[string]bridgeRow
+	// portInterfaces maps the UUID of each port to its interface UUIDs.
+	portInterfaces map[string][]string
+	// interfaces maps the UUID of each interface to its name and ofport.
+	interfaces map[string]interfaceRow
+	// ofPorts is the ofport of each interface of the bridge, by name, as last notified to the handler.
+	ofPorts map[string]int32
+}
+
+type bridgeRow struct {
+	name  string
+	ports []string
+}
+
+type interfaceRow struct {
+	name   string
+	ofPort int32
+}
+
+// tableUpdates is the format of the initial state and of the updates of an OVSDB monitor: the updates of the rows of
+// each table, by row UUID.
+type tableUpdates map[string]map[string]dbmonitor.RowUpdate
+
+func newInterfaceMonitor(bridgeName string, handler InterfaceEventHandler) *interfaceMonitor {
+	return &interfaceMonitor{
+		bridgeName:     bridgeName,
+		handler:        handler,
+		bridges:        map[string]bridgeRow{},
+		portInterfaces: map[string][]string{},
+		interfaces:     map[string]interfaceRow{},
+		ofPorts:        map[string]int32{},
+	}
+}
+
+// start starts the OVSDB monitor, and replaces the tracked rows with the initial state it returns.
+func (m *interfaceMonitor) start(conn *OVSDBConnection) Error {
+	monitor := conn.Monitor(openvSwitchSchema)
+	sel := dbmonitor.Select{Initial: true, Insert: true, Delete: true, Modify: true}
+	monitor.Register("Bridge", dbmonitor.Table{Columns: []string{"name", "ports"}, Select: sel})
+	monitor.Register("Port", dbmonitor.Table{Columns: []string{"interfaces"}, Select: sel})
+	monitor.Register("Interface", dbmonitor.Table{Columns: []string{"name", "ofport"}, Select: sel})
+	initial, err := monitor.Start(func(update json.RawMessage) {
+		m.update(update, false)
+	})
+	if err != nil {
+		return NewTransactionError(fmt.Errorf("failed to start the monitor of the interfaces: %v", err), true)
+	}
+	m.update(initial, true)
+	return nil
+}
+
+// update applies the initial state or an update of the OVSDB monitor, and notifies the handler of the changes of the
+// interfaces of the bridge. The initial state replaces all the tracked rows.
+func (m *interfaceMonitor) update(data json.RawMessage, initial bool) {
+	var updates tableUpdates
+	if err := json.Unmarshal(data, &updates); err != nil {
+		klog.Errorf("Failed to parse the update of the monitor of the interfaces: %v", err)
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if initial {
+		m.bridges = map[string]bridgeRow{}
+		m.portInterfaces = map[string][]string{}
+		m.interfaces = map[string]interfaceRow{}
+	}
+	// The new values of all the monitored columns are in the New row, which is nil when the row is deleted.
+	for uuid, row := range updates["Bridge"] {
+		if row.New == nil {
+			delete(m.bridges, uuid)
+			continue
+		}
+		name, _ := row.New["name"].(string)
+		m.bridges[uuid] = bridgeRow{name: name, ports: getUUIDs(row.New["ports"])}
+	}
+	for uuid, row := range updates["Port"] {
+		if row.New == nil {
+			delete(m.portInterfaces, uuid)
+			continue
+		}
+		m.portInterfaces[uuid] = getUUIDs(row.New["interfaces"])
+	}
+	for uuid, row := range updates["Interface"] {
+		if row.New == nil {
+			delete(m.interfaces, uuid)
+			continue
+		}
+		name, _ := row.New["name"].(string)
+		// ofport is an empty set until OVS assigns it.
+		var ofPort int32
+		if value, ok := row.New["ofport"].(float64); ok {
+			ofPort = int32(value)
+		}
+		m.interfaces[uuid] = interfaceRow{name: name, ofPort: ofPort}
+	}
+	m.notifyChanges()
+}
+
+// notifyChanges compares the interfaces of the bridge with the ones last notified, and notifies the handler of the
+// differences.
+func (m *interfaceMonitor) notifyChanges() {
+	ofPorts := map[string]int32{}
+	for _, bridge := range m.bridges {
+		if bridge.name != m.bridgeName {
+			continue
+		}
+		for _, portUUID := range bridge.ports {
+			for _, ifaceUUID := range m.portInterfaces[portUUID] {
+				if iface, ok := m.interfaces[ifaceUUID]; ok {
+					ofPorts[iface.name] = iface.ofPort
+				}
+			}
+		}
+	}
+	for name, ofPort := range ofPorts {
+		if oldOFPort, ok := m.ofPorts[name]; !ok {
+			m.handler(InterfaceEvent{Type: InterfaceAdded, Name: name, OFPort: ofPort})
+		} else if oldOFPort != ofPort {
+			m.handler(InterfaceEvent{Type: InterfaceUpdated, Name: name, OFPort: ofPort})
+		}
+	}
+	for name, oldOFPort := range m.ofPorts {
+		if _, ok := ofPorts[name]; !ok {
+			m.handler(InterfaceEvent{Type: InterfaceDeleted, Name: name, OFPort: oldOFPort})
+		}
+	}
+	m.ofPorts = ofPorts
+}
+
+// getUUIDs returns the UUIDs of a column which references other rows, i.e. a UUID or a set of UUIDs.
+func getUUIDs(column interface{}) []string {
+	data, ok := column.([]interface{})
+	if !ok || len(data) != 2 {
+		return nil
+	}
+	return helpers.GetIdListFromOVSDBSet(data)
+}
@@ -24,6 +24,7 @@ type Port struct {
 	Name        string        `json:"name"`
 	Interfaces  []interface{} `json:"interfaces"`
 	ExternalIDs []interface{} `json:"external_ids,omitempty"`
+	QoS         []interface{} `json:"qos,omitempty"`
 }
 
 type Interface struct {
@@ -31,4 +32,20 @@ type Interface struct {
 	Type          string        `json:"type,omitempty"`
 	OFPortRequest int32         `json:"ofport_request,omitempty"`
 	Options       []interface{} `json:"options,omitempty"`
+	MTURequest    int           `json:"mtu_request,omitempty"`
+	// The ingress policing rate is in kbps, and the burst in kb.
+	IngressPolicingRate  uint64 `json:"ingress_policing_rate,omitempty"`
+	IngressPolicingBurst uint64 `json:"ingress_policing_burst,omitempty"`
+}
+
+type QoS struct {
+	Type        string        `json:"type"`
+	OtherConfig []interface{} `json:"other_config,omitempty"`
+	Queues      []interface{} `json:"queues,omitempty"`
+	ExternalIDs []interface{} `json:"external_ids,omitempty"`
+}
+
+type Queue struct {
+	OtherConfig []interface{} `json:"other_config,omitempty"`
+	ExternalIDs []interface{} `json:"external_ids,omitempty"`
 }
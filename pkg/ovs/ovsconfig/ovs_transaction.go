@@ -0,0 +1,311 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovsconfig
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/TomCodeLV/OVSDB-golang-lib/pkg/dbtransaction"
+	"github.com/TomCodeLV/OVSDB-golang-lib/pkg/helpers"
+	"k8s.io/klog"
+)
+
+// qosPortExternalID is the external ID of the QoS and Queue rows created for a port, whose value is the name of the
+// port. Unlike the Port and Interface rows, they are not garbage collected by OVSDB when they are no longer
+// referenced, so they are deleted by the name of their port.
+const qosPortExternalID = "antrea-qos-port"
+
+// portOperations are the operations of a PortTransaction on a port.
+type portOperations struct {
+	name string
+	// create is true if the port is created by the transaction, ifName, ifType and ofPortRequest are then the
+	// configuration of its interface.
+	create        bool
+	ifName        string
+	ifType        string
+	ofPortRequest int32
+	options       map[string]interface{}
+	mtu           int
+	externalIDs   map[string]interface{}
+	// setQoS is true if the QoS of the port is replaced by the transaction. No QoS is set if maxRate is 0.
+	setQoS  bool
+	maxRate uint64
+	burst   uint64
+	// setIngressPolicing is true if the ingress policing of the interface is set by the transaction, it is disabled
+	// if policingRate is 0.
+	setIngressPolicing bool
+	policingRate       uint64
+	policingBurst      uint64
+}
+
+type portTransaction struct {
+	br *OVSBridge
+	// ports are the operations of the transaction by port name, portNames keeps the order of the ports.
+	ports     map[string]*portOperations
+	portNames []string
+	err       Error
+}
+
+// NewPortTransaction returns a PortTransaction which creates and configures ports of the bridge in one OVSDB
+// transaction.
+func (br *OVSBridge) NewPortTransaction() PortTransaction {
+	return &portTransaction{br: br, ports: map[string]*portOperations{}}
+}
+
+func (t *portTransaction) getPort(name string) *portOperations {
+	port, ok := t.ports[name]
+	if !ok {
+		port = &portOperations{name: name}
+		t.ports[name] = port
+		t.portNames = append(t.portNames, name)
+	}
+	return port
+}
+
+func (t *portTransaction) CreatePort(name, ifDev, ifType string, ofPortRequest int32) {
+	if ofPortRequest < 0 || ofPortRequest > ofPortRequestMax {
+		t.err = newInvalidArgumentsError(fmt.Sprint("invalid ofPortRequest value: ", ofPortRequest))
+		return
+	}
+	port := t.getPort(name)
+	port.create = true
+	port.ifName = ifDev
+	port.ifType = ifType
+	port.ofPortRequest = ofPortRequest
+}
+
+func (t *portTransaction) SetInterfaceOptions(name string, options map[string]interface{}) {
+	t.getPort(name).options = options
+}
+
+func (t *portTransaction) SetInterfaceMTU(name string, mtu int) {
+	t.getPort(name).mtu = mtu
+}
+
+func (t *portTransaction) SetPortExternalIDs(name string, externalIDs map[string]interface{}) {
+	t.getPort(name).externalIDs = externalIDs
+}
+
+func (t *portTransaction) SetPortQoS(name string, maxRate, burst uint64) {
+	port := t.getPort(name)
+	port.setQoS = true
+	port.maxRate = maxRate
+	port.burst = burst
+}
+
+func (t *portTransaction) SetInterfaceIngressPolicing(name string, rate, burst uint64) {
+	port := t.getPort(name)
+	port.setIngressPolicing = true
+	port.policingRate = rate
+	port.policingBurst = burst
+}
+
+func (t *portTransaction) Commit() (map[string]string, Error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	tx := t.br.ovsdb.Transaction(openvSwitchSchema)
+	// portInserts are the indexes of the results of the Port inserts, by port name.
+	portInserts := map[string]int{}
+	for _, name := range t.portNames {
+		port := t.ports[name]
+		var qos []interface{}
+		if port.setQoS {
+			qos = addPortQoS(tx, port)
+		}
+		if port.create {
+			portInserts[name] = t.addCreatePort(tx, port, qos)
+		} else {
+			t.addUpdatePort(tx, port, qos)
+		}
+	}
+
+	res, err, temporary := tx.Commit()
+	if err != nil {
+		klog.Error("Transaction failed: ", err)
+		return nil, NewTransactionError(err, temporary)
+	}
+	portUUIDs := make(map[string]string, len(portInserts))
+	for name, i := range portInserts {
+		portUUIDs[name] = res[i].UUID[1]
+	}
+	return portUUIDs, nil
+}
+
+// addCreatePort adds the insertion of the port and its interface to tx, and returns the index of the Port insert.
+func (t *portTransaction) addCreatePort(tx *dbtransaction.Transaction, port *portOperations, qos []interface{}) int {
+	interf := Interface{
+		Name:          port.ifName,
+		Type:          port.ifType,
+		OFPortRequest: port.ofPortRequest,
+		MTURequest:    port.mtu,
+	}
+	if port.setIngressPolicing {
+		interf.IngressPolicingRate = port.policingRate
+		interf.IngressPolicingBurst = port.policingBurst
+	}
+	if port.options != nil {
+		interf.Options = helpers.MakeOVSDBMap(port.options)
+	}
+	ifNamedUUID := tx.Insert(dbtransaction.Insert{
+		Table: "Interface",
+		Row:   interf,
+	})
+
+	portRow := Port{
+		Name: port.name,
+		Interfaces: helpers.MakeOVSDBSet(map[string]interface{}{
+			"named-uuid": []string{ifNamedUUID},
+		}),
+		QoS: qos,
+	}
+	if port.externalIDs != nil {
+		portRow.ExternalIDs = helpers.MakeOVSDBMap(port.externalIDs)
+	}
+	portIndex := len(tx.Actions)
+	portNamedUUID := tx.Insert(dbtransaction.Insert{
+		Table: "Port",
+		Row:   portRow,
+	})
+
+	mutateSet := helpers.MakeOVSDBSet(map[string]interface{}{
+		"named-uuid": []string{portNamedUUID},
+	})
+	tx.Mutate(dbtransaction.Mutate{
+		Table:     "Bridge",
+		Mutations: [][]interface{}{{"ports", "insert", mutateSet}},
+		Where:     [][]interface{}{{"name", "==", t.br.name}},
+	})
+	return portIndex
+}
+
+// addUpdatePort adds the update of an existing port and of its interface to tx. The interface of the port is
+// expected to have the name of the port.
+func (t *portTransaction) addUpdatePort(tx *dbtransaction.Transaction, port *portOperations, qos []interface{}) {
+	interfaceRow := map[string]interface{}{}
+	if port.options != nil {
+		interfaceRow["options"] = helpers.MakeOVSDBMap(port.options)
+	}
+	if port.mtu != 0 {
+		interfaceRow["mtu_request"] = port.mtu
+	}
+	if port.setIngressPolicing {
+		interfaceRow["ingress_policing_rate"] = port.policingRate
+		interfaceRow["ingress_policing_burst"] = port.policingBurst
+	}
+	if len(interfaceRow) > 0 {
+		tx.Update(dbtransaction.Update{
+			Table: "Interface",
+			Where: [][]interface{}{{"name", "==", port.name}},
+			Row:   interfaceRow,
+		})
+	}
+
+	portRow := map[string]interface{}{}
+	if port.externalIDs != nil {
+		portRow["external_ids"] = helpers.MakeOVSDBMap(port.externalIDs)
+	}
+	if port.setQoS {
+		if qos == nil {
+			qos = helpers.MakeOVSDBSet(map[string]interface{}{})
+		}
+		portRow["qos"] = qos
+	}
+	if len(portRow) > 0 {
+		tx.Update(dbtransaction.Update{
+			Table: "Port",
+			Where: [][]interface{}{{"name", "==", port.name}},
+			Row:   portRow,
+		})
+	}
+}
+
+// addPortQoS adds to tx the deletion of the QoS of the port, and the insertion of its new QoS if maxRate is not 0.
+// It returns the reference to the new QoS row, or nil if there is none. The traffic output to the port is shaped
+// with a linux-htb QoS, whose default queue has the max rate and the burst of the port.
+func addPortQoS(tx *dbtransaction.Transaction, port *portOperations) []interface{} {
+	addDeleteQoS(tx, port.name)
+	if port.maxRate == 0 {
+		return nil
+	}
+	externalIDs := helpers.MakeOVSDBMap(map[string]interface{}{qosPortExternalID: port.name})
+	queueConfig := map[string]interface{}{"max-rate": strconv.FormatUint(port.maxRate, 10)}
+	if port.burst != 0 {
+		queueConfig["burst"] = strconv.FormatUint(port.burst, 10)
+	}
+	queueNamedUUID := tx.Insert(dbtransaction.Insert{
+		Table: "Queue",
+		Row: Queue{
+			OtherConfig: helpers.MakeOVSDBMap(queueConfig),
+			ExternalIDs: externalIDs,
+		},
+	})
+	qosNamedUUID := tx.Insert(dbtransaction.Insert{
+		Table: "QoS",
+		Row: QoS{
+			Type:        "linux-htb",
+			OtherConfig: helpers.MakeOVSDBMap(map[string]interface{}{"max-rate": strconv.FormatUint(port.maxRate, 10)}),
+			// The queues column maps the queue numbers to the Queue rows, the traffic is sent to queue 0 by default.
+			Queues:      []interface{}{"map", []interface{}{[]interface{}{0, []string{"named-uuid", queueNamedUUID}}}},
+			ExternalIDs: externalIDs,
+		},
+	})
+	return []interface{}{"named-uuid", qosNamedUUID}
+}
+
+// addDeleteQoS adds to tx the deletion of the QoS and Queue rows created for the port portName. The reference of the
+// port to its QoS must be removed in the same transaction.
+func addDeleteQoS(tx *dbtransaction.Transaction, portName string) {
+	where := [][]interface{}{{"external_ids", "includes", helpers.MakeOVSDBMap(map[string]interface{}{qosPortExternalID: portName})}}
+	tx.Delete(dbtransaction.Delete{Table: "QoS", Where: where})
+	tx.Delete(dbtransaction.Delete{Table: "Queue", Where: where})
+}
+
+// addDeletePortsQoS adds to tx the deletion of the QoS and Queue rows created for the ports in portUUIDList, which
+// tx deletes.
+func (br *OVSBridge) addDeletePortsQoS(tx *dbtransaction.Transaction, portUUIDList []string) Error {
+	selectTx := br.ovsdb.Transaction(openvSwitchSchema)
+	for _, portUUID := range portUUIDList {
+		selectTx.Select(dbtransaction.Select{
+			Table:   "Port",
+			Columns: []string{"name", "qos"},
+			Where:   [][]interface{}{{"_uuid", "==", []string{"uuid", portUUID}}},
+		})
+	}
+	res, err, temporary := selectTx.Commit()
+	if err != nil {
+		klog.Error("Transaction failed: ", err)
+		return NewTransactionError(err, temporary)
+	}
+	for i, result := range res {
+		// The ports which do not exist are ignored.
+		for _, row := range result.Rows {
+			port := row.(map[string]interface{})
+			if len(helpers.GetIdListFromOVSDBSet(port["qos"].([]interface{}))) == 0 {
+				continue
+			}
+			// The port is garbage collected after the referential integrity is checked, so its reference to the
+			// QoS is removed first.
+			tx.Update(dbtransaction.Update{
+				Table: "Port",
+				Where: [][]interface{}{{"_uuid", "==", []string{"uuid", portUUIDList[i]}}},
+				Row:   map[string]interface{}{"qos": helpers.MakeOVSDBSet(map[string]interface{}{})},
+			})
+			addDeleteQoS(tx, port["name"].(string))
+		}
+	}
+	return nil
+}
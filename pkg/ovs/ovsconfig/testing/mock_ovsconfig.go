@@ -14,7 +14,7 @@
 //
 
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig (interfaces: OVSBridgeClient)
+// Source: github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig (interfaces: OVSBridgeClient,PortTransaction)
 
 // Package testing is a generated GoMock package.
 package testing
@@ -108,18 +108,18 @@ func (mr *MockOVSBridgeClientMockRecorder) CreateTunnelPort(arg0, arg1, arg2 int
 }
 
 // CreateTunnelPortExt mocks base method
-func (m *MockOVSBridgeClient) CreateTunnelPortExt(arg0 string, arg1 ovsconfig.TunnelType, arg2 int32, arg3, arg4 string, arg5 map[string]interface{}) (string, ovsconfig.Error) {
+func (m *MockOVSBridgeClient) CreateTunnelPortExt(arg0 string, arg1 ovsconfig.TunnelType, arg2 int32, arg3, arg4, arg5 string, arg6 map[string]interface{}) (string, ovsconfig.Error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTunnelPortExt", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret := m.ctrl.Call(m, "CreateTunnelPortExt", arg0, arg1, arg2, arg3, arg4, arg5, arg6)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(ovsconfig.Error)
 	return ret0, ret1
 }
 
 // CreateTunnelPortExt indicates an expected call of CreateTunnelPortExt
-func (mr *MockOVSBridgeClientMockRecorder) CreateTunnelPortExt(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+func (mr *MockOVSBridgeClientMockRecorder) CreateTunnelPortExt(arg0, arg1, arg2, arg3, arg4, arg5, arg6 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTunnelPortExt", reflect.TypeOf((*MockOVSBridgeClient)(nil).CreateTunnelPortExt), arg0, arg1, arg2, arg3, arg4, arg5)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTunnelPortExt", reflect.TypeOf((*MockOVSBridgeClient)(nil).CreateTunnelPortExt), arg0, arg1, arg2, arg3, arg4, arg5, arg6)
 }
 
 // Delete mocks base method
@@ -136,6 +136,20 @@ func (mr *MockOVSBridgeClientMockRecorder) Delete() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockOVSBridgeClient)(nil).Delete))
 }
 
+// DeleteOVSOtherConfig mocks base method
+func (m *MockOVSBridgeClient) DeleteOVSOtherConfig(arg0 []string) ovsconfig.Error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOVSOtherConfig", arg0)
+	ret0, _ := ret[0].(ovsconfig.Error)
+	return ret0
+}
+
+// DeleteOVSOtherConfig indicates an expected call of DeleteOVSOtherConfig
+func (mr *MockOVSBridgeClientMockRecorder) DeleteOVSOtherConfig(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOVSOtherConfig", reflect.TypeOf((*MockOVSBridgeClient)(nil).DeleteOVSOtherConfig), arg0)
+}
+
 // DeletePort mocks base method
 func (m *MockOVSBridgeClient) DeletePort(arg0 string) ovsconfig.Error {
 	m.ctrl.T.Helper()
@@ -194,6 +208,21 @@ func (mr *MockOVSBridgeClientMockRecorder) GetOFPort(arg0 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOFPort", reflect.TypeOf((*MockOVSBridgeClient)(nil).GetOFPort), arg0)
 }
 
+// GetOVSOtherConfig mocks base method
+func (m *MockOVSBridgeClient) GetOVSOtherConfig() (map[string]string, ovsconfig.Error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOVSOtherConfig")
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(ovsconfig.Error)
+	return ret0, ret1
+}
+
+// GetOVSOtherConfig indicates an expected call of GetOVSOtherConfig
+func (mr *MockOVSBridgeClientMockRecorder) GetOVSOtherConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOVSOtherConfig", reflect.TypeOf((*MockOVSBridgeClient)(nil).GetOVSOtherConfig))
+}
+
 // GetOVSVersion mocks base method
 func (m *MockOVSBridgeClient) GetOVSVersion() (string, ovsconfig.Error) {
 	m.ctrl.T.Helper()
@@ -239,6 +268,34 @@ func (mr *MockOVSBridgeClientMockRecorder) GetPortList() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPortList", reflect.TypeOf((*MockOVSBridgeClient)(nil).GetPortList))
 }
 
+// MonitorInterfaces mocks base method
+func (m *MockOVSBridgeClient) MonitorInterfaces(arg0 ovsconfig.InterfaceEventHandler) ovsconfig.Error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MonitorInterfaces", arg0)
+	ret0, _ := ret[0].(ovsconfig.Error)
+	return ret0
+}
+
+// MonitorInterfaces indicates an expected call of MonitorInterfaces
+func (mr *MockOVSBridgeClientMockRecorder) MonitorInterfaces(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MonitorInterfaces", reflect.TypeOf((*MockOVSBridgeClient)(nil).MonitorInterfaces), arg0)
+}
+
+// NewPortTransaction mocks base method
+func (m *MockOVSBridgeClient) NewPortTransaction() ovsconfig.PortTransaction {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewPortTransaction")
+	ret0, _ := ret[0].(ovsconfig.PortTransaction)
+	return ret0
+}
+
+// NewPortTransaction indicates an expected call of NewPortTransaction
+func (mr *MockOVSBridgeClientMockRecorder) NewPortTransaction() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewPortTransaction", reflect.TypeOf((*MockOVSBridgeClient)(nil).NewPortTransaction))
+}
+
 // SetExternalIDs mocks base method
 func (m *MockOVSBridgeClient) SetExternalIDs(arg0 map[string]interface{}) ovsconfig.Error {
 	m.ctrl.T.Helper()
@@ -266,3 +323,127 @@ func (mr *MockOVSBridgeClientMockRecorder) SetInterfaceMTU(arg0, arg1 interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInterfaceMTU", reflect.TypeOf((*MockOVSBridgeClient)(nil).SetInterfaceMTU), arg0, arg1)
 }
+
+// UpdateOVSOtherConfig mocks base method
+func (m *MockOVSBridgeClient) UpdateOVSOtherConfig(arg0 map[string]interface{}) ovsconfig.Error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOVSOtherConfig", arg0)
+	ret0, _ := ret[0].(ovsconfig.Error)
+	return ret0
+}
+
+// UpdateOVSOtherConfig indicates an expected call of UpdateOVSOtherConfig
+func (mr *MockOVSBridgeClientMockRecorder) UpdateOVSOtherConfig(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOVSOtherConfig", reflect.TypeOf((*MockOVSBridgeClient)(nil).UpdateOVSOtherConfig), arg0)
+}
+
+// MockPortTransaction is a mock of PortTransaction interface
+type MockPortTransaction struct {
+	ctrl     *gomock.Controller
+	recorder *MockPortTransactionMockRecorder
+}
+
+// MockPortTransactionMockRecorder is the mock recorder for MockPortTransaction
+type MockPortTransactionMockRecorder struct {
+	mock *MockPortTransaction
+}
+
+// NewMockPortTransaction creates a new mock instance
+func NewMockPortTransaction(ctrl *gomock.Controller) *MockPortTransaction {
+	mock := &MockPortTransaction{ctrl: ctrl}
+	mock.recorder = &MockPortTransactionMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockPortTransaction) EXPECT() *MockPortTransactionMockRecorder {
+	return m.recorder
+}
+
+// Commit mocks base method
+func (m *MockPortTransaction) Commit() (map[string]string, ovsconfig.Error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Commit")
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(ovsconfig.Error)
+	return ret0, ret1
+}
+
+// Commit indicates an expected call of Commit
+func (mr *MockPortTransactionMockRecorder) Commit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockPortTransaction)(nil).Commit))
+}
+
+// CreatePort mocks base method
+func (m *MockPortTransaction) CreatePort(arg0, arg1, arg2 string, arg3 int32) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CreatePort", arg0, arg1, arg2, arg3)
+}
+
+// CreatePort indicates an expected call of CreatePort
+func (mr *MockPortTransactionMockRecorder) CreatePort(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePort", reflect.TypeOf((*MockPortTransaction)(nil).CreatePort), arg0, arg1, arg2, arg3)
+}
+
+// SetInterfaceIngressPolicing mocks base method
+func (m *MockPortTransaction) SetInterfaceIngressPolicing(arg0 string, arg1, arg2 uint64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetInterfaceIngressPolicing", arg0, arg1, arg2)
+}
+
+// SetInterfaceIngressPolicing indicates an expected call of SetInterfaceIngressPolicing
+func (mr *MockPortTransactionMockRecorder) SetInterfaceIngressPolicing(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInterfaceIngressPolicing", reflect.TypeOf((*MockPortTransaction)(nil).SetInterfaceIngressPolicing), arg0, arg1, arg2)
+}
+
+// SetInterfaceMTU mocks base method
+func (m *MockPortTransaction) SetInterfaceMTU(arg0 string, arg1 int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetInterfaceMTU", arg0, arg1)
+}
+
+// SetInterfaceMTU indicates an expected call of SetInterfaceMTU
+func (mr *MockPortTransactionMockRecorder) SetInterfaceMTU(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInterfaceMTU", reflect.TypeOf((*MockPortTransaction)(nil).SetInterfaceMTU), arg0, arg1)
+}
+
+// SetInterfaceOptions mocks base method
+func (m *MockPortTransaction) SetInterfaceOptions(arg0 string, arg1 map[string]interface{}) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetInterfaceOptions", arg0, arg1)
+}
+
+// SetInterfaceOptions indicates an expected call of SetInterfaceOptions
+func (mr *MockPortTransactionMockRecorder) SetInterfaceOptions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInterfaceOptions", reflect.TypeOf((*MockPortTransaction)(nil).SetInterfaceOptions), arg0, arg1)
+}
+
+// SetPortExternalIDs mocks base method
+func (m *MockPortTransaction) SetPortExternalIDs(arg0 string, arg1 map[string]interface{}) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetPortExternalIDs", arg0, arg1)
+}
+
+// SetPortExternalIDs indicates an expected call of SetPortExternalIDs
+func (mr *MockPortTransactionMockRecorder) SetPortExternalIDs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPortExternalIDs", reflect.TypeOf((*MockPortTransaction)(nil).SetPortExternalIDs), arg0, arg1)
+}
+
+// SetPortQoS mocks base method
+func (m *MockPortTransaction) SetPortQoS(arg0 string, arg1, arg2 uint64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetPortQoS", arg0, arg1, arg2)
+}
+
+// SetPortQoS indicates an expected call of SetPortQoS
+func (mr *MockPortTransactionMockRecorder) SetPortQoS(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPortQoS", reflect.TypeOf((*MockPortTransaction)(nil).SetPortQoS), arg0, arg1, arg2)
+}
@@ -33,6 +33,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/vishvananda/netlink"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
 	k8sFake "k8s.io/client-go/kubernetes/fake"
 
 	"github.com/vmware-tanzu/antrea/pkg/agent"
@@ -103,6 +106,7 @@ const (
 
 var ipamMock *ipamtest.MockIPAMDriver
 var ovsServiceMock *ovsconfigtest.MockOVSBridgeClient
+var portTransactionMock *ovsconfigtest.MockPortTransaction
 var ofServiceMock *openflowtest.MockClient
 var testNodeConfig *agent.NodeConfig
 
@@ -466,7 +470,10 @@ func (tester *cmdAddDelTester) cmdDelTest(tc testCase, dataDir string) {
 func newTester() *cmdAddDelTester {
 	tester := &cmdAddDelTester{}
 	ifaceStore := agent.NewInterfaceStore()
-	tester.server = cniserver.New(testSock, "", 1450, testNodeConfig, ovsServiceMock, ofServiceMock, ifaceStore, k8sFake.NewSimpleClientset())
+	k8sClient := k8sFake.NewSimpleClientset()
+	podInformer := informers.NewSharedInformerFactory(k8sClient, 0).Core().V1().Pods()
+	podInformer.Informer().GetStore().Add(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: testPod, Namespace: testPodNamespace}})
+	tester.server = cniserver.New(testSock, "", 1450, testNodeConfig, ovsServiceMock, ofServiceMock, ifaceStore, k8sClient, podInformer)
 	ctx, _ := context.WithCancel(context.Background())
 	tester.ctx = ctx
 	return tester
@@ -491,7 +498,11 @@ func cmdAddDelCheckTest(testNS ns.NetNS, tc testCase, dataDir string) {
 	// Mock ovs output while get ovs port external configuration
 	ovsPortname := util.GenerateContainerInterfaceName(testPod, testPodNamespace)
 	ovsPortUUID := uuid.New().String()
-	ovsServiceMock.EXPECT().CreatePort(ovsPortname, ovsPortname, mock.Any()).Return(ovsPortUUID, nil).AnyTimes()
+	ovsServiceMock.EXPECT().NewPortTransaction().Return(portTransactionMock).AnyTimes()
+	portTransactionMock.EXPECT().CreatePort(ovsPortname, ovsPortname, "", int32(0)).AnyTimes()
+	portTransactionMock.EXPECT().SetPortExternalIDs(ovsPortname, mock.Any()).AnyTimes()
+	portTransactionMock.EXPECT().SetInterfaceMTU(ovsPortname, mock.Any()).AnyTimes()
+	portTransactionMock.EXPECT().Commit().Return(map[string]string{ovsPortname: ovsPortUUID}, nil).AnyTimes()
 	ovsServiceMock.EXPECT().GetOFPort(ovsPortname).Return(int32(10), nil).AnyTimes()
 	ofServiceMock.EXPECT().InstallPodFlows(ovsPortname, mock.Any(), mock.Any(), mock.Any(), mock.Any()).Return(nil)
 
@@ -540,6 +551,7 @@ func TestAntreaServerFunc(t *testing.T) {
 	ipamMock = ipamtest.NewMockIPAMDriver(controller)
 	_ = ipam.RegisterIPAMDriver("mock", ipamMock)
 	ovsServiceMock = ovsconfigtest.NewMockOVSBridgeClient(controller)
+	portTransactionMock = ovsconfigtest.NewMockPortTransaction(controller)
 	ofServiceMock = openflowtest.NewMockClient(controller)
 
 	var originalNS ns.NetNS
@@ -17,6 +17,7 @@ package agent
 import (
 	"fmt"
 	"net"
+	"os/exec"
 	"strings"
 	"testing"
 
@@ -28,6 +29,7 @@ import (
 
 	ofClient "github.com/vmware-tanzu/antrea/pkg/agent/openflow"
 	"github.com/vmware-tanzu/antrea/pkg/agent/types"
+	binding "github.com/vmware-tanzu/antrea/pkg/ovs/openflow"
 	ofTestUtils "github.com/vmware-tanzu/antrea/pkg/ovs/openflow/testing"
 )
 
@@ -74,10 +76,12 @@ type testConfig struct {
 	tunnelOFPort uint32
 	serviceCIDR  *net.IPNet
 	globalMAC    net.HardwareAddr
+	roundNum     uint64
+	nodeConfig   *types.NodeConfig
 }
 
 func TestConnectivityFlows(t *testing.T) {
-	c = ofClient.NewClient(br)
+	c = ofClient.NewClient(br, binding.BackendNative, false)
 	err := ofTestUtils.PrepareOVSBridge(br)
 	if err != nil {
 		t.Errorf("failed to prepare OVS bridge: %v", br)
@@ -89,7 +93,10 @@ func TestConnectivityFlows(t *testing.T) {
 		}
 	}()
 
-	defer c.Disconnect()
+	// c is replaced by testReplayFlows, so it must be evaluated when the test completes.
+	defer func() {
+		c.Disconnect()
+	}()
 
 	config := prepareConfiguration()
 	for _, f := range []func(t *testing.T, config *testConfig){
@@ -99,6 +106,8 @@ func TestConnectivityFlows(t *testing.T) {
 		testInstallTunnelFlows,
 		testInstallNodeFlows,
 		testInstallPodFlows,
+		testReplayFlows,
+		testReplayFlowsAfterFlowLoss,
 		testUninstallPodFlows,
 		testUninstallNodeFlows,
 	} {
@@ -107,7 +116,7 @@ func TestConnectivityFlows(t *testing.T) {
 }
 
 func testInitialize(t *testing.T, config *testConfig) {
-	if err := c.Initialize(); err != nil {
+	if _, err := c.Initialize(config.roundNum, config.nodeConfig); err != nil {
 		t.Errorf("failed to initialize openflow client: %v", err)
 	}
 	for _, tableFlow := range prepareDefaultFlows() {
@@ -115,6 +124,63 @@ func testInitialize(t *testing.T, config *testConfig) {
 	}
 }
 
+// testReplayFlows simulates an Agent restart: a new client replays the desired state with the next round number,
+// then deletes the flows installed in the previous round. The replayed flows must not be affected.
+func testReplayFlows(t *testing.T, config *testConfig) {
+	c.Disconnect()
+	c = ofClient.NewClient(config.bridge, binding.BackendNative, false)
+	config.roundNum++
+	for _, f := range []func(t *testing.T, config *testConfig){
+		testInitialize,
+		testInstallGatewayFlows,
+		testInstallServiceFlows,
+		testInstallTunnelFlows,
+		testInstallNodeFlows,
+		testInstallPodFlows,
+	} {
+		f(t, config)
+	}
+	if err := c.DeleteStaleFlows(); err != nil {
+		t.Fatalf("Failed to delete stale flows: %v", err)
+	}
+	for _, tableFlow := range prepareDefaultFlows() {
+		ofTestUtils.CheckFlowExists(t, config.bridge, tableFlow.tableID, true, tableFlow.flows)
+	}
+	for _, pod := range config.localPods {
+		for _, tableFlow := range preparePodFlows(pod.ip, pod.mac, pod.ofPort, config.localGateway.mac, config.globalMAC) {
+			ofTestUtils.CheckFlowExists(t, config.bridge, tableFlow.tableID, true, tableFlow.flows)
+		}
+	}
+}
+
+// testReplayFlowsAfterFlowLoss simulates the loss of all the flows when ovs-vswitchd restarts, and checks that
+// ReplayFlows installs them again.
+func testReplayFlowsAfterFlowLoss(t *testing.T, config *testConfig) {
+	err := exec.Command("/bin/sh", "-c", fmt.Sprintf("sudo /usr/bin/ovs-ofctl del-flows %s", config.bridge)).Run()
+	require.Nil(t, err, "Failed to delete all flows")
+	require.Nil(t, c.ReplayFlows(), "Failed to replay flows")
+
+	for _, tableFlow := range prepareDefaultFlows() {
+		ofTestUtils.CheckFlowExists(t, config.bridge, tableFlow.tableID, true, tableFlow.flows)
+	}
+	for _, tableFlow := range prepareGatewayFlows(config.localGateway.ip, config.localGateway.mac, config.localGateway.ofPort) {
+		ofTestUtils.CheckFlowExists(t, config.bridge, tableFlow.tableID, true, tableFlow.flows)
+	}
+	for _, tableFlow := range prepareTunnelFlows(config.tunnelOFPort, config.globalMAC) {
+		ofTestUtils.CheckFlowExists(t, config.bridge, tableFlow.tableID, true, tableFlow.flows)
+	}
+	for _, node := range config.peers {
+		for _, tableFlow := range prepareNodeFlows(node.subnet, node.gateway, node.nodeAddress, config.globalMAC, config.localGateway.mac) {
+			ofTestUtils.CheckFlowExists(t, config.bridge, tableFlow.tableID, true, tableFlow.flows)
+		}
+	}
+	for _, pod := range config.localPods {
+		for _, tableFlow := range preparePodFlows(pod.ip, pod.mac, pod.ofPort, config.localGateway.mac, config.globalMAC) {
+			ofTestUtils.CheckFlowExists(t, config.bridge, tableFlow.tableID, true, tableFlow.flows)
+		}
+	}
+}
+
 func testInstallTunnelFlows(t *testing.T, config *testConfig) {
 	err := c.InstallTunnelFlows(config.tunnelOFPort)
 	if err != nil {
@@ -137,7 +203,7 @@ func testInstallServiceFlows(t *testing.T, config *testConfig) {
 
 func testInstallNodeFlows(t *testing.T, config *testConfig) {
 	for _, node := range config.peers {
-		err := c.InstallNodeFlows("peer", config.localGateway.mac, node.gateway, node.subnet, node.nodeAddress)
+		err := c.InstallNodeFlows("peer", config.localGateway.mac, map[*net.IPNet]net.IP{&node.subnet: node.gateway}, node.nodeAddress, 0)
 		if err != nil {
 			t.Fatalf("Failed to install Openflow entries for node connectivity: %v", err)
 		}
@@ -161,7 +227,7 @@ func testUninstallNodeFlows(t *testing.T, config *testConfig) {
 
 func testInstallPodFlows(t *testing.T, config *testConfig) {
 	for _, pod := range config.localPods {
-		err := c.InstallPodFlows(pod.name, pod.ip, pod.mac, config.localGateway.mac, pod.ofPort)
+		err := c.InstallPodFlows(pod.name, []net.IP{pod.ip}, pod.mac, config.localGateway.mac, pod.ofPort)
 		if err != nil {
 			t.Fatalf("Failed to install Openflow entries for pod: %v", err)
 		}
@@ -183,8 +249,70 @@ func testUninstallPodFlows(t *testing.T, config *testConfig) {
 	}
 }
 
+func TestProxyFlows(t *testing.T) {
+	c = ofClient.NewClient(br, binding.BackendNative, true)
+	err := ofTestUtils.PrepareOVSBridge(br)
+	require.Nil(t, err, fmt.Sprintf("Failed to prepare OVS bridge %s", br))
+	defer func() {
+		err = ofTestUtils.DeleteOVSBridge(br)
+		assert.Nil(t, err, fmt.Sprintf("Error while deleting OVS bridge: %v", err))
+	}()
+	defer c.Disconnect()
+
+	config := prepareConfiguration()
+	_, err = c.Initialize(config.roundNum, config.nodeConfig)
+	require.Nil(t, err, "Failed to initialize OpenFlow client")
+	err = c.InstallClusterServiceCIDRFlows(config.serviceCIDR, config.localGateway.ofPort)
+	require.Nil(t, err, "Failed to install Service CIDR flows")
+
+	groupID := binding.GroupIDType(1)
+	svcPort := &types.ServicePort{IP: net.ParseIP("172.16.0.10"), Port: 80, Protocol: coreV1.ProtocolTCP}
+	endpoints := []*types.Endpoint{
+		{IP: config.localPods[0].ip, Port: 8080},
+		{IP: config.peers[0].gateway, Port: 8080},
+	}
+	err = c.InstallServiceFlows(groupID, svcPort, endpoints, 100)
+	require.Nil(t, err, "Failed to install Service flows")
+	for _, tableFlow := range prepareProxyFlows(*config.serviceCIDR, groupID, svcPort, endpoints, 100) {
+		ofTestUtils.CheckFlowExists(t, config.bridge, tableFlow.tableID, true, tableFlow.flows)
+	}
+
+	// Removing an Endpoint and the session affinity removes the flows of the Endpoint and the learn flow.
+	err = c.InstallServiceFlows(groupID, svcPort, endpoints[:1], 0)
+	require.Nil(t, err, "Failed to update Service flows")
+	for _, tableFlow := range prepareProxyFlows(*config.serviceCIDR, groupID, svcPort, endpoints[:1], 0) {
+		ofTestUtils.CheckFlowExists(t, config.bridge, tableFlow.tableID, true, tableFlow.flows)
+	}
+	for _, tableFlow := range prepareProxyFlows(*config.serviceCIDR, groupID, svcPort, endpoints[1:], 0) {
+		if tableFlow.tableID == uint8(42) {
+			ofTestUtils.CheckFlowExists(t, config.bridge, tableFlow.tableID, false, tableFlow.flows[1:])
+		}
+	}
+
+	err = c.UninstallServiceFlows(groupID, svcPort)
+	require.Nil(t, err, "Failed to uninstall Service flows")
+	for _, tableFlow := range prepareProxyFlows(*config.serviceCIDR, groupID, svcPort, endpoints[:1], 0) {
+		if tableFlow.tableID == uint8(42) {
+			ofTestUtils.CheckFlowExists(t, config.bridge, tableFlow.tableID, false, tableFlow.flows[1:])
+		}
+	}
+
+	// The NodePorts are load balanced as ports of the NodePort virtual IP.
+	err = c.InstallNodePortFlows(types.NodePortVirtualIP)
+	require.Nil(t, err, "Failed to install NodePort flows")
+	ofTestUtils.CheckFlowExists(t, config.bridge, uint8(31), true, []*ofTestUtils.ExpectFlow{
+		{fmt.Sprintf("priority=210,ct_state=+new+trk,ip,reg0=0x1/0xffff,nw_dst=%s", types.NodePortVirtualIP.String()), "resubmit(,40),resubmit(,41)"},
+	})
+	nodePort := &types.ServicePort{IP: types.NodePortVirtualIP, Port: 30080, Protocol: coreV1.ProtocolTCP}
+	err = c.InstallServiceFlows(groupID+1, nodePort, endpoints, 0)
+	require.Nil(t, err, "Failed to install NodePort Service flows")
+	for _, tableFlow := range prepareProxyFlows(*config.serviceCIDR, groupID+1, nodePort, endpoints, 0) {
+		ofTestUtils.CheckFlowExists(t, config.bridge, tableFlow.tableID, true, tableFlow.flows)
+	}
+}
+
 func TestNetworkPolicyFlows(t *testing.T) {
-	c = ofClient.NewClient(br)
+	c = ofClient.NewClient(br, binding.BackendNative, false)
 	err := ofTestUtils.PrepareOVSBridge(br)
 	require.Nil(t, err, fmt.Sprintf("Failed to prepare OVS bridge %s", br))
 
@@ -192,7 +320,8 @@ func TestNetworkPolicyFlows(t *testing.T) {
 		err = ofTestUtils.DeleteOVSBridge(br)
 	}()
 
-	err = c.Initialize()
+	config := prepareConfiguration()
+	_, err = c.Initialize(1, config.nodeConfig)
 	require.Nil(t, err, "Failed to ininitalize OFClient")
 
 	ruleID := uint32(100)
@@ -229,7 +358,7 @@ func TestNetworkPolicyFlows(t *testing.T) {
 	// Dump flows.
 	flowList, err := ofTestUtils.OfctlDumpFlows(br, ingressRuleTable)
 	require.Nil(t, err, "Failed to dump flows")
-	conjMatch := fmt.Sprintf("priority=%d,ip,reg1=0x%x", priorityNormal, ofport)
+	conjMatch := fmt.Sprintf("priority=%d,reg1=0x%x", priorityNormal, ofport)
 	flow := &ofTestUtils.ExpectFlow{conjMatch, fmt.Sprintf("conjunction(%d,2/3)", ruleID)}
 	assert.True(t, ofTestUtils.OfctlFlowMatch(flowList, ingressRuleTable, flow), "Failed to install conjunctive match flow")
 
@@ -412,7 +541,7 @@ func checkConjunctionFlows(t *testing.T, ruleTable uint8, dropTable uint8, allow
 }
 
 func testInstallGatewayFlows(t *testing.T, config *testConfig) {
-	err := c.InstallGatewayFlows(config.localGateway.ip, config.localGateway.mac, config.localGateway.ofPort)
+	err := c.InstallGatewayFlows([]net.IP{config.localGateway.ip}, config.localGateway.mac, config.localGateway.ofPort)
 	if err != nil {
 		t.Fatalf("Failed to install Openflow entries for gateway: %v", err)
 	}
@@ -437,6 +566,7 @@ func prepareConfiguration() *testConfig {
 		mac:    gwMAC,
 		ofPort: uint32(1),
 	}
+	_, podCIDR, _ := net.ParseCIDR("192.168.1.0/24")
 	_, serviceCIDR, _ := net.ParseCIDR("172.16.0.0/16")
 	_, peerSubnet, _ := net.ParseCIDR("192.168.2.0/24")
 	peerNode := &testPeerConfig{
@@ -454,6 +584,13 @@ func prepareConfiguration() *testConfig {
 		tunnelOFPort: uint32(2),
 		serviceCIDR:  serviceCIDR,
 		globalMAC:    vMAC,
+		roundNum:     1,
+		nodeConfig: &types.NodeConfig{
+			Bridge:        br,
+			Name:          "n1",
+			PodIPv4CIDR:   podCIDR,
+			GatewayConfig: &types.GatewayConfig{IPv4: gwCfg.ip, MAC: gwCfg.mac, Name: "gw0"},
+		},
 	}
 }
 
@@ -571,7 +708,7 @@ func prepareNodeFlows(peerSubnet net.IPNet, peerGwIP, peerNodeIP net.IP, vMAC, l
 func prepareServiceHelperFlows(serviceCIDR net.IPNet) []expectTableFlows {
 	return []expectTableFlows{
 		{
-			uint8(40),
+			uint8(41),
 			[]*ofTestUtils.ExpectFlow{
 				{fmt.Sprintf("priority=200,ip,nw_dst=%s", serviceCIDR.String()), "output:1"},
 			},
@@ -579,6 +716,42 @@ func prepareServiceHelperFlows(serviceCIDR net.IPNet) []expectTableFlows {
 	}
 }
 
+// prepareProxyFlows returns the flows of the Service CIDR and of the Service port. The first flow of table 42 is the
+// default flow, the next ones are the flows of the endpoints.
+func prepareProxyFlows(serviceCIDR net.IPNet, groupID binding.GroupIDType, svcPort *types.ServicePort, endpoints []*types.Endpoint, affinityTimeout uint16) []expectTableFlows {
+	svcMatch := fmt.Sprintf("nw_dst=%s,tp_dst=%d", svcPort.IP.String(), svcPort.Port)
+	lbFlows := []*ofTestUtils.ExpectFlow{
+		{"priority=210,ip,reg4=0x10000/0x30000", "resubmit(,42)"},
+		{fmt.Sprintf("priority=200,tcp,%s", svcMatch), fmt.Sprintf("group:%d", groupID)},
+	}
+	if affinityTimeout != 0 {
+		lbFlows = append(lbFlows, &ofTestUtils.ExpectFlow{
+			MatchStr: fmt.Sprintf("priority=210,tcp,reg4=0x20000/0x30000,%s", svcMatch),
+			ActStr:   fmt.Sprintf("learn(table=40,hard_timeout=%d,priority=200,", affinityTimeout),
+		})
+	}
+	dnatFlows := []*ofTestUtils.ExpectFlow{
+		{"priority=190,ip,reg4=0x10000/0x30000", "load:0->NXM_NX_REG4[16..17],resubmit(,41)"},
+	}
+	for _, endpoint := range endpoints {
+		ip := endpoint.IP.To4()
+		dnatFlows = append(dnatFlows, &ofTestUtils.ExpectFlow{
+			MatchStr: fmt.Sprintf("priority=200,tcp,reg3=0x%02x%02x%02x%02x,reg4=0x%x/0xffff,%s", ip[0], ip[1], ip[2], ip[3], endpoint.Port, svcMatch),
+			ActStr:   fmt.Sprintf("ct(commit,table=50,zone=65520,nat(dst=%s:%d))", endpoint.IP.String(), endpoint.Port),
+		})
+	}
+	return []expectTableFlows{
+		{
+			uint8(31),
+			[]*ofTestUtils.ExpectFlow{
+				{fmt.Sprintf("priority=210,ct_state=+new+trk,ip,nw_dst=%s", serviceCIDR.String()), "resubmit(,40),resubmit(,41)"},
+			},
+		},
+		{uint8(41), lbFlows},
+		{uint8(42), dnatFlows},
+	}
+}
+
 func prepareDefaultFlows() []expectTableFlows {
 	return []expectTableFlows{
 		{
@@ -599,23 +772,23 @@ func prepareDefaultFlows() []expectTableFlows {
 		{
 			uint8(30),
 			[]*ofTestUtils.ExpectFlow{
-				{"priority=200,ip", "ct(table=31,zone=65520)"},
+				{"priority=200,ip", "ct(table=31,zone=65520,nat)"},
 				{"priority=80,ip", "resubmit(,31)"},
 			},
 		},
 		{
 			uint8(31),
 			[]*ofTestUtils.ExpectFlow{
-				{"priority=210,ct_state=-new+trk,ct_mark=0x20,ip,reg0=0x1/0xffff", "resubmit(,40)"},
-				{"priority=200,ct_state=+new+trk,ip,reg0=0x1/0xffff", "ct(commit,table=40,zone=65520,exec(load:0x20->NXM_NX_CT_MARK[],move:NXM_OF_ETH_SRC[]->NXM_NX_CT_LABEL[0..47]))"},
-				{"priority=200,ct_state=-new+trk,ct_mark=0x20,ip", "move:NXM_NX_CT_LABEL[0..47]->NXM_OF_ETH_DST[],resubmit(,40)"},
+				{"priority=210,ct_state=-new+trk,ct_mark=0x20,ip,reg0=0x1/0xffff", "resubmit(,41)"},
+				{"priority=200,ct_state=+new+trk,ip,reg0=0x1/0xffff", "ct(commit,table=41,zone=65520,exec(load:0x20->NXM_NX_CT_MARK[],move:NXM_OF_ETH_SRC[]->NXM_NX_CT_LABEL[0..47]))"},
+				{"priority=200,ct_state=-new+trk,ct_mark=0x20,ip", "move:NXM_NX_CT_LABEL[0..47]->NXM_OF_ETH_DST[],resubmit(,41)"},
 				{"priority=200,ct_state=+new+inv,ip", "drop"},
-				{"priority=190,ct_state=+new+trk,ip", "ct(commit,table=40,zone=65520)"},
-				{"priority=80,ip", "resubmit(,40)"},
+				{"priority=190,ct_state=+new+trk,ip", "ct(commit,table=41,zone=65520)"},
+				{"priority=80,ip", "resubmit(,41)"},
 			},
 		},
 		{
-			uint8(40),
+			uint8(41),
 			[]*ofTestUtils.ExpectFlow{{"priority=80,ip", "resubmit(,50)"}},
 		},
 		{
@@ -20,7 +20,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/TomCodeLV/OVSDB-golang-lib/pkg/ovsdb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/vmware-tanzu/antrea/pkg/ovs/ovsconfig"
@@ -36,7 +35,7 @@ var UDSAddress string
 var bridgeName string
 
 type testData struct {
-	ovsdb *ovsdb.OVSDB
+	ovsdb *ovsconfig.OVSDBConnection
 	br    *ovsconfig.OVSBridge
 }
 
@@ -123,6 +122,144 @@ func TestOVSDeletePortIdempotent(t *testing.T) {
 	testDeletePort(t, data.br, uuid)
 }
 
+// TestOVSIPSecTunnelPort tests creating an IPSec tunnel port, which must have a
+// remote IP, with PSK or certificate authentication.
+func TestOVSIPSecTunnelPort(t *testing.T) {
+	data := &testData{}
+	data.setup(t)
+	defer data.teardown(t)
+
+	deleteAllPorts(t, data.br)
+
+	_, err := data.br.CreateTunnelPortExt("ipsec0", ovsconfig.VXLANTunnel, 0, "", "", "psk", nil)
+	assert.NotNil(t, err, "Flow based IPSec tunnel port should not be created")
+
+	externalIDs := map[string]interface{}{"k1": "v1"}
+	uuid, err := data.br.CreateTunnelPortExt("ipsec1", ovsconfig.VXLANTunnel, 0, "192.168.1.1", "", "psk", externalIDs)
+	require.Nil(t, err, "Failed to create IPSec tunnel port")
+	port, err := data.br.GetPortData(uuid, "ipsec1")
+	require.Nil(t, err, "Failed to get IPSec tunnel port")
+	require.NotNil(t, port, "IPSec tunnel port not found")
+	assert.Equal(t, "192.168.1.1", port.Options["remote_ip"])
+	assert.Equal(t, "psk", port.Options["psk"])
+	assert.Equal(t, "v1", port.ExternalIDs["k1"])
+	testDeletePort(t, data.br, uuid)
+
+	_, err = data.br.CreateTunnelPortExt("ipsec2", ovsconfig.VXLANTunnel, 0, "192.168.1.2", "node2", "psk", nil)
+	assert.NotNil(t, err, "IPSec tunnel port should not be created with both PSK and certificate authentication")
+
+	uuid, err = data.br.CreateTunnelPortExt("ipsec3", ovsconfig.VXLANTunnel, 0, "192.168.1.3", "node3", "", nil)
+	require.Nil(t, err, "Failed to create IPSec tunnel port")
+	port, err = data.br.GetPortData(uuid, "ipsec3")
+	require.Nil(t, err, "Failed to get IPSec tunnel port")
+	require.NotNil(t, port, "IPSec tunnel port not found")
+	assert.Equal(t, "node3", port.Options["remote_name"])
+	assert.Empty(t, port.Options["psk"])
+	testDeletePort(t, data.br, uuid)
+}
+
+// TestOVSMonitorInterfaces tests that the changes of the interfaces of the
+// bridge are notified, starting with the existing interfaces.
+func TestOVSMonitorInterfaces(t *testing.T) {
+	data := &testData{}
+	data.setup(t)
+	defer data.teardown(t)
+
+	deleteAllPorts(t, data.br)
+
+	uuid1 := testCreatePort(t, data.br, "p1", "internal")
+	events := make(chan ovsconfig.InterfaceEvent, 10)
+	err := data.br.MonitorInterfaces(func(event ovsconfig.InterfaceEvent) {
+		events <- event
+	})
+	require.Nil(t, err, "Failed to monitor interfaces")
+
+	expectEvent := func(eventType ovsconfig.InterfaceEventType, name string) ovsconfig.InterfaceEvent {
+		for {
+			select {
+			case event := <-events:
+				if event.Type == eventType && event.Name == name {
+					return event
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Interface %s was not %s", name, eventType)
+			}
+		}
+	}
+	event := expectEvent(ovsconfig.InterfaceAdded, "p1")
+	if event.OFPort <= 0 {
+		event = expectEvent(ovsconfig.InterfaceUpdated, "p1")
+	}
+	ofPort, err := data.br.GetOFPort("p1")
+	require.Nil(t, err, "Failed to get ofport")
+	assert.Equal(t, ofPort, event.OFPort)
+
+	uuid2 := testCreatePort(t, data.br, "p2", "internal")
+	expectEvent(ovsconfig.InterfaceAdded, "p2")
+
+	testDeletePort(t, data.br, uuid1)
+	event = expectEvent(ovsconfig.InterfaceDeleted, "p1")
+	assert.Equal(t, ofPort, event.OFPort)
+	testDeletePort(t, data.br, uuid2)
+	expectEvent(ovsconfig.InterfaceDeleted, "p2")
+}
+
+// TestOVSPortTransaction tests that the operations of a PortTransaction are
+// applied together, or not at all.
+func TestOVSPortTransaction(t *testing.T) {
+	data := &testData{}
+	data.setup(t)
+	defer data.teardown(t)
+
+	deleteAllPorts(t, data.br)
+
+	tx := data.br.NewPortTransaction()
+	tx.CreatePort("p1", "p1", "internal", 0)
+	tx.SetPortExternalIDs("p1", map[string]interface{}{"k1": "v1"})
+	tx.SetInterfaceMTU("p1", 1400)
+	tx.SetPortQoS("p1", 10000000, 1000000)
+	tx.SetInterfaceIngressPolicing("p1", 10000, 1000)
+	tx.CreatePort("p2", "p2", "vxlan", 0)
+	tx.SetInterfaceOptions("p2", map[string]interface{}{"remote_ip": "192.168.1.1"})
+	portUUIDs, err := tx.Commit()
+	require.Nil(t, err, "Failed to commit port transaction")
+	require.Len(t, portUUIDs, 2)
+
+	port, err := data.br.GetPortData(portUUIDs["p1"], "p1")
+	require.Nil(t, err, "Failed to get port")
+	require.NotNil(t, port, "Port not found")
+	assert.Equal(t, "v1", port.ExternalIDs["k1"])
+	port, err = data.br.GetPortData(portUUIDs["p2"], "p2")
+	require.Nil(t, err, "Failed to get port")
+	require.NotNil(t, port, "Port not found")
+	assert.Equal(t, "192.168.1.1", port.Options["remote_ip"])
+
+	// The existing ports are updated.
+	tx = data.br.NewPortTransaction()
+	tx.SetPortExternalIDs("p1", map[string]interface{}{"k2": "v2"})
+	tx.SetPortQoS("p1", 0, 0)
+	tx.SetInterfaceIngressPolicing("p1", 0, 0)
+	_, err = tx.Commit()
+	require.Nil(t, err, "Failed to commit port transaction")
+	port, err = data.br.GetPortData(portUUIDs["p1"], "p1")
+	require.Nil(t, err, "Failed to get port")
+	assert.Equal(t, map[string]string{"k2": "v2"}, port.ExternalIDs)
+
+	// No port is created if an operation fails, here the creation of a port
+	// whose name is taken.
+	tx = data.br.NewPortTransaction()
+	tx.CreatePort("p3", "p3", "internal", 0)
+	tx.CreatePort("p1", "p1", "internal", 0)
+	_, err = tx.Commit()
+	assert.NotNil(t, err, "Port transaction should fail")
+	portList, err := data.br.GetPortList()
+	require.Nil(t, err, "Failed to get ports")
+	assert.Len(t, portList, 2)
+
+	testDeletePort(t, data.br, portUUIDs["p1"])
+	testDeletePort(t, data.br, portUUIDs["p2"])
+}
+
 // TestOVSBridgeExternalIDs tests getting and setting external IDs of the OVS
 // bridge.
 func TestOVSBridgeExternalIDs(t *testing.T) {
@@ -149,6 +286,31 @@ func TestOVSBridgeExternalIDs(t *testing.T) {
 	}
 }
 
+// TestOVSOtherConfig tests updating and deleting keys of the other_config of
+// the Open_vSwitch table.
+func TestOVSOtherConfig(t *testing.T) {
+	data := &testData{}
+	data.setup(t)
+	defer data.teardown(t)
+
+	err := data.br.UpdateOVSOtherConfig(map[string]interface{}{"flow-restore-wait": "true", "k1": "v1"})
+	require.Nil(t, err, "Failed to update other_config")
+	err = data.br.UpdateOVSOtherConfig(map[string]interface{}{"k1": "v2"})
+	require.Nil(t, err, "Failed to update other_config")
+
+	otherConfig, err := data.br.GetOVSOtherConfig()
+	require.Nil(t, err, "Failed to get other_config")
+	assert.Equal(t, "true", otherConfig["flow-restore-wait"])
+	assert.Equal(t, "v2", otherConfig["k1"])
+
+	err = data.br.DeleteOVSOtherConfig([]string{"flow-restore-wait", "k1"})
+	require.Nil(t, err, "Failed to delete other_config")
+	otherConfig, err = data.br.GetOVSOtherConfig()
+	require.Nil(t, err, "Failed to get other_config")
+	assert.NotContains(t, otherConfig, "flow-restore-wait")
+	assert.NotContains(t, otherConfig, "k1")
+}
+
 func deleteAllPorts(t *testing.T, br *ovsconfig.OVSBridge) {
 	portList, err := br.GetPortUUIDList()
 	require.Nil(t, err, "Error when retrieving port list")